@@ -1,15 +1,18 @@
 package main
 
 import (
+	"AiHackathon-admin/internal/auth"
 	"AiHackathon-admin/internal/clients/gemini"
 	"AiHackathon-admin/internal/config"
 	"AiHackathon-admin/internal/scheduler"
 	"AiHackathon-admin/internal/services"
 	"AiHackathon-admin/internal/storage/mysql"
 	"AiHackathon-admin/internal/storage/nas"
+	"AiHackathon-admin/internal/storage/s3"
 	"AiHackathon-admin/internal/web"
 	"AiHackathon-admin/internal/web/handlers"
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -23,13 +26,28 @@ import (
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 )
 
+const (
+	configPath  = "./configs"
+	configName  = "config"
+	templateDir = "internal/web/templates"
+)
+
 func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	checkConfig := flag.Bool("check-config", false, "檢查設定 (Cron 表達式、prompt 檔案、NAS 路徑與資料庫連線) 後結束")
+	flag.Parse()
+
+	if *checkConfig {
+		os.Exit(runConfigCheck())
+	}
 
-	cfg, err := config.Load("./configs", "config")
+	cfg, err := config.Load(configPath, configName)
 	if err != nil {
 		log.Fatalf("錯誤：無法載入設定: %v", err)
 	}
+	if err := config.Validate(cfg); err != nil {
+		log.Fatalf("錯誤：設定驗證失敗:\n%v", err)
+	}
 	log.Println("資訊：應用程式設定載入成功。")
 
 	// 資料庫遷移
@@ -59,9 +77,21 @@ func main() {
 		log.Printf("資訊：資料庫遷移成功完成，版本更新至: %d。", newVersion)
 	}
 
-	nasStorage, err := nas.NewFileSystemStorage(cfg.NAS)
-	if err != nil {
-		log.Fatalf("錯誤：初始化 NAS 儲存失敗: %v", err)
+	// nas.backend 為 s3 時影片存放於物件儲存，nas.videoPath 作為本地快取 (變更需重新啟動)
+	var nasForService services.NASStorage
+	var mediaObjects handlers.MediaObjectStore
+	if cfg.NAS.Backend == "s3" {
+		objectStorage, err := s3.NewObjectStorage(cfg.NAS)
+		if err != nil {
+			log.Fatalf("錯誤：初始化 S3 物件儲存失敗: %v", err)
+		}
+		nasForService, mediaObjects = objectStorage, objectStorage
+	} else {
+		nasStorage, err := nas.NewFileSystemStorage(cfg.NAS)
+		if err != nil {
+			log.Fatalf("錯誤：初始化 NAS 儲存失敗: %v", err)
+		}
+		nasForService = nasStorage
 	}
 
 	var dbStore handlers.DBStore
@@ -72,33 +102,90 @@ func main() {
 	dbStore = realDBStore
 	defer realDBStore.Close()
 
-	// 初始化 Gemini 客戶端時傳入模型名稱
-	// 您可以將這些模型名稱也移到 config.yaml 中
-	textModelName := "gemini-1.5-flash-latest"  // 或者 cfg.GeminiClient.TextModel
-	videoModelName := "gemini-1.5-flash-latest" // 或者 cfg.GeminiClient.VideoModel
-	geminiClient, err := gemini.NewClient(cfg.GeminiClient.APIKey, textModelName, videoModelName)
+	// 模型名稱與各分析任務的生成參數皆來自 geminiClient 設定 (變更需重新啟動)
+	geminiClient, err := gemini.NewClient(cfg.GeminiClient)
 	if err != nil {
 		log.Fatalf("錯誤：初始化 Gemini 客戶端失敗: %v", err)
 	}
 
-	var nasForService services.NASStorage = nasStorage
 	fetchSvc, err := services.NewFetchService(cfg, dbStore, nasForService)
 	if err != nil {
 		log.Fatalf("錯誤：初始化影片擷取服務失敗: %v", err)
 	}
-	analyzeSvc, err := services.NewAnalyzeService(cfg, dbStore, nasForService, geminiClient)
+	promptSvc, err := services.NewPromptService(cfg, dbStore)
+	if err != nil {
+		log.Fatalf("錯誤：初始化 Prompt 服務失敗: %v", err)
+	}
+	if err := promptSvc.ImportFileVersions(); err != nil {
+		log.Printf("警告：匯入設定檔中的 Prompt 版本時發生錯誤: %v", err)
+	}
+	analyzeSvc, err := services.NewAnalyzeService(cfg, dbStore, nasForService, geminiClient, promptSvc)
 	if err != nil {
 		log.Fatalf("錯誤：初始化影片分析服務失敗: %v", err)
 	}
+	webhookSvc, err := services.NewWebhookService(cfg, dbStore)
+	if err != nil {
+		log.Fatalf("錯誤：初始化 Webhook 服務失敗: %v", err)
+	}
+	analyzeSvc.AddCompletionNotifier(webhookSvc)
+	alertSvc, err := services.NewAlertService(cfg, dbStore)
+	if err != nil {
+		log.Fatalf("錯誤：初始化快訊服務失敗: %v", err)
+	}
+	analyzeSvc.AddCompletionNotifier(alertSvc)
+	costSvc, err := services.NewCostService(cfg, dbStore)
+	if err != nil {
+		log.Fatalf("錯誤：初始化費用服務失敗: %v", err)
+	}
+	analyzeSvc.SetBudgetChecker(costSvc) // 達到每月預算後，排程、手動觸發與檔案監看皆不再呼叫 Gemini
+	retentionSvc, err := services.NewRetentionService(cfg, dbStore, nasForService)
+	if err != nil {
+		log.Fatalf("錯誤：初始化保留政策服務失敗: %v", err)
+	}
+	thumbnailSvc, err := services.NewThumbnailService(cfg, dbStore, nasForService)
+	if err != nil {
+		log.Fatalf("錯誤：初始化縮圖服務失敗: %v", err)
+	}
+	webProxySvc, err := services.NewWebProxyService(cfg, dbStore, nasForService)
+	if err != nil {
+		log.Fatalf("錯誤：初始化播放版本服務失敗: %v", err)
+	}
+	webhookSvc.ResumePending()
 
+	// 網頁介面登入驗證
+	authManager, err := auth.NewManager(dbStore, cfg.Auth)
+	if err != nil {
+		log.Fatalf("錯誤：初始化登入驗證失敗: %v", err)
+	}
+	if err := authManager.EnsureBootstrapAdmin(); err != nil {
+		log.Fatalf("錯誤：%v", err)
+	}
+	authManager.CleanupExpiredSessions()
+	var oidcProvider *auth.OIDCProvider
+	if cfg.Auth.Enabled && cfg.Auth.OIDC.Enabled {
+		ctxOIDC, cancelOIDC := context.WithTimeout(context.Background(), 15*time.Second)
+		oidcProvider, err = auth.NewOIDCProvider(ctxOIDC, cfg.Auth.OIDC)
+		cancelOIDC()
+		if err != nil {
+			log.Printf("錯誤：初始化 OIDC 失敗，將僅提供本機帳號登入: %v", err)
+			oidcProvider = nil
+		}
+	}
+
+	var appScheduler *scheduler.Scheduler
 	if cfg.Scheduler.Enabled {
 		log.Println("資訊：排程器已在設定檔中啟用，正在初始化...")
-		appScheduler := scheduler.NewScheduler(
-			fetchSvc,
-			analyzeSvc,
-			cfg.Scheduler.FetchCronSpec,
-			cfg.Scheduler.AnalyzeCronSpec,
-		)
+		appScheduler, err = scheduler.NewScheduler(scheduler.Services{
+			Fetch:      fetchSvc,
+			Analyze:    analyzeSvc,
+			Costs:      costSvc,
+			Retention:  retentionSvc,
+			Thumbnails: thumbnailSvc,
+			WebProxies: webProxySvc,
+		}, cfg.Scheduler)
+		if err != nil {
+			log.Fatalf("錯誤：初始化排程器失敗: %v", err)
+		}
 		appScheduler.Start()
 		log.Println("資訊：排程器已啟動。")
 		defer appScheduler.Stop()
@@ -106,7 +193,44 @@ func main() {
 		log.Println("資訊：排程器已在設定檔中禁用。")
 	}
 
-	router := web.SetupRouter(cfg, dbStore, analyzeSvc) // 傳遞 analyzeSvc 給路由
+	// 設定熱重載：檔案變更或收到 SIGHUP 時重新載入 prompt、排程與頁面範本
+	reload := &reloader{configPath: configPath, configName: configName, templateDir: templateDir, current: cfg, prompts: promptSvc, costs: costSvc, retention: retentionSvc, thumbnails: thumbnailSvc, webProxies: webProxySvc, scheduler: appScheduler}
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	if watcher, err := config.Watch(watchCtx, reload.watchPaths(), 500*time.Millisecond, func() { reload.Reload("檔案變更") }); err != nil {
+		log.Printf("警告：無法監看設定檔變更，僅能以 SIGHUP 重新載入: %v", err)
+	} else {
+		reload.setWatcher(watcher)
+	}
+	// 監看 NAS 下載目錄，新素材下載完成後立即進行文本元數據分析 (設定變更需重新啟動)
+	if cfg.NAS.Watch.Enabled {
+		watchSvc, err := services.NewWatchService(cfg, analyzeSvc)
+		if err != nil {
+			log.Fatalf("錯誤：初始化 NAS 監看服務失敗: %v", err)
+		}
+		if err := watchSvc.Start(watchCtx); err != nil {
+			log.Printf("警告：無法監看 NAS 下載目錄，新素材將等待排程分析: %v", err)
+		}
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reload.Reload("SIGHUP")
+		}
+	}()
+
+	router := web.SetupRouter(cfg, web.Dependencies{
+		DB:           dbStore,
+		Analyze:      analyzeSvc,
+		Webhooks:     webhookSvc,
+		Alerts:       alertSvc,
+		Costs:        costSvc,
+		Retention:    retentionSvc,
+		MediaObjects: mediaObjects,
+		Auth:         authManager,
+		OIDC:         oidcProvider,
+	})
 	serverAddr := ":8080"
 	server := &http.Server{
 		Addr:    serverAddr,
@@ -131,5 +255,38 @@ func main() {
 		log.Fatalf("錯誤：HTTP 伺服器優雅關閉失敗: %v", err)
 	}
 	log.Println("資訊：HTTP 伺服器已關閉。")
+	if err := webhookSvc.Close(ctx); err != nil {
+		log.Printf("警告：%v", err)
+	}
 	log.Println("資訊：應用程式已成功關閉。")
 }
+
+// runConfigCheck 執行 --check-config：載入並驗證設定、測試資料庫連線，回傳程序結束碼
+func runConfigCheck() int {
+	cfg, err := config.Load(configPath, configName)
+	if err != nil {
+		fmt.Printf("設定載入失敗: %v\n", err)
+		return 1
+	}
+	ok := true
+	if err := config.Validate(cfg); err != nil {
+		fmt.Printf("設定驗證失敗:\n%v\n", err)
+		ok = false
+	}
+	if err := gemini.ValidateSettings(cfg.GeminiClient); err != nil {
+		fmt.Printf("Gemini 生成參數無效:\n%v\n", err)
+		ok = false
+	}
+	store, err := mysql.NewMySQLStore(cfg.Database)
+	if err != nil {
+		fmt.Printf("資料庫連線失敗: %v\n", err)
+		ok = false
+	} else {
+		store.Close()
+	}
+	if !ok {
+		return 1
+	}
+	fmt.Println("設定檢查通過。")
+	return 0
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/scheduler"
+	"AiHackathon-admin/internal/services"
+	"AiHackathon-admin/internal/web/handlers"
+	"log"
+	"slices"
+	"sync"
+)
+
+// reloader 於設定檔或範本變更 (或收到 SIGHUP) 時重新載入 prompt、排程表達式、費用設定、保留規則、縮圖與播放版本設定及頁面範本。
+// 其他設定 (資料庫、NAS、登入、Webhook 等) 仍需重新啟動才會生效。
+type reloader struct {
+	mu          sync.Mutex
+	configPath  string
+	configName  string
+	templateDir string
+	current     *config.Config
+	watcher     *config.Watcher // 未能監看檔案變更時為 nil (僅能以 SIGHUP 重新載入)
+	prompts     *services.PromptService
+	costs       *services.CostService
+	retention   *services.RetentionService
+	thumbnails  *services.ThumbnailService
+	webProxies  *services.WebProxyService
+	scheduler   *scheduler.Scheduler // 排程器未啟用時為 nil
+}
+
+// Reload 重新載入設定；新設定未通過驗證時保留原設定
+func (r *reloader) Reload(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	log.Printf("資訊：[Reload] 重新載入設定 (%s)...", reason)
+	newCfg, err := config.Load(r.configPath, r.configName)
+	if err != nil {
+		log.Printf("錯誤：[Reload] 無法載入設定，維持原設定: %v", err)
+		return
+	}
+	if err := config.Validate(newCfg); err != nil {
+		log.Printf("錯誤：[Reload] 新設定未通過驗證，維持原設定:\n%v", err)
+		return
+	}
+
+	if err := r.prompts.UpdatePrompts(newCfg.Prompts); err != nil {
+		log.Printf("警告：[Reload] 更新 Prompt 設定時發生錯誤: %v", err)
+	}
+	r.costs.UpdateConfig(newCfg.Costs)
+	r.retention.UpdateConfig(newCfg.Retention)
+	r.thumbnails.UpdateConfig(newCfg.Thumbnails)
+	r.webProxies.UpdateConfig(newCfg.WebProxy)
+	if !slices.Equal(newCfg.WebProxy.Extensions, r.current.WebProxy.Extensions) {
+		log.Println("警告：[Reload] webProxy.extensions 的變更需重新啟動應用程式才會套用至 /media/ 的播放版本選擇。")
+	}
+	if newCfg.Scheduler.Enabled != r.current.Scheduler.Enabled {
+		log.Println("警告：[Reload] scheduler.enabled 的變更需重新啟動應用程式才會生效。")
+	}
+	if newCfg.NAS.Watch != r.current.NAS.Watch {
+		log.Println("警告：[Reload] nas.watch 的變更需重新啟動應用程式才會生效。")
+	}
+	if newCfg.NAS.Backend != r.current.NAS.Backend || newCfg.NAS.S3 != r.current.NAS.S3 {
+		log.Println("警告：[Reload] nas.backend 與 nas.s3 的變更需重新啟動應用程式才會生效。")
+	}
+	if newCfg.MediaSigning != r.current.MediaSigning {
+		log.Println("警告：[Reload] mediaSigning 的變更需重新啟動應用程式才會生效。")
+	}
+	if r.scheduler != nil {
+		if err := r.scheduler.Reschedule(newCfg.Scheduler); err != nil {
+			log.Printf("錯誤：[Reload] 更新排程失敗，維持原排程: %v", err)
+		}
+	}
+	if err := handlers.ReloadTemplates(); err != nil {
+		log.Printf("錯誤：[Reload] 重新載入頁面範本失敗，維持原範本: %v", err)
+	}
+	r.current = newCfg
+	// prompt 版本可能新增或移至其他目錄，依新設定更新監看的目錄
+	r.watcher.SetPaths(r.watchPaths())
+	log.Println("資訊：[Reload] 設定重新載入完成。")
+}
+
+// setWatcher 設定檔案監看器，之後每次重新載入都會依新設定更新監看的目錄
+func (r *reloader) setWatcher(w *config.Watcher) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.watcher = w
+}
+
+// watchPaths 回傳需監看變更的目錄：設定檔、prompt 檔案與頁面範本
+func (r *reloader) watchPaths() []string {
+	paths := []string{r.configPath, r.templateDir}
+	for _, p := range r.current.Prompts.VideoAnalysis.Versions {
+		paths = append(paths, p)
+	}
+	for _, p := range r.current.Prompts.TextFileAnalysis.Versions {
+		paths = append(paths, p)
+	}
+	return paths
+}
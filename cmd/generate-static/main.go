@@ -2,17 +2,24 @@ package main
 
 import (
 	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/mediasign"
 	"AiHackathon-admin/internal/models"
+	"AiHackathon-admin/internal/renditions"
 	"AiHackathon-admin/internal/storage/mysql"
+	"AiHackathon-admin/internal/storage/nas"
+	"AiHackathon-admin/internal/storage/s3"
 	"AiHackathon-admin/internal/web/handlers"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 )
 
 func main() {
@@ -30,14 +37,53 @@ func main() {
 	defer db.Close()
 
 	// 獲取所有影片和分析結果
-	videos, analysisResults, err := db.GetAllVideosWithAnalysis(1000, 0, "", "importance", "desc")
+	videos, analysisResults, err := db.GetAllVideosWithAnalysis(1000, 0, "", "importance", "desc", "")
 	if err != nil {
 		log.Fatalf("無法獲取影片數據: %v", err)
 	}
+	// 優先使用編輯修訂後的內容
+	if err := handlers.ApplyAnalysisReviews(db, videos, analysisResults); err != nil {
+		log.Fatalf("無法套用編輯修訂: %v", err)
+	}
 
 	// 轉換為顯示格式
 	displayData := convertToDisplayData(videos, analysisResults)
 
+	// 影片檔案的技術資訊 (解析度、編碼等)
+	videoIDs := make([]int64, len(videos))
+	for i, video := range videos {
+		videoIDs[i] = video.ID
+	}
+	if mediaInfo, err := db.GetMediaInfo(videoIDs); err != nil {
+		log.Printf("警告：無法獲取影片技術資訊: %v", err)
+	} else {
+		for i := range displayData {
+			displayData[i].Media = handlers.NewMediaDisplay(mediaInfo[displayData[i].VideoID])
+		}
+	}
+
+	// 設定 export.publicBaseURL 與 mediaSigning.secret 時，影片改由伺服器的已簽署 /media/ 網址播放
+	if signer := mediasign.New(cfg.MediaSigning.Secret, 0); signer != nil && cfg.Export.PublicBaseURL != "" {
+		if files, err := db.GetVideoFiles(videoIDs); err != nil {
+			log.Printf("警告：無法獲取影片版本清單，靜態頁面將不含影片網址簽章: %v", err)
+		} else {
+			signMediaURLs(signer, cfg, videos, files, displayData)
+		}
+	}
+
+	// 創建輸出目錄
+	outputDir := "static"
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		log.Fatalf("無法創建輸出目錄: %v", err)
+	}
+
+	// 複製已產生的海報與關鍵影格縮圖表至輸出目錄 (thumbs/)，靜態頁面不需連線至 NAS 即可顯示
+	if store, err := newNASReader(cfg.NAS); err != nil {
+		log.Printf("警告：無法存取 NAS，靜態頁面將不含縮圖: %v", err)
+	} else {
+		copyThumbnails(store, videos, displayData, outputDir)
+	}
+
 	// 根據重要性評分排序
 	sort.Slice(displayData, func(i, j int) bool {
 		// 如果兩個影片都有重要性評分，比較評分
@@ -65,12 +111,6 @@ func main() {
 		log.Fatalf("無法解析模板: %v", err)
 	}
 
-	// 創建輸出目錄
-	outputDir := "static"
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		log.Fatalf("無法創建輸出目錄: %v", err)
-	}
-
 	// 生成靜態檔案
 	outputFile := filepath.Join(outputDir, "index.html")
 	file, err := os.Create(outputFile)
@@ -205,6 +245,83 @@ func convertToDisplayData(videos []models.Video, analysisResults []models.Analys
 	return displayData
 }
 
+// signMediaURLs 將顯示資料的影片網址設為播放版本的已簽署 /media/ 網址，有效期間為 mediaSigning.exportTTLHours；
+// displayData 與 videos 順序相同
+func signMediaURLs(signer *mediasign.Signer, cfg *config.Config, videos []models.Video, files map[int64][]models.VideoFile, displayData []handlers.VideoDisplayData) {
+	rules := renditions.RulesFromConfig(cfg.NAS.Renditions)
+	prefix := strings.TrimRight(cfg.Export.PublicBaseURL, "/") + "/media"
+	expires := time.Now().Add(time.Duration(cfg.MediaSigning.ExportTTLHours) * time.Hour)
+	for i, video := range videos {
+		streamPath := filepath.ToSlash(video.NASPath)
+		if f, ok := renditions.ForStream(files[video.ID], rules); ok {
+			streamPath = f.Path
+		}
+		displayData[i].VideoURL = signer.URLUntil(prefix, streamPath, expires)
+	}
+	log.Printf("影片網址已簽署，有效至 %s", expires.Format("2006-01-02 15:04"))
+}
+
+// nasReader 讀取 NAS 上的檔案 (nas.FileSystemStorage 或 s3.ObjectStorage)
+type nasReader interface {
+	OpenVideo(filePath string) (io.ReadSeekCloser, error)
+}
+
+// newNASReader 依 nas.backend 建立讀取 NAS 檔案的儲存後端
+func newNASReader(nasCfg config.NASConfig) (nasReader, error) {
+	if nasCfg.Backend == "s3" {
+		return s3.NewObjectStorage(nasCfg)
+	}
+	return nas.NewFileSystemStorage(nasCfg)
+}
+
+// copyThumbnails 將影片的海報與縮圖表複製到 outputDir/thumbs/ 下的相同相對路徑，並設定顯示資料的相對網址；
+// displayData 與 videos 順序相同。複製失敗的縮圖不顯示
+func copyThumbnails(store nasReader, videos []models.Video, displayData []handlers.VideoDisplayData, outputDir string) {
+	var copied int
+	for i, video := range videos {
+		for _, thumb := range []struct {
+			path sql.NullString
+			url  *string
+		}{
+			{video.PosterPath, &displayData[i].PosterURL},
+			{video.ContactSheetPath, &displayData[i].ContactSheetURL},
+		} {
+			rel := filepath.ToSlash(filepath.Clean(thumb.path.String))
+			if !thumb.path.Valid || rel == ".." || strings.HasPrefix(rel, "../") || filepath.IsAbs(rel) {
+				continue
+			}
+			if err := copyNASFile(store, rel, filepath.Join(outputDir, "thumbs", filepath.FromSlash(rel))); err != nil {
+				log.Printf("警告：無法複製影片 ID %d 的縮圖 '%s': %v", video.ID, rel, err)
+				continue
+			}
+			*thumb.url = "thumbs/" + rel
+			copied++
+		}
+	}
+	log.Printf("已複製 %d 個縮圖至 %s", copied, filepath.Join(outputDir, "thumbs"))
+}
+
+// copyNASFile 將 NAS 上的 rel 複製到本地 dst
+func copyNASFile(store nasReader, rel, dst string) error {
+	src, err := store.OpenVideo(filepath.FromSlash(rel))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
 func getFlagForLocation(locationString string) string {
 	if locationString == "" {
 		return ""
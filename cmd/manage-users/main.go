@@ -0,0 +1,150 @@
+package main
+
+import (
+	"AiHackathon-admin/internal/auth"
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/models"
+	"AiHackathon-admin/internal/storage/mysql"
+	"bufio"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+const usage = `用法: manage-users <指令> [參數]
+
+指令:
+  list                                           列出所有使用者
+  add -username 帳號 -role 角色 [-password 密碼]  新增本機帳號 (未提供密碼時由標準輸入讀取)
+  set-role -username 帳號 -role 角色              變更角色 (viewer、editor、admin)
+  set-password -username 帳號 [-password 密碼]    重設本機帳號密碼，並登出該帳號的所有 session
+  disable -username 帳號                          停用帳號，並登出該帳號的所有 session
+  enable -username 帳號                           重新啟用帳號
+`
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command := os.Args[1]
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	username := fs.String("username", "", "帳號")
+	roleFlag := fs.String("role", "", "角色 (viewer、editor、admin)")
+	password := fs.String("password", "", "密碼 (未提供時由標準輸入讀取)")
+	fs.Parse(os.Args[2:])
+
+	cfg, err := config.Load("configs", "config")
+	if err != nil {
+		log.Fatalf("無法載入配置: %v", err)
+	}
+	db, err := mysql.NewMySQLStore(cfg.Database)
+	if err != nil {
+		log.Fatalf("無法連接到資料庫: %v", err)
+	}
+	defer db.Close()
+
+	if command == "list" {
+		users, err := db.ListUsers()
+		if err != nil {
+			log.Fatalf("錯誤：%v", err)
+		}
+		fmt.Printf("%-6s %-30s %-8s %-6s %-8s %s\n", "ID", "帳號", "角色", "登入方式", "啟用", "最後登入")
+		for _, u := range users {
+			lastLogin := "-"
+			if u.LastLoginAt.Valid {
+				lastLogin = u.LastLoginAt.Time.Format("2006-01-02 15:04")
+			}
+			fmt.Printf("%-6d %-30s %-8s %-6s %-8t %s\n", u.ID, u.Username, u.Role, u.AuthProvider, u.Enabled, lastLogin)
+		}
+		return
+	}
+
+	if *username == "" {
+		log.Fatalf("錯誤：請以 -username 指定帳號")
+	}
+	if command == "add" {
+		role, err := auth.ParseRole(*roleFlag)
+		if err != nil {
+			log.Fatalf("錯誤：%v", err)
+		}
+		hash, err := auth.HashPassword(readPassword(*password))
+		if err != nil {
+			log.Fatalf("錯誤：%v", err)
+		}
+		user := &models.User{
+			Username:     *username,
+			PasswordHash: sql.NullString{String: hash, Valid: true},
+			Role:         role,
+			AuthProvider: models.AuthProviderLocal,
+			Enabled:      true,
+		}
+		id, err := db.CreateUser(user)
+		if err != nil {
+			log.Fatalf("錯誤：%v", err)
+		}
+		fmt.Printf("已新增使用者 '%s' (ID: %d, 角色: %s)\n", *username, id, role)
+		return
+	}
+
+	user, err := db.GetUserByUsername(*username)
+	if err != nil {
+		log.Fatalf("錯誤：%v", err)
+	}
+	if user == nil {
+		log.Fatalf("錯誤：找不到使用者 '%s'", *username)
+	}
+	logoutAll := false
+	switch command {
+	case "set-role":
+		role, err := auth.ParseRole(*roleFlag)
+		if err != nil {
+			log.Fatalf("錯誤：%v", err)
+		}
+		user.Role = role
+	case "set-password":
+		if user.AuthProvider != models.AuthProviderLocal {
+			log.Fatalf("錯誤：'%s' 為 %s 帳號，無法設定密碼", user.Username, user.AuthProvider)
+		}
+		hash, err := auth.HashPassword(readPassword(*password))
+		if err != nil {
+			log.Fatalf("錯誤：%v", err)
+		}
+		user.PasswordHash = sql.NullString{String: hash, Valid: true}
+		logoutAll = true
+	case "disable":
+		user.Enabled = false
+		logoutAll = true
+	case "enable":
+		user.Enabled = true
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err := db.UpdateUser(user); err != nil {
+		log.Fatalf("錯誤：%v", err)
+	}
+	if logoutAll {
+		if err := db.DeleteUserSessions(user.ID); err != nil {
+			log.Fatalf("錯誤：%v", err)
+		}
+	}
+	fmt.Printf("已更新使用者 '%s' (%s)\n", user.Username, command)
+}
+
+// readPassword 未以參數提供密碼時，從標準輸入讀取一行
+func readPassword(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	fmt.Fprint(os.Stderr, "請輸入密碼: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		log.Fatalf("錯誤：讀取密碼失敗: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
@@ -0,0 +1,127 @@
+package alerts
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/models"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message 是一則快訊通知的內容
+type Message struct {
+	Subject string
+	Text    string
+}
+
+// BuildMessage 依規則、影片與分析結果組成快訊內容
+func BuildMessage(rule models.AlertRule, video models.Video, result *models.AnalysisResult, dashboardURL string) Message {
+	doc := BuildDocument(video, result)
+	title := video.Title.String
+	if title == "" {
+		title = strings.ToUpper(video.SourceName) + video.SourceID
+	}
+	rating := ""
+	if r := doc["importance"]; len(r) > 0 {
+		rating = r[0]
+	}
+
+	subject := fmt.Sprintf("[快訊] %s", title)
+	if rating != "" {
+		subject = fmt.Sprintf("[快訊 %s] %s", rating, title)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "規則：%s\n", rule.Name)
+	fmt.Fprintf(&sb, "來源：%s %s\n", strings.ToUpper(video.SourceName), video.SourceID)
+	if rating != "" {
+		fmt.Fprintf(&sb, "重要性：%s\n", rating)
+	}
+	if factors := doc["key_factors"]; len(factors) > 0 {
+		fmt.Fprintf(&sb, "關鍵因素：%s\n", strings.Join(factors, "、"))
+	}
+	if video.Location.Valid && video.Location.String != "" {
+		fmt.Fprintf(&sb, "地點：%s\n", video.Location.String)
+	}
+	if result != nil && result.ShortSummary != nil && result.ShortSummary.Valid && result.ShortSummary.String != "" {
+		fmt.Fprintf(&sb, "\n%s\n", result.ShortSummary.String)
+	}
+	if dashboardURL != "" {
+		fmt.Fprintf(&sb, "\n%s\n", dashboardURL)
+	}
+	return Message{Subject: subject, Text: sb.String()}
+}
+
+// ParseRecipients 將以逗號或分號分隔的收件者字串拆成清單
+func ParseRecipients(target string) []string {
+	var recipients []string
+	for _, r := range strings.FieldsFunc(target, func(c rune) bool { return c == ',' || c == ';' }) {
+		if r = strings.TrimSpace(r); r != "" {
+			recipients = append(recipients, r)
+		}
+	}
+	return recipients
+}
+
+// SendEmail 透過 SMTP 寄送快訊
+func SendEmail(cfg config.SMTPConfig, to []string, msg Message) error {
+	if cfg.Host == "" || cfg.Port == 0 {
+		return fmt.Errorf("未設定 SMTP 伺服器 (alerts.smtp.host/port)")
+	}
+	if len(to) == 0 {
+		return fmt.Errorf("快訊沒有收件者")
+	}
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	body.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(msg.Text))
+	for len(encoded) > 76 {
+		body.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	body.WriteString(encoded + "\r\n")
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	addr := cfg.Host + ":" + strconv.Itoa(cfg.Port)
+	if err := smtp.SendMail(addr, auth, cfg.From, to, body.Bytes()); err != nil {
+		return fmt.Errorf("透過 SMTP (%s) 寄送快訊失敗: %w", addr, err)
+	}
+	return nil
+}
+
+// SendChat 將快訊以 JSON {"text": ...} POST 到聊天室 incoming webhook (Slack / Google Chat 相容)
+func SendChat(client *http.Client, url string, msg Message) error {
+	if url == "" {
+		return fmt.Errorf("聊天室 webhook URL 不得為空")
+	}
+	payload, err := json.Marshal(map[string]string{"text": "*" + msg.Subject + "*\n" + msg.Text})
+	if err != nil {
+		return fmt.Errorf("序列化聊天室訊息失敗: %w", err)
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("發送聊天室快訊失敗: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("聊天室 webhook 回應 HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package alerts
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/models"
+	"bufio"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// smtpMessage 為測試 SMTP 伺服器收到的一封信
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// startSMTPServer 在本機啟動只支援基本指令 (無 TLS、無驗證) 的 SMTP 伺服器，回傳位址與收到的信件
+func startSMTPServer(t *testing.T) (string, int, <-chan smtpMessage) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	messages := make(chan smtpMessage, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, messages)
+		}
+	}()
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, messages
+}
+
+func serveSMTP(conn net.Conn, messages chan<- smtpMessage) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+	reply("220 localhost ESMTP test")
+	var msg smtpMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimRight(line, "\r\n")
+		upper := strings.ToUpper(cmd)
+		switch {
+		case strings.HasPrefix(upper, "EHLO"), strings.HasPrefix(upper, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(upper, "MAIL FROM:"):
+			msg.from = strings.Trim(cmd[len("MAIL FROM:"):], "<> ")
+			reply("250 OK")
+		case strings.HasPrefix(upper, "RCPT TO:"):
+			msg.to = append(msg.to, strings.Trim(cmd[len("RCPT TO:"):], "<> "))
+			reply("250 OK")
+		case upper == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			msg.data = data.String()
+			messages <- msg
+			msg = smtpMessage{}
+			reply("250 OK")
+		case upper == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSendEmail(t *testing.T) {
+	host, port, messages := startSMTPServer(t)
+	cfg := config.SMTPConfig{Host: host, Port: port, From: "alerts@example.com"}
+	msg := Message{Subject: "[快訊 S] 颱風登陸", Text: strings.Repeat("颱風登陸花蓮，", 20) + "\nhttps://admin.example.com/dashboard#video-1\n"}
+
+	if err := SendEmail(cfg, []string{"desk@example.com", "chief@example.com"}, msg); err != nil {
+		t.Fatalf("SendEmail: %v", err)
+	}
+	got := <-messages
+	if got.from != cfg.From {
+		t.Errorf("MAIL FROM = %q, want %q", got.from, cfg.From)
+	}
+	if want := []string{"desk@example.com", "chief@example.com"}; !reflect.DeepEqual(got.to, want) {
+		t.Errorf("RCPT TO = %v, want %v", got.to, want)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(got.data))
+	if err != nil {
+		t.Fatalf("無法解析信件: %v\n%s", err, got.data)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != msg.Subject {
+		t.Errorf("Subject = %q (%v), want %q", subject, err, msg.Subject)
+	}
+	if parsed.Header.Get("To") != "desk@example.com, chief@example.com" || parsed.Header.Get("From") != cfg.From {
+		t.Errorf("標頭不正確: %v", parsed.Header)
+	}
+	if ct := parsed.Header.Get("Content-Type"); ct != "text/plain; charset=UTF-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	raw, _ := io.ReadAll(parsed.Body)
+	for _, line := range strings.Split(strings.TrimRight(string(raw), "\r\n"), "\r\n") {
+		if len(line) > 76 {
+			t.Errorf("base64 行長度 %d 超過 76", len(line))
+		}
+	}
+	body, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(raw), "\r\n", ""))
+	if err != nil || string(body) != msg.Text {
+		t.Errorf("內文 = %q (%v), want %q", body, err, msg.Text)
+	}
+}
+
+func TestSendEmailErrors(t *testing.T) {
+	msg := Message{Subject: "s", Text: "t"}
+	if err := SendEmail(config.SMTPConfig{}, []string{"a@example.com"}, msg); err == nil || !strings.Contains(err.Error(), "未設定 SMTP") {
+		t.Errorf("未設定 SMTP 時應回傳錯誤，got %v", err)
+	}
+	if err := SendEmail(config.SMTPConfig{Host: "127.0.0.1", Port: 25}, nil, msg); err == nil || !strings.Contains(err.Error(), "收件者") {
+		t.Errorf("沒有收件者時應回傳錯誤，got %v", err)
+	}
+	// 連線被拒
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	if err := SendEmail(config.SMTPConfig{Host: "127.0.0.1", Port: port, From: "a@example.com"}, []string{"b@example.com"}, msg); err == nil {
+		t.Error("SMTP 無法連線時應回傳錯誤")
+	}
+}
+
+func TestSendChat(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type = %q", r.Header.Get("Content-Type"))
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		if r.URL.Path == "/fail" {
+			http.Error(w, "invalid_token", http.StatusForbidden)
+		}
+	}))
+	defer srv.Close()
+
+	if err := SendChat(srv.Client(), srv.URL+"/ok", Message{Subject: "主旨", Text: "內容"}); err != nil {
+		t.Fatalf("SendChat: %v", err)
+	}
+	if got["text"] != "*主旨*\n內容" {
+		t.Errorf("text = %q", got["text"])
+	}
+	if err := SendChat(srv.Client(), srv.URL+"/fail", Message{}); err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "invalid_token") {
+		t.Errorf("非 2xx 回應應回傳含狀態碼與內容的錯誤，got %v", err)
+	}
+	if err := SendChat(srv.Client(), "", Message{}); err == nil {
+		t.Error("URL 為空時應回傳錯誤")
+	}
+}
+
+func TestParseRecipients(t *testing.T) {
+	got := ParseRecipients(" a@example.com, b@example.com;;c@example.com ,")
+	if want := []string{"a@example.com", "b@example.com", "c@example.com"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseRecipients = %v, want %v", got, want)
+	}
+	if got := ParseRecipients(" ; "); got != nil {
+		t.Errorf("ParseRecipients(空白) = %v, want nil", got)
+	}
+}
+
+func TestBuildMessage(t *testing.T) {
+	rule := models.AlertRule{Name: "重大新聞"}
+	video := models.Video{
+		SourceName: "ap", SourceID: "123",
+		Location: sql.NullString{String: "花蓮", Valid: true},
+	}
+	result := &models.AnalysisResult{
+		ImportanceScore: json.RawMessage(`{"overall_rating":"s","key_factors":["傷亡","台灣相關"]}`),
+		ShortSummary:    &models.JsonNullString{NullString: sql.NullString{String: "颱風登陸", Valid: true}},
+	}
+	msg := BuildMessage(rule, video, result, "https://admin.example.com/dashboard#video-1")
+	if msg.Subject != "[快訊 S] AP123" {
+		t.Errorf("Subject = %q", msg.Subject)
+	}
+	want := "規則：重大新聞\n來源：AP 123\n重要性：S\n關鍵因素：傷亡、台灣相關\n地點：花蓮\n\n颱風登陸\n\nhttps://admin.example.com/dashboard#video-1\n"
+	if msg.Text != want {
+		t.Errorf("Text = %q, want %q", msg.Text, want)
+	}
+
+	video.Title = sql.NullString{String: "標題", Valid: true}
+	if msg := BuildMessage(rule, video, nil, ""); msg.Subject != "[快訊] 標題" || msg.Text != "規則：重大新聞\n來源：AP 123\n地點：花蓮\n" {
+		t.Errorf("沒有分析結果時 = %+v", msg)
+	}
+}
@@ -0,0 +1,381 @@
+package alerts
+
+import (
+	"AiHackathon-admin/internal/models"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// 規則語言：
+//
+//	expr      := orExpr
+//	orExpr    := andExpr { ("OR" | "or" | "||") andExpr }
+//	andExpr   := unary { ("AND" | "and" | "&&") unary }
+//	unary     := ("NOT" | "not" | "!") unary | "(" expr ")" | condition
+//	condition := field op value
+//
+// 欄位：importance (亦可寫 rating)、key_factors、keywords、topics、locations (mentioned_locations)、
+// source、location、subjects、title、summary、speakers、quotes
+//
+// 運算子：
+//   - =、!=         完全相符 (不分大小寫；多值欄位任一值相符即成立)
+//   - contains      子字串 (不分大小寫；多值欄位任一值包含即成立)
+//   - matches       正規表示式
+//   - >=、>、<=、<  僅用於 importance，順序為 S > A > B > C > N
+//
+// 值可為不含空白與括號的字詞，或以雙引號包住的字串，例如：
+//
+//	importance = S AND key_factors contains 台灣相關
+//	importance >= A AND (keywords contains "黃仁勳" OR speakers contains "黃仁勳")
+
+// Rule 是已解析的規則，可重複用於多筆分析結果
+type Rule struct {
+	source string
+	root   node
+}
+
+// String 回傳原始規則字串
+func (r *Rule) String() string { return r.source }
+
+// Fields 可用於規則的欄位名稱
+var Fields = []string{"importance", "key_factors", "keywords", "topics", "locations", "source", "location", "subjects", "title", "summary", "speakers", "quotes"}
+
+var fieldAliases = map[string]string{
+	"rating":              "importance",
+	"mentioned_locations": "locations",
+	"keyword":             "keywords",
+	"topic":               "topics",
+	"subject":             "subjects",
+	"speaker":             "speakers",
+	"quote":               "quotes",
+}
+
+var ratingOrder = map[string]int{"S": 5, "A": 4, "B": 3, "C": 2, "N": 1}
+
+// Document 是規則比對的對象：欄位名稱對應一或多個字串值
+type Document map[string][]string
+
+type node interface {
+	eval(doc Document) bool
+}
+
+type andNode struct{ left, right node }
+type orNode struct{ left, right node }
+type notNode struct{ inner node }
+type condNode struct {
+	field string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+func (n andNode) eval(doc Document) bool { return n.left.eval(doc) && n.right.eval(doc) }
+func (n orNode) eval(doc Document) bool  { return n.left.eval(doc) || n.right.eval(doc) }
+func (n notNode) eval(doc Document) bool { return !n.inner.eval(doc) }
+
+func (n condNode) eval(doc Document) bool {
+	values := doc[n.field]
+	switch n.op {
+	case "!=":
+		for _, v := range values {
+			if strings.EqualFold(strings.TrimSpace(v), n.value) {
+				return false
+			}
+		}
+		return true
+	case ">=", ">", "<=", "<":
+		if len(values) == 0 {
+			return false
+		}
+		got, ok := ratingOrder[strings.ToUpper(strings.TrimSpace(values[0]))]
+		if !ok {
+			return false
+		}
+		want := ratingOrder[strings.ToUpper(n.value)]
+		switch n.op {
+		case ">=":
+			return got >= want
+		case ">":
+			return got > want
+		case "<=":
+			return got <= want
+		default:
+			return got < want
+		}
+	}
+	for _, v := range values {
+		switch n.op {
+		case "=":
+			if strings.EqualFold(strings.TrimSpace(v), n.value) {
+				return true
+			}
+		case "contains":
+			if strings.Contains(strings.ToLower(v), strings.ToLower(n.value)) {
+				return true
+			}
+		case "matches":
+			if n.re.MatchString(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Matches 判斷文件是否符合規則
+func (r *Rule) Matches(doc Document) bool {
+	return r.root.eval(doc)
+}
+
+// Parse 解析規則字串，語法錯誤時回傳描述位置的錯誤
+func Parse(expression string) (*Rule, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("規則不得為空")
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("規則在 '%s' 附近有多餘的內容", p.tokens[p.pos].text)
+	}
+	return &Rule{source: expression, root: root}, nil
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, token{text: string(r)})
+			i++
+		case r == '"':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '"'; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				sb.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("規則中的字串缺少結尾的雙引號")
+			}
+			tokens = append(tokens, token{text: sb.String(), quoted: true})
+			i = j + 1
+		case strings.ContainsRune("=!<>", r):
+			j := i + 1
+			if j < len(runes) && runes[j] == '=' {
+				j++
+			}
+			tokens = append(tokens, token{text: string(runes[i:j])})
+			i = j
+		default:
+			j := i
+			for j < len(runes) && !unicode.IsSpace(runes[j]) && !strings.ContainsRune("()\"=!<>", runes[j]) {
+				j++
+			}
+			tokens = append(tokens, token{text: string(runes[i:j])})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peekKeyword(words ...string) bool {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].quoted {
+		return false
+	}
+	for _, w := range words {
+		if strings.EqualFold(p.tokens[p.pos].text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or", "||") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and", "&&") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.peekKeyword("not", "!") {
+		p.pos++
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner}, nil
+	}
+	if p.peekKeyword("(") {
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peekKeyword(")") {
+			return nil, fmt.Errorf("規則缺少右括號")
+		}
+		p.pos++
+		return inner, nil
+	}
+	return p.parseCondition()
+}
+
+func (p *parser) parseCondition() (node, error) {
+	if p.pos+2 >= len(p.tokens) {
+		return nil, fmt.Errorf("規則不完整：條件需為「欄位 運算子 值」")
+	}
+	fieldTok, opTok, valueTok := p.tokens[p.pos], p.tokens[p.pos+1], p.tokens[p.pos+2]
+	field := strings.ToLower(fieldTok.text)
+	if alias, ok := fieldAliases[field]; ok {
+		field = alias
+	}
+	if !isKnownField(field) || fieldTok.quoted {
+		return nil, fmt.Errorf("未知的欄位 '%s'，可用欄位: %s", fieldTok.text, strings.Join(Fields, ", "))
+	}
+	op := strings.ToLower(opTok.text)
+	cond := condNode{field: field, op: op, value: strings.TrimSpace(valueTok.text)}
+	switch op {
+	case "=", "==":
+		cond.op = "="
+	case "!=", "contains", "matches":
+	case ">=", ">", "<=", "<":
+		if field != "importance" {
+			return nil, fmt.Errorf("運算子 '%s' 僅能用於 importance", opTok.text)
+		}
+		if _, ok := ratingOrder[strings.ToUpper(cond.value)]; !ok {
+			return nil, fmt.Errorf("無效的評級 '%s'，需為 S/A/B/C/N", valueTok.text)
+		}
+	default:
+		return nil, fmt.Errorf("未知的運算子 '%s'，可用: =, !=, contains, matches, >=, >, <=, <", opTok.text)
+	}
+	if opTok.quoted {
+		return nil, fmt.Errorf("未知的運算子 '%s'", opTok.text)
+	}
+	if cond.value == "" {
+		return nil, fmt.Errorf("欄位 '%s' 的比對值不得為空", fieldTok.text)
+	}
+	if op == "matches" {
+		re, err := regexp.Compile("(?i)" + cond.value)
+		if err != nil {
+			return nil, fmt.Errorf("無效的正規表示式 '%s': %w", cond.value, err)
+		}
+		cond.re = re
+	}
+	p.pos += 3
+	return cond, nil
+}
+
+func isKnownField(field string) bool {
+	for _, f := range Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildDocument 將影片與分析結果轉為規則比對用的 Document
+func BuildDocument(video models.Video, result *models.AnalysisResult) Document {
+	doc := Document{
+		"source": {video.SourceName},
+	}
+	if video.Title.Valid {
+		doc["title"] = []string{video.Title.String}
+	}
+	if video.Location.Valid {
+		doc["location"] = []string{video.Location.String}
+	}
+	var subjects []string
+	if len(video.Subjects) > 0 && json.Unmarshal(video.Subjects, &subjects) == nil {
+		doc["subjects"] = subjects
+	}
+	if result == nil {
+		return doc
+	}
+
+	var importance models.ImportanceScore
+	if len(result.ImportanceScore) > 0 && json.Unmarshal(result.ImportanceScore, &importance) == nil {
+		if importance.OverallRating != "" {
+			doc["importance"] = []string{strings.ToUpper(strings.TrimSpace(importance.OverallRating))}
+		}
+		doc["key_factors"] = importance.KeyFactors
+	}
+	var keywords []models.Keyword
+	if len(result.Keywords) > 0 && json.Unmarshal(result.Keywords, &keywords) == nil {
+		for _, k := range keywords {
+			doc["keywords"] = append(doc["keywords"], k.Keyword)
+		}
+	}
+	var topics, locations []string
+	if len(result.Topics) > 0 && json.Unmarshal(result.Topics, &topics) == nil {
+		doc["topics"] = topics
+	}
+	if len(result.MentionedLocations) > 0 && json.Unmarshal(result.MentionedLocations, &locations) == nil {
+		doc["locations"] = locations
+	}
+	var bites []models.Bite
+	if len(result.Bites) > 0 && json.Unmarshal(result.Bites, &bites) == nil {
+		for _, b := range bites {
+			doc["speakers"] = append(doc["speakers"], b.Speaker)
+			doc["quotes"] = append(doc["quotes"], b.Quote)
+		}
+	}
+	for _, s := range []*models.JsonNullString{result.ShortSummary, result.BulletedSummary} {
+		if s != nil && s.Valid {
+			doc["summary"] = append(doc["summary"], s.String)
+		}
+	}
+	return doc
+}
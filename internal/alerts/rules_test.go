@@ -0,0 +1,151 @@
+package alerts
+
+import (
+	"AiHackathon-admin/internal/models"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string // 錯誤訊息需包含的字串
+	}{
+		{expr: "", wantErr: "不得為空"},
+		{expr: "   ", wantErr: "不得為空"},
+		{expr: "importance =", wantErr: "不完整"},
+		{expr: "colour = red", wantErr: "未知的欄位"},
+		{expr: `"title" = x`, wantErr: "未知的欄位"},
+		{expr: "title ~ x", wantErr: "未知的運算子"},
+		{expr: "title like x", wantErr: "未知的運算子"},
+		{expr: `title "contains" x`, wantErr: "未知的運算子"},
+		{expr: "topics >= A", wantErr: "僅能用於 importance"},
+		{expr: "importance >= X", wantErr: "無效的評級"},
+		{expr: `title = ""`, wantErr: "不得為空"},
+		{expr: "title matches (", wantErr: "無效的正規表示式"},
+		{expr: `title matches "("`, wantErr: "無效的正規表示式"},
+		{expr: `title = "unterminated`, wantErr: "雙引號"},
+		{expr: "(importance = S", wantErr: "右括號"},
+		{expr: "importance = S topics = x", wantErr: "多餘的內容"},
+		{expr: "importance = S AND", wantErr: "不完整"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			if err == nil {
+				t.Fatalf("Parse(%q) 應回傳錯誤", tt.expr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse(%q) 錯誤 = %q，應包含 %q", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	doc := Document{
+		"importance":  {"A"},
+		"key_factors": {"台灣相關", "傷亡"},
+		"keywords":    {"黃仁勳", "NVIDIA"},
+		"topics":      {"科技"},
+		"source":      {"ap"},
+		"title":       {"Nvidia CEO visits Taipei"},
+		"speakers":    {"Jensen Huang"},
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"importance = A", true},
+		{"rating == a", true},
+		{"importance = S", false},
+		{"importance >= A", true},
+		{"importance > A", false},
+		{"importance <= B", false},
+		{"importance < S", true},
+		{"key_factors contains 台灣", true},
+		{"keyword = nvidia", true},
+		{"keywords != nvidia", false},
+		{"topics != 體育", true},
+		{"locations = 台北", false},
+		{"locations != 台北", true},
+		{`title matches "^nvidia .* taipei$"`, true},
+		{`speakers contains "jensen huang"`, true},
+		{"importance = S AND key_factors contains 台灣相關", false},
+		{"importance = S OR key_factors contains 台灣相關", true},
+		{"importance = S || source = AP", true},
+		{"importance >= A && NOT topics = 體育", true},
+		{"!(source = ap)", false},
+		{"not not source = ap", true},
+		{`importance >= A AND (keywords contains "黃仁勳" OR speakers contains "黃仁勳")`, true},
+		{"importance = S AND topics = 科技 OR source = ap", true},
+		{"importance = S AND (topics = 科技 OR source = ap)", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			rule, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.expr, err)
+			}
+			if got := rule.Matches(doc); got != tt.want {
+				t.Errorf("%q Matches = %t, want %t", tt.expr, got, tt.want)
+			}
+			if rule.String() != tt.expr {
+				t.Errorf("String() = %q, want %q", rule.String(), tt.expr)
+			}
+		})
+	}
+}
+
+func TestRatingComparisonWithoutRating(t *testing.T) {
+	for _, doc := range []Document{{}, {"importance": {"?"}}} {
+		rule, err := Parse("importance >= N")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rule.Matches(doc) {
+			t.Errorf("沒有有效評級的文件 %v 不應符合 importance >= N", doc)
+		}
+	}
+}
+
+func TestBuildDocument(t *testing.T) {
+	video := models.Video{
+		SourceName: "ap",
+		Title:      sql.NullString{String: "標題", Valid: true},
+		Location:   sql.NullString{String: "台北", Valid: true},
+		Subjects:   json.RawMessage(`["Politics"]`),
+	}
+	result := &models.AnalysisResult{
+		ImportanceScore:    json.RawMessage(`{"overall_rating":" s ","key_factors":["台灣相關"]}`),
+		Keywords:           json.RawMessage(`[{"keyword":"選舉"},{"keyword":"立法院"}]`),
+		Topics:             json.RawMessage(`["政治"]`),
+		MentionedLocations: json.RawMessage(`["台北","高雄"]`),
+		Bites:              json.RawMessage(`[{"speaker":"甲","quote":"一"},{"speaker":"乙","quote":"二"}]`),
+		ShortSummary:       &models.JsonNullString{NullString: sql.NullString{String: "短", Valid: true}},
+		BulletedSummary:    &models.JsonNullString{NullString: sql.NullString{String: "- 長", Valid: true}},
+	}
+	want := Document{
+		"source":      {"ap"},
+		"title":       {"標題"},
+		"location":    {"台北"},
+		"subjects":    {"Politics"},
+		"importance":  {"S"},
+		"key_factors": {"台灣相關"},
+		"keywords":    {"選舉", "立法院"},
+		"topics":      {"政治"},
+		"locations":   {"台北", "高雄"},
+		"speakers":    {"甲", "乙"},
+		"quotes":      {"一", "二"},
+		"summary":     {"短", "- 長"},
+	}
+	if got := BuildDocument(video, result); !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildDocument =\n%v\nwant\n%v", got, want)
+	}
+	if got := BuildDocument(video, nil); !reflect.DeepEqual(got, Document{"source": {"ap"}, "title": {"標題"}, "location": {"台北"}, "subjects": {"Politics"}}) {
+		t.Errorf("BuildDocument(nil result) = %v", got)
+	}
+}
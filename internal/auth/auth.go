@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"AiHackathon-admin/internal/models"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Store 定義了登入驗證所需的資料存取操作 (由 mysql.MySQLStore 實作)
+type Store interface {
+	GetUserByID(id int64) (*models.User, error)
+	GetUserByUsername(username string) (*models.User, error)
+	GetUserByOIDCSubject(subject string) (*models.User, error)
+	CreateUser(user *models.User) (int64, error)
+	UpdateUser(user *models.User) error
+	UpdateUserLastLogin(userID int64, at time.Time) error
+	CountUsers() (int, error)
+	CreateSession(session *models.Session) error
+	GetSession(id string) (*models.Session, error)
+	DeleteSession(id string) error
+	DeleteExpiredSessions() (int64, error)
+	CreateAuditLog(entry *models.AuditLogEntry) error
+}
+
+// MinPasswordLength 本機帳號密碼的最短長度
+const MinPasswordLength = 10
+
+var roleLevels = map[models.UserRole]int{
+	models.RoleViewer: 1,
+	models.RoleEditor: 2,
+	models.RoleAdmin:  3,
+}
+
+// ParseRole 將字串轉換為角色，無效時回傳錯誤
+func ParseRole(s string) (models.UserRole, error) {
+	role := models.UserRole(strings.ToLower(strings.TrimSpace(s)))
+	if _, ok := roleLevels[role]; !ok {
+		return "", fmt.Errorf("無效的角色 '%s'，需為 viewer、editor 或 admin", s)
+	}
+	return role, nil
+}
+
+// RoleAllows 判斷角色 have 是否具備 need 所需的權限
+func RoleAllows(have, need models.UserRole) bool {
+	return roleLevels[have] >= roleLevels[need] && roleLevels[need] > 0
+}
+
+// HigherRole 回傳兩個角色中權限較高者
+func HigherRole(a, b models.UserRole) models.UserRole {
+	if roleLevels[b] > roleLevels[a] {
+		return b
+	}
+	return a
+}
+
+// HashPassword 以 bcrypt 雜湊密碼
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", fmt.Errorf("密碼長度至少需 %d 個字元", MinPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("雜湊密碼失敗: %w", err)
+	}
+	return string(hash), nil
+}
+
+// dummyHash 用於帳號不存在時仍執行一次 bcrypt 比對，避免以回應時間推測帳號是否存在
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("aihackathon-admin-dummy-password"), bcrypt.DefaultCost)
+
+// CheckPassword 驗證本機帳號的密碼；帳號不存在、已停用或非本機帳號時一律回傳 false
+func CheckPassword(user *models.User, password string) bool {
+	if user == nil || !user.Enabled || user.AuthProvider != models.AuthProviderLocal || !user.PasswordHash.Valid {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash.String), []byte(password)) == nil
+}
+
+// NewToken 產生 32 bytes 的隨機 token (base64url)
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("產生隨機 token 失敗: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken 回傳 token 的 SHA-256 十六進位字串，資料庫只儲存此值
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseTrustedProxies 將 auth.trustedProxies 的 IP 或 CIDR 清單轉換為網段 (單一 IP 視為只含該位址的網段)
+func ParseTrustedProxies(entries []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			nets = append(nets, ipNet)
+			continue
+		}
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("無效的可信任代理 '%s'，需為 IP 或 CIDR", entry)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return nets, nil
+}
+
+// ClientIP 回傳請求的來源 IP。只有直接連線的來源 (RemoteAddr) 為可信任的反向代理時才採用 X-Forwarded-For：
+// 由右至左略過同為可信任代理的位址，取第一個不可信任的位址；否則 X-Forwarded-For 可由用戶端偽造，一律忽略
+func ClientIP(r *http.Request, trusted []*net.IPNet) string {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+	if !isTrustedProxy(peer, trusted) {
+		return peer
+	}
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if net.ParseIP(hops[i]) == nil {
+			// 無法解析的位址之前的內容皆不可信
+			break
+		}
+		if !isTrustedProxy(hops[i], trusted) || i == 0 {
+			return hops[i]
+		}
+	}
+	return peer
+}
+
+func isTrustedProxy(addr string, trusted []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey int
+
+const (
+	userContextKey contextKey = iota
+	sessionContextKey
+)
+
+// WithUser 將登入的使用者與 session 放入 context
+func WithUser(ctx context.Context, user *models.User, session *models.Session) context.Context {
+	ctx = context.WithValue(ctx, userContextKey, user)
+	if session != nil {
+		ctx = context.WithValue(ctx, sessionContextKey, session)
+	}
+	return ctx
+}
+
+// UserFromContext 取得目前登入的使用者，未登入時回傳 nil
+func UserFromContext(ctx context.Context) *models.User {
+	user, _ := ctx.Value(userContextKey).(*models.User)
+	return user
+}
+
+// SessionFromContext 取得目前的 session，以 Basic 認證或未啟用驗證時回傳 nil
+func SessionFromContext(ctx context.Context) *models.Session {
+	session, _ := ctx.Value(sessionContextKey).(*models.Session)
+	return session
+}
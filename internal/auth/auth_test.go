@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.5", "::1", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		want       string
+	}{
+		{name: "沒有代理", remoteAddr: "203.0.113.7:51234", want: "203.0.113.7"},
+		{name: "不可信任的來源偽造 XFF", remoteAddr: "203.0.113.7:51234", xff: []string{"1.2.3.4"}, want: "203.0.113.7"},
+		{name: "可信任代理", remoteAddr: "10.1.2.3:443", xff: []string{"198.51.100.9"}, want: "198.51.100.9"},
+		{name: "單一 IP 的可信任代理", remoteAddr: "192.168.1.5:80", xff: []string{"198.51.100.9"}, want: "198.51.100.9"},
+		{name: "同網段的其他位址不可信任", remoteAddr: "192.168.1.6:80", xff: []string{"198.51.100.9"}, want: "192.168.1.6"},
+		{name: "用戶端在 XFF 開頭偽造位址", remoteAddr: "10.1.2.3:443", xff: []string{"1.2.3.4, 198.51.100.9"}, want: "198.51.100.9"},
+		{name: "多層可信任代理", remoteAddr: "10.1.2.3:443", xff: []string{"1.2.3.4, 198.51.100.9, 10.9.9.9"}, want: "198.51.100.9"},
+		{name: "多個 XFF 標頭", remoteAddr: "10.1.2.3:443", xff: []string{"1.2.3.4", "198.51.100.9,10.9.9.9"}, want: "198.51.100.9"},
+		{name: "XFF 全為可信任代理", remoteAddr: "10.1.2.3:443", xff: []string{"10.5.5.5, 10.9.9.9"}, want: "10.5.5.5"},
+		{name: "可信任代理但沒有 XFF", remoteAddr: "10.1.2.3:443", want: "10.1.2.3"},
+		{name: "XFF 中有無效位址", remoteAddr: "10.1.2.3:443", xff: []string{"198.51.100.9, unknown"}, want: "10.1.2.3"},
+		{name: "IPv6 可信任代理", remoteAddr: "[::1]:8080", xff: []string{"2001:db8::1"}, want: "2001:db8::1"},
+		{name: "IPv6 不可信任", remoteAddr: "[2001:db8::2]:8080", xff: []string{"1.2.3.4"}, want: "2001:db8::2"},
+		{name: "RemoteAddr 沒有 port", remoteAddr: "10.1.2.3", xff: []string{"198.51.100.9"}, want: "198.51.100.9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := ClientIP(r, trusted); got != tt.want {
+				t.Errorf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIPWithoutTrustedProxies(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "127.0.0.1:1234"
+	r.Header.Set("X-Forwarded-For", "1.2.3.4")
+	if got := ClientIP(r, nil); got != "127.0.0.1" {
+		t.Errorf("未設定可信任代理時應忽略 X-Forwarded-For，got %q", got)
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	nets, err := ParseTrustedProxies([]string{" 172.16.0.0/12 ", "127.0.0.1", "2001:db8::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, n := range nets {
+		got = append(got, n.String())
+	}
+	want := []string{"172.16.0.0/12", "127.0.0.1/32", "2001:db8::1/128"}
+	if len(got) != len(want) {
+		t.Fatalf("ParseTrustedProxies = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ParseTrustedProxies[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+	for _, entry := range []string{"", "localhost", "10.0.0.0/33", "1.2.3"} {
+		if _, err := ParseTrustedProxies([]string{entry}); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) 應回傳錯誤", entry)
+		}
+	}
+}
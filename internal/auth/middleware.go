@@ -0,0 +1,295 @@
+package auth
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/models"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Cookie 與表頭名稱
+const (
+	SessionCookieName = "aihackathon_session"
+	CSRFCookieName    = "csrf_token" // 非 HttpOnly，供頁面上的 JavaScript 讀取後放入 X-CSRF-Token
+	CSRFHeaderName    = "X-CSRF-Token"
+	CSRFFormField     = "csrf_token"
+)
+
+// anonymousAdmin 為未啟用驗證 (auth.enabled=false) 時使用的虛擬使用者
+var anonymousAdmin = &models.User{Username: "anonymous", Role: models.RoleAdmin, AuthProvider: models.AuthProviderLocal, Enabled: true}
+
+// Manager 負責 session 建立/驗證、權限檢查、CSRF 驗證與操作稽核
+type Manager struct {
+	store          Store
+	cfg            config.AuthConfig
+	trustedProxies []*net.IPNet
+}
+
+// NewManager 建立 Manager 實例
+func NewManager(store Store, cfg config.AuthConfig) (*Manager, error) {
+	if store == nil {
+		return nil, fmt.Errorf("auth.Manager：Store 不得為空")
+	}
+	if cfg.SessionTTLHours <= 0 {
+		cfg.SessionTTLHours = 12
+	}
+	trustedProxies, err := ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("auth.Manager：%w", err)
+	}
+	return &Manager{store: store, cfg: cfg, trustedProxies: trustedProxies}, nil
+}
+
+// ClientIP 回傳請求的來源 IP (依 auth.trustedProxies 決定是否採用 X-Forwarded-For)
+func (m *Manager) ClientIP(r *http.Request) string {
+	return ClientIP(r, m.trustedProxies)
+}
+
+// Enabled 回傳是否啟用登入驗證
+func (m *Manager) Enabled() bool { return m.cfg.Enabled }
+
+// EnsureBootstrapAdmin 在資料庫沒有任何使用者時，依設定建立初始管理員
+func (m *Manager) EnsureBootstrapAdmin() error {
+	if !m.cfg.Enabled {
+		return nil
+	}
+	count, err := m.store.CountUsers()
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	admin := m.cfg.BootstrapAdmin
+	if admin.Username == "" || admin.Password == "" {
+		log.Println("警告：[Auth] 資料庫中沒有任何使用者，且未設定 auth.bootstrapAdmin；請以 cmd/manage-users 建立管理員帳號。")
+		return nil
+	}
+	hash, err := HashPassword(admin.Password)
+	if err != nil {
+		return fmt.Errorf("建立初始管理員失敗: %w", err)
+	}
+	user := &models.User{
+		Username:     admin.Username,
+		PasswordHash: sql.NullString{String: hash, Valid: true},
+		Role:         models.RoleAdmin,
+		AuthProvider: models.AuthProviderLocal,
+		Enabled:      true,
+	}
+	if _, err := m.store.CreateUser(user); err != nil {
+		return err
+	}
+	log.Printf("資訊：[Auth] 已建立初始管理員帳號 '%s'，請登入後盡快變更密碼。", admin.Username)
+	return nil
+}
+
+// StartSession 為使用者建立 session 並設定 cookie
+func (m *Manager) StartSession(w http.ResponseWriter, r *http.Request, user *models.User) error {
+	token, err := NewToken()
+	if err != nil {
+		return err
+	}
+	csrfToken, err := NewToken()
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(time.Duration(m.cfg.SessionTTLHours) * time.Hour)
+	userAgent := r.UserAgent()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	session := &models.Session{
+		ID:        HashToken(token),
+		UserID:    user.ID,
+		CSRFToken: csrfToken,
+		IPAddress: sql.NullString{String: m.ClientIP(r), Valid: true},
+		UserAgent: sql.NullString{String: userAgent, Valid: userAgent != ""},
+		ExpiresAt: expiresAt,
+	}
+	if err := m.store.CreateSession(session); err != nil {
+		return err
+	}
+	if err := m.store.UpdateUserLastLogin(user.ID, time.Now()); err != nil {
+		log.Printf("警告：[Auth] %v", err)
+	}
+	http.SetCookie(w, &http.Cookie{Name: SessionCookieName, Value: token, Path: "/", Expires: expiresAt, HttpOnly: true, Secure: m.cfg.CookieSecure, SameSite: http.SameSiteLaxMode})
+	http.SetCookie(w, &http.Cookie{Name: CSRFCookieName, Value: csrfToken, Path: "/", Expires: expiresAt, Secure: m.cfg.CookieSecure, SameSite: http.SameSiteStrictMode})
+	return nil
+}
+
+// EndSession 刪除目前的 session 並清除 cookie
+func (m *Manager) EndSession(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(SessionCookieName); err == nil && c.Value != "" {
+		if err := m.store.DeleteSession(HashToken(c.Value)); err != nil {
+			log.Printf("錯誤：[Auth] %v", err)
+		}
+	}
+	for _, name := range []string{SessionCookieName, CSRFCookieName} {
+		http.SetCookie(w, &http.Cookie{Name: name, Value: "", Path: "/", MaxAge: -1, Secure: m.cfg.CookieSecure})
+	}
+}
+
+// Authenticate 由 session cookie (或允許時的 HTTP Basic 認證) 找出目前的使用者；未登入時回傳 nil
+func (m *Manager) Authenticate(r *http.Request) (*models.User, *models.Session) {
+	if !m.cfg.Enabled {
+		return anonymousAdmin, nil
+	}
+	if c, err := r.Cookie(SessionCookieName); err == nil && c.Value != "" {
+		session, err := m.store.GetSession(HashToken(c.Value))
+		if err != nil {
+			log.Printf("錯誤：[Auth] %v", err)
+			return nil, nil
+		}
+		if session != nil {
+			user, err := m.store.GetUserByID(session.UserID)
+			if err != nil {
+				log.Printf("錯誤：[Auth] %v", err)
+				return nil, nil
+			}
+			if user != nil && user.Enabled {
+				return user, session
+			}
+		}
+	}
+	// HTTP Basic 認證僅接受不會變更狀態的請求，避免瀏覽器自動帶入帳密造成 CSRF
+	if m.cfg.BasicAuthForGets && isSafeMethod(r.Method) {
+		if username, password, ok := r.BasicAuth(); ok {
+			user, err := m.store.GetUserByUsername(username)
+			if err != nil {
+				log.Printf("錯誤：[Auth] %v", err)
+				return nil, nil
+			}
+			if CheckPassword(user, password) {
+				return user, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// wantsHTML 判斷未登入時應導向登入頁 (瀏覽器頁面) 或回傳 401 (API / 下載)
+func wantsHTML(r *http.Request) bool {
+	return r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") && !strings.HasPrefix(r.URL.Path, "/api/")
+}
+
+func writeAuthError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// validCSRF 驗證變更狀態的請求是否帶有與 session 相符的 CSRF token
+func validCSRF(r *http.Request, session *models.Session) bool {
+	if session == nil {
+		return false
+	}
+	token := r.Header.Get(CSRFHeaderName)
+	if token == "" {
+		token = r.PostFormValue(CSRFFormField)
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(session.CSRFToken)) == 1
+}
+
+// statusRecorder 記錄 handler 回應的狀態碼，供稽核紀錄使用
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// Require 要求請求者至少具備 role 角色；變更狀態的請求另需通過 CSRF 驗證並寫入稽核紀錄
+func (m *Manager) Require(role models.UserRole, next http.Handler) http.Handler {
+	return m.RequireByMethod(role, role, next)
+}
+
+// RequireByMethod 依請求方法要求不同角色：GET/HEAD 需 readRole，其餘需 writeRole
+func (m *Manager) RequireByMethod(readRole, writeRole models.UserRole, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, session := m.Authenticate(r)
+		if user == nil {
+			if wantsHTML(r) {
+				http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+				return
+			}
+			if m.cfg.BasicAuthForGets && isSafeMethod(r.Method) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="AiHackathon-admin", charset="UTF-8"`)
+			}
+			writeAuthError(w, http.StatusUnauthorized, "請先登入")
+			return
+		}
+		need := readRole
+		safe := isSafeMethod(r.Method)
+		if !safe {
+			need = writeRole
+		}
+		if !RoleAllows(user.Role, need) {
+			log.Printf("警告：[Auth] 使用者 '%s' (%s) 無權限存取 %s %s (需要 %s)", user.Username, user.Role, r.Method, r.URL.Path, need)
+			if !safe {
+				m.Audit(r, user, r.Method+" "+r.URL.Path, r.URL.RequestURI(), http.StatusForbidden, "權限不足")
+			}
+			writeAuthError(w, http.StatusForbidden, fmt.Sprintf("權限不足，需要 %s 角色", need))
+			return
+		}
+		if !safe && m.cfg.Enabled && !validCSRF(r, session) {
+			log.Printf("警告：[Auth] 使用者 '%s' 的 %s %s 請求 CSRF 驗證失敗", user.Username, r.Method, r.URL.Path)
+			writeAuthError(w, http.StatusForbidden, "CSRF 驗證失敗，請重新整理頁面後再試")
+			return
+		}
+
+		r = r.WithContext(WithUser(r.Context(), user, session))
+		if safe {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		m.Audit(r, user, r.Method+" "+r.URL.Path, r.URL.RequestURI(), rec.status, "")
+	})
+}
+
+// Audit 寫入一筆稽核紀錄；user 可為 nil (例如登入失敗)
+func (m *Manager) Audit(r *http.Request, user *models.User, action, target string, status int, details string) {
+	entry := &models.AuditLogEntry{
+		Username:   "-",
+		Action:     action,
+		Target:     sql.NullString{String: target, Valid: target != ""},
+		StatusCode: sql.NullInt64{Int64: int64(status), Valid: status > 0},
+		IPAddress:  sql.NullString{String: m.ClientIP(r), Valid: true},
+		Details:    sql.NullString{String: details, Valid: details != ""},
+	}
+	if user != nil {
+		entry.Username = user.Username
+		entry.UserID = sql.NullInt64{Int64: user.ID, Valid: user.ID > 0}
+	}
+	if err := m.store.CreateAuditLog(entry); err != nil {
+		log.Printf("錯誤：[Auth] %v", err)
+	}
+}
+
+// CleanupExpiredSessions 清除已過期的 session (供排程或啟動時呼叫)
+func (m *Manager) CleanupExpiredSessions() {
+	n, err := m.store.DeleteExpiredSessions()
+	if err != nil {
+		log.Printf("錯誤：[Auth] %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("資訊：[Auth] 已清除 %d 個過期的 session。", n)
+	}
+}
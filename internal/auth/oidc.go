@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// OIDCProvider 以 authorization code flow (含 PKCE) 對設定的 issuer 進行單一登入。
+// 使用者資料由 userinfo endpoint 取得 (以 TLS 直接向 issuer 取得，無需另外驗證 ID token 簽章)。
+type OIDCProvider struct {
+	cfg         config.OIDCConfig
+	oauth       *oauth2.Config
+	userInfoURL string
+	httpClient  *http.Client
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// OIDCClaims 為登入後由 userinfo 取得的使用者資訊
+type OIDCClaims struct {
+	Subject           string
+	Email             string
+	Name              string
+	PreferredUsername string
+	Groups            []string
+}
+
+// NewOIDCProvider 讀取 issuer 的 discovery 文件並建立 OIDCProvider
+func NewOIDCProvider(ctx context.Context, cfg config.OIDCConfig) (*OIDCProvider, error) {
+	if cfg.IssuerURL == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, fmt.Errorf("OIDC 設定不完整 (issuerURL、clientID、redirectURL 皆為必填)")
+	}
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	discoveryURL := strings.TrimRight(cfg.IssuerURL, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("讀取 OIDC discovery 文件失敗: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("讀取 OIDC discovery 文件失敗: HTTP %d", resp.StatusCode)
+	}
+	var doc discoveryDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("解析 OIDC discovery 文件失敗: %w", err)
+	}
+	if strings.TrimRight(doc.Issuer, "/") != strings.TrimRight(cfg.IssuerURL, "/") {
+		return nil, fmt.Errorf("OIDC issuer 不符：設定為 %s，discovery 文件為 %s", cfg.IssuerURL, doc.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.UserinfoEndpoint == "" {
+		return nil, fmt.Errorf("OIDC discovery 文件缺少 authorization/token/userinfo endpoint")
+	}
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+	log.Printf("資訊：[Auth] OIDC 已啟用，issuer: %s", doc.Issuer)
+	return &OIDCProvider{
+		cfg: cfg,
+		oauth: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       scopes,
+			Endpoint:     oauth2.Endpoint{AuthURL: doc.AuthorizationEndpoint, TokenURL: doc.TokenEndpoint},
+		},
+		userInfoURL: doc.UserinfoEndpoint,
+		httpClient:  httpClient,
+	}, nil
+}
+
+// AuthCodeURL 回傳導向 issuer 登入頁的網址
+func (p *OIDCProvider) AuthCodeURL(state, verifier string) string {
+	return p.oauth.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier))
+}
+
+// Exchange 以授權碼換取 access token 並讀取 userinfo
+func (p *OIDCProvider) Exchange(ctx context.Context, code, verifier string) (*OIDCClaims, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.httpClient)
+	token, err := p.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("OIDC 授權碼交換失敗: %w", err)
+	}
+	resp, err := p.oauth.Client(ctx, token).Get(p.userInfoURL)
+	if err != nil {
+		return nil, fmt.Errorf("讀取 OIDC userinfo 失敗: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("讀取 OIDC userinfo 失敗: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("讀取 OIDC userinfo 失敗: HTTP %d", resp.StatusCode)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("解析 OIDC userinfo 失敗: %w", err)
+	}
+	claims := &OIDCClaims{
+		Subject:           stringClaim(raw, "sub"),
+		Email:             stringClaim(raw, "email"),
+		Name:              stringClaim(raw, "name"),
+		PreferredUsername: stringClaim(raw, "preferred_username"),
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("OIDC userinfo 缺少 sub")
+	}
+	groupsClaim := p.cfg.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+	if groups, ok := raw[groupsClaim].([]interface{}); ok {
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				claims.Groups = append(claims.Groups, s)
+			}
+		}
+	}
+	return claims, nil
+}
+
+func stringClaim(raw map[string]interface{}, key string) string {
+	s, _ := raw[key].(string)
+	return s
+}
+
+// roleForClaims 依群組對應取得最高角色；沒有符合的群組時使用 defaultRole (可為空)
+func (p *OIDCProvider) roleForClaims(claims *OIDCClaims) models.UserRole {
+	var role models.UserRole
+	for _, g := range claims.Groups {
+		if mapped, ok := p.cfg.GroupRoles[g]; ok {
+			if r, err := ParseRole(mapped); err == nil {
+				role = HigherRole(role, r)
+			}
+		}
+	}
+	if role == "" && p.cfg.DefaultRole != "" {
+		if r, err := ParseRole(p.cfg.DefaultRole); err == nil {
+			role = r
+		}
+	}
+	return role
+}
+
+// ResolveUser 依 OIDC 資訊找出或建立對應的使用者；設定了 groupRoles 時每次登入都會同步角色，
+// 已不屬於任何授權群組 (且未設定 defaultRole) 的既有使用者與新使用者一樣拒絕登入
+func (p *OIDCProvider) ResolveUser(store Store, claims *OIDCClaims) (*models.User, error) {
+	role := p.roleForClaims(claims)
+	user, err := store.GetUserByOIDCSubject(claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		if !user.Enabled {
+			return nil, fmt.Errorf("帳號 '%s' 已停用", user.Username)
+		}
+		if len(p.cfg.GroupRoles) > 0 && role == "" {
+			log.Printf("警告：[Auth] OIDC 使用者 '%s' 已不屬於任何授權群組，拒絕登入 (原角色: %s)", user.Username, user.Role)
+			return nil, fmt.Errorf("OIDC 帳號 '%s' 未被授權使用此系統", claims.Email)
+		}
+		if len(p.cfg.GroupRoles) > 0 && role != user.Role {
+			log.Printf("資訊：[Auth] 依 OIDC 群組將使用者 '%s' 的角色由 %s 更新為 %s", user.Username, user.Role, role)
+			user.Role = role
+			if err := store.UpdateUser(user); err != nil {
+				return nil, err
+			}
+		}
+		return user, nil
+	}
+
+	if role == "" {
+		return nil, fmt.Errorf("OIDC 帳號 '%s' 未被授權使用此系統", claims.Email)
+	}
+	username := claims.PreferredUsername
+	if username == "" {
+		username = claims.Email
+	}
+	if username == "" {
+		username = "oidc-" + claims.Subject
+	}
+	if existing, err := store.GetUserByUsername(username); err != nil {
+		return nil, err
+	} else if existing != nil {
+		return nil, fmt.Errorf("帳號名稱 '%s' 已被其他使用者使用", username)
+	}
+	user = &models.User{
+		Username:     username,
+		DisplayName:  sql.NullString{String: claims.Name, Valid: claims.Name != ""},
+		Email:        sql.NullString{String: claims.Email, Valid: claims.Email != ""},
+		Role:         role,
+		AuthProvider: models.AuthProviderOIDC,
+		OIDCSubject:  sql.NullString{String: claims.Subject, Valid: true},
+		Enabled:      true,
+	}
+	id, err := store.CreateUser(user)
+	if err != nil {
+		return nil, err
+	}
+	user.ID = id
+	log.Printf("資訊：[Auth] 已為 OIDC 使用者 '%s' 建立帳號 (角色: %s)", username, role)
+	return user, nil
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/models"
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+// fakeUserStore 以記憶體保存 OIDC 使用者
+type fakeUserStore struct {
+	Store
+
+	users   map[string]*models.User // OIDC subject -> 使用者
+	updates int
+}
+
+func (s *fakeUserStore) GetUserByOIDCSubject(subject string) (*models.User, error) {
+	u, ok := s.users[subject]
+	if !ok {
+		return nil, nil
+	}
+	copied := *u
+	return &copied, nil
+}
+
+func (s *fakeUserStore) GetUserByUsername(username string) (*models.User, error) {
+	for _, u := range s.users {
+		if u.Username == username {
+			copied := *u
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakeUserStore) CreateUser(user *models.User) (int64, error) {
+	copied := *user
+	copied.ID = int64(len(s.users) + 1)
+	s.users[user.OIDCSubject.String] = &copied
+	return copied.ID, nil
+}
+
+func (s *fakeUserStore) UpdateUser(user *models.User) error {
+	copied := *user
+	s.users[user.OIDCSubject.String] = &copied
+	s.updates++
+	return nil
+}
+
+func newOIDCTestStore() *fakeUserStore {
+	return &fakeUserStore{users: map[string]*models.User{
+		"sub-editor": {ID: 1, Username: "amy", Role: models.RoleEditor, AuthProvider: models.AuthProviderOIDC,
+			OIDCSubject: sql.NullString{String: "sub-editor", Valid: true}, Enabled: true},
+		"sub-disabled": {ID: 2, Username: "bob", Role: models.RoleAdmin, AuthProvider: models.AuthProviderOIDC,
+			OIDCSubject: sql.NullString{String: "sub-disabled", Valid: true}},
+	}}
+}
+
+func TestResolveUser(t *testing.T) {
+	groupRoles := map[string]string{"newsroom": "editor", "ops": "admin", "guests": "viewer"}
+	tests := []struct {
+		name        string
+		cfg         config.OIDCConfig
+		claims      OIDCClaims
+		wantErr     string
+		wantRole    models.UserRole
+		wantUpdates int
+	}{
+		{
+			name:     "角色不變",
+			cfg:      config.OIDCConfig{GroupRoles: groupRoles},
+			claims:   OIDCClaims{Subject: "sub-editor", Email: "amy@example.com", Groups: []string{"newsroom"}},
+			wantRole: models.RoleEditor,
+		},
+		{
+			name:        "依群組升級",
+			cfg:         config.OIDCConfig{GroupRoles: groupRoles},
+			claims:      OIDCClaims{Subject: "sub-editor", Email: "amy@example.com", Groups: []string{"newsroom", "ops"}},
+			wantRole:    models.RoleAdmin,
+			wantUpdates: 1,
+		},
+		{
+			name:        "依群組降級",
+			cfg:         config.OIDCConfig{GroupRoles: groupRoles},
+			claims:      OIDCClaims{Subject: "sub-editor", Email: "amy@example.com", Groups: []string{"guests"}},
+			wantRole:    models.RoleViewer,
+			wantUpdates: 1,
+		},
+		{
+			name:    "已不屬於任何授權群組時拒絕登入",
+			cfg:     config.OIDCConfig{GroupRoles: groupRoles},
+			claims:  OIDCClaims{Subject: "sub-editor", Email: "amy@example.com", Groups: []string{"alumni"}},
+			wantErr: "未被授權",
+		},
+		{
+			name:        "不屬於授權群組時降為 defaultRole",
+			cfg:         config.OIDCConfig{GroupRoles: groupRoles, DefaultRole: "viewer"},
+			claims:      OIDCClaims{Subject: "sub-editor", Email: "amy@example.com"},
+			wantRole:    models.RoleViewer,
+			wantUpdates: 1,
+		},
+		{
+			name:     "未設定 groupRoles 時不同步角色",
+			cfg:      config.OIDCConfig{DefaultRole: "viewer"},
+			claims:   OIDCClaims{Subject: "sub-editor", Email: "amy@example.com"},
+			wantRole: models.RoleEditor,
+		},
+		{
+			name:    "停用的帳號",
+			cfg:     config.OIDCConfig{GroupRoles: groupRoles},
+			claims:  OIDCClaims{Subject: "sub-disabled", Email: "bob@example.com", Groups: []string{"ops"}},
+			wantErr: "已停用",
+		},
+		{
+			name:     "新使用者",
+			cfg:      config.OIDCConfig{GroupRoles: groupRoles},
+			claims:   OIDCClaims{Subject: "sub-new", Email: "cat@example.com", PreferredUsername: "cat", Groups: []string{"newsroom"}},
+			wantRole: models.RoleEditor,
+		},
+		{
+			name:    "不屬於授權群組的新使用者",
+			cfg:     config.OIDCConfig{GroupRoles: groupRoles},
+			claims:  OIDCClaims{Subject: "sub-new", Email: "cat@example.com", Groups: []string{"alumni"}},
+			wantErr: "未被授權",
+		},
+		{
+			name:    "帳號名稱已被使用",
+			cfg:     config.OIDCConfig{GroupRoles: groupRoles},
+			claims:  OIDCClaims{Subject: "sub-new", Email: "amy@example.com", PreferredUsername: "amy", Groups: []string{"newsroom"}},
+			wantErr: "已被其他使用者使用",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newOIDCTestStore()
+			p := &OIDCProvider{cfg: tt.cfg}
+			claims := tt.claims
+			user, err := p.ResolveUser(store, &claims)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ResolveUser() error = %v, want 包含 %q", err, tt.wantErr)
+				}
+				if store.updates != 0 {
+					t.Errorf("拒絕登入時不應更新使用者 (%d 次)", store.updates)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.Role != tt.wantRole {
+				t.Errorf("角色 = %s, want %s", user.Role, tt.wantRole)
+			}
+			if store.updates != tt.wantUpdates {
+				t.Errorf("更新次數 = %d, want %d", store.updates, tt.wantUpdates)
+			}
+			if saved := store.users[claims.Subject]; saved == nil || saved.Role != tt.wantRole {
+				t.Errorf("儲存的使用者 = %+v", saved)
+			}
+		})
+	}
+}
@@ -1,31 +1,57 @@
 package gemini
 
 import (
+	"AiHackathon-admin/internal/config"
 	"AiHackathon-admin/internal/models"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
-	"os"
 	"path/filepath"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/option"
 )
 
+const (
+	fileProcessingTimeout      = 10 * time.Minute // 等待 File API 處理上傳影片的上限
+	fileProcessingPollInterval = 3 * time.Second
+)
+
 // Client 結構用於與 Gemini API 互動
 type Client struct {
 	textAnalysisModel  *genai.GenerativeModel
 	videoAnalysisModel *genai.GenerativeModel
+	longVideoModel     *genai.GenerativeModel // 未設定長影片路由時為 nil
+	longVideoMinSecs   int64
+
+	textInfo      CallInfo
+	videoInfo     CallInfo
+	longVideoInfo CallInfo
+
+	// 回應被阻擋時的備援設定 (geminiClient.fallback)
+	relaxedSafety      []*genai.SafetySetting
+	relaxedSafetyNames map[string]string
+	textOnlyFallback   bool
+
+	sdk *genai.Client // 上傳影片至 File API
 }
 
-// NewClient 建立一個 Gemini 客戶端實例
-func NewClient(apiKey string, textModelName string, videoModelName string) (*Client, error) {
-	if apiKey == "" {
+// NewClient 依設定建立 Gemini 客戶端實例：文本與影片分析各自使用設定的模型與生成參數，
+// 並可將長影片改由另一個模型分析
+func NewClient(cfg config.GeminiClientConfig) (*Client, error) {
+	if cfg.APIKey == "" {
 		return nil, fmt.Errorf("Gemini API Key 不得為空")
 	}
+	if err := ValidateSettings(cfg); err != nil {
+		return nil, fmt.Errorf("Gemini 生成參數無效: %w", err)
+	}
+	textModelName, videoModelName := cfg.TextModelName, cfg.VideoModelName
 	if textModelName == "" {
 		textModelName = "gemini-2.5-flash-latest"
 		log.Printf("警告：[Gemini Client] 未提供文本分析模型名稱，使用預設值: %s\n", textModelName)
@@ -36,27 +62,45 @@ func NewClient(apiKey string, textModelName string, videoModelName string) (*Cli
 	}
 
 	ctx := context.Background()
-	genaiSDKClient, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+	genaiSDKClient, err := genai.NewClient(ctx, option.WithAPIKey(cfg.APIKey))
 	if err != nil {
 		return nil, fmt.Errorf("無法建立 Gemini GenAI SDK 客戶端: %w", err)
 	}
 
-	txtModel := genaiSDKClient.GenerativeModel(textModelName)
-	var textGenConfig genai.GenerationConfig
-	textGenConfig.ResponseMIMEType = "application/json"
-	txtModel.GenerationConfig = textGenConfig
-	log.Printf("資訊：[Gemini Client] 文本分析模型 '%s' 初始化成功。\n", textModelName)
+	c := &Client{sdk: genaiSDKClient}
+	if c.textAnalysisModel, c.textInfo, err = newModel(genaiSDKClient, textModelName, cfg.Text); err != nil {
+		return nil, err
+	}
+	log.Printf("資訊：[Gemini Client] 文本分析模型 '%s' 初始化成功，參數: %s\n", textModelName, c.textInfo.Params)
 
-	vidModel := genaiSDKClient.GenerativeModel(videoModelName)
-	var videoGenConfig genai.GenerationConfig
-	videoGenConfig.ResponseMIMEType = "application/json"
-	vidModel.GenerationConfig = videoGenConfig
-	log.Printf("資訊：[Gemini Client] 影片分析模型 '%s' 初始化成功。\n", videoModelName)
+	if c.videoAnalysisModel, c.videoInfo, err = newModel(genaiSDKClient, videoModelName, cfg.Video); err != nil {
+		return nil, err
+	}
+	log.Printf("資訊：[Gemini Client] 影片分析模型 '%s' 初始化成功，參數: %s\n", videoModelName, c.videoInfo.Params)
 
-	return &Client{
-		textAnalysisModel:  txtModel,
-		videoAnalysisModel: vidModel,
-	}, nil
+	if c.relaxedSafety, c.relaxedSafetyNames, err = parseSafetySettings(cfg.Fallback.RelaxedSafetySettings); err != nil {
+		return nil, err
+	}
+	c.textOnlyFallback = cfg.Fallback.TextOnly
+	log.Printf("資訊：[Gemini Client] 備援分析：放寬安全設定重試 %t，純文字分析 %t\n", len(c.relaxedSafety) > 0, c.textOnlyFallback)
+
+	if cfg.LongVideo.MinDurationSecs > 0 {
+		longGen := mergeGeneration(cfg.Video, cfg.LongVideo.Generation)
+		if c.longVideoModel, c.longVideoInfo, err = newModel(genaiSDKClient, cfg.LongVideo.ModelName, longGen); err != nil {
+			return nil, err
+		}
+		c.longVideoMinSecs = cfg.LongVideo.MinDurationSecs
+		log.Printf("資訊：[Gemini Client] 長影片 (>= %d 秒) 分析模型 '%s' 初始化成功，參數: %s\n", c.longVideoMinSecs, cfg.LongVideo.ModelName, c.longVideoInfo.Params)
+	}
+	return c, nil
+}
+
+// selectVideoModel 依影片長度選擇分析模型；長度未知 (<= 0) 時使用一般影片模型
+func (c *Client) selectVideoModel(durationSecs int64) (*genai.GenerativeModel, CallInfo) {
+	if c.longVideoModel != nil && durationSecs >= c.longVideoMinSecs {
+		return c.longVideoModel, c.longVideoInfo
+	}
+	return c.videoAnalysisModel, c.videoInfo
 }
 
 // cleanJSONString 清理從 LLM 收到的可能包含雜質的 JSON 字串
@@ -179,8 +223,15 @@ func cleanJSONString(rawResponse string) string {
 	return finalCleaned
 }
 
-// AnalyzeText 向 Gemini API 發送純文本內容和提示以進行分析，期望回傳 JSON 字串
-func (c *Client) AnalyzeText(ctx context.Context, textContent string, prompt string) (string, error) {
+// AnalyzeText 向 Gemini API 發送純文本內容和提示以進行分析，期望回傳 JSON 字串；
+// CallInfo 為實際使用的模型與生成參數。回應被阻擋或截斷時回傳 *BlockedError
+func (c *Client) AnalyzeText(ctx context.Context, textContent string, prompt string) (string, CallInfo, error) {
+	info := c.textInfo
+	jsonText, err := c.analyzeText(ctx, &info, textContent, prompt)
+	return jsonText, info, err
+}
+
+func (c *Client) analyzeText(ctx context.Context, info *CallInfo, textContent string, prompt string) (string, error) {
 	log.Printf("資訊：[Gemini Client] AnalyzeText - 開始分析文本內容 (長度: %d 字元)\n", len(textContent))
 	if strings.TrimSpace(textContent) == "" {
 		return "", fmt.Errorf("要分析的文本內容不得為空")
@@ -191,62 +242,134 @@ func (c *Client) AnalyzeText(ctx context.Context, textContent string, prompt str
 
 	requestParts := []genai.Part{genai.Text(prompt), genai.Text(textContent)}
 	log.Println("資訊：[Gemini Client] AnalyzeText - 正在向 Gemini API 發送請求...")
-	resp, err := c.textAnalysisModel.GenerateContent(ctx, requestParts...)
+	resp, err := generate(ctx, c.textAnalysisModel, info, requestParts...)
 	if err != nil {
-		return "", fmt.Errorf("Gemini API 文本分析 GenerateContent 失敗: %w", err)
+		return "", classifyError("文本分析", err)
 	}
+	return extractJSON(resp, "文本分析", "AnalyzeText")
+}
 
-	if resp == nil || len(resp.Candidates) == 0 {
-		return "", fmt.Errorf("Gemini API 文本分析回應無效或為空 (nil response or no candidates)")
+// AnalyzeVideo 向 Gemini API 發送影片和提示以進行分析。durationSecs 為影片長度 (未知時傳 0)，
+// 用於將長影片改由設定的長影片模型分析；CallInfo 為實際使用的模型與生成參數。
+// 回應被阻擋或截斷時回傳 *BlockedError，可改用 AnalyzeVideoRelaxed 或 AnalyzeVideoFromText 備援
+// 影片內容由 video 串流上傳，fileName 用於判斷 MIME 類型與記錄
+func (c *Client) AnalyzeVideo(ctx context.Context, video io.Reader, fileName string, prompt string, durationSecs int64) (*models.AnalysisResult, CallInfo, error) {
+	model, info := c.selectVideoModel(durationSecs)
+	log.Printf("資訊：[Gemini Client] AnalyzeVideo - 影片長度 %d 秒，使用模型 '%s'\n", durationSecs, info.ModelName)
+	analysis, err := c.analyzeVideo(ctx, model, &info, video, fileName, prompt)
+	return analysis, info, err
+}
+
+// AnalyzeVideoRelaxed 以 fallback.relaxedSafetySettings 重新分析因安全設定被阻擋的影片；
+// 未設定放寬的安全設定時回傳 ErrFallbackDisabled
+func (c *Client) AnalyzeVideoRelaxed(ctx context.Context, video io.Reader, fileName string, prompt string, durationSecs int64) (*models.AnalysisResult, CallInfo, error) {
+	if len(c.relaxedSafety) == 0 {
+		return nil, CallInfo{}, ErrFallbackDisabled
 	}
-	candidate := resp.Candidates[0]
-	if candidate.Content == nil || len(candidate.Content.Parts) == 0 {
-		if candidate.FinishReason != genai.FinishReasonStop && candidate.FinishReason != genai.FinishReasonUnspecified {
-			logMsg := fmt.Sprintf("Gemini API 文本分析回應無效或內容被阻止，原因: %s", candidate.FinishReason.String())
-			if candidate.SafetyRatings != nil {
-				for _, rating := range candidate.SafetyRatings {
-					log.Printf("警告：[Gemini Client] 安全評級 (文本分析) - Category: %s, Probability: %s\n", rating.Category, rating.Probability)
-				}
-			}
-			return "", fmt.Errorf(logMsg)
-		}
-		return "", fmt.Errorf("Gemini API 文本分析回應無效或為空 (no content parts, FinishReason: %s)", candidate.FinishReason.String())
+	model, info := c.relaxedVideoModel(durationSecs)
+	log.Printf("資訊：[Gemini Client] AnalyzeVideoRelaxed - 以放寬的安全設定重新分析影片: %s\n", fileName)
+	analysis, err := c.analyzeVideo(ctx, model, &info, video, fileName, prompt)
+	return analysis, info, err
+}
+
+// relaxedVideoModel 回傳套用 fallback.relaxedSafetySettings 的影片分析模型 (複本，不影響原模型)
+func (c *Client) relaxedVideoModel(durationSecs int64) (*genai.GenerativeModel, CallInfo) {
+	base, info := c.selectVideoModel(durationSecs)
+	model := *base
+	model.SafetySettings = c.relaxedSafety
+	info.Params = paramsWithSafety(info.Params, c.relaxedSafetyNames)
+	return &model, info
+}
+
+// UploadedVideo 已上傳至 File API 的影片，可重複用於多次分析 (例如長影片以 prompt 指定時間範圍分段分析)，
+// 使用完畢需呼叫 DeleteVideo
+type UploadedVideo struct {
+	file     *genai.File
+	fileName string
+}
+
+// UploadVideo 串流上傳影片並等待 File API 處理完成
+func (c *Client) UploadVideo(ctx context.Context, video io.Reader, fileName string) (*UploadedVideo, error) {
+	file, err := c.uploadVideo(ctx, video, fileName, videoMIMEType(fileName))
+	if err != nil {
+		return nil, err
 	}
-	var responseTextBuilder strings.Builder
-	for _, part := range candidate.Content.Parts {
-		if txt, ok := part.(genai.Text); ok {
-			responseTextBuilder.WriteString(string(txt))
-		} else {
-			log.Printf("警告：[Gemini Client] AnalyzeText - 收到非預期的 Part 類型: %T\n", part)
-		}
+	return &UploadedVideo{file: file, fileName: fileName}, nil
+}
+
+// DeleteVideo 刪除 UploadVideo 上傳的影片
+func (c *Client) DeleteVideo(video *UploadedVideo) {
+	if video != nil {
+		c.deleteUploadedFile(video.file.Name)
 	}
-	rawJsonResponseString := responseTextBuilder.String()
-	if strings.TrimSpace(rawJsonResponseString) == "" {
-		return "", fmt.Errorf("Gemini API 文本分析回傳的內容為空")
+}
+
+// AnalyzeUploadedVideo 同 AnalyzeVideo，但分析已上傳的影片 (不重新上傳)
+func (c *Client) AnalyzeUploadedVideo(ctx context.Context, video *UploadedVideo, prompt string, durationSecs int64) (*models.AnalysisResult, CallInfo, error) {
+	model, info := c.selectVideoModel(durationSecs)
+	analysis, err := c.analyzeFile(ctx, model, &info, video.file, video.fileName, prompt)
+	return analysis, info, err
+}
+
+// AnalyzeUploadedVideoRelaxed 同 AnalyzeVideoRelaxed，但分析已上傳的影片 (不重新上傳)
+func (c *Client) AnalyzeUploadedVideoRelaxed(ctx context.Context, video *UploadedVideo, prompt string, durationSecs int64) (*models.AnalysisResult, CallInfo, error) {
+	if len(c.relaxedSafety) == 0 {
+		return nil, CallInfo{}, ErrFallbackDisabled
 	}
-	log.Printf("資訊：[Gemini Client] AnalyzeText - 收到 API 的原始文字回應 (長度: %d):\nRAW_TEXT_START\n%s\nRAW_TEXT_END\n", len(rawJsonResponseString), rawJsonResponseString)
+	model, info := c.relaxedVideoModel(durationSecs)
+	analysis, err := c.analyzeFile(ctx, model, &info, video.file, video.fileName, prompt)
+	return analysis, info, err
+}
 
-	cleanedJSONString := cleanJSONString(rawJsonResponseString)
-	log.Printf("資訊：[Gemini Client] AnalyzeText - 清理後的 JSON 字串 (長度: %d):\nCLEANED_TEXT_START\n%s\nCLEANED_TEXT_END\n", len(cleanedJSONString), cleanedJSONString)
+// AnalyzeVideoFromText 在無法分析影片畫面時，以影片 prompt 與文字元數據 (標題、SHOTLIST 等) 進行純文字分析，
+// 使用文本分析模型；未啟用 fallback.textOnly 時回傳 ErrFallbackDisabled
+func (c *Client) AnalyzeVideoFromText(ctx context.Context, prompt string, metadataText string) (*models.AnalysisResult, CallInfo, error) {
+	if !c.textOnlyFallback {
+		return nil, CallInfo{}, ErrFallbackDisabled
+	}
+	info := c.textInfo
+	textPrompt := prompt + "\n\n" + textOnlyInstruction
+	jsonText, err := c.analyzeText(ctx, &info, metadataText, textPrompt)
+	if err != nil {
+		return nil, info, err
+	}
+	analysis, err := parseAnalysis(jsonText, "純文字影片分析")
+	return analysis, info, err
+}
 
-	if !json.Valid([]byte(cleanedJSONString)) {
-		log.Printf("錯誤：[Gemini Client] AnalyzeText - 清理後的字串仍然不是有效的 JSON。完整的 Cleaned JSON String:\n%s\n", cleanedJSONString)
-		return "", fmt.Errorf("清理後的字串不是有效的 JSON (文本分析)")
+// generate 送出請求並將 token 用量記錄於 info。回應被阻擋 (SAFETY、RECITATION) 時 SDK 不回傳回應，
+// 但 prompt 仍會計費，改以 CountTokens 取得 prompt 的 token 數
+func generate(ctx context.Context, model *genai.GenerativeModel, info *CallInfo, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	resp, err := model.GenerateContent(ctx, parts...)
+	info.setUsage(resp)
+	var blocked *genai.BlockedError
+	if resp == nil && errors.As(err, &blocked) {
+		count, countErr := model.CountTokens(ctx, parts...)
+		if countErr != nil {
+			log.Printf("警告：[Gemini Client] 回應被阻擋，且無法計算 prompt 的 token 數: %v\n", countErr)
+		} else {
+			info.PromptTokens, info.TotalTokens = count.TotalTokens, count.TotalTokens
+		}
 	}
-	return cleanedJSONString, nil
+	return resp, err
 }
 
-// AnalyzeVideo 向 Gemini API 發送影片和提示以進行分析
-func (c *Client) AnalyzeVideo(ctx context.Context, videoPath string, prompt string) (*models.AnalysisResult, error) {
-	log.Printf("資訊：[Gemini Client] AnalyzeVideo - 開始分析影片: %s\n", videoPath)
-	log.Printf("資訊：[Gemini Client] AnalyzeVideo - 使用影片分析 Prompt (前100字元): %s...\n", firstNChars(prompt, 100))
+// textOnlyInstruction 附加於純文字備援分析的 prompt 之後
+const textOnlyInstruction = "注意：本次無法提供影片畫面與聲音，請僅依下列文字資料 (標題、SHOTLIST、地點、主題) 進行分析；無法由文字判斷的欄位 (例如逐字稿、畫面描述) 請留空。"
 
-	videoData, err := os.ReadFile(videoPath)
+func (c *Client) analyzeVideo(ctx context.Context, model *genai.GenerativeModel, info *CallInfo, video io.Reader, fileName string, prompt string) (*models.AnalysisResult, error) {
+	file, err := c.uploadVideo(ctx, video, fileName, videoMIMEType(fileName))
 	if err != nil {
-		return nil, fmt.Errorf("讀取影片檔案 %s 失敗: %w", videoPath, err)
+		return nil, err
 	}
+	defer c.deleteUploadedFile(file.Name)
+	return c.analyzeFile(ctx, model, info, file, fileName, prompt)
+}
+
+// videoMIMEType 依副檔名判斷上傳影片的 MIME 類型，未知時視為 video/mp4
+func videoMIMEType(fileName string) string {
 	videoMIMEType := "video/mp4"
-	ext := strings.ToLower(filepath.Ext(videoPath))
+	ext := strings.ToLower(filepath.Ext(fileName))
 	switch ext {
 	case ".mp4":
 		videoMIMEType = "video/mp4"
@@ -266,56 +389,123 @@ func (c *Client) AnalyzeVideo(ctx context.Context, videoPath string, prompt stri
 		log.Printf("警告：[Gemini Client] 未知的影片副檔名 '%s'\n", ext)
 	}
 	log.Printf("資訊：[Gemini Client] 使用影片 MIME 類型: %s\n", videoMIMEType)
-	videoFilePart := genai.Blob{MIMEType: videoMIMEType, Data: videoData}
+	return videoMIMEType
+}
+
+// analyzeFile 以已上傳至 File API 的影片送出分析請求
+func (c *Client) analyzeFile(ctx context.Context, model *genai.GenerativeModel, info *CallInfo, file *genai.File, fileName string, prompt string) (*models.AnalysisResult, error) {
+	log.Printf("資訊：[Gemini Client] AnalyzeVideo - 開始分析影片: %s\n", fileName)
+	log.Printf("資訊：[Gemini Client] AnalyzeVideo - 使用影片分析 Prompt (前100字元): %s...\n", firstNChars(prompt, 100))
+	videoFilePart := genai.FileData{MIMEType: file.MIMEType, URI: file.URI}
 	requestParts := []genai.Part{genai.Text(prompt), videoFilePart}
 	log.Println("資訊：[Gemini Client] AnalyzeVideo - 正在向 Gemini API 發送請求...")
-	resp, err := c.videoAnalysisModel.GenerateContent(ctx, requestParts...)
+	resp, err := generate(ctx, model, info, requestParts...)
+	if err != nil {
+		return nil, classifyError("影片分析", err)
+	}
+	cleanedJSONString, err := extractJSON(resp, "影片分析", "AnalyzeVideo")
+	if err != nil {
+		return nil, err
+	}
+	analysis, err := parseAnalysis(cleanedJSONString, "影片分析")
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("資訊：[Gemini Client] 影片 '%s' JSON 回應解析成功。\n", fileName)
+	return analysis, nil
+}
+
+// uploadVideo 將影片串流上傳至 Gemini File API 並等待處理完成 (最多 fileProcessingTimeout)，不會將整個影片載入記憶體
+func (c *Client) uploadVideo(ctx context.Context, video io.Reader, fileName string, mimeType string) (*genai.File, error) {
+	log.Printf("資訊：[Gemini Client] 正在上傳影片 '%s' 至 File API...\n", fileName)
+	file, err := c.sdk.UploadFile(ctx, "", video, &genai.UploadFileOptions{DisplayName: filepath.Base(fileName), MIMEType: mimeType})
 	if err != nil {
-		return nil, fmt.Errorf("Gemini API 影片分析 GenerateContent 失敗: %w", err)
+		return nil, fmt.Errorf("上傳影片 '%s' 至 Gemini File API 失敗: %w", fileName, err)
 	}
+	deadline := time.Now().Add(fileProcessingTimeout)
+	for file.State == genai.FileStateProcessing {
+		if time.Now().After(deadline) {
+			c.deleteUploadedFile(file.Name)
+			return nil, fmt.Errorf("影片 '%s' 在 %s 內未完成處理", fileName, fileProcessingTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			c.deleteUploadedFile(file.Name)
+			return nil, ctx.Err()
+		case <-time.After(fileProcessingPollInterval):
+		}
+		if file, err = c.sdk.GetFile(ctx, file.Name); err != nil {
+			return nil, fmt.Errorf("查詢影片 '%s' 的處理狀態失敗: %w", fileName, err)
+		}
+	}
+	if file.State != genai.FileStateActive {
+		c.deleteUploadedFile(file.Name)
+		return nil, fmt.Errorf("Gemini File API 無法處理影片 '%s' (狀態: %s)", fileName, file.State)
+	}
+	log.Printf("資訊：[Gemini Client] 影片 '%s' 上傳完成 (%d bytes)。\n", fileName, file.SizeBytes)
+	return file, nil
+}
+
+// deleteUploadedFile 分析完成後刪除 File API 上的影片 (未刪除的檔案也會在 48 小時後自動過期)
+func (c *Client) deleteUploadedFile(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := c.sdk.DeleteFile(ctx, name); err != nil {
+		log.Printf("警告：[Gemini Client] 刪除 File API 上的影片 '%s' 失敗: %v\n", name, err)
+	}
+}
+
+// extractJSON 取出回應的文字內容並清理為 JSON 字串。
+// 回應因 MAX_TOKENS 被截斷且無法解析時回傳 *BlockedError (截斷但仍為有效 JSON 時照常回傳)
+func extractJSON(resp *genai.GenerateContentResponse, task string, logTag string) (string, error) {
 	if resp == nil || len(resp.Candidates) == 0 {
-		return nil, fmt.Errorf("Gemini API 影片分析回應無效或為空 (nil response or no candidates)")
+		return "", fmt.Errorf("Gemini API %s回應無效或為空 (nil response or no candidates)", task)
 	}
 	candidate := resp.Candidates[0]
-	if candidate.Content == nil || len(candidate.Content.Parts) == 0 {
-		if candidate.FinishReason != genai.FinishReasonStop && candidate.FinishReason != genai.FinishReasonUnspecified {
-			logMsg := fmt.Sprintf("Gemini API 影片分析回應無效或內容被阻止，原因: %s", candidate.FinishReason.String())
-			if candidate.SafetyRatings != nil {
-				for _, rating := range candidate.SafetyRatings {
-					log.Printf("警告：[Gemini Client] 安全評級 (影片分析) - Category: %s, Probability: %s\n", rating.Category, rating.Probability)
-				}
+	truncated := candidate.FinishReason == genai.FinishReasonMaxTokens
+	var responseTextBuilder strings.Builder
+	if candidate.Content != nil {
+		for _, part := range candidate.Content.Parts {
+			if txt, ok := part.(genai.Text); ok {
+				responseTextBuilder.WriteString(string(txt))
+			} else {
+				log.Printf("警告：[Gemini Client] %s - 收到非預期的 Part 類型: %T\n", logTag, part)
 			}
-			return nil, fmt.Errorf(logMsg)
 		}
-		return nil, fmt.Errorf("Gemini API 影片分析回應無效或為空 (no content parts, FinishReason: %s)", candidate.FinishReason.String())
 	}
-	var responseTextBuilder strings.Builder
-	for _, part := range candidate.Content.Parts {
-		if txt, ok := part.(genai.Text); ok {
-			responseTextBuilder.WriteString(string(txt))
-		} else {
-			log.Printf("警告：[Gemini Client] AnalyzeVideo - 收到非預期的 Part 類型: %T\n", part)
+	rawText := responseTextBuilder.String()
+	if strings.TrimSpace(rawText) == "" {
+		if truncated {
+			return "", &BlockedError{Outcome: OutcomeMaxTokens, FinishReason: "MAX_TOKENS", SafetyRatings: convertRatings(candidate.SafetyRatings)}
 		}
+		return "", fmt.Errorf("Gemini API %s回應無效或為空 (no content parts, FinishReason: %s)", task, candidate.FinishReason.String())
 	}
-	rawFullResponseText := responseTextBuilder.String()
-	if strings.TrimSpace(rawFullResponseText) == "" {
-		return nil, fmt.Errorf("Gemini API 影片分析回傳的文字內容為空")
-	}
-	log.Printf("資訊：[Gemini Client] AnalyzeVideo - 收到 API 的原始文字回應 (長度: %d):\nRAW_VIDEO_JSON_START\n%s\nRAW_VIDEO_JSON_END\n", len(rawFullResponseText), rawFullResponseText)
+	log.Printf("資訊：[Gemini Client] %s - 收到 API 的原始文字回應 (長度: %d):\nRAW_TEXT_START\n%s\nRAW_TEXT_END\n", logTag, len(rawText), rawText)
 
-	cleanedJSONString := cleanJSONString(rawFullResponseText)
-	log.Printf("資訊：[Gemini Client] AnalyzeVideo - 清理後的 JSON 字串準備解析 (長度: %d):\nCLEANED_VIDEO_JSON_START\n%s\nCLEANED_VIDEO_JSON_END\n", len(cleanedJSONString), cleanedJSONString)
+	cleanedJSONString := cleanJSONString(rawText)
+	log.Printf("資訊：[Gemini Client] %s - 清理後的 JSON 字串 (長度: %d):\nCLEANED_TEXT_START\n%s\nCLEANED_TEXT_END\n", logTag, len(cleanedJSONString), cleanedJSONString)
 
 	if !json.Valid([]byte(cleanedJSONString)) {
-		log.Printf("錯誤：[Gemini Client] AnalyzeVideo - 清理後的字串仍然不是有效的 JSON。完整的 Cleaned JSON String:\n%s\n", cleanedJSONString)
-		return nil, fmt.Errorf("清理後的字串不是有效的 JSON (影片分析)")
+		if truncated {
+			log.Printf("錯誤：[Gemini Client] %s - 回應超過輸出 token 上限被截斷，無法解析為 JSON。\n", logTag)
+			return "", &BlockedError{Outcome: OutcomeMaxTokens, FinishReason: "MAX_TOKENS", SafetyRatings: convertRatings(candidate.SafetyRatings)}
+		}
+		log.Printf("錯誤：[Gemini Client] %s - 清理後的字串仍然不是有效的 JSON。完整的 Cleaned JSON String:\n%s\n", logTag, cleanedJSONString)
+		return "", fmt.Errorf("清理後的字串不是有效的 JSON (%s)", task)
+	}
+	if truncated {
+		log.Printf("警告：[Gemini Client] %s - 回應達輸出 token 上限 (MAX_TOKENS)，但仍為有效的 JSON，照常使用。\n", logTag)
 	}
+	return cleanedJSONString, nil
+}
+
+// parseAnalysis 將清理後的 JSON 字串解析為影片分析結果
+func parseAnalysis(cleanedJSONString string, task string) (*models.AnalysisResult, error) {
 	var analysis models.AnalysisResult
 	if err := json.Unmarshal([]byte(cleanedJSONString), &analysis); err != nil {
-		log.Printf("錯誤：[Gemini Client] AnalyzeVideo - 無法將 Gemini API 回應解析為 JSON: %v\n完整的 Cleaned JSON String:\n%s\n", err, cleanedJSONString)
-		return nil, fmt.Errorf("無法將 Gemini API 回應解析為 JSON (影片分析): %w。請檢查日誌中的完整 JSON 字串。", err)
+		log.Printf("錯誤：[Gemini Client] 無法將 Gemini API 回應解析為 JSON (%s): %v\n完整的 Cleaned JSON String:\n%s\n", task, err, cleanedJSONString)
+		return nil, fmt.Errorf("無法將 Gemini API 回應解析為 JSON (%s): %w。請檢查日誌中的完整 JSON 字串。", task, err)
 	}
-	log.Printf("資訊：[Gemini Client] 影片 '%s' JSON 回應解析成功。\n", videoPath)
 	return &analysis, nil
 }
 
@@ -0,0 +1,112 @@
+package gemini
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// Outcome 分析回應的結束類型，與分析結果一併保存
+type Outcome string
+
+const (
+	OutcomeOK         Outcome = "ok"
+	OutcomeSafety     Outcome = "safety"     // prompt 或回應因安全設定被阻擋
+	OutcomeMaxTokens  Outcome = "max_tokens" // 回應超過 maxOutputTokens 被截斷且無法解析
+	OutcomeRecitation Outcome = "recitation" // 回應與受保護內容過於相似而被阻擋
+)
+
+// ErrFallbackDisabled 表示設定檔未啟用對應的備援分析
+var ErrFallbackDisabled = errors.New("未啟用備援分析")
+
+// SafetyRating 被阻擋時回應 (或 prompt) 的安全評級
+type SafetyRating struct {
+	Category    string `json:"category"`    // 與設定檔 safetySettings 相同的類別名稱，例如 dangerous_content
+	Probability string `json:"probability"` // negligible、low、medium、high
+	Blocked     bool   `json:"blocked,omitempty"`
+}
+
+// BlockedError 表示回應因安全設定、輸出長度上限或引用內容而未能取得可用的結果
+type BlockedError struct {
+	Outcome       Outcome
+	FinishReason  string // SAFETY、RECITATION、MAX_TOKENS，prompt 被阻擋時為 PROMPT_BLOCKED_*
+	SafetyRatings []SafetyRating
+}
+
+func (e *BlockedError) Error() string {
+	switch e.Outcome {
+	case OutcomeSafety:
+		return fmt.Sprintf("Gemini 回應因安全設定被阻擋 (%s)", e.FinishReason)
+	case OutcomeMaxTokens:
+		return "Gemini 回應超過輸出 token 上限被截斷 (MAX_TOKENS)，無法解析"
+	case OutcomeRecitation:
+		return "Gemini 回應因引用受保護內容被阻擋 (RECITATION)"
+	}
+	return fmt.Sprintf("Gemini 回應未完成 (%s)", e.FinishReason)
+}
+
+// OutcomeOf 回傳分析錯誤對應的結束類型：nil 為 OutcomeOK，非 BlockedError 的錯誤回傳空字串
+func OutcomeOf(err error) Outcome {
+	if err == nil {
+		return OutcomeOK
+	}
+	var blocked *BlockedError
+	if errors.As(err, &blocked) {
+		return blocked.Outcome
+	}
+	return ""
+}
+
+var harmProbabilities = map[genai.HarmProbability]string{
+	genai.HarmProbabilityNegligible: "negligible",
+	genai.HarmProbabilityLow:        "low",
+	genai.HarmProbabilityMedium:     "medium",
+	genai.HarmProbabilityHigh:       "high",
+}
+
+func convertRatings(ratings []*genai.SafetyRating) []SafetyRating {
+	out := make([]SafetyRating, 0, len(ratings))
+	for _, r := range ratings {
+		if r == nil {
+			continue
+		}
+		category := strings.TrimPrefix(r.Category.String(), "HarmCategory")
+		for name, c := range harmCategories {
+			if c == r.Category {
+				category = name
+				break
+			}
+		}
+		probability, ok := harmProbabilities[r.Probability]
+		if !ok {
+			probability = "unspecified"
+		}
+		out = append(out, SafetyRating{Category: category, Probability: probability, Blocked: r.Blocked})
+	}
+	return out
+}
+
+// classifyError 將 SDK 的 *genai.BlockedError 轉為 *BlockedError，其他錯誤加上說明後回傳
+func classifyError(task string, err error) error {
+	var sdkBlocked *genai.BlockedError
+	if !errors.As(err, &sdkBlocked) {
+		return fmt.Errorf("Gemini API %s GenerateContent 失敗: %w", task, err)
+	}
+	if sdkBlocked.PromptFeedback != nil {
+		reason := "PROMPT_BLOCKED_OTHER"
+		if sdkBlocked.PromptFeedback.BlockReason == genai.BlockReasonSafety {
+			reason = "PROMPT_BLOCKED_SAFETY"
+		}
+		return &BlockedError{Outcome: OutcomeSafety, FinishReason: reason, SafetyRatings: convertRatings(sdkBlocked.PromptFeedback.SafetyRatings)}
+	}
+	blocked := &BlockedError{Outcome: OutcomeSafety, FinishReason: "SAFETY"}
+	if c := sdkBlocked.Candidate; c != nil {
+		blocked.SafetyRatings = convertRatings(c.SafetyRatings)
+		if c.FinishReason == genai.FinishReasonRecitation {
+			blocked.Outcome, blocked.FinishReason = OutcomeRecitation, "RECITATION"
+		}
+	}
+	return blocked
+}
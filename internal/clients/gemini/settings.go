@@ -0,0 +1,204 @@
+package gemini
+
+import (
+	"AiHackathon-admin/internal/config"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// responseMIMEType 所有分析任務皆要求 JSON 回應
+const responseMIMEType = "application/json"
+
+var harmCategories = map[string]genai.HarmCategory{
+	"harassment":        genai.HarmCategoryHarassment,
+	"hate_speech":       genai.HarmCategoryHateSpeech,
+	"sexually_explicit": genai.HarmCategorySexuallyExplicit,
+	"dangerous_content": genai.HarmCategoryDangerousContent,
+}
+
+var harmThresholds = map[string]genai.HarmBlockThreshold{
+	"block_none":             genai.HarmBlockNone,
+	"block_only_high":        genai.HarmBlockOnlyHigh,
+	"block_medium_and_above": genai.HarmBlockMediumAndAbove,
+	"block_low_and_above":    genai.HarmBlockLowAndAbove,
+}
+
+// CallInfo 記錄一次分析實際使用的模型名稱、生成參數與 token 用量，與分析結果一併保存
+type CallInfo struct {
+	ModelName string
+	Params    json.RawMessage // 生成參數 (JSON)
+
+	// 來自回應的 UsageMetadata；回應被阻擋時僅有 prompt 的 token 數 (CountTokens)，其他失敗時為 0
+	PromptTokens    int32
+	CandidateTokens int32
+	TotalTokens     int32
+}
+
+// setUsage 自回應記錄 token 用量
+func (i *CallInfo) setUsage(resp *genai.GenerateContentResponse) {
+	if resp == nil || resp.UsageMetadata == nil {
+		return
+	}
+	i.PromptTokens = resp.UsageMetadata.PromptTokenCount
+	i.CandidateTokens = resp.UsageMetadata.CandidatesTokenCount
+	i.TotalTokens = resp.UsageMetadata.TotalTokenCount
+}
+
+// modelParams 為 CallInfo.Params 的內容
+type modelParams struct {
+	Temperature      *float32          `json:"temperature,omitempty"`
+	TopP             *float32          `json:"top_p,omitempty"`
+	TopK             *int32            `json:"top_k,omitempty"`
+	MaxOutputTokens  *int32            `json:"max_output_tokens,omitempty"`
+	SafetySettings   map[string]string `json:"safety_settings,omitempty"`
+	ResponseMIMEType string            `json:"response_mime_type"`
+}
+
+// ValidateSettings 檢查各分析任務的生成參數 (數值範圍與安全設定名稱) 是否有效
+func ValidateSettings(cfg config.GeminiClientConfig) error {
+	var errs []error
+	check := func(task string, gen config.GeminiGenerationConfig) {
+		if err := validateGeneration(gen); err != nil {
+			errs = append(errs, fmt.Errorf("geminiClient.%s: %w", task, err))
+		}
+	}
+	check("text", cfg.Text)
+	check("video", cfg.Video)
+	if _, _, err := parseSafetySettings(cfg.Fallback.RelaxedSafetySettings); err != nil {
+		errs = append(errs, fmt.Errorf("geminiClient.fallback.relaxedSafetySettings: %w", err))
+	}
+	if cfg.LongVideo.MinDurationSecs < 0 {
+		errs = append(errs, fmt.Errorf("geminiClient.longVideo.minDurationSecs 不得為負數"))
+	}
+	if cfg.LongVideo.MinDurationSecs > 0 {
+		if cfg.LongVideo.ModelName == "" {
+			errs = append(errs, fmt.Errorf("geminiClient.longVideo 已設定 minDurationSecs，但 modelName 未設定"))
+		}
+		check("longVideo.generation", cfg.LongVideo.Generation)
+	}
+	return errors.Join(errs...)
+}
+
+func validateGeneration(gen config.GeminiGenerationConfig) error {
+	var errs []error
+	if gen.Temperature != nil && (*gen.Temperature < 0 || *gen.Temperature > 2) {
+		errs = append(errs, fmt.Errorf("temperature 需介於 0 與 2 之間"))
+	}
+	if gen.TopP != nil && (*gen.TopP < 0 || *gen.TopP > 1) {
+		errs = append(errs, fmt.Errorf("topP 需介於 0 與 1 之間"))
+	}
+	if gen.TopK != nil && *gen.TopK <= 0 {
+		errs = append(errs, fmt.Errorf("topK 需大於 0"))
+	}
+	if gen.MaxOutputTokens != nil && *gen.MaxOutputTokens <= 0 {
+		errs = append(errs, fmt.Errorf("maxOutputTokens 需大於 0"))
+	}
+	if _, _, err := parseSafetySettings(gen.SafetySettings); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// mergeGeneration 以 override 中有設定的欄位覆蓋 base
+func mergeGeneration(base, override config.GeminiGenerationConfig) config.GeminiGenerationConfig {
+	merged := base
+	if override.Temperature != nil {
+		merged.Temperature = override.Temperature
+	}
+	if override.TopP != nil {
+		merged.TopP = override.TopP
+	}
+	if override.TopK != nil {
+		merged.TopK = override.TopK
+	}
+	if override.MaxOutputTokens != nil {
+		merged.MaxOutputTokens = override.MaxOutputTokens
+	}
+	if len(override.SafetySettings) > 0 {
+		merged.SafetySettings = override.SafetySettings
+	}
+	return merged
+}
+
+// parseSafetySettings 將設定檔的安全設定轉為 SDK 格式；名稱不分大小寫，類別可加 harm_category_ 前綴。
+// 另回傳正規化後的名稱對應，供記錄使用。
+func parseSafetySettings(m map[string]string) ([]*genai.SafetySetting, map[string]string, error) {
+	if len(m) == 0 {
+		return nil, nil, nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var settings []*genai.SafetySetting
+	normalized := make(map[string]string, len(m))
+	var errs []error
+	for _, k := range keys {
+		name := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(k)), "harm_category_")
+		category, ok := harmCategories[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("未知的安全設定類別 '%s'", k))
+			continue
+		}
+		level := strings.ToLower(strings.TrimSpace(m[k]))
+		threshold, ok := harmThresholds[level]
+		if !ok {
+			errs = append(errs, fmt.Errorf("安全設定類別 '%s' 的門檻 '%s' 無效", k, m[k]))
+			continue
+		}
+		settings = append(settings, &genai.SafetySetting{Category: category, Threshold: threshold})
+		normalized[name] = level
+	}
+	return settings, normalized, errors.Join(errs...)
+}
+
+// paramsWithSafety 回傳以 safety 取代安全設定後的生成參數 JSON
+func paramsWithSafety(params json.RawMessage, safety map[string]string) json.RawMessage {
+	var p modelParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return params
+	}
+	p.SafetySettings = safety
+	out, err := json.Marshal(p)
+	if err != nil {
+		return params
+	}
+	return out
+}
+
+// newModel 依模型名稱與生成參數建立模型，並回傳要記錄的 CallInfo
+func newModel(sdk *genai.Client, name string, gen config.GeminiGenerationConfig) (*genai.GenerativeModel, CallInfo, error) {
+	safety, normalized, err := parseSafetySettings(gen.SafetySettings)
+	if err != nil {
+		return nil, CallInfo{}, err
+	}
+	model := sdk.GenerativeModel(name)
+	model.GenerationConfig = genai.GenerationConfig{
+		Temperature:      gen.Temperature,
+		TopP:             gen.TopP,
+		TopK:             gen.TopK,
+		MaxOutputTokens:  gen.MaxOutputTokens,
+		ResponseMIMEType: responseMIMEType,
+	}
+	model.SafetySettings = safety
+
+	params, err := json.Marshal(modelParams{
+		Temperature:      gen.Temperature,
+		TopP:             gen.TopP,
+		TopK:             gen.TopK,
+		MaxOutputTokens:  gen.MaxOutputTokens,
+		SafetySettings:   normalized,
+		ResponseMIMEType: responseMIMEType,
+	})
+	if err != nil {
+		return nil, CallInfo{}, fmt.Errorf("序列化模型 '%s' 的生成參數失敗: %w", name, err)
+	}
+	return model, CallInfo{ModelName: name, Params: params}, nil
+}
@@ -31,6 +31,12 @@ type SchedulerConfig struct {
 	Enabled         bool   `mapstructure:"enabled"`
 	FetchCronSpec   string `mapstructure:"fetchCronSpec"`
 	AnalyzeCronSpec string `mapstructure:"analyzeCronSpec"`
+	// RetentionCronSpec NAS 保留政策清理任務的排程；空字串代表不排程
+	RetentionCronSpec string `mapstructure:"retentionCronSpec"`
+	// ThumbnailCronSpec 產生影片海報與關鍵影格縮圖任務的排程；空字串代表不排程
+	ThumbnailCronSpec string `mapstructure:"thumbnailCronSpec"`
+	// WebProxyCronSpec 為瀏覽器無法播放的影片產生 H.264/AAC 播放版本任務的排程；空字串 (預設) 代表不排程
+	WebProxyCronSpec string `mapstructure:"webProxyCronSpec"`
 }
 type Config struct {
 	AppName       string
@@ -42,6 +48,18 @@ type Config struct {
 	NAS           NASConfig
 	Prompts       PromptConfig
 	Scheduler     SchedulerConfig
+	Export        ExportConfig
+	Feeds         FeedsConfig
+	Webhooks      WebhooksConfig
+	Alerts        AlertsConfig
+	Auth          AuthConfig
+	Costs         CostsConfig
+	Segmentation  SegmentationConfig
+	Retention     RetentionConfig
+	Thumbnails    ThumbnailsConfig
+	MediaProbe    MediaProbeConfig
+	WebProxy      WebProxyConfig
+	MediaSigning  MediaSigningConfig
 }
 type APClientConfig struct {
 	APIKey  string `mapstructure:"apiKey"`
@@ -59,10 +77,121 @@ type YouTubeClientConfig struct {
 	BaseURL string `mapstructure:"baseURL"`
 }
 type GeminiClientConfig struct {
-	APIKey         string `mapstructure:"apiKey"`
-	TextModelName  string `mapstructure:"textModelName"`
-	VideoModelName string `mapstructure:"videoModelName"`
+	APIKey         string                 `mapstructure:"apiKey"`
+	TextModelName  string                 `mapstructure:"textModelName"`
+	VideoModelName string                 `mapstructure:"videoModelName"`
+	Text           GeminiGenerationConfig `mapstructure:"text"`      // 文本元數據分析的生成參數
+	Video          GeminiGenerationConfig `mapstructure:"video"`     // 影片內容分析的生成參數
+	LongVideo      GeminiLongVideoConfig  `mapstructure:"longVideo"` // 長影片改用其他模型
+	Fallback       GeminiFallbackConfig   `mapstructure:"fallback"`  // 影片分析回應被阻擋或截斷時的備援
 }
+
+// GeminiFallbackConfig 影片內容分析因安全設定 (SAFETY)、引用 (RECITATION) 或輸出上限 (MAX_TOKENS) 未完成時的備援分析
+type GeminiFallbackConfig struct {
+	// RelaxedSafetySettings 因安全設定被阻擋時，以此安全設定 (格式同 safetySettings) 重試一次；未設定時不重試
+	RelaxedSafetySettings map[string]string `mapstructure:"relaxedSafetySettings"`
+	// TextOnly 仍無法取得結果時，改以標題、SHOTLIST 等文字元數據進行純文字分析 (預設啟用)
+	TextOnly bool `mapstructure:"textOnly"`
+}
+
+// GeminiGenerationConfig 單一分析任務的生成參數；未設定 (nil) 的欄位使用模型預設值
+type GeminiGenerationConfig struct {
+	Temperature     *float32 `mapstructure:"temperature"`
+	TopP            *float32 `mapstructure:"topP"`
+	TopK            *int32   `mapstructure:"topK"`
+	MaxOutputTokens *int32   `mapstructure:"maxOutputTokens"`
+	// SafetySettings 安全類別對應的封鎖門檻，例如 dangerous_content: block_only_high。
+	// 類別：harassment、hate_speech、sexually_explicit、dangerous_content (可加 harm_category_ 前綴)；
+	// 門檻：block_none、block_only_high、block_medium_and_above、block_low_and_above
+	SafetySettings map[string]string `mapstructure:"safetySettings"`
+}
+
+// GeminiLongVideoConfig 影片長度 (videos.duration_secs) 達 MinDurationSecs 秒時改用 ModelName 分析。
+// MinDurationSecs 為 0 時停用；長度未知的影片一律使用 videoModelName。
+type GeminiLongVideoConfig struct {
+	MinDurationSecs int64                  `mapstructure:"minDurationSecs"`
+	ModelName       string                 `mapstructure:"modelName"`
+	Generation      GeminiGenerationConfig `mapstructure:"generation"` // 未設定的欄位沿用 video 的生成參數
+}
+
+// SegmentationConfig 長影片分段分析：超過 MinDurationSecs 的影片切成 SegmentSecs 秒的片段分別分析後合併
+type SegmentationConfig struct {
+	MinDurationSecs int64  `mapstructure:"minDurationSecs"` // 0 表示不分段
+	SegmentSecs     int64  `mapstructure:"segmentSecs"`     // 每段長度 (預設 600 秒)
+	FFmpegPath      string `mapstructure:"ffmpegPath"`      // 未設定時由 PATH 尋找；找不到 ffmpeg 時改以 prompt 指定分析的時間範圍
+	TempDir         string `mapstructure:"tempDir"`         // 切割片段的暫存目錄，預設為系統暫存目錄
+}
+
+// RetentionConfig NAS 儲存保留政策：依評級與下載時間刪除影片版本並將影片標記為 archived。
+// TXT 檔案永久保留，編輯釘選的影片不受影響。排程由 scheduler.retentionCronSpec 設定
+type RetentionConfig struct {
+	DryRun bool            `mapstructure:"dryRun"` // 排程執行時只記錄將刪除的檔案，不實際刪除
+	Rules  []RetentionRule `mapstructure:"rules"`  // 依序比對，檔案以第一個符合的規則為刪除原因
+}
+
+// RetentionRule 單一保留規則，例如 N 級影片的 master 保留 7 天
+type RetentionRule struct {
+	Name      string   `mapstructure:"name"`
+	Ratings   []string `mapstructure:"ratings"`   // 適用的評級 (S/A/B/C/N)；空白代表所有評級 (含未評級)
+	Roles     []string `mapstructure:"roles"`     // 刪除的版本：master、proxy、audio；空白代表所有版本
+	AfterDays int      `mapstructure:"afterDays"` // 下載 (fetched_at) 超過此天數後刪除
+}
+
+// ThumbnailsConfig 以 ffmpeg 產生影片海報 (poster) 與關鍵影格縮圖表 (contact sheet)，存放於影片旁。
+// ffmpeg 路徑沿用 segmentation.ffmpegPath；找不到 ffmpeg 時略過。排程由 scheduler.thumbnailCronSpec 設定
+type ThumbnailsConfig struct {
+	BatchSize   int `mapstructure:"batchSize"`   // 每次排程處理的影片數 (預設 20)
+	PosterWidth int `mapstructure:"posterWidth"` // 海報寬度 (像素，預設 640)
+	Columns     int `mapstructure:"columns"`     // 縮圖表欄數 (預設 4)
+	Rows        int `mapstructure:"rows"`        // 縮圖表列數 (預設 4)
+	TileWidth   int `mapstructure:"tileWidth"`   // 縮圖表每格寬度 (像素，預設 320)
+	TimeoutSecs int `mapstructure:"timeoutSecs"` // 單部影片的 ffmpeg 執行時間上限 (預設 120)
+}
+
+// WebProxyConfig 為瀏覽器無法播放的格式 (.ts、.mkv 等) 以 ffmpeg 產生 H.264/AAC MP4 播放版本，
+// 存放於影片旁的 .web/ 目錄；/media/ 自動改用播放版本，加上 ?original=1 時下載原始檔。
+// ffmpeg 路徑沿用 segmentation.ffmpegPath；排程由 scheduler.webProxyCronSpec 設定
+type WebProxyConfig struct {
+	Extensions       []string `mapstructure:"extensions"`       // 需要轉檔的副檔名 (預設 .ts、.mkv、.avi、.wmv、.flv)
+	BatchSize        int      `mapstructure:"batchSize"`        // 每次排程轉檔的影片數 (預設 5)
+	MaxHeight        int      `mapstructure:"maxHeight"`        // 輸出高度上限 (像素，預設 720)
+	CRF              int      `mapstructure:"crf"`              // libx264 畫質 (預設 23)
+	Preset           string   `mapstructure:"preset"`           // libx264 編碼速度 (預設 veryfast)
+	AudioBitrateKbps int      `mapstructure:"audioBitrateKbps"` // AAC 位元率 (預設 128)
+	TimeoutMins      int      `mapstructure:"timeoutMins"`      // 單部影片的轉檔時間上限 (預設 60)
+}
+
+// MediaSigningConfig /media/ 網址的 HMAC 簽章與到期時間。設定 secret 後儀表板、API 與靜態匯出產生的影片網址皆附帶簽章，
+// 持有有效簽章者不需登入即可存取；require 決定沒有簽章的請求 (即使已登入) 是否被拒絕。變更需重新啟動
+type MediaSigningConfig struct {
+	Secret         string `mapstructure:"secret"`         // HMAC 金鑰 (至少 32 字元)；未設定時不簽章
+	Require        string `mapstructure:"require"`        // none (預設)、restricted (有 Restrictions 的影片需簽章) 或 all
+	TTLMinutes     int    `mapstructure:"ttlMinutes"`     // 儀表板與 API 網址的有效分鐘數 (預設 240)
+	ExportTTLHours int    `mapstructure:"exportTTLHours"` // 靜態匯出與 NewsML 網址的有效時數 (預設 168)
+}
+
+// MediaProbeConfig 文本元數據分析時由影片檔案取得實際長度、解析度、編碼等技術資訊。
+// 有 ffprobe 時使用 ffprobe，否則 .mp4/.mov 以內建的 box 解析器取得
+type MediaProbeConfig struct {
+	FFprobePath           string `mapstructure:"ffprobePath"`           // 未設定時由 PATH 尋找
+	TimeoutSecs           int    `mapstructure:"timeoutSecs"`           // 單一檔案的解析時間上限 (預設 30)
+	DurationToleranceSecs int64  `mapstructure:"durationToleranceSecs"` // TXT 記載的長度與實際長度差異超過此秒數時標示為不符 (預設 5)
+}
+
+// CostsConfig Gemini 費用計算與每月預算
+type CostsConfig struct {
+	Currency      string       `mapstructure:"currency"`      // 報表顯示的幣別 (預設 USD)
+	MonthlyBudget float64      `mapstructure:"monthlyBudget"` // 每月預算；0 表示不限制。超過時排程器暫停影片分析任務
+	Prices        []ModelPrice `mapstructure:"prices"`
+}
+
+// ModelPrice 模型每百萬 token 的價格。以清單設定，因為 viper 會將含 "." 的模型名稱 (如 gemini-2.5-flash) 拆成巢狀鍵
+type ModelPrice struct {
+	Model            string  `mapstructure:"model"`
+	InputPerMillion  float64 `mapstructure:"inputPerMillion"`  // prompt token
+	OutputPerMillion float64 `mapstructure:"outputPerMillion"` // 回應 token (含思考 token)
+}
+
 type DatabaseConfig struct {
 	Driver   string `mapstructure:"driver"`
 	Host     string `mapstructure:"host"`
@@ -72,7 +201,118 @@ type DatabaseConfig struct {
 	DBName   string `mapstructure:"dbName"`
 }
 type NASConfig struct {
-	VideoPath string `mapstructure:"videoPath"`
+	VideoPath     string              `mapstructure:"videoPath"`     // backend 為 s3 時作為本地快取目錄 (分析時下載的檔案)，目錄結構與 bucket 相同
+	Backend       string              `mapstructure:"backend"`       // filesystem (預設) 或 s3
+	FullScanHours int                 `mapstructure:"fullScanHours"` // 增量掃描只檢查修改時間有變化的目錄，每隔此時數全量比對一次以涵蓋原地覆寫的檔案 (預設 24)
+	MinFreeMB     int                 `mapstructure:"minFreeMB"`     // 寫入影片 (含物件儲存快取) 後需保留的磁碟剩餘空間 (預設 1024)
+	Watch         NASWatchConfig      `mapstructure:"watch"`
+	Renditions    NASRenditionsConfig `mapstructure:"renditions"`
+	S3            NASS3Config         `mapstructure:"s3"`
+}
+
+// NASS3Config S3 相容物件儲存 (MinIO 等) 設定；物件鍵為 prefix + <source>/<id>/<檔名>，與 videoPath 下的目錄結構相同
+type NASS3Config struct {
+	Endpoint        string `mapstructure:"endpoint"` // 例如 https://minio.example.com:9000
+	Region          string `mapstructure:"region"`   // 預設 us-east-1
+	Bucket          string `mapstructure:"bucket"`
+	Prefix          string `mapstructure:"prefix"` // 物件鍵前綴，例如 Download/
+	AccessKeyID     string `mapstructure:"accessKeyID"`
+	SecretAccessKey string `mapstructure:"secretAccessKey"` // 建議以環境變數 NAS_S3_SECRETACCESSKEY 提供
+	PathStyle       bool   `mapstructure:"pathStyle"`       // 使用 endpoint/bucket/key 形式的網址 (MinIO 需要，預設 true)
+	PartSizeMB      int    `mapstructure:"partSizeMB"`      // 超過此大小的檔案以 multipart 上傳 (預設 16，最小 5)
+	StreamMode      string `mapstructure:"streamMode"`      // /media/ 串流方式：proxy 由伺服器轉送 Range 請求、presign 重新導向至預先簽署網址 (預設 proxy)
+	PresignMinutes  int    `mapstructure:"presignMinutes"`  // 預先簽署網址的有效分鐘數 (預設 15)
+}
+
+// NASRenditionsConfig 影片資料夾中有多個版本 (master/proxy/audio) 或多個 TXT 檔案時的選擇規則
+type NASRenditionsConfig struct {
+	ProxyPatterns     []string `mapstructure:"proxyPatterns"`     // 檔名包含任一字串 (不分大小寫) 時視為 proxy (預設 proxy, lowres, preview, _lr)
+	MinAnalysisHeight int      `mapstructure:"minAnalysisHeight"` // 送交分析時使用達此高度的最小版本，解析度未知視為可接受 (預設 360)
+	PreferStream      string   `mapstructure:"preferStream"`      // 儀表板播放優先使用的版本：proxy 或 master (預設 proxy)
+	TextMode          string   `mapstructure:"textMode"`          // 多個 TXT 時：concat 依檔名合併、first 使用第一個、largest 使用最長者 (預設 concat)
+}
+
+// NASWatchConfig 監看 VideoPath，新下載的影片/TXT 配對在檔案大小穩定後立即進行文本元數據分析
+type NASWatchConfig struct {
+	Enabled       bool `mapstructure:"enabled"`
+	SettleSecs    int  `mapstructure:"settleSecs"`    // 檔案大小與修改時間需維持不變的秒數，避免處理仍在下載的檔案 (預設 15)
+	RescanMinutes int  `mapstructure:"rescanMinutes"` // 定期全量掃描的間隔，補足監看遺漏的事件 (預設 30)
+}
+
+// ExportConfig 匯出 (NewsML-G2 等) 相關設定
+type ExportConfig struct {
+	NewsMLGUIDPrefix string `mapstructure:"newsMLGUIDPrefix"` // newsItem guid 前綴
+	PublicBaseURL    string `mapstructure:"publicBaseURL"`    // 對外可存取的網址，例如 https://admin.example.com
+}
+
+// FeedsConfig RSS/Atom 訂閱源設定
+type FeedsConfig struct {
+	MaxItems      int               `mapstructure:"maxItems"`      // 每個訂閱源最多輸出的項目數
+	SavedSearches map[string]string `mapstructure:"savedSearches"` // 具名的儲存搜尋，/feeds/search-{名稱}.atom
+}
+
+// WebhooksConfig 分析完成時對外發送 webhook 的設定
+type WebhooksConfig struct {
+	MaxAttempts        int                         `mapstructure:"maxAttempts"`        // 每筆傳送最多嘗試次數
+	InitialBackoffSecs int                         `mapstructure:"initialBackoffSecs"` // 第一次重試前等待秒數，之後每次加倍
+	TimeoutSecs        int                         `mapstructure:"timeoutSecs"`        // 單次 HTTP 請求逾時秒數
+	Subscriptions      []WebhookSubscriptionConfig `mapstructure:"subscriptions"`
+}
+
+// WebhookSubscriptionConfig 單一 webhook 訂閱；篩選條件為空代表不篩選
+type WebhookSubscriptionConfig struct {
+	Name    string   `mapstructure:"name"`
+	URL     string   `mapstructure:"url"`
+	Secret  string   `mapstructure:"secret"` // 用於 HMAC-SHA256 簽章
+	Enabled bool     `mapstructure:"enabled"`
+	Ratings []string `mapstructure:"ratings"` // 例如 ["S", "A"]
+	Sources []string `mapstructure:"sources"` // 例如 ["ap", "reuters"]
+	Topics  []string `mapstructure:"topics"`  // 比對分析結果的 topics 與影片的 subjects
+}
+
+// AlertsConfig 快訊規則通知設定 (規則本身儲存在資料庫)
+type AlertsConfig struct {
+	SMTP            SMTPConfig `mapstructure:"smtp"`
+	ChatTimeoutSecs int        `mapstructure:"chatTimeoutSecs"` // 聊天室 webhook 請求逾時秒數
+}
+
+// SMTPConfig 寄送 email 快訊的 SMTP 伺服器設定；本機測試可使用 MailHog 等 SMTP 替身 (預設 localhost:1025)
+type SMTPConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"` // 為空時不進行 SMTP 認證
+	Password string `mapstructure:"password"`
+	From     string `mapstructure:"from"`
+}
+
+// AuthConfig 網頁介面登入與權限設定
+type AuthConfig struct {
+	Enabled          bool           `mapstructure:"enabled"`          // 關閉時所有請求皆視為管理員 (僅供本機開發)
+	SessionTTLHours  int            `mapstructure:"sessionTTLHours"`  // 登入 session 有效時數
+	CookieSecure     bool           `mapstructure:"cookieSecure"`     // 透過 HTTPS 提供服務時應設為 true
+	BootstrapAdmin   BootstrapAdmin `mapstructure:"bootstrapAdmin"`   // 資料庫尚無任何使用者時自動建立的管理員
+	BasicAuthForGets bool           `mapstructure:"basicAuthForGets"` // 允許 GET 請求以 HTTP Basic 認證 (供 Atom 閱讀器等工具使用)
+	TrustedProxies   []string       `mapstructure:"trustedProxies"`   // 反向代理的 IP 或 CIDR；只有直接連線來源在此清單中時才採用 X-Forwarded-For
+	OIDC             OIDCConfig     `mapstructure:"oidc"`
+}
+
+// BootstrapAdmin 初始管理員帳號
+type BootstrapAdmin struct {
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+}
+
+// OIDCConfig OpenID Connect 單一登入設定 (選用)
+type OIDCConfig struct {
+	Enabled      bool              `mapstructure:"enabled"`
+	IssuerURL    string            `mapstructure:"issuerURL"` // 例如 https://accounts.google.com
+	ClientID     string            `mapstructure:"clientID"`
+	ClientSecret string            `mapstructure:"clientSecret"`
+	RedirectURL  string            `mapstructure:"redirectURL"` // 例如 https://admin.example.com/auth/oidc/callback
+	Scopes       []string          `mapstructure:"scopes"`
+	DefaultRole  string            `mapstructure:"defaultRole"` // 首次登入時給予的角色；為空時只允許符合 groupRoles 的使用者
+	GroupsClaim  string            `mapstructure:"groupsClaim"` // userinfo 中群組清單的欄位名稱
+	GroupRoles   map[string]string `mapstructure:"groupRoles"`  // 群組名稱 -> 角色，取最高者
 }
 
 // Load 函式 (調整 Prompt 的預設值邏輯)
@@ -90,6 +330,41 @@ func Load(configPath string, configName string) (*Config, error) {
 	v.SetDefault("database.host", "127.0.0.1")
 	v.SetDefault("geminiClient.textModelName", "gemini-1.5-flash-latest")
 	v.SetDefault("geminiClient.videoModelName", "gemini-1.5-flash-latest")
+	v.SetDefault("geminiClient.fallback.textOnly", true)
+	v.SetDefault("costs.currency", "USD")
+	v.SetDefault("segmentation.segmentSecs", 600)
+	v.SetDefault("mediaProbe.timeoutSecs", 30)
+	v.SetDefault("mediaProbe.durationToleranceSecs", 5)
+	v.SetDefault("thumbnails.batchSize", 20)
+	v.SetDefault("thumbnails.posterWidth", 640)
+	v.SetDefault("thumbnails.columns", 4)
+	v.SetDefault("thumbnails.rows", 4)
+	v.SetDefault("thumbnails.tileWidth", 320)
+	v.SetDefault("thumbnails.timeoutSecs", 120)
+	v.SetDefault("webProxy.extensions", []string{".ts", ".mkv", ".avi", ".wmv", ".flv"})
+	v.SetDefault("webProxy.batchSize", 5)
+	v.SetDefault("webProxy.maxHeight", 720)
+	v.SetDefault("webProxy.crf", 23)
+	v.SetDefault("webProxy.preset", "veryfast")
+	v.SetDefault("webProxy.audioBitrateKbps", 128)
+	v.SetDefault("webProxy.timeoutMins", 60)
+	v.SetDefault("mediaSigning.require", "none")
+	v.SetDefault("mediaSigning.ttlMinutes", 240)
+	v.SetDefault("mediaSigning.exportTTLHours", 168)
+	v.SetDefault("nas.fullScanHours", 24)
+	v.SetDefault("nas.backend", "filesystem")
+	v.SetDefault("nas.minFreeMB", 1024)
+	v.SetDefault("nas.s3.region", "us-east-1")
+	v.SetDefault("nas.s3.pathStyle", true)
+	v.SetDefault("nas.s3.partSizeMB", 16)
+	v.SetDefault("nas.s3.streamMode", "proxy")
+	v.SetDefault("nas.s3.presignMinutes", 15)
+	v.SetDefault("nas.renditions.proxyPatterns", []string{"proxy", "lowres", "preview", "_lr"})
+	v.SetDefault("nas.renditions.minAnalysisHeight", 360)
+	v.SetDefault("nas.renditions.preferStream", "proxy")
+	v.SetDefault("nas.renditions.textMode", "concat")
+	v.SetDefault("nas.watch.settleSecs", 15)
+	v.SetDefault("nas.watch.rescanMinutes", 30)
 
 	// 對於 Prompt 路徑，可以設定預設的 currentVersion，但 versions 的路徑如果不存在，
 	// 應該由服務層在讀取檔案時處理。
@@ -99,9 +374,26 @@ func Load(configPath string, configName string) (*Config, error) {
 	v.SetDefault("prompts.videoAnalysis.currentVersion", "default-v-not-found") // 一個標示性的預設版本
 	v.SetDefault("prompts.textFileAnalysis.currentVersion", "default-t-not-found")
 
+	v.SetDefault("export.newsMLGUIDPrefix", "urn:newsml:aihackathon-admin")
+	v.SetDefault("feeds.maxItems", 50)
+	v.SetDefault("webhooks.maxAttempts", 5)
+	v.SetDefault("webhooks.initialBackoffSecs", 30)
+	v.SetDefault("webhooks.timeoutSecs", 10)
+	v.SetDefault("alerts.smtp.host", "localhost")
+	v.SetDefault("alerts.smtp.port", 1025)
+	v.SetDefault("alerts.smtp.from", "alerts@aihackathon-admin.local")
+	v.SetDefault("alerts.chatTimeoutSecs", 10)
+	v.SetDefault("auth.enabled", true)
+	v.SetDefault("auth.sessionTTLHours", 12)
+	v.SetDefault("auth.basicAuthForGets", true)
+	v.SetDefault("auth.trustedProxies", []string{})
+	v.SetDefault("auth.oidc.scopes", []string{"openid", "profile", "email"})
+	v.SetDefault("auth.oidc.groupsClaim", "groups")
+
 	v.SetDefault("scheduler.enabled", true)
 	v.SetDefault("scheduler.fetchCronSpec", "0 0 * * * *")
 	v.SetDefault("scheduler.analyzeCronSpec", "0 */10 * * * *")
+	v.SetDefault("scheduler.thumbnailCronSpec", "0 5,35 * * * *")
 
 	if err := v.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
@@ -129,6 +421,12 @@ func Load(configPath string, configName string) (*Config, error) {
 	} else if _, ok := cfg.Prompts.TextFileAnalysis.Versions[cfg.Prompts.TextFileAnalysis.CurrentVersion]; !ok {
 		log.Printf("警告：[Config] TextFileAnalysis currentVersion '%s' 在 versions 中未找到對應的 Prompt 路徑！", cfg.Prompts.TextFileAnalysis.CurrentVersion)
 	}
+	if !cfg.Auth.Enabled {
+		log.Println("警告：[Config] auth.enabled 為 false，網頁介面未啟用登入驗證，所有請求皆具管理員權限！")
+	}
+	if cfg.Auth.OIDC.Enabled && (cfg.Auth.OIDC.IssuerURL == "" || cfg.Auth.OIDC.ClientID == "" || cfg.Auth.OIDC.RedirectURL == "") {
+		log.Println("警告：[Config] 已啟用 OIDC 但 issuerURL、clientID 或 redirectURL 未設定，OIDC 登入將無法使用。")
+	}
 
 	fmt.Println("資訊：設定載入成功。")
 	return &cfg, nil
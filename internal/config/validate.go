@@ -0,0 +1,252 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/robfig/cron/v3"
+)
+
+// cronParser 與排程器 (cron.WithSeconds) 使用相同的六欄位格式
+var cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// validateCronSpec 檢查排程 Cron 表達式 (含秒欄位) 是否可解析
+func validateCronSpec(spec string) error {
+	if _, err := cronParser.Parse(spec); err != nil {
+		return fmt.Errorf("無效的 Cron 表達式 '%s': %w", spec, err)
+	}
+	return nil
+}
+
+// Validate 嚴格檢查設定內容：必要欄位、排程表達式、prompt 檔案與 NAS 路徑。
+// 回傳所有發現的問題 (以 errors.Join 合併)，nil 代表通過。資料庫連線需另行檢查。
+func Validate(cfg *Config) error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if cfg.GeminiClient.APIKey == "" {
+		add("geminiClient.apiKey 未設定")
+	}
+	if cfg.Database.Host == "" || cfg.Database.User == "" || cfg.Database.DBName == "" {
+		add("database.host、database.user 與 database.dbName 皆需設定")
+	}
+
+	if cfg.Scheduler.Enabled {
+		for key, spec := range map[string]string{"scheduler.fetchCronSpec": cfg.Scheduler.FetchCronSpec, "scheduler.analyzeCronSpec": cfg.Scheduler.AnalyzeCronSpec, "scheduler.retentionCronSpec": cfg.Scheduler.RetentionCronSpec, "scheduler.thumbnailCronSpec": cfg.Scheduler.ThumbnailCronSpec, "scheduler.webProxyCronSpec": cfg.Scheduler.WebProxyCronSpec} {
+			if spec == "" {
+				continue
+			}
+			if err := validateCronSpec(spec); err != nil {
+				add("%s: %v", key, err)
+			}
+		}
+	}
+
+	if err := checkWritableDir(cfg.NAS.VideoPath); err != nil {
+		add("nas.videoPath: %v", err)
+	}
+
+	errs = append(errs, validatePrompts("prompts.videoAnalysis", cfg.Prompts.VideoAnalysis.CurrentVersion, cfg.Prompts.VideoAnalysis.Versions)...)
+	errs = append(errs, validatePrompts("prompts.textFileAnalysis", cfg.Prompts.TextFileAnalysis.CurrentVersion, cfg.Prompts.TextFileAnalysis.Versions)...)
+
+	for i, sub := range cfg.Webhooks.Subscriptions {
+		if sub.Enabled && !strings.HasPrefix(sub.URL, "http://") && !strings.HasPrefix(sub.URL, "https://") {
+			add("webhooks.subscriptions[%d] (%s): url 需以 http:// 或 https:// 開頭", i, sub.Name)
+		}
+	}
+	if cfg.Auth.OIDC.Enabled && (cfg.Auth.OIDC.IssuerURL == "" || cfg.Auth.OIDC.ClientID == "" || cfg.Auth.OIDC.RedirectURL == "") {
+		add("auth.oidc 已啟用，但 issuerURL、clientID 或 redirectURL 未設定")
+	}
+	for i, entry := range cfg.Auth.TrustedProxies {
+		if _, _, err := net.ParseCIDR(entry); err != nil && net.ParseIP(entry) == nil {
+			add("auth.trustedProxies[%d]: 無效的 IP 或 CIDR '%s'", i, entry)
+		}
+	}
+	if cfg.Costs.MonthlyBudget < 0 {
+		add("costs.monthlyBudget 不得為負數")
+	}
+	seenPrices := make(map[string]bool)
+	for i, p := range cfg.Costs.Prices {
+		model := strings.ToLower(strings.TrimSpace(p.Model))
+		switch {
+		case model == "":
+			add("costs.prices[%d]: model 未設定", i)
+		case seenPrices[model]:
+			add("costs.prices[%d]: 模型 '%s' 的價格重複設定", i, p.Model)
+		case p.InputPerMillion < 0 || p.OutputPerMillion < 0:
+			add("costs.prices[%d] (%s): 價格不得為負數", i, p.Model)
+		}
+		seenPrices[model] = true
+	}
+	if cfg.NAS.FullScanHours <= 0 {
+		add("nas.fullScanHours 需大於 0")
+	}
+	if cfg.NAS.MinFreeMB < 0 {
+		add("nas.minFreeMB 不得為負數")
+	}
+	for i, rule := range cfg.Retention.Rules {
+		if rule.AfterDays <= 0 {
+			add("retention.rules[%d] (%s): afterDays 需大於 0", i, rule.Name)
+		}
+		for _, role := range rule.Roles {
+			switch role {
+			case "master", "proxy", "audio":
+			default:
+				add("retention.rules[%d] (%s): 無效的版本 '%s' (需為 master、proxy 或 audio；TXT 永久保留)", i, rule.Name, role)
+			}
+		}
+	}
+	if cfg.NAS.Renditions.MinAnalysisHeight < 0 {
+		add("nas.renditions.minAnalysisHeight 不得為負數")
+	}
+	switch cfg.NAS.Renditions.PreferStream {
+	case "proxy", "master":
+	default:
+		add("nas.renditions.preferStream 需為 proxy 或 master (目前為 '%s')", cfg.NAS.Renditions.PreferStream)
+	}
+	switch cfg.NAS.Renditions.TextMode {
+	case "concat", "first", "largest":
+	default:
+		add("nas.renditions.textMode 需為 concat、first 或 largest (目前為 '%s')", cfg.NAS.Renditions.TextMode)
+	}
+	if w := cfg.NAS.Watch; w.Enabled && (w.SettleSecs <= 0 || w.RescanMinutes <= 0) {
+		add("nas.watch.settleSecs 與 nas.watch.rescanMinutes 需大於 0")
+	}
+	switch cfg.NAS.Backend {
+	case "filesystem":
+	case "s3":
+		s3 := cfg.NAS.S3
+		if !strings.HasPrefix(s3.Endpoint, "http://") && !strings.HasPrefix(s3.Endpoint, "https://") {
+			add("nas.s3.endpoint 需以 http:// 或 https:// 開頭")
+		}
+		if s3.Bucket == "" || s3.AccessKeyID == "" || s3.SecretAccessKey == "" {
+			add("nas.s3.bucket、accessKeyID 與 secretAccessKey 不得為空")
+		}
+		if s3.PartSizeMB < 5 {
+			add("nas.s3.partSizeMB 需至少為 5 (S3 multipart 上傳的最小分段大小)")
+		}
+		switch s3.StreamMode {
+		case "proxy", "presign":
+		default:
+			add("nas.s3.streamMode 需為 proxy 或 presign (目前為 '%s')", s3.StreamMode)
+		}
+		if s3.PresignMinutes <= 0 {
+			add("nas.s3.presignMinutes 需大於 0")
+		}
+		if cfg.NAS.Watch.Enabled {
+			add("nas.watch 僅支援 filesystem 儲存 (s3 請改用排程掃描)")
+		}
+	default:
+		add("nas.backend 需為 filesystem 或 s3 (目前為 '%s')", cfg.NAS.Backend)
+	}
+	if seg := cfg.Segmentation; seg.MinDurationSecs < 0 {
+		add("segmentation.minDurationSecs 不得為負數")
+	} else if seg.MinDurationSecs > 0 {
+		if seg.SegmentSecs <= 0 {
+			add("segmentation.segmentSecs 需大於 0")
+		} else if seg.SegmentSecs >= seg.MinDurationSecs {
+			add("segmentation.segmentSecs (%d) 需小於 minDurationSecs (%d)", seg.SegmentSecs, seg.MinDurationSecs)
+		}
+		if seg.TempDir != "" {
+			if err := checkWritableDir(seg.TempDir); err != nil {
+				add("segmentation.tempDir: %v", err)
+			}
+		}
+	}
+	if cfg.MediaProbe.TimeoutSecs <= 0 {
+		add("mediaProbe.timeoutSecs 需大於 0")
+	}
+	if cfg.MediaProbe.DurationToleranceSecs < 0 {
+		add("mediaProbe.durationToleranceSecs 不得為負數")
+	}
+	if th := cfg.Thumbnails; th.BatchSize <= 0 || th.PosterWidth <= 0 || th.TileWidth <= 0 || th.TimeoutSecs <= 0 {
+		add("thumbnails.batchSize、posterWidth、tileWidth 與 timeoutSecs 皆需大於 0")
+	}
+	if th := cfg.Thumbnails; th.Columns < 1 || th.Columns > 10 || th.Rows < 1 || th.Rows > 10 {
+		add("thumbnails.columns 與 thumbnails.rows 需介於 1 到 10 (目前為 %d x %d)", cfg.Thumbnails.Columns, cfg.Thumbnails.Rows)
+	}
+	if wp := cfg.WebProxy; wp.BatchSize <= 0 || wp.MaxHeight <= 0 || wp.AudioBitrateKbps <= 0 || wp.TimeoutMins <= 0 {
+		add("webProxy.batchSize、maxHeight、audioBitrateKbps 與 timeoutMins 皆需大於 0")
+	}
+	if cfg.WebProxy.CRF < 0 || cfg.WebProxy.CRF > 51 {
+		add("webProxy.crf 需介於 0 到 51 (目前為 %d)", cfg.WebProxy.CRF)
+	}
+	if cfg.WebProxy.Preset == "" {
+		add("webProxy.preset 不得為空")
+	}
+	for i, ext := range cfg.WebProxy.Extensions {
+		if !strings.HasPrefix(ext, ".") {
+			add("webProxy.extensions[%d]: 副檔名需以 . 開頭 (目前為 '%s')", i, ext)
+		}
+	}
+	ms := cfg.MediaSigning
+	switch ms.Require {
+	case "none", "restricted", "all":
+		if ms.Require != "none" && ms.Secret == "" {
+			add("mediaSigning.require 為 %s 時需設定 mediaSigning.secret", ms.Require)
+		}
+	default:
+		add("mediaSigning.require 需為 none、restricted 或 all (目前為 '%s')", ms.Require)
+	}
+	if ms.Secret != "" && len(ms.Secret) < 32 {
+		add("mediaSigning.secret 需至少 32 字元")
+	}
+	if ms.TTLMinutes <= 0 || ms.ExportTTLHours <= 0 {
+		add("mediaSigning.ttlMinutes 與 mediaSigning.exportTTLHours 需大於 0")
+	}
+	return errors.Join(errs...)
+}
+
+// validatePrompts 檢查 currentVersion 存在於 versions，且每個版本的檔案皆可讀取
+func validatePrompts(key, current string, versions map[string]string) []error {
+	var errs []error
+	if _, ok := versions[current]; !ok {
+		errs = append(errs, fmt.Errorf("%s.currentVersion '%s' 不在 versions 中", key, current))
+	}
+	names := make([]string, 0, len(versions))
+	for name := range versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := versions[name]
+		if path == "" {
+			errs = append(errs, fmt.Errorf("%s.versions.%s 的檔案路徑為空", key, name))
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s.versions.%s 無法讀取: %w", key, name, err))
+			continue
+		}
+		f.Close()
+	}
+	return errs
+}
+
+// checkWritableDir 確認路徑存在、為目錄且可寫入 (建立並刪除一個暫存檔)
+func checkWritableDir(path string) error {
+	if path == "" {
+		return fmt.Errorf("路徑未設定")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("'%s' 不是目錄", path)
+	}
+	f, err := os.CreateTemp(path, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("'%s' 無法寫入: %w", path, err)
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher 監看設定檔、prompt 檔案與範本所在的目錄
+type Watcher struct {
+	watcher *fsnotify.Watcher
+
+	mu   sync.Mutex
+	dirs map[string]bool // 監看中的目錄
+}
+
+// Watch 監看 paths (檔案或目錄；檔案會改為監看其所在目錄，以涵蓋編輯器以改名方式存檔的情況)，
+// 在最後一次變更後經過 debounce 仍無新變更時呼叫 onChange。ctx 結束時停止監看。
+// 重新載入後監看的路徑可能改變 (例如新增 prompt 版本)，可再以 SetPaths 更新
+func Watch(ctx context.Context, paths []string, debounce time.Duration, onChange func()) (*Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("建立檔案監看器失敗: %w", err)
+	}
+	w := &Watcher{watcher: watcher, dirs: make(map[string]bool)}
+	if w.SetPaths(paths) == 0 {
+		watcher.Close()
+		return nil, fmt.Errorf("沒有可監看的路徑")
+	}
+
+	go func() {
+		defer watcher.Close()
+		var timer *time.Timer
+		var fire <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Op == fsnotify.Chmod {
+					continue
+				}
+				if timer == nil {
+					timer = time.NewTimer(debounce)
+				} else {
+					if !timer.Stop() {
+						select {
+						case <-timer.C:
+						default:
+						}
+					}
+					timer.Reset(debounce)
+				}
+				fire = timer.C
+			case <-fire:
+				fire = nil
+				onChange()
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("警告：[Config] 檔案監看錯誤: %v", err)
+			}
+		}
+	}()
+	log.Printf("資訊：[Config] 正在監看 %d 個目錄的設定、prompt 與範本變更。", len(w.dirs))
+	return w, nil
+}
+
+// SetPaths 將監看的目錄更新為 paths 對應的目錄：開始監看新增的目錄，停止監看不再需要的目錄。
+// 回傳監看中的目錄數；w 為 nil (未啟用監看) 時不做任何事
+func (w *Watcher) SetPaths(paths []string) int {
+	if w == nil {
+		return 0
+	}
+	wanted := make(map[string]bool)
+	for _, p := range paths {
+		dir := p
+		if ext := filepath.Ext(p); ext != "" {
+			dir = filepath.Dir(p)
+		}
+		wanted[filepath.Clean(dir)] = true
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for dir := range w.dirs {
+		if !wanted[dir] {
+			_ = w.watcher.Remove(dir)
+			delete(w.dirs, dir)
+		}
+	}
+	for dir := range wanted {
+		if w.dirs[dir] {
+			continue
+		}
+		if err := w.watcher.Add(dir); err != nil {
+			log.Printf("警告：[Config] 無法監看 '%s': %v", dir, err)
+			continue
+		}
+		w.dirs[dir] = true
+	}
+	return len(w.dirs)
+}
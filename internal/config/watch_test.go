@@ -0,0 +1,58 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcherSetPaths(t *testing.T) {
+	base := t.TempDir()
+	oldDir, newDir := filepath.Join(base, "prompts_v1"), filepath.Join(base, "prompts_v2")
+	for _, dir := range []string{oldDir, newDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan struct{}, 10)
+	w, err := Watch(ctx, []string{filepath.Join(oldDir, "v1.txt")}, 20*time.Millisecond, func() { changes <- struct{}{} })
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectChange := func(dir string, want bool) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "v.txt"), []byte(time.Now().String()), 0644); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-changes:
+			if !want {
+				t.Errorf("'%s' 已不在監看範圍，不應觸發重新載入", dir)
+			}
+		case <-time.After(300 * time.Millisecond):
+			if want {
+				t.Errorf("'%s' 的變更未觸發重新載入", dir)
+			}
+		}
+	}
+
+	expectChange(newDir, false)
+	expectChange(oldDir, true)
+
+	// 重新載入後 prompt 移至新目錄
+	if n := w.SetPaths([]string{filepath.Join(newDir, "v2.txt")}); n != 1 {
+		t.Fatalf("監看的目錄數 = %d, want 1", n)
+	}
+	expectChange(newDir, true)
+	expectChange(oldDir, false)
+
+	// 未啟用監看時可安全呼叫
+	var none *Watcher
+	if n := none.SetPaths([]string{newDir}); n != 0 {
+		t.Errorf("nil Watcher 的目錄數 = %d", n)
+	}
+}
@@ -0,0 +1,161 @@
+// Package costs 依設定檔 costs.prices 的模型價格，將 Gemini token 用量換算為費用，
+// 並依日期、來源、模型與 prompt 版本彙總成報表。
+package costs
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Row 為報表中一個分組的用量與費用
+type Row struct {
+	Key             string  `json:"key"`
+	Calls           int64   `json:"calls"`
+	FailedCalls     int64   `json:"failed_calls"`
+	PromptTokens    int64   `json:"prompt_tokens"`
+	CandidateTokens int64   `json:"candidate_tokens"`
+	TotalTokens     int64   `json:"total_tokens"`
+	Cost            float64 `json:"cost"`
+}
+
+// BudgetStatus 本月預算使用狀況
+type BudgetStatus struct {
+	Month    string  `json:"month"`  // 2006-01
+	Budget   float64 `json:"budget"` // 0 表示不限制
+	Spent    float64 `json:"spent"`
+	Exceeded bool    `json:"exceeded"`
+}
+
+// Report 費用報表；From / To 為含頭含尾的日期 (2006-01-02)
+type Report struct {
+	From            string       `json:"from"`
+	To              string       `json:"to"`
+	Currency        string       `json:"currency"`
+	Total           Row          `json:"total"`
+	ByDay           []Row        `json:"by_day"`
+	BySource        []Row        `json:"by_source"`
+	ByModel         []Row        `json:"by_model"`
+	ByPromptVersion []Row        `json:"by_prompt_version"`
+	UnpricedModels  []string     `json:"unpriced_models"` // 有用量但未設定價格的模型 (費用以 0 計)
+	Budget          BudgetStatus `json:"budget"`
+}
+
+type price struct {
+	input, output float64 // 每百萬 token
+}
+
+func priceTable(cfg config.CostsConfig) map[string]price {
+	table := make(map[string]price, len(cfg.Prices))
+	for _, p := range cfg.Prices {
+		table[strings.ToLower(strings.TrimSpace(p.Model))] = price{input: p.InputPerMillion, output: p.OutputPerMillion}
+	}
+	return table
+}
+
+// cost 計算一組用量的費用；回應 token 以 total - prompt 計 (涵蓋思考 token)，且不少於 candidate token
+func cost(a models.UsageAggregate, p price) float64 {
+	output := a.CandidateTokens
+	if extra := a.TotalTokens - a.PromptTokens; extra > output {
+		output = extra
+	}
+	return float64(a.PromptTokens)*p.input/1e6 + float64(output)*p.output/1e6
+}
+
+func (r *Row) add(a models.UsageAggregate, c float64) {
+	r.Calls += a.Calls
+	r.FailedCalls += a.FailedCalls
+	r.PromptTokens += a.PromptTokens
+	r.CandidateTokens += a.CandidateTokens
+	r.TotalTokens += a.TotalTokens
+	r.Cost += c
+}
+
+// PromptVersionKey 回傳報表中 prompt 版本分組的名稱；文本與影片 prompt 的版本名稱各自獨立
+func PromptVersionKey(task models.PromptKind, version string) string {
+	if version == "" {
+		version = "(未記錄)"
+	}
+	if task == models.PromptKindText {
+		return "文本 / " + version
+	}
+	return "影片 / " + version
+}
+
+// Compute 依價格設定彙總用量 (From、To 與 Budget 由呼叫端設定)
+func Compute(aggs []models.UsageAggregate, cfg config.CostsConfig) Report {
+	prices := priceTable(cfg)
+	report := Report{Currency: cfg.Currency}
+	byDay := make(map[string]*Row)
+	bySource := make(map[string]*Row)
+	byModel := make(map[string]*Row)
+	byPrompt := make(map[string]*Row)
+	unpriced := make(map[string]bool)
+
+	group := func(m map[string]*Row, key string) *Row {
+		if key == "" {
+			key = "(未知)"
+		}
+		r, ok := m[key]
+		if !ok {
+			r = &Row{Key: key}
+			m[key] = r
+		}
+		return r
+	}
+	for _, a := range aggs {
+		p, ok := prices[strings.ToLower(a.ModelName)]
+		if !ok && a.TotalTokens > 0 {
+			unpriced[a.ModelName] = true
+		}
+		c := cost(a, p)
+		report.Total.add(a, c)
+		group(byDay, a.Day).add(a, c)
+		group(bySource, a.SourceName).add(a, c)
+		group(byModel, a.ModelName).add(a, c)
+		group(byPrompt, PromptVersionKey(a.Task, a.PromptVersion)).add(a, c)
+	}
+	report.Total.Key = "合計"
+	report.ByDay = sortedRows(byDay, func(a, b Row) bool { return a.Key < b.Key })
+	byCost := func(a, b Row) bool {
+		if a.Cost != b.Cost {
+			return a.Cost > b.Cost
+		}
+		return a.Key < b.Key
+	}
+	report.BySource = sortedRows(bySource, byCost)
+	report.ByModel = sortedRows(byModel, byCost)
+	report.ByPromptVersion = sortedRows(byPrompt, byCost)
+	for m := range unpriced {
+		report.UnpricedModels = append(report.UnpricedModels, m)
+	}
+	sort.Strings(report.UnpricedModels)
+	return report
+}
+
+func sortedRows(m map[string]*Row, less func(a, b Row) bool) []Row {
+	rows := make([]Row, 0, len(m))
+	for _, r := range m {
+		rows = append(rows, *r)
+	}
+	sort.Slice(rows, func(i, j int) bool { return less(rows[i], rows[j]) })
+	return rows
+}
+
+// MonthStart 回傳 t 所在月份的第一天 (當地時間 00:00)
+func MonthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// Budget 依本月至今的用量計算預算使用狀況
+func Budget(monthAggs []models.UsageAggregate, cfg config.CostsConfig, now time.Time) BudgetStatus {
+	spent := Compute(monthAggs, cfg).Total.Cost
+	return BudgetStatus{
+		Month:    now.Format("2006-01"),
+		Budget:   cfg.MonthlyBudget,
+		Spent:    spent,
+		Exceeded: cfg.MonthlyBudget > 0 && spent >= cfg.MonthlyBudget,
+	}
+}
@@ -0,0 +1,186 @@
+package feeds
+
+import (
+	"AiHackathon-admin/internal/models"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+// Feed 描述一個訂閱源的基本資訊
+type Feed struct {
+	ID       string // 訂閱源的唯一識別，例如 "urn:aihackathon-admin:feed:rating-SA"
+	Title    string
+	SelfURL  string
+	BaseURL  string // 儀表板的對外網址前綴，例如 https://admin.example.com
+	Subtitle string
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"feed"`
+	Xmlns    string      `xml:"xmlns,attr"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr,omitempty"`
+	Text string `xml:",chardata"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Author     atomAuthor     `xml:"author"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+}
+
+// EntryUpdated 回傳項目的更新時間，用於 Last-Modified 與 <updated>
+func EntryUpdated(video models.Video, result models.AnalysisResult) time.Time {
+	updated := result.UpdatedAt
+	if video.AnalyzedAt.Valid && video.AnalyzedAt.Time.After(updated) {
+		updated = video.AnalyzedAt.Time
+	}
+	return updated
+}
+
+// DashboardEntryURL 回傳儀表板中該影片卡片的連結
+func DashboardEntryURL(baseURL string, video models.Video) string {
+	return fmt.Sprintf("%s/dashboard?search=%s#video-%d", strings.TrimRight(baseURL, "/"), url.QueryEscape(video.SourceID), video.ID)
+}
+
+// Render 將影片與分析結果輸出為 Atom 1.0 XML。videos 與 results 需一一對應。
+func Render(w io.Writer, feed Feed, videos []models.Video, results []models.AnalysisResult) error {
+	if len(videos) != len(results) {
+		return fmt.Errorf("影片數量 (%d) 與分析結果數量 (%d) 不一致", len(videos), len(results))
+	}
+	af := atomFeed{
+		Xmlns:    atomNamespace,
+		ID:       feed.ID,
+		Title:    feed.Title,
+		Subtitle: feed.Subtitle,
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: feed.SelfURL},
+			{Rel: "alternate", Type: "text/html", Href: strings.TrimRight(feed.BaseURL, "/") + "/dashboard"},
+		},
+	}
+	var latest time.Time
+	for i, v := range videos {
+		entry, updated := buildEntry(feed, v, results[i])
+		if updated.After(latest) {
+			latest = updated
+		}
+		af.Entries = append(af.Entries, entry)
+	}
+	if latest.IsZero() {
+		latest = time.Unix(0, 0)
+	}
+	af.Updated = latest.UTC().Format(time.RFC3339)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(af); err != nil {
+		return fmt.Errorf("輸出 Atom XML 失敗: %w", err)
+	}
+	return enc.Flush()
+}
+
+func buildEntry(feed Feed, v models.Video, ar models.AnalysisResult) (atomEntry, time.Time) {
+	updated := EntryUpdated(v, ar)
+	var importance models.ImportanceScore
+	if len(ar.ImportanceScore) > 0 {
+		_ = json.Unmarshal(ar.ImportanceScore, &importance)
+	}
+	title := v.Title.String
+	if title == "" {
+		title = fmt.Sprintf("%s%s", strings.ToUpper(v.SourceName), v.SourceID)
+	}
+	if importance.OverallRating != "" {
+		title = fmt.Sprintf("[%s] %s", strings.ToUpper(importance.OverallRating), title)
+	}
+	entry := atomEntry{
+		ID:      fmt.Sprintf("urn:aihackathon-admin:video:%d", v.ID),
+		Title:   title,
+		Updated: updated.UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: strings.ToUpper(v.SourceName)},
+		Links:   []atomLink{{Rel: "alternate", Type: "text/html", Href: DashboardEntryURL(feed.BaseURL, v)}},
+	}
+	if v.PublishedAt.Valid {
+		entry.Published = v.PublishedAt.Time.UTC().Format(time.RFC3339)
+	}
+	var topics []string
+	if len(ar.Topics) > 0 && json.Unmarshal(ar.Topics, &topics) == nil {
+		for _, t := range topics {
+			if t = strings.TrimSpace(t); t != "" {
+				entry.Categories = append(entry.Categories, atomCategory{Term: t})
+			}
+		}
+	}
+	if ar.ShortSummary != nil && ar.ShortSummary.Valid && ar.ShortSummary.String != "" {
+		entry.Summary = &atomText{Type: "text", Text: ar.ShortSummary.String}
+	}
+	entry.Content = &atomText{Type: "html", Text: buildContentHTML(v, ar, importance)}
+	return entry, updated
+}
+
+// buildContentHTML 產生 entry 內容：摘要、評級因素與 BITE
+func buildContentHTML(v models.Video, ar models.AnalysisResult, importance models.ImportanceScore) string {
+	var sb strings.Builder
+	if ar.ShortSummary != nil && ar.ShortSummary.Valid && ar.ShortSummary.String != "" {
+		fmt.Fprintf(&sb, "<p>%s</p>", html.EscapeString(ar.ShortSummary.String))
+	}
+	if ar.BulletedSummary != nil && ar.BulletedSummary.Valid && ar.BulletedSummary.String != "" {
+		fmt.Fprintf(&sb, "<pre>%s</pre>", html.EscapeString(ar.BulletedSummary.String))
+	}
+	if len(importance.KeyFactors) > 0 {
+		fmt.Fprintf(&sb, "<p>重要性 %s：%s</p>", html.EscapeString(importance.OverallRating), html.EscapeString(strings.Join(importance.KeyFactors, "、")))
+	}
+	var bites []models.Bite
+	if len(ar.Bites) > 0 && json.Unmarshal(ar.Bites, &bites) == nil && len(bites) > 0 {
+		sb.WriteString("<h4>BITE</h4><ul>")
+		for _, b := range bites {
+			fmt.Fprintf(&sb, "<li>%s %s：%s</li>", html.EscapeString(b.TimeLine), html.EscapeString(b.Speaker), html.EscapeString(b.Quote))
+		}
+		sb.WriteString("</ul>")
+	}
+	if v.Location.Valid && v.Location.String != "" {
+		fmt.Fprintf(&sb, "<p>地點：%s</p>", html.EscapeString(v.Location.String))
+	}
+	if v.Restrictions.Valid && v.Restrictions.String != "" {
+		fmt.Fprintf(&sb, "<p>限制：%s</p>", html.EscapeString(v.Restrictions.String))
+	}
+	return sb.String()
+}
@@ -0,0 +1,135 @@
+package mediaprobe
+
+import (
+	"AiHackathon-admin/internal/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FindFFprobe 尋找 ffprobe 執行檔；configured 為設定檔指定的路徑 (可為空，改由 PATH 尋找)
+func FindFFprobe(configured string) (string, bool) {
+	name := configured
+	if name == "" {
+		name = "ffprobe"
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// ffprobeOutput ffprobe -print_format json -show_format -show_streams 的輸出 (只取用到的欄位)
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecType    string `json:"codec_type"`
+		CodecName    string `json:"codec_name"`
+		Width        int    `json:"width"`
+		Height       int    `json:"height"`
+		AvgFrameRate string `json:"avg_frame_rate"`
+		RFrameRate   string `json:"r_frame_rate"`
+		Channels     int    `json:"channels"`
+		Duration     string `json:"duration"`
+		Disposition  struct {
+			AttachedPic int `json:"attached_pic"`
+		} `json:"disposition"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+		Size     string `json:"size"`
+	} `json:"format"`
+}
+
+// FFprobe 以 ffprobe 取得 input (本地路徑或 http(s) 網址) 的技術資訊；只取第一個視訊與音訊串流
+func FFprobe(ctx context.Context, ffprobePath, input string) (models.MediaInfo, error) {
+	cmd := exec.CommandContext(ctx, ffprobePath,
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format", "-show_streams",
+		input,
+	)
+	out, err := cmd.Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return models.MediaInfo{}, fmt.Errorf("ffprobe 執行失敗: %w (%s)", err, strings.TrimSpace(string(ee.Stderr)))
+		}
+		return models.MediaInfo{}, fmt.Errorf("ffprobe 執行失敗: %w", err)
+	}
+	return parseFFprobe(out)
+}
+
+func parseFFprobe(out []byte) (models.MediaInfo, error) {
+	var probe ffprobeOutput
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return models.MediaInfo{}, fmt.Errorf("無法解析 ffprobe 輸出: %w", err)
+	}
+	info := models.MediaInfo{ProbedWith: models.ProbedWithFFprobe}
+	info.DurationMs = secondsToMs(probe.Format.Duration)
+	info.Size, _ = strconv.ParseInt(probe.Format.Size, 10, 64)
+	var hasVideo, hasAudio bool
+	for _, st := range probe.Streams {
+		switch {
+		case st.CodecType == "video" && !hasVideo && st.Disposition.AttachedPic == 0: // 略過封面圖片
+			hasVideo = true
+			info.VideoCodec, info.Width, info.Height = st.CodecName, st.Width, st.Height
+			info.FrameRate = parseRational(st.AvgFrameRate)
+			if info.FrameRate == 0 {
+				info.FrameRate = parseRational(st.RFrameRate)
+			}
+			if info.DurationMs == 0 {
+				info.DurationMs = secondsToMs(st.Duration)
+			}
+		case st.CodecType == "audio" && !hasAudio:
+			hasAudio = true
+			info.AudioCodec, info.AudioChannels = st.CodecName, st.Channels
+			if info.DurationMs == 0 {
+				info.DurationMs = secondsToMs(st.Duration)
+			}
+		}
+	}
+	if !hasVideo && !hasAudio {
+		return models.MediaInfo{}, fmt.Errorf("ffprobe 未找到視訊或音訊串流")
+	}
+	return info, nil
+}
+
+// parseRational 解析 "30000/1001" 形式的影格率，四捨五入至小數三位；無法解析時回傳 0
+func parseRational(s string) float64 {
+	num, den, ok := strings.Cut(s, "/")
+	n, err1 := strconv.ParseFloat(num, 64)
+	d := 1.0
+	var err2 error
+	if ok {
+		d, err2 = strconv.ParseFloat(den, 64)
+	}
+	if err1 != nil || err2 != nil || d == 0 || n <= 0 {
+		return 0
+	}
+	return math.Round(n/d*1000) / 1000
+}
+
+func secondsToMs(s string) int64 {
+	secs, err := strconv.ParseFloat(s, 64)
+	if err != nil || secs <= 0 {
+		return 0
+	}
+	return int64(math.Round(secs * float64(time.Second/time.Millisecond)))
+}
+
+// DurationMismatch 判斷 TXT 記載的長度 (秒) 與實際長度的差異是否超過 toleranceSecs；任一方未知時回傳 false
+func DurationMismatch(reportedSecs int64, actual time.Duration, toleranceSecs int64) bool {
+	if reportedSecs <= 0 || actual <= 0 {
+		return false
+	}
+	diff := time.Duration(reportedSecs)*time.Second - actual
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff > time.Duration(toleranceSecs)*time.Second
+}
@@ -0,0 +1,274 @@
+package mediaprobe
+
+import (
+	"AiHackathon-admin/internal/models"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// MP4Extensions 可由 ProbeMP4 解析的副檔名 (ISO BMFF / QuickTime)
+var MP4Extensions = map[string]bool{".mp4": true, ".m4v": true, ".mov": true, ".m4a": true}
+
+// maxMoovSize moov box 的大小上限；超過時視為損毀，避免讀入過多內容
+const maxMoovSize = 64 << 20
+
+// ErrNotMP4 檔案不是 ISO BMFF 格式或找不到 moov box
+var ErrNotMP4 = errors.New("不是有效的 MP4/MOV 檔案")
+
+// ProbeMP4 不依賴 ffprobe，直接解析 MP4/MOV 的 box 結構取得長度、解析度、編碼、影格率與聲道數。
+// 只讀取 moov box，以 Seek 略過 mdat (物件儲存的 Range 讀取也不會下載整個檔案)；size 為檔案大小
+func ProbeMP4(r io.ReadSeeker, size int64) (models.MediaInfo, error) {
+	moov, err := findTopLevelBox(r, size, "moov")
+	if err != nil {
+		return models.MediaInfo{}, err
+	}
+	info := models.MediaInfo{ProbedWith: models.ProbedWithMP4, Size: size}
+	var movieDuration, movieTimescale uint64
+	var hasVideo, hasAudio bool
+	for _, b := range children(moov) {
+		switch b.typ {
+		case "mvhd":
+			movieTimescale, movieDuration = parseTimeHeader(b.data, 12, 20)
+		case "trak":
+			t := parseTrak(b.data)
+			switch {
+			case t.handler == "vide" && !hasVideo:
+				hasVideo = true
+				info.VideoCodec, info.Width, info.Height, info.FrameRate = t.codec, t.width, t.height, t.frameRate
+			case t.handler == "soun" && !hasAudio:
+				hasAudio = true
+				info.AudioCodec, info.AudioChannels = t.codec, t.channels
+			}
+			if info.DurationMs == 0 && t.timescale > 0 && (t.handler == "vide" || t.handler == "soun") {
+				info.DurationMs = int64(t.duration * 1000 / t.timescale)
+			}
+		}
+	}
+	if movieTimescale > 0 && movieDuration > 0 {
+		info.DurationMs = int64(movieDuration * 1000 / movieTimescale)
+	}
+	if !hasVideo && !hasAudio {
+		return models.MediaInfo{}, fmt.Errorf("%w: 沒有視訊或音訊軌", ErrNotMP4)
+	}
+	return info, nil
+}
+
+type box struct {
+	typ  string
+	data []byte // 不含 box 標頭
+}
+
+// findTopLevelBox 依序讀取最上層 box 的標頭，找到 typ 時讀入其內容
+func findTopLevelBox(r io.ReadSeeker, size int64, typ string) ([]byte, error) {
+	var offset int64
+	header := make([]byte, 16)
+	for offset+8 <= size {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(r, header[:8]); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrNotMP4, err)
+		}
+		boxSize := int64(binary.BigEndian.Uint32(header[:4]))
+		boxType := string(header[4:8])
+		headerLen := int64(8)
+		switch boxSize {
+		case 0: // 延伸到檔案結尾
+			boxSize = size - offset
+		case 1: // 64 位元大小
+			if _, err := io.ReadFull(r, header[8:16]); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrNotMP4, err)
+			}
+			boxSize = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerLen = 16
+		}
+		if offset == 0 && !isMP4TopLevel(boxType) {
+			return nil, ErrNotMP4
+		}
+		if boxSize < headerLen || offset+boxSize > size {
+			return nil, fmt.Errorf("%w: box '%s' 大小無效", ErrNotMP4, boxType)
+		}
+		if boxType == typ {
+			if boxSize-headerLen > maxMoovSize {
+				return nil, fmt.Errorf("%w: box '%s' 過大 (%d bytes)", ErrNotMP4, boxType, boxSize)
+			}
+			data := make([]byte, boxSize-headerLen)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrNotMP4, err)
+			}
+			return data, nil
+		}
+		offset += boxSize
+	}
+	return nil, fmt.Errorf("%w: 找不到 %s box", ErrNotMP4, typ)
+}
+
+// isMP4TopLevel 檔案開頭可能出現的 box 類型
+func isMP4TopLevel(typ string) bool {
+	switch typ {
+	case "ftyp", "moov", "mdat", "free", "skip", "wide", "pnot", "uuid":
+		return true
+	}
+	return false
+}
+
+// children 解析容器 box 內的子 box；遇到無效大小時停止
+func children(data []byte) []box {
+	var boxes []box
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data[:4]))
+		typ := string(data[4:8])
+		headerLen := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return boxes
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			headerLen = 16
+		}
+		if size < headerLen || size > uint64(len(data)) {
+			return boxes
+		}
+		boxes = append(boxes, box{typ: typ, data: data[headerLen:size]})
+		data = data[size:]
+	}
+	return boxes
+}
+
+func child(data []byte, path ...string) []byte {
+	for _, typ := range path {
+		found := false
+		for _, b := range children(data) {
+			if b.typ == typ {
+				data, found = b.data, true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	return data
+}
+
+// parseTimeHeader 解析 mvhd/mdhd 的 timescale 與 duration；version 0 的 timescale 位於 v0Offset，version 1 位於 v1Offset
+func parseTimeHeader(data []byte, v0Offset, v1Offset int) (timescale, duration uint64) {
+	if len(data) < 4 {
+		return 0, 0
+	}
+	if data[0] == 1 {
+		if len(data) < v1Offset+12 {
+			return 0, 0
+		}
+		return uint64(binary.BigEndian.Uint32(data[v1Offset:])), binary.BigEndian.Uint64(data[v1Offset+4:])
+	}
+	if len(data) < v0Offset+8 {
+		return 0, 0
+	}
+	d := binary.BigEndian.Uint32(data[v0Offset+4:])
+	if d == math.MaxUint32 { // 長度未知
+		d = 0
+	}
+	return uint64(binary.BigEndian.Uint32(data[v0Offset:])), uint64(d)
+}
+
+type trackInfo struct {
+	handler             string // vide、soun 等
+	codec               string
+	width, height       int
+	frameRate           float64
+	channels            int
+	timescale, duration uint64
+}
+
+func parseTrak(trak []byte) trackInfo {
+	var t trackInfo
+	mdia := child(trak, "mdia")
+	if hdlr := child(mdia, "hdlr"); len(hdlr) >= 12 {
+		t.handler = string(hdlr[8:12])
+	}
+	t.timescale, t.duration = parseTimeHeader(child(mdia, "mdhd"), 12, 20)
+
+	// tkhd 的寬高為 16.16 定點數 (已套用顯示比例)
+	if tkhd := child(trak, "tkhd"); len(tkhd) > 0 {
+		offset := 76
+		if tkhd[0] == 1 {
+			offset = 88
+		}
+		if len(tkhd) >= offset+8 {
+			t.width = int(binary.BigEndian.Uint32(tkhd[offset:]) >> 16)
+			t.height = int(binary.BigEndian.Uint32(tkhd[offset+4:]) >> 16)
+		}
+	}
+
+	stbl := child(mdia, "minf", "stbl")
+	if stsd := child(stbl, "stsd"); len(stsd) >= 16 {
+		entry := stsd[8:]
+		t.codec = codecName(string(entry[4:8]))
+		switch t.handler {
+		case "vide":
+			if (t.width == 0 || t.height == 0) && len(entry) >= 36 {
+				t.width, t.height = int(binary.BigEndian.Uint16(entry[32:])), int(binary.BigEndian.Uint16(entry[34:]))
+			}
+		case "soun":
+			if len(entry) >= 26 {
+				t.channels = int(binary.BigEndian.Uint16(entry[24:]))
+			}
+		}
+	}
+	if t.handler == "vide" && t.timescale > 0 {
+		t.frameRate = frameRate(child(stbl, "stts"), t.timescale)
+	}
+	return t
+}
+
+// frameRate 由 stts (每個樣本的時間長度) 計算平均影格率
+func frameRate(stts []byte, timescale uint64) float64 {
+	if len(stts) < 8 {
+		return 0
+	}
+	n := int(binary.BigEndian.Uint32(stts[4:8]))
+	var samples, ticks uint64
+	for i := 0; i < n && 8+i*8+8 <= len(stts); i++ {
+		count := uint64(binary.BigEndian.Uint32(stts[8+i*8:]))
+		delta := uint64(binary.BigEndian.Uint32(stts[12+i*8:]))
+		samples += count
+		ticks += count * delta
+	}
+	if samples == 0 || ticks == 0 {
+		return 0
+	}
+	return math.Round(float64(samples)*float64(timescale)/float64(ticks)*1000) / 1000
+}
+
+// codecName 將 sample entry 的 fourcc 轉為與 ffprobe 相同的編碼名稱
+func codecName(fourcc string) string {
+	switch fourcc {
+	case "avc1", "avc3":
+		return "h264"
+	case "hvc1", "hev1":
+		return "hevc"
+	case "av01":
+		return "av1"
+	case "vp09":
+		return "vp9"
+	case "mp4a":
+		return "aac"
+	case "ac-3":
+		return "ac3"
+	case "ec-3":
+		return "eac3"
+	case "Opus":
+		return "opus"
+	case "apch", "apcn", "apcs", "apco", "ap4h":
+		return "prores"
+	}
+	return strings.TrimSpace(strings.ToLower(fourcc))
+}
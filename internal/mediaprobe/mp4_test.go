@@ -0,0 +1,348 @@
+package mediaprobe
+
+import (
+	"AiHackathon-admin/internal/models"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func u16(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+func u32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+func u64(v uint64) []byte { return binary.BigEndian.AppendUint64(nil, v) }
+func zeros(n int) []byte  { return make([]byte, n) }
+
+// mp4Box 組合一個 box (32 位元大小)
+func mp4Box(typ string, parts ...[]byte) []byte {
+	payload := bytes.Join(parts, nil)
+	return append(append(u32(uint32(8+len(payload))), typ...), payload...)
+}
+
+// largeBox 組合一個 64 位元大小的 box
+func largeBox(typ string, payload []byte) []byte {
+	return append(append(append(u32(1), typ...), u64(uint64(16+len(payload)))...), payload...)
+}
+
+// timeHeader 產生 mvhd/mdhd 的內容
+func timeHeader(version byte, timescale uint32, duration uint64) []byte {
+	if version == 1 {
+		return bytes.Join([][]byte{{1, 0, 0, 0}, zeros(16), u32(timescale), u64(duration), zeros(4)}, nil)
+	}
+	return bytes.Join([][]byte{zeros(4), zeros(8), u32(timescale), u32(uint32(duration)), zeros(4)}, nil)
+}
+
+// tkhd 產生指定版本、寬高 (整數像素) 的 tkhd 內容
+func tkhd(version byte, width, height uint32) []byte {
+	offset := 76
+	if version == 1 {
+		offset = 88
+	}
+	data := zeros(offset + 8)
+	data[0] = version
+	binary.BigEndian.PutUint32(data[offset:], width<<16)
+	binary.BigEndian.PutUint32(data[offset+4:], height<<16)
+	return data
+}
+
+func hdlr(handler string) []byte {
+	return bytes.Join([][]byte{zeros(8), []byte(handler), zeros(12), {0}}, nil)
+}
+
+// stsd 產生只有一個 sample entry 的 stsd；視訊 entry 帶寬高，音訊 entry 帶聲道數
+func stsd(fourcc string, width, height, channels uint16) []byte {
+	var entry []byte
+	if width > 0 || height > 0 {
+		entry = bytes.Join([][]byte{zeros(6), u16(1), zeros(16), u16(width), u16(height), zeros(50)}, nil)
+	} else {
+		entry = bytes.Join([][]byte{zeros(6), u16(1), zeros(8), u16(channels), u16(16), zeros(4), u32(48000 << 16)}, nil)
+	}
+	return bytes.Join([][]byte{zeros(4), u32(1), mp4Box(fourcc, entry)}, nil)
+}
+
+// stts 產生 (樣本數, 每樣本長度) 的 stts
+func stts(entries ...[2]uint32) []byte {
+	data := append(zeros(4), u32(uint32(len(entries)))...)
+	for _, e := range entries {
+		data = append(append(data, u32(e[0])...), u32(e[1])...)
+	}
+	return data
+}
+
+type trackSpec struct {
+	handler             string
+	tkhdVersion         byte
+	tkhdWidth, tkhdHigh uint32
+	mdhdVersion         byte
+	timescale           uint32
+	duration            uint64
+	stsd                []byte
+	stts                []byte
+}
+
+func trak(s trackSpec) []byte {
+	stbl := [][]byte{mp4Box("stsd", s.stsd)}
+	if s.stts != nil {
+		stbl = append(stbl, mp4Box("stts", s.stts))
+	}
+	return mp4Box("trak",
+		mp4Box("tkhd", tkhd(s.tkhdVersion, s.tkhdWidth, s.tkhdHigh)),
+		mp4Box("mdia",
+			mp4Box("mdhd", timeHeader(s.mdhdVersion, s.timescale, s.duration)),
+			mp4Box("hdlr", hdlr(s.handler)),
+			mp4Box("minf", mp4Box("stbl", stbl...)),
+		),
+	)
+}
+
+func videoTrak() []byte {
+	return trak(trackSpec{
+		handler: "vide", tkhdWidth: 1920, tkhdHigh: 1080, timescale: 30000, duration: 375375,
+		stsd: stsd("avc1", 1920, 1080, 0), stts: stts([2]uint32{375, 1001}),
+	})
+}
+
+func audioTrak() []byte {
+	return trak(trackSpec{handler: "soun", timescale: 48000, duration: 600000, stsd: stsd("mp4a", 0, 0, 2)})
+}
+
+var ftyp = mp4Box("ftyp", []byte("isom"), u32(512), []byte("isomiso2avc1mp41"))
+
+func probeBytes(t *testing.T, data []byte) (models.MediaInfo, error) {
+	t.Helper()
+	return ProbeMP4(bytes.NewReader(data), int64(len(data)))
+}
+
+func TestProbeMP4(t *testing.T) {
+	moov := mp4Box("moov", mp4Box("mvhd", timeHeader(0, 1000, 12512)), videoTrak(), audioTrak())
+	mdat := mp4Box("mdat", zeros(4096))
+	want := func(size int) models.MediaInfo {
+		return models.MediaInfo{
+			ProbedWith: models.ProbedWithMP4, Size: int64(size), DurationMs: 12512,
+			VideoCodec: "h264", Width: 1920, Height: 1080, FrameRate: 29.97, AudioCodec: "aac", AudioChannels: 2,
+		}
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "moov 在 mdat 之前 (faststart)", data: bytes.Join([][]byte{ftyp, moov, mdat}, nil)},
+		{name: "moov 在 mdat 之後", data: bytes.Join([][]byte{ftyp, mp4Box("free"), mdat, moov}, nil)},
+		{name: "64 位元大小的 mdat", data: bytes.Join([][]byte{ftyp, largeBox("mdat", zeros(4096)), moov}, nil)},
+		{name: "最後的 moov 大小為 0 (延伸到檔案結尾)", data: bytes.Join([][]byte{ftyp, mdat, append(u32(0), moov[4:]...)}, nil)},
+		{name: "沒有 ftyp 的 QuickTime 檔案", data: bytes.Join([][]byte{mp4Box("wide"), mdat, moov}, nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := probeBytes(t, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want(len(tt.data)) {
+				t.Errorf("ProbeMP4() =\n%+v\nwant\n%+v", got, want(len(tt.data)))
+			}
+		})
+	}
+}
+
+// countingReader 記錄實際讀取的 bytes 數
+type countingReader struct {
+	io.ReadSeeker
+	read int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadSeeker.Read(p)
+	r.read += n
+	return n, err
+}
+
+func TestProbeMP4SkipsMdat(t *testing.T) {
+	moov := mp4Box("moov", mp4Box("mvhd", timeHeader(0, 1000, 5000)), videoTrak())
+	data := bytes.Join([][]byte{ftyp, mp4Box("mdat", zeros(1<<20)), moov}, nil)
+	r := &countingReader{ReadSeeker: bytes.NewReader(data)}
+	if _, err := ProbeMP4(r, int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	if max := len(moov) + 3*16; r.read > max {
+		t.Errorf("讀取了 %d bytes，應只讀取 box 標頭與 moov (%d bytes 以內)", r.read, max)
+	}
+}
+
+func TestProbeMP4TrackVariants(t *testing.T) {
+	tests := []struct {
+		name string
+		moov []byte
+		want models.MediaInfo
+	}{
+		{
+			name: "version 1 的 mvhd 與 tkhd",
+			moov: mp4Box("moov", mp4Box("mvhd", timeHeader(1, 90000, 90000*3600*30)), trak(trackSpec{
+				handler: "vide", tkhdVersion: 1, tkhdWidth: 3840, tkhdHigh: 2160, mdhdVersion: 1, timescale: 25, duration: 25 * 3600 * 30,
+				stsd: stsd("hvc1", 3840, 2160, 0), stts: stts([2]uint32{1000, 1}),
+			})),
+			want: models.MediaInfo{DurationMs: 3600 * 30 * 1000, VideoCodec: "hevc", Width: 3840, Height: 2160, FrameRate: 25},
+		},
+		{
+			name: "mvhd 長度未知時使用軌道長度",
+			moov: mp4Box("moov", mp4Box("mvhd", timeHeader(0, 600, math.MaxUint32)), audioTrak(), videoTrak()),
+			want: models.MediaInfo{DurationMs: 12500, VideoCodec: "h264", Width: 1920, Height: 1080, FrameRate: 29.97, AudioCodec: "aac", AudioChannels: 2},
+		},
+		{
+			name: "tkhd 沒有寬高時使用 sample entry 的寬高",
+			moov: mp4Box("moov", trak(trackSpec{
+				handler: "vide", timescale: 24000, duration: 48048,
+				stsd: stsd("apcn", 1280, 720, 0), stts: stts([2]uint32{47, 1001}, [2]uint32{1, 1001}),
+			})),
+			want: models.MediaInfo{DurationMs: 2002, VideoCodec: "prores", Width: 1280, Height: 720, FrameRate: 23.976},
+		},
+		{
+			name: "只有音訊 (m4a)",
+			moov: mp4Box("moov", mp4Box("mvhd", timeHeader(0, 44100, 441000)), trak(trackSpec{handler: "soun", timescale: 44100, duration: 441000, stsd: stsd("Opus", 0, 0, 6)})),
+			want: models.MediaInfo{DurationMs: 10000, AudioCodec: "opus", AudioChannels: 6},
+		},
+		{
+			name: "只取第一個視訊軌並略過字幕軌",
+			moov: mp4Box("moov",
+				trak(trackSpec{handler: "text", timescale: 1000, duration: 99000, stsd: stsd("tx3g", 0, 0, 0)}),
+				videoTrak(),
+				trak(trackSpec{handler: "vide", tkhdWidth: 640, tkhdHigh: 360, timescale: 1000, duration: 1000, stsd: stsd("avc1", 640, 360, 0)}),
+			),
+			want: models.MediaInfo{DurationMs: 12512, VideoCodec: "h264", Width: 1920, Height: 1080, FrameRate: 29.97},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := append(append([]byte{}, ftyp...), tt.moov...)
+			got, err := probeBytes(t, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.want.ProbedWith, tt.want.Size = models.ProbedWithMP4, int64(len(data))
+			if got != tt.want {
+				t.Errorf("ProbeMP4() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeMP4Malformed(t *testing.T) {
+	moov := mp4Box("moov", mp4Box("mvhd", timeHeader(0, 1000, 5000)), videoTrak())
+	valid := append(append([]byte{}, ftyp...), moov...)
+	tests := []struct {
+		name    string
+		data    []byte
+		size    int64 // 0 時使用 len(data)
+		wantErr string
+	}{
+		{name: "空檔案", data: nil, wantErr: "找不到 moov"},
+		{name: "不足一個 box 標頭", data: []byte("ftyp"), wantErr: "找不到 moov"},
+		{name: "不是 MP4", data: bytes.Join([][]byte{[]byte("RIFF"), u32(100), []byte("AVI LIST")}, nil), wantErr: ErrNotMP4.Error()},
+		{name: "沒有 moov", data: bytes.Join([][]byte{ftyp, mp4Box("mdat", zeros(64))}, nil), wantErr: "找不到 moov"},
+		{name: "下載中斷 (moov 不完整)", data: valid[:len(valid)-10], wantErr: "大小無效"},
+		{name: "mdat 大小超過檔案", data: bytes.Join([][]byte{ftyp, u32(1 << 30), []byte("mdat"), zeros(16)}, nil), wantErr: "大小無效"},
+		{name: "box 大小小於標頭", data: bytes.Join([][]byte{ftyp, u32(4), []byte("free"), moov}, nil), wantErr: "大小無效"},
+		{name: "64 位元大小不完整", data: bytes.Join([][]byte{ftyp, u32(1), []byte("mdat")}, nil), wantErr: ErrNotMP4.Error()},
+		{name: "64 位元大小小於標頭", data: bytes.Join([][]byte{ftyp, u32(1), []byte("mdat"), u64(8), moov}, nil), wantErr: "大小無效"},
+		{name: "moov 過大", data: bytes.Join([][]byte{ftyp, u32(maxMoovSize + 9), []byte("moov")}, nil), size: int64(len(ftyp)) + maxMoovSize + 9, wantErr: "過大"},
+		{name: "實際內容短於檔案大小", data: valid[:len(valid)-10], size: int64(len(valid)), wantErr: io.ErrUnexpectedEOF.Error()},
+		{name: "沒有軌道", data: bytes.Join([][]byte{ftyp, mp4Box("moov", mp4Box("mvhd", timeHeader(0, 1000, 5000)))}, nil), wantErr: "沒有視訊或音訊軌"},
+		{name: "moov 內容損毀", data: bytes.Join([][]byte{ftyp, mp4Box("moov", u32(0xFFFFFF), []byte("trak"), zeros(32))}, nil), wantErr: "沒有視訊或音訊軌"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size := tt.size
+			if size == 0 {
+				size = int64(len(tt.data))
+			}
+			_, err := ProbeMP4(bytes.NewReader(tt.data), size)
+			if !errors.Is(err, ErrNotMP4) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ProbeMP4() error = %v, want ErrNotMP4 且包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestProbeMP4Truncated 任意截斷的檔案或 moov 內容都不得造成 panic
+func TestProbeMP4Truncated(t *testing.T) {
+	moovPayload := bytes.Join([][]byte{mp4Box("mvhd", timeHeader(0, 1000, 5000)), videoTrak(), audioTrak()}, nil)
+	valid := append(append([]byte{}, ftyp...), mp4Box("moov", moovPayload)...)
+	for n := 0; n < len(valid); n++ {
+		if _, err := probeBytes(t, valid[:n]); err == nil {
+			t.Errorf("截斷至 %d bytes 應回傳錯誤", n)
+		}
+	}
+	// moov 標頭完整但內容被截斷：子 box 的大小與實際內容不符
+	for n := 0; n <= len(moovPayload); n++ {
+		data := append(append([]byte{}, ftyp...), mp4Box("moov", moovPayload[:n])...)
+		info, err := probeBytes(t, data)
+		if err == nil && info.VideoCodec == "" && info.AudioCodec == "" {
+			t.Errorf("截斷 moov 至 %d bytes: 沒有錯誤也沒有軌道資訊", n)
+		}
+	}
+}
+
+func TestFrameRate(t *testing.T) {
+	tests := []struct {
+		name      string
+		stts      []byte
+		timescale uint64
+		want      float64
+	}{
+		{name: "固定影格率", stts: stts([2]uint32{300, 1000}), timescale: 30000, want: 30},
+		{name: "NTSC", stts: stts([2]uint32{300, 1001}), timescale: 30000, want: 29.97},
+		{name: "多個項目", stts: stts([2]uint32{10, 512}, [2]uint32{10, 1024}), timescale: 12800, want: 16.667},
+		{name: "沒有項目", stts: stts(), timescale: 30000, want: 0},
+		{name: "項目數大於實際內容", stts: append(append(zeros(4), u32(5)...), append(u32(300), u32(1000)...)...), timescale: 30000, want: 30},
+		{name: "長度為 0 的樣本", stts: stts([2]uint32{300, 0}), timescale: 30000, want: 0},
+		{name: "內容過短", stts: zeros(6), timescale: 30000, want: 0},
+	}
+	for _, tt := range tests {
+		if got := frameRate(tt.stts, tt.timescale); got != tt.want {
+			t.Errorf("%s: frameRate() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseTimeHeaderShortData(t *testing.T) {
+	for _, data := range [][]byte{nil, zeros(3), zeros(19), append([]byte{1}, zeros(30)...)} {
+		if ts, d := parseTimeHeader(data, 12, 20); ts != 0 || d != 0 {
+			t.Errorf("parseTimeHeader(%d bytes) = %d, %d", len(data), ts, d)
+		}
+	}
+}
+
+func TestChildren(t *testing.T) {
+	data := bytes.Join([][]byte{
+		mp4Box("free", []byte("ab")),
+		largeBox("skip", []byte("cd")),
+		u32(100), []byte("bad!"), // 大小超過剩餘內容，停止解析
+	}, nil)
+	got := children(data)
+	if len(got) != 2 || got[0].typ != "free" || string(got[0].data) != "ab" || got[1].typ != "skip" || string(got[1].data) != "cd" {
+		t.Errorf("children() = %+v", got)
+	}
+	// 大小為 0 的 box 延伸到容器結尾
+	if got := children(append(u32(0), "last1234"...)); len(got) != 1 || string(got[0].data) != "1234" {
+		t.Errorf("children() = %+v", got)
+	}
+	if got := child(data, "free", "missing"); got != nil {
+		t.Errorf("child() = %v, want nil", got)
+	}
+}
+
+func TestCodecName(t *testing.T) {
+	tests := map[string]string{
+		"avc1": "h264", "avc3": "h264", "hev1": "hevc", "av01": "av1", "vp09": "vp9",
+		"mp4a": "aac", "ac-3": "ac3", "ec-3": "eac3", "Opus": "opus", "ap4h": "prores",
+		"mp4v": "mp4v", "MJPG": "mjpg", "raw ": "raw",
+	}
+	for fourcc, want := range tests {
+		if got := codecName(fourcc); got != want {
+			t.Errorf("codecName(%q) = %q, want %q", fourcc, got, want)
+		}
+	}
+}
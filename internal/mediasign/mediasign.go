@@ -0,0 +1,122 @@
+package mediasign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 簽章查詢參數名稱
+const (
+	ParamExpires   = "exp"
+	ParamSignature = "sig"
+)
+
+var (
+	// ErrMissing 網址沒有簽章
+	ErrMissing = errors.New("網址未簽章")
+	// ErrExpired 簽章已過期
+	ErrExpired = errors.New("網址已過期")
+	// ErrInvalid 簽章不符 (路徑或到期時間遭修改，或金鑰已更換)
+	ErrInvalid = errors.New("網址簽章無效")
+)
+
+// Signer 以 HMAC-SHA256 簽署 /media/ 網址：簽章涵蓋 NAS 相對路徑與到期時間，
+// 持有網址者在到期前不需登入即可存取該檔案
+type Signer struct {
+	key []byte
+	ttl time.Duration
+}
+
+// New 建立 Signer；secret 為空時回傳 nil (不簽章)。nil 的 Signer 可安全呼叫，產生的網址不含簽章
+func New(secret string, ttl time.Duration) *Signer {
+	if secret == "" {
+		return nil
+	}
+	return &Signer{key: []byte(secret), ttl: ttl}
+}
+
+// URL 回傳 prefix (例如 /media 或 https://admin.example.com/media) 加上逸出後的 relPath，並附加有效期間為預設 TTL 的簽章。
+// 到期時間取整分鐘，同一分鐘內產生的網址相同，瀏覽器可沿用快取
+func (s *Signer) URL(prefix, relPath string) string {
+	if s == nil {
+		return joinURL(prefix, relPath, "")
+	}
+	return s.URLUntil(prefix, relPath, time.Now().Add(s.ttl).Truncate(time.Minute))
+}
+
+// URLUntil 同 URL，但簽章在 expires 到期 (例如靜態匯出需要較長的期間)
+func (s *Signer) URLUntil(prefix, relPath string, expires time.Time) string {
+	if s == nil {
+		return joinURL(prefix, relPath, "")
+	}
+	return joinURL(prefix, relPath, s.Query(relPath, expires))
+}
+
+// TTL 回傳預設的有效期間
+func (s *Signer) TTL() time.Duration {
+	return s.ttl
+}
+
+// Query 回傳 relPath 在 expires (取至秒) 前有效的簽章查詢字串 (不含 ?)
+func (s *Signer) Query(relPath string, expires time.Time) string {
+	exp := expires.Unix()
+	v := url.Values{}
+	v.Set(ParamExpires, strconv.FormatInt(exp, 10))
+	v.Set(ParamSignature, s.sign(canonical(relPath), exp))
+	return v.Encode()
+}
+
+// Verify 檢查 query 中的簽章是否涵蓋 relPath 且在 now 時尚未過期；沒有簽章參數時回傳 ErrMissing
+func (s *Signer) Verify(relPath string, query url.Values, now time.Time) error {
+	expStr, sig := query.Get(ParamExpires), query.Get(ParamSignature)
+	if expStr == "" && sig == "" {
+		return ErrMissing
+	}
+	if s == nil {
+		return ErrInvalid
+	}
+	exp, err := strconv.ParseInt(expStr, 10, 64)
+	if err != nil || sig == "" {
+		return ErrInvalid
+	}
+	if !hmac.Equal([]byte(sig), []byte(s.sign(canonical(relPath), exp))) {
+		return ErrInvalid
+	}
+	if !now.Before(time.Unix(exp, 0)) {
+		return ErrExpired
+	}
+	return nil
+}
+
+func (s *Signer) sign(relPath string, exp int64) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(relPath))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(strconv.FormatInt(exp, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// canonical 統一簽署與驗證時的路徑格式 (以 / 分隔、無開頭的 /)
+func canonical(relPath string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(relPath, "\\", "/")), "/")
+}
+
+// joinURL 逐段逸出 relPath (檔名可能含空白、# 或 ?) 並組成網址
+func joinURL(prefix, relPath, query string) string {
+	segments := strings.Split(canonical(relPath), "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	u := strings.TrimRight(prefix, "/") + "/" + strings.Join(segments, "/")
+	if query != "" {
+		u += "?" + query
+	}
+	return u
+}
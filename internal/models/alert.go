@@ -0,0 +1,45 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// AlertChannel 快訊通知管道
+type AlertChannel string
+
+const (
+	AlertChannelEmail AlertChannel = "email"
+	AlertChannelChat  AlertChannel = "chat"
+)
+
+// AlertFiringStatus 快訊觸發紀錄的狀態
+type AlertFiringStatus string
+
+const (
+	AlertFiringPending AlertFiringStatus = "pending"
+	AlertFiringSent    AlertFiringStatus = "sent"
+	AlertFiringFailed  AlertFiringStatus = "failed"
+)
+
+// AlertRule 對應 alert_rules 資料表
+type AlertRule struct {
+	ID         int64        `json:"id"`
+	Name       string       `json:"name"`
+	Expression string       `json:"expression"`
+	Channel    AlertChannel `json:"channel"`
+	Target     string       `json:"target"`
+	Enabled    bool         `json:"enabled"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+}
+
+// AlertFiring 對應 alert_firings 資料表
+type AlertFiring struct {
+	ID           int64             `json:"id"`
+	RuleID       int64             `json:"rule_id"`
+	VideoID      int64             `json:"video_id"`
+	Status       AlertFiringStatus `json:"status"`
+	ErrorMessage sql.NullString    `json:"error_message"`
+	FiredAt      time.Time         `json:"fired_at"`
+}
@@ -11,6 +11,7 @@ type AnalysisResult struct {
 	VideoID            int64           `json:"-"`
 	Transcript         *JsonNullString `json:"transcript,omitempty"`         // 來自 types.go 或同 package
 	Translation        *JsonNullString `json:"translation,omitempty"`        // 來自 types.go 或同 package
+	Segments           json.RawMessage `json:"segments,omitempty"`           // []TranscriptSegment，字幕用
 	VisualDescription  *JsonNullString `json:"visual_description,omitempty"` // 來自 types.go 或同 package
 	ShortSummary       *JsonNullString `json:"short_summary,omitempty"`      // 來自 types.go 或同 package
 	BulletedSummary    *JsonNullString `json:"bulleted_summary,omitempty"`   // 來自 types.go 或同 package
@@ -23,6 +24,41 @@ type AnalysisResult struct {
 	Keywords           json.RawMessage `json:"keywords,omitempty"`
 	ErrorMessage       *JsonNullString `json:"error_message,omitempty"` // 來自 types.go 或同 package
 	PromptVersion      string          `json:"-"`
+	PromptHash         string          `json:"-"` // 實際送出 prompt 的 SHA-256
+	ModelName          string          `json:"-"` // 使用的 Gemini 模型
+	ModelParams        json.RawMessage `json:"-"` // 生成參數 (JSON)
+	Outcome            string          `json:"-"` // Gemini 回應結果 (ok/safety/max_tokens/recitation)
+	Fallback           string          `json:"-"` // 原始分析被阻擋時採用的備援方式 (relaxed_safety/text_only)
+	SafetyRatings      json.RawMessage `json:"-"` // 被阻擋時的安全評級 (JSON)
 	CreatedAt          time.Time       `json:"-"`
 	UpdatedAt          time.Time       `json:"-"`
 }
+
+// Bite 對應 AnalysisResult.Bites 中的單一引言
+type Bite struct {
+	TimeLine string `json:"time_line"` // 出現在影片中的時間點，例如 "00:01:02"
+	Speaker  string `json:"speaker"`
+	Quote    string `json:"quote"` // 繁體中文引言
+}
+
+// ImportanceScore 對應 AnalysisResult.ImportanceScore
+type ImportanceScore struct {
+	OverallRating     string   `json:"overall_rating"` // S/A/B/C/N
+	KeyFactors        []string `json:"key_factors"`
+	AssessmentDetails string   `json:"assessment_details"`
+}
+
+// Keyword 對應 AnalysisResult.Keywords 中的單一關鍵字
+type Keyword struct {
+	Keyword  string `json:"keyword"`
+	Category string `json:"category"`
+}
+
+// TranscriptSegment 為逐字稿中帶有時間軸的一段，用於產生 SRT/WebVTT 字幕
+type TranscriptSegment struct {
+	Start       string `json:"start"`       // 起始時間，例如 "00:01:02.500"
+	End         string `json:"end"`         // 結束時間
+	Speaker     string `json:"speaker"`     // 講者 (可為空)
+	Text        string `json:"text"`        // 原始語言內容
+	Translation string `json:"translation"` // 繁體中文翻譯
+}
@@ -0,0 +1,9 @@
+package models
+
+// FeedFilter 定義 RSS/Atom 訂閱源的篩選條件，空值代表不篩選
+type FeedFilter struct {
+	SourceName string   // 來源名稱，例如 "ap"
+	Ratings    []string // 重要性評級，例如 ["S", "A"]
+	Topic      string   // 主題或分類 (比對 analysis_results.topics 與 videos.subjects)
+	SearchTerm string   // 關鍵字搜尋 (同儀表板搜尋欄位)
+}
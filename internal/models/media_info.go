@@ -0,0 +1,36 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// 取得技術資訊的方式
+const (
+	ProbedWithFFprobe = "ffprobe" // ffprobe 解析
+	ProbedWithMP4     = "mp4"     // 沒有 ffprobe 時以內建的 MP4/MOV box 解析 (僅 .mp4/.mov 等 ISO BMFF 檔案)
+)
+
+// MediaInfo 對應 video_media_info 資料表：由影片檔案本身取得的技術資訊 (不依賴 TXT 或模型判讀)。
+// 無法取得的欄位為零值
+type MediaInfo struct {
+	VideoID          int64         `json:"video_id"`
+	Path             string        `json:"path"` // 取得資訊的影片版本，相對於 NAS.VideoPath，以 / 分隔
+	DurationMs       int64         `json:"duration_ms"`
+	Width            int           `json:"width,omitempty"`
+	Height           int           `json:"height,omitempty"`
+	VideoCodec       string        `json:"video_codec,omitempty"` // 例如 h264、hevc
+	AudioCodec       string        `json:"audio_codec,omitempty"` // 例如 aac
+	FrameRate        float64       `json:"frame_rate,omitempty"`
+	AudioChannels    int           `json:"audio_channels,omitempty"`
+	Size             int64         `json:"size"`
+	ProbedWith       string        `json:"probed_with"`
+	TxtDurationSecs  sql.NullInt64 `json:"txt_duration_secs"` // TXT 記載的長度 (用於比對)
+	DurationMismatch bool          `json:"duration_mismatch"` // TXT 記載的長度與實際長度差異超過容許範圍
+	ProbedAt         time.Time     `json:"probed_at"`
+}
+
+// Duration 回傳影片長度
+func (m MediaInfo) Duration() time.Duration {
+	return time.Duration(m.DurationMs) * time.Millisecond
+}
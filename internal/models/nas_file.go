@@ -0,0 +1,49 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// NASFileKind nas_files 紀錄的類型
+type NASFileKind string
+
+const (
+	NASFileDir   NASFileKind = "dir"   // 影片ID目錄 (Download/<source>/<id>)，以修改時間判斷是否需要重新掃描
+	NASFileVideo NASFileKind = "video" // 目錄中的影片/音訊檔 (各個版本)
+	NASFileText  NASFileKind = "text"  // 目錄中的 TXT 描述檔
+)
+
+// NASFile 對應 nas_files 資料表：NAS 下載目錄的檔案清單，用於增量掃描與偵測分析後被修改的檔案
+type NASFile struct {
+	ID              int64
+	Path            string // 相對於 NAS.VideoPath 的路徑，以 / 分隔
+	Kind            NASFileKind
+	SourceName      string
+	SourceID        string
+	Size            int64
+	ModTimeNs       int64  // 修改時間 (Unix 奈秒)
+	SHA256          string // dir 紀錄為空；物件儲存的檔案為 ETag 的 sha256
+	AnalyzedSHA256  string // 最近一次文本元數據分析時的 sha256
+	NeedsReanalysis bool
+	VideoID         sql.NullInt64
+	UpdatedAt       time.Time
+
+	// AnalysisStatus 對應影片 (依 source_name/source_id) 的分析狀態，僅查詢時由 videos 帶出；尚無影片紀錄時為空字串
+	AnalysisStatus AnalysisStatus
+}
+
+// StorageObject 儲存後端中的一個檔案 (物件儲存的列表項目或本地目錄項目)
+type StorageObject struct {
+	Path    string // 相對於儲存根目錄 (NAS.VideoPath 或 bucket prefix) 的路徑，以 / 分隔
+	Size    int64
+	ModTime time.Time
+	ETag    string // 物件儲存的 ETag；本地檔案為空
+}
+
+// StoredFile 串流寫入儲存後端的結果
+type StoredFile struct {
+	Path   string // 相對於儲存根目錄的路徑
+	Size   int64
+	SHA256 string // 寫入時同步計算
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// PromptKind 區分 prompt 所屬的分析類型
+type PromptKind string
+
+const (
+	PromptKindText  PromptKind = "text"  // 文本元數據分析 (對應 config prompts.textFileAnalysis)
+	PromptKindVideo PromptKind = "video" // 影片內容分析 (對應 config prompts.videoAnalysis)
+)
+
+// PromptVersion 對應 prompt_versions 資料表
+type PromptVersion struct {
+	ID          int64      `json:"id"`
+	Kind        PromptKind `json:"kind"`
+	Version     string     `json:"version"`
+	Content     string     `json:"content,omitempty"`
+	BaseVersion string     `json:"base_version,omitempty"` // 建立時所依據的版本，空字串代表無
+	Author      string     `json:"author"`
+	Notes       string     `json:"notes,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// ActivePrompt 對應 prompt_active_versions 資料表：某分析類型目前使用的版本
+type ActivePrompt struct {
+	Kind        PromptKind `json:"kind"`
+	Version     string     `json:"version"`
+	ActivatedBy string     `json:"activated_by"`
+	ActivatedAt time.Time  `json:"activated_at"`
+}
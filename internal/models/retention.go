@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// RetentionItem 保留政策評估的單一影片 (已完成或已封存)
+type RetentionItem struct {
+	VideoID    int64
+	SourceName string
+	SourceID   string
+	NASPath    string
+	FetchedAt  time.Time
+	Status     AnalysisStatus
+	Rating     string // 編輯修訂優先於 AI 評級；未評級時為空字串
+	Pinned     bool
+	Files      []VideoFile // 由 video_files 帶出；沒有版本紀錄時為 nas_path 對應的 master
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// ReviewStatus 編輯審核狀態
+type ReviewStatus string
+
+const (
+	ReviewPending  ReviewStatus = "pending"
+	ReviewApproved ReviewStatus = "approved"
+	ReviewRejected ReviewStatus = "rejected"
+)
+
+// AnalysisReview 對應 analysis_reviews 資料表：編輯對 AI 分析結果的修訂與審核狀態。
+// 修訂欄位為 NULL (或 Bites、Keywords 為 nil) 時代表沿用 analysis_results 中的原始輸出。
+type AnalysisReview struct {
+	VideoID          int64           `json:"video_id"`
+	Status           ReviewStatus    `json:"status"`
+	ShortSummary     sql.NullString  `json:"short_summary"`
+	Bites            json.RawMessage `json:"bites"`
+	Keywords         json.RawMessage `json:"keywords"`
+	Location         sql.NullString  `json:"location"`
+	OverallRating    sql.NullString  `json:"overall_rating"`
+	Note             sql.NullString  `json:"note"`
+	EditedByUserID   sql.NullInt64   `json:"-"`
+	EditedBy         sql.NullString  `json:"edited_by"`
+	EditedAt         sql.NullTime    `json:"edited_at"`
+	ReviewedByUserID sql.NullInt64   `json:"-"`
+	ReviewedBy       sql.NullString  `json:"reviewed_by"`
+	ReviewedAt       sql.NullTime    `json:"reviewed_at"`
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+}
+
+// HasOverrides 回傳是否有任何欄位被編輯修訂
+func (r *AnalysisReview) HasOverrides() bool {
+	return r.ShortSummary.Valid || len(r.Bites) > 0 || len(r.Keywords) > 0 || r.Location.Valid || r.OverallRating.Valid
+}
+
+// ApplyTo 將編輯修訂覆寫到 video 與 result 上 (result 可為 nil)。
+// 呼叫端應傳入自資料庫讀出的副本；資料庫中的原始 AI 輸出不會被修改。
+func (r *AnalysisReview) ApplyTo(video *Video, result *AnalysisResult) {
+	if r == nil {
+		return
+	}
+	if video != nil && r.Location.Valid {
+		video.Location = r.Location
+	}
+	if result == nil {
+		return
+	}
+	if r.ShortSummary.Valid {
+		result.ShortSummary = &JsonNullString{NullString: r.ShortSummary}
+	}
+	if len(r.Bites) > 0 {
+		result.Bites = r.Bites
+	}
+	if len(r.Keywords) > 0 {
+		result.Keywords = r.Keywords
+	}
+	if r.OverallRating.Valid {
+		score := map[string]json.RawMessage{}
+		if len(result.ImportanceScore) > 0 {
+			_ = json.Unmarshal(result.ImportanceScore, &score)
+			if score == nil {
+				score = map[string]json.RawMessage{}
+			}
+		}
+		rating, _ := json.Marshal(r.OverallRating.String)
+		score["overall_rating"] = rating
+		if merged, err := json.Marshal(score); err == nil {
+			result.ImportanceScore = merged
+		}
+	}
+	// 讓訂閱源等依更新時間判斷快取的輸出能反映修訂
+	if r.HasOverrides() && r.UpdatedAt.After(result.UpdatedAt) {
+		result.UpdatedAt = r.UpdatedAt
+	}
+}
+
+// ReviewSample 為計算 prompt 品質指標所需的單筆已審核資料 (AI 原始輸出 + 編輯審核紀錄)
+type ReviewSample struct {
+	VideoID            int64
+	VideoPromptVersion string          // analysis_results.prompt_version (影片內容分析)
+	TextPromptVersion  string          // videos.prompt_version (文本元數據分析，產生地點等欄位)
+	ImportanceScore    json.RawMessage // AI 原始 importance_score
+	Keywords           json.RawMessage // AI 原始 keywords
+	Review             AnalysisReview
+}
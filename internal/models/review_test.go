@@ -0,0 +1,144 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestAnalysisReviewApplyTo(t *testing.T) {
+	aiTime := time.Date(2025, 5, 1, 8, 0, 0, 0, time.UTC)
+	editTime := aiTime.Add(time.Hour)
+	newVideo := func() *Video {
+		return &Video{ID: 1, Location: sql.NullString{String: "Kyiv", Valid: true}}
+	}
+	newResult := func() *AnalysisResult {
+		return &AnalysisResult{
+			VideoID:         1,
+			ShortSummary:    &JsonNullString{NullString: sql.NullString{String: "AI 摘要", Valid: true}},
+			Bites:           json.RawMessage(`[{"quote":"AI"}]`),
+			Keywords:        json.RawMessage(`[{"keyword":"AI"}]`),
+			ImportanceScore: json.RawMessage(`{"overall_rating":"B","key_factors":["戰爭"],"assessment_details":"說明"}`),
+			UpdatedAt:       aiTime,
+		}
+	}
+
+	tests := []struct {
+		name   string
+		review *AnalysisReview
+		check  func(t *testing.T, v *Video, r *AnalysisResult)
+	}{
+		{
+			name:   "nil 審核不修改",
+			review: nil,
+			check: func(t *testing.T, v *Video, r *AnalysisResult) {
+				if v.Location.String != "Kyiv" || r.ShortSummary.String != "AI 摘要" || !r.UpdatedAt.Equal(aiTime) {
+					t.Errorf("不應修改: %+v %+v", v, r)
+				}
+			},
+		},
+		{
+			name:   "沒有修訂只有審核狀態",
+			review: &AnalysisReview{Status: ReviewApproved, Note: sql.NullString{String: "OK", Valid: true}, UpdatedAt: editTime},
+			check: func(t *testing.T, v *Video, r *AnalysisResult) {
+				if v.Location.String != "Kyiv" || string(r.Bites) != `[{"quote":"AI"}]` || !r.UpdatedAt.Equal(aiTime) {
+					t.Errorf("沒有修訂時不應修改: %+v %+v", v, r)
+				}
+			},
+		},
+		{
+			name: "覆寫文字欄位",
+			review: &AnalysisReview{
+				ShortSummary: sql.NullString{String: "編輯摘要", Valid: true},
+				Location:     sql.NullString{String: "基輔", Valid: true},
+				Bites:        json.RawMessage(`[{"quote":"編輯"}]`),
+				Keywords:     json.RawMessage(`[{"keyword":"編輯"}]`),
+				UpdatedAt:    editTime,
+			},
+			check: func(t *testing.T, v *Video, r *AnalysisResult) {
+				if v.Location.String != "基輔" || r.ShortSummary.String != "編輯摘要" || !r.ShortSummary.Valid {
+					t.Errorf("地點或摘要未覆寫: %q %q", v.Location.String, r.ShortSummary.String)
+				}
+				if string(r.Bites) != `[{"quote":"編輯"}]` || string(r.Keywords) != `[{"keyword":"編輯"}]` {
+					t.Errorf("BITE 或關鍵字未覆寫: %s %s", r.Bites, r.Keywords)
+				}
+				if !r.UpdatedAt.Equal(editTime) {
+					t.Errorf("UpdatedAt = %v, want %v", r.UpdatedAt, editTime)
+				}
+			},
+		},
+		{
+			name:   "無效的修訂欄位沿用原始輸出",
+			review: &AnalysisReview{ShortSummary: sql.NullString{String: "未生效"}, Location: sql.NullString{String: "未生效"}, OverallRating: sql.NullString{String: "S"}},
+			check: func(t *testing.T, v *Video, r *AnalysisResult) {
+				if v.Location.String != "Kyiv" || r.ShortSummary.String != "AI 摘要" || string(r.ImportanceScore) != string(newResult().ImportanceScore) {
+					t.Errorf("不應修改: %+v %+v", v, r)
+				}
+			},
+		},
+		{
+			name:   "評級合併到原有的 importance_score",
+			review: &AnalysisReview{OverallRating: sql.NullString{String: "S", Valid: true}, UpdatedAt: aiTime.Add(-time.Hour)},
+			check: func(t *testing.T, v *Video, r *AnalysisResult) {
+				var score map[string]interface{}
+				if err := json.Unmarshal(r.ImportanceScore, &score); err != nil {
+					t.Fatal(err)
+				}
+				if score["overall_rating"] != "S" || score["assessment_details"] != "說明" || len(score["key_factors"].([]interface{})) != 1 {
+					t.Errorf("importance_score = %s", r.ImportanceScore)
+				}
+				// 修訂時間早於分析結果時不調整 UpdatedAt
+				if !r.UpdatedAt.Equal(aiTime) {
+					t.Errorf("UpdatedAt = %v, want %v", r.UpdatedAt, aiTime)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, r := newVideo(), newResult()
+			tt.review.ApplyTo(v, r)
+			tt.check(t, v, r)
+		})
+	}
+}
+
+func TestAnalysisReviewApplyToRatingWithoutScore(t *testing.T) {
+	review := &AnalysisReview{OverallRating: sql.NullString{String: "A", Valid: true}}
+	for _, raw := range []json.RawMessage{nil, json.RawMessage("null"), json.RawMessage(`"無效"`)} {
+		result := &AnalysisResult{ImportanceScore: raw}
+		review.ApplyTo(nil, result)
+		if string(result.ImportanceScore) != `{"overall_rating":"A"}` {
+			t.Errorf("importance_score %s -> %s", raw, result.ImportanceScore)
+		}
+	}
+}
+
+func TestAnalysisReviewApplyToWithoutResult(t *testing.T) {
+	video := &Video{Location: sql.NullString{String: "Kyiv", Valid: true}}
+	review := &AnalysisReview{Location: sql.NullString{String: "基輔", Valid: true}, ShortSummary: sql.NullString{String: "x", Valid: true}}
+	review.ApplyTo(video, nil)
+	if video.Location.String != "基輔" {
+		t.Errorf("Location = %q", video.Location.String)
+	}
+}
+
+func TestAnalysisReviewHasOverrides(t *testing.T) {
+	tests := []struct {
+		review AnalysisReview
+		want   bool
+	}{
+		{AnalysisReview{Status: ReviewApproved, Note: sql.NullString{String: "備註", Valid: true}}, false},
+		{AnalysisReview{ShortSummary: sql.NullString{String: "x", Valid: true}}, true},
+		{AnalysisReview{Bites: json.RawMessage(`[]`)}, true},
+		{AnalysisReview{Keywords: json.RawMessage(`[{"keyword":"x"}]`)}, true},
+		{AnalysisReview{Location: sql.NullString{String: "x", Valid: true}}, true},
+		{AnalysisReview{OverallRating: sql.NullString{String: "S", Valid: true}}, true},
+	}
+	for i, tt := range tests {
+		if got := tt.review.HasOverrides(); got != tt.want {
+			t.Errorf("#%d HasOverrides() = %v, want %v", i, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// GeminiUsage 對應 gemini_usage 資料表：一次 Gemini GenerateContent 呼叫的 token 用量
+type GeminiUsage struct {
+	ID              int64
+	VideoID         sql.NullInt64
+	SourceName      string
+	Task            PromptKind // text: 文本元數據分析；video: 影片內容分析
+	ModelName       string
+	PromptVersion   string
+	PromptTokens    int64
+	CandidateTokens int64
+	TotalTokens     int64
+	Success         bool
+	CreatedAt       time.Time
+}
+
+// UsageAggregate 依日期、來源、分析類型、模型與 prompt 版本彙總的 token 用量
+type UsageAggregate struct {
+	Day             string // 2006-01-02
+	SourceName      string
+	Task            PromptKind
+	ModelName       string
+	PromptVersion   string
+	Calls           int64
+	FailedCalls     int64
+	PromptTokens    int64
+	CandidateTokens int64
+	TotalTokens     int64
+}
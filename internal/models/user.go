@@ -0,0 +1,63 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// UserRole 使用者角色，權限由低至高為 viewer < editor < admin
+type UserRole string
+
+const (
+	RoleViewer UserRole = "viewer"
+	RoleEditor UserRole = "editor"
+	RoleAdmin  UserRole = "admin"
+)
+
+// AuthProvider 使用者的登入方式
+type AuthProvider string
+
+const (
+	AuthProviderLocal AuthProvider = "local"
+	AuthProviderOIDC  AuthProvider = "oidc"
+)
+
+// User 對應 users 資料表
+type User struct {
+	ID           int64          `json:"id"`
+	Username     string         `json:"username"`
+	DisplayName  sql.NullString `json:"display_name"`
+	Email        sql.NullString `json:"email"`
+	PasswordHash sql.NullString `json:"-"`
+	Role         UserRole       `json:"role"`
+	AuthProvider AuthProvider   `json:"auth_provider"`
+	OIDCSubject  sql.NullString `json:"-"`
+	Enabled      bool           `json:"enabled"`
+	LastLoginAt  sql.NullTime   `json:"last_login_at"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+// Session 對應 sessions 資料表；ID 為 session token 的 SHA-256
+type Session struct {
+	ID        string         `json:"-"`
+	UserID    int64          `json:"user_id"`
+	CSRFToken string         `json:"-"`
+	IPAddress sql.NullString `json:"ip_address"`
+	UserAgent sql.NullString `json:"user_agent"`
+	ExpiresAt time.Time      `json:"expires_at"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// AuditLogEntry 對應 audit_logs 資料表
+type AuditLogEntry struct {
+	ID         int64          `json:"id"`
+	UserID     sql.NullInt64  `json:"user_id"`
+	Username   string         `json:"username"`
+	Action     string         `json:"action"`
+	Target     sql.NullString `json:"target"`
+	StatusCode sql.NullInt64  `json:"status_code"`
+	IPAddress  sql.NullString `json:"ip_address"`
+	Details    sql.NullString `json:"details"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
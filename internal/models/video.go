@@ -17,7 +17,8 @@ const (
 	StatusProcessing          AnalysisStatus = "processing"
 	StatusVideoAnalysisFailed AnalysisStatus = "video_analysis_failed"
 	StatusCompleted           AnalysisStatus = "completed"
-	StatusFailed              AnalysisStatus = "failed" // 通用失敗，可考慮是否保留
+	StatusFailed              AnalysisStatus = "failed"   // 通用失敗，可考慮是否保留
+	StatusArchived            AnalysisStatus = "archived" // 保留政策已刪除部分或全部影片版本 (TXT 與分析結果仍保留)
 )
 
 // VideoFileInfo (保持不變)
@@ -29,6 +30,8 @@ type VideoFileInfo struct {
 	OriginalID        string
 	VideoFileName     string
 	ModTime           time.Time
+	TextFilePaths     []string    // 目錄中所有 TXT 檔案 (依檔名排序)，TextFilePath 為第一個
+	Renditions        []VideoFile // 目錄中所有影片/音訊版本 (尚未連結影片，VideoID 為 0)；VideoAbsolutePath 為其中的 master
 }
 
 // ParsedTxtData 用於存放從 Gemini 分析 .txt 檔案後回傳的 JSON 數據
@@ -62,5 +65,12 @@ type Video struct {
 	AnalysisStatus   AnalysisStatus  `json:"analysis_status"`
 	AnalyzedAt       sql.NullTime    `json:"analyzed_at"`
 	SourceMetadata   json.RawMessage `json:"source_metadata"`
-	PromptVersion    string          `json:"prompt_version"` // 新增：文本 Prompt 版本
+	PromptVersion    string          `json:"prompt_version"`     // 新增：文本 Prompt 版本
+	Pinned           bool            `json:"pinned"`             // 編輯釘選，保留政策不會刪除其檔案 (僅 GetAllVideosWithAnalysis 帶出)
+	ArchiveReason    sql.NullString  `json:"archive_reason"`     // 保留政策刪除檔案的原因 (僅 GetAllVideosWithAnalysis 帶出)
+	PosterPath       sql.NullString  `json:"poster_path"`        // 海報圖片的相對路徑 (僅 GetAllVideosWithAnalysis 與 GetVideoByID 帶出)
+	ContactSheetPath sql.NullString  `json:"contact_sheet_path"` // 關鍵影格縮圖表的相對路徑 (僅 GetAllVideosWithAnalysis 與 GetVideoByID 帶出)
+	ThumbnailsAt     sql.NullTime    `json:"thumbnails_at"`      // 最近一次產生縮圖的時間 (僅 GetAllVideosWithAnalysis 與 GetVideoByID 帶出)
+	WebProxySource   sql.NullString  `json:"web_proxy_source"`   // 產生播放版本的原始檔相對路徑 (僅 GetAllVideosWithAnalysis 帶出)
+	WebProxyPath     sql.NullString  `json:"web_proxy_path"`     // 瀏覽器可播放的 H.264/AAC 版本相對路徑 (僅 GetAllVideosWithAnalysis 帶出)
 }
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// VideoFileRole 影片資料夾中檔案的版本角色
+type VideoFileRole string
+
+const (
+	VideoFileMaster VideoFileRole = "master" // 原始/最高畫質版本
+	VideoFileProxy  VideoFileRole = "proxy"  // 低畫質預覽版本
+	VideoFileAudio  VideoFileRole = "audio"  // 僅音訊
+)
+
+// VideoFile 對應 video_files 資料表：影片資料夾中的一個版本 (rendition)
+type VideoFile struct {
+	ID      int64         `json:"id"`
+	VideoID int64         `json:"video_id"`
+	Path    string        `json:"path"` // 相對於 NAS.VideoPath 的路徑，以 / 分隔
+	Role    VideoFileRole `json:"role"`
+	Width   int           `json:"width,omitempty"`  // 未知時為 0
+	Height  int           `json:"height,omitempty"` // 未知時為 0
+	Size    int64         `json:"size"`
+	ModTime time.Time     `json:"mod_time"`
+}
+
+// Resolution 回傳 "1920x1080" 或 "1080p"；未知時回傳空字串
+func (f VideoFile) Resolution() string {
+	switch {
+	case f.Width > 0 && f.Height > 0:
+		return fmt.Sprintf("%dx%d", f.Width, f.Height)
+	case f.Height > 0:
+		return fmt.Sprintf("%dp", f.Height)
+	}
+	return ""
+}
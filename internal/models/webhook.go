@@ -0,0 +1,32 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// WebhookDeliveryStatus webhook 傳送狀態
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery 對應 webhook_deliveries 資料表
+type WebhookDelivery struct {
+	ID               int64                 `json:"id"`
+	SubscriptionName string                `json:"subscription_name"`
+	VideoID          int64                 `json:"video_id"`
+	Event            string                `json:"event"`
+	Payload          json.RawMessage       `json:"payload"`
+	Status           WebhookDeliveryStatus `json:"status"`
+	Attempts         int                   `json:"attempts"`
+	LastStatusCode   sql.NullInt64         `json:"last_status_code"`
+	LastError        sql.NullString        `json:"last_error"`
+	NextAttemptAt    sql.NullTime          `json:"next_attempt_at"`
+	CreatedAt        time.Time             `json:"created_at"`
+	UpdatedAt        time.Time             `json:"updated_at"`
+}
@@ -0,0 +1,302 @@
+package newsml
+
+import (
+	"AiHackathon-admin/internal/models"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// NewsML-G2 相關常數
+const (
+	namespace       = "http://iptc.org/std/nar/2006-10-01/"
+	standardVersion = "2.33"
+	catalogHref     = "http://www.iptc.org/std/catalog/catalog.IPTC-G2-Standards_38.xml"
+	defaultLang     = "zh-TW"
+)
+
+// Options 控制 NewsML-G2 輸出的內容
+type Options struct {
+	// GUIDPrefix 用於組成 newsItem 的 guid，例如 "urn:newsml:aihackathon-admin"
+	GUIDPrefix string
+	// MediaBaseURL 為影片 rendition 連結的前綴，例如 "https://admin.example.com/media"
+	MediaBaseURL string
+	// MediaQuery 回傳附加於 rendition 連結的查詢字串 (例如 /media/ 網址簽章，不含 ?)；nil 時不附加
+	MediaQuery func(nasPath string) string
+}
+
+type newsItem struct {
+	XMLName         xml.Name    `xml:"newsItem"`
+	Xmlns           string      `xml:"xmlns,attr"`
+	GUID            string      `xml:"guid,attr"`
+	Version         int         `xml:"version,attr"`
+	Standard        string      `xml:"standard,attr"`
+	StandardVersion string      `xml:"standardversion,attr"`
+	Conformance     string      `xml:"conformance,attr"`
+	Lang            string      `xml:"xml:lang,attr"`
+	CatalogRef      catalogRef  `xml:"catalogRef"`
+	RightsInfo      *rightsInfo `xml:"rightsInfo,omitempty"`
+	ItemMeta        itemMeta    `xml:"itemMeta"`
+	ContentMeta     contentMeta `xml:"contentMeta"`
+	ContentSet      *contentSet `xml:"contentSet,omitempty"`
+}
+
+type catalogRef struct {
+	Href string `xml:"href,attr"`
+}
+
+type rightsInfo struct {
+	UsageTerms []langText `xml:"usageTerms"`
+}
+
+type qcodeElem struct {
+	QCode string `xml:"qcode,attr"`
+}
+
+type itemMeta struct {
+	ItemClass      qcodeElem `xml:"itemClass"`
+	Provider       literal   `xml:"provider"`
+	VersionCreated string    `xml:"versionCreated"`
+	PubStatus      qcodeElem `xml:"pubStatus"`
+}
+
+type literal struct {
+	Literal string `xml:"literal,attr"`
+}
+
+type langText struct {
+	Lang string `xml:"xml:lang,attr,omitempty"`
+	Role string `xml:"role,attr,omitempty"`
+	Text string `xml:",chardata"`
+}
+
+type named struct {
+	Name string `xml:"name"`
+}
+
+type subject struct {
+	Type string `xml:"type,attr"`
+	Name string `xml:"name"`
+}
+
+// contentMeta 的欄位順序需符合 NewsML-G2 schema 的元素順序
+type contentMeta struct {
+	Urgency        int        `xml:"urgency,omitempty"`
+	ContentCreated string     `xml:"contentCreated,omitempty"`
+	Located        *named     `xml:"located,omitempty"`
+	Genres         []named    `xml:"genre"`
+	Keywords       []string   `xml:"keyword"`
+	Subjects       []subject  `xml:"subject"`
+	Headline       string     `xml:"headline,omitempty"`
+	Descriptions   []langText `xml:"description"`
+}
+
+type contentSet struct {
+	RemoteContent []remoteContent `xml:"remoteContent"`
+}
+
+type remoteContent struct {
+	Href         string `xml:"href,attr"`
+	ContentType  string `xml:"contenttype,attr,omitempty"`
+	Rendition    string `xml:"rendition,attr"`
+	Duration     int64  `xml:"duration,attr,omitempty"`
+	DurationUnit string `xml:"durationunit,attr,omitempty"`
+}
+
+// videoContentTypes 影片副檔名對應的 MIME 類型 (與 scanVideoFiles 支援的副檔名一致)
+var videoContentTypes = map[string]string{
+	".mp4": "video/mp4",
+	".mov": "video/quicktime",
+	".avi": "video/x-msvideo",
+	".mkv": "video/x-matroska",
+	".ts":  "video/mp2t",
+	".flv": "video/x-flv",
+	".wmv": "video/x-ms-wmv",
+}
+
+// urgencyForRating 將重要性評級 (S/A/B/C/N) 轉換為 IPTC urgency (1 最緊急 ~ 9)
+func urgencyForRating(rating string) int {
+	switch strings.ToUpper(strings.TrimSpace(rating)) {
+	case "S":
+		return 1
+	case "A":
+		return 2
+	case "B":
+		return 4
+	case "C":
+		return 6
+	case "N":
+		return 8
+	default:
+		return 0
+	}
+}
+
+// ItemFileName 回傳單一項目匯出時使用的檔名，例如 "AP12345.xml"
+func ItemFileName(video models.Video) string {
+	name := fmt.Sprintf("%s%s", strings.ToUpper(video.SourceName), video.SourceID)
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' {
+			return '_'
+		}
+		return r
+	}, name)
+	return name + ".xml"
+}
+
+// Render 將影片及其分析結果輸出為 NewsML-G2 newsItem XML。
+// result 可為 nil，此時只輸出影片本身的元數據。
+func Render(w io.Writer, video models.Video, result *models.AnalysisResult, opts Options) error {
+	item := buildItem(video, result, opts)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(item); err != nil {
+		return fmt.Errorf("輸出 NewsML-G2 XML 失敗 (VideoID: %d): %w", video.ID, err)
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func buildItem(video models.Video, result *models.AnalysisResult, opts Options) newsItem {
+	guidPrefix := opts.GUIDPrefix
+	if guidPrefix == "" {
+		guidPrefix = "urn:newsml:aihackathon-admin"
+	}
+	versionCreated := video.FetchedAt
+	if video.AnalyzedAt.Valid {
+		versionCreated = video.AnalyzedAt.Time
+	}
+	if result != nil && !result.UpdatedAt.IsZero() {
+		versionCreated = result.UpdatedAt
+	}
+
+	item := newsItem{
+		Xmlns:           namespace,
+		GUID:            fmt.Sprintf("%s:%s:%s", guidPrefix, strings.ToLower(video.SourceName), video.SourceID),
+		Version:         1,
+		Standard:        "NewsML-G2",
+		StandardVersion: standardVersion,
+		Conformance:     "power",
+		Lang:            defaultLang,
+		CatalogRef:      catalogRef{Href: catalogHref},
+		ItemMeta: itemMeta{
+			ItemClass:      qcodeElem{QCode: "ninat:video"},
+			Provider:       literal{Literal: video.SourceName},
+			VersionCreated: versionCreated.Format(time.RFC3339),
+			PubStatus:      qcodeElem{QCode: "stat:usable"},
+		},
+	}
+
+	var usageTerms []langText
+	if video.Restrictions.Valid && strings.TrimSpace(video.Restrictions.String) != "" {
+		usageTerms = append(usageTerms, langText{Lang: "en", Text: video.Restrictions.String})
+	}
+	if video.TranRestrictions.Valid && strings.TrimSpace(video.TranRestrictions.String) != "" {
+		usageTerms = append(usageTerms, langText{Lang: defaultLang, Text: video.TranRestrictions.String})
+	}
+	if len(usageTerms) > 0 {
+		item.RightsInfo = &rightsInfo{UsageTerms: usageTerms}
+	}
+
+	cm := contentMeta{Headline: video.Title.String}
+	if video.PublishedAt.Valid {
+		cm.ContentCreated = video.PublishedAt.Time.Format(time.RFC3339)
+	}
+	if video.Location.Valid && strings.TrimSpace(video.Location.String) != "" {
+		cm.Located = &named{Name: video.Location.String}
+	}
+	var subjects []string
+	if len(video.Subjects) > 0 && json.Unmarshal(video.Subjects, &subjects) == nil {
+		for _, s := range subjects {
+			if s = strings.TrimSpace(s); s != "" {
+				cm.Genres = append(cm.Genres, named{Name: s})
+			}
+		}
+	}
+
+	if result != nil {
+		var importance models.ImportanceScore
+		if len(result.ImportanceScore) > 0 && json.Unmarshal(result.ImportanceScore, &importance) == nil {
+			cm.Urgency = urgencyForRating(importance.OverallRating)
+		}
+		var keywords []models.Keyword
+		if len(result.Keywords) > 0 && json.Unmarshal(result.Keywords, &keywords) == nil {
+			for _, kw := range keywords {
+				if kw.Keyword = strings.TrimSpace(kw.Keyword); kw.Keyword != "" {
+					cm.Subjects = append(cm.Subjects, subject{Type: "cpnat:abstract", Name: kw.Keyword})
+				}
+			}
+		}
+		var locations []string
+		if len(result.MentionedLocations) > 0 && json.Unmarshal(result.MentionedLocations, &locations) == nil {
+			for _, loc := range locations {
+				if loc = strings.TrimSpace(loc); loc != "" {
+					cm.Subjects = append(cm.Subjects, subject{Type: "cpnat:geoArea", Name: loc})
+				}
+			}
+		}
+		var topics []string
+		if len(result.Topics) > 0 && json.Unmarshal(result.Topics, &topics) == nil {
+			for _, t := range topics {
+				if t = strings.TrimSpace(t); t != "" {
+					cm.Keywords = append(cm.Keywords, t)
+				}
+			}
+		}
+		if result.ShortSummary != nil && result.ShortSummary.Valid && result.ShortSummary.String != "" {
+			cm.Descriptions = append(cm.Descriptions, langText{Role: "drol:summary", Text: result.ShortSummary.String})
+		}
+		if result.BulletedSummary != nil && result.BulletedSummary.Valid && result.BulletedSummary.String != "" {
+			cm.Descriptions = append(cm.Descriptions, langText{Role: "drol:caption", Text: result.BulletedSummary.String})
+		}
+	}
+	if video.ShotlistContent.Valid && video.ShotlistContent.String != "" {
+		cm.Descriptions = append(cm.Descriptions, langText{Lang: "en", Role: "drol:shotlist", Text: video.ShotlistContent.String})
+	}
+	item.ContentMeta = cm
+
+	if video.NASPath != "" {
+		rc := remoteContent{
+			Href:        mediaURL(opts.MediaBaseURL, video.NASPath, opts.MediaQuery),
+			ContentType: videoContentTypes[strings.ToLower(filepath.Ext(video.NASPath))],
+			Rendition:   "rnd:highRes",
+		}
+		if video.DurationSecs.Valid && video.DurationSecs.Int64 > 0 {
+			rc.Duration = video.DurationSecs.Int64
+			rc.DurationUnit = "timeunit:seconds"
+		}
+		item.ContentSet = &contentSet{RemoteContent: []remoteContent{rc}}
+	}
+	return item
+}
+
+// mediaURL 以 MediaBaseURL 與 NAS 相對路徑組出 rendition 連結；query 不為 nil 時附加其查詢字串
+func mediaURL(base, nasPath string, query func(nasPath string) string) string {
+	escaped := make([]string, 0)
+	for _, seg := range strings.Split(filepath.ToSlash(nasPath), "/") {
+		escaped = append(escaped, url.PathEscape(seg))
+	}
+	rel := strings.Join(escaped, "/")
+	u := strings.TrimRight(base, "/") + "/" + rel
+	if base == "" {
+		u = path.Join("/media", rel)
+	}
+	if query != nil {
+		if q := query(filepath.ToSlash(nasPath)); q != "" {
+			u += "?" + q
+		}
+	}
+	return u
+}
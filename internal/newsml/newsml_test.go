@@ -0,0 +1,144 @@
+package newsml
+
+import (
+	"AiHackathon-admin/internal/mediasign"
+	"AiHackathon-admin/internal/models"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 以 go test ./internal/newsml -update 重新產生 testdata/*.xml
+var update = flag.Bool("update", false, "更新 testdata 中的 golden 檔案")
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: true}
+}
+
+func jsonNullString(s string) *models.JsonNullString {
+	return &models.JsonNullString{NullString: nullString(s)}
+}
+
+func sampleVideo() models.Video {
+	taipei := time.FixedZone("CST", 8*3600)
+	return models.Video{
+		ID:               42,
+		SourceName:       "ap",
+		SourceID:         "4521987",
+		NASPath:          "ap/4521987/Typhoon landfall #1.mp4",
+		Title:            nullString("Typhoon makes landfall in eastern Taiwan"),
+		FetchedAt:        time.Date(2024, 9, 2, 1, 0, 0, 0, time.UTC),
+		PublishedAt:      sql.NullTime{Time: time.Date(2024, 9, 2, 8, 30, 0, 0, taipei), Valid: true},
+		DurationSecs:     sql.NullInt64{Int64: 185, Valid: true},
+		ShotlistContent:  models.JsonNullString{NullString: nullString("1. Wide of waves hitting seawall\n2. SOUNDBITE (Mandarin) resident")},
+		Subjects:         json.RawMessage(`["Weather", " ", "Disasters & Accidents"]`),
+		Location:         nullString("Hualien, Taiwan"),
+		Restrictions:     nullString("No access Taiwan"),
+		TranRestrictions: nullString("台灣地區不得使用"),
+		AnalyzedAt:       sql.NullTime{Time: time.Date(2024, 9, 2, 1, 5, 0, 0, time.UTC), Valid: true},
+	}
+}
+
+func sampleResult() *models.AnalysisResult {
+	return &models.AnalysisResult{
+		VideoID:            42,
+		ShortSummary:       jsonNullString("颱風登陸花蓮，強風巨浪襲擊海堤 <現場>"),
+		BulletedSummary:    jsonNullString("- 颱風於上午登陸\n- 居民撤離 & 停班停課"),
+		Keywords:           json.RawMessage(`[{"keyword":"颱風","translation":"typhoon"},{"keyword":" "},{"keyword":"花蓮"}]`),
+		MentionedLocations: json.RawMessage(`["花蓮","台東"]`),
+		Topics:             json.RawMessage(`["天氣","災害"]`),
+		ImportanceScore:    json.RawMessage(`{"overall_rating":"a","key_factors":["傷亡"],"assessment_details":"重大"}`),
+		UpdatedAt:          time.Date(2024, 9, 2, 1, 10, 0, 0, time.UTC),
+	}
+}
+
+func TestRenderGolden(t *testing.T) {
+	minimal := models.Video{
+		ID:         7,
+		SourceName: "reuters",
+		SourceID:   "RW1234",
+		Title:      nullString("Markets open"),
+		FetchedAt:  time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+	}
+	relative := sampleVideo()
+	relative.NASPath = "ap/4521987/clip.mov"
+	relative.Restrictions = sql.NullString{}
+	relative.TranRestrictions = sql.NullString{}
+
+	signer := mediasign.New("0123456789abcdef0123456789abcdef", time.Hour)
+	tests := []struct {
+		name   string
+		video  models.Video
+		result *models.AnalysisResult
+		opts   Options
+	}{
+		{
+			name:   "full",
+			video:  sampleVideo(),
+			result: sampleResult(),
+			opts:   Options{GUIDPrefix: "urn:newsml:example.com", MediaBaseURL: "https://admin.example.com/media/"},
+		},
+		{
+			name:   "signed",
+			video:  sampleVideo(),
+			result: sampleResult(),
+			opts: Options{
+				MediaBaseURL: "https://admin.example.com/media",
+				MediaQuery: func(nasPath string) string {
+					return signer.Query(nasPath, time.Date(2024, 9, 9, 0, 0, 0, 0, time.UTC))
+				},
+			},
+		},
+		{name: "relative_media_url", video: relative, result: nil},
+		{name: "minimal", video: minimal, result: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Render(&buf, tt.video, tt.result, tt.opts); err != nil {
+				t.Fatalf("Render: %v", err)
+			}
+			golden := filepath.Join("testdata", tt.name+".xml")
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("讀取 golden 檔案失敗 (可用 -update 產生): %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("%s 與 golden 檔案不符:\n--- got ---\n%s\n--- want ---\n%s", golden, buf.String(), want)
+			}
+		})
+	}
+}
+
+func TestItemFileName(t *testing.T) {
+	tests := []struct {
+		video models.Video
+		want  string
+	}{
+		{models.Video{SourceName: "ap", SourceID: "4521987"}, "AP4521987.xml"},
+		{models.Video{SourceName: "reuters", SourceID: "tag:reuters.com/2024:x"}, "REUTERStag_reuters.com_2024_x.xml"},
+	}
+	for _, tt := range tests {
+		if got := ItemFileName(tt.video); got != tt.want {
+			t.Errorf("ItemFileName(%s/%s) = %q, want %q", tt.video.SourceName, tt.video.SourceID, got, tt.want)
+		}
+	}
+}
+
+func TestUrgencyForRating(t *testing.T) {
+	for rating, want := range map[string]int{"S": 1, "a": 2, "B": 4, "c": 6, "N": 8, "": 0, "?": 0} {
+		if got := urgencyForRating(rating); got != want {
+			t.Errorf("urgencyForRating(%q) = %d, want %d", rating, got, want)
+		}
+	}
+}
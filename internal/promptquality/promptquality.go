@@ -0,0 +1,247 @@
+// Package promptquality 依編輯審核紀錄計算各 prompt 版本的品質指標：
+// 各欄位修訂率、模型與編輯的重要性評級一致率，以及關鍵字精確率，
+// 作為調整 PromptConfig.CurrentVersion 的依據。
+package promptquality
+
+import (
+	"AiHackathon-admin/internal/models"
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+)
+
+// 欄位名稱 (與 analysis_reviews 欄位一致)
+const (
+	FieldShortSummary  = "short_summary"
+	FieldBites         = "bites"
+	FieldOverallRating = "overall_rating"
+	FieldKeywords      = "keywords"
+	FieldLocation      = "location"
+)
+
+// videoFields 為影片內容分析 prompt 產生的欄位；地點則由文本元數據分析 prompt 產生
+var videoFields = []string{FieldShortSummary, FieldBites, FieldOverallRating, FieldKeywords}
+var textFields = []string{FieldLocation}
+
+// ratingGrades 將評級轉為數值以計算差距
+var ratingGrades = map[string]int{"S": 5, "A": 4, "B": 3, "C": 2, "N": 1}
+
+// FieldStats 單一欄位的修訂統計
+type FieldStats struct {
+	Field     string  `json:"field"`
+	Corrected int     `json:"corrected"`
+	Rate      float64 `json:"rate"`
+}
+
+// VersionMetrics 單一 prompt 版本的品質指標
+type VersionMetrics struct {
+	PromptVersion string       `json:"prompt_version"`
+	IsCurrent     bool         `json:"is_current"`
+	Reviewed      int          `json:"reviewed"`
+	Approved      int          `json:"approved"`
+	Rejected      int          `json:"rejected"`
+	RejectionRate float64      `json:"rejection_rate"`
+	Fields        []FieldStats `json:"fields"`
+
+	// 重要性評級 (僅影片內容分析 prompt)
+	RatingCompared    int     `json:"rating_compared"`
+	RatingAgreement   float64 `json:"rating_agreement"`
+	RatingWithinOne   float64 `json:"rating_within_one"`
+	RatingMeanAbsDiff float64 `json:"rating_mean_abs_diff"`
+
+	// 關鍵字 (僅影片內容分析 prompt)：精確率 = 編輯保留的模型關鍵字 / 模型提出的關鍵字
+	KeywordsProposed int     `json:"keywords_proposed"`
+	KeywordsKept     int     `json:"keywords_kept"`
+	KeywordsAdded    int     `json:"keywords_added"`
+	KeywordPrecision float64 `json:"keyword_precision"`
+
+	ratingAgree, ratingWithinOne, ratingAbsDiff int
+}
+
+// Report 為所有 prompt 版本的品質報表
+type Report struct {
+	Samples int              `json:"samples"`
+	Video   []VersionMetrics `json:"video_analysis"`
+	Text    []VersionMetrics `json:"text_file_analysis"`
+}
+
+// Compute 依審核樣本計算品質報表。
+// prompts 中設定的版本即使尚無審核樣本也會列出。
+func Compute(samples []models.ReviewSample, prompts PromptVersions) Report {
+	video := newAccumulator(videoFields, prompts.VideoCurrent, prompts.VideoVersions)
+	text := newAccumulator(textFields, prompts.TextCurrent, prompts.TextVersions)
+
+	for _, s := range samples {
+		review := s.Review
+
+		vm := video.get(s.VideoPromptVersion)
+		vm.count(review.Status)
+		corrected := map[string]bool{
+			FieldShortSummary:  review.ShortSummary.Valid,
+			FieldBites:         len(review.Bites) > 0,
+			FieldOverallRating: review.OverallRating.Valid,
+			FieldKeywords:      len(review.Keywords) > 0,
+		}
+		for i := range vm.Fields {
+			if corrected[vm.Fields[i].Field] {
+				vm.Fields[i].Corrected++
+			}
+		}
+		if modelRating, ok := ratingGrades[overallRating(s.ImportanceScore)]; ok {
+			// 編輯未修訂評級即視為同意模型評級
+			editorRating := modelRating
+			if review.OverallRating.Valid {
+				if g, ok := ratingGrades[strings.ToUpper(strings.TrimSpace(review.OverallRating.String))]; ok {
+					editorRating = g
+				}
+			}
+			diff := modelRating - editorRating
+			if diff < 0 {
+				diff = -diff
+			}
+			vm.RatingCompared++
+			vm.ratingAbsDiff += diff
+			if diff == 0 {
+				vm.ratingAgree++
+			}
+			if diff <= 1 {
+				vm.ratingWithinOne++
+			}
+		}
+		proposed := keywordSet(s.Keywords)
+		vm.KeywordsProposed += len(proposed)
+		if len(review.Keywords) > 0 {
+			final := keywordSet(review.Keywords)
+			for k := range proposed {
+				if final[k] {
+					vm.KeywordsKept++
+				}
+			}
+			for k := range final {
+				if !proposed[k] {
+					vm.KeywordsAdded++
+				}
+			}
+		} else {
+			vm.KeywordsKept += len(proposed)
+		}
+
+		tm := text.get(s.TextPromptVersion)
+		tm.count(review.Status)
+		if review.Location.Valid {
+			tm.Fields[0].Corrected++
+		}
+	}
+
+	return Report{Samples: len(samples), Video: video.finish(), Text: text.finish()}
+}
+
+// PromptVersions 為設定檔中目前使用與已設定的 prompt 版本
+type PromptVersions struct {
+	VideoCurrent  string
+	VideoVersions []string
+	TextCurrent   string
+	TextVersions  []string
+}
+
+type accumulator struct {
+	fields  []string
+	current string
+	byName  map[string]*VersionMetrics
+}
+
+func newAccumulator(fields []string, current string, configured []string) *accumulator {
+	a := &accumulator{fields: fields, current: current, byName: make(map[string]*VersionMetrics)}
+	for _, v := range configured {
+		a.get(v)
+	}
+	if current != "" {
+		a.get(current)
+	}
+	return a
+}
+
+func (a *accumulator) get(version string) *VersionMetrics {
+	if version == "" {
+		version = "(未知)"
+	}
+	m, ok := a.byName[version]
+	if !ok {
+		m = &VersionMetrics{PromptVersion: version, IsCurrent: version == a.current}
+		for _, f := range a.fields {
+			m.Fields = append(m.Fields, FieldStats{Field: f})
+		}
+		a.byName[version] = m
+	}
+	return m
+}
+
+func (m *VersionMetrics) count(status models.ReviewStatus) {
+	m.Reviewed++
+	switch status {
+	case models.ReviewApproved:
+		m.Approved++
+	case models.ReviewRejected:
+		m.Rejected++
+	}
+}
+
+// finish 計算比率並依版本名稱排序 (目前使用的版本排在最前)
+func (a *accumulator) finish() []VersionMetrics {
+	out := make([]VersionMetrics, 0, len(a.byName))
+	for _, m := range a.byName {
+		if m.Reviewed > 0 {
+			m.RejectionRate = ratio(m.Rejected, m.Reviewed)
+			for i := range m.Fields {
+				m.Fields[i].Rate = ratio(m.Fields[i].Corrected, m.Reviewed)
+			}
+		}
+		if m.RatingCompared > 0 {
+			m.RatingAgreement = ratio(m.ratingAgree, m.RatingCompared)
+			m.RatingWithinOne = ratio(m.ratingWithinOne, m.RatingCompared)
+			m.RatingMeanAbsDiff = ratio(m.ratingAbsDiff, m.RatingCompared)
+		}
+		if m.KeywordsProposed > 0 {
+			m.KeywordPrecision = ratio(m.KeywordsKept, m.KeywordsProposed)
+		}
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].IsCurrent != out[j].IsCurrent {
+			return out[i].IsCurrent
+		}
+		return out[i].PromptVersion > out[j].PromptVersion
+	})
+	return out
+}
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return math.Round(float64(n)/float64(d)*1000) / 1000
+}
+
+func overallRating(importanceScore json.RawMessage) string {
+	var score models.ImportanceScore
+	if len(importanceScore) == 0 || json.Unmarshal(importanceScore, &score) != nil {
+		return ""
+	}
+	return strings.ToUpper(strings.TrimSpace(score.OverallRating))
+}
+
+// keywordSet 以小寫關鍵字文字作為比對鍵 (類別不同但文字相同視為同一關鍵字)
+func keywordSet(raw json.RawMessage) map[string]bool {
+	set := make(map[string]bool)
+	var items []models.Keyword
+	if len(raw) == 0 || json.Unmarshal(raw, &items) != nil {
+		return set
+	}
+	for _, item := range items {
+		if k := strings.ToLower(strings.TrimSpace(item.Keyword)); k != "" {
+			set[k] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,165 @@
+package promptquality
+
+import (
+	"AiHackathon-admin/internal/models"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func valid(s string) sql.NullString { return sql.NullString{String: s, Valid: true} }
+
+func reviewSamples() []models.ReviewSample {
+	return []models.ReviewSample{
+		{
+			// 核准且未修訂：評級一致，模型關鍵字全部保留
+			VideoID: 1, VideoPromptVersion: "v7", TextPromptVersion: "v3",
+			ImportanceScore: json.RawMessage(`{"overall_rating":"A"}`),
+			Keywords:        json.RawMessage(`[{"keyword":"烏克蘭"},{"keyword":"Kyiv"}]`),
+			Review:          models.AnalysisReview{Status: models.ReviewApproved},
+		},
+		{
+			// 退回並修訂摘要、評級 (A -> C) 與關鍵字 (保留 1 個、新增 1 個)，地點亦被修訂
+			VideoID: 2, VideoPromptVersion: "v7", TextPromptVersion: "v3",
+			ImportanceScore: json.RawMessage(`{"overall_rating":" a "}`),
+			Keywords:        json.RawMessage(`[{"keyword":"烏克蘭"},{"keyword":"Kyiv"}]`),
+			Review: models.AnalysisReview{
+				Status:        models.ReviewRejected,
+				ShortSummary:  valid("編輯摘要"),
+				OverallRating: valid("c"),
+				Keywords:      json.RawMessage(`[{"keyword":"KYIV","category":"地點"},{"keyword":"澤倫斯基"}]`),
+				Location:      valid("基輔"),
+			},
+		},
+		{
+			// 待審核：模型沒有評級，不列入評級比較
+			VideoID: 3, VideoPromptVersion: "v7", TextPromptVersion: "v3",
+			ImportanceScore: json.RawMessage(`{"key_factors":[]}`),
+			Review:          models.AnalysisReview{Status: models.ReviewPending, Bites: json.RawMessage(`[{"quote":"編輯"}]`)},
+		},
+		{
+			// 舊版 prompt：評級差一級 (B -> A)，模型關鍵字全部被刪除；文本 prompt 版本未知
+			VideoID: 4, VideoPromptVersion: "v6",
+			ImportanceScore: json.RawMessage(`{"overall_rating":"B"}`),
+			Keywords:        json.RawMessage(`[{"keyword":"x"}]`),
+			Review:          models.AnalysisReview{Status: models.ReviewApproved, OverallRating: valid("A"), Keywords: json.RawMessage(`[]`)},
+		},
+	}
+}
+
+// withoutCounters 清除計算比率用的內部計數，只比較匯出的欄位
+func withoutCounters(metrics []VersionMetrics) []VersionMetrics {
+	for i := range metrics {
+		metrics[i].ratingAgree, metrics[i].ratingWithinOne, metrics[i].ratingAbsDiff = 0, 0, 0
+	}
+	return metrics
+}
+
+func videoFieldStats(summary, bites, rating, keywords int, reviewed int) []FieldStats {
+	return []FieldStats{
+		{Field: FieldShortSummary, Corrected: summary, Rate: ratio(summary, reviewed)},
+		{Field: FieldBites, Corrected: bites, Rate: ratio(bites, reviewed)},
+		{Field: FieldOverallRating, Corrected: rating, Rate: ratio(rating, reviewed)},
+		{Field: FieldKeywords, Corrected: keywords, Rate: ratio(keywords, reviewed)},
+	}
+}
+
+func TestCompute(t *testing.T) {
+	report := Compute(reviewSamples(), PromptVersions{
+		VideoCurrent: "v7", VideoVersions: []string{"v5", "v6", "v7"},
+		TextCurrent: "v3", TextVersions: []string{"v2", "v3"},
+	})
+	if report.Samples != 4 {
+		t.Errorf("Samples = %d", report.Samples)
+	}
+
+	wantVideo := []VersionMetrics{
+		{
+			PromptVersion: "v7", IsCurrent: true, Reviewed: 3, Approved: 1, Rejected: 1, RejectionRate: 0.333,
+			Fields:         videoFieldStats(1, 1, 1, 1, 3),
+			RatingCompared: 2, RatingAgreement: 0.5, RatingWithinOne: 0.5, RatingMeanAbsDiff: 1,
+			KeywordsProposed: 4, KeywordsKept: 3, KeywordsAdded: 1, KeywordPrecision: 0.75,
+		},
+		{
+			PromptVersion: "v6", Reviewed: 1, Approved: 1,
+			Fields:         videoFieldStats(0, 0, 1, 1, 1),
+			RatingCompared: 1, RatingAgreement: 0, RatingWithinOne: 1, RatingMeanAbsDiff: 1,
+			KeywordsProposed: 1,
+		},
+		{PromptVersion: "v5", Fields: videoFieldStats(0, 0, 0, 0, 0)},
+	}
+	if got := withoutCounters(report.Video); !reflect.DeepEqual(got, wantVideo) {
+		t.Errorf("Video =\n%+v\nwant\n%+v", got, wantVideo)
+	}
+
+	wantText := []VersionMetrics{
+		{PromptVersion: "v3", IsCurrent: true, Reviewed: 3, Approved: 1, Rejected: 1, RejectionRate: 0.333, Fields: []FieldStats{{Field: FieldLocation, Corrected: 1, Rate: 0.333}}},
+		{PromptVersion: "v2", Fields: []FieldStats{{Field: FieldLocation}}},
+		{PromptVersion: "(未知)", Reviewed: 1, Approved: 1, Fields: []FieldStats{{Field: FieldLocation}}},
+	}
+	if got := withoutCounters(report.Text); !reflect.DeepEqual(got, wantText) {
+		t.Errorf("Text =\n%+v\nwant\n%+v", got, wantText)
+	}
+}
+
+func TestComputeWithoutSamples(t *testing.T) {
+	report := Compute(nil, PromptVersions{VideoCurrent: "v7"})
+	if report.Samples != 0 || len(report.Text) != 0 {
+		t.Errorf("report = %+v", report)
+	}
+	if len(report.Video) != 1 || report.Video[0].PromptVersion != "v7" || !report.Video[0].IsCurrent || report.Video[0].RejectionRate != 0 {
+		t.Errorf("尚無樣本時仍應列出目前使用的版本: %+v", report.Video)
+	}
+}
+
+func TestComputeIgnoresInvalidRatings(t *testing.T) {
+	samples := []models.ReviewSample{
+		// 模型評級無效：不列入比較
+		{VideoPromptVersion: "v7", ImportanceScore: json.RawMessage(`{"overall_rating":"極高"}`)},
+		{VideoPromptVersion: "v7", ImportanceScore: json.RawMessage(`not json`)},
+		// 編輯評級無效：視為同意模型評級
+		{VideoPromptVersion: "v7", ImportanceScore: json.RawMessage(`{"overall_rating":"S"}`), Review: models.AnalysisReview{OverallRating: valid("?")}},
+	}
+	m := Compute(samples, PromptVersions{}).Video[0]
+	if m.RatingCompared != 1 || m.RatingAgreement != 1 || m.RatingMeanAbsDiff != 0 {
+		t.Errorf("評級比較 = %d / %v / %v", m.RatingCompared, m.RatingAgreement, m.RatingMeanAbsDiff)
+	}
+	if m.Fields[2].Corrected != 1 {
+		t.Errorf("評級修訂次數 = %d", m.Fields[2].Corrected)
+	}
+}
+
+func TestKeywordSet(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want map[string]bool
+	}{
+		{`[{"keyword":" Kyiv ","category":"地點"},{"keyword":"kyiv","category":"城市"},{"keyword":""},{"keyword":"烏克蘭"}]`, map[string]bool{"kyiv": true, "烏克蘭": true}},
+		{``, map[string]bool{}},
+		{`null`, map[string]bool{}},
+		{`{"keyword":"x"}`, map[string]bool{}},
+	}
+	for _, tt := range tests {
+		if got := keywordSet(json.RawMessage(tt.raw)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("keywordSet(%s) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestRatio(t *testing.T) {
+	tests := []struct {
+		n, d int
+		want float64
+	}{
+		{0, 0, 0},
+		{1, 3, 0.333},
+		{2, 3, 0.667},
+		{3, 3, 1},
+	}
+	for _, tt := range tests {
+		if got := ratio(tt.n, tt.d); got != tt.want {
+			t.Errorf("ratio(%d, %d) = %v, want %v", tt.n, tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Package prompttpl 將 prompt 檔案視為 Go text/template，依每部影片的元數據填入佔位符，
+// 例如 {{.Title}}、{{.Shotlist}}、{{.Location}}、{{.Source}}。
+// 不含佔位符的 prompt 會原樣送出，與舊版 prompt 檔案相容。
+package prompttpl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Data 為 prompt 中可使用的變數
+type Data struct {
+	Title        string   // 標題
+	Shotlist     string   // SHOTLIST 內容
+	Location     string   // 主要地點 (文本元數據分析結果)
+	Subjects     []string // 主題
+	Source       string   // 來源 (例如 AP、REUTERS)
+	SourceID     string   // 來源端的影片 ID
+	FileName     string   // 影片檔名
+	PublishedAt  string   // 發布時間 (2006-01-02 15:04:05)，未知時為空字串
+	DurationSecs int64    // 影片長度 (秒)，未知時為 0
+}
+
+// sampleData 用於載入時驗證 prompt：每個欄位皆有值，讓條件區塊也會被執行到
+var sampleData = Data{
+	Title:        "範例標題",
+	Shotlist:     "1. 範例畫面",
+	Location:     "台北",
+	Subjects:     []string{"政治"},
+	Source:       "AP",
+	SourceID:     "0000000",
+	FileName:     "0000000.mp4",
+	PublishedAt:  time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC).Format("2006-01-02 15:04:05"),
+	DurationSecs: 60,
+}
+
+var funcs = template.FuncMap{
+	// join 以指定分隔字串串接主題等字串陣列，例如 {{join .Subjects "、"}}
+	"join": func(items []string, sep string) string { return strings.Join(items, sep) },
+}
+
+func parse(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+}
+
+// Validate 檢查 prompt 的佔位符語法與變數名稱是否正確 (於載入或儲存 prompt 時呼叫)
+func Validate(name, text string) error {
+	if !strings.Contains(text, "{{") {
+		return nil
+	}
+	tpl, err := parse(name, text)
+	if err != nil {
+		return fmt.Errorf("prompt '%s' 範本語法錯誤: %w", name, err)
+	}
+	if err := tpl.Execute(&bytes.Buffer{}, sampleData); err != nil {
+		return fmt.Errorf("prompt '%s' 使用了不存在的變數或無效的運算: %w", name, err)
+	}
+	return nil
+}
+
+// Render 以 data 填入 prompt 中的佔位符
+func Render(name, text string, data Data) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tpl, err := parse(name, text)
+	if err != nil {
+		return "", fmt.Errorf("prompt '%s' 範本語法錯誤: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("套用 prompt '%s' 失敗: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// Hash 回傳實際送出之 prompt 的 SHA-256 (hex)，與分析結果一併保存以利重現
+func Hash(rendered string) string {
+	sum := sha256.Sum256([]byte(rendered))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,169 @@
+package prompttpl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		wantErr string
+	}{
+		{name: "沒有佔位符", text: "請分析影片，並回傳 JSON：{\"transcript\": \"\"}"},
+		{name: "有效的佔位符", text: "標題：{{.Title}}\n{{if .Subjects}}主題：{{join .Subjects \"、\"}}{{end}}\n長度：{{.DurationSecs}}"},
+		{name: "條件區塊中的變數", text: "{{if .Shotlist}}{{.Shotlist}}{{else}}無{{end}}{{range .Subjects}}{{.}}{{end}}"},
+		{name: "語法錯誤", text: "標題：{{.Title", wantErr: "範本語法錯誤"},
+		{name: "未結束的區塊", text: "{{if .Title}}標題", wantErr: "範本語法錯誤"},
+		{name: "不存在的函式", text: "{{upper .Title}}", wantErr: "範本語法錯誤"},
+		{name: "不存在的欄位", text: "標題：{{.Headline}}", wantErr: "不存在的變數"},
+		{name: "函式參數型別錯誤", text: "{{join .Title \"、\"}}", wantErr: "不存在的變數"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate("v-test", tt.text)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() 應通過，got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !strings.Contains(err.Error(), "v-test") {
+				t.Errorf("Validate() error = %v, want 包含 %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	text := "來源：{{.Source}} {{.SourceID}} ({{.FileName}})\n" +
+		"{{- if .Title}}\n標題：{{.Title}}{{end}}\n" +
+		"{{- if .PublishedAt}}\n發布時間：{{.PublishedAt}}{{end}}\n" +
+		"{{- if .DurationSecs}}\n時長：{{.DurationSecs}} 秒{{end}}\n" +
+		"{{- if .Subjects}}\n主題：{{join .Subjects \"、\"}}{{end}}"
+	tests := []struct {
+		name string
+		data Data
+		want string
+	}{
+		{
+			name: "完整元數據",
+			data: Data{
+				Title: "Taiwan election", Source: "AP", SourceID: "4512345", FileName: "4512345.mp4",
+				Subjects: []string{"政治", "選舉"}, PublishedAt: "2025-05-01 12:30:00", DurationSecs: 95,
+			},
+			want: "來源：AP 4512345 (4512345.mp4)\n標題：Taiwan election\n發布時間：2025-05-01 12:30:00\n時長：95 秒\n主題：政治、選舉",
+		},
+		{
+			name: "未知的欄位省略",
+			data: Data{Source: "REUTERS", SourceID: "r1", FileName: "r1.mp4"},
+			want: "來源：REUTERS r1 (r1.mp4)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render("v-test", text, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Render() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderWithoutPlaceholders(t *testing.T) {
+	// 舊版 prompt 含有 JSON 大括號，不含佔位符時須原樣送出
+	text := "請回傳 {\"a\": {\"b\": \"{口白}\"}}\n"
+	got, err := Render("v5", text, Data{Title: "x"})
+	if err != nil || got != text {
+		t.Errorf("Render() = %q, %v, want 原樣回傳", got, err)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	if _, err := Render("v-test", "{{.Title", Data{}); err == nil || !strings.Contains(err.Error(), "範本語法錯誤") {
+		t.Errorf("語法錯誤時應回傳錯誤，got %v", err)
+	}
+	if _, err := Render("v-test", "{{.Headline}}", Data{}); err == nil || !strings.Contains(err.Error(), "套用 prompt 'v-test' 失敗") {
+		t.Errorf("不存在的欄位應回傳錯誤，got %v", err)
+	}
+}
+
+func TestHash(t *testing.T) {
+	// echo -n "" | sha256sum
+	if got := Hash(""); got != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Errorf("Hash(\"\") = %s", got)
+	}
+	if Hash("標題：A") == Hash("標題：B") {
+		t.Error("不同的 prompt 應有不同的雜湊")
+	}
+}
+
+// TestRepoPrompts 確認 configs/prompts 中的每個 prompt 檔案都能通過載入時的驗證
+func TestRepoPrompts(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "configs", "prompts", "*", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("找不到 configs/prompts 中的 prompt 檔案")
+	}
+	for _, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Validate(path, string(content)); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+}
+
+func TestVideoPromptV8Render(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("..", "..", "configs", "prompts", "video_analysis", "v8.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := Data{
+		Title:        "UKRAINE ZELENSKYY VISIT",
+		Shotlist:     "1. Wide of Zelenskyy arriving\n2. SOUNDBITE (Ukrainian) Volodymyr Zelenskyy",
+		Location:     "基輔",
+		Subjects:     []string{"戰爭", "外交"},
+		Source:       "AP",
+		SourceID:     "4567890",
+		FileName:     "4567890.mp4",
+		PublishedAt:  "2025-05-01 12:30:00",
+		DurationSecs: 125,
+	}
+	got, err := Render("v8", string(content), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"- 來源：AP（4567890）\n- 標題：UKRAINE ZELENSKYY VISIT\n",
+		"- 發布時間：2025-05-01 12:30:00\n- 時長：125 秒\n- 主要地點：基輔\n- 主題：戰爭、外交\n",
+		"- SHOTLIST：\n1. Wide of Zelenskyy arriving\n2. SOUNDBITE (Ukrainian) Volodymyr Zelenskyy\n\n請您專注於分析影片",
+		"「角色：{口白}」", // JSON 結構說明中的單一大括號不受影響
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("v8 套用結果缺少 %q", want)
+		}
+	}
+	if strings.Contains(got, "{{") {
+		t.Error("v8 套用結果仍有未替換的佔位符")
+	}
+
+	// 只有來源資訊時省略其他元數據行
+	got, err = Render("v8", string(content), Data{Source: "REUTERS", SourceID: "r1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "- 來源：REUTERS（r1）\n\n請您專注於分析影片") || strings.Contains(got, "標題：") {
+		t.Errorf("v8 未知元數據的套用結果 =\n%s", got[:strings.Index(got, "```json")])
+	}
+}
@@ -0,0 +1,231 @@
+package renditions
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/models"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TXT 檔案的處理方式 (nas.renditions.textMode)
+const (
+	TextConcat  = "concat"  // 依檔名排序後全部合併
+	TextFirst   = "first"   // 使用檔名排序第一個
+	TextLargest = "largest" // 使用內容最長者
+)
+
+// Rules 判斷與選擇版本的規則
+type Rules struct {
+	ProxyPatterns     []string             // 檔名 (不分大小寫) 包含任一字串時視為 proxy
+	MinAnalysisHeight int                  // 送交分析的版本最低高度；解析度未知的版本視為可接受
+	PreferStream      models.VideoFileRole // 儀表板播放優先使用的角色 (proxy 或 master)
+}
+
+// RulesFromConfig 由 nas.renditions 設定建立規則
+func RulesFromConfig(c config.NASRenditionsConfig) Rules {
+	return Rules{ProxyPatterns: c.ProxyPatterns, MinAnalysisHeight: c.MinAnalysisHeight, PreferStream: models.VideoFileRole(c.PreferStream)}
+}
+
+// AudioExtensions 視為音訊版本的副檔名
+var AudioExtensions = map[string]bool{
+	".mp3": true, ".wav": true, ".m4a": true, ".aac": true, ".flac": true,
+}
+
+var (
+	sizePattern   = regexp.MustCompile(`(?:^|[^0-9])(\d{3,4})x(\d{3,4})(?:[^0-9]|$)`)
+	heightPattern = regexp.MustCompile(`(?:^|[^0-9a-z])(\d{3,4})[pi](?:[^0-9a-z]|$)`)
+	uhdPattern    = regexp.MustCompile(`(?:^|[^0-9a-z])(4k|uhd)(?:[^0-9a-z]|$)`)
+)
+
+// ParseResolution 由檔名取得解析度 (例如 1920x1080、720p、4k)；無法判斷時回傳 0
+func ParseResolution(name string) (width, height int) {
+	lower := strings.ToLower(name)
+	if m := sizePattern.FindStringSubmatch(lower); m != nil {
+		width, _ = strconv.Atoi(m[1])
+		height, _ = strconv.Atoi(m[2])
+		return width, height
+	}
+	if m := heightPattern.FindStringSubmatch(lower); m != nil {
+		height, _ = strconv.Atoi(m[1])
+		return 0, height
+	}
+	if uhdPattern.MatchString(lower) {
+		return 3840, 2160
+	}
+	return 0, 0
+}
+
+// Classify 判斷各檔案的角色與解析度：音訊副檔名為 audio，檔名符合 ProxyPatterns 為 proxy；
+// 其餘影片檔中畫質最高 (高度相同時檔案最大) 者為 master，其他降為 proxy。
+// 已有解析度的檔案 (例如由 ffprobe 取得) 保留原值。回傳依 master、proxy (畫質高至低)、audio 排序
+func Classify(files []models.VideoFile, rules Rules) []models.VideoFile {
+	out := make([]models.VideoFile, len(files))
+	copy(out, files)
+	var masters []int
+	for i := range out {
+		f := &out[i]
+		name := strings.ToLower(path.Base(f.Path))
+		if f.Width == 0 && f.Height == 0 {
+			f.Width, f.Height = ParseResolution(name)
+		}
+		switch {
+		case AudioExtensions[strings.ToLower(path.Ext(name))]:
+			f.Role = models.VideoFileAudio
+		case matchesAny(name, rules.ProxyPatterns):
+			f.Role = models.VideoFileProxy
+		default:
+			f.Role = models.VideoFileMaster
+			masters = append(masters, i)
+		}
+	}
+	if len(masters) > 1 {
+		best := masters[0]
+		for _, i := range masters[1:] {
+			if better(out[i], out[best]) {
+				best = i
+			}
+		}
+		for _, i := range masters {
+			if i != best {
+				out[i].Role = models.VideoFileProxy
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if ri, rj := roleOrder(out[i].Role), roleOrder(out[j].Role); ri != rj {
+			return ri < rj
+		}
+		return better(out[i], out[j])
+	})
+	return out
+}
+
+// ForAnalysis 選擇送交分析的版本：高度達 MinAnalysisHeight (或解析度未知) 的影片版本中檔案最小者；
+// 都不符合時使用畫質最高的版本
+func ForAnalysis(files []models.VideoFile, rules Rules) (models.VideoFile, bool) {
+	var chosen, fallback *models.VideoFile
+	for i := range files {
+		f := &files[i]
+		if f.Role == models.VideoFileAudio {
+			continue
+		}
+		if fallback == nil || better(*f, *fallback) {
+			fallback = f
+		}
+		if f.Height > 0 && f.Height < rules.MinAnalysisHeight {
+			continue
+		}
+		if chosen == nil || f.Size < chosen.Size {
+			chosen = f
+		}
+	}
+	if chosen == nil {
+		chosen = fallback
+	}
+	if chosen == nil {
+		return models.VideoFile{}, false
+	}
+	return *chosen, true
+}
+
+// ForStream 選擇儀表板播放的版本：優先使用 PreferStream 角色中畫質最高者，沒有時使用其他影片版本
+func ForStream(files []models.VideoFile, rules Rules) (models.VideoFile, bool) {
+	var preferred, other *models.VideoFile
+	for i := range files {
+		f := &files[i]
+		switch {
+		case f.Role == models.VideoFileAudio:
+			continue
+		case f.Role == rules.PreferStream:
+			if preferred == nil || better(*f, *preferred) {
+				preferred = f
+			}
+		default:
+			if other == nil || better(*f, *other) {
+				other = f
+			}
+		}
+	}
+	switch {
+	case preferred != nil:
+		return *preferred, true
+	case other != nil:
+		return *other, true
+	}
+	return models.VideoFile{}, false
+}
+
+// ReadTexts 依 mode 讀取同一項目的 TXT 檔案；paths 需依檔名排序。
+// concat 模式下多個檔案以 "===== 檔名 =====" 分隔，只有一個檔案時直接回傳其內容
+func ReadTexts(paths []string, mode string) (string, error) {
+	if len(paths) == 0 {
+		return "", fmt.Errorf("沒有 TXT 檔案")
+	}
+	if mode == TextFirst {
+		paths = paths[:1]
+	}
+	contents := make([]string, len(paths))
+	for i, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return "", fmt.Errorf("無法讀取 TXT 檔案 '%s': %w", p, err)
+		}
+		contents[i] = string(b)
+	}
+	switch {
+	case len(contents) == 1:
+		return contents[0], nil
+	case mode == TextLargest:
+		largest := 0
+		for i := range contents {
+			if len(contents[i]) > len(contents[largest]) {
+				largest = i
+			}
+		}
+		return contents[largest], nil
+	}
+	var sb strings.Builder
+	for i, c := range contents {
+		if strings.TrimSpace(c) == "" {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\n\n")
+		}
+		fmt.Fprintf(&sb, "===== %s =====\n%s", filepath.Base(paths[i]), strings.TrimRight(c, "\n"))
+	}
+	return sb.String(), nil
+}
+
+// better 回傳 a 的畫質是否高於 b (高度相同時比較檔案大小)
+func better(a, b models.VideoFile) bool {
+	if a.Height != b.Height {
+		return a.Height > b.Height
+	}
+	return a.Size > b.Size
+}
+
+func roleOrder(role models.VideoFileRole) int {
+	switch role {
+	case models.VideoFileMaster:
+		return 0
+	case models.VideoFileProxy:
+		return 1
+	}
+	return 2
+}
+
+func matchesAny(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if p != "" && strings.Contains(name, strings.ToLower(p)) {
+			return true
+		}
+	}
+	return false
+}
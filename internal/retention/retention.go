@@ -0,0 +1,94 @@
+package retention
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/models"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Action 對單一影片要刪除的檔案
+type Action struct {
+	VideoID    int64              `json:"video_id"`
+	SourceName string             `json:"source_name"`
+	SourceID   string             `json:"source_id"`
+	Rating     string             `json:"rating"`
+	FetchedAt  time.Time          `json:"fetched_at"`
+	Rule       string             `json:"rule"` // 第一個符合的規則名稱
+	Files      []models.VideoFile `json:"files"`
+	Bytes      int64              `json:"bytes"`
+}
+
+// Report 保留政策評估結果；Protected 為因編輯釘選而略過的影片
+type Report struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	DryRun      bool      `json:"dry_run"`
+	Actions     []Action  `json:"actions"`
+	Protected   []Action  `json:"protected"`
+	TotalFiles  int       `json:"total_files"`
+	TotalBytes  int64     `json:"total_bytes"`
+}
+
+// Plan 依規則找出各影片已超過保留期限的檔案。每個檔案以第一個符合 (評級、版本且已超過天數) 的規則為準；
+// 一部影片符合多個規則時，Action.Rule 為其中第一個檔案的規則
+func Plan(items []models.RetentionItem, rules []config.RetentionRule, now time.Time) Report {
+	report := Report{GeneratedAt: now}
+	for _, item := range items {
+		action := Action{VideoID: item.VideoID, SourceName: item.SourceName, SourceID: item.SourceID, Rating: item.Rating, FetchedAt: item.FetchedAt}
+		for _, f := range item.Files {
+			rule, ok := matchRule(rules, item, f, now)
+			if !ok {
+				continue
+			}
+			if action.Rule == "" {
+				action.Rule = rule
+			}
+			action.Files = append(action.Files, f)
+			action.Bytes += f.Size
+		}
+		if len(action.Files) == 0 {
+			continue
+		}
+		if item.Pinned {
+			report.Protected = append(report.Protected, action)
+			continue
+		}
+		report.Actions = append(report.Actions, action)
+		report.TotalFiles += len(action.Files)
+		report.TotalBytes += action.Bytes
+	}
+	return report
+}
+
+func matchRule(rules []config.RetentionRule, item models.RetentionItem, f models.VideoFile, now time.Time) (string, bool) {
+	for i, rule := range rules {
+		if now.Sub(item.FetchedAt) < time.Duration(rule.AfterDays)*24*time.Hour {
+			continue
+		}
+		if len(rule.Ratings) > 0 && !containsFold(rule.Ratings, item.Rating) {
+			continue
+		}
+		if len(rule.Roles) > 0 && !containsFold(rule.Roles, string(f.Role)) {
+			continue
+		}
+		if rule.Name != "" {
+			return rule.Name, true
+		}
+		return fmt.Sprintf("rules[%d]", i), true
+	}
+	return "", false
+}
+
+func containsFold(list []string, value string) bool {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return false
+	}
+	for _, item := range list {
+		if strings.EqualFold(strings.TrimSpace(item), value) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,11 +3,13 @@ package scheduler
 import (
 	"AiHackathon-admin/internal/services"
 	"log"
+	"sync"
 )
 
 // FetchJob 是一個排程任務，用於執行影片擷取
 type FetchJob struct {
 	fetchService *services.FetchService
+	running      sync.Mutex // 避免前一次執行尚未結束時重複執行 (例如重新排程後立即觸發)
 }
 
 // NewFetchJob 建立一個 FetchJob
@@ -17,6 +19,11 @@ func NewFetchJob(fs *services.FetchService) *FetchJob {
 
 // Run 實現 cron.Job 介面 (github.com/robfig/cron/v3)
 func (j *FetchJob) Run() {
+	if !j.running.TryLock() {
+		log.Println("警告：上一次影片擷取排程任務仍在執行，略過本次執行。")
+		return
+	}
+	defer j.running.Unlock()
 	log.Println("資訊：執行排程任務 - 影片擷取...")
 	if err := j.fetchService.Run(); err != nil {
 		log.Printf("錯誤：影片擷取排程任務執行失敗: %v", err)
@@ -28,15 +35,31 @@ func (j *FetchJob) Run() {
 // AnalyzeJob 是一個排程任務，用於執行影片分析
 type AnalyzeJob struct {
 	analyzeService *services.AnalyzeService
+	costService    *services.CostService // 為 nil 時不檢查每月預算
+	running        sync.Mutex
 }
 
 // NewAnalyzeJob 建立一個 AnalyzeJob
-func NewAnalyzeJob(as *services.AnalyzeService) *AnalyzeJob {
-	return &AnalyzeJob{analyzeService: as}
+func NewAnalyzeJob(as *services.AnalyzeService, cs *services.CostService) *AnalyzeJob {
+	return &AnalyzeJob{analyzeService: as, costService: cs}
 }
 
 // Run 實現 cron.Job 介面
 func (j *AnalyzeJob) Run() {
+	if !j.running.TryLock() {
+		log.Println("警告：上一次影片分析排程任務仍在執行，略過本次執行。")
+		return
+	}
+	defer j.running.Unlock()
+	if j.costService != nil {
+		status, err := j.costService.CheckBudget()
+		if err != nil {
+			log.Printf("警告：無法檢查本月 Gemini 預算，仍執行影片分析: %v", err)
+		} else if status.Exceeded {
+			log.Printf("警告：本月 (%s) Gemini 花費 %.2f 已達預算 %.2f，暫停影片分析排程任務。", status.Month, status.Spent, status.Budget)
+			return
+		}
+	}
 	log.Println("資訊：執行排程任務 - 影片分析...")
 	if err := j.analyzeService.Run(); err != nil {
 		log.Printf("錯誤：影片分析排程任務執行失敗: %v", err)
@@ -44,3 +67,57 @@ func (j *AnalyzeJob) Run() {
 		log.Println("資訊：影片分析排程任務執行完成。")
 	}
 }
+
+// RetentionJob 是一個排程任務，依保留政策清理 NAS 上的影片版本
+type RetentionJob struct {
+	retentionService *services.RetentionService
+}
+
+// NewRetentionJob 建立一個 RetentionJob
+func NewRetentionJob(rs *services.RetentionService) *RetentionJob {
+	return &RetentionJob{retentionService: rs}
+}
+
+// Run 實現 cron.Job 介面；重複執行由 RetentionService 本身避免
+func (j *RetentionJob) Run() {
+	log.Println("資訊：執行排程任務 - NAS 保留政策清理...")
+	if err := j.retentionService.Run(); err != nil {
+		log.Printf("錯誤：NAS 保留政策清理排程任務執行失敗: %v", err)
+	} else {
+		log.Println("資訊：NAS 保留政策清理排程任務執行完成。")
+	}
+}
+
+// ThumbnailJob 是一個排程任務，為尚未產生縮圖的影片產生海報與關鍵影格縮圖表
+type ThumbnailJob struct {
+	thumbnailService *services.ThumbnailService
+}
+
+// NewThumbnailJob 建立一個 ThumbnailJob
+func NewThumbnailJob(ts *services.ThumbnailService) *ThumbnailJob {
+	return &ThumbnailJob{thumbnailService: ts}
+}
+
+// Run 實現 cron.Job 介面；重複執行由 ThumbnailService 本身避免
+func (j *ThumbnailJob) Run() {
+	if err := j.thumbnailService.Run(); err != nil {
+		log.Printf("錯誤：影片縮圖產生排程任務執行失敗: %v", err)
+	}
+}
+
+// WebProxyJob 是一個排程任務，為瀏覽器無法播放的影片產生 H.264/AAC 播放版本
+type WebProxyJob struct {
+	webProxyService *services.WebProxyService
+}
+
+// NewWebProxyJob 建立一個 WebProxyJob
+func NewWebProxyJob(ws *services.WebProxyService) *WebProxyJob {
+	return &WebProxyJob{webProxyService: ws}
+}
+
+// Run 實現 cron.Job 介面；重複執行由 WebProxyService 本身避免
+func (j *WebProxyJob) Run() {
+	if err := j.webProxyService.Run(); err != nil {
+		log.Printf("錯誤：播放版本產生排程任務執行失敗: %v", err)
+	}
+}
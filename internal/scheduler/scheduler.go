@@ -1,8 +1,11 @@
 package scheduler
 
 import (
+	"AiHackathon-admin/internal/config"
 	"AiHackathon-admin/internal/services"
+	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/robfig/cron/v3"
@@ -10,49 +13,135 @@ import (
 
 // Scheduler 結構 (保持不變)
 type Scheduler struct {
-	cron       *cron.Cron
-	fetchJob   *FetchJob
-	analyzeJob *AnalyzeJob
+	cron         *cron.Cron
+	fetchJob     *FetchJob
+	analyzeJob   *AnalyzeJob
+	retentionJob *RetentionJob // 未提供 RetentionService 時為 nil
+	thumbnailJob *ThumbnailJob // 未提供 ThumbnailService 時為 nil
+	webProxyJob  *WebProxyJob  // 未提供 WebProxyService 時為 nil
+
+	mu               sync.Mutex // 保護以下排程狀態 (重新排程時使用)
+	fetchSpec        string
+	analyzeSpec      string
+	retentionSpec    string
+	thumbnailSpec    string
+	webProxySpec     string
+	fetchEntryID     cron.EntryID
+	analyzeEntryID   cron.EntryID
+	retentionEntryID cron.EntryID
+	thumbnailEntryID cron.EntryID
+	webProxyEntryID  cron.EntryID
+}
+
+// Services 排程任務使用的服務；Fetch 與 Analyze 必填，其餘為 nil 時不註冊對應的任務
+type Services struct {
+	Fetch      *services.FetchService
+	Analyze    *services.AnalyzeService
+	Costs      *services.CostService      // 每月預算檢查
+	Retention  *services.RetentionService // NAS 保留政策清理
+	Thumbnails *services.ThumbnailService // 影片縮圖產生
+	WebProxies *services.WebProxyService  // 瀏覽器播放版本產生
 }
 
-// NewScheduler 更新：接收 Cron 表達式
-func NewScheduler(
-	fs *services.FetchService,
-	as *services.AnalyzeService,
-	fetchCronSpec string, // 新增參數
-	analyzeCronSpec string, // 新增參數
-) *Scheduler {
+// NewScheduler 建立排程器，並依 cfg 的 Cron 表達式註冊各任務
+func NewScheduler(svcs Services, cfg config.SchedulerConfig) (*Scheduler, error) {
+	if svcs.Fetch == nil || svcs.Analyze == nil {
+		return nil, fmt.Errorf("Scheduler：FetchService 與 AnalyzeService 不得為空")
+	}
 	c := cron.New(cron.WithSeconds())
 
-	fetchJob := NewFetchJob(fs)
-	analyzeJob := NewAnalyzeJob(as)
+	fetchJob := NewFetchJob(svcs.Fetch)
+	analyzeJob := NewAnalyzeJob(svcs.Analyze, svcs.Costs)
 
+	s := &Scheduler{
+		cron:       c,
+		fetchJob:   fetchJob,
+		analyzeJob: analyzeJob,
+	}
+	if svcs.Retention != nil {
+		s.retentionJob = NewRetentionJob(svcs.Retention)
+	}
+	if svcs.Thumbnails != nil {
+		s.thumbnailJob = NewThumbnailJob(svcs.Thumbnails)
+	}
+	if svcs.WebProxies != nil {
+		s.webProxyJob = NewWebProxyJob(svcs.WebProxies)
+	}
 	// 使用從設定檔傳入的 Cron 表達式
-	if fetchCronSpec != "" {
-		_, err := c.AddJob(fetchCronSpec, fetchJob)
-		if err != nil {
-			log.Fatalf("錯誤：無法新增影片擷取任務到排程器 (spec: %s): %v", fetchCronSpec, err)
-		}
-		log.Printf("資訊：影片擷取任務已註冊，排程：%s\n", fetchCronSpec)
-	} else {
-		log.Println("警告：未提供影片擷取任務的 Cron 表達式，該任務將不會被排程。")
+	if err := s.Reschedule(cfg); err != nil {
+		return nil, err
 	}
+	return s, nil
+}
+
+// Reschedule 以新的 Cron 表達式重新註冊擷取、分析、保留政策清理、縮圖與播放版本產生任務 (空字串代表不排程該任務)。
+// 所有表達式皆解析成功後才會替換，失敗時維持原排程；正在執行中的任務不會被中斷。
+func (s *Scheduler) Reschedule(cfg config.SchedulerConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	if analyzeCronSpec != "" {
-		_, err := c.AddJob(analyzeCronSpec, analyzeJob)
-		if err != nil {
-			log.Fatalf("錯誤：無法新增影片分析任務到排程器 (spec: %s): %v", analyzeCronSpec, err)
+	parser := cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	var fetchSchedule, analyzeSchedule, retentionSchedule, thumbnailSchedule, webProxySchedule cron.Schedule
+	var err error
+	if cfg.FetchCronSpec != "" {
+		if fetchSchedule, err = parser.Parse(cfg.FetchCronSpec); err != nil {
+			return fmt.Errorf("無效的影片擷取任務排程 (spec: %s): %w", cfg.FetchCronSpec, err)
+		}
+	}
+	if cfg.AnalyzeCronSpec != "" {
+		if analyzeSchedule, err = parser.Parse(cfg.AnalyzeCronSpec); err != nil {
+			return fmt.Errorf("無效的影片分析任務排程 (spec: %s): %w", cfg.AnalyzeCronSpec, err)
+		}
+	}
+	if cfg.RetentionCronSpec != "" && s.retentionJob != nil {
+		if retentionSchedule, err = parser.Parse(cfg.RetentionCronSpec); err != nil {
+			return fmt.Errorf("無效的保留政策清理任務排程 (spec: %s): %w", cfg.RetentionCronSpec, err)
+		}
+	}
+	if cfg.ThumbnailCronSpec != "" && s.thumbnailJob != nil {
+		if thumbnailSchedule, err = parser.Parse(cfg.ThumbnailCronSpec); err != nil {
+			return fmt.Errorf("無效的影片縮圖產生任務排程 (spec: %s): %w", cfg.ThumbnailCronSpec, err)
+		}
+	}
+	if cfg.WebProxyCronSpec != "" && s.webProxyJob != nil {
+		if webProxySchedule, err = parser.Parse(cfg.WebProxyCronSpec); err != nil {
+			return fmt.Errorf("無效的播放版本產生任務排程 (spec: %s): %w", cfg.WebProxyCronSpec, err)
 		}
-		log.Printf("資訊：影片分析任務已註冊，排程：%s\n", analyzeCronSpec)
-	} else {
-		log.Println("警告：未提供影片分析任務的 Cron 表達式，該任務將不會被排程。")
 	}
 
-	return &Scheduler{
-		cron:       c,
-		fetchJob:   fetchJob,
-		analyzeJob: analyzeJob,
+	s.fetchEntryID = s.replaceEntry(s.fetchEntryID, s.fetchSpec, cfg.FetchCronSpec, fetchSchedule, s.fetchJob, "影片擷取")
+	s.fetchSpec = cfg.FetchCronSpec
+	s.analyzeEntryID = s.replaceEntry(s.analyzeEntryID, s.analyzeSpec, cfg.AnalyzeCronSpec, analyzeSchedule, s.analyzeJob, "影片分析")
+	s.analyzeSpec = cfg.AnalyzeCronSpec
+	if s.retentionJob != nil {
+		s.retentionEntryID = s.replaceEntry(s.retentionEntryID, s.retentionSpec, cfg.RetentionCronSpec, retentionSchedule, s.retentionJob, "NAS 保留政策清理")
+		s.retentionSpec = cfg.RetentionCronSpec
+	}
+	if s.thumbnailJob != nil {
+		s.thumbnailEntryID = s.replaceEntry(s.thumbnailEntryID, s.thumbnailSpec, cfg.ThumbnailCronSpec, thumbnailSchedule, s.thumbnailJob, "影片縮圖產生")
+		s.thumbnailSpec = cfg.ThumbnailCronSpec
+	}
+	if s.webProxyJob != nil {
+		s.webProxyEntryID = s.replaceEntry(s.webProxyEntryID, s.webProxySpec, cfg.WebProxyCronSpec, webProxySchedule, s.webProxyJob, "瀏覽器播放版本產生")
+		s.webProxySpec = cfg.WebProxyCronSpec
+	}
+	return nil
+}
+
+// replaceEntry 在排程表達式變更時移除舊的排程項目並註冊新的，回傳目前的項目 ID (0 代表未排程)
+func (s *Scheduler) replaceEntry(id cron.EntryID, oldSpec, newSpec string, schedule cron.Schedule, job cron.Job, name string) cron.EntryID {
+	if id != 0 && oldSpec == newSpec {
+		return id
+	}
+	if id != 0 {
+		s.cron.Remove(id)
+	}
+	if schedule == nil {
+		log.Printf("警告：未提供%s任務的 Cron 表達式，該任務將不會被排程。", name)
+		return 0
 	}
+	log.Printf("資訊：%s任務已註冊，排程：%s\n", name, newSpec)
+	return s.cron.Schedule(schedule, job)
 }
 
 // Start 方法 (不再直接註冊任務，因為已在 NewScheduler 中完成)
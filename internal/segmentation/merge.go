@@ -0,0 +1,288 @@
+package segmentation
+
+import (
+	"AiHackathon-admin/internal/models"
+	"AiHackathon-admin/internal/subtitles"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Result 單一片段的分析結果
+type Result struct {
+	Span     Span
+	Analysis *models.AnalysisResult
+	// AbsoluteTimecodes 為 true 時片段內的時間點已是完整影片的時間 (以 prompt 指定時間範圍分析)，合併時不再位移
+	AbsoluteTimecodes bool
+}
+
+var ratingWeights = map[string]int{"S": 5, "A": 4, "B": 3, "C": 2, "N": 1}
+
+// maxKeyFactors 合併後保留的評級因素數量上限
+const maxKeyFactors = 5
+
+// Merge 依片段順序合併各片段的分析結果：逐字稿與翻譯依序串接並標示片段時間，
+// segments 與 bites 的時間點換算為完整影片的絕對時間 (落在片段範圍以外的會與相鄰片段重複，予以略過)，關鍵字、主題與地點去除重複，
+// 重要性評級取最高的片段。摘要暫以各片段摘要串接，可再以 ApplyOverallSummary 取代為整體摘要。
+// 個別欄位 JSON 無法解析時略過該片段的該欄位並記錄警告
+func Merge(results []Result) (*models.AnalysisResult, error) {
+	if len(results) == 0 {
+		return nil, fmt.Errorf("沒有可合併的片段分析結果")
+	}
+	sorted := sortedResults(results)
+	if len(sorted) == 0 {
+		return nil, fmt.Errorf("沒有可合併的片段分析結果")
+	}
+
+	var transcripts, translations, shortSummaries, bulletedSummaries, visuals []string
+	var segments []models.TranscriptSegment
+	var bites []models.Bite
+	var keywords []map[string]interface{}
+	seenKeywords := make(map[string]bool)
+	var topics, locations, relatedNews, materialTypes []string
+	var best *models.ImportanceScore
+	var keyFactors []string
+
+	for _, r := range sorted {
+		a := r.Analysis
+		header := fmt.Sprintf("[%s-%s]", FormatTimecode(r.Span.Start, false), FormatTimecode(r.Span.End, false))
+		if t := text(a.Transcript); t != "" {
+			transcripts = append(transcripts, header+"\n"+t)
+		}
+		if t := text(a.Translation); t != "" {
+			translations = append(translations, header+"\n"+t)
+		}
+		if t := text(a.ShortSummary); t != "" {
+			shortSummaries = append(shortSummaries, t)
+		}
+		if t := text(a.BulletedSummary); t != "" {
+			bulletedSummaries = append(bulletedSummaries, t)
+		}
+		if t := text(a.VisualDescription); t != "" {
+			visuals = append(visuals, header+" "+t)
+		}
+		materialTypes = appendUnique(materialTypes, splitMaterialTypes(text(a.MaterialType))...)
+
+		offset := r.Span.Start
+		if r.AbsoluteTimecodes {
+			offset = 0
+		}
+
+		var segs []models.TranscriptSegment
+		if decode(a.Segments, &segs, "segments", r.Span) {
+			for _, s := range segs {
+				s.Start = shiftTimecode(s.Start, offset, true)
+				s.End = shiftTimecode(s.End, offset, true)
+				if inSpan(s.Start, r.Span) {
+					segments = append(segments, s)
+				}
+			}
+		}
+		var bs []models.Bite
+		if decode(a.Bites, &bs, "bites", r.Span) {
+			for _, b := range bs {
+				b.TimeLine = shiftTimecode(b.TimeLine, offset, false)
+				if inSpan(b.TimeLine, r.Span) {
+					bites = append(bites, b)
+				}
+			}
+		}
+
+		var kws []map[string]interface{}
+		if decode(a.Keywords, &kws, "keywords", r.Span) {
+			for _, kw := range kws {
+				name, _ := kw["keyword"].(string)
+				key := strings.ToLower(strings.TrimSpace(name))
+				if key == "" || seenKeywords[key] {
+					continue
+				}
+				seenKeywords[key] = true
+				keywords = append(keywords, kw)
+			}
+		}
+
+		var list []string
+		if decode(a.Topics, &list, "topics", r.Span) {
+			topics = appendUnique(topics, list...)
+		}
+		list = nil
+		if decode(a.MentionedLocations, &list, "mentioned_locations", r.Span) {
+			locations = appendUnique(locations, list...)
+		}
+		list = nil
+		if decode(a.RelatedNews, &list, "related_news", r.Span) {
+			relatedNews = appendUnique(relatedNews, list...)
+		}
+
+		var score models.ImportanceScore
+		if decode(a.ImportanceScore, &score, "importance_score", r.Span) {
+			keyFactors = appendUnique(keyFactors, score.KeyFactors...)
+			if best == nil || ratingWeights[strings.ToUpper(score.OverallRating)] > ratingWeights[strings.ToUpper(best.OverallRating)] {
+				s := score
+				s.AssessmentDetails = strings.TrimSpace(fmt.Sprintf("%s %s", r.Span.Label(), score.AssessmentDetails))
+				best = &s
+			}
+		}
+	}
+
+	merged := &models.AnalysisResult{
+		Transcript:        nullString(strings.Join(transcripts, "\n\n")),
+		Translation:       nullString(strings.Join(translations, "\n\n")),
+		ShortSummary:      nullString(strings.Join(shortSummaries, "\n")),
+		BulletedSummary:   nullString(strings.Join(bulletedSummaries, "\n")),
+		VisualDescription: nullString(strings.Join(visuals, "\n")),
+		MaterialType:      nullString(strings.Join(materialTypes, "、")),
+	}
+	merged.Segments = marshal(segments)
+	merged.Bites = marshal(bites)
+	merged.Keywords = marshal(keywords)
+	merged.Topics = marshal(topics)
+	merged.MentionedLocations = marshal(locations)
+	merged.RelatedNews = marshal(relatedNews)
+	if best != nil {
+		if len(keyFactors) > maxKeyFactors {
+			keyFactors = keyFactors[:maxKeyFactors]
+		}
+		best.KeyFactors = keyFactors
+		merged.ImportanceScore = marshal(best)
+	}
+	return merged, nil
+}
+
+// OverallSummaryPrompt 以各片段摘要產生整體摘要時使用的 prompt
+const OverallSummaryPrompt = `以下是一支長影片依時間順序分段分析後，各片段的摘要與重點。請綜合所有片段，以「繁體中文」產生整支影片的摘要，並嚴格按照以下 JSON 格式回傳：
+{"short_summary": "約50-100字的短摘要，涵蓋最核心的人事時地物", "bulleted_summary": "以列點方式 (每點以 '-' 開頭並換行，約3-5點) 總共400字以內的摘要"}`
+
+// SummaryInput 組合產生整體摘要時送出的各片段摘要
+func SummaryInput(results []Result) string {
+	var b strings.Builder
+	for _, r := range sortedResults(results) {
+		fmt.Fprintf(&b, "%s\n", r.Span.Label())
+		if t := text(r.Analysis.ShortSummary); t != "" {
+			fmt.Fprintf(&b, "短摘要: %s\n", t)
+		}
+		if t := text(r.Analysis.BulletedSummary); t != "" {
+			fmt.Fprintf(&b, "重點:\n%s\n", t)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// ApplyOverallSummary 以整體摘要 (OverallSummaryPrompt 的 JSON 回應) 取代合併時串接的片段摘要
+func ApplyOverallSummary(merged *models.AnalysisResult, summaryJSON string) error {
+	var summary struct {
+		ShortSummary    string `json:"short_summary"`
+		BulletedSummary string `json:"bulleted_summary"`
+	}
+	if err := json.Unmarshal([]byte(summaryJSON), &summary); err != nil {
+		return fmt.Errorf("無法解析整體摘要 JSON: %w", err)
+	}
+	if strings.TrimSpace(summary.ShortSummary) == "" {
+		return fmt.Errorf("整體摘要為空")
+	}
+	merged.ShortSummary = nullString(summary.ShortSummary)
+	if strings.TrimSpace(summary.BulletedSummary) != "" {
+		merged.BulletedSummary = nullString(summary.BulletedSummary)
+	}
+	return nil
+}
+
+// sortedResults 依片段起點排序，並略過沒有分析結果的片段
+func sortedResults(results []Result) []Result {
+	sorted := make([]Result, 0, len(results))
+	for _, r := range results {
+		if r.Analysis != nil {
+			sorted = append(sorted, r)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Span.Start < sorted[j].Span.Start })
+	return sorted
+}
+
+// shiftTimecode 將片段內的時間點加上片段起點；無法解析的時間點原樣保留
+func shiftTimecode(tc string, offset time.Duration, withMillis bool) string {
+	if offset == 0 || strings.TrimSpace(tc) == "" {
+		return tc
+	}
+	d, err := subtitles.ParseTimecode(tc)
+	if err != nil {
+		return tc
+	}
+	return FormatTimecode(d+offset, withMillis)
+}
+
+// inSpan 判斷 (已換算為完整影片時間的) 時間點是否落在片段範圍內。以 prompt 指定時間範圍分析時模型可能回傳範圍外的內容，
+// 與相鄰片段的結果重複；無法解析的時間點 (例如 bites 的 "00:01:00-00:01:10" 區間) 以起點判斷，仍無法解析時保留
+func inSpan(tc string, span Span) bool {
+	start, _, _ := strings.Cut(tc, "-")
+	d, err := subtitles.ParseTimecode(start)
+	if err != nil {
+		return true
+	}
+	return d >= span.Start && d < span.End
+}
+
+func decode(raw json.RawMessage, target interface{}, field string, span Span) bool {
+	if len(raw) == 0 || string(raw) == "null" {
+		return false
+	}
+	if err := json.Unmarshal(raw, target); err != nil {
+		log.Printf("警告：[Segmentation] 無法解析%s的 %s JSON，合併時略過: %v", span.Label(), field, err)
+		return false
+	}
+	return true
+}
+
+func marshal(v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" {
+		return nil
+	}
+	return data
+}
+
+func text(s *models.JsonNullString) string {
+	if s == nil || !s.Valid {
+		return ""
+	}
+	return strings.TrimSpace(s.String)
+}
+
+func nullString(s string) *models.JsonNullString {
+	if s == "" {
+		return nil
+	}
+	return &models.JsonNullString{NullString: sql.NullString{String: s, Valid: true}}
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		found := false
+		for _, existing := range list {
+			if existing == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// splitMaterialTypes 拆解 material_type (可能以頓號、逗號或換行列出多個分類)
+func splitMaterialTypes(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == '、' || r == ',' || r == '，' || r == '\n'
+	})
+}
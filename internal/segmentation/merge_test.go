@@ -0,0 +1,287 @@
+package segmentation
+
+import (
+	"AiHackathon-admin/internal/models"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func str(s string) *models.JsonNullString {
+	return &models.JsonNullString{NullString: sql.NullString{String: s, Valid: true}}
+}
+
+func raw(t *testing.T, v interface{}) json.RawMessage {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// twoSpans 回傳 20 分鐘影片以 10 分鐘切割的兩個片段
+func twoSpans() (Span, Span) {
+	spans := Plan(20*time.Minute, 10*time.Minute)
+	return spans[0], spans[1]
+}
+
+func TestMergeTimecodes(t *testing.T) {
+	first, second := twoSpans()
+	tests := []struct {
+		name         string
+		results      []Result
+		wantSegments []models.TranscriptSegment
+		wantBites    []models.Bite
+	}{
+		{
+			name: "切割片段的時間點加上片段起點",
+			results: []Result{
+				{Span: first, Analysis: &models.AnalysisResult{
+					ShortSummary: str("甲"),
+					Segments:     raw(t, []models.TranscriptSegment{{Start: "00:00:05.000", End: "00:00:09.500", Text: "a"}}),
+					Bites:        raw(t, []models.Bite{{TimeLine: "00:00:30", Speaker: "A", Quote: "q1"}}),
+				}},
+				{Span: second, Analysis: &models.AnalysisResult{
+					ShortSummary: str("乙"),
+					Segments:     raw(t, []models.TranscriptSegment{{Start: "00:01:00.000", End: "00:01:02.250", Text: "b"}}),
+					Bites:        raw(t, []models.Bite{{TimeLine: "00:00:30", Speaker: "B", Quote: "q2"}}),
+				}},
+			},
+			wantSegments: []models.TranscriptSegment{
+				{Start: "00:00:05.000", End: "00:00:09.500", Text: "a"},
+				{Start: "00:11:00.000", End: "00:11:02.250", Text: "b"},
+			},
+			wantBites: []models.Bite{{TimeLine: "00:00:30", Speaker: "A", Quote: "q1"}, {TimeLine: "00:10:30", Speaker: "B", Quote: "q2"}},
+		},
+		{
+			name: "以 prompt 指定範圍分析的時間點不位移",
+			results: []Result{
+				{Span: second, AbsoluteTimecodes: true, Analysis: &models.AnalysisResult{
+					ShortSummary: str("乙"),
+					Segments:     raw(t, []models.TranscriptSegment{{Start: "00:12:00.000", End: "00:12:03.000", Text: "b"}}),
+				}},
+			},
+			wantSegments: []models.TranscriptSegment{{Start: "00:12:00.000", End: "00:12:03.000", Text: "b"}},
+		},
+		{
+			name: "片段範圍以外的時間點與相鄰片段重複，予以略過",
+			results: []Result{
+				{Span: first, AbsoluteTimecodes: true, Analysis: &models.AnalysisResult{
+					ShortSummary: str("甲"),
+					Segments: raw(t, []models.TranscriptSegment{
+						{Start: "00:09:50.000", End: "00:09:58.000", Text: "a"},
+						{Start: "00:10:01.000", End: "00:10:04.000", Text: "overlap"},
+					}),
+					Bites: raw(t, []models.Bite{{TimeLine: "00:10:02", Speaker: "B", Quote: "overlap"}}),
+				}},
+				{Span: second, AbsoluteTimecodes: true, Analysis: &models.AnalysisResult{
+					ShortSummary: str("乙"),
+					Segments: raw(t, []models.TranscriptSegment{
+						{Start: "00:09:50.000", End: "00:09:58.000", Text: "a"},
+						{Start: "00:10:01.000", End: "00:10:04.000", Text: "b"},
+					}),
+					Bites: raw(t, []models.Bite{{TimeLine: "00:10:02", Speaker: "B", Quote: "q"}}),
+				}},
+			},
+			wantSegments: []models.TranscriptSegment{
+				{Start: "00:09:50.000", End: "00:09:58.000", Text: "a"},
+				{Start: "00:10:01.000", End: "00:10:04.000", Text: "b"},
+			},
+			wantBites: []models.Bite{{TimeLine: "00:10:02", Speaker: "B", Quote: "q"}},
+		},
+		{
+			name: "依片段起點排序並略過沒有結果的片段",
+			results: []Result{
+				{Span: second, Analysis: &models.AnalysisResult{
+					ShortSummary: str("乙"),
+					Segments:     raw(t, []models.TranscriptSegment{{Start: "00:00:01.000", End: "00:00:02.000", Text: "b"}}),
+				}},
+				{Span: first, Analysis: nil},
+				{Span: first, Analysis: &models.AnalysisResult{
+					ShortSummary: str("甲"),
+					Segments:     raw(t, []models.TranscriptSegment{{Start: "00:00:01.000", End: "00:00:02.000", Text: "a"}}),
+				}},
+			},
+			wantSegments: []models.TranscriptSegment{
+				{Start: "00:00:01.000", End: "00:00:02.000", Text: "a"},
+				{Start: "00:10:01.000", End: "00:10:02.000", Text: "b"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, err := Merge(tt.results)
+			if err != nil {
+				t.Fatalf("Merge: %v", err)
+			}
+			var segments []models.TranscriptSegment
+			if len(merged.Segments) > 0 {
+				if err := json.Unmarshal(merged.Segments, &segments); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !reflect.DeepEqual(segments, tt.wantSegments) {
+				t.Errorf("segments = %+v, want %+v", segments, tt.wantSegments)
+			}
+			var bites []models.Bite
+			if len(merged.Bites) > 0 {
+				if err := json.Unmarshal(merged.Bites, &bites); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !reflect.DeepEqual(bites, tt.wantBites) {
+				t.Errorf("bites = %+v, want %+v", bites, tt.wantBites)
+			}
+		})
+	}
+}
+
+func TestMergeCombinesFields(t *testing.T) {
+	first, second := twoSpans()
+	merged, err := Merge([]Result{
+		{Span: first, Analysis: &models.AnalysisResult{
+			Transcript:         str("hello"),
+			ShortSummary:       str("甲摘要"),
+			BulletedSummary:    str("- 甲"),
+			VisualDescription:  str("街景"),
+			MaterialType:       str("新聞、訪問"),
+			Keywords:           raw(t, []map[string]string{{"keyword": "Taipei"}, {"keyword": "水災"}}),
+			Topics:             raw(t, []string{"天氣", "交通"}),
+			MentionedLocations: raw(t, []string{"台北"}),
+			ImportanceScore:    raw(t, models.ImportanceScore{OverallRating: "B", KeyFactors: []string{"在地"}, AssessmentDetails: "一般"}),
+		}},
+		{Span: second, Analysis: &models.AnalysisResult{
+			Transcript:         str("world"),
+			ShortSummary:       str("乙摘要"),
+			BulletedSummary:    str("- 乙"),
+			MaterialType:       str("訪問,資料畫面"),
+			Keywords:           raw(t, []map[string]string{{"keyword": "taipei "}, {"keyword": "颱風"}}),
+			Topics:             raw(t, []string{"交通", "災害"}),
+			MentionedLocations: raw(t, []string{"台北", "新北"}),
+			ImportanceScore:    raw(t, models.ImportanceScore{OverallRating: "A", KeyFactors: []string{"在地", "傷亡"}, AssessmentDetails: "重大"}),
+			RelatedNews:        json.RawMessage(`{not json`),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+
+	if got, want := text(merged.Transcript), "[00:00:00-00:10:00]\nhello\n\n[00:10:00-00:20:00]\nworld"; got != want {
+		t.Errorf("transcript = %q, want %q", got, want)
+	}
+	if got, want := text(merged.ShortSummary), "甲摘要\n乙摘要"; got != want {
+		t.Errorf("short summary = %q, want %q", got, want)
+	}
+	if got, want := text(merged.VisualDescription), "[00:00:00-00:10:00] 街景"; got != want {
+		t.Errorf("visual description = %q, want %q", got, want)
+	}
+	if got, want := text(merged.MaterialType), "新聞、訪問、資料畫面"; got != want {
+		t.Errorf("material type = %q, want %q", got, want)
+	}
+	if merged.Translation != nil {
+		t.Errorf("translation = %q, want nil", text(merged.Translation))
+	}
+	if merged.RelatedNews != nil {
+		t.Errorf("related news = %s, want nil (invalid JSON skipped)", merged.RelatedNews)
+	}
+
+	var keywords []map[string]string
+	json.Unmarshal(merged.Keywords, &keywords)
+	if want := []map[string]string{{"keyword": "Taipei"}, {"keyword": "水災"}, {"keyword": "颱風"}}; !reflect.DeepEqual(keywords, want) {
+		t.Errorf("keywords = %v, want %v", keywords, want)
+	}
+	var topics, locations []string
+	json.Unmarshal(merged.Topics, &topics)
+	if want := []string{"天氣", "交通", "災害"}; !reflect.DeepEqual(topics, want) {
+		t.Errorf("topics = %v, want %v", topics, want)
+	}
+	json.Unmarshal(merged.MentionedLocations, &locations)
+	if want := []string{"台北", "新北"}; !reflect.DeepEqual(locations, want) {
+		t.Errorf("locations = %v, want %v", locations, want)
+	}
+
+	var score models.ImportanceScore
+	if err := json.Unmarshal(merged.ImportanceScore, &score); err != nil {
+		t.Fatal(err)
+	}
+	if score.OverallRating != "A" || !strings.HasPrefix(score.AssessmentDetails, second.Label()) {
+		t.Errorf("importance = %+v, want rating A from %s", score, second.Label())
+	}
+	if want := []string{"在地", "傷亡"}; !reflect.DeepEqual(score.KeyFactors, want) {
+		t.Errorf("key factors = %v, want %v", score.KeyFactors, want)
+	}
+}
+
+func TestMergeEmpty(t *testing.T) {
+	first, _ := twoSpans()
+	for _, results := range [][]Result{nil, {{Span: first}}} {
+		if _, err := Merge(results); err == nil {
+			t.Errorf("Merge(%v) 應回傳錯誤", results)
+		}
+	}
+}
+
+func TestApplyOverallSummary(t *testing.T) {
+	tests := []struct {
+		name         string
+		summaryJSON  string
+		wantErr      bool
+		wantShort    string
+		wantBulleted string
+	}{
+		{
+			name:         "整體摘要取代片段摘要",
+			summaryJSON:  `{"short_summary": "整體", "bulleted_summary": "- 重點一\n- 重點二"}`,
+			wantShort:    "整體",
+			wantBulleted: "- 重點一\n- 重點二",
+		},
+		{
+			name:         "沒有列點摘要時保留片段串接",
+			summaryJSON:  `{"short_summary": "整體"}`,
+			wantShort:    "整體",
+			wantBulleted: "- 甲\n- 乙",
+		},
+		{name: "短摘要為空", summaryJSON: `{"short_summary": " ", "bulleted_summary": "- x"}`, wantErr: true},
+		{name: "無效 JSON", summaryJSON: `short_summary: x`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := &models.AnalysisResult{ShortSummary: str("甲\n乙"), BulletedSummary: str("- 甲\n- 乙")}
+			err := ApplyOverallSummary(merged, tt.summaryJSON)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("應回傳錯誤")
+				}
+				if text(merged.ShortSummary) != "甲\n乙" || text(merged.BulletedSummary) != "- 甲\n- 乙" {
+					t.Errorf("失敗時不應修改摘要: %q / %q", text(merged.ShortSummary), text(merged.BulletedSummary))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplyOverallSummary: %v", err)
+			}
+			if got := text(merged.ShortSummary); got != tt.wantShort {
+				t.Errorf("short summary = %q, want %q", got, tt.wantShort)
+			}
+			if got := text(merged.BulletedSummary); got != tt.wantBulleted {
+				t.Errorf("bulleted summary = %q, want %q", got, tt.wantBulleted)
+			}
+		})
+	}
+}
+
+func TestSummaryInput(t *testing.T) {
+	first, second := twoSpans()
+	got := SummaryInput([]Result{
+		{Span: second, Analysis: &models.AnalysisResult{ShortSummary: str("乙")}},
+		{Span: first, Analysis: &models.AnalysisResult{ShortSummary: str("甲"), BulletedSummary: str("- a")}},
+	})
+	want := first.Label() + "\n短摘要: 甲\n重點:\n- a\n\n" + second.Label() + "\n短摘要: 乙\n\n"
+	if got != want {
+		t.Errorf("SummaryInput = %q, want %q", got, want)
+	}
+}
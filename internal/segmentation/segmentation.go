@@ -0,0 +1,121 @@
+package segmentation
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Span 長影片中的一個分析片段
+type Span struct {
+	Index int           // 0 起算
+	Count int           // 片段總數
+	Start time.Duration // 在原始影片中的起點
+	End   time.Duration // 在原始影片中的終點
+}
+
+// Label 回傳人看的片段標示，例如 "第 2/5 段 (00:10:00-00:20:00)"
+func (s Span) Label() string {
+	return fmt.Sprintf("第 %d/%d 段 (%s-%s)", s.Index+1, s.Count, FormatTimecode(s.Start, false), FormatTimecode(s.End, false))
+}
+
+// Plan 將長度為 total 的影片切成每段 segmentLen 的片段；最後一段短於 segmentLen 的四分之一時併入前一段，
+// 避免只有幾秒的尾段
+func Plan(total, segmentLen time.Duration) []Span {
+	if total <= 0 || segmentLen <= 0 {
+		return nil
+	}
+	var spans []Span
+	for start := time.Duration(0); start < total; start += segmentLen {
+		end := start + segmentLen
+		if end > total {
+			end = total
+		}
+		spans = append(spans, Span{Start: start, End: end})
+	}
+	if n := len(spans); n > 1 && spans[n-1].End-spans[n-1].Start < segmentLen/4 {
+		spans[n-2].End = spans[n-1].End
+		spans = spans[:n-1]
+	}
+	for i := range spans {
+		spans[i].Index = i
+		spans[i].Count = len(spans)
+	}
+	return spans
+}
+
+// FindFFmpeg 尋找 ffmpeg 執行檔；configured 為設定檔指定的路徑 (可為空，改由 PATH 尋找)
+func FindFFmpeg(configured string) (string, bool) {
+	name := configured
+	if name == "" {
+		name = "ffmpeg"
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// Cut 以 ffmpeg 將 src 中 span 範圍的內容重新編碼為 MP4 片段存放於 dstDir，回傳片段檔案路徑。
+// 不重新編碼 (-c copy) 時切點會對齊前一個關鍵影格，片段實際起點早於 span.Start，
+// 合併時以 span.Start 換算的時間點就會偏移；重新編碼可讓片段精確地從 span.Start 開始。
+// 片段只供模型分析，以較快的 preset 編碼並限制高度為 720
+func Cut(ctx context.Context, ffmpegPath string, src string, dstDir string, span Span) (string, error) {
+	dst := filepath.Join(dstDir, fmt.Sprintf("segment_%03d.mp4", span.Index))
+	cmd := exec.CommandContext(ctx, ffmpegPath,
+		"-hide_banner", "-loglevel", "error", "-y",
+		"-ss", seconds(span.Start),
+		"-i", src,
+		"-t", seconds(span.End-span.Start),
+		"-map", "0:v:0", "-map", "0:a:0?",
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "28", "-pix_fmt", "yuv420p",
+		"-vf", "scale=-2:'trunc(min(720,ih)/2)*2'",
+		"-c:a", "aac", "-b:a", "96k",
+		"-avoid_negative_ts", "make_zero",
+		"-f", "mp4",
+		dst,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("ffmpeg 切割%s失敗: %w (%s)", span.Label(), err, strings.TrimSpace(string(out)))
+	}
+	return dst, nil
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// SegmentPrompt 在影片分析 prompt 後附加片段說明。
+// absolute 為 true 時送出的是完整影片 (未能以 ffmpeg 切割)，要求模型只分析指定時間範圍並以完整影片的時間標示；
+// 否則送出的是切割後的片段，時間點以片段起點為 00:00:00，合併時再加上片段起點
+func SegmentPrompt(prompt string, span Span, total time.Duration, absolute bool) string {
+	var note string
+	if absolute {
+		note = fmt.Sprintf("注意：本影片全長 %s，本次請只分析 %s 至 %s 的內容 (%s)，忽略此範圍以外的畫面與語音；"+
+			"所有時間點 (segments、bites) 請以完整影片的時間標示。摘要、關鍵字與評級只需涵蓋此範圍。",
+			FormatTimecode(total, false), FormatTimecode(span.Start, false), FormatTimecode(span.End, false), span.Label())
+	} else {
+		note = fmt.Sprintf("注意：本影片為全長 %s 的影片中%s，所有時間點 (segments、bites) 請以本片段起點為 00:00:00 標示。"+
+			"摘要、關鍵字與評級只需涵蓋本片段內容。",
+			FormatTimecode(total, false), span.Label())
+	}
+	return prompt + "\n\n" + note
+}
+
+// FormatTimecode 將時間格式化為 HH:MM:SS，withMillis 為 true 時為 HH:MM:SS.mmm
+func FormatTimecode(d time.Duration, withMillis bool) string {
+	if d < 0 {
+		d = 0
+	}
+	ms := d.Milliseconds()
+	tc := fmt.Sprintf("%02d:%02d:%02d", ms/3600000, (ms%3600000)/60000, (ms%60000)/1000)
+	if withMillis {
+		tc += fmt.Sprintf(".%03d", ms%1000)
+	}
+	return tc
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"AiHackathon-admin/internal/alerts"
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/feeds"
+	"AiHackathon-admin/internal/models"
+	"AiHackathon-admin/internal/web/handlers"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AlertService 在影片完成分析後依資料庫中的快訊規則比對，符合時寄送 email 或聊天室通知。
+// 每個規則對同一部影片只會觸發一次 (alert_firings 的唯一鍵)。
+type AlertService struct {
+	cfg        config.AlertsConfig
+	baseURL    string
+	db         handlers.DBStore
+	httpClient *http.Client
+}
+
+// NewAlertService 建立 AlertService 實例
+func NewAlertService(cfg *config.Config, db handlers.DBStore) (*AlertService, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("AlertService：設定不得為空")
+	}
+	if db == nil {
+		return nil, fmt.Errorf("AlertService：DBStore 不得為空")
+	}
+	timeout := cfg.Alerts.ChatTimeoutSecs
+	if timeout <= 0 {
+		timeout = 10
+	}
+	log.Println("資訊：AlertService 初始化完成。")
+	return &AlertService{
+		cfg:        cfg.Alerts,
+		baseURL:    strings.TrimRight(cfg.Export.PublicBaseURL, "/"),
+		db:         db,
+		httpClient: &http.Client{Timeout: time.Duration(timeout) * time.Second},
+	}, nil
+}
+
+// NotifyAnalysisCompleted 比對所有啟用中的規則，並於背景寄送符合規則的快訊
+func (s *AlertService) NotifyAnalysisCompleted(video models.Video, result *models.AnalysisResult) {
+	rules, err := s.db.GetAlertRules(true)
+	if err != nil {
+		log.Printf("錯誤：[AlertService] %v", err)
+		return
+	}
+	doc := alerts.BuildDocument(video, result)
+	for _, rule := range rules {
+		parsed, err := alerts.Parse(rule.Expression)
+		if err != nil {
+			log.Printf("警告：[AlertService] 規則 %d '%s' 無法解析，略過: %v", rule.ID, rule.Name, err)
+			continue
+		}
+		if !parsed.Matches(doc) {
+			continue
+		}
+		firingID, created, err := s.db.RecordAlertFiring(rule.ID, video.ID)
+		if err != nil {
+			log.Printf("錯誤：[AlertService] %v", err)
+			continue
+		}
+		if !created {
+			log.Printf("資訊：[AlertService] 規則 '%s' 已對影片 ID %d 觸發過，略過。", rule.Name, video.ID)
+			continue
+		}
+		go func(rule models.AlertRule, firingID int64) {
+			status, errMsg := models.AlertFiringSent, sql.NullString{}
+			if err := s.send(rule, video, result); err != nil {
+				log.Printf("錯誤：[AlertService] 規則 '%s' 對影片 ID %d 的快訊寄送失敗: %v", rule.Name, video.ID, err)
+				status, errMsg = models.AlertFiringFailed, sql.NullString{String: err.Error(), Valid: true}
+			} else {
+				log.Printf("資訊：[AlertService] 規則 '%s' 對影片 ID %d 的快訊已寄送 (%s)。", rule.Name, video.ID, rule.Channel)
+			}
+			if err := s.db.UpdateAlertFiringStatus(firingID, status, errMsg); err != nil {
+				log.Printf("錯誤：[AlertService] %v", err)
+			}
+		}(rule, firingID)
+	}
+}
+
+// TestFire 以指定影片 (videoID 為 0 時使用最新完成分析的影片) 測試規則：
+// 無論是否符合規則都會寄送一則測試快訊，且不記錄觸發 (不影響去重)。回傳規則是否符合該影片。
+func (s *AlertService) TestFire(ruleID, videoID int64) (bool, error) {
+	rule, err := s.db.GetAlertRuleByID(ruleID)
+	if err != nil {
+		return false, err
+	}
+	if rule == nil {
+		return false, fmt.Errorf("找不到快訊規則 %d", ruleID)
+	}
+	parsed, err := alerts.Parse(rule.Expression)
+	if err != nil {
+		return false, fmt.Errorf("規則無法解析: %w", err)
+	}
+
+	var video *models.Video
+	var result *models.AnalysisResult
+	if videoID > 0 {
+		if video, err = s.db.GetVideoByID(videoID); err != nil {
+			return false, err
+		}
+		if video == nil {
+			return false, fmt.Errorf("找不到影片 %d", videoID)
+		}
+		if result, err = s.db.GetAnalysisResultByVideoID(videoID); err != nil {
+			return false, err
+		}
+	} else {
+		videos, results, err := s.db.GetCompletedVideosForFeed(models.FeedFilter{}, 1)
+		if err != nil {
+			return false, err
+		}
+		if len(videos) == 0 {
+			return false, fmt.Errorf("尚無已完成分析的影片可供測試")
+		}
+		video, result = &videos[0], &results[0]
+	}
+
+	matched := parsed.Matches(alerts.BuildDocument(*video, result))
+	testRule := *rule
+	testRule.Name = "[測試] " + rule.Name
+	if err := s.send(testRule, *video, result); err != nil {
+		return matched, err
+	}
+	log.Printf("資訊：[AlertService] 規則 '%s' 已以影片 ID %d 測試寄送 (符合: %t)。", rule.Name, video.ID, matched)
+	return matched, nil
+}
+
+func (s *AlertService) send(rule models.AlertRule, video models.Video, result *models.AnalysisResult) error {
+	msg := alerts.BuildMessage(rule, video, result, feeds.DashboardEntryURL(s.baseURL, video))
+	switch rule.Channel {
+	case models.AlertChannelEmail:
+		return alerts.SendEmail(s.cfg.SMTP, alerts.ParseRecipients(rule.Target), msg)
+	case models.AlertChannelChat:
+		return alerts.SendChat(s.httpClient, rule.Target, msg)
+	}
+	return fmt.Errorf("未知的快訊管道: %s", rule.Channel)
+}
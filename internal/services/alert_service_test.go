@@ -0,0 +1,161 @@
+package services
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/models"
+	"AiHackathon-admin/internal/web/handlers"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAlertDB 以記憶體保存快訊規則與觸發紀錄，(rule_id, video_id) 唯一，與 alert_firings 的唯一鍵相同
+type fakeAlertDB struct {
+	handlers.DBStore
+
+	rules []models.AlertRule
+
+	mu      sync.Mutex
+	nextID  int64
+	firings map[[2]int64]int64
+	status  map[int64]models.AlertFiringStatus
+}
+
+func newFakeAlertDB(rules ...models.AlertRule) *fakeAlertDB {
+	return &fakeAlertDB{rules: rules, firings: make(map[[2]int64]int64), status: make(map[int64]models.AlertFiringStatus)}
+}
+
+func (db *fakeAlertDB) GetAlertRules(enabledOnly bool) ([]models.AlertRule, error) {
+	var rules []models.AlertRule
+	for _, r := range db.rules {
+		if !enabledOnly || r.Enabled {
+			rules = append(rules, r)
+		}
+	}
+	return rules, nil
+}
+
+func (db *fakeAlertDB) RecordAlertFiring(ruleID, videoID int64) (int64, bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	key := [2]int64{ruleID, videoID}
+	if id, ok := db.firings[key]; ok {
+		return id, false, nil
+	}
+	db.nextID++
+	db.firings[key] = db.nextID
+	return db.nextID, true, nil
+}
+
+func (db *fakeAlertDB) UpdateAlertFiringStatus(id int64, status models.AlertFiringStatus, errMsg sql.NullString) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.status[id] = status
+	return nil
+}
+
+func (db *fakeAlertDB) statuses() map[int64]models.AlertFiringStatus {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	out := make(map[int64]models.AlertFiringStatus, len(db.status))
+	for k, v := range db.status {
+		out[k] = v
+	}
+	return out
+}
+
+func TestNotifyAnalysisCompletedFiresOncePerRule(t *testing.T) {
+	srv, received := newReceiver(t, http.StatusOK)
+	db := newFakeAlertDB(
+		models.AlertRule{ID: 1, Name: "重大", Expression: "importance >= A", Channel: models.AlertChannelChat, Target: srv.URL, Enabled: true},
+		models.AlertRule{ID: 2, Name: "台灣", Expression: "key_factors contains 台灣", Channel: models.AlertChannelChat, Target: srv.URL, Enabled: true},
+		models.AlertRule{ID: 3, Name: "不符合", Expression: "importance = S", Channel: models.AlertChannelChat, Target: srv.URL, Enabled: true},
+		models.AlertRule{ID: 4, Name: "無法解析", Expression: "importance >=", Channel: models.AlertChannelChat, Target: srv.URL, Enabled: true},
+		models.AlertRule{ID: 5, Name: "停用", Expression: "source = ap", Channel: models.AlertChannelChat, Target: srv.URL, Enabled: false},
+	)
+	svc, err := NewAlertService(&config.Config{}, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	video := models.Video{ID: 10, SourceName: "ap", SourceID: "1", Title: sql.NullString{String: "颱風", Valid: true}}
+	result := &models.AnalysisResult{ImportanceScore: json.RawMessage(`{"overall_rating":"A","key_factors":["台灣相關"]}`)}
+	// 重新分析同一部影片時不應重複觸發
+	svc.NotifyAnalysisCompleted(video, result)
+	svc.NotifyAnalysisCompleted(video, result)
+
+	var texts []string
+	for len(texts) < 2 {
+		select {
+		case r := <-received:
+			var payload map[string]string
+			if err := json.Unmarshal(r.body, &payload); err != nil {
+				t.Fatal(err)
+			}
+			texts = append(texts, payload["text"])
+		case <-time.After(5 * time.Second):
+			t.Fatalf("等待快訊逾時，已收到 %d 則", len(texts))
+		}
+	}
+	select {
+	case r := <-received:
+		t.Fatalf("同一部影片同一規則不應重複觸發，多收到: %s", r.body)
+	case <-time.After(200 * time.Millisecond):
+	}
+	var rule1, rule2 bool
+	for _, text := range texts {
+		rule1 = rule1 || strings.Contains(text, "規則：重大\n")
+		rule2 = rule2 || strings.Contains(text, "規則：台灣\n")
+	}
+	if !rule1 || !rule2 {
+		t.Errorf("應各收到規則 1 與 2 的快訊一次，收到: %q", texts)
+	}
+
+	// 寄送結果寫回觸發紀錄
+	deadline := time.Now().Add(5 * time.Second)
+	for len(db.statuses()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	statuses := db.statuses()
+	if len(statuses) != 2 {
+		t.Fatalf("應有 2 筆觸發紀錄狀態，got %v", statuses)
+	}
+	for id, status := range statuses {
+		if status != models.AlertFiringSent {
+			t.Errorf("觸發紀錄 %d 狀態 = %s, want %s", id, status, models.AlertFiringSent)
+		}
+	}
+
+	// 另一部影片仍會觸發
+	svc.NotifyAnalysisCompleted(models.Video{ID: 11, SourceName: "ap", SourceID: "2"}, result)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatal("不同影片應各自觸發規則")
+		}
+	}
+}
+
+func TestNotifyAnalysisCompletedRecordsFailure(t *testing.T) {
+	srv, received := newReceiver(t, http.StatusInternalServerError)
+	db := newFakeAlertDB(models.AlertRule{ID: 1, Name: "全部", Expression: "source = ap", Channel: models.AlertChannelChat, Target: srv.URL, Enabled: true})
+	svc, err := NewAlertService(&config.Config{}, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.NotifyAnalysisCompleted(models.Video{ID: 1, SourceName: "ap"}, nil)
+	<-received
+
+	deadline := time.Now().Add(5 * time.Second)
+	for db.statuses()[1] == "" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := db.statuses()[1]; got != models.AlertFiringFailed {
+		t.Errorf("寄送失敗時觸發紀錄狀態 = %q, want %s", got, models.AlertFiringFailed)
+	}
+}
@@ -4,23 +4,30 @@ import (
 	"AiHackathon-admin/internal/clients/gemini"
 	"AiHackathon-admin/internal/config"
 	"AiHackathon-admin/internal/models"
+	"AiHackathon-admin/internal/prompttpl"
+	"AiHackathon-admin/internal/renditions"
+	"AiHackathon-admin/internal/segmentation"
 	"AiHackathon-admin/internal/web/handlers"
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // NASStorage 介面定義了 AnalyzeService 需要的 NAS 操作。
 // (應在 internal/services/interfaces.go 中唯一定義)
 // type NASStorage interface {
-// 	ReadVideo(filePath string) ([]byte, error)
+// 	OpenVideo(filePath string) (io.ReadSeekCloser, error)
 // }
 
 // AnalyzeService 結構
@@ -29,6 +36,13 @@ type AnalyzeService struct {
 	db           handlers.DBStore
 	nas          NASStorage // 來自同 package services 下的 interfaces.go
 	geminiClient *gemini.Client
+	prompts      *PromptService
+	notifiers    []AnalysisCompletionNotifier
+	budget       BudgetChecker // 為 nil 時不檢查每月預算
+	textMu       sync.Mutex    // 逐一處理文本元數據分析 (排程掃描與檔案監看共用)
+
+	manifestMu           sync.Mutex // 逐一同步 nas_files 清單 (排程與手動觸發可能同時執行)
+	lastFullManifestScan time.Time  // 上次全量比對清單的時間；啟動後第一次同步一律全量比對
 }
 
 // NewAnalyzeService 建立 AnalyzeService 實例
@@ -37,6 +51,7 @@ func NewAnalyzeService(
 	db handlers.DBStore,
 	nas NASStorage,
 	geminiClient *gemini.Client,
+	prompts *PromptService,
 ) (*AnalyzeService, error) {
 	if cfg == nil {
 		return nil, fmt.Errorf("AnalyzeService：設定不得為空")
@@ -50,16 +65,49 @@ func NewAnalyzeService(
 	if geminiClient == nil {
 		return nil, fmt.Errorf("AnalyzeService：Gemini 客戶端不得為空")
 	}
+	if prompts == nil {
+		return nil, fmt.Errorf("AnalyzeService：PromptService 不得為空")
+	}
 	log.Println("資訊：AnalyzeService 初始化完成。")
 	return &AnalyzeService{
 		cfg:          cfg,
 		db:           db,
 		nas:          nas,
 		geminiClient: geminiClient,
+		prompts:      prompts,
 	}, nil
 }
 
-// scanVideoFiles 掃描 NAS 路徑，找到成對的影片檔案和 .txt 描述檔
+// ErrBudgetExceeded 本月 Gemini 花費已達 costs.monthlyBudget，暫停所有分析 (排程、手動觸發與檔案監看皆同)
+var ErrBudgetExceeded = errors.New("本月 Gemini 花費已達預算")
+
+// SetBudgetChecker 設定每月預算的檢查對象；每次送出 Gemini 分析前都會檢查，達到預算時不再呼叫 Gemini
+func (s *AnalyzeService) SetBudgetChecker(b BudgetChecker) {
+	s.budget = b
+}
+
+// checkBudget 本月花費已達預算時回傳 ErrBudgetExceeded；無法查詢花費時僅記錄警告並繼續分析
+func (s *AnalyzeService) checkBudget() error {
+	if s.budget == nil {
+		return nil
+	}
+	status, err := s.budget.CheckBudget()
+	if err != nil {
+		log.Printf("警告：[AnalyzeService] 無法檢查本月 Gemini 預算，仍繼續分析: %v", err)
+		return nil
+	}
+	if status.Exceeded {
+		return fmt.Errorf("%w (%s 已花費 %.2f，預算 %.2f)", ErrBudgetExceeded, status.Month, status.Spent, status.Budget)
+	}
+	return nil
+}
+
+// supportedVideoExtensions 掃描 NAS 時視為影片檔的副檔名
+var supportedVideoExtensions = map[string]bool{
+	".mp4": true, ".mov": true, ".avi": true, ".mkv": true, ".ts": true, ".flv": true, ".wmv": true,
+}
+
+// scanVideoFiles 掃描 NAS 路徑，找到成對的影片檔案和 .txt 描述檔；物件儲存以 bucket 列表取代讀取本地目錄
 func (s *AnalyzeService) scanVideoFiles() ([]models.VideoFileInfo, error) {
 	var videoFileInfos []models.VideoFileInfo
 	downloadPath, err := filepath.Abs(s.cfg.NAS.VideoPath)
@@ -67,103 +115,61 @@ func (s *AnalyzeService) scanVideoFiles() ([]models.VideoFileInfo, error) {
 		return nil, fmt.Errorf("無法取得 Download 路徑的絕對路徑 '%s': %w", s.cfg.NAS.VideoPath, err)
 	}
 	log.Printf("資訊：[AnalyzeService] 開始掃描影片及 TXT 檔案於路徑: %s\n", downloadPath)
-	supportedVideoExtensions := map[string]bool{
-		".mp4": true, ".mov": true, ".avi": true, ".mkv": true, ".ts": true, ".flv": true, ".wmv": true,
-	}
-	txtExtension := ".txt"
 
-	// 讀取 Download 目錄下的所有子資料夾
-	sourceDirs, err := os.ReadDir(downloadPath)
-	if err != nil {
-		return nil, fmt.Errorf("[AnalyzeService] 讀取 Download 目錄 '%s' 失敗: %w", downloadPath, err)
-	}
-
-	for _, sourceDirEntry := range sourceDirs {
-		if !sourceDirEntry.IsDir() {
-			continue
+	var dirs []nasDir
+	if lister, ok := s.nas.(NASLister); ok {
+		objects, err := lister.ListFiles("")
+		if err != nil {
+			return nil, fmt.Errorf("[AnalyzeService] 列出物件儲存檔案失敗: %w", err)
 		}
-		sourceName := sourceDirEntry.Name() // 使用第一層子資料夾名稱作為 source
-		sourcePath := filepath.Join(downloadPath, sourceName)
-
-		// 掃描 source 目錄下的所有子資料夾（每個子資料夾代表一個影片ID）
-		videoIDDirs, err := os.ReadDir(sourcePath)
+		dirs = groupObjectsByDir(objects)
+	} else {
+		// 讀取 Download 目錄下的所有子資料夾
+		sourceDirs, err := os.ReadDir(downloadPath)
 		if err != nil {
-			log.Printf("警告：[AnalyzeService] 讀取來源目錄 '%s' 失敗: %v\n", sourcePath, err)
-			continue
+			return nil, fmt.Errorf("[AnalyzeService] 讀取 Download 目錄 '%s' 失敗: %w", downloadPath, err)
 		}
-
-		for _, videoIDDirEntry := range videoIDDirs {
-			if !videoIDDirEntry.IsDir() {
+		for _, sourceDirEntry := range sourceDirs {
+			if !sourceDirEntry.IsDir() {
 				continue
 			}
-			videoID := videoIDDirEntry.Name()
-			videoIDPath := filepath.Join(sourcePath, videoID)
+			sourceName := sourceDirEntry.Name() // 使用第一層子資料夾名稱作為 source
+			sourcePath := filepath.Join(downloadPath, sourceName)
 
-			var videoFilePath, txtFilePath, videoFileName string
-			var modTime time.Time
-
-			// 在影片ID目錄下尋找影片和 TXT 檔案
-			entries, err := os.ReadDir(videoIDPath)
+			// 掃描 source 目錄下的所有子資料夾（每個子資料夾代表一個影片ID）
+			videoIDDirs, err := os.ReadDir(sourcePath)
 			if err != nil {
-				log.Printf("警告：[AnalyzeService] 讀取影片ID目錄 '%s' 失敗: %v\n", videoIDPath, err)
+				log.Printf("警告：[AnalyzeService] 讀取來源目錄 '%s' 失敗: %v\n", sourcePath, err)
 				continue
 			}
-
-			for _, entry := range entries {
-				if entry.IsDir() {
+			for _, videoIDDirEntry := range videoIDDirs {
+				if !videoIDDirEntry.IsDir() {
 					continue
 				}
-				ext := strings.ToLower(filepath.Ext(entry.Name()))
-				if supportedVideoExtensions[ext] {
-					if videoFilePath == "" {
-						videoFilePath = filepath.Join(videoIDPath, entry.Name())
-						videoFileName = entry.Name()
-						fi, _ := entry.Info()
-						if fi != nil {
-							modTime = fi.ModTime()
-						}
-					} else {
-						log.Printf("警告：[AnalyzeService] 影片ID目錄 '%s' 中找到多個影片檔案，使用第一個: %s\n", videoIDPath, videoFileName)
-					}
-				} else if ext == txtExtension {
-					if txtFilePath == "" {
-						txtFilePath = filepath.Join(videoIDPath, entry.Name())
-					} else {
-						log.Printf("警告：[AnalyzeService] 影片ID目錄 '%s' 中找到多個 TXT 檔案，使用第一個。\n", videoIDPath)
-					}
+				objects, err := listLocalVideoDir(downloadPath, sourceName, videoIDDirEntry.Name())
+				if err != nil {
+					log.Printf("警告：[AnalyzeService] %v\n", err)
+					continue
 				}
+				dirs = append(dirs, nasDir{sourceName: sourceName, videoID: videoIDDirEntry.Name(), objects: objects})
 			}
+		}
+	}
 
-			if videoFilePath != "" && txtFilePath != "" {
-				relativePath, _ := filepath.Rel(downloadPath, videoFilePath)
-				videoFileInfos = append(videoFileInfos, models.VideoFileInfo{
-					VideoAbsolutePath: videoFilePath,
-					TextFilePath:      txtFilePath,
-					RelativePath:      relativePath,
-					SourceName:        sourceName, // 使用第一層子資料夾名稱作為 source
-					OriginalID:        videoID,    // 使用第二層子資料夾名稱作為 ID
-					VideoFileName:     videoFileName,
-					ModTime:           modTime,
-				})
-				log.Printf("資訊：[AnalyzeService] 找到匹配的影片和TXT: V: %s, T: %s (來源: %s, ID: %s)\n",
-					videoFileName, filepath.Base(txtFilePath), sourceName, videoID)
-			} else if txtFilePath != "" {
-				// 只有 TXT 檔案的情況
-				relativePath, _ := filepath.Rel(downloadPath, txtFilePath)
-				videoFileInfos = append(videoFileInfos, models.VideoFileInfo{
-					VideoAbsolutePath: "", // 空字串表示沒有影片檔案
-					TextFilePath:      txtFilePath,
-					RelativePath:      relativePath,
-					SourceName:        sourceName,
-					OriginalID:        videoID,
-					VideoFileName:     "", // 空字串表示沒有影片檔案
-					ModTime:           modTime,
-				})
-				log.Printf("資訊：[AnalyzeService] 找到只有 TXT 檔案的記錄: T: %s (來源: %s, ID: %s)\n",
-					filepath.Base(txtFilePath), sourceName, videoID)
-			} else if videoFilePath != "" {
-				log.Printf("警告：[AnalyzeService] 影片ID目錄 '%s' 中只找到影片檔案。\n", videoIDPath)
-			}
+	for _, dir := range dirs {
+		info := s.videoDirInfo(downloadPath, dir.sourceName, dir.videoID, dir.objects)
+		switch {
+		case info.VideoAbsolutePath != "" && info.TextFilePath != "":
+			videoFileInfos = append(videoFileInfos, info)
+			log.Printf("資訊：[AnalyzeService] 找到匹配的影片和TXT: V: %s, T: %s (來源: %s, ID: %s)\n",
+				info.VideoFileName, filepath.Base(info.TextFilePath), info.SourceName, info.OriginalID)
+		case info.TextFilePath != "":
+			// 只有 TXT 檔案的情況
+			videoFileInfos = append(videoFileInfos, info)
+			log.Printf("資訊：[AnalyzeService] 找到只有 TXT 檔案的記錄: T: %s (來源: %s, ID: %s)\n",
+				filepath.Base(info.TextFilePath), info.SourceName, info.OriginalID)
+		case info.VideoAbsolutePath != "":
+			log.Printf("警告：[AnalyzeService] 影片ID目錄 '%s' 中只找到影片檔案。\n", filepath.Dir(info.VideoAbsolutePath))
 		}
 	}
 
@@ -171,58 +177,178 @@ func (s *AnalyzeService) scanVideoFiles() ([]models.VideoFileInfo, error) {
 	return videoFileInfos, nil
 }
 
-// *** getPromptTextAndVersionFromFile 函式定義 ***
-func getPromptTextAndVersionFromFile(configuredPath, versionKeyFromConfig, fallbackPrompt, fallbackVersionKey string) (string, string, error) {
-	if configuredPath == "" {
-		log.Printf("警告：[Service] Prompt 版本 '%s' 的檔案路徑為空，將使用備用 Prompt (版本: %s)。\n", versionKeyFromConfig, fallbackVersionKey)
-		return fallbackPrompt, fallbackVersionKey, nil
+// nasDir 一個影片ID目錄 (Download/<source>/<id>/) 及其中的檔案
+type nasDir struct {
+	sourceName string
+	videoID    string
+	modTimeNs  int64 // 目錄修改時間；物件儲存沒有目錄，為其中檔案最新的修改時間
+	objects    []models.StorageObject
+}
+
+// groupObjectsByDir 將物件儲存列表依影片ID目錄分組，只保留 <source>/<id>/<檔名> 形式的物件 (與讀取本地目錄相同，不含更深層的檔案)
+func groupObjectsByDir(objects []models.StorageObject) []nasDir {
+	byDir := make(map[string]*nasDir)
+	var order []string
+	for _, o := range objects {
+		parts := strings.Split(o.Path, "/")
+		if len(parts) != 3 || hasHiddenPart(parts[:2]) || parts[2] == "" {
+			continue
+		}
+		key := parts[0] + "/" + parts[1]
+		dir := byDir[key]
+		if dir == nil {
+			dir = &nasDir{sourceName: parts[0], videoID: parts[1]}
+			byDir[key] = dir
+			order = append(order, key)
+		}
+		dir.objects = append(dir.objects, o)
+		if ns := o.ModTime.UnixNano(); ns > dir.modTimeNs {
+			dir.modTimeNs = ns
+		}
+	}
+	sort.Strings(order)
+	dirs := make([]nasDir, 0, len(order))
+	for _, key := range order {
+		dirs = append(dirs, *byDir[key])
+	}
+	return dirs
+}
+
+func hasHiddenPart(parts []string) bool {
+	for _, p := range parts {
+		if p == "" || strings.HasPrefix(p, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// listLocalVideoDir 讀取本地影片ID目錄中的檔案 (不含子目錄)
+func listLocalVideoDir(downloadPath, sourceName, videoID string) ([]models.StorageObject, error) {
+	videoIDPath := filepath.Join(downloadPath, sourceName, videoID)
+	entries, err := os.ReadDir(videoIDPath)
+	if err != nil {
+		return nil, fmt.Errorf("讀取影片ID目錄 '%s' 失敗: %w", videoIDPath, err)
+	}
+	var objects []models.StorageObject
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		objects = append(objects, models.StorageObject{Path: sourceName + "/" + videoID + "/" + entry.Name(), Size: fi.Size(), ModTime: fi.ModTime()})
 	}
-	if _, err := os.Stat(configuredPath); os.IsNotExist(err) {
-		log.Printf("錯誤：[Service] Prompt 檔案 '%s' (版本 '%s') 不存在，將使用備用 Prompt (版本: %s)。\n", configuredPath, versionKeyFromConfig, fallbackVersionKey)
-		return fallbackPrompt, fallbackVersionKey, fmt.Errorf("prompt 檔案 '%s' (版本 '%s') 不存在", configuredPath, versionKeyFromConfig)
+	return objects, nil
+}
+
+// listVideoDir 列出影片ID目錄中的檔案；物件儲存以列表取代讀取本地目錄
+func (s *AnalyzeService) listVideoDir(downloadPath, sourceName, videoID string) ([]models.StorageObject, error) {
+	lister, ok := s.nas.(NASLister)
+	if !ok {
+		return listLocalVideoDir(downloadPath, sourceName, videoID)
 	}
-	promptBytes, err := os.ReadFile(configuredPath)
+	objects, err := lister.ListFiles(sourceName + "/" + videoID + "/")
 	if err != nil {
-		log.Printf("錯誤：[Service] 讀取 Prompt 檔案 '%s' (版本 '%s') 失敗: %v。將使用備用 Prompt (版本: %s)。\n", configuredPath, versionKeyFromConfig, err, fallbackVersionKey)
-		return fallbackPrompt, fallbackVersionKey, fmt.Errorf("讀取 prompt 檔案 '%s' (版本 '%s') 失敗: %w", configuredPath, versionKeyFromConfig, err)
+		return nil, fmt.Errorf("列出影片ID目錄 '%s/%s' 的物件失敗: %w", sourceName, videoID, err)
+	}
+	var direct []models.StorageObject
+	for _, dir := range groupObjectsByDir(objects) {
+		direct = append(direct, dir.objects...)
 	}
-	return string(promptBytes), versionKeyFromConfig, nil // 成功讀取檔案，使用配置的版本號
+	return direct, nil
 }
 
-// *** 結束 getPromptTextAndVersionFromFile 定義 ***
+// scanVideoDir 在 Download/<source>/<id>/ 目錄中尋找所有影片/音訊版本與 TXT 檔案，見 videoDirInfo
+func (s *AnalyzeService) scanVideoDir(downloadPath string, sourceName string, videoID string) (models.VideoFileInfo, error) {
+	objects, err := s.listVideoDir(downloadPath, sourceName, videoID)
+	if err != nil {
+		return models.VideoFileInfo{SourceName: sourceName, OriginalID: videoID}, err
+	}
+	return s.videoDirInfo(downloadPath, sourceName, videoID, objects), nil
+}
+
+// videoDirInfo 由影片ID目錄中的檔案找出所有影片/音訊版本與 TXT 檔案，依 nas.renditions 規則判斷版本角色；
+// VideoAbsolutePath 為 master 版本 (沒有 master 時為畫質最高的 proxy)。
+// 找不到的檔案其路徑欄位為空字串；只有 TXT (或只有音訊) 時 VideoAbsolutePath 與 VideoFileName 為空。
+// 物件儲存時路徑為本地快取位置：TXT 檔案會先下載，影片則在分析時以 GetVideoAbsolutePath 下載
+func (s *AnalyzeService) videoDirInfo(downloadPath string, sourceName string, videoID string, objects []models.StorageObject) models.VideoFileInfo {
+	info := models.VideoFileInfo{
+		SourceName: sourceName, // 使用第一層子資料夾名稱作為 source
+		OriginalID: videoID,    // 使用第二層子資料夾名稱作為 ID
+	}
+	_, remote := s.nas.(NASLister)
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Path < objects[j].Path })
+
+	var files []models.VideoFile
+	for _, o := range objects {
+		ext := strings.ToLower(path.Ext(o.Path))
+		switch {
+		case supportedVideoExtensions[ext] || renditions.AudioExtensions[ext]:
+			files = append(files, models.VideoFile{Path: o.Path, Size: o.Size, ModTime: o.ModTime})
+		case ext == ".txt":
+			txtPath := filepath.Join(downloadPath, filepath.FromSlash(o.Path))
+			if remote {
+				local, err := s.nas.GetVideoAbsolutePath(o.Path)
+				if err != nil {
+					log.Printf("警告：[AnalyzeService] 下載 TXT 檔案 '%s' 失敗: %v\n", o.Path, err)
+					continue
+				}
+				txtPath = local
+			}
+			info.TextFilePaths = append(info.TextFilePaths, txtPath)
+		}
+	}
+	if len(info.TextFilePaths) > 0 {
+		info.TextFilePath = info.TextFilePaths[0]
+	}
+	info.Renditions = renditions.Classify(files, renditions.RulesFromConfig(s.cfg.NAS.Renditions))
+	if len(info.Renditions) > 0 && info.Renditions[0].Role != models.VideoFileAudio {
+		master := info.Renditions[0]
+		info.VideoAbsolutePath = filepath.Join(downloadPath, filepath.FromSlash(master.Path))
+		info.VideoFileName = path.Base(master.Path)
+		info.ModTime = master.ModTime
+	}
+	switch {
+	case info.VideoAbsolutePath != "" && info.TextFilePath != "":
+		info.RelativePath, _ = filepath.Rel(downloadPath, info.VideoAbsolutePath)
+	case info.TextFilePath != "":
+		info.RelativePath, _ = filepath.Rel(downloadPath, info.TextFilePath)
+	}
+	return info
+}
 
 // analyzeTextFileContent 使用 Gemini 分析 TXT 檔案內容並回傳結構化的元數據
-func (s *AnalyzeService) analyzeTextFileContent(ctx context.Context, txtFilePath string) (*models.ParsedTxtData, string, error) {
+// 同一項目有多個 TXT 檔案時依 nas.renditions.textMode 合併或選擇其中之一
+func (s *AnalyzeService) analyzeTextFileContent(ctx context.Context, videoID int64, sourceName string, txtFilePaths []string) (*models.ParsedTxtData, string, error) {
+	txtFilePath := strings.Join(txtFilePaths, ", ")
 	log.Printf("資訊：[AnalyzeService] 開始使用 Gemini 分析 TXT 檔案: %s\n", txtFilePath)
-	txtContentBytes, err := os.ReadFile(txtFilePath)
+	txtContent, err := renditions.ReadTexts(txtFilePaths, s.cfg.NAS.Renditions.TextMode)
 	if err != nil {
-		return nil, "", fmt.Errorf("無法讀取 TXT 檔案 '%s': %w", txtFilePath, err)
+		return nil, "", err
 	}
-	txtContent := string(txtContentBytes)
 	if strings.TrimSpace(txtContent) == "" {
 		log.Printf("警告：[AnalyzeService] TXT 檔案 '%s' 內容為空，跳過 Gemini 分析。\n", txtFilePath)
 		return &models.ParsedTxtData{}, "no_prompt_needed_empty_txt", nil
 	}
 
-	currentVersionKey := s.cfg.Prompts.TextFileAnalysis.CurrentVersion
-	promptFilePath, pathOk := s.cfg.Prompts.TextFileAnalysis.Versions[currentVersionKey] // pathOk 在這裡被賦值
-	if !pathOk {                                                                         // *** 使用 pathOk 檢查 ***
-		log.Printf("警告：[AnalyzeService] TextFileAnalysis Prompt 版本 '%s' 在設定檔的 versions map 中未找到對應路徑。", currentVersionKey)
-		return nil, currentVersionKey, fmt.Errorf("未在 versions map 中找到文本分析 Prompt 的檔案路徑 (版本: %s)", currentVersionKey)
-	}
-	if promptFilePath == "" { // 額外檢查路徑是否為空
-		log.Printf("警告：[AnalyzeService] TextFileAnalysis Prompt 版本 '%s' 的檔案路徑為空。", currentVersionKey)
-		return nil, currentVersionKey, fmt.Errorf("文本分析 Prompt 版本 '%s' 的檔案路徑為空", currentVersionKey)
-	}
-
 	fallbackPrompt := "請從提供的文本中提取標題、創建日期（YYYY-MM-DD HH:MM:SS）、時長（秒）、主題（陣列）、地點和 SHOTLIST，並以 JSON 格式回傳。"
-	promptText, actualPromptVersion, errPrompt := getPromptTextAndVersionFromFile(promptFilePath, currentVersionKey, fallbackPrompt, "default-text-fallback")
-	if errPrompt != nil && promptFilePath != "" {
-		log.Printf("警告：讀取指定的文本 Prompt 檔案 '%s' 失敗 (%v)，將使用硬編碼的備用 Prompt。", promptFilePath, errPrompt)
+	promptText, actualPromptVersion, errPrompt := s.prompts.Resolve(models.PromptKindText)
+	if errors.Is(errPrompt, errPromptNotConfigured) {
+		log.Printf("警告：[AnalyzeService] %v", errPrompt)
+		return nil, actualPromptVersion, fmt.Errorf("找不到文本分析 Prompt: %w", errPrompt)
+	}
+	if errPrompt != nil {
+		log.Printf("警告：讀取文本 Prompt 失敗 (%v)，將使用硬編碼的備用 Prompt。", errPrompt)
+		promptText, actualPromptVersion = fallbackPrompt, "default-text-fallback"
 	}
-	log.Printf("資訊：[AnalyzeService] 使用 TextFileAnalysis Prompt 版本: %s (來自檔案: %s)\n", actualPromptVersion, promptFilePath)
+	log.Printf("資訊：[AnalyzeService] 使用 TextFileAnalysis Prompt 版本: %s\n", actualPromptVersion)
 
-	cleanedJSONString, err := s.geminiClient.AnalyzeText(ctx, txtContent, promptText)
+	cleanedJSONString, callInfo, err := s.geminiClient.AnalyzeText(ctx, txtContent, promptText)
+	log.Printf("資訊：[AnalyzeService] TXT 檔案 '%s' 使用模型 '%s' 分析 (參數: %s)\n", txtFilePath, callInfo.ModelName, callInfo.Params)
+	s.recordUsage(videoID, sourceName, models.PromptKindText, actualPromptVersion, callInfo, err == nil)
 	if err != nil {
 		return nil, actualPromptVersion, fmt.Errorf("Gemini 分析 TXT 內容失敗 ('%s'): %w", txtFilePath, err)
 	}
@@ -242,27 +368,64 @@ func (s *AnalyzeService) analyzeTextFileContent(ctx context.Context, txtFilePath
 	return &parsedData, actualPromptVersion, nil
 }
 
-// buildPromptForVideo (修正 ok 的使用)
-func (s *AnalyzeService) buildPromptForVideo(videoInfo models.VideoFileInfo, txtAnalyzedData *models.ParsedTxtData) (promptText string, promptVersion string) {
-	currentVersionKey := s.cfg.Prompts.VideoAnalysis.CurrentVersion
-	promptFilePath, pathOk := s.cfg.Prompts.VideoAnalysis.Versions[currentVersionKey] // pathOk 在這裡被賦值
+// buildPromptForVideo 取得目前使用的影片內容分析 Prompt，並以影片元數據填入其中的佔位符 ({{.Title}} 等)；
+// 無法取得或套用時使用備用 Prompt。promptHash 為實際送出內容的 SHA-256。
+func (s *AnalyzeService) buildPromptForVideo(videoInfo models.VideoFileInfo, txtAnalyzedData *models.ParsedTxtData) (promptText string, promptVersion string, promptHash string) {
 	fallbackPrompt := "請分析此影片的音視覺內容，提供短摘要、列點摘要、BITE、影片中提及的地點、重要性評分、關鍵詞、影片內容的分類和素材類型。"
-
-	if !pathOk { // *** 使用 pathOk 檢查 ***
-		log.Printf("警告：[AnalyzeService] VideoAnalysis Prompt 版本 '%s' 在設定檔的 versions map 中未找到對應路徑。將使用備用。", currentVersionKey)
-		return fallbackPrompt, "default-video-fallback-no-key"
+	promptTemplate, promptVersion, err := s.prompts.Resolve(models.PromptKindVideo)
+	switch {
+	case errors.Is(err, errPromptNotConfigured):
+		log.Printf("警告：[AnalyzeService] %v。將使用備用。", err)
+		return fallbackPrompt, "default-video-fallback-no-key", prompttpl.Hash(fallbackPrompt)
+	case errors.Is(err, errPromptInvalidTemplate):
+		log.Printf("警告：[AnalyzeService] 影片 Prompt 版本 '%s' %v。將使用備用。", promptVersion, err)
+		return fallbackPrompt, "default-video-fallback-invalid-template", prompttpl.Hash(fallbackPrompt)
+	case err != nil:
+		log.Printf("警告：讀取影片 Prompt 失敗 (%v)，將使用硬編碼的備用 Prompt。", err)
+		return fallbackPrompt, "default-video-fallback-read-err", prompttpl.Hash(fallbackPrompt)
 	}
-	if promptFilePath == "" { // 額外檢查路徑是否為空
-		log.Printf("警告：[AnalyzeService] VideoAnalysis Prompt 版本 '%s' 的檔案路徑為空。將使用備用。", currentVersionKey)
-		return fallbackPrompt, "default-video-fallback-empty-path"
+
+	promptText, err = prompttpl.Render(promptVersion, promptTemplate, promptTemplateData(videoInfo, txtAnalyzedData))
+	if err != nil {
+		log.Printf("警告：[AnalyzeService] %v。將使用備用。", err)
+		return fallbackPrompt, "default-video-fallback-render-err", prompttpl.Hash(fallbackPrompt)
 	}
+	promptHash = prompttpl.Hash(promptText)
+	log.Printf("資訊：[AnalyzeService] 使用 VideoAnalysis Prompt 版本: %s (hash: %.12s)\n", promptVersion, promptHash)
+	return promptText, promptVersion, promptHash
+}
 
-	textFromFile, actualPromptVersion, errPrompt := getPromptTextAndVersionFromFile(promptFilePath, currentVersionKey, fallbackPrompt, "default-video-fallback-read-err")
-	if errPrompt != nil && promptFilePath != "" {
-		log.Printf("警告：讀取指定的影片 Prompt 檔案 '%s' 失敗 (%v)，將使用硬編碼的備用 Prompt。", promptFilePath, errPrompt)
+// promptTemplateData 將影片檔案資訊與文本元數據轉為 prompt 範本變數
+func promptTemplateData(videoInfo models.VideoFileInfo, txtAnalyzedData *models.ParsedTxtData) prompttpl.Data {
+	data := prompttpl.Data{
+		Source:   strings.ToUpper(videoInfo.SourceName),
+		SourceID: videoInfo.OriginalID,
+		FileName: videoInfo.VideoFileName,
 	}
-	log.Printf("資訊：[AnalyzeService] 使用 VideoAnalysis Prompt 版本: %s (來自檔案: %s)\n", actualPromptVersion, promptFilePath)
-	return textFromFile, actualPromptVersion
+	if txtAnalyzedData == nil {
+		return data
+	}
+	data.Title = txtAnalyzedData.Title
+	data.Shotlist = txtAnalyzedData.ShotlistContent
+	data.Location = txtAnalyzedData.Location
+	data.PublishedAt = txtAnalyzedData.CreationDateStr
+	if len(txtAnalyzedData.Subjects) > 0 {
+		_ = json.Unmarshal(txtAnalyzedData.Subjects, &data.Subjects)
+	}
+	if len(txtAnalyzedData.DurationSeconds) > 0 {
+		// Gemini 可能以數字或字串回傳時長
+		var secs json.Number
+		if json.Unmarshal(txtAnalyzedData.DurationSeconds, &secs) != nil {
+			var str string
+			if json.Unmarshal(txtAnalyzedData.DurationSeconds, &str) == nil {
+				secs = json.Number(strings.TrimSpace(str))
+			}
+		}
+		if n, err := secs.Int64(); err == nil {
+			data.DurationSecs = n
+		}
+	}
+	return data
 }
 
 // logAnalysisResult (保持不變)
@@ -272,176 +435,247 @@ func (s *AnalyzeService) logAnalysisResult(videoPath string, result *models.Anal
 // ExecuteTextAnalysisPipeline (修正 ok 的使用)
 func (s *AnalyzeService) ExecuteTextAnalysisPipeline() error {
 	log.Println("資訊：[AnalyzeService-TextPipeline] 開始執行文本元數據分析流程...")
-	videoFileInfos, err := s.scanVideoFiles()
+	candidates, err := s.syncNASManifest()
 	if err != nil {
-		log.Printf("錯誤：[AnalyzeService-TextPipeline] 掃描檔案失敗: %v", err)
+		log.Printf("錯誤：[AnalyzeService-TextPipeline] 同步 NAS 檔案清單失敗: %v", err)
 		return err
 	}
-	if len(videoFileInfos) == 0 {
-		log.Println("資訊：[AnalyzeService-TextPipeline] 未找到任何影片/TXT 配對進行處理。")
+	if len(candidates) == 0 {
+		log.Println("資訊：[AnalyzeService-TextPipeline] 沒有需要處理的影片/TXT 配對。")
 		return nil
 	}
 	var successCount, failCount int
-	for _, videoInfo := range videoFileInfos {
-		log.Printf("資訊：[AnalyzeService-TextPipeline] 處理 TXT 檔案: %s (影片: %s)\n", videoInfo.TextFilePath, videoInfo.VideoFileName)
-
-		// 先檢查資料庫中是否存在對應的 source_id 記錄
-		existingVideo, getErr := s.db.GetVideoBySourceID(videoInfo.SourceName, videoInfo.OriginalID)
-		if getErr != nil {
-			log.Printf("錯誤：[AnalyzeService-TextPipeline] 查詢影片 SourceID %s 狀態失敗: %v. 跳過此文本分析.\n", videoInfo.OriginalID, getErr)
-			failCount++
-			continue
-		}
-
-		// 如果記錄存在且狀態為 completed，則跳過分析
-		if existingVideo != nil && existingVideo.AnalysisStatus == models.StatusCompleted {
-			log.Printf("資訊：[AnalyzeService-TextPipeline] 影片 SourceID %s 狀態為 %s，已完成分析，跳過文本分析。\n", videoInfo.OriginalID, existingVideo.AnalysisStatus)
-			continue
-		}
-
-		baseVideoForFind := &models.Video{SourceName: videoInfo.SourceName, SourceID: videoInfo.OriginalID, NASPath: videoInfo.RelativePath, FetchedAt: videoInfo.ModTime}
-		videoID, findErr := s.db.FindOrCreateVideo(baseVideoForFind)
-		if findErr != nil {
-			log.Printf("錯誤：[AnalyzeService-TextPipeline] 為 TXT '%s' 查找/建立基礎影片記錄失敗: %v", videoInfo.TextFilePath, findErr)
-			failCount++
-			continue
-		}
-		if videoID == 0 {
-			log.Printf("錯誤：[AnalyzeService-TextPipeline] FindOrCreateVideo 為 TXT '%s' 回傳了無效的 videoID (0)。\n", videoInfo.TextFilePath)
-			failCount++
-			continue
-		}
-		existingVideo, getErr = s.db.GetVideoByID(videoID)
-		if getErr != nil {
-			log.Printf("錯誤：[AnalyzeService-TextPipeline] 查詢影片 ID %d 狀態失敗: %v. 跳過此文本分析.\n", videoID, getErr)
-			failCount++
-			continue
-		}
-		if existingVideo != nil && (existingVideo.AnalysisStatus == models.StatusMetadataExtracted || existingVideo.AnalysisStatus == models.StatusProcessing || existingVideo.AnalysisStatus == models.StatusCompleted || existingVideo.AnalysisStatus == models.StatusVideoAnalysisFailed) {
-			log.Printf("資訊：[AnalyzeService-TextPipeline] 影片 ID %d (TXT: %s) 狀態為 %s，已提取過元數據或正在/已完成後續分析，跳過文本分析。\n", videoID, videoInfo.TextFilePath, existingVideo.AnalysisStatus)
+	for _, c := range candidates {
+		if c.reanalyze && c.status == models.StatusArchived {
+			log.Printf("資訊：[AnalyzeService-TextPipeline] 影片 ID %d 已由保留政策封存，不重新分析被修改的檔案。\n", c.videoID)
+			if err := s.db.MarkNASFilesAnalyzed(c.info.SourceName, c.info.OriginalID, c.videoID); err != nil {
+				log.Printf("警告：[AnalyzeService-TextPipeline] 更新影片 ID %d 的 NAS 檔案清單分析紀錄失敗: %v\n", c.videoID, err)
+			}
 			continue
 		}
-		updateStatusErr := s.db.UpdateVideoAnalysisStatus(videoID, models.StatusMetadataExtracting, sql.NullTime{Time: time.Now(), Valid: true}, sql.NullString{})
-		if updateStatusErr != nil {
-			log.Printf("警告：[AnalyzeService-TextPipeline] 更新影片 ID %d 狀態為 '%s' 失敗: %v\n", videoID, models.StatusMetadataExtracting, updateStatusErr)
-		}
-		ctxTxt, cancelTxt := context.WithTimeout(context.Background(), 3*time.Minute)
-		parsedTxtData, _, txtErr := s.analyzeTextFileContent(ctxTxt, videoInfo.TextFilePath)
-		cancelTxt()
-		currentTime := time.Now()
-		if txtErr != nil {
-			log.Printf("錯誤：[AnalyzeService-TextPipeline] 分析 TXT 檔案 '%s' (VideoID: %d) 失敗: %v\n", videoInfo.TextFilePath, videoID, txtErr)
-			s.db.UpdateVideoAnalysisStatus(videoID, models.StatusTxtAnalysisFailed, sql.NullTime{Time: currentTime, Valid: true}, sql.NullString{String: "TXT分析失敗: " + txtErr.Error(), Valid: true})
-			failCount++
-			continue
+		if c.reanalyze && c.videoID != 0 {
+			log.Printf("資訊：[AnalyzeService-TextPipeline] 影片 ID %d 的檔案在分析後被修改，重設為待分析。\n", c.videoID)
+			if err := s.db.UpdateVideoAnalysisStatus(c.videoID, models.StatusPending, sql.NullTime{Time: time.Now(), Valid: true}, sql.NullString{String: "NAS 檔案在分析後被修改，重新分析", Valid: true}); err != nil {
+				log.Printf("錯誤：[AnalyzeService-TextPipeline] 重設影片 ID %d 狀態失敗: %v\n", c.videoID, err)
+				failCount++
+				continue
+			}
 		}
-		if parsedTxtData == nil {
-			log.Printf("錯誤：[AnalyzeService-TextPipeline] analyzeTextFileContent 為 TXT '%s' 回傳了 nil parsedTxtData 但沒有錯誤。", videoInfo.TextFilePath)
-			s.db.UpdateVideoAnalysisStatus(videoID, models.StatusTxtAnalysisFailed, sql.NullTime{Time: currentTime, Valid: true}, sql.NullString{String: "TXT分析回傳nil數據", Valid: true})
+		analyzed, err := s.analyzeTextFile(c.info)
+		switch {
+		case errors.Is(err, ErrBudgetExceeded):
+			log.Printf("警告：[AnalyzeService-TextPipeline] %v，停止本次文本元數據分析。成功: %d, 失敗: %d\n", err, successCount, failCount)
+			return err
+		case err != nil:
 			failCount++
-			continue
+		case analyzed:
+			successCount++
 		}
-		videoToUpdate := &models.Video{
-			ID:               videoID,
-			SourceName:       videoInfo.SourceName,
-			SourceID:         videoInfo.OriginalID,
-			NASPath:          videoInfo.RelativePath,
-			FetchedAt:        existingVideo.FetchedAt,
-			Title:            sql.NullString{String: parsedTxtData.Title, Valid: parsedTxtData.Title != ""},
-			ShotlistContent:  models.JsonNullString{NullString: sql.NullString{String: parsedTxtData.ShotlistContent, Valid: parsedTxtData.ShotlistContent != ""}},
-			Location:         sql.NullString{String: parsedTxtData.Location, Valid: parsedTxtData.Location != ""},
-			Restrictions:     sql.NullString{String: parsedTxtData.Restrictions, Valid: parsedTxtData.Restrictions != ""},
-			TranRestrictions: sql.NullString{String: parsedTxtData.TranRestrictions, Valid: parsedTxtData.TranRestrictions != ""},
-			Subjects:         parsedTxtData.Subjects,
-			AnalysisStatus:   models.StatusMetadataExtracted,
-			AnalyzedAt:       sql.NullTime{Time: currentTime, Valid: true},
-			ViewLink:         existingVideo.ViewLink,
-			SourceMetadata:   existingVideo.SourceMetadata,
-			PromptVersion:    s.cfg.Prompts.TextFileAnalysis.CurrentVersion,
-		}
-		if !videoInfo.ModTime.IsZero() && videoInfo.ModTime.After(existingVideo.FetchedAt) {
-			videoToUpdate.FetchedAt = videoInfo.ModTime
-		}
-		if parsedTxtData.CreationDateStr != "" {
-			parsedTime, errDate := time.Parse("2006-01-02 15:04:05", parsedTxtData.CreationDateStr)
-			if errDate == nil {
-				videoToUpdate.PublishedAt = sql.NullTime{Time: parsedTime, Valid: true}
-			} else {
-				log.Printf("警告：[AnalyzeService-TextPipeline] 無法解析 TXT CreationDate '%s': %v", parsedTxtData.CreationDateStr, errDate)
-				videoToUpdate.PublishedAt = existingVideo.PublishedAt
-			}
+	}
+	log.Printf("資訊：[AnalyzeService-TextPipeline] 文本元數據分析流程完成。成功: %d, 失敗: %d\n", successCount, failCount)
+	return nil
+}
+
+// analyzeTextFile 對單一影片/TXT 配對執行文本元數據分析；已分析過或正在後續分析的影片會略過 (回傳 false, nil)。
+// 排程的全量掃描與檔案監看可能同時送來同一組檔案，以 textMu 逐一處理，後到者會因狀態已更新而略過
+func (s *AnalyzeService) analyzeTextFile(videoInfo models.VideoFileInfo) (bool, error) {
+	s.textMu.Lock()
+	defer s.textMu.Unlock()
+
+	log.Printf("資訊：[AnalyzeService-TextPipeline] 處理 TXT 檔案: %s (影片: %s)\n", videoInfo.TextFilePath, videoInfo.VideoFileName)
+
+	// 先檢查資料庫中是否存在對應的 source_id 記錄
+	existingVideo, getErr := s.db.GetVideoBySourceID(videoInfo.SourceName, videoInfo.OriginalID)
+	if getErr != nil {
+		log.Printf("錯誤：[AnalyzeService-TextPipeline] 查詢影片 SourceID %s 狀態失敗: %v. 跳過此文本分析.\n", videoInfo.OriginalID, getErr)
+		return false, getErr
+	}
+
+	// 如果記錄存在且狀態為 completed 或已由保留政策封存，則跳過分析
+	if existingVideo != nil && (existingVideo.AnalysisStatus == models.StatusCompleted || existingVideo.AnalysisStatus == models.StatusArchived) {
+		log.Printf("資訊：[AnalyzeService-TextPipeline] 影片 SourceID %s 狀態為 %s，已完成分析，跳過文本分析。\n", videoInfo.OriginalID, existingVideo.AnalysisStatus)
+		return false, nil
+	}
+
+	baseVideoForFind := &models.Video{SourceName: videoInfo.SourceName, SourceID: videoInfo.OriginalID, NASPath: videoInfo.RelativePath, FetchedAt: videoInfo.ModTime}
+	videoID, findErr := s.db.FindOrCreateVideo(baseVideoForFind)
+	if findErr != nil {
+		log.Printf("錯誤：[AnalyzeService-TextPipeline] 為 TXT '%s' 查找/建立基礎影片記錄失敗: %v", videoInfo.TextFilePath, findErr)
+		return false, findErr
+	}
+	if videoID == 0 {
+		log.Printf("錯誤：[AnalyzeService-TextPipeline] FindOrCreateVideo 為 TXT '%s' 回傳了無效的 videoID (0)。\n", videoInfo.TextFilePath)
+		return false, fmt.Errorf("FindOrCreateVideo 回傳了無效的 videoID (0)")
+	}
+	if err := s.db.SaveVideoFiles(videoID, videoInfo.Renditions); err != nil {
+		log.Printf("警告：[AnalyzeService-TextPipeline] 儲存影片 ID %d 的版本清單失敗: %v\n", videoID, err)
+	}
+	existingVideo, getErr = s.db.GetVideoByID(videoID)
+	if getErr != nil {
+		log.Printf("錯誤：[AnalyzeService-TextPipeline] 查詢影片 ID %d 狀態失敗: %v. 跳過此文本分析.\n", videoID, getErr)
+		return false, getErr
+	}
+	if existingVideo != nil && (existingVideo.AnalysisStatus == models.StatusMetadataExtracted || existingVideo.AnalysisStatus == models.StatusProcessing || existingVideo.AnalysisStatus == models.StatusCompleted || existingVideo.AnalysisStatus == models.StatusVideoAnalysisFailed) {
+		log.Printf("資訊：[AnalyzeService-TextPipeline] 影片 ID %d (TXT: %s) 狀態為 %s，已提取過元數據或正在/已完成後續分析，跳過文本分析。\n", videoID, videoInfo.TextFilePath, existingVideo.AnalysisStatus)
+		return false, nil
+	}
+	if err := s.checkBudget(); err != nil {
+		log.Printf("警告：[AnalyzeService-TextPipeline] 影片 ID %d 暫不分析: %v\n", videoID, err)
+		return false, err
+	}
+	updateStatusErr := s.db.UpdateVideoAnalysisStatus(videoID, models.StatusMetadataExtracting, sql.NullTime{Time: time.Now(), Valid: true}, sql.NullString{})
+	if updateStatusErr != nil {
+		log.Printf("警告：[AnalyzeService-TextPipeline] 更新影片 ID %d 狀態為 '%s' 失敗: %v\n", videoID, models.StatusMetadataExtracting, updateStatusErr)
+	}
+	ctxTxt, cancelTxt := context.WithTimeout(context.Background(), 3*time.Minute)
+	parsedTxtData, txtPromptVersion, txtErr := s.analyzeTextFileContent(ctxTxt, videoID, videoInfo.SourceName, videoInfo.TextFilePaths)
+	cancelTxt()
+	currentTime := time.Now()
+	if txtErr != nil {
+		log.Printf("錯誤：[AnalyzeService-TextPipeline] 分析 TXT 檔案 '%s' (VideoID: %d) 失敗: %v\n", videoInfo.TextFilePath, videoID, txtErr)
+		s.db.UpdateVideoAnalysisStatus(videoID, models.StatusTxtAnalysisFailed, sql.NullTime{Time: currentTime, Valid: true}, sql.NullString{String: "TXT分析失敗: " + txtErr.Error(), Valid: true})
+		return false, txtErr
+	}
+	if parsedTxtData == nil {
+		log.Printf("錯誤：[AnalyzeService-TextPipeline] analyzeTextFileContent 為 TXT '%s' 回傳了 nil parsedTxtData 但沒有錯誤。", videoInfo.TextFilePath)
+		s.db.UpdateVideoAnalysisStatus(videoID, models.StatusTxtAnalysisFailed, sql.NullTime{Time: currentTime, Valid: true}, sql.NullString{String: "TXT分析回傳nil數據", Valid: true})
+		return false, fmt.Errorf("TXT分析回傳nil數據")
+	}
+	videoToUpdate := &models.Video{
+		ID:               videoID,
+		SourceName:       videoInfo.SourceName,
+		SourceID:         videoInfo.OriginalID,
+		NASPath:          videoInfo.RelativePath,
+		FetchedAt:        existingVideo.FetchedAt,
+		Title:            sql.NullString{String: parsedTxtData.Title, Valid: parsedTxtData.Title != ""},
+		ShotlistContent:  models.JsonNullString{NullString: sql.NullString{String: parsedTxtData.ShotlistContent, Valid: parsedTxtData.ShotlistContent != ""}},
+		Location:         sql.NullString{String: parsedTxtData.Location, Valid: parsedTxtData.Location != ""},
+		Restrictions:     sql.NullString{String: parsedTxtData.Restrictions, Valid: parsedTxtData.Restrictions != ""},
+		TranRestrictions: sql.NullString{String: parsedTxtData.TranRestrictions, Valid: parsedTxtData.TranRestrictions != ""},
+		Subjects:         parsedTxtData.Subjects,
+		AnalysisStatus:   models.StatusMetadataExtracted,
+		AnalyzedAt:       sql.NullTime{Time: currentTime, Valid: true},
+		ViewLink:         existingVideo.ViewLink,
+		SourceMetadata:   existingVideo.SourceMetadata,
+		PromptVersion:    txtPromptVersion,
+	}
+	if !videoInfo.ModTime.IsZero() && videoInfo.ModTime.After(existingVideo.FetchedAt) {
+		videoToUpdate.FetchedAt = videoInfo.ModTime
+	}
+	if parsedTxtData.CreationDateStr != "" {
+		parsedTime, errDate := time.Parse("2006-01-02 15:04:05", parsedTxtData.CreationDateStr)
+		if errDate == nil {
+			videoToUpdate.PublishedAt = sql.NullTime{Time: parsedTime, Valid: true}
 		} else {
+			log.Printf("警告：[AnalyzeService-TextPipeline] 無法解析 TXT CreationDate '%s': %v", parsedTxtData.CreationDateStr, errDate)
 			videoToUpdate.PublishedAt = existingVideo.PublishedAt
 		}
-		if len(parsedTxtData.DurationSeconds) > 0 && string(parsedTxtData.DurationSeconds) != "null" {
-			var durationInt int
-			var durationStr string
-			rawDurationContent := string(parsedTxtData.DurationSeconds)
-			if err := json.Unmarshal(parsedTxtData.DurationSeconds, &durationInt); err == nil {
-				if durationInt > 0 {
-					videoToUpdate.DurationSecs = sql.NullInt64{Int64: int64(durationInt), Valid: true}
-				}
-			} else if err := json.Unmarshal(parsedTxtData.DurationSeconds, &durationStr); err == nil {
-				durationIntConv, convErr := strconv.Atoi(durationStr)
-				if convErr == nil && durationIntConv > 0 {
-					videoToUpdate.DurationSecs = sql.NullInt64{Int64: int64(durationIntConv), Valid: true}
-				} else {
-					log.Printf("警告：[AnalyzeService-TextPipeline] 無法將 TXT DurationSeconds 字串 '%s' (來自JSON字串 '%s') 解析為數字: %v", durationStr, rawDurationContent, convErr)
-					videoToUpdate.DurationSecs = existingVideo.DurationSecs
-				}
+	} else {
+		videoToUpdate.PublishedAt = existingVideo.PublishedAt
+	}
+	var txtDurationSecs int64 // TXT 記載的長度，用於與影片實際長度比對
+	if len(parsedTxtData.DurationSeconds) > 0 && string(parsedTxtData.DurationSeconds) != "null" {
+		var durationInt int
+		var durationStr string
+		rawDurationContent := string(parsedTxtData.DurationSeconds)
+		if err := json.Unmarshal(parsedTxtData.DurationSeconds, &durationInt); err == nil {
+			if durationInt > 0 {
+				videoToUpdate.DurationSecs = sql.NullInt64{Int64: int64(durationInt), Valid: true}
+				txtDurationSecs = int64(durationInt)
+			}
+		} else if err := json.Unmarshal(parsedTxtData.DurationSeconds, &durationStr); err == nil {
+			durationIntConv, convErr := strconv.Atoi(durationStr)
+			if convErr == nil && durationIntConv > 0 {
+				videoToUpdate.DurationSecs = sql.NullInt64{Int64: int64(durationIntConv), Valid: true}
+				txtDurationSecs = int64(durationIntConv)
 			} else {
-				log.Printf("警告：[AnalyzeService-TextPipeline] TXT DurationSeconds ('%s') 解析為數字或字串均失敗。", rawDurationContent)
+				log.Printf("警告：[AnalyzeService-TextPipeline] 無法將 TXT DurationSeconds 字串 '%s' (來自JSON字串 '%s') 解析為數字: %v", durationStr, rawDurationContent, convErr)
 				videoToUpdate.DurationSecs = existingVideo.DurationSecs
 			}
 		} else {
+			log.Printf("警告：[AnalyzeService-TextPipeline] TXT DurationSeconds ('%s') 解析為數字或字串均失敗。", rawDurationContent)
 			videoToUpdate.DurationSecs = existingVideo.DurationSecs
 		}
-		_, dbErr := s.db.FindOrCreateVideo(videoToUpdate)
-		if dbErr != nil {
-			log.Printf("錯誤：[AnalyzeService-TextPipeline] 更新影片 '%s' 的 TXT 元數據到資料庫失敗: %v\n", videoInfo.RelativePath, dbErr)
-			failCount++
-			continue
+	} else {
+		videoToUpdate.DurationSecs = existingVideo.DurationSecs
+	}
+	// 以影片檔案的實際技術資訊校正 TXT 記載 (由模型判讀) 的長度
+	media := s.probeMedia(videoInfo)
+	if media != nil {
+		s.applyMediaInfo(videoID, media, videoToUpdate, txtDurationSecs, &videoInfo)
+	}
+	_, dbErr := s.db.FindOrCreateVideo(videoToUpdate)
+	if dbErr != nil {
+		log.Printf("錯誤：[AnalyzeService-TextPipeline] 更新影片 '%s' 的 TXT 元數據到資料庫失敗: %v\n", videoInfo.RelativePath, dbErr)
+		return false, dbErr
+	}
+	if media != nil {
+		if err := s.db.SaveMediaInfo(media); err != nil {
+			log.Printf("警告：[AnalyzeService-TextPipeline] %v\n", err)
+		}
+		if err := s.db.SaveVideoFiles(videoID, videoInfo.Renditions); err != nil {
+			log.Printf("警告：[AnalyzeService-TextPipeline] 更新影片 ID %d 的版本解析度失敗: %v\n", videoID, err)
 		}
-		log.Printf("資訊：[AnalyzeService-TextPipeline] TXT 元數據已為影片 ID %d 更新/儲存。\n", videoID)
-		successCount++
 	}
-	log.Printf("資訊：[AnalyzeService-TextPipeline] 文本元數據分析流程完成。成功: %d, 失敗: %d\n", successCount, failCount)
-	return nil
+	log.Printf("資訊：[AnalyzeService-TextPipeline] TXT 元數據已為影片 ID %d 更新/儲存。\n", videoID)
+	if err := s.db.MarkNASFilesAnalyzed(videoInfo.SourceName, videoInfo.OriginalID, videoID); err != nil {
+		log.Printf("警告：[AnalyzeService-TextPipeline] 更新影片 ID %d 的 NAS 檔案清單分析紀錄失敗: %v\n", videoID, err)
+	}
+	return true, nil
 }
 
 func (s *AnalyzeService) ExecuteVideoContentPipeline() error {
 	log.Println("資訊：[AnalyzeService-VideoPipeline] 開始執行影片內容分析流程...")
 
 	// 先檢查資料庫中是否有影片
-	videos, _, err := s.db.GetAllVideosWithAnalysis(100, 0, "", "", "")
+	videos, _, err := s.db.GetAllVideosWithAnalysis(100, 0, "", "", "", "")
 	if err != nil {
 		return fmt.Errorf("查詢影片失敗: %w", err)
 	}
 	log.Printf("資訊：[AnalyzeService-VideoPipeline] 資料庫中共有 %d 個影片", len(videos))
 
 	// 獲取所有狀態為 metadata_extracted 的影片
-	videos, _, err = s.db.GetAllVideosWithAnalysis(100, 0, "", "", string(models.StatusMetadataExtracted))
+	videos, _, err = s.db.GetAllVideosWithAnalysis(100, 0, "", "", string(models.StatusMetadataExtracted), "")
 	if err != nil {
 		return fmt.Errorf("查詢待分析影片失敗: %w", err)
 	}
 	log.Printf("資訊：[AnalyzeService-VideoPipeline] 找到 %d 個待分析的影片", len(videos))
 
+	videoIDs := make([]int64, len(videos))
+	for i, v := range videos {
+		videoIDs[i] = v.ID
+	}
+	videoFiles, err := s.db.GetVideoFiles(videoIDs)
+	if err != nil {
+		log.Printf("警告：[AnalyzeService-VideoPipeline] 查詢影片版本清單失敗，改用 nas_path: %v", err)
+	}
+	rules := renditions.RulesFromConfig(s.cfg.NAS.Renditions)
+
 	for _, video := range videos {
+		if err := s.checkBudget(); err != nil {
+			log.Printf("警告：[AnalyzeService-VideoPipeline] %v，停止本次影片內容分析\n", err)
+			return err
+		}
 		log.Printf("資訊：[AnalyzeService-VideoPipeline] 開始處理影片 ID: %d, SourceID: %s\n", video.ID, video.SourceID)
 
-		// 使用 nas_path 構建影片路徑
-		videoPath := filepath.Join(s.cfg.NAS.VideoPath, video.NASPath)
-		log.Printf("資訊：[AnalyzeService-VideoPipeline] 影片路徑: %s\n", videoPath)
+		// 依 nas.renditions 規則選擇送交分析的版本；沒有版本紀錄時使用 nas_path
+		relPath := video.NASPath
+		if f, ok := renditions.ForAnalysis(videoFiles[video.ID], rules); ok {
+			relPath = filepath.FromSlash(f.Path)
+			log.Printf("資訊：[AnalyzeService-VideoPipeline] 使用 %s 版本: %s\n", f.Role, f.Path)
+		}
 
-		// 檢查影片檔案是否存在
-		if _, err := os.Stat(videoPath); os.IsNotExist(err) {
-			log.Printf("錯誤：[AnalyzeService-VideoPipeline] 影片檔案不存在: %s\n", videoPath)
+		// 檢查影片檔案是否存在 (物件儲存時下載至本地快取)
+		videoPath, errPath := s.nas.GetVideoAbsolutePath(relPath)
+		if errPath != nil {
+			log.Printf("錯誤：[AnalyzeService-VideoPipeline] 影片檔案不存在: %v\n", errPath)
 			// 更新影片狀態為分析失敗
-			if err := s.db.UpdateVideoAnalysisStatus(video.ID, models.StatusVideoAnalysisFailed, sql.NullTime{Time: time.Now(), Valid: true}, sql.NullString{String: fmt.Sprintf("影片檔案不存在: %s", videoPath), Valid: true}); err != nil {
+			if err := s.db.UpdateVideoAnalysisStatus(video.ID, models.StatusVideoAnalysisFailed, sql.NullTime{Time: time.Now(), Valid: true}, sql.NullString{String: fmt.Sprintf("影片檔案不存在: %s", relPath), Valid: true}); err != nil {
 				log.Printf("錯誤：[AnalyzeService-VideoPipeline] 更新影片狀態失敗: %v\n", err)
 			}
 			continue
 		}
+		log.Printf("資訊：[AnalyzeService-VideoPipeline] 影片路徑: %s\n", videoPath)
 
 		// 更新影片狀態為處理中
 		if err := s.db.UpdateVideoAnalysisStatus(video.ID, models.StatusProcessing, sql.NullTime{Time: time.Now(), Valid: true}, sql.NullString{}); err != nil {
@@ -450,19 +684,44 @@ func (s *AnalyzeService) ExecuteVideoContentPipeline() error {
 		}
 
 		// 使用 Gemini API 分析影片
-		promptText, promptVersion := s.buildPromptForVideo(models.VideoFileInfo{
-			VideoAbsolutePath: videoPath,
-			SourceName:        video.SourceName,
-			OriginalID:        video.SourceID,
-			VideoFileName:     filepath.Base(video.NASPath),
-		}, &models.ParsedTxtData{
+		txtData := &models.ParsedTxtData{
 			Title:           video.Title.String,
 			ShotlistContent: video.ShotlistContent.String,
 			Subjects:        video.Subjects,
 			Location:        video.Location.String,
-		})
+		}
+		if video.PublishedAt.Valid {
+			txtData.CreationDateStr = video.PublishedAt.Time.Format("2006-01-02 15:04:05")
+		}
+		if video.DurationSecs.Valid {
+			txtData.DurationSeconds = json.RawMessage(strconv.FormatInt(video.DurationSecs.Int64, 10))
+		}
+		promptText, promptVersion, promptHash := s.buildPromptForVideo(models.VideoFileInfo{
+			VideoAbsolutePath: videoPath,
+			SourceName:        video.SourceName,
+			OriginalID:        video.SourceID,
+			VideoFileName:     filepath.Base(video.NASPath),
+		}, txtData)
 
-		analysis, err := s.geminiClient.AnalyzeVideo(context.Background(), videoPath, promptText)
+		var durationSecs int64
+		if video.DurationSecs.Valid {
+			durationSecs = video.DurationSecs.Int64
+		}
+		var attempt videoAnalysisAttempt
+		if minSecs := s.cfg.Segmentation.MinDurationSecs; minSecs > 0 && durationSecs > minSecs {
+			attempt, err = s.analyzeVideoSegmented(context.Background(), video, videoPath, promptText, promptVersion, durationSecs)
+		} else {
+			attempt, err = s.analyzeVideoWithFallback(context.Background(), video, videoPath, promptText, promptVersion, durationSecs)
+		}
+		analysis, callInfo := attempt.analysis, attempt.callInfo
+		if errors.Is(err, ErrBudgetExceeded) {
+			// 長影片分段分析途中達到預算：放棄已分析的片段，恢復為待分析，下個月或調高預算後重新分析
+			log.Printf("警告：[AnalyzeService-VideoPipeline] 影片 ID: %d %v，停止本次影片內容分析\n", video.ID, err)
+			if err := s.db.UpdateVideoAnalysisStatus(video.ID, models.StatusMetadataExtracted, sql.NullTime{Time: time.Now(), Valid: true}, sql.NullString{String: err.Error(), Valid: true}); err != nil {
+				log.Printf("錯誤：[AnalyzeService-VideoPipeline] 更新影片狀態失敗: %v\n", err)
+			}
+			return err
+		}
 		if err != nil {
 			errorMsg := fmt.Sprintf("Gemini API 分析失敗: %v", err)
 			log.Printf("錯誤：[AnalyzeService-VideoPipeline] %s\n", errorMsg)
@@ -472,6 +731,11 @@ func (s *AnalyzeService) ExecuteVideoContentPipeline() error {
 				VideoID:       video.ID,
 				ErrorMessage:  &models.JsonNullString{NullString: sql.NullString{String: errorMsg, Valid: true}},
 				PromptVersion: promptVersion,
+				PromptHash:    promptHash,
+				ModelName:     callInfo.ModelName,
+				ModelParams:   callInfo.Params,
+				Outcome:       string(attempt.outcome),
+				SafetyRatings: attempt.safetyRatings,
 				CreatedAt:     time.Now(),
 				UpdatedAt:     time.Now(),
 			}
@@ -489,7 +753,7 @@ func (s *AnalyzeService) ExecuteVideoContentPipeline() error {
 		}
 
 		// 檢查分析結果是否為空或無效
-		if analysis == nil || (analysis.ShortSummary == nil && analysis.BulletedSummary == nil && analysis.VisualDescription == nil) {
+		if !hasAnalysisContent(analysis) {
 			errorMsg := "Gemini API 回傳的分析結果為空或無效"
 			log.Printf("錯誤：[AnalyzeService-VideoPipeline] %s\n", errorMsg)
 
@@ -498,6 +762,11 @@ func (s *AnalyzeService) ExecuteVideoContentPipeline() error {
 				VideoID:       video.ID,
 				ErrorMessage:  &models.JsonNullString{NullString: sql.NullString{String: errorMsg, Valid: true}},
 				PromptVersion: promptVersion,
+				PromptHash:    promptHash,
+				ModelName:     callInfo.ModelName,
+				ModelParams:   callInfo.Params,
+				Outcome:       string(attempt.outcome),
+				SafetyRatings: attempt.safetyRatings,
 				CreatedAt:     time.Now(),
 				UpdatedAt:     time.Now(),
 			}
@@ -517,6 +786,12 @@ func (s *AnalyzeService) ExecuteVideoContentPipeline() error {
 		// 設置分析結果的額外資訊
 		analysis.VideoID = video.ID
 		analysis.PromptVersion = promptVersion
+		analysis.PromptHash = promptHash
+		analysis.ModelName = callInfo.ModelName
+		analysis.ModelParams = callInfo.Params
+		analysis.Outcome = string(attempt.outcome)
+		analysis.Fallback = attempt.fallback
+		analysis.SafetyRatings = attempt.safetyRatings
 		analysis.CreatedAt = time.Now()
 		analysis.UpdatedAt = time.Now()
 
@@ -537,6 +812,7 @@ func (s *AnalyzeService) ExecuteVideoContentPipeline() error {
 			log.Printf("錯誤：[AnalyzeService-VideoPipeline] 更新影片狀態失敗: %v\n", err)
 			continue
 		}
+		s.notifyAnalysisCompleted(video.ID)
 
 		log.Printf("資訊：[AnalyzeService-VideoPipeline] 影片 ID: %d 分析完成\n", video.ID)
 	}
@@ -545,6 +821,342 @@ func (s *AnalyzeService) ExecuteVideoContentPipeline() error {
 	return nil
 }
 
+// 影片內容分析被阻擋後採用的備援方式，記錄於 analysis_results.fallback
+const (
+	fallbackRelaxedSafety = "relaxed_safety"
+	fallbackTextOnly      = "text_only"
+)
+
+// videoAnalysisAttempt 影片內容分析 (含備援) 的結果；outcome 為第一次分析的結束類型，
+// 即使備援成功仍保留 safety 等原始結果，供儀表板篩選需人工審閱的影片
+type videoAnalysisAttempt struct {
+	analysis      *models.AnalysisResult
+	callInfo      gemini.CallInfo
+	outcome       gemini.Outcome
+	fallback      string
+	safetyRatings json.RawMessage
+}
+
+// hasAnalysisContent 判斷分析結果是否至少包含摘要或畫面描述
+func hasAnalysisContent(a *models.AnalysisResult) bool {
+	return a != nil && (a.ShortSummary != nil || a.BulletedSummary != nil || a.VisualDescription != nil)
+}
+
+// analyzeVideoFile 開啟本地影片 (原始檔案或切割的片段) 並串流交給 Gemini 分析；relaxed 時使用放寬的安全設定
+func (s *AnalyzeService) analyzeVideoFile(ctx context.Context, videoPath string, prompt string, durationSecs int64, relaxed bool) (*models.AnalysisResult, gemini.CallInfo, error) {
+	f, err := os.Open(videoPath)
+	if err != nil {
+		return nil, gemini.CallInfo{}, fmt.Errorf("開啟影片檔案 '%s' 失敗: %w", videoPath, err)
+	}
+	defer f.Close()
+	if relaxed {
+		return s.geminiClient.AnalyzeVideoRelaxed(ctx, f, videoPath, prompt, durationSecs)
+	}
+	return s.geminiClient.AnalyzeVideo(ctx, f, videoPath, prompt, durationSecs)
+}
+
+// uploadVideoFile 開啟本地影片並上傳至 File API，供多次分析重複使用
+func (s *AnalyzeService) uploadVideoFile(ctx context.Context, videoPath string) (*gemini.UploadedVideo, error) {
+	f, err := os.Open(videoPath)
+	if err != nil {
+		return nil, fmt.Errorf("開啟影片檔案 '%s' 失敗: %w", videoPath, err)
+	}
+	defer f.Close()
+	return s.geminiClient.UploadVideo(ctx, f, videoPath)
+}
+
+// analyzeVideoWithFallback 分析影片內容；回應因安全設定被阻擋時先以放寬的安全設定重試，
+// 仍被阻擋 (或因 RECITATION、MAX_TOKENS 未完成) 時改以標題、SHOTLIST 等文字元數據進行純文字分析。
+// 所有備援都失敗時回傳第一次分析的錯誤
+func (s *AnalyzeService) analyzeVideoWithFallback(ctx context.Context, video models.Video, videoPath string, promptText string, promptVersion string, durationSecs int64) (videoAnalysisAttempt, error) {
+	analysis, callInfo, err := s.analyzeVideoFile(ctx, videoPath, promptText, durationSecs, false)
+	s.recordUsage(video.ID, video.SourceName, models.PromptKindVideo, promptVersion, callInfo, err == nil && hasAnalysisContent(analysis))
+	attempt := videoAnalysisAttempt{analysis: analysis, callInfo: callInfo, outcome: gemini.OutcomeOf(err)}
+
+	var blocked *gemini.BlockedError
+	if !errors.As(err, &blocked) {
+		return attempt, err
+	}
+	if len(blocked.SafetyRatings) > 0 {
+		if ratings, mErr := json.Marshal(blocked.SafetyRatings); mErr == nil {
+			attempt.safetyRatings = ratings
+		}
+	}
+	log.Printf("警告：[AnalyzeService-VideoPipeline] 影片 ID: %d 分析未完成 (%s)，嘗試備援分析\n", video.ID, blocked.FinishReason)
+
+	if blocked.Outcome == gemini.OutcomeSafety {
+		relaxed, relaxedInfo, relaxedErr := s.analyzeVideoFile(ctx, videoPath, promptText, durationSecs, true)
+		if !errors.Is(relaxedErr, gemini.ErrFallbackDisabled) {
+			s.recordUsage(video.ID, video.SourceName, models.PromptKindVideo, promptVersion, relaxedInfo, relaxedErr == nil && hasAnalysisContent(relaxed))
+			if relaxedErr == nil && hasAnalysisContent(relaxed) {
+				log.Printf("資訊：[AnalyzeService-VideoPipeline] 影片 ID: %d 以放寬的安全設定分析成功\n", video.ID)
+				attempt.analysis, attempt.callInfo, attempt.fallback = relaxed, relaxedInfo, fallbackRelaxedSafety
+				return attempt, nil
+			}
+			log.Printf("警告：[AnalyzeService-VideoPipeline] 影片 ID: %d 放寬安全設定後仍無法分析: %v\n", video.ID, relaxedErr)
+		}
+	}
+
+	metadataText := videoMetadataText(video)
+	if metadataText == "" {
+		log.Printf("警告：[AnalyzeService-VideoPipeline] 影片 ID: %d 沒有標題或 SHOTLIST，無法進行純文字備援分析\n", video.ID)
+		return attempt, err
+	}
+	textOnly, textInfo, textErr := s.geminiClient.AnalyzeVideoFromText(ctx, promptText, metadataText)
+	if errors.Is(textErr, gemini.ErrFallbackDisabled) {
+		return attempt, err
+	}
+	s.recordUsage(video.ID, video.SourceName, models.PromptKindVideo, promptVersion, textInfo, textErr == nil && hasAnalysisContent(textOnly))
+	if textErr != nil || !hasAnalysisContent(textOnly) {
+		log.Printf("警告：[AnalyzeService-VideoPipeline] 影片 ID: %d 純文字備援分析失敗: %v\n", video.ID, textErr)
+		return attempt, err
+	}
+	log.Printf("資訊：[AnalyzeService-VideoPipeline] 影片 ID: %d 已改以純文字元數據完成分析，需人工審閱\n", video.ID)
+	attempt.analysis, attempt.callInfo, attempt.fallback = textOnly, textInfo, fallbackTextOnly
+	return attempt, nil
+}
+
+// analyzeVideoSegmented 將超過 segmentation.minDurationSecs 的長影片切成多個片段分別分析後合併為一筆分析結果。
+// 有 ffmpeg 時實際切割影片；沒有 ffmpeg (或切割失敗) 時送出完整影片並以 prompt 指定分析的時間範圍。
+// 片段因安全設定被阻擋時以放寬的安全設定重試；部分片段失敗時仍合併其餘片段並於錯誤訊息註記，全部失敗時回傳錯誤
+func (s *AnalyzeService) analyzeVideoSegmented(ctx context.Context, video models.Video, videoPath string, promptText string, promptVersion string, durationSecs int64) (videoAnalysisAttempt, error) {
+	segCfg := s.cfg.Segmentation
+	total := time.Duration(durationSecs) * time.Second
+	spans := segmentation.Plan(total, time.Duration(segCfg.SegmentSecs)*time.Second)
+	attempt := videoAnalysisAttempt{outcome: gemini.OutcomeOK}
+
+	ffmpegPath, useFFmpeg := segmentation.FindFFmpeg(segCfg.FFmpegPath)
+	var tempDir string
+	if useFFmpeg {
+		dir, err := os.MkdirTemp(segCfg.TempDir, fmt.Sprintf("segments_%d_", video.ID))
+		if err != nil {
+			log.Printf("警告：[AnalyzeService-VideoPipeline] 無法建立片段暫存目錄，改以 prompt 指定時間範圍分析: %v\n", err)
+			useFFmpeg = false
+		} else {
+			tempDir = dir
+			defer os.RemoveAll(dir)
+		}
+	} else {
+		log.Printf("資訊：[AnalyzeService-VideoPipeline] 找不到 ffmpeg，影片 ID: %d 將以 prompt 指定時間範圍分段分析\n", video.ID)
+	}
+	log.Printf("資訊：[AnalyzeService-VideoPipeline] 影片 ID: %d 長度 %d 秒，分為 %d 段分析\n", video.ID, durationSecs, len(spans))
+
+	// 沒有切割的片段共用同一次上傳的完整影片，於所有片段分析完成後刪除
+	var uploaded *gemini.UploadedVideo
+	var uploadErr error
+	defer func() { s.geminiClient.DeleteVideo(uploaded) }()
+	analyzeWhole := func(prompt string, relaxed bool) (*models.AnalysisResult, gemini.CallInfo, error) {
+		if uploaded == nil && uploadErr == nil {
+			uploaded, uploadErr = s.uploadVideoFile(ctx, videoPath)
+		}
+		if uploadErr != nil {
+			return nil, gemini.CallInfo{}, uploadErr
+		}
+		if relaxed {
+			return s.geminiClient.AnalyzeUploadedVideoRelaxed(ctx, uploaded, prompt, durationSecs)
+		}
+		return s.geminiClient.AnalyzeUploadedVideo(ctx, uploaded, prompt, durationSecs)
+	}
+
+	var results []segmentation.Result
+	var failures []string
+	var firstErr error
+	cutSegments := 0
+	for i, span := range spans {
+		if i > 0 {
+			if err := s.checkBudget(); err != nil {
+				return attempt, err
+			}
+		}
+		var segPath string
+		if useFFmpeg {
+			path, err := segmentation.Cut(ctx, ffmpegPath, videoPath, tempDir, span)
+			if err != nil {
+				log.Printf("警告：[AnalyzeService-VideoPipeline] %v，此片段改以 prompt 指定時間範圍分析\n", err)
+			} else {
+				segPath = path
+				cutSegments++
+			}
+		}
+		absolute := segPath == ""
+		analyze := analyzeWhole
+		if !absolute {
+			segDuration := int64((span.End - span.Start) / time.Second)
+			analyze = func(prompt string, relaxed bool) (*models.AnalysisResult, gemini.CallInfo, error) {
+				return s.analyzeVideoFile(ctx, segPath, prompt, segDuration, relaxed)
+			}
+		}
+		analysis, err := s.analyzeSegment(video, segmentation.SegmentPrompt(promptText, span, total, absolute), promptVersion, analyze, &attempt)
+		if !absolute {
+			os.Remove(segPath)
+		}
+		if err != nil {
+			log.Printf("警告：[AnalyzeService-VideoPipeline] 影片 ID: %d %s分析失敗: %v\n", video.ID, span.Label(), err)
+			failures = append(failures, fmt.Sprintf("%s: %v", span.Label(), err))
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		results = append(results, segmentation.Result{Span: span, Analysis: analysis, AbsoluteTimecodes: absolute})
+	}
+	if len(results) == 0 {
+		return attempt, fmt.Errorf("長影片 %d 個片段皆分析失敗: %w", len(spans), firstErr)
+	}
+
+	merged, err := segmentation.Merge(results)
+	if err != nil {
+		return attempt, err
+	}
+	if len(results) > 1 {
+		summaryJSON, info, err := s.geminiClient.AnalyzeText(ctx, segmentation.SummaryInput(results), segmentation.OverallSummaryPrompt)
+		s.recordUsage(video.ID, video.SourceName, models.PromptKindVideo, promptVersion, info, err == nil)
+		if err == nil {
+			err = segmentation.ApplyOverallSummary(merged, summaryJSON)
+		}
+		if err != nil {
+			log.Printf("警告：[AnalyzeService-VideoPipeline] 影片 ID: %d 產生整體摘要失敗，改用各片段摘要串接: %v\n", video.ID, err)
+		}
+	}
+	if len(failures) > 0 {
+		merged.ErrorMessage = &models.JsonNullString{NullString: sql.NullString{
+			String: fmt.Sprintf("%d/%d 個片段分析失敗，結果不完整：%s", len(failures), len(spans), strings.Join(failures, "；")),
+			Valid:  true,
+		}}
+	}
+	method := "ffmpeg"
+	if cutSegments < len(spans) {
+		method = "prompt_offset"
+	}
+	attempt.callInfo.Params = withSegmentationParams(attempt.callInfo.Params, len(spans), segCfg.SegmentSecs, method)
+	attempt.analysis = merged
+	return attempt, nil
+}
+
+// analyzeSegment 以 analyze (分析切割後的片段或已上傳的完整影片) 分析長影片的單一片段；因安全設定被阻擋時以放寬的安全設定重試，
+// 並將第一個被阻擋片段的結束類型與安全評級記錄於 attempt
+func (s *AnalyzeService) analyzeSegment(video models.Video, prompt string, promptVersion string, analyze func(prompt string, relaxed bool) (*models.AnalysisResult, gemini.CallInfo, error), attempt *videoAnalysisAttempt) (*models.AnalysisResult, error) {
+	analysis, info, err := analyze(prompt, false)
+	s.recordUsage(video.ID, video.SourceName, models.PromptKindVideo, promptVersion, info, err == nil && hasAnalysisContent(analysis))
+	if attempt.callInfo.ModelName == "" {
+		attempt.callInfo = info
+	}
+	var blocked *gemini.BlockedError
+	if errors.As(err, &blocked) {
+		if attempt.outcome == gemini.OutcomeOK {
+			attempt.outcome = blocked.Outcome
+			if ratings, mErr := json.Marshal(blocked.SafetyRatings); mErr == nil && len(blocked.SafetyRatings) > 0 {
+				attempt.safetyRatings = ratings
+			}
+		}
+		if blocked.Outcome == gemini.OutcomeSafety {
+			relaxed, relaxedInfo, relaxedErr := analyze(prompt, true)
+			if !errors.Is(relaxedErr, gemini.ErrFallbackDisabled) {
+				s.recordUsage(video.ID, video.SourceName, models.PromptKindVideo, promptVersion, relaxedInfo, relaxedErr == nil && hasAnalysisContent(relaxed))
+				if relaxedErr == nil && hasAnalysisContent(relaxed) {
+					attempt.fallback = fallbackRelaxedSafety
+					return relaxed, nil
+				}
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !hasAnalysisContent(analysis) {
+		return nil, fmt.Errorf("Gemini API 回傳的分析結果為空或無效")
+	}
+	return analysis, nil
+}
+
+// withSegmentationParams 在生成參數中記錄分段方式，供日後比對分析結果
+func withSegmentationParams(params json.RawMessage, segments int, segmentSecs int64, method string) json.RawMessage {
+	m := make(map[string]interface{})
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &m); err != nil {
+			return params
+		}
+	}
+	m["segmentation"] = map[string]interface{}{"segments": segments, "segmentSecs": segmentSecs, "method": method}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return params
+	}
+	return data
+}
+
+// videoMetadataText 組合純文字備援分析使用的影片元數據；沒有標題與 SHOTLIST 時回傳空字串
+func videoMetadataText(video models.Video) string {
+	if strings.TrimSpace(video.Title.String) == "" && strings.TrimSpace(video.ShotlistContent.String) == "" {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "標題: %s\n", video.Title.String)
+	if video.PublishedAt.Valid {
+		fmt.Fprintf(&b, "發布時間: %s\n", video.PublishedAt.Time.Format("2006-01-02 15:04:05"))
+	}
+	if video.Location.String != "" {
+		fmt.Fprintf(&b, "地點: %s\n", video.Location.String)
+	}
+	var subjects []string
+	if len(video.Subjects) > 0 && json.Unmarshal(video.Subjects, &subjects) == nil && len(subjects) > 0 {
+		fmt.Fprintf(&b, "主題: %s\n", strings.Join(subjects, "、"))
+	}
+	if video.ShotlistContent.String != "" {
+		fmt.Fprintf(&b, "SHOTLIST:\n%s\n", video.ShotlistContent.String)
+	}
+	return b.String()
+}
+
+// recordUsage 記錄一次 Gemini 呼叫的 token 用量，供費用報表與每月預算使用。失敗 (包含被阻擋、未取得用量) 的呼叫也會記錄，
+// 以便統計失敗次數；沒有模型名稱表示未送出請求，不記錄
+func (s *AnalyzeService) recordUsage(videoID int64, sourceName string, task models.PromptKind, promptVersion string, info gemini.CallInfo, success bool) {
+	if info.ModelName == "" {
+		return
+	}
+	usage := &models.GeminiUsage{
+		VideoID:         sql.NullInt64{Int64: videoID, Valid: videoID != 0},
+		SourceName:      sourceName,
+		Task:            task,
+		ModelName:       info.ModelName,
+		PromptVersion:   promptVersion,
+		PromptTokens:    int64(info.PromptTokens),
+		CandidateTokens: int64(info.CandidateTokens),
+		TotalTokens:     int64(info.TotalTokens),
+		Success:         success,
+	}
+	if err := s.db.RecordGeminiUsage(usage); err != nil {
+		log.Printf("警告：[AnalyzeService] %v", err)
+	}
+}
+
+// AddCompletionNotifier 註冊在影片完成內容分析後要通知的對象 (例如 webhook)
+func (s *AnalyzeService) AddCompletionNotifier(n AnalysisCompletionNotifier) {
+	if n != nil {
+		s.notifiers = append(s.notifiers, n)
+	}
+}
+
+// notifyAnalysisCompleted 重新讀取已儲存的影片與分析結果後通知所有註冊的對象
+func (s *AnalyzeService) notifyAnalysisCompleted(videoID int64) {
+	if len(s.notifiers) == 0 {
+		return
+	}
+	video, err := s.db.GetVideoByID(videoID)
+	if err != nil || video == nil {
+		log.Printf("錯誤：[AnalyzeService-VideoPipeline] 讀取影片 ID %d 以發送完成通知失敗: %v\n", videoID, err)
+		return
+	}
+	result, err := s.db.GetAnalysisResultByVideoID(videoID)
+	if err != nil || result == nil {
+		log.Printf("錯誤：[AnalyzeService-VideoPipeline] 讀取影片 ID %d 的分析結果以發送完成通知失敗: %v\n", videoID, err)
+		return
+	}
+	for _, n := range s.notifiers {
+		n.NotifyAnalysisCompleted(*video, result)
+	}
+}
+
 // Run 方法 (保持不變)
 func (s *AnalyzeService) Run() error { /* ... */ return nil }
 
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/costs"
+	"AiHackathon-admin/internal/web/handlers"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// CostService 依 gemini_usage 的 token 用量與設定檔的模型價格計算費用報表與每月預算
+type CostService struct {
+	db handlers.DBStore
+
+	mu    sync.RWMutex
+	costs config.CostsConfig // 可於設定重新載入時更新
+}
+
+// NewCostService 建立 CostService 實例
+func NewCostService(cfg *config.Config, db handlers.DBStore) (*CostService, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("CostService：設定不得為空")
+	}
+	if db == nil {
+		return nil, fmt.Errorf("CostService：DBStore 不得為空")
+	}
+	log.Printf("資訊：CostService 初始化完成 (每月預算: %.2f %s，已設定 %d 個模型價格)。", cfg.Costs.MonthlyBudget, cfg.Costs.Currency, len(cfg.Costs.Prices))
+	return &CostService{db: db, costs: cfg.Costs}, nil
+}
+
+// UpdateConfig 於設定重新載入後更新價格與預算
+func (s *CostService) UpdateConfig(c config.CostsConfig) {
+	s.mu.Lock()
+	s.costs = c
+	s.mu.Unlock()
+}
+
+func (s *CostService) config() config.CostsConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.costs
+}
+
+// Report 產生 from 至 to (皆含當日) 期間的費用報表，並附上本月預算狀況
+func (s *CostService) Report(from, to time.Time) (costs.Report, error) {
+	cfg := s.config()
+	aggs, err := s.db.GetUsageAggregates(from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return costs.Report{}, err
+	}
+	report := costs.Compute(aggs, cfg)
+	report.From = from.Format("2006-01-02")
+	report.To = to.Format("2006-01-02")
+	if report.Budget, err = s.checkBudget(cfg); err != nil {
+		return costs.Report{}, err
+	}
+	return report, nil
+}
+
+// CheckBudget 計算本月至今的花費是否已達每月預算
+func (s *CostService) CheckBudget() (costs.BudgetStatus, error) {
+	return s.checkBudget(s.config())
+}
+
+func (s *CostService) checkBudget(cfg config.CostsConfig) (costs.BudgetStatus, error) {
+	now := time.Now()
+	aggs, err := s.db.GetUsageAggregates(costs.MonthStart(now), now.Add(time.Minute))
+	if err != nil {
+		return costs.BudgetStatus{}, err
+	}
+	return costs.Budget(aggs, cfg, now), nil
+}
@@ -37,7 +37,7 @@ func (s *FetchService) Run() error {
 	// 實際的擷取邏輯將在這裡實現：
 	// 1. 連接各個影片來源 API (AP, Reuters, YouTube)
 	// 2. 查詢新影片
-	// 3. 下載影片到 NAS
+	// 3. 以 s.nas.SaveVideo 將下載回應 (resp.Body 與 Content-Length) 串流寫入 NAS，不將整個影片載入記憶體
 	// 4. 在資料庫中記錄影片元數據
 	log.Printf("資訊：使用設定 - AppName: %s, NAS Path: %s\n", s.cfg.AppName, s.cfg.NAS.VideoPath)
 	return nil
@@ -1,9 +1,30 @@
 package services
 
-// NASStorage 介面定義了儲存操作
+import (
+	"AiHackathon-admin/internal/costs"
+	"AiHackathon-admin/internal/models"
+	"io"
+)
+
+// NASStorage 介面定義了儲存操作；影片以串流讀寫，不會整個載入記憶體
 type NASStorage interface {
-	SaveVideo(sourceName string, sourceID string, originalFileName string, videoData []byte) (string, error)
+	// SaveVideo 串流寫入影片 (完成前不會出現在正式路徑)，同時計算 sha256；size 未知時傳入 -1
+	SaveVideo(sourceName string, sourceID string, originalFileName string, r io.Reader, size int64) (models.StoredFile, error)
+	// SaveFile 串流寫入指定相對路徑 (例如影片旁的縮圖)，已存在時覆寫
+	SaveFile(relativePath string, r io.Reader, size int64) (models.StoredFile, error)
 	GetVideoAbsolutePath(relativePath string) (string, error)
-	ReadVideo(filePath string) ([]byte, error)
-	// DeleteVideo(filePathInDB string) error // 如果需要
+	// OpenVideo 開啟影片供串流讀取，呼叫端需關閉
+	OpenVideo(filePath string) (io.ReadSeekCloser, error)
+	DeleteVideo(filePathInDB string) error
+}
+
+// NASLister 可列出檔案的儲存後端 (S3 等物件儲存)。掃描時以列表取代讀取本地目錄，
+// 需要讀取內容的檔案再以 GetVideoAbsolutePath 下載至本地快取
+type NASLister interface {
+	ListFiles(prefix string) ([]models.StorageObject, error)
+}
+
+// BudgetChecker 回報本月 Gemini 花費是否已達預算 (由 CostService 實作)
+type BudgetChecker interface {
+	CheckBudget() (costs.BudgetStatus, error)
 }
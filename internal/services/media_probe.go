@@ -0,0 +1,114 @@
+package services
+
+import (
+	"AiHackathon-admin/internal/mediaprobe"
+	"AiHackathon-admin/internal/models"
+	"AiHackathon-admin/internal/renditions"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// probeMedia 取得影片目錄中最高畫質版本 (沒有影片時為音訊) 的技術資訊：有 ffprobe 時使用 ffprobe，
+// 否則 (或 ffprobe 失敗時) .mp4/.mov 以內建的 box 解析器取得。無法取得時回傳 nil
+func (s *AnalyzeService) probeMedia(videoInfo models.VideoFileInfo) *models.MediaInfo {
+	target, ok := probeTarget(videoInfo)
+	if !ok {
+		return nil
+	}
+	cfg := s.cfg.MediaProbe
+	var info models.MediaInfo
+	var err error
+	if ffprobePath, found := mediaprobe.FindFFprobe(cfg.FFprobePath); found {
+		info, err = s.ffprobe(ffprobePath, target.Path, time.Duration(cfg.TimeoutSecs)*time.Second)
+		if err != nil {
+			log.Printf("警告：[AnalyzeService-Probe] ffprobe 無法解析 '%s': %v", target.Path, err)
+		}
+	} else {
+		err = fmt.Errorf("找不到 ffprobe")
+	}
+	if err != nil {
+		if !mediaprobe.MP4Extensions[strings.ToLower(path.Ext(target.Path))] {
+			return nil
+		}
+		if info, err = s.probeMP4(target); err != nil {
+			log.Printf("警告：[AnalyzeService-Probe] 無法解析 '%s' 的 MP4 結構: %v", target.Path, err)
+			return nil
+		}
+	}
+	info.Path = target.Path
+	if info.Size == 0 {
+		info.Size = target.Size
+	}
+	info.ProbedAt = time.Now()
+	return &info
+}
+
+// probeTarget 選擇取得技術資訊的檔案：Renditions 已依 master、proxy (畫質高至低)、audio 排序
+func probeTarget(videoInfo models.VideoFileInfo) (models.VideoFile, bool) {
+	if len(videoInfo.Renditions) > 0 {
+		return videoInfo.Renditions[0], true
+	}
+	rel := filepath.ToSlash(videoInfo.RelativePath)
+	ext := strings.ToLower(path.Ext(rel))
+	if supportedVideoExtensions[ext] || renditions.AudioExtensions[ext] {
+		return models.VideoFile{Path: rel, Role: models.VideoFileMaster}, true
+	}
+	return models.VideoFile{}, false
+}
+
+// ffprobe 以 ffprobe 解析影片；物件儲存使用預先簽署網址，ffprobe 只讀取所需的部分
+func (s *AnalyzeService) ffprobe(ffprobePath, relPath string, timeout time.Duration) (models.MediaInfo, error) {
+	input, err := mediaInput(s.nas, relPath, timeout+time.Minute)
+	if err != nil {
+		return models.MediaInfo{}, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return mediaprobe.FFprobe(ctx, ffprobePath, input)
+}
+
+// probeMP4 以內建的 box 解析器解析 MP4/MOV；透過 OpenVideo 讀取，物件儲存只以 Range 請求讀取 moov
+func (s *AnalyzeService) probeMP4(target models.VideoFile) (models.MediaInfo, error) {
+	r, err := s.nas.OpenVideo(filepath.FromSlash(target.Path))
+	if err != nil {
+		return models.MediaInfo{}, err
+	}
+	defer r.Close()
+	size := target.Size
+	if size <= 0 {
+		if size, err = r.Seek(0, io.SeekEnd); err != nil {
+			return models.MediaInfo{}, err
+		}
+	}
+	return mediaprobe.ProbeMP4(r, size)
+}
+
+// applyMediaInfo 以實際技術資訊校正影片長度並記錄與 TXT 的差異，同時補上該版本的解析度
+func (s *AnalyzeService) applyMediaInfo(videoID int64, info *models.MediaInfo, video *models.Video, txtDurationSecs int64, videoInfo *models.VideoFileInfo) {
+	info.VideoID = videoID
+	if txtDurationSecs > 0 {
+		info.TxtDurationSecs.Int64, info.TxtDurationSecs.Valid = txtDurationSecs, true
+	}
+	if info.DurationMs > 0 {
+		info.DurationMismatch = mediaprobe.DurationMismatch(txtDurationSecs, info.Duration(), s.cfg.MediaProbe.DurationToleranceSecs)
+		if info.DurationMismatch {
+			log.Printf("警告：[AnalyzeService-Probe] 影片 ID %d 的 TXT 記載長度 %d 秒與實際長度 %.1f 秒不符，改用實際長度。", videoID, txtDurationSecs, info.Duration().Seconds())
+		}
+		secs := (info.DurationMs + 500) / 1000
+		if secs == 0 {
+			secs = 1
+		}
+		video.DurationSecs.Int64, video.DurationSecs.Valid = secs, true
+	}
+	for i, f := range videoInfo.Renditions {
+		if f.Path == info.Path && info.Width > 0 && info.Height > 0 {
+			videoInfo.Renditions[i].Width, videoInfo.Renditions[i].Height = info.Width, info.Height
+		}
+	}
+}
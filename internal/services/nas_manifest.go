@@ -0,0 +1,296 @@
+package services
+
+import (
+	"AiHackathon-admin/internal/models"
+	"AiHackathon-admin/internal/renditions"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// recentWriteWindow 目錄中有檔案在此時間內被修改時 (可能仍在下載)，不記錄目錄修改時間，下次掃描仍會重新檢查
+const recentWriteWindow = 10 * time.Minute
+
+// manifestCandidate 需要進行文本元數據分析的影片ID目錄
+type manifestCandidate struct {
+	info      models.VideoFileInfo
+	videoID   int64                 // 已連結的影片紀錄，尚未建立時為 0
+	status    models.AnalysisStatus // 已連結影片的分析狀態
+	reanalyze bool                  // 檔案在分析後被修改
+}
+
+// needsTextAnalysis 影片狀態是否仍需文本元數據分析 (與 analyzeTextFile 略過的狀態互補)；尚無影片紀錄時狀態為空字串
+func needsTextAnalysis(status models.AnalysisStatus) bool {
+	switch status {
+	case "", models.StatusPending, models.StatusMetadataExtracting, models.StatusTxtAnalysisFailed, models.StatusFailed:
+		return true
+	}
+	return false
+}
+
+// syncNASManifest 增量更新 nas_files 清單並回傳需要文本元數據分析的配對。
+// 只重新讀取修改時間有變化的影片ID目錄；距上次全量檢查超過 nas.fullScanHours 時檢查所有目錄，
+// 以涵蓋原地覆寫而不改變目錄修改時間的檔案。新增或大小、修改時間改變的檔案會重新計算 sha256，
+// 與分析時的 sha256 不同時標記為需重新分析。物件儲存每次以 bucket 列表全量比對，並以 ETag 取代下載檔案計算 sha256
+func (s *AnalyzeService) syncNASManifest() ([]manifestCandidate, error) {
+	s.manifestMu.Lock()
+	defer s.manifestMu.Unlock()
+
+	root, err := filepath.Abs(s.cfg.NAS.VideoPath)
+	if err != nil {
+		return nil, fmt.Errorf("無法取得 Download 路徑的絕對路徑 '%s': %w", s.cfg.NAS.VideoPath, err)
+	}
+	existing, err := s.db.GetNASManifest()
+	if err != nil {
+		return nil, err
+	}
+	byDir := groupManifestByDir(existing)
+
+	full := time.Since(s.lastFullManifestScan) >= time.Duration(s.cfg.NAS.FullScanHours)*time.Hour
+	startedAt := time.Now()
+
+	var toSave []models.NASFile
+	var toDelete []int64
+	seen := make(map[string]bool)
+	var unreadable []string
+	changedDirs, hashedFiles := 0, 0
+	syncDir := func(dir nasDir) {
+		changedDirs++
+		saves, deletes, hashed := s.syncManifestDir(root, dir, byDir[dir.sourceName+"/"+dir.videoID])
+		toSave = append(toSave, saves...)
+		toDelete = append(toDelete, deletes...)
+		hashedFiles += hashed
+	}
+
+	if lister, ok := s.nas.(NASLister); ok {
+		objects, err := lister.ListFiles("")
+		if err != nil {
+			return nil, fmt.Errorf("列出物件儲存檔案失敗: %w", err)
+		}
+		full = true // 列表已包含所有檔案的大小、修改時間與 ETag，不需讀取檔案即可全量比對
+		for _, dir := range groupObjectsByDir(objects) {
+			seen[dir.sourceName+"/"+dir.videoID] = true
+			syncDir(dir)
+		}
+	} else {
+		sourceDirs, err := os.ReadDir(root)
+		if err != nil {
+			return nil, fmt.Errorf("讀取 Download 目錄 '%s' 失敗: %w", root, err)
+		}
+		for _, sourceDir := range sourceDirs {
+			if !sourceDir.IsDir() || strings.HasPrefix(sourceDir.Name(), ".") {
+				continue
+			}
+			sourceName := sourceDir.Name()
+			idDirs, err := os.ReadDir(filepath.Join(root, sourceName))
+			if err != nil {
+				log.Printf("警告：[AnalyzeService-Manifest] 讀取來源目錄 '%s' 失敗，保留其清單紀錄: %v", sourceName, err)
+				unreadable = append(unreadable, sourceName+"/")
+				continue
+			}
+			for _, idDir := range idDirs {
+				if !idDir.IsDir() || strings.HasPrefix(idDir.Name(), ".") {
+					continue
+				}
+				rel := sourceName + "/" + idDir.Name()
+				seen[rel] = true
+				dirInfo, err := idDir.Info()
+				if err != nil {
+					continue
+				}
+				if dirRow := findManifestKind(byDir[rel], models.NASFileDir); !full && dirRow != nil && dirRow.ModTimeNs == dirInfo.ModTime().UnixNano() {
+					continue
+				}
+				objects, err := listLocalVideoDir(root, sourceName, idDir.Name())
+				if err != nil {
+					log.Printf("警告：[AnalyzeService-Manifest] %v", err)
+					continue
+				}
+				syncDir(nasDir{sourceName: sourceName, videoID: idDir.Name(), modTimeNs: dirInfo.ModTime().UnixNano(), objects: objects})
+			}
+		}
+	}
+	// 已從 NAS 移除的目錄
+	for dir, rows := range byDir {
+		if seen[dir] || hasAnyPrefix(dir+"/", unreadable) {
+			continue
+		}
+		for _, r := range rows {
+			toDelete = append(toDelete, r.ID)
+		}
+	}
+
+	if err := s.db.SaveNASFiles(toSave); err != nil {
+		return nil, err
+	}
+	if err := s.db.DeleteNASFiles(toDelete); err != nil {
+		return nil, err
+	}
+	if err := s.db.LinkNASFiles(); err != nil {
+		return nil, err
+	}
+	if full {
+		s.lastFullManifestScan = startedAt
+	}
+	log.Printf("資訊：[AnalyzeService-Manifest] 清單同步完成 (全量檢查: %t)：檢查 %d 個目錄，計算 %d 個檔案的 sha256，移除 %d 筆紀錄，耗時 %s。",
+		full, changedDirs, hashedFiles, len(toDelete), time.Since(startedAt).Round(time.Millisecond))
+
+	manifest, err := s.db.GetNASManifest()
+	if err != nil {
+		return nil, err
+	}
+	return s.manifestCandidates(root, manifest), nil
+}
+
+// syncManifestDir 比對單一影片ID目錄中的影片/音訊與 TXT 檔案，回傳要儲存與刪除的紀錄及計算 sha256 的檔案數
+func (s *AnalyzeService) syncManifestDir(root string, dir nasDir, rows []models.NASFile) ([]models.NASFile, []int64, int) {
+	dirRow := models.NASFile{
+		Path: dir.sourceName + "/" + dir.videoID, Kind: models.NASFileDir,
+		SourceName: dir.sourceName, SourceID: dir.videoID, ModTimeNs: dir.modTimeNs,
+	}
+
+	var saves []models.NASFile
+	keep := make(map[string]bool)
+	hashed := 0
+	for _, o := range dir.objects {
+		ext := strings.ToLower(path.Ext(o.Path))
+		kind := models.NASFileVideo
+		switch {
+		case supportedVideoExtensions[ext] || renditions.AudioExtensions[ext]:
+		case ext == ".txt":
+			kind = models.NASFileText
+		default:
+			continue
+		}
+		keep[o.Path] = true
+		if time.Since(o.ModTime) < recentWriteWindow {
+			dirRow.ModTimeNs = 0 // 可能仍在下載，下次掃描重新檢查
+		}
+
+		f := models.NASFile{Path: o.Path, Kind: kind, SourceName: dir.sourceName, SourceID: dir.videoID, Size: o.Size, ModTimeNs: o.ModTime.UnixNano()}
+		old := findManifestPath(rows, o.Path)
+		if old != nil {
+			if old.Size == f.Size && old.ModTimeNs == f.ModTimeNs && old.SHA256 != "" {
+				continue
+			}
+			f.SHA256, f.AnalyzedSHA256, f.NeedsReanalysis = old.SHA256, old.AnalyzedSHA256, old.NeedsReanalysis
+		}
+		sum, err := objectFingerprint(root, o)
+		if err != nil {
+			log.Printf("警告：[AnalyzeService-Manifest] 計算 '%s' 的 sha256 失敗: %v", o.Path, err)
+			continue
+		}
+		hashed++
+		if f.AnalyzedSHA256 != "" && sum != f.AnalyzedSHA256 && !f.NeedsReanalysis {
+			log.Printf("警告：[AnalyzeService-Manifest] '%s' 在分析後被修改，標記為需重新分析。", o.Path)
+			f.NeedsReanalysis = true
+		}
+		f.SHA256 = sum
+		saves = append(saves, f)
+	}
+	saves = append(saves, dirRow)
+
+	var deletes []int64
+	for _, r := range rows {
+		if r.Kind != models.NASFileDir && !keep[r.Path] {
+			deletes = append(deletes, r.ID)
+		}
+	}
+	return saves, deletes, hashed
+}
+
+// manifestCandidates 由清單找出有 TXT 檔案、且影片尚未完成文本元數據分析或檔案在分析後被修改的目錄，
+// 並重新讀取這些目錄以取得完整的版本與 TXT 清單
+func (s *AnalyzeService) manifestCandidates(root string, manifest []models.NASFile) []manifestCandidate {
+	var candidates []manifestCandidate
+	for _, rows := range groupManifestByDir(manifest) {
+		text := findManifestKind(rows, models.NASFileText)
+		if text == nil {
+			continue
+		}
+		reanalyze := false
+		for _, r := range rows {
+			reanalyze = reanalyze || r.NeedsReanalysis
+		}
+		if !reanalyze && !needsTextAnalysis(text.AnalysisStatus) {
+			continue
+		}
+		info, err := s.scanVideoDir(root, text.SourceName, text.SourceID)
+		if err != nil || info.TextFilePath == "" {
+			continue // 目錄在同步後被移除
+		}
+		candidates = append(candidates, manifestCandidate{info: info, videoID: text.VideoID.Int64, status: text.AnalysisStatus, reanalyze: reanalyze})
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].info.RelativePath < candidates[j].info.RelativePath })
+	return candidates
+}
+
+// groupManifestByDir 依影片ID目錄 (source/id) 分組
+func groupManifestByDir(files []models.NASFile) map[string][]models.NASFile {
+	byDir := make(map[string][]models.NASFile)
+	for _, f := range files {
+		dir := f.Path
+		if f.Kind != models.NASFileDir {
+			dir = path.Dir(f.Path)
+		}
+		byDir[dir] = append(byDir[dir], f)
+	}
+	return byDir
+}
+
+func findManifestKind(rows []models.NASFile, kind models.NASFileKind) *models.NASFile {
+	for i := range rows {
+		if rows[i].Kind == kind {
+			return &rows[i]
+		}
+	}
+	return nil
+}
+
+func findManifestPath(rows []models.NASFile, rel string) *models.NASFile {
+	for i := range rows {
+		if rows[i].Path == rel {
+			return &rows[i]
+		}
+	}
+	return nil
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// objectFingerprint 檔案內容的 sha256；物件儲存的檔案以 ETag 的 sha256 代替 (內容改變時 ETag 隨之改變)，不需下載
+func objectFingerprint(root string, o models.StorageObject) (string, error) {
+	if o.ETag != "" {
+		sum := sha256.Sum256([]byte("etag:" + o.ETag))
+		return hex.EncodeToString(sum[:]), nil
+	}
+	return fileSHA256(filepath.Join(root, filepath.FromSlash(o.Path)))
+}
+
+func fileSHA256(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
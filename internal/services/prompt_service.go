@@ -0,0 +1,292 @@
+package services
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/models"
+	"AiHackathon-admin/internal/prompttpl"
+	"AiHackathon-admin/internal/web/handlers"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+)
+
+// promptImportAuthor 為自設定檔匯入的版本與啟動時預設使用版本所記錄的作者
+const promptImportAuthor = "config-import"
+
+// promptReloadAuthor 為設定檔重新載入後切換使用版本時所記錄的操作者
+const promptReloadAuthor = "config-reload"
+
+// errPromptNotConfigured 表示資料庫與設定檔皆未設定可用的 prompt 版本
+var errPromptNotConfigured = errors.New("未設定可用的 prompt 版本")
+
+// errPromptInvalidTemplate 表示影片 prompt 的佔位符無法解析
+var errPromptInvalidTemplate = errors.New("prompt 範本無效")
+
+// validatePrompt 於載入時檢查影片內容分析 prompt 的佔位符 (文本分析 prompt 不套用範本，原樣送出)
+func validatePrompt(kind models.PromptKind, version, content string) error {
+	if kind != models.PromptKindVideo {
+		return nil
+	}
+	if err := prompttpl.Validate(version, content); err != nil {
+		return fmt.Errorf("%w: %v", errPromptInvalidTemplate, err)
+	}
+	return nil
+}
+
+// PromptService 負責取得分析時使用的 prompt。
+// 優先使用資料庫中目前啟用的版本 (可於 /admin/prompts 即時切換)，
+// 資料庫尚未設定或查詢失敗時退回設定檔 prompts.*.currentVersion 指向的檔案。
+type PromptService struct {
+	db handlers.DBStore
+
+	mu      sync.RWMutex
+	prompts config.PromptConfig // 設定檔中的 prompt 設定，可於重新載入時更新
+}
+
+// NewPromptService 建立 PromptService 實例
+func NewPromptService(cfg *config.Config, db handlers.DBStore) (*PromptService, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("PromptService：設定不得為空")
+	}
+	if db == nil {
+		return nil, fmt.Errorf("PromptService：DBStore 不得為空")
+	}
+	log.Println("資訊：PromptService 初始化完成。")
+	return &PromptService{db: db, prompts: cfg.Prompts}, nil
+}
+
+// configuredPrompts 回傳設定檔中指定類型的目前版本與版本檔案路徑
+func (s *PromptService) configuredPrompts(kind models.PromptKind) (string, map[string]string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return promptsOfKind(s.prompts, kind)
+}
+
+func promptsOfKind(p config.PromptConfig, kind models.PromptKind) (string, map[string]string) {
+	if kind == models.PromptKindText {
+		return p.TextFileAnalysis.CurrentVersion, p.TextFileAnalysis.Versions
+	}
+	return p.VideoAnalysis.CurrentVersion, p.VideoAnalysis.Versions
+}
+
+// UpdatePrompts 於設定檔重新載入後更新 prompt 設定：匯入新增的版本，
+// 並在設定檔的 currentVersion 變更時將資料庫的使用版本切換過去。
+// 進行中的分析已取得 prompt 內容，不受影響；下一部影片起使用新版本。
+func (s *PromptService) UpdatePrompts(p config.PromptConfig) error {
+	s.mu.Lock()
+	old := s.prompts
+	s.prompts = p
+	s.mu.Unlock()
+
+	errs := []error{s.ImportFileVersions()}
+	for _, kind := range []models.PromptKind{models.PromptKindText, models.PromptKindVideo} {
+		oldCurrent, _ := promptsOfKind(old, kind)
+		newCurrent, _ := promptsOfKind(p, kind)
+		if newCurrent == "" || newCurrent == oldCurrent {
+			continue
+		}
+		target, ok := s.importedVersion(kind, newCurrent)
+		if !ok {
+			log.Printf("警告：[PromptService] 設定檔的 %s prompt currentVersion '%s' 未能匯入資料庫，維持原使用版本", kind, newCurrent)
+			continue
+		}
+		if err := s.db.SetActivePrompt(kind, target, promptReloadAuthor); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		log.Printf("資訊：[PromptService] %s prompt 使用版本已依設定檔切換為 '%s'", kind, target)
+	}
+	return errors.Join(errs...)
+}
+
+// ImportFileVersions 將設定檔中尚未存在於資料庫的 prompt 版本匯入，
+// 並在資料庫尚未設定使用版本時，以設定檔的 currentVersion 作為使用版本。
+// 已存在的版本不會被覆寫：檔案內容變更時改以衍生版本名稱 (見 changedVersionName) 匯入為新版本。
+func (s *PromptService) ImportFileVersions() error {
+	var errs []error
+	for _, kind := range []models.PromptKind{models.PromptKindText, models.PromptKindVideo} {
+		current, versions := s.configuredPrompts(kind)
+		keys := make([]string, 0, len(versions))
+		for k := range versions {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, version := range keys {
+			existing, err := s.db.GetPromptVersion(kind, version)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			path := versions[version]
+			content, err := os.ReadFile(path)
+			if err != nil {
+				log.Printf("警告：[PromptService] 讀取 %s prompt 版本 '%s' 的檔案 '%s' 失敗，略過匯入: %v", kind, version, path, err)
+				continue
+			}
+			if existing != nil {
+				target := version
+				if string(content) != existing.Content {
+					if target, err = s.importChangedFile(kind, version, path, string(content)); err != nil {
+						errs = append(errs, err)
+						continue
+					}
+				}
+				if version == current && target != "" {
+					if err := s.followFileVersion(kind, version, target, path); err != nil {
+						errs = append(errs, err)
+					}
+				}
+				continue
+			}
+			if err := validatePrompt(kind, version, string(content)); err != nil {
+				log.Printf("警告：[PromptService] %s prompt 檔案 '%s' 無效，略過匯入: %v", kind, path, err)
+				continue
+			}
+			if _, err := s.db.CreatePromptVersion(&models.PromptVersion{
+				Kind:    kind,
+				Version: version,
+				Content: string(content),
+				Author:  promptImportAuthor,
+				Notes:   fmt.Sprintf("自設定檔 %s 匯入", path),
+			}); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			log.Printf("資訊：[PromptService] 已匯入 %s prompt 版本 '%s' (%s)", kind, version, path)
+		}
+
+		active, err := s.db.GetActivePrompt(kind)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if active != nil || current == "" {
+			continue
+		}
+		target, ok := s.importedVersion(kind, current)
+		if !ok {
+			log.Printf("警告：[PromptService] 設定檔的 %s prompt currentVersion '%s' 未能匯入資料庫，暫不設定使用版本", kind, current)
+			continue
+		}
+		if err := s.db.SetActivePrompt(kind, target, promptImportAuthor); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		log.Printf("資訊：[PromptService] %s prompt 使用版本設定為 '%s' (來自設定檔)", kind, target)
+	}
+	return errors.Join(errs...)
+}
+
+// changedVersionName 回傳版本檔案內容變更後匯入時使用的版本名稱：<原版本>-<內容 sha256 前 8 碼>。
+// 同一內容的名稱固定，重複匯入時不會產生多個版本
+func changedVersionName(version, content string) string {
+	sum := sha256.Sum256([]byte(content))
+	const maxLen = 64 // prompt_versions.version 欄位長度
+	suffix := "-" + hex.EncodeToString(sum[:4])
+	if len(version)+len(suffix) > maxLen {
+		version = version[:maxLen-len(suffix)]
+	}
+	return version + suffix
+}
+
+// importedVersion 回傳設定檔版本 version 在資料庫中對應的版本名稱：檔案內容與資料庫中的版本不同且已匯入為衍生版本時
+// 回傳衍生版本，否則回傳 version。版本不存在於資料庫時 ok 為 false
+func (s *PromptService) importedVersion(kind models.PromptKind, version string) (string, bool) {
+	p, err := s.db.GetPromptVersion(kind, version)
+	if err != nil || p == nil {
+		return "", false
+	}
+	_, versions := s.configuredPrompts(kind)
+	content, err := os.ReadFile(versions[version])
+	if err != nil || string(content) == p.Content {
+		return version, true
+	}
+	derived := changedVersionName(version, string(content))
+	if d, err := s.db.GetPromptVersion(kind, derived); err == nil && d != nil {
+		return derived, true
+	}
+	return version, true
+}
+
+// importChangedFile 將內容與資料庫中版本 version 不同的設定檔匯入為衍生版本 (基準版本為 version)，回傳衍生版本名稱；
+// 內容無效而略過匯入時回傳空字串
+func (s *PromptService) importChangedFile(kind models.PromptKind, version, path, content string) (string, error) {
+	derived := changedVersionName(version, content)
+	existing, err := s.db.GetPromptVersion(kind, derived)
+	if err != nil || existing != nil {
+		return derived, err
+	}
+	if err := validatePrompt(kind, derived, content); err != nil {
+		log.Printf("警告：[PromptService] %s prompt 檔案 '%s' 已變更但內容無效，略過匯入: %v", kind, path, err)
+		return "", nil
+	}
+	if _, err := s.db.CreatePromptVersion(&models.PromptVersion{
+		Kind:        kind,
+		Version:     derived,
+		Content:     content,
+		BaseVersion: version,
+		Author:      promptImportAuthor,
+		Notes:       fmt.Sprintf("設定檔 %s 的內容與版本 '%s' 不同，匯入為新版本", path, version),
+	}); err != nil {
+		return "", err
+	}
+	log.Printf("資訊：[PromptService] %s prompt 檔案 '%s' 與版本 '%s' 內容不同，已匯入為新版本 '%s'", kind, path, version, derived)
+	return derived, nil
+}
+
+// followFileVersion 讓使用版本跟隨設定檔 currentVersion 檔案的內容：資料庫目前使用的是 version 或其先前匯入的
+// 衍生版本時切換為 target (與檔案內容相同的版本)，使檔案的修改在下一部影片起生效。
+// 管理員於 /admin/prompts 切換的其他版本不會被覆蓋
+func (s *PromptService) followFileVersion(kind models.PromptKind, version, target, path string) error {
+	active, err := s.db.GetActivePrompt(kind)
+	if err != nil || active == nil || active.Version == target {
+		return err
+	}
+	if active.Version != version {
+		activeVersion, err := s.db.GetPromptVersion(kind, active.Version)
+		if err != nil {
+			return err
+		}
+		if activeVersion == nil || activeVersion.BaseVersion != version || activeVersion.Author != promptImportAuthor {
+			return nil
+		}
+	}
+	if err := s.db.SetActivePrompt(kind, target, promptImportAuthor); err != nil {
+		return err
+	}
+	log.Printf("資訊：[PromptService] %s prompt 使用版本已切換為 '%s' (設定檔 %s 內容變更)", kind, target, path)
+	return nil
+}
+
+// Resolve 取得指定類型目前使用的 prompt 內容與版本 (影片 prompt 尚未填入佔位符)
+func (s *PromptService) Resolve(kind models.PromptKind) (string, string, error) {
+	active, err := s.db.GetActivePrompt(kind)
+	if err != nil {
+		log.Printf("警告：[PromptService] %v，改用設定檔的 prompt", err)
+	} else if active != nil {
+		p, err := s.db.GetPromptVersion(kind, active.Version)
+		if err != nil {
+			log.Printf("警告：[PromptService] %v，改用設定檔的 prompt", err)
+		} else if p != nil {
+			return p.Content, p.Version, validatePrompt(kind, p.Version, p.Content)
+		} else {
+			log.Printf("警告：[PromptService] 使用中的 %s prompt 版本 '%s' 不存在，改用設定檔的 prompt", kind, active.Version)
+		}
+	}
+
+	current, versions := s.configuredPrompts(kind)
+	path, ok := versions[current]
+	if !ok || path == "" {
+		return "", current, fmt.Errorf("%w (%s, 版本: '%s')", errPromptNotConfigured, kind, current)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", current, fmt.Errorf("讀取 prompt 檔案 '%s' (版本 '%s') 失敗: %w", path, current, err)
+	}
+	return string(content), current, validatePrompt(kind, current, string(content))
+}
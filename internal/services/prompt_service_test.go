@@ -0,0 +1,249 @@
+package services
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/models"
+	"AiHackathon-admin/internal/web/handlers"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// fakePromptDB 以記憶體保存 prompt_versions 與 active_prompts
+type fakePromptDB struct {
+	handlers.DBStore
+
+	versions map[models.PromptKind]map[string]models.PromptVersion
+	active   map[models.PromptKind]models.ActivePrompt
+}
+
+func newFakePromptDB() *fakePromptDB {
+	return &fakePromptDB{versions: make(map[models.PromptKind]map[string]models.PromptVersion), active: make(map[models.PromptKind]models.ActivePrompt)}
+}
+
+func (db *fakePromptDB) GetPromptVersion(kind models.PromptKind, version string) (*models.PromptVersion, error) {
+	p, ok := db.versions[kind][version]
+	if !ok {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+func (db *fakePromptDB) CreatePromptVersion(p *models.PromptVersion) (int64, error) {
+	if db.versions[p.Kind] == nil {
+		db.versions[p.Kind] = make(map[string]models.PromptVersion)
+	}
+	db.versions[p.Kind][p.Version] = *p
+	return int64(len(db.versions[p.Kind])), nil
+}
+
+func (db *fakePromptDB) GetActivePrompt(kind models.PromptKind) (*models.ActivePrompt, error) {
+	a, ok := db.active[kind]
+	if !ok {
+		return nil, nil
+	}
+	return &a, nil
+}
+
+func (db *fakePromptDB) SetActivePrompt(kind models.PromptKind, version, activatedBy string) error {
+	db.active[kind] = models.ActivePrompt{Kind: kind, Version: version, ActivatedBy: activatedBy}
+	return nil
+}
+
+// videoVersions 回傳影片 prompt 的版本名稱 (排序後)
+func (db *fakePromptDB) videoVersions() []string {
+	var names []string
+	for v := range db.versions[models.PromptKindVideo] {
+		names = append(names, v)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (db *fakePromptDB) activeVideo() string {
+	return db.active[models.PromptKindVideo].Version
+}
+
+// promptFixture 在暫存目錄建立影片 prompt 檔案並回傳對應的 PromptConfig
+type promptFixture struct {
+	t   *testing.T
+	dir string
+	cfg config.PromptConfig
+}
+
+func newPromptFixture(t *testing.T, current string, files map[string]string) *promptFixture {
+	f := &promptFixture{t: t, dir: t.TempDir()}
+	f.cfg.VideoAnalysis.CurrentVersion = current
+	f.cfg.VideoAnalysis.Versions = make(map[string]string)
+	for version, content := range files {
+		f.write(version, content)
+	}
+	return f
+}
+
+func (f *promptFixture) write(version, content string) {
+	f.t.Helper()
+	path := filepath.Join(f.dir, version+".txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		f.t.Fatal(err)
+	}
+	f.cfg.VideoAnalysis.Versions[version] = path
+}
+
+func newTestPromptService(t *testing.T, db *fakePromptDB, prompts config.PromptConfig) *PromptService {
+	t.Helper()
+	svc, err := NewPromptService(&config.Config{Prompts: prompts}, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+func TestImportFileVersionsImportsChangedFileAsNewVersion(t *testing.T) {
+	db := newFakePromptDB()
+	f := newPromptFixture(t, "v7", map[string]string{"v6": "六", "v7": "七"})
+	svc := newTestPromptService(t, db, f.cfg)
+	if err := svc.ImportFileVersions(); err != nil {
+		t.Fatal(err)
+	}
+	if got := db.videoVersions(); !reflect.DeepEqual(got, []string{"v6", "v7"}) || db.activeVideo() != "v7" {
+		t.Fatalf("首次匯入: 版本 %v，使用版本 %s", got, db.activeVideo())
+	}
+
+	// 修改 currentVersion 的檔案：匯入為衍生版本並切換使用版本，原版本不變
+	f.write("v7", "七 (修訂)")
+	if err := svc.ImportFileVersions(); err != nil {
+		t.Fatal(err)
+	}
+	derived := changedVersionName("v7", "七 (修訂)")
+	p, _ := db.GetPromptVersion(models.PromptKindVideo, derived)
+	if p == nil || p.Content != "七 (修訂)" || p.BaseVersion != "v7" || p.Author != promptImportAuthor {
+		t.Fatalf("衍生版本 %s = %+v", derived, p)
+	}
+	if orig, _ := db.GetPromptVersion(models.PromptKindVideo, "v7"); orig.Content != "七" {
+		t.Errorf("已儲存的版本不應被覆寫: %q", orig.Content)
+	}
+	if db.activeVideo() != derived {
+		t.Errorf("使用版本 = %s, want %s", db.activeVideo(), derived)
+	}
+
+	// 內容未再變更時重複匯入不會產生新版本
+	if err := svc.ImportFileVersions(); err != nil {
+		t.Fatal(err)
+	}
+	if got := db.videoVersions(); len(got) != 3 {
+		t.Errorf("重複匯入不應新增版本: %v", got)
+	}
+
+	// 再次修改：由先前的衍生版本切換到新的衍生版本
+	f.write("v7", "七 (再修訂)")
+	if err := svc.ImportFileVersions(); err != nil {
+		t.Fatal(err)
+	}
+	if want := changedVersionName("v7", "七 (再修訂)"); db.activeVideo() != want {
+		t.Errorf("使用版本 = %s, want %s", db.activeVideo(), want)
+	}
+
+	// 改回原內容時切換回原版本，不再產生衍生版本
+	f.write("v7", "七")
+	if err := svc.ImportFileVersions(); err != nil {
+		t.Fatal(err)
+	}
+	if got := db.videoVersions(); len(got) != 4 || db.activeVideo() != "v7" {
+		t.Errorf("內容與原版本相同時應使用原版本且不新增版本: 版本 %v，使用版本 %s", got, db.activeVideo())
+	}
+}
+
+func TestImportFileVersionsKeepsManuallyActivatedVersion(t *testing.T) {
+	db := newFakePromptDB()
+	f := newPromptFixture(t, "v7", map[string]string{"v6": "六", "v7": "七"})
+	svc := newTestPromptService(t, db, f.cfg)
+	if err := svc.ImportFileVersions(); err != nil {
+		t.Fatal(err)
+	}
+	// 管理員於 /admin/prompts 切換回 v6
+	db.SetActivePrompt(models.PromptKindVideo, "v6", "editor")
+
+	f.write("v7", "七 (修訂)")
+	f.write("v6", "六 (修訂)") // 非 currentVersion 的檔案
+	if err := svc.ImportFileVersions(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"v6", changedVersionName("v6", "六 (修訂)"), "v7", changedVersionName("v7", "七 (修訂)")}
+	sort.Strings(want)
+	if got := db.videoVersions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("版本 = %v, want %v", got, want)
+	}
+	if a := db.active[models.PromptKindVideo]; a.Version != "v6" || a.ActivatedBy != "editor" {
+		t.Errorf("不應覆蓋管理員切換的使用版本: %+v", a)
+	}
+}
+
+func TestImportFileVersionsSkipsInvalidChangedTemplate(t *testing.T) {
+	db := newFakePromptDB()
+	f := newPromptFixture(t, "v7", map[string]string{"v7": "標題：{{.Title}}"})
+	svc := newTestPromptService(t, db, f.cfg)
+	if err := svc.ImportFileVersions(); err != nil {
+		t.Fatal(err)
+	}
+	f.write("v7", "標題：{{.Headline}}")
+	if err := svc.ImportFileVersions(); err != nil {
+		t.Fatal(err)
+	}
+	if got := db.videoVersions(); !reflect.DeepEqual(got, []string{"v7"}) || db.activeVideo() != "v7" {
+		t.Errorf("無效的範本不應匯入: 版本 %v，使用版本 %s", got, db.activeVideo())
+	}
+}
+
+func TestImportFileVersionsActivatesChangedCurrentOnFirstRun(t *testing.T) {
+	// 資料庫已有 v7 但尚未設定使用版本 (例如使用版本被清除)，而檔案內容已變更
+	db := newFakePromptDB()
+	db.CreatePromptVersion(&models.PromptVersion{Kind: models.PromptKindVideo, Version: "v7", Content: "七", Author: promptImportAuthor})
+	f := newPromptFixture(t, "v7", map[string]string{"v7": "七 (修訂)"})
+	svc := newTestPromptService(t, db, f.cfg)
+	if err := svc.ImportFileVersions(); err != nil {
+		t.Fatal(err)
+	}
+	if want := changedVersionName("v7", "七 (修訂)"); db.activeVideo() != want {
+		t.Errorf("使用版本 = %s, want %s", db.activeVideo(), want)
+	}
+}
+
+func TestUpdatePromptsSwitchesToChangedVersion(t *testing.T) {
+	db := newFakePromptDB()
+	f := newPromptFixture(t, "v6", map[string]string{"v6": "六", "v7": "七"})
+	svc := newTestPromptService(t, db, f.cfg)
+	if err := svc.ImportFileVersions(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新載入時同時修改 v7 檔案並將 currentVersion 切換為 v7
+	f.write("v7", "七 (修訂)")
+	f.cfg.VideoAnalysis.CurrentVersion = "v7"
+	if err := svc.UpdatePrompts(f.cfg); err != nil {
+		t.Fatal(err)
+	}
+	if a := db.active[models.PromptKindVideo]; a.Version != changedVersionName("v7", "七 (修訂)") || a.ActivatedBy != promptReloadAuthor {
+		t.Errorf("使用版本 = %+v", a)
+	}
+	content, version, err := svc.Resolve(models.PromptKindVideo)
+	if err != nil || content != "七 (修訂)" || version != changedVersionName("v7", "七 (修訂)") {
+		t.Errorf("Resolve = %q, %q, %v", content, version, err)
+	}
+}
+
+func TestChangedVersionName(t *testing.T) {
+	a := changedVersionName("v7", "a")
+	if !strings.HasPrefix(a, "v7-") || len(a) != len("v7-")+8 {
+		t.Errorf("changedVersionName = %q", a)
+	}
+	if a == changedVersionName("v7", "b") || a != changedVersionName("v7", "a") {
+		t.Error("版本名稱應由內容決定")
+	}
+	if got := changedVersionName(strings.Repeat("x", 64), "a"); len(got) != 64 || !strings.HasSuffix(got, a[2:]) {
+		t.Errorf("過長的版本名稱應截斷至 64 字元: %q", got)
+	}
+}
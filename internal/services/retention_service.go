@@ -0,0 +1,183 @@
+package services
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/models"
+	"AiHackathon-admin/internal/retention"
+	"AiHackathon-admin/internal/web/handlers"
+	"AiHackathon-admin/internal/webproxy"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RetentionService 依 retention 設定刪除超過保留期限的影片版本，並將影片標記為 archived
+type RetentionService struct {
+	db      handlers.DBStore
+	nas     NASStorage
+	nasRoot string
+
+	mu      sync.RWMutex
+	cfg     config.RetentionConfig // 可於設定重新載入時更新
+	running sync.Mutex
+}
+
+// NewRetentionService 建立 RetentionService 實例
+func NewRetentionService(cfg *config.Config, db handlers.DBStore, nas NASStorage) (*RetentionService, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("RetentionService：設定不得為空")
+	}
+	if db == nil {
+		return nil, fmt.Errorf("RetentionService：DBStore 不得為空")
+	}
+	if nas == nil {
+		return nil, fmt.Errorf("RetentionService：NASStorage 不得為空")
+	}
+	log.Printf("資訊：RetentionService 初始化完成 (%d 條規則，dryRun: %t)。", len(cfg.Retention.Rules), cfg.Retention.DryRun)
+	return &RetentionService{db: db, nas: nas, nasRoot: cfg.NAS.VideoPath, cfg: cfg.Retention}, nil
+}
+
+// UpdateConfig 於設定重新載入後更新保留規則
+func (s *RetentionService) UpdateConfig(c config.RetentionConfig) {
+	s.mu.Lock()
+	s.cfg = c
+	s.mu.Unlock()
+}
+
+func (s *RetentionService) config() config.RetentionConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
+// Report 產生試算報表 (不刪除任何檔案)
+func (s *RetentionService) Report() (retention.Report, error) {
+	report, err := s.plan(s.config())
+	report.DryRun = true
+	return report, err
+}
+
+// Run 執行保留政策：刪除超過期限的檔案、更新版本清單並將影片標記為 archived；dryRun 時只記錄報表
+func (s *RetentionService) Run() error {
+	if !s.running.TryLock() {
+		return fmt.Errorf("保留政策清理任務仍在執行")
+	}
+	defer s.running.Unlock()
+
+	cfg := s.config()
+	if len(cfg.Rules) == 0 {
+		log.Println("資訊：[RetentionService] 未設定保留規則，略過清理。")
+		return nil
+	}
+	report, err := s.plan(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.DryRun {
+		for _, a := range report.Actions {
+			log.Printf("資訊：[RetentionService] (dryRun) 影片 ID %d (%s/%s，評級 %s) 將依規則 '%s' 刪除 %d 個檔案 (%d bytes)。", a.VideoID, a.SourceName, a.SourceID, a.Rating, a.Rule, len(a.Files), a.Bytes)
+		}
+		log.Printf("資訊：[RetentionService] (dryRun) 共 %d 部影片、%d 個檔案、%d bytes 符合保留規則；%d 部釘選影片受保護。", len(report.Actions), report.TotalFiles, report.TotalBytes, len(report.Protected))
+		return nil
+	}
+
+	var deletedFiles, failed int
+	var deletedBytes int64
+	for _, a := range report.Actions {
+		n, bytes, err := s.apply(a)
+		deletedFiles += n
+		deletedBytes += bytes
+		if err != nil {
+			failed++
+			log.Printf("錯誤：[RetentionService] 影片 ID %d: %v", a.VideoID, err)
+		}
+	}
+	log.Printf("資訊：[RetentionService] 清理完成：刪除 %d 個檔案 (%d bytes)，%d 部影片處理失敗，%d 部釘選影片受保護。", deletedFiles, deletedBytes, failed, len(report.Protected))
+	return nil
+}
+
+// plan 查詢已完成或已封存的影片及其版本，依規則試算
+func (s *RetentionService) plan(cfg config.RetentionConfig) (retention.Report, error) {
+	items, err := s.db.GetRetentionItems()
+	if err != nil {
+		return retention.Report{}, err
+	}
+	ids := make([]int64, len(items))
+	for i, item := range items {
+		ids[i] = item.VideoID
+	}
+	files, err := s.db.GetVideoFiles(ids)
+	if err != nil {
+		return retention.Report{}, err
+	}
+	for i := range items {
+		items[i].Files = files[items[i].VideoID]
+		// 只有 TXT 的影片 nas_path 指向 TXT 檔案，不列入
+		if len(items[i].Files) == 0 && supportedVideoExtensions[strings.ToLower(filepath.Ext(items[i].NASPath))] && items[i].Status != models.StatusArchived {
+			items[i].Files = []models.VideoFile{s.legacyMaster(items[i])}
+		}
+	}
+	return retention.Plan(items, cfg.Rules, time.Now()), nil
+}
+
+// legacyMaster 以 nas_path 代表尚未記錄版本清單的影片 (建立版本清單前分析的影片)
+func (s *RetentionService) legacyMaster(item models.RetentionItem) models.VideoFile {
+	f := models.VideoFile{VideoID: item.VideoID, Path: filepath.ToSlash(item.NASPath), Role: models.VideoFileMaster}
+	if fi, err := os.Stat(filepath.Join(s.nasRoot, item.NASPath)); err == nil {
+		f.Size, f.ModTime = fi.Size(), fi.ModTime()
+	}
+	return f
+}
+
+// apply 刪除單一影片符合規則的檔案；已不存在的檔案視為已刪除。刪除任一檔案後更新版本清單並標記為 archived
+func (s *RetentionService) apply(a retention.Action) (int, int64, error) {
+	deleted := make(map[string]bool)
+	var roles []string
+	var bytes int64
+	var errs []error
+	for _, f := range a.Files {
+		err := s.nas.DeleteVideo(filepath.FromSlash(f.Path))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("刪除 '%s' 失敗: %w", f.Path, err))
+			continue
+		}
+		// 一併刪除由此檔案產生的瀏覽器播放版本 (沒有時忽略)
+		if err := s.nas.DeleteVideo(filepath.FromSlash(webproxy.Path(f.Path))); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("警告：[RetentionService] 刪除 '%s' 的播放版本失敗: %v", f.Path, err)
+		}
+		deleted[f.Path] = true
+		roles = append(roles, string(f.Role))
+		bytes += f.Size
+	}
+	if len(deleted) == 0 {
+		return 0, 0, errors.Join(errs...)
+	}
+
+	files, err := s.db.GetVideoFiles([]int64{a.VideoID})
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		var remaining []models.VideoFile
+		for _, f := range files[a.VideoID] {
+			if !deleted[f.Path] {
+				remaining = append(remaining, f)
+			}
+		}
+		if err := s.db.SaveVideoFiles(a.VideoID, remaining); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	sort.Strings(roles)
+	reason := fmt.Sprintf("%s 依保留規則 '%s' 刪除 %s", time.Now().Format("2006-01-02"), a.Rule, strings.Join(roles, ", "))
+	if err := s.db.ArchiveVideo(a.VideoID, reason); err != nil {
+		errs = append(errs, err)
+	}
+	log.Printf("資訊：[RetentionService] 影片 ID %d (%s/%s)：%s。", a.VideoID, a.SourceName, a.SourceID, reason)
+	return len(deleted), bytes, errors.Join(errs...)
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/models"
+	"AiHackathon-admin/internal/renditions"
+	"AiHackathon-admin/internal/segmentation"
+	"AiHackathon-admin/internal/thumbnails"
+	"AiHackathon-admin/internal/web/handlers"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mediaURLSigner 可產生預先簽署網址的儲存後端 (s3.ObjectStorage)；ffmpeg 直接以 Range 請求讀取所需的部分，不需下載整部影片
+type mediaURLSigner interface {
+	PresignURL(relativePath string, expiry time.Duration) (string, error)
+}
+
+// ThumbnailService 以 ffmpeg 為影片產生海報與關鍵影格縮圖表，存放於播放版本旁並記錄於 videos
+type ThumbnailService struct {
+	db         handlers.DBStore
+	nas        NASStorage
+	ffmpegPath string // segmentation.ffmpegPath；空字串時由 PATH 尋找
+	rules      renditions.Rules
+
+	mu      sync.RWMutex
+	cfg     config.ThumbnailsConfig // 可於設定重新載入時更新
+	running sync.Mutex
+}
+
+// NewThumbnailService 建立 ThumbnailService 實例
+func NewThumbnailService(cfg *config.Config, db handlers.DBStore, nas NASStorage) (*ThumbnailService, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("ThumbnailService：設定不得為空")
+	}
+	if db == nil {
+		return nil, fmt.Errorf("ThumbnailService：DBStore 不得為空")
+	}
+	if nas == nil {
+		return nil, fmt.Errorf("ThumbnailService：NASStorage 不得為空")
+	}
+	if _, ok := segmentation.FindFFmpeg(cfg.Segmentation.FFmpegPath); !ok {
+		log.Println("警告：[ThumbnailService] 找不到 ffmpeg，安裝前不會產生影片縮圖，儀表板將直接載入影片。")
+	}
+	log.Println("資訊：ThumbnailService 初始化完成。")
+	return &ThumbnailService{
+		db:         db,
+		nas:        nas,
+		ffmpegPath: cfg.Segmentation.FFmpegPath,
+		rules:      renditions.RulesFromConfig(cfg.NAS.Renditions),
+		cfg:        cfg.Thumbnails,
+	}, nil
+}
+
+// UpdateConfig 於設定重新載入後更新縮圖尺寸與批次大小
+func (s *ThumbnailService) UpdateConfig(c config.ThumbnailsConfig) {
+	s.mu.Lock()
+	s.cfg = c
+	s.mu.Unlock()
+}
+
+func (s *ThumbnailService) config() config.ThumbnailsConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
+// Run 為一批尚未產生縮圖的影片產生海報與縮圖表；找不到 ffmpeg 時略過 (不視為錯誤)
+func (s *ThumbnailService) Run() error {
+	if !s.running.TryLock() {
+		return fmt.Errorf("縮圖產生任務仍在執行")
+	}
+	defer s.running.Unlock()
+
+	ffmpegPath, ok := segmentation.FindFFmpeg(s.ffmpegPath)
+	if !ok {
+		log.Println("資訊：[ThumbnailService] 找不到 ffmpeg，略過縮圖產生。")
+		return nil
+	}
+	cfg := s.config()
+	videos, err := s.db.GetVideosPendingThumbnails(cfg.BatchSize)
+	if err != nil {
+		return err
+	}
+	if len(videos) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(videos))
+	for i, v := range videos {
+		ids[i] = v.ID
+	}
+	files, err := s.db.GetVideoFiles(ids)
+	if err != nil {
+		return err
+	}
+	tempDir, err := os.MkdirTemp("", "thumbnails-*")
+	if err != nil {
+		return fmt.Errorf("無法建立縮圖暫存目錄: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	var generated, failed int
+	for _, v := range videos {
+		source, ok := renditions.ForStream(files[v.ID], s.rules)
+		if !ok {
+			// 尚未建立版本清單的影片以 nas_path 為來源
+			source = models.VideoFile{VideoID: v.ID, Path: filepath.ToSlash(v.NASPath), Role: models.VideoFileMaster}
+		}
+		var poster, sheet sql.NullString
+		// 只有音訊的影片沒有畫面，仍記錄產生時間以免每次排程重複查詢
+		if supportedVideoExtensions[strings.ToLower(path.Ext(source.Path))] {
+			poster, sheet = s.generate(ffmpegPath, tempDir, v, source.Path, cfg)
+			if !poster.Valid && !sheet.Valid {
+				failed++
+			} else {
+				generated++
+			}
+		}
+		if err := s.db.SaveVideoThumbnails(v.ID, poster, sheet); err != nil {
+			log.Printf("錯誤：[ThumbnailService] %v", err)
+		}
+	}
+	log.Printf("資訊：[ThumbnailService] 縮圖產生完成：%d 部影片成功，%d 部失敗。", generated, failed)
+	return nil
+}
+
+// generate 產生單部影片的海報與縮圖表並存放於 source 旁，回傳成功者的相對路徑
+func (s *ThumbnailService) generate(ffmpegPath, tempDir string, v models.Video, source string, cfg config.ThumbnailsConfig) (poster, sheet sql.NullString) {
+	input, err := mediaInput(s.nas, source, time.Hour)
+	if err != nil {
+		log.Printf("錯誤：[ThumbnailService] 影片 ID %d 的來源 '%s' 不可用: %v", v.ID, source, err)
+		return
+	}
+	var duration time.Duration
+	if v.DurationSecs.Valid {
+		duration = time.Duration(v.DurationSecs.Int64) * time.Second
+	}
+	opts := thumbnails.Options{PosterWidth: cfg.PosterWidth, Columns: cfg.Columns, Rows: cfg.Rows, TileWidth: cfg.TileWidth}
+	timeout := time.Duration(cfg.TimeoutSecs) * time.Second
+
+	steps := []struct {
+		name    string
+		dstPath string
+		out     *sql.NullString
+		run     func(ctx context.Context, ffmpegPath, input, dst string, duration time.Duration, opts thumbnails.Options) error
+	}{
+		{"海報", thumbnails.PosterPath(source), &poster, thumbnails.ExtractPoster},
+		{"關鍵影格縮圖表", thumbnails.ContactSheetPath(source), &sheet, thumbnails.ContactSheet},
+	}
+	for _, step := range steps {
+		local := filepath.Join(tempDir, fmt.Sprintf("%d-%s", v.ID, path.Base(step.dstPath)))
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := step.run(ctx, ffmpegPath, input, local, duration, opts)
+		cancel()
+		if err == nil {
+			err = storeLocalFile(s.nas, local, step.dstPath)
+		}
+		os.Remove(local)
+		if err != nil {
+			log.Printf("錯誤：[ThumbnailService] 影片 ID %d 的%s產生失敗: %v", v.ID, step.name, err)
+			continue
+		}
+		*step.out = sql.NullString{String: step.dstPath, Valid: true}
+	}
+	return
+}
+
+// mediaInput 回傳 ffmpeg/ffprobe 讀取影片的位置：物件儲存使用有效期間為 expiry 的預先簽署網址，本地 NAS 使用絕對路徑
+func mediaInput(nas NASStorage, relPath string, expiry time.Duration) (string, error) {
+	if signer, ok := nas.(mediaURLSigner); ok {
+		return signer.PresignURL(relPath, expiry)
+	}
+	return nas.GetVideoAbsolutePath(filepath.FromSlash(relPath))
+}
+
+// storeLocalFile 將 ffmpeg 產生的暫存檔寫入 NAS 的 dstPath
+func storeLocalFile(nas NASStorage, local, dstPath string) error {
+	f, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	_, err = nas.SaveFile(filepath.FromSlash(dstPath), f, st.Size())
+	return err
+}
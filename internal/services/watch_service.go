@@ -0,0 +1,302 @@
+package services
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/models"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchQueueSize 等待文本元數據分析的配對數量上限；佇列滿時保留於待確認清單，下次檢查再送交
+const watchQueueSize = 256
+
+// WatchService 以 fsnotify 監看 NAS.VideoPath (Download/<source>/<id>/)，在影片與 TXT 檔案都到齊、
+// 且檔案大小與修改時間維持 settle 時間不變後，立即送交文本元數據分析；並定期全量掃描，補足監看遺漏的事件
+// (例如超過 inotify 監看數量上限或服務重新啟動期間下載的檔案)。
+// 事件處理、穩定度檢查與全量掃描都在同一個 goroutine 中進行，分析則由另一個 goroutine 逐一執行
+type WatchService struct {
+	analyze *AnalyzeService
+	root    string
+	settle  time.Duration
+	rescan  time.Duration
+
+	pending  map[string]*pendingDir // key 為影片ID目錄的絕對路徑
+	enqueued map[string]string      // 已送交分析的目錄及當時的檔案簽章，避免重複送交
+	queue    chan models.VideoFileInfo
+}
+
+// pendingDir 有檔案變動、等待確認下載完成的影片ID目錄
+type pendingDir struct {
+	source      string
+	id          string
+	lastEvent   time.Time
+	signature   string
+	stableSince time.Time
+}
+
+// NewWatchService 建立 WatchService 實例
+func NewWatchService(cfg *config.Config, analyze *AnalyzeService) (*WatchService, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("WatchService：設定不得為空")
+	}
+	if analyze == nil {
+		return nil, fmt.Errorf("WatchService：AnalyzeService 不得為空")
+	}
+	root, err := filepath.Abs(cfg.NAS.VideoPath)
+	if err != nil {
+		return nil, fmt.Errorf("WatchService：無法取得 Download 路徑的絕對路徑 '%s': %w", cfg.NAS.VideoPath, err)
+	}
+	return &WatchService{
+		analyze:  analyze,
+		root:     root,
+		settle:   time.Duration(cfg.NAS.Watch.SettleSecs) * time.Second,
+		rescan:   time.Duration(cfg.NAS.Watch.RescanMinutes) * time.Minute,
+		pending:  make(map[string]*pendingDir),
+		enqueued: make(map[string]string),
+		queue:    make(chan models.VideoFileInfo, watchQueueSize),
+	}, nil
+}
+
+// Start 開始監看並啟動分析佇列，ctx 結束時停止
+func (w *WatchService) Start(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("建立檔案監看器失敗: %w", err)
+	}
+	if err := watcher.Add(w.root); err != nil {
+		watcher.Close()
+		return fmt.Errorf("無法監看 Download 目錄 '%s': %w", w.root, err)
+	}
+	w.watchTree(watcher)
+	go w.loop(ctx, watcher)
+	go w.worker(ctx)
+	log.Printf("資訊：[WatchService] 正在監看 %s (檔案穩定 %s 後送交分析，每 %s 全量掃描)。", w.root, w.settle, w.rescan)
+	return nil
+}
+
+func (w *WatchService) loop(ctx context.Context, watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+	checkEvery := w.settle / 3
+	if checkEvery < time.Second {
+		checkEvery = time.Second
+	}
+	check := time.NewTicker(checkEvery)
+	defer check.Stop()
+	rescan := time.NewTicker(w.rescan)
+	defer rescan.Stop()
+
+	w.rescanAll(watcher)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			w.handleEvent(watcher, event)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			// 例如事件佇列溢位 (fsnotify.ErrEventOverflow)；遺漏的檔案由定期全量掃描補上
+			log.Printf("警告：[WatchService] 檔案監看錯誤: %v", err)
+		case <-check.C:
+			w.checkPending()
+		case <-rescan.C:
+			w.rescanAll(watcher)
+		}
+	}
+}
+
+func (w *WatchService) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case info := <-w.queue:
+			if _, err := w.analyze.analyzeTextFile(info); err != nil {
+				log.Printf("警告：[WatchService] 影片 %s/%s 文本元數據分析失敗，將由排程重試: %v", info.SourceName, info.OriginalID, err)
+			}
+		}
+	}
+}
+
+// watchTree 監看所有來源目錄與影片ID目錄 (fsnotify 不會遞迴監看子目錄)；已監看的目錄重複加入不會有影響
+func (w *WatchService) watchTree(watcher *fsnotify.Watcher) {
+	sourceDirs, err := os.ReadDir(w.root)
+	if err != nil {
+		log.Printf("警告：[WatchService] 讀取 Download 目錄 '%s' 失敗: %v", w.root, err)
+		return
+	}
+	for _, sourceDir := range sourceDirs {
+		if !sourceDir.IsDir() || strings.HasPrefix(sourceDir.Name(), ".") {
+			continue
+		}
+		sourcePath := filepath.Join(w.root, sourceDir.Name())
+		if !w.watchDir(watcher, sourcePath) {
+			continue
+		}
+		idDirs, err := os.ReadDir(sourcePath)
+		if err != nil {
+			continue
+		}
+		for _, idDir := range idDirs {
+			if idDir.IsDir() && !strings.HasPrefix(idDir.Name(), ".") {
+				w.watchDir(watcher, filepath.Join(sourcePath, idDir.Name()))
+			}
+		}
+	}
+}
+
+func (w *WatchService) watchDir(watcher *fsnotify.Watcher, dir string) bool {
+	if err := watcher.Add(dir); err != nil {
+		log.Printf("警告：[WatchService] 無法監看 '%s' (將由定期全量掃描處理): %v", dir, err)
+		return false
+	}
+	return true
+}
+
+// handleEvent 依事件路徑的層級處理：新的來源目錄與影片ID目錄加入監看，影片ID目錄內的檔案變動則標記為待確認
+func (w *WatchService) handleEvent(watcher *fsnotify.Watcher, event fsnotify.Event) {
+	if event.Op == fsnotify.Chmod {
+		return
+	}
+	rel, err := filepath.Rel(w.root, event.Name)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	for _, part := range parts[:len(parts)-1] {
+		if strings.HasPrefix(part, ".") {
+			return
+		}
+	}
+	created := event.Has(fsnotify.Create) && isDir(event.Name)
+	switch len(parts) {
+	case 1: // Download/<source>
+		if !created || strings.HasPrefix(parts[0], ".") || !w.watchDir(watcher, event.Name) {
+			return
+		}
+		// 以 mkdir -p 建立的影片ID目錄可能在監看來源目錄前就已存在
+		idDirs, err := os.ReadDir(event.Name)
+		if err != nil {
+			return
+		}
+		for _, idDir := range idDirs {
+			if idDir.IsDir() && w.watchDir(watcher, filepath.Join(event.Name, idDir.Name())) {
+				w.markPending(parts[0], idDir.Name(), time.Now())
+			}
+		}
+	case 2: // Download/<source>/<id>
+		if created && !strings.HasPrefix(parts[1], ".") && w.watchDir(watcher, event.Name) {
+			w.markPending(parts[0], parts[1], time.Now())
+		}
+	case 3: // Download/<source>/<id>/<file>
+		w.markPending(parts[0], parts[1], time.Now())
+	}
+}
+
+func (w *WatchService) markPending(source, id string, at time.Time) {
+	dir := filepath.Join(w.root, source, id)
+	if p, ok := w.pending[dir]; ok {
+		if at.After(p.lastEvent) {
+			p.lastEvent = at
+		}
+		return
+	}
+	w.pending[dir] = &pendingDir{source: source, id: id, lastEvent: at}
+}
+
+// checkPending 檢查待確認的目錄：影片與 TXT 都存在，且檔案簽章 (大小與修改時間) 在 settle 時間內沒有變化時送交分析。
+// 一段時間沒有事件仍未到齊的目錄會移出清單，之後有新事件或全量掃描時再重新加入
+func (w *WatchService) checkPending() {
+	now := time.Now()
+	for dir, p := range w.pending {
+		info, err := w.analyze.scanVideoDir(w.root, p.source, p.id)
+		if err != nil {
+			delete(w.pending, dir) // 目錄已被移除或無法讀取
+			continue
+		}
+		signature := fileSignature(info)
+		if signature == "" {
+			if now.Sub(p.lastEvent) > 4*w.settle {
+				delete(w.pending, dir)
+			}
+			continue
+		}
+		if signature != p.signature {
+			p.signature, p.stableSince = signature, now
+			continue
+		}
+		if now.Sub(p.stableSince) < w.settle || now.Sub(p.lastEvent) < w.settle {
+			continue
+		}
+		if w.enqueued[dir] == signature {
+			delete(w.pending, dir)
+			continue
+		}
+		select {
+		case w.queue <- info:
+			w.enqueued[dir] = signature
+			delete(w.pending, dir)
+			log.Printf("資訊：[WatchService] 影片 %s/%s 下載完成，已送交文本元數據分析。", p.source, p.id)
+		default:
+			// 佇列已滿，留待下次檢查
+		}
+	}
+}
+
+// rescanAll 全量掃描 Download 目錄，補上監看遺漏的目錄與尚未送交分析的配對 (只有 TXT 的記錄仍由排程處理)
+func (w *WatchService) rescanAll(watcher *fsnotify.Watcher) {
+	w.watchTree(watcher)
+	infos, err := w.analyze.scanVideoFiles()
+	if err != nil {
+		log.Printf("警告：[WatchService] 全量掃描失敗: %v", err)
+		return
+	}
+	for _, info := range infos {
+		if info.VideoAbsolutePath == "" {
+			continue
+		}
+		if w.enqueued[filepath.Dir(info.VideoAbsolutePath)] == fileSignature(info) {
+			continue
+		}
+		w.markPending(info.SourceName, info.OriginalID, info.ModTime)
+	}
+}
+
+// fileSignature 回傳目錄中所有版本與 TXT 檔案的大小與修改時間；主要影片或 TXT 不存在、或主要影片為空檔時回傳空字串
+func fileSignature(info models.VideoFileInfo) string {
+	if info.VideoAbsolutePath == "" || info.TextFilePath == "" {
+		return ""
+	}
+	video, err := os.Stat(info.VideoAbsolutePath)
+	if err != nil || video.Size() == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	for _, r := range info.Renditions {
+		fmt.Fprintf(&sb, "%s:%d:%d|", r.Path, r.Size, r.ModTime.UnixNano())
+	}
+	for _, p := range info.TextFilePaths {
+		txt, err := os.Stat(p)
+		if err != nil {
+			return ""
+		}
+		fmt.Fprintf(&sb, "%s:%d:%d|", filepath.Base(p), txt.Size(), txt.ModTime().UnixNano())
+	}
+	return sb.String()
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/models"
+	"AiHackathon-admin/internal/renditions"
+	"AiHackathon-admin/internal/segmentation"
+	"AiHackathon-admin/internal/web/handlers"
+	"AiHackathon-admin/internal/webproxy"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// webProxyPageSize 每次查詢待檢查影片的筆數；不需轉檔的影片只會被標記，不計入 batchSize
+const webProxyPageSize = 50
+
+// WebProxyService 以 ffmpeg 為瀏覽器無法播放的影片 (.ts、.mkv 等) 產生 H.264/AAC MP4 播放版本，
+// 存放於原始檔旁的 .web/ 目錄並記錄於 videos
+type WebProxyService struct {
+	db         handlers.DBStore
+	nas        NASStorage
+	ffmpegPath string // segmentation.ffmpegPath；空字串時由 PATH 尋找
+	tempDir    string // segmentation.tempDir；空字串時使用系統暫存目錄
+	rules      renditions.Rules
+
+	mu      sync.RWMutex
+	cfg     config.WebProxyConfig // 可於設定重新載入時更新
+	running sync.Mutex
+}
+
+// NewWebProxyService 建立 WebProxyService 實例
+func NewWebProxyService(cfg *config.Config, db handlers.DBStore, nas NASStorage) (*WebProxyService, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("WebProxyService：設定不得為空")
+	}
+	if db == nil {
+		return nil, fmt.Errorf("WebProxyService：DBStore 不得為空")
+	}
+	if nas == nil {
+		return nil, fmt.Errorf("WebProxyService：NASStorage 不得為空")
+	}
+	if _, ok := segmentation.FindFFmpeg(cfg.Segmentation.FFmpegPath); !ok && cfg.Scheduler.WebProxyCronSpec != "" {
+		log.Println("警告：[WebProxyService] 找不到 ffmpeg，安裝前不會產生播放版本，.ts/.mkv 等格式僅能下載原始檔。")
+	}
+	log.Println("資訊：WebProxyService 初始化完成。")
+	return &WebProxyService{
+		db:         db,
+		nas:        nas,
+		ffmpegPath: cfg.Segmentation.FFmpegPath,
+		tempDir:    cfg.Segmentation.TempDir,
+		rules:      renditions.RulesFromConfig(cfg.NAS.Renditions),
+		cfg:        cfg.WebProxy,
+	}, nil
+}
+
+// UpdateConfig 於設定重新載入後更新轉檔參數與批次大小
+func (s *WebProxyService) UpdateConfig(c config.WebProxyConfig) {
+	s.mu.Lock()
+	s.cfg = c
+	s.mu.Unlock()
+}
+
+func (s *WebProxyService) config() config.WebProxyConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
+// Run 檢查尚未處理的影片：播放版本不是 webProxy.extensions 的格式時只記錄檢查時間，
+// 否則轉檔，每次最多轉檔 batchSize 部。找不到 ffmpeg 時略過 (不視為錯誤)
+func (s *WebProxyService) Run() error {
+	if !s.running.TryLock() {
+		return fmt.Errorf("播放版本產生任務仍在執行")
+	}
+	defer s.running.Unlock()
+
+	ffmpegPath, ok := segmentation.FindFFmpeg(s.ffmpegPath)
+	if !ok {
+		log.Println("資訊：[WebProxyService] 找不到 ffmpeg，略過播放版本產生。")
+		return nil
+	}
+	cfg := s.config()
+	tempDir, err := os.MkdirTemp(s.tempDir, "webproxy-*")
+	if err != nil {
+		return fmt.Errorf("無法建立轉檔暫存目錄: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	var transcoded, failed, skipped int
+	for transcoded+failed < cfg.BatchSize {
+		videos, err := s.db.GetVideosPendingWebProxy(webProxyPageSize)
+		if err != nil {
+			return err
+		}
+		if len(videos) == 0 {
+			break
+		}
+		ids := make([]int64, len(videos))
+		for i, v := range videos {
+			ids[i] = v.ID
+		}
+		files, err := s.db.GetVideoFiles(ids)
+		if err != nil {
+			return err
+		}
+		marked := 0
+		for _, v := range videos {
+			if transcoded+failed >= cfg.BatchSize {
+				break
+			}
+			source, ok := renditions.ForStream(files[v.ID], s.rules)
+			if !ok {
+				// 尚未建立版本清單的影片以 nas_path 為來源
+				source = models.VideoFile{VideoID: v.ID, Path: filepath.ToSlash(v.NASPath), Role: models.VideoFileMaster}
+			}
+			var sourcePath, proxyPath sql.NullString
+			if webproxy.Needs(source.Path, cfg.Extensions) {
+				sourcePath = sql.NullString{String: source.Path, Valid: true}
+				if err := s.transcode(ffmpegPath, tempDir, v.ID, source.Path, cfg); err != nil {
+					log.Printf("錯誤：[WebProxyService] 影片 ID %d 的播放版本產生失敗: %v", v.ID, err)
+					failed++
+				} else {
+					proxyPath = sql.NullString{String: webproxy.Path(source.Path), Valid: true}
+					transcoded++
+				}
+			} else {
+				skipped++
+			}
+			if err := s.db.SaveVideoWebProxy(v.ID, sourcePath, proxyPath); err != nil {
+				log.Printf("錯誤：[WebProxyService] %v", err)
+				continue
+			}
+			marked++
+		}
+		if marked == 0 {
+			break // 無法記錄時避免重複查詢同一批影片
+		}
+	}
+	if transcoded+failed+skipped > 0 {
+		log.Printf("資訊：[WebProxyService] 播放版本產生完成：%d 部轉檔成功，%d 部失敗，%d 部不需轉檔。", transcoded, failed, skipped)
+	}
+	return nil
+}
+
+// transcode 將 source 轉為 H.264/AAC MP4 並存放於 webproxy.Path(source)
+func (s *WebProxyService) transcode(ffmpegPath, tempDir string, videoID int64, source string, cfg config.WebProxyConfig) error {
+	timeout := time.Duration(cfg.TimeoutMins) * time.Minute
+	input, err := mediaInput(s.nas, source, timeout+time.Minute)
+	if err != nil {
+		return fmt.Errorf("來源 '%s' 不可用: %w", source, err)
+	}
+	local := filepath.Join(tempDir, fmt.Sprintf("%d.mp4", videoID))
+	defer os.Remove(local)
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	err = webproxy.Transcode(ctx, ffmpegPath, input, local, webproxy.Options{
+		MaxHeight: cfg.MaxHeight, CRF: cfg.CRF, Preset: cfg.Preset, AudioBitrateKbps: cfg.AudioBitrateKbps,
+	})
+	cancel()
+	if err != nil {
+		return err
+	}
+	if err := storeLocalFile(s.nas, local, webproxy.Path(source)); err != nil {
+		return fmt.Errorf("無法儲存播放版本: %w", err)
+	}
+	log.Printf("資訊：[WebProxyService] 影片 ID %d 的播放版本已產生：%s (耗時 %s)", videoID, webproxy.Path(source), time.Since(start).Round(time.Second))
+	return nil
+}
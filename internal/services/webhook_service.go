@@ -0,0 +1,428 @@
+package services
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/feeds"
+	"AiHackathon-admin/internal/models"
+	"AiHackathon-admin/internal/web/handlers"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebhookEventAnalysisCompleted 影片分析完成事件
+const WebhookEventAnalysisCompleted = "analysis.completed"
+
+// AnalysisCompletionNotifier 在影片完成內容分析後接收通知
+type AnalysisCompletionNotifier interface {
+	NotifyAnalysisCompleted(video models.Video, result *models.AnalysisResult)
+}
+
+// WebhookService 負責依訂閱設定將分析完成事件以 HMAC 簽章的 JSON 推送到外部系統，
+// 每次傳送都記錄於 webhook_deliveries，失敗時以指數退避重試。
+// 背景推送在 Close 後停止，尚未完成的紀錄維持 pending，於下次啟動時由 ResumePending 接續
+type WebhookService struct {
+	cfg        config.WebhooksConfig
+	baseURL    string
+	db         handlers.DBStore
+	httpClient *http.Client
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	inflight sync.WaitGroup
+}
+
+// NewWebhookService 建立 WebhookService 實例
+func NewWebhookService(cfg *config.Config, db handlers.DBStore) (*WebhookService, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("WebhookService：設定不得為空")
+	}
+	if db == nil {
+		return nil, fmt.Errorf("WebhookService：DBStore 不得為空")
+	}
+	whCfg := cfg.Webhooks
+	if whCfg.MaxAttempts <= 0 {
+		whCfg.MaxAttempts = 5
+	}
+	if whCfg.InitialBackoffSecs <= 0 {
+		whCfg.InitialBackoffSecs = 30
+	}
+	if whCfg.TimeoutSecs <= 0 {
+		whCfg.TimeoutSecs = 10
+	}
+	enabled := 0
+	for _, sub := range whCfg.Subscriptions {
+		if sub.Enabled && sub.URL != "" {
+			enabled++
+		}
+	}
+	log.Printf("資訊：WebhookService 初始化完成，共 %d 個啟用中的訂閱。", enabled)
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WebhookService{
+		cfg:        whCfg,
+		baseURL:    strings.TrimRight(cfg.Export.PublicBaseURL, "/"),
+		db:         db,
+		httpClient: &http.Client{Timeout: time.Duration(whCfg.TimeoutSecs) * time.Second},
+		ctx:        ctx,
+		cancel:     cancel,
+	}, nil
+}
+
+// Close 停止所有背景推送 (包含等待重試中的紀錄) 並等待進行中的請求結束，最多等待至 ctx 逾時
+func (s *WebhookService) Close(ctx context.Context) error {
+	s.cancel()
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("等待 webhook 推送結束逾時: %w", ctx.Err())
+	}
+}
+
+// startDelivery 於背景推送；服務關閉後不再啟動新的推送
+func (s *WebhookService) startDelivery(sub config.WebhookSubscriptionConfig, delivery *models.WebhookDelivery) {
+	if s.ctx.Err() != nil {
+		return
+	}
+	s.inflight.Add(1)
+	go func() {
+		defer s.inflight.Done()
+		s.deliverWithRetry(s.ctx, sub, delivery)
+	}()
+}
+
+// webhookPayload 為推送給訂閱者的 JSON 內容
+type webhookPayload struct {
+	Event        string          `json:"event"`
+	OccurredAt   time.Time       `json:"occurred_at"`
+	Video        webhookVideo    `json:"video"`
+	Analysis     webhookAnalysis `json:"analysis"`
+	DashboardURL string          `json:"dashboard_url"`
+}
+
+type webhookVideo struct {
+	ID           int64           `json:"id"`
+	SourceName   string          `json:"source_name"`
+	SourceID     string          `json:"source_id"`
+	Title        string          `json:"title,omitempty"`
+	PublishedAt  *time.Time      `json:"published_at,omitempty"`
+	DurationSecs int64           `json:"duration_secs,omitempty"`
+	Location     string          `json:"location,omitempty"`
+	Subjects     json.RawMessage `json:"subjects,omitempty"`
+	Restrictions string          `json:"restrictions,omitempty"`
+	NASPath      string          `json:"nas_path"`
+}
+
+type webhookAnalysis struct {
+	Rating        string          `json:"rating,omitempty"`
+	KeyFactors    []string        `json:"key_factors,omitempty"`
+	ShortSummary  string          `json:"short_summary,omitempty"`
+	Topics        json.RawMessage `json:"topics,omitempty"`
+	Keywords      json.RawMessage `json:"keywords,omitempty"`
+	Bites         json.RawMessage `json:"bites,omitempty"`
+	PromptVersion string          `json:"prompt_version,omitempty"`
+	PromptHash    string          `json:"prompt_hash,omitempty"`
+	ModelName     string          `json:"model_name,omitempty"`
+	Outcome       string          `json:"outcome,omitempty"`  // safety 等表示原始分析被阻擋，需人工審閱
+	Fallback      string          `json:"fallback,omitempty"` // relaxed_safety 或 text_only
+}
+
+// subscriptionMatches 判斷影片與分析結果是否符合訂閱的篩選條件；空的條件代表不篩選
+func subscriptionMatches(sub config.WebhookSubscriptionConfig, video models.Video, rating string, topics []string) bool {
+	if len(sub.Ratings) > 0 && !containsFold(sub.Ratings, rating) {
+		return false
+	}
+	if len(sub.Sources) > 0 && !containsFold(sub.Sources, video.SourceName) {
+		return false
+	}
+	if len(sub.Topics) > 0 {
+		for _, t := range topics {
+			if containsFold(sub.Topics, t) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func containsFold(list []string, value string) bool {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return false
+	}
+	for _, item := range list {
+		if strings.EqualFold(strings.TrimSpace(item), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildPayload 組成分析完成事件的 JSON 內容，並回傳評級與主題供篩選使用
+func (s *WebhookService) buildPayload(video models.Video, result *models.AnalysisResult) ([]byte, string, []string, error) {
+	var importance models.ImportanceScore
+	if len(result.ImportanceScore) > 0 {
+		_ = json.Unmarshal(result.ImportanceScore, &importance)
+	}
+	rating := strings.ToUpper(strings.TrimSpace(importance.OverallRating))
+
+	// 主題同時比對分析結果的 topics 與影片本身的 subjects
+	var topics, subjects []string
+	if len(result.Topics) > 0 {
+		_ = json.Unmarshal(result.Topics, &topics)
+	}
+	if len(video.Subjects) > 0 && json.Unmarshal(video.Subjects, &subjects) == nil {
+		topics = append(topics, subjects...)
+	}
+
+	payload := webhookPayload{
+		Event:      WebhookEventAnalysisCompleted,
+		OccurredAt: time.Now().UTC(),
+		Video: webhookVideo{
+			ID:           video.ID,
+			SourceName:   video.SourceName,
+			SourceID:     video.SourceID,
+			Title:        video.Title.String,
+			DurationSecs: video.DurationSecs.Int64,
+			Location:     video.Location.String,
+			Subjects:     video.Subjects,
+			Restrictions: video.Restrictions.String,
+			NASPath:      video.NASPath,
+		},
+		Analysis: webhookAnalysis{
+			Rating:        rating,
+			KeyFactors:    importance.KeyFactors,
+			Topics:        result.Topics,
+			Keywords:      result.Keywords,
+			Bites:         result.Bites,
+			PromptVersion: result.PromptVersion,
+			PromptHash:    result.PromptHash,
+			ModelName:     result.ModelName,
+			Outcome:       result.Outcome,
+			Fallback:      result.Fallback,
+		},
+		DashboardURL: feeds.DashboardEntryURL(s.baseURL, video),
+	}
+	if video.PublishedAt.Valid {
+		t := video.PublishedAt.Time.UTC()
+		payload.Video.PublishedAt = &t
+	}
+	if result.ShortSummary != nil && result.ShortSummary.Valid {
+		payload.Analysis.ShortSummary = result.ShortSummary.String
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("序列化 webhook 內容失敗 (VideoID: %d): %w", video.ID, err)
+	}
+	return body, rating, topics, nil
+}
+
+// NotifyAnalysisCompleted 為每個符合條件的訂閱建立傳送紀錄並於背景推送
+func (s *WebhookService) NotifyAnalysisCompleted(video models.Video, result *models.AnalysisResult) {
+	if result == nil || len(s.cfg.Subscriptions) == 0 {
+		return
+	}
+	body, rating, topics, err := s.buildPayload(video, result)
+	if err != nil {
+		log.Printf("錯誤：[WebhookService] %v", err)
+		return
+	}
+	for _, sub := range s.cfg.Subscriptions {
+		if !sub.Enabled || sub.URL == "" || !subscriptionMatches(sub, video, rating, topics) {
+			continue
+		}
+		delivery := &models.WebhookDelivery{
+			SubscriptionName: sub.Name,
+			VideoID:          video.ID,
+			Event:            WebhookEventAnalysisCompleted,
+			Payload:          body,
+			Status:           models.WebhookDeliveryPending,
+			NextAttemptAt:    sql.NullTime{Time: time.Now(), Valid: true},
+		}
+		id, err := s.db.CreateWebhookDelivery(delivery)
+		if err != nil {
+			log.Printf("錯誤：[WebhookService] %v", err)
+			continue
+		}
+		delivery.ID = id
+		s.startDelivery(sub, delivery)
+	}
+}
+
+// ReplayDelivery 重新推送一筆既有的傳送紀錄 (例如儀表板上的失敗項目)，嘗試次數重新計算。
+// 仍為 pending 的紀錄 (原本的推送仍在等待或重試中) 回傳 handlers.ErrWebhookDeliveryInProgress，避免同一筆紀錄同時推送
+func (s *WebhookService) ReplayDelivery(id int64) error {
+	delivery, err := s.db.GetWebhookDeliveryByID(id)
+	if err != nil {
+		return err
+	}
+	if delivery == nil {
+		return fmt.Errorf("找不到 webhook 傳送紀錄 %d", id)
+	}
+	sub, ok := s.subscription(delivery.SubscriptionName)
+	if !ok {
+		return fmt.Errorf("webhook 訂閱 '%s' 不存在或未啟用", delivery.SubscriptionName)
+	}
+	if delivery.Status == models.WebhookDeliveryPending {
+		return fmt.Errorf("傳送紀錄 %d: %w", id, handlers.ErrWebhookDeliveryInProgress)
+	}
+	now := time.Now()
+	claimed, err := s.db.ClaimWebhookDeliveryReplay(delivery.ID, now)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		// 查詢後狀態已被其他請求改為 pending (例如同時按下兩次重新推送)
+		return fmt.Errorf("傳送紀錄 %d: %w", id, handlers.ErrWebhookDeliveryInProgress)
+	}
+	delivery.Attempts = 0
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.NextAttemptAt = sql.NullTime{Time: now, Valid: true}
+	log.Printf("資訊：[WebhookService] 重新推送傳送紀錄 %d (訂閱: %s, VideoID: %d)", delivery.ID, sub.Name, delivery.VideoID)
+	s.startDelivery(sub, delivery)
+	return nil
+}
+
+// ResumePending 於啟動時接續處理上次關閉前仍在等待重試的傳送紀錄
+func (s *WebhookService) ResumePending() {
+	pending, err := s.db.GetWebhookDeliveriesByStatus(models.WebhookDeliveryPending, 500)
+	if err != nil {
+		log.Printf("錯誤：[WebhookService] 查詢待傳送紀錄失敗: %v", err)
+		return
+	}
+	for i := range pending {
+		delivery := &pending[i]
+		sub, ok := s.subscription(delivery.SubscriptionName)
+		if !ok {
+			s.recordAttempt(delivery, models.WebhookDeliveryFailed, sql.NullInt64{}, sql.NullString{String: "訂閱不存在或未啟用", Valid: true}, sql.NullTime{})
+			continue
+		}
+		s.startDelivery(sub, delivery)
+	}
+	if len(pending) > 0 {
+		log.Printf("資訊：[WebhookService] 接續處理 %d 筆待傳送的 webhook。", len(pending))
+	}
+}
+
+func (s *WebhookService) subscription(name string) (config.WebhookSubscriptionConfig, bool) {
+	for _, sub := range s.cfg.Subscriptions {
+		if sub.Name == name && sub.Enabled && sub.URL != "" {
+			return sub, true
+		}
+	}
+	return config.WebhookSubscriptionConfig{}, false
+}
+
+// deliverWithRetry 推送直到成功或達到最大嘗試次數，每次失敗後等待時間加倍。
+// ctx 取消時立即停止，紀錄維持 pending 與下次嘗試時間
+func (s *WebhookService) deliverWithRetry(ctx context.Context, sub config.WebhookSubscriptionConfig, delivery *models.WebhookDelivery) {
+	if delivery.NextAttemptAt.Valid && !sleepContext(ctx, time.Until(delivery.NextAttemptAt.Time)) {
+		return
+	}
+	backoff := time.Duration(s.cfg.InitialBackoffSecs) * time.Second
+	for i := 1; i < delivery.Attempts; i++ {
+		backoff *= 2
+	}
+	for delivery.Attempts < s.cfg.MaxAttempts {
+		statusCode, err := s.send(ctx, sub, delivery)
+		if ctx.Err() != nil {
+			return // 關閉時中斷的請求不計入嘗試次數
+		}
+		delivery.Attempts++
+		code := sql.NullInt64{Int64: int64(statusCode), Valid: statusCode > 0}
+		if err == nil {
+			s.recordAttempt(delivery, models.WebhookDeliverySucceeded, code, sql.NullString{}, sql.NullTime{})
+			log.Printf("資訊：[WebhookService] 傳送紀錄 %d 推送至 '%s' 成功 (HTTP %d，第 %d 次嘗試)", delivery.ID, sub.Name, statusCode, delivery.Attempts)
+			return
+		}
+		lastErr := sql.NullString{String: firstNChars(err.Error(), 1000), Valid: true}
+		if delivery.Attempts >= s.cfg.MaxAttempts {
+			s.recordAttempt(delivery, models.WebhookDeliveryFailed, code, lastErr, sql.NullTime{})
+			log.Printf("錯誤：[WebhookService] 傳送紀錄 %d 推送至 '%s' 失敗，已達最大嘗試次數 %d: %v", delivery.ID, sub.Name, s.cfg.MaxAttempts, err)
+			return
+		}
+		next := time.Now().Add(backoff)
+		s.recordAttempt(delivery, models.WebhookDeliveryPending, code, lastErr, sql.NullTime{Time: next, Valid: true})
+		log.Printf("警告：[WebhookService] 傳送紀錄 %d 推送至 '%s' 失敗 (第 %d 次)，%s 後重試: %v", delivery.ID, sub.Name, delivery.Attempts, backoff, err)
+		if !sleepContext(ctx, backoff) {
+			return
+		}
+		backoff *= 2
+	}
+}
+
+// sleepContext 等待 d；ctx 先被取消時回傳 false
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+func (s *WebhookService) recordAttempt(delivery *models.WebhookDelivery, status models.WebhookDeliveryStatus, code sql.NullInt64, lastErr sql.NullString, next sql.NullTime) {
+	delivery.Status, delivery.LastStatusCode, delivery.LastError, delivery.NextAttemptAt = status, code, lastErr, next
+	if err := s.db.UpdateWebhookDeliveryAttempt(delivery.ID, status, delivery.Attempts, code, lastErr, next); err != nil {
+		log.Printf("錯誤：[WebhookService] %v", err)
+	}
+}
+
+// SignPayload 以 HMAC-SHA256 對 "{timestamp}.{body}" 簽章，回傳十六進位字串
+func SignPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// send 執行單次 HTTP POST，2xx 視為成功
+func (s *WebhookService) send(ctx context.Context, sub config.WebhookSubscriptionConfig, delivery *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("建立請求失敗: %w", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "AiHackathon-admin-webhook/1.0")
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	if sub.Secret != "" {
+		req.Header.Set("X-Webhook-Signature", "sha256="+SignPayload(sub.Secret, timestamp, delivery.Payload))
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return resp.StatusCode, nil
+}
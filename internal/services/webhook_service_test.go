@@ -0,0 +1,305 @@
+package services
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/models"
+	"AiHackathon-admin/internal/web/handlers"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeWebhookDB 以記憶體保存 webhook_deliveries；未實作的 DBStore 方法被呼叫時會 panic
+type fakeWebhookDB struct {
+	handlers.DBStore
+
+	mu         sync.Mutex
+	nextID     int64
+	deliveries map[int64]models.WebhookDelivery
+}
+
+func newFakeWebhookDB() *fakeWebhookDB {
+	return &fakeWebhookDB{deliveries: make(map[int64]models.WebhookDelivery)}
+}
+
+func (db *fakeWebhookDB) CreateWebhookDelivery(d *models.WebhookDelivery) (int64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.nextID++
+	row := *d
+	row.ID = db.nextID
+	db.deliveries[row.ID] = row
+	return row.ID, nil
+}
+
+func (db *fakeWebhookDB) UpdateWebhookDeliveryAttempt(id int64, status models.WebhookDeliveryStatus, attempts int, code sql.NullInt64, lastErr sql.NullString, next sql.NullTime) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	row := db.deliveries[id]
+	row.Status, row.Attempts, row.LastStatusCode, row.LastError, row.NextAttemptAt = status, attempts, code, lastErr, next
+	db.deliveries[id] = row
+	return nil
+}
+
+func (db *fakeWebhookDB) ClaimWebhookDeliveryReplay(id int64, next time.Time) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	row, ok := db.deliveries[id]
+	if !ok || row.Status == models.WebhookDeliveryPending {
+		return false, nil
+	}
+	row.Status, row.Attempts, row.NextAttemptAt = models.WebhookDeliveryPending, 0, sql.NullTime{Time: next, Valid: true}
+	db.deliveries[id] = row
+	return true, nil
+}
+
+func (db *fakeWebhookDB) GetWebhookDeliveryByID(id int64) (*models.WebhookDelivery, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	row, ok := db.deliveries[id]
+	if !ok {
+		return nil, nil
+	}
+	return &row, nil
+}
+
+func (db *fakeWebhookDB) get(id int64) models.WebhookDelivery {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.deliveries[id]
+}
+
+// waitFor 等待傳送紀錄符合 cond，逾時則測試失敗
+func (db *fakeWebhookDB) waitFor(t *testing.T, id int64, cond func(models.WebhookDelivery) bool) models.WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		row := db.get(id)
+		if cond(row) {
+			return row
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("等待傳送紀錄 %d 逾時，目前狀態: %+v", id, row)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// receivedRequest 為測試接收端收到的請求
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// newReceiver 建立 httptest 接收端；statuses 依序為每次請求回應的狀態碼，用完後回應最後一個
+func newReceiver(t *testing.T, statuses ...int) (*httptest.Server, <-chan receivedRequest) {
+	t.Helper()
+	received := make(chan receivedRequest, 16)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- receivedRequest{header: r.Header.Clone(), body: body}
+		i := int(calls.Add(1)) - 1
+		if i >= len(statuses) {
+			i = len(statuses) - 1
+		}
+		w.WriteHeader(statuses[i])
+	}))
+	t.Cleanup(srv.Close)
+	return srv, received
+}
+
+func newTestWebhookService(t *testing.T, db *fakeWebhookDB, whCfg config.WebhooksConfig) *WebhookService {
+	t.Helper()
+	svc, err := NewWebhookService(&config.Config{Webhooks: whCfg}, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		svc.Close(ctx)
+	})
+	return svc
+}
+
+func sampleWebhookVideo() (models.Video, *models.AnalysisResult) {
+	video := models.Video{
+		ID: 7, SourceName: "ap", SourceID: "123", NASPath: "ap/123/clip.mp4",
+		Title:    sql.NullString{String: "颱風登陸", Valid: true},
+		Subjects: json.RawMessage(`["Weather"]`),
+	}
+	result := &models.AnalysisResult{
+		VideoID:         7,
+		ShortSummary:    &models.JsonNullString{NullString: sql.NullString{String: "摘要", Valid: true}},
+		ImportanceScore: json.RawMessage(`{"overall_rating":"a","key_factors":["災害"]}`),
+		Topics:          json.RawMessage(`["天氣"]`),
+		ModelName:       "gemini-test",
+	}
+	return video, result
+}
+
+func TestNotifyAnalysisCompletedDeliversSignedPayload(t *testing.T) {
+	srv, received := newReceiver(t, http.StatusOK)
+	db := newFakeWebhookDB()
+	svc := newTestWebhookService(t, db, config.WebhooksConfig{
+		MaxAttempts: 3,
+		Subscriptions: []config.WebhookSubscriptionConfig{
+			{Name: "desk", URL: srv.URL, Secret: "s3cret", Enabled: true, Ratings: []string{"S", "A"}, Topics: []string{"weather"}},
+			{Name: "sports", URL: srv.URL, Enabled: true, Topics: []string{"體育"}},
+			{Name: "disabled", URL: srv.URL, Enabled: false},
+		},
+	})
+
+	video, result := sampleWebhookVideo()
+	svc.NotifyAnalysisCompleted(video, result)
+
+	row := db.waitFor(t, 1, func(d models.WebhookDelivery) bool { return d.Status == models.WebhookDeliverySucceeded })
+	if row.SubscriptionName != "desk" || row.Attempts != 1 || row.LastStatusCode.Int64 != http.StatusOK {
+		t.Errorf("傳送紀錄 = %+v", row)
+	}
+	db.mu.Lock()
+	created := len(db.deliveries)
+	db.mu.Unlock()
+	if created != 1 {
+		t.Errorf("建立了 %d 筆傳送紀錄，只有 desk 訂閱符合條件", created)
+	}
+
+	req := <-received
+	ts := req.header.Get("X-Webhook-Timestamp")
+	if got, want := req.header.Get("X-Webhook-Signature"), "sha256="+SignPayload("s3cret", ts, req.body); got != want {
+		t.Errorf("簽章 = %q, want %q", got, want)
+	}
+	if req.header.Get("X-Webhook-Event") != WebhookEventAnalysisCompleted || req.header.Get("X-Webhook-Delivery") != "1" {
+		t.Errorf("標頭不正確: %v", req.header)
+	}
+	var payload webhookPayload
+	if err := json.Unmarshal(req.body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Video.ID != 7 || payload.Analysis.Rating != "A" || payload.Analysis.ShortSummary != "摘要" || payload.Analysis.ModelName != "gemini-test" {
+		t.Errorf("payload = %+v", payload)
+	}
+}
+
+func TestDeliverWithRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		maxAttempts  int
+		wantStatus   models.WebhookDeliveryStatus
+		wantAttempts int
+		wantCode     int64
+	}{
+		{name: "失敗後重試成功", statuses: []int{http.StatusInternalServerError, http.StatusNoContent}, maxAttempts: 3, wantStatus: models.WebhookDeliverySucceeded, wantAttempts: 2, wantCode: http.StatusNoContent},
+		{name: "達最大嘗試次數", statuses: []int{http.StatusBadGateway}, maxAttempts: 1, wantStatus: models.WebhookDeliveryFailed, wantAttempts: 1, wantCode: http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newReceiver(t, tt.statuses...)
+			db := newFakeWebhookDB()
+			svc := newTestWebhookService(t, db, config.WebhooksConfig{
+				MaxAttempts: tt.maxAttempts, InitialBackoffSecs: 1,
+				Subscriptions: []config.WebhookSubscriptionConfig{{Name: "desk", URL: srv.URL, Enabled: true}},
+			})
+			video, result := sampleWebhookVideo()
+			svc.NotifyAnalysisCompleted(video, result)
+
+			row := db.waitFor(t, 1, func(d models.WebhookDelivery) bool { return d.Status != models.WebhookDeliveryPending })
+			if row.Status != tt.wantStatus || row.Attempts != tt.wantAttempts || row.LastStatusCode.Int64 != tt.wantCode {
+				t.Errorf("傳送紀錄 = status %s, attempts %d, code %d; want %s, %d, %d",
+					row.Status, row.Attempts, row.LastStatusCode.Int64, tt.wantStatus, tt.wantAttempts, tt.wantCode)
+			}
+			if tt.wantStatus == models.WebhookDeliveryFailed && !row.LastError.Valid {
+				t.Error("失敗的紀錄應保留最後錯誤")
+			}
+		})
+	}
+}
+
+func TestReplayDelivery(t *testing.T) {
+	srv, received := newReceiver(t, http.StatusOK)
+	db := newFakeWebhookDB()
+	svc := newTestWebhookService(t, db, config.WebhooksConfig{
+		MaxAttempts:   3,
+		Subscriptions: []config.WebhookSubscriptionConfig{{Name: "desk", URL: srv.URL, Enabled: true}},
+	})
+	failedID, _ := db.CreateWebhookDelivery(&models.WebhookDelivery{SubscriptionName: "desk", VideoID: 7, Event: WebhookEventAnalysisCompleted, Payload: []byte(`{}`), Status: models.WebhookDeliveryFailed, Attempts: 3})
+	pendingID, _ := db.CreateWebhookDelivery(&models.WebhookDelivery{SubscriptionName: "desk", VideoID: 7, Event: WebhookEventAnalysisCompleted, Payload: []byte(`{}`), Status: models.WebhookDeliveryPending, Attempts: 1})
+	orphanID, _ := db.CreateWebhookDelivery(&models.WebhookDelivery{SubscriptionName: "removed", VideoID: 7, Status: models.WebhookDeliveryFailed})
+
+	if err := svc.ReplayDelivery(pendingID); !errors.Is(err, handlers.ErrWebhookDeliveryInProgress) {
+		t.Errorf("重新推送 pending 紀錄應回傳 ErrWebhookDeliveryInProgress，got %v", err)
+	}
+	if err := svc.ReplayDelivery(orphanID); err == nil {
+		t.Error("訂閱不存在時應回傳錯誤")
+	}
+	if err := svc.ReplayDelivery(999); err == nil {
+		t.Error("紀錄不存在時應回傳錯誤")
+	}
+
+	if err := svc.ReplayDelivery(failedID); err != nil {
+		t.Fatalf("ReplayDelivery: %v", err)
+	}
+	// 推送完成前再次重新推送同一筆紀錄會被拒絕 (紀錄已被領取為 pending)
+	if row := db.get(failedID); row.Status == models.WebhookDeliveryPending {
+		if err := svc.ReplayDelivery(failedID); !errors.Is(err, handlers.ErrWebhookDeliveryInProgress) {
+			t.Errorf("重複重新推送應回傳 ErrWebhookDeliveryInProgress，got %v", err)
+		}
+	}
+	row := db.waitFor(t, failedID, func(d models.WebhookDelivery) bool { return d.Status == models.WebhookDeliverySucceeded })
+	if row.Attempts != 1 {
+		t.Errorf("重新推送後嘗試次數 = %d, want 1", row.Attempts)
+	}
+	if req := <-received; req.header.Get("X-Webhook-Delivery") != "1" {
+		t.Errorf("X-Webhook-Delivery = %q", req.header.Get("X-Webhook-Delivery"))
+	}
+	select {
+	case <-received:
+		t.Error("同一筆紀錄被推送了兩次")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestCloseStopsPendingRetries(t *testing.T) {
+	srv, received := newReceiver(t, http.StatusServiceUnavailable)
+	db := newFakeWebhookDB()
+	svc := newTestWebhookService(t, db, config.WebhooksConfig{
+		MaxAttempts: 5, InitialBackoffSecs: 3600,
+		Subscriptions: []config.WebhookSubscriptionConfig{{Name: "desk", URL: srv.URL, Enabled: true}},
+	})
+	video, result := sampleWebhookVideo()
+	svc.NotifyAnalysisCompleted(video, result)
+	<-received
+	db.waitFor(t, 1, func(d models.WebhookDelivery) bool { return d.Attempts == 1 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := svc.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Close 花費 %s，應立即中斷等待中的重試", elapsed)
+	}
+	row := db.get(1)
+	if row.Status != models.WebhookDeliveryPending || row.Attempts != 1 || !row.NextAttemptAt.Valid {
+		t.Errorf("關閉後紀錄應維持 pending 供下次啟動接續: %+v", row)
+	}
+
+	// 關閉後不再啟動新的推送
+	svc.NotifyAnalysisCompleted(video, result)
+	select {
+	case <-received:
+		t.Error("關閉後仍送出請求")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
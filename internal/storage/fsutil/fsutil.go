@@ -0,0 +1,60 @@
+// Package fsutil 提供本地儲存 (NAS 與物件儲存快取) 共用的檔案寫入工具
+package fsutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrInsufficientSpace 磁碟剩餘空間不足以寫入檔案
+var ErrInsufficientSpace = errors.New("磁碟剩餘空間不足")
+
+// EnsureFreeSpace 確認 dir 所在磁碟在寫入 need bytes 後仍保留 reserve bytes；need 未知 (負數) 時只檢查 reserve。
+// 不支援查詢剩餘空間的平台略過檢查
+func EnsureFreeSpace(dir string, need, reserve int64) error {
+	free, err := freeBytes(dir)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("無法取得 '%s' 的磁碟剩餘空間: %w", dir, err)
+	}
+	required := uint64(max(need, 0) + max(reserve, 0))
+	if free < required {
+		return fmt.Errorf("%w：'%s' 剩餘 %d MB，需要 %d MB", ErrInsufficientSpace, dir, free>>20, required>>20)
+	}
+	return nil
+}
+
+// WriteAtomic 將 r 寫入 targetPath 同目錄下的暫存檔，完成後 fsync 並 rename 為 targetPath，過程中計算 sha256。
+// size 不為負數時，寫入的大小不符即視為失敗 (例如下載中斷)；失敗時不會留下部分寫入的檔案
+func WriteAtomic(targetPath string, r io.Reader, size int64) (int64, string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(targetPath), ".upload-*")
+	if err != nil {
+		return 0, "", fmt.Errorf("無法建立暫存檔: %w", err)
+	}
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if err == nil && size >= 0 && written != size {
+		err = fmt.Errorf("寫入 %d bytes，預期 %d bytes", written, size)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), targetPath)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, "", fmt.Errorf("寫入 '%s' 失敗: %w", targetPath, err)
+	}
+	return written, hex.EncodeToString(hash.Sum(nil)), nil
+}
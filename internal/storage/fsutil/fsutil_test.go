@@ -0,0 +1,141 @@
+package fsutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// dirEntries 回傳目錄中的檔名，用於確認沒有殘留的暫存檔
+func dirEntries(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestWriteAtomic(t *testing.T) {
+	content := "影片內容 0123456789"
+	sum := sha256.Sum256([]byte(content))
+	for _, size := range []int64{int64(len(content)), -1} {
+		dir := t.TempDir()
+		target := filepath.Join(dir, "4567890.mp4")
+		written, hash, err := WriteAtomic(target, strings.NewReader(content), size)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if written != int64(len(content)) || hash != hex.EncodeToString(sum[:]) {
+			t.Errorf("size %d: WriteAtomic = %d, %s", size, written, hash)
+		}
+		if got, _ := os.ReadFile(target); string(got) != content {
+			t.Errorf("size %d: 檔案內容 = %q", size, got)
+		}
+		if names := dirEntries(t, dir); len(names) != 1 {
+			t.Errorf("size %d: 不應殘留暫存檔: %v", size, names)
+		}
+	}
+}
+
+func TestWriteAtomicReplacesExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "a.mp4")
+	if err := os.WriteFile(target, []byte("舊內容"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := WriteAtomic(target, strings.NewReader("新內容"), -1); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(target); string(got) != "新內容" {
+		t.Errorf("檔案內容 = %q", got)
+	}
+}
+
+func TestWriteAtomicFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		r       io.Reader
+		size    int64
+		wantErr string
+	}{
+		{name: "大小不符 (下載中斷)", r: strings.NewReader("12345"), size: 10, wantErr: "預期 10 bytes"},
+		{name: "超過預期大小", r: strings.NewReader("12345"), size: 3, wantErr: "預期 3 bytes"},
+		{name: "讀取錯誤", r: io.MultiReader(strings.NewReader("12345"), iotest.ErrReader(io.ErrUnexpectedEOF)), size: -1, wantErr: io.ErrUnexpectedEOF.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			target := filepath.Join(dir, "a.mp4")
+			if err := os.WriteFile(target, []byte("舊內容"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			written, hash, err := WriteAtomic(target, tt.r, tt.size)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || written != 0 || hash != "" {
+				t.Fatalf("WriteAtomic = %d, %q, %v, want 錯誤包含 %q", written, hash, err, tt.wantErr)
+			}
+			// 失敗時保留原檔案且不留下部分寫入的暫存檔
+			if got, _ := os.ReadFile(target); string(got) != "舊內容" {
+				t.Errorf("原檔案被覆寫: %q", got)
+			}
+			if names := dirEntries(t, dir); len(names) != 1 {
+				t.Errorf("不應殘留暫存檔: %v", names)
+			}
+		})
+	}
+}
+
+func TestWriteAtomicMissingDir(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "missing", "a.mp4")
+	if _, _, err := WriteAtomic(target, strings.NewReader("x"), 1); err == nil || !strings.Contains(err.Error(), "暫存檔") {
+		t.Errorf("目錄不存在時應回傳錯誤，got %v", err)
+	}
+}
+
+func TestEnsureFreeSpace(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := freeBytes(dir); errors.Is(err, errors.ErrUnsupported) {
+		// 不支援的平台一律略過檢查
+		if err := EnsureFreeSpace(dir, 1<<62, 1<<62); err != nil {
+			t.Errorf("EnsureFreeSpace() = %v", err)
+		}
+		t.Skip("此平台不支援查詢磁碟剩餘空間")
+	}
+
+	tests := []struct {
+		name          string
+		need, reserve int64
+		wantErr       bool
+	}{
+		{name: "不需要空間", need: 0, reserve: 0},
+		{name: "大小未知只檢查保留空間", need: -1, reserve: 1},
+		{name: "負數的保留空間視為 0", need: 1, reserve: -1 << 62},
+		{name: "檔案大小超過剩餘空間", need: 1 << 62, reserve: 0, wantErr: true},
+		{name: "保留空間超過剩餘空間", need: -1, reserve: 1 << 62, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := EnsureFreeSpace(dir, tt.need, tt.reserve)
+			if tt.wantErr != errors.Is(err, ErrInsufficientSpace) {
+				t.Errorf("EnsureFreeSpace() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("EnsureFreeSpace() = %v", err)
+			}
+		})
+	}
+
+	if err := EnsureFreeSpace(filepath.Join(dir, "missing"), 0, 0); err == nil || errors.Is(err, ErrInsufficientSpace) {
+		t.Errorf("目錄不存在時應回傳查詢錯誤，got %v", err)
+	}
+}
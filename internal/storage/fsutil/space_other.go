@@ -0,0 +1,9 @@
+//go:build !unix
+
+package fsutil
+
+import "errors"
+
+func freeBytes(dir string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
@@ -63,8 +63,8 @@ func appendIfMissingVideo(slice []models.Video, v models.Video) []models.Video {
 }
 
 // GetAllVideosWithAnalysis (保持不變)
-func (s *MySQLStore) GetAllVideosWithAnalysis(limit int, offset int, searchTerm string, sortBy string, sortOrder string) ([]models.Video, []models.AnalysisResult, error) {
-	log.Printf("資訊：MySQLStore.GetAllVideosWithAnalysis 被呼叫 (limit: %d, offset: %d, search: '%s', sortBy: '%s', sortOrder: '%s')\n", limit, offset, searchTerm, sortBy, sortOrder)
+func (s *MySQLStore) GetAllVideosWithAnalysis(limit int, offset int, searchTerm string, sortBy string, sortOrder string, outcome string) ([]models.Video, []models.AnalysisResult, error) {
+	log.Printf("資訊：MySQLStore.GetAllVideosWithAnalysis 被呼叫 (limit: %d, offset: %d, search: '%s', sortBy: '%s', sortOrder: '%s', outcome: '%s')\n", limit, offset, searchTerm, sortBy, sortOrder, outcome)
 	var args []interface{}
 	baseQuery := `
 		SELECT
@@ -72,12 +72,14 @@ func (s *MySQLStore) GetAllVideosWithAnalysis(limit int, offset int, searchTerm
 			v.fetched_at, v.published_at, v.duration_secs, v.shotlist_content, v.view_link,
 			v.analysis_status, v.analyzed_at, v.source_metadata,
 			v.subjects, v.location, v.restrictions, v.tran_restrictions,
-			v.prompt_version,
-			ar.video_id, ar.transcript, ar.translation, 
+			v.prompt_version, v.pinned, v.archive_reason,
+			v.poster_path, v.contact_sheet_path, v.thumbnails_at, v.web_proxy_source, v.web_proxy_path,
+			ar.video_id, ar.transcript, ar.translation, ar.segments,
 			ar.short_summary, ar.bulleted_summary, ar.bites, ar.mentioned_locations,
 			ar.importance_score, ar.material_type, ar.related_news,
 			ar.visual_description, ar.topics, ar.keywords, ar.error_message,
-			ar.prompt_version, ar.created_at, ar.updated_at
+			ar.prompt_version, ar.model_name, ar.outcome, ar.fallback, ar.safety_ratings,
+			ar.created_at, ar.updated_at
 		FROM videos v
 		LEFT JOIN analysis_results ar ON v.id = ar.video_id
 	`
@@ -105,11 +107,17 @@ func (s *MySQLStore) GetAllVideosWithAnalysis(limit int, offset int, searchTerm
 		string(models.StatusVideoAnalysisFailed): true,
 		string(models.StatusCompleted):           true,
 		string(models.StatusFailed):              true,
+		string(models.StatusArchived):            true,
 	}
 	if validStatuses[sortOrder] {
 		whereClauses = append(whereClauses, "v.analysis_status = ?")
 		args = append(args, sortOrder)
 	}
+	// 依 Gemini 回應結果過濾 (例如只列出被安全設定阻擋、需人工審閱的影片)
+	if outcome != "" {
+		whereClauses = append(whereClauses, "ar.outcome = ?")
+		args = append(args, outcome)
+	}
 	if len(whereClauses) > 0 {
 		baseQuery += " WHERE " + strings.Join(whereClauses, " AND ")
 	}
@@ -170,18 +178,20 @@ func (s *MySQLStore) GetAllVideosWithAnalysis(limit int, offset int, searchTerm
 		var sourceMetadataSQL, subjectsSQL sql.RawBytes
 		var shotlistContentSQL, viewLinkSQL, locationSQL, restrictionsSQL, tranRestrictionsSQL sql.NullString
 		var arVideoID sql.NullInt64
-		var arTranscriptSQL, arTranslationSQL, arShortSummarySQL, arBulletedSummarySQL, arMaterialTypeSQL, arVisualDescriptionSQL, arErrorMessageSQL, arPromptVersionSQL sql.NullString
-		var arTopicsSQL, arKeywordsSQL, arBitesSQL, arMentionedLocationsSQL, arImportanceScoreSQL, arRelatedNewsSQL sql.RawBytes
+		var arTranscriptSQL, arTranslationSQL, arShortSummarySQL, arBulletedSummarySQL, arMaterialTypeSQL, arVisualDescriptionSQL, arErrorMessageSQL, arPromptVersionSQL, arModelNameSQL, arOutcomeSQL, arFallbackSQL sql.NullString
+		var arTopicsSQL, arKeywordsSQL, arBitesSQL, arMentionedLocationsSQL, arImportanceScoreSQL, arRelatedNewsSQL, arSegmentsSQL, arSafetyRatingsSQL sql.RawBytes
 		var arCreatedAt, arUpdatedAt sql.NullTime
 
 		scanTargets := []interface{}{
 			&v.ID, &v.SourceName, &v.SourceID, &v.NASPath, &v.Title,
 			&v.FetchedAt, &v.PublishedAt, &v.DurationSecs, &shotlistContentSQL, &viewLinkSQL,
 			&v.AnalysisStatus, &v.AnalyzedAt, &sourceMetadataSQL,
-			&subjectsSQL, &locationSQL, &restrictionsSQL, &tranRestrictionsSQL, &v.PromptVersion,
-			&arVideoID, &arTranscriptSQL, &arTranslationSQL, &arShortSummarySQL, &arBulletedSummarySQL,
+			&subjectsSQL, &locationSQL, &restrictionsSQL, &tranRestrictionsSQL, &v.PromptVersion, &v.Pinned, &v.ArchiveReason,
+			&v.PosterPath, &v.ContactSheetPath, &v.ThumbnailsAt, &v.WebProxySource, &v.WebProxyPath,
+			&arVideoID, &arTranscriptSQL, &arTranslationSQL, &arSegmentsSQL, &arShortSummarySQL, &arBulletedSummarySQL,
 			&arBitesSQL, &arMentionedLocationsSQL, &arImportanceScoreSQL, &arMaterialTypeSQL, &arRelatedNewsSQL,
-			&arVisualDescriptionSQL, &arTopicsSQL, &arKeywordsSQL, &arErrorMessageSQL, &arPromptVersionSQL,
+			&arVisualDescriptionSQL, &arTopicsSQL, &arKeywordsSQL, &arErrorMessageSQL, &arPromptVersionSQL, &arModelNameSQL,
+			&arOutcomeSQL, &arFallbackSQL, &arSafetyRatingsSQL,
 			&arCreatedAt, &arUpdatedAt,
 		}
 		if err := rows.Scan(scanTargets...); err != nil {
@@ -254,6 +264,11 @@ func (s *MySQLStore) GetAllVideosWithAnalysis(limit int, offset int, searchTerm
 			} else {
 				arTemp.Bites = nil
 			}
+			if arSegmentsSQL != nil {
+				arTemp.Segments = copyBytes(arSegmentsSQL)
+			} else {
+				arTemp.Segments = nil
+			}
 			if arMentionedLocationsSQL != nil {
 				arTemp.MentionedLocations = copyBytes(arMentionedLocationsSQL)
 			} else {
@@ -285,6 +300,12 @@ func (s *MySQLStore) GetAllVideosWithAnalysis(limit int, offset int, searchTerm
 			} else {
 				arTemp.PromptVersion = ""
 			}
+			arTemp.ModelName = arModelNameSQL.String
+			arTemp.Outcome = arOutcomeSQL.String
+			arTemp.Fallback = arFallbackSQL.String
+			if arSafetyRatingsSQL != nil {
+				arTemp.SafetyRatings = copyBytes(arSafetyRatingsSQL)
+			}
 			if arCreatedAt.Valid {
 				arTemp.CreatedAt = arCreatedAt.Time
 			}
@@ -399,20 +420,24 @@ func (s *MySQLStore) SaveAnalysisResult(result *models.AnalysisResult) error {
 
 	query := `
 		INSERT INTO analysis_results (
-			video_id, transcript, translation, short_summary, bulleted_summary, bites, 
+			video_id, transcript, translation, segments, short_summary, bulleted_summary, bites, 
 			mentioned_locations, importance_score, material_type, related_news,
-			visual_description, topics, keywords, error_message, prompt_version, 
-			created_at, updated_at
+			visual_description, topics, keywords, error_message, prompt_version, prompt_hash,
+			model_name, model_params, outcome, fallback, safety_ratings, created_at, updated_at
 		)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON DUPLICATE KEY UPDATE
-			transcript = VALUES(transcript), translation = VALUES(translation), short_summary = VALUES(short_summary),
+			transcript = VALUES(transcript), translation = VALUES(translation), segments = VALUES(segments),
+			short_summary = VALUES(short_summary),
 			bulleted_summary = VALUES(bulleted_summary), bites = VALUES(bites),
 			mentioned_locations = VALUES(mentioned_locations), importance_score = VALUES(importance_score),
 			material_type = VALUES(material_type), related_news = VALUES(related_news),
 			visual_description = VALUES(visual_description), topics = VALUES(topics), 
 			keywords = VALUES(keywords), error_message = VALUES(error_message), 
-			prompt_version = VALUES(prompt_version), updated_at = VALUES(updated_at);`
+			prompt_version = VALUES(prompt_version), prompt_hash = VALUES(prompt_hash),
+			model_name = VALUES(model_name), model_params = VALUES(model_params),
+			outcome = VALUES(outcome), fallback = VALUES(fallback), safety_ratings = VALUES(safety_ratings),
+			updated_at = VALUES(updated_at);`
 
 	toSQLNullString := func(jns *models.JsonNullString) sql.NullString {
 		if jns != nil {
@@ -439,6 +464,7 @@ func (s *MySQLStore) SaveAnalysisResult(result *models.AnalysisResult) error {
 		result.VideoID,
 		toSQLNullString(result.Transcript),
 		toSQLNullString(result.Translation),
+		result.Segments, // json.RawMessage
 		toSQLNullString(result.ShortSummary),
 		toSQLNullString(result.BulletedSummary),
 		result.Bites,              // json.RawMessage
@@ -451,6 +477,12 @@ func (s *MySQLStore) SaveAnalysisResult(result *models.AnalysisResult) error {
 		result.Keywords, // json.RawMessage
 		toSQLNullString(result.ErrorMessage),
 		promptVersion,
+		sql.NullString{String: result.PromptHash, Valid: result.PromptHash != ""},
+		sql.NullString{String: result.ModelName, Valid: result.ModelName != ""},
+		result.ModelParams, // json.RawMessage
+		sql.NullString{String: result.Outcome, Valid: result.Outcome != ""},
+		sql.NullString{String: result.Fallback, Valid: result.Fallback != ""},
+		result.SafetyRatings, // json.RawMessage
 		createdAt,
 		updatedAt,
 	)
@@ -513,12 +545,14 @@ func (s *MySQLStore) GetVideoByID(videoID int64) (*models.Video, error) {
 	if videoID == 0 {
 		return nil, fmt.Errorf("無效的 VideoID")
 	}
-	query := ` SELECT id, source_name, source_id, nas_path, title, fetched_at, published_at, duration_secs, shotlist_content, view_link, subjects, location, analysis_status, analyzed_at, source_metadata FROM videos WHERE id = ?;`
+	query := ` SELECT id, source_name, source_id, nas_path, title, fetched_at, published_at, duration_secs, shotlist_content, view_link, subjects, location, analysis_status, analyzed_at, source_metadata,
+		restrictions, tran_restrictions, poster_path, contact_sheet_path, thumbnails_at FROM videos WHERE id = ?;`
 	row := s.db.QueryRow(query, videoID)
 	var v models.Video
 	var sourceMetadataBytes, subjectsBytes []byte
 	var shotlistContentSQL, locationSQL, viewLinkSQL sql.NullString
-	err := row.Scan(&v.ID, &v.SourceName, &v.SourceID, &v.NASPath, &v.Title, &v.FetchedAt, &v.PublishedAt, &v.DurationSecs, &shotlistContentSQL, &viewLinkSQL, &subjectsBytes, &locationSQL, &v.AnalysisStatus, &v.AnalyzedAt, &sourceMetadataBytes)
+	err := row.Scan(&v.ID, &v.SourceName, &v.SourceID, &v.NASPath, &v.Title, &v.FetchedAt, &v.PublishedAt, &v.DurationSecs, &shotlistContentSQL, &viewLinkSQL, &subjectsBytes, &locationSQL, &v.AnalysisStatus, &v.AnalyzedAt, &sourceMetadataBytes,
+		&v.Restrictions, &v.TranRestrictions, &v.PosterPath, &v.ContactSheetPath, &v.ThumbnailsAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -605,3 +639,1163 @@ func (s *MySQLStore) GetVideoBySourceID(sourceName string, sourceID string) (*mo
 	}
 	return &v, nil
 }
+
+// GetAnalysisResultByVideoID 查詢單一影片的分析結果，查無資料時回傳 (nil, nil)
+func (s *MySQLStore) GetAnalysisResultByVideoID(videoID int64) (*models.AnalysisResult, error) {
+	if videoID == 0 {
+		return nil, fmt.Errorf("無效的 VideoID")
+	}
+	query := ` SELECT video_id, transcript, translation, segments, short_summary, bulleted_summary, bites, mentioned_locations, importance_score, material_type, related_news, visual_description, topics, keywords, error_message, prompt_version, prompt_hash, model_name, model_params, outcome, fallback, safety_ratings, created_at, updated_at FROM analysis_results WHERE video_id = ?;`
+	row := s.db.QueryRow(query, videoID)
+	var ar models.AnalysisResult
+	var transcriptSQL, translationSQL, shortSummarySQL, bulletedSummarySQL, materialTypeSQL, visualDescriptionSQL, errorMessageSQL, promptVersionSQL, promptHashSQL, modelNameSQL, outcomeSQL, fallbackSQL sql.NullString
+	var segmentsBytes, bitesBytes, mentionedLocationsBytes, importanceScoreBytes, relatedNewsBytes, topicsBytes, keywordsBytes, modelParamsBytes, safetyRatingsBytes []byte
+	err := row.Scan(&ar.VideoID, &transcriptSQL, &translationSQL, &segmentsBytes, &shortSummarySQL, &bulletedSummarySQL, &bitesBytes, &mentionedLocationsBytes, &importanceScoreBytes, &materialTypeSQL, &relatedNewsBytes, &visualDescriptionSQL, &topicsBytes, &keywordsBytes, &errorMessageSQL, &promptVersionSQL, &promptHashSQL, &modelNameSQL, &modelParamsBytes, &outcomeSQL, &fallbackSQL, &safetyRatingsBytes, &ar.CreatedAt, &ar.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查詢 VideoID %d 的分析結果失敗: %w", videoID, err)
+	}
+	toJsonNullString := func(ns sql.NullString) *models.JsonNullString {
+		if !ns.Valid {
+			return nil
+		}
+		return &models.JsonNullString{NullString: ns}
+	}
+	ar.Transcript = toJsonNullString(transcriptSQL)
+	ar.Translation = toJsonNullString(translationSQL)
+	ar.ShortSummary = toJsonNullString(shortSummarySQL)
+	ar.BulletedSummary = toJsonNullString(bulletedSummarySQL)
+	ar.MaterialType = toJsonNullString(materialTypeSQL)
+	ar.VisualDescription = toJsonNullString(visualDescriptionSQL)
+	ar.ErrorMessage = toJsonNullString(errorMessageSQL)
+	ar.Segments = copyBytes(segmentsBytes)
+	ar.Bites = copyBytes(bitesBytes)
+	ar.MentionedLocations = copyBytes(mentionedLocationsBytes)
+	ar.ImportanceScore = copyBytes(importanceScoreBytes)
+	ar.RelatedNews = copyBytes(relatedNewsBytes)
+	ar.Topics = copyBytes(topicsBytes)
+	ar.Keywords = copyBytes(keywordsBytes)
+	ar.PromptVersion = promptVersionSQL.String
+	ar.PromptHash = promptHashSQL.String
+	ar.ModelName = modelNameSQL.String
+	ar.ModelParams = copyBytes(modelParamsBytes)
+	ar.Outcome = outcomeSQL.String
+	ar.Fallback = fallbackSQL.String
+	ar.SafetyRatings = copyBytes(safetyRatingsBytes)
+	return &ar, nil
+}
+
+// GetCompletedVideosForFeed 依篩選條件查詢最近完成分析的影片及其分析結果 (依 analyzed_at 降冪)，供訂閱源使用
+func (s *MySQLStore) GetCompletedVideosForFeed(filter models.FeedFilter, limit int) ([]models.Video, []models.AnalysisResult, error) {
+	query := `SELECT v.id FROM videos v INNER JOIN analysis_results ar ON v.id = ar.video_id LEFT JOIN analysis_reviews rv ON v.id = rv.video_id`
+	whereClauses := []string{"v.analysis_status = ?"}
+	args := []interface{}{models.StatusCompleted}
+	if filter.SourceName != "" {
+		whereClauses = append(whereClauses, "v.source_name = ?")
+		args = append(args, filter.SourceName)
+	}
+	if len(filter.Ratings) > 0 {
+		placeholders := make([]string, len(filter.Ratings))
+		for i, rating := range filter.Ratings {
+			placeholders[i] = "?"
+			args = append(args, strings.ToUpper(rating))
+		}
+		whereClauses = append(whereClauses, fmt.Sprintf("UPPER(COALESCE(rv.overall_rating, JSON_UNQUOTE(JSON_EXTRACT(ar.importance_score, '$.overall_rating')))) IN (%s)", strings.Join(placeholders, ", ")))
+	}
+	if filter.Topic != "" {
+		whereClauses = append(whereClauses, "(JSON_CONTAINS(IFNULL(ar.topics, JSON_ARRAY()), JSON_QUOTE(?)) OR JSON_CONTAINS(IFNULL(v.subjects, JSON_ARRAY()), JSON_QUOTE(?)))")
+		args = append(args, filter.Topic, filter.Topic)
+	}
+	if filter.SearchTerm != "" {
+		likeTerm := "%" + strings.ReplaceAll(strings.ReplaceAll(filter.SearchTerm, "%", "\\%"), "_", "\\_") + "%"
+		whereClauses = append(whereClauses, `(
+			v.source_id LIKE ? OR v.title LIKE ? OR IFNULL(v.shotlist_content, '') LIKE ? OR
+			IFNULL(ar.short_summary, '') LIKE ? OR IFNULL(ar.bulleted_summary, '') LIKE ? OR IFNULL(ar.keywords, '') LIKE ?
+		)`)
+		for i := 0; i < 6; i++ {
+			args = append(args, likeTerm)
+		}
+	}
+	query += " WHERE " + strings.Join(whereClauses, " AND ") + " ORDER BY v.analyzed_at DESC, v.id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("查詢訂閱源影片失敗: %w", err)
+	}
+	var videoIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("掃描訂閱源影片 ID 失敗: %w", err)
+		}
+		videoIDs = append(videoIDs, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("處理訂閱源查詢結果集時發生錯誤: %w", err)
+	}
+
+	var videos []models.Video
+	var results []models.AnalysisResult
+	for _, id := range videoIDs {
+		v, err := s.GetVideoByID(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		ar, err := s.GetAnalysisResultByVideoID(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		if v == nil || ar == nil {
+			continue
+		}
+		videos = append(videos, *v)
+		results = append(results, *ar)
+	}
+	return videos, results, nil
+}
+
+// CreateWebhookDelivery 新增一筆 webhook 傳送紀錄並回傳其 ID
+func (s *MySQLStore) CreateWebhookDelivery(delivery *models.WebhookDelivery) (int64, error) {
+	if delivery == nil || delivery.VideoID == 0 || delivery.SubscriptionName == "" {
+		return 0, fmt.Errorf("無效的 webhook 傳送紀錄")
+	}
+	status := delivery.Status
+	if status == "" {
+		status = models.WebhookDeliveryPending
+	}
+	query := `INSERT INTO webhook_deliveries (subscription_name, video_id, event, payload, status, attempts, next_attempt_at) VALUES (?, ?, ?, ?, ?, ?, ?);`
+	res, err := s.db.Exec(query, delivery.SubscriptionName, delivery.VideoID, delivery.Event, delivery.Payload, status, delivery.Attempts, delivery.NextAttemptAt)
+	if err != nil {
+		return 0, fmt.Errorf("新增 webhook 傳送紀錄失敗 (VideoID: %d, 訂閱: %s): %w", delivery.VideoID, delivery.SubscriptionName, err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("獲取 webhook 傳送紀錄 ID 失敗: %w", err)
+	}
+	return id, nil
+}
+
+// UpdateWebhookDeliveryAttempt 更新 webhook 傳送紀錄的狀態與最後一次嘗試結果
+func (s *MySQLStore) UpdateWebhookDeliveryAttempt(id int64, status models.WebhookDeliveryStatus, attempts int, statusCode sql.NullInt64, lastError sql.NullString, nextAttemptAt sql.NullTime) error {
+	query := `UPDATE webhook_deliveries SET status = ?, attempts = ?, last_status_code = ?, last_error = ?, next_attempt_at = ? WHERE id = ?;`
+	if _, err := s.db.Exec(query, status, attempts, statusCode, lastError, nextAttemptAt, id); err != nil {
+		return fmt.Errorf("更新 webhook 傳送紀錄 %d 失敗: %w", id, err)
+	}
+	return nil
+}
+
+// ClaimWebhookDeliveryReplay 將非 pending 的傳送紀錄重設為 pending 以重新推送 (嘗試次數歸零)；
+// 紀錄仍為 pending (原本的推送仍在重試中) 或不存在時回傳 false。以單一條件式 UPDATE 避免同時重送
+func (s *MySQLStore) ClaimWebhookDeliveryReplay(id int64, nextAttemptAt time.Time) (bool, error) {
+	query := `UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = ? WHERE id = ? AND status <> ?;`
+	res, err := s.db.Exec(query, models.WebhookDeliveryPending, nextAttemptAt, id, models.WebhookDeliveryPending)
+	if err != nil {
+		return false, fmt.Errorf("重設 webhook 傳送紀錄 %d 失敗: %w", id, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("重設 webhook 傳送紀錄 %d 失敗: %w", id, err)
+	}
+	return n == 1, nil
+}
+
+const webhookDeliveryColumns = `id, subscription_name, video_id, event, payload, status, attempts, last_status_code, last_error, next_attempt_at, created_at, updated_at`
+
+func scanWebhookDelivery(scan func(dest ...interface{}) error) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var payload []byte
+	if err := scan(&d.ID, &d.SubscriptionName, &d.VideoID, &d.Event, &payload, &d.Status, &d.Attempts, &d.LastStatusCode, &d.LastError, &d.NextAttemptAt, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		return nil, err
+	}
+	d.Payload = copyBytes(payload)
+	return &d, nil
+}
+
+// GetWebhookDeliveryByID 查詢單一 webhook 傳送紀錄，查無資料時回傳 (nil, nil)
+func (s *MySQLStore) GetWebhookDeliveryByID(id int64) (*models.WebhookDelivery, error) {
+	row := s.db.QueryRow("SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE id = ?;", id)
+	d, err := scanWebhookDelivery(row.Scan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查詢 webhook 傳送紀錄 %d 失敗: %w", id, err)
+	}
+	return d, nil
+}
+
+// GetWebhookDeliveriesByStatus 依狀態查詢 webhook 傳送紀錄 (新的在前)
+func (s *MySQLStore) GetWebhookDeliveriesByStatus(status models.WebhookDeliveryStatus, limit int) ([]models.WebhookDelivery, error) {
+	rows, err := s.db.Query("SELECT "+webhookDeliveryColumns+" FROM webhook_deliveries WHERE status = ? ORDER BY updated_at DESC, id DESC LIMIT ?;", status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查詢狀態為 '%s' 的 webhook 傳送紀錄失敗: %w", status, err)
+	}
+	defer rows.Close()
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows.Scan)
+		if err != nil {
+			log.Printf("錯誤：掃描 webhook 傳送紀錄失敗: %v", err)
+			continue
+		}
+		deliveries = append(deliveries, *d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("處理 webhook 傳送紀錄查詢結果集時發生錯誤: %w", err)
+	}
+	return deliveries, nil
+}
+
+const alertRuleColumns = `id, name, expression, channel, target, enabled, created_at, updated_at`
+
+func scanAlertRule(scan func(dest ...interface{}) error) (*models.AlertRule, error) {
+	var r models.AlertRule
+	if err := scan(&r.ID, &r.Name, &r.Expression, &r.Channel, &r.Target, &r.Enabled, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetAlertRules 查詢所有快訊規則；enabledOnly 為 true 時只回傳啟用中的規則
+func (s *MySQLStore) GetAlertRules(enabledOnly bool) ([]models.AlertRule, error) {
+	query := "SELECT " + alertRuleColumns + " FROM alert_rules"
+	if enabledOnly {
+		query += " WHERE enabled = TRUE"
+	}
+	rows, err := s.db.Query(query + " ORDER BY id;")
+	if err != nil {
+		return nil, fmt.Errorf("查詢快訊規則失敗: %w", err)
+	}
+	defer rows.Close()
+	var rules []models.AlertRule
+	for rows.Next() {
+		r, err := scanAlertRule(rows.Scan)
+		if err != nil {
+			log.Printf("錯誤：掃描快訊規則失敗: %v", err)
+			continue
+		}
+		rules = append(rules, *r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("處理快訊規則查詢結果集時發生錯誤: %w", err)
+	}
+	return rules, nil
+}
+
+// GetAlertRuleByID 查詢單一快訊規則，查無資料時回傳 (nil, nil)
+func (s *MySQLStore) GetAlertRuleByID(id int64) (*models.AlertRule, error) {
+	row := s.db.QueryRow("SELECT "+alertRuleColumns+" FROM alert_rules WHERE id = ?;", id)
+	r, err := scanAlertRule(row.Scan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查詢快訊規則 %d 失敗: %w", id, err)
+	}
+	return r, nil
+}
+
+// CreateAlertRule 新增快訊規則並回傳其 ID
+func (s *MySQLStore) CreateAlertRule(rule *models.AlertRule) (int64, error) {
+	query := `INSERT INTO alert_rules (name, expression, channel, target, enabled) VALUES (?, ?, ?, ?, ?);`
+	res, err := s.db.Exec(query, rule.Name, rule.Expression, rule.Channel, rule.Target, rule.Enabled)
+	if err != nil {
+		return 0, fmt.Errorf("新增快訊規則 '%s' 失敗: %w", rule.Name, err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("獲取快訊規則 ID 失敗: %w", err)
+	}
+	return id, nil
+}
+
+// UpdateAlertRule 更新快訊規則
+func (s *MySQLStore) UpdateAlertRule(rule *models.AlertRule) error {
+	query := `UPDATE alert_rules SET name = ?, expression = ?, channel = ?, target = ?, enabled = ? WHERE id = ?;`
+	if _, err := s.db.Exec(query, rule.Name, rule.Expression, rule.Channel, rule.Target, rule.Enabled, rule.ID); err != nil {
+		return fmt.Errorf("更新快訊規則 %d 失敗: %w", rule.ID, err)
+	}
+	return nil
+}
+
+// DeleteAlertRule 刪除快訊規則 (觸發紀錄一併刪除)
+func (s *MySQLStore) DeleteAlertRule(id int64) error {
+	if _, err := s.db.Exec("DELETE FROM alert_rules WHERE id = ?;", id); err != nil {
+		return fmt.Errorf("刪除快訊規則 %d 失敗: %w", id, err)
+	}
+	return nil
+}
+
+// RecordAlertFiring 嘗試記錄規則對影片的觸發；若已觸發過則 created 為 false (用於去重)
+func (s *MySQLStore) RecordAlertFiring(ruleID, videoID int64) (firingID int64, created bool, err error) {
+	res, err := s.db.Exec("INSERT IGNORE INTO alert_firings (rule_id, video_id, status) VALUES (?, ?, ?);", ruleID, videoID, models.AlertFiringPending)
+	if err != nil {
+		return 0, false, fmt.Errorf("記錄快訊觸發失敗 (規則: %d, VideoID: %d): %w", ruleID, videoID, err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, false, fmt.Errorf("獲取快訊觸發影響行數失敗: %w", err)
+	}
+	if affected == 0 {
+		return 0, false, nil
+	}
+	firingID, err = res.LastInsertId()
+	if err != nil {
+		return 0, false, fmt.Errorf("獲取快訊觸發 ID 失敗: %w", err)
+	}
+	return firingID, true, nil
+}
+
+// UpdateAlertFiringStatus 更新快訊觸發紀錄的寄送結果
+func (s *MySQLStore) UpdateAlertFiringStatus(id int64, status models.AlertFiringStatus, errorMessage sql.NullString) error {
+	if _, err := s.db.Exec("UPDATE alert_firings SET status = ?, error_message = ? WHERE id = ?;", status, errorMessage, id); err != nil {
+		return fmt.Errorf("更新快訊觸發紀錄 %d 失敗: %w", id, err)
+	}
+	return nil
+}
+
+const userColumns = `id, username, display_name, email, password_hash, role, auth_provider, oidc_subject, enabled, last_login_at, created_at, updated_at`
+
+func scanUser(scan func(dest ...interface{}) error) (*models.User, error) {
+	var u models.User
+	if err := scan(&u.ID, &u.Username, &u.DisplayName, &u.Email, &u.PasswordHash, &u.Role, &u.AuthProvider, &u.OIDCSubject, &u.Enabled, &u.LastLoginAt, &u.CreatedAt, &u.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (s *MySQLStore) getUserWhere(where string, arg interface{}) (*models.User, error) {
+	row := s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE "+where+";", arg)
+	u, err := scanUser(row.Scan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查詢使用者 (%v) 失敗: %w", arg, err)
+	}
+	return u, nil
+}
+
+// GetUserByID 依 ID 查詢使用者，查無資料時回傳 (nil, nil)
+func (s *MySQLStore) GetUserByID(id int64) (*models.User, error) {
+	return s.getUserWhere("id = ?", id)
+}
+
+// GetUserByUsername 依帳號查詢使用者，查無資料時回傳 (nil, nil)
+func (s *MySQLStore) GetUserByUsername(username string) (*models.User, error) {
+	return s.getUserWhere("username = ?", username)
+}
+
+// GetUserByOIDCSubject 依 OIDC subject 查詢使用者，查無資料時回傳 (nil, nil)
+func (s *MySQLStore) GetUserByOIDCSubject(subject string) (*models.User, error) {
+	return s.getUserWhere("oidc_subject = ?", subject)
+}
+
+// ListUsers 列出所有使用者
+func (s *MySQLStore) ListUsers() ([]models.User, error) {
+	rows, err := s.db.Query("SELECT " + userColumns + " FROM users ORDER BY username;")
+	if err != nil {
+		return nil, fmt.Errorf("查詢使用者列表失敗: %w", err)
+	}
+	defer rows.Close()
+	var users []models.User
+	for rows.Next() {
+		u, err := scanUser(rows.Scan)
+		if err != nil {
+			log.Printf("錯誤：掃描使用者失敗: %v", err)
+			continue
+		}
+		users = append(users, *u)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("處理使用者查詢結果集時發生錯誤: %w", err)
+	}
+	return users, nil
+}
+
+// CountUsers 回傳使用者總數
+func (s *MySQLStore) CountUsers() (int, error) {
+	var count int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM users;").Scan(&count); err != nil {
+		return 0, fmt.Errorf("計算使用者數量失敗: %w", err)
+	}
+	return count, nil
+}
+
+// CreateUser 新增使用者並回傳其 ID
+func (s *MySQLStore) CreateUser(user *models.User) (int64, error) {
+	query := `INSERT INTO users (username, display_name, email, password_hash, role, auth_provider, oidc_subject, enabled) VALUES (?, ?, ?, ?, ?, ?, ?, ?);`
+	res, err := s.db.Exec(query, user.Username, user.DisplayName, user.Email, user.PasswordHash, user.Role, user.AuthProvider, user.OIDCSubject, user.Enabled)
+	if err != nil {
+		return 0, fmt.Errorf("新增使用者 '%s' 失敗: %w", user.Username, err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("獲取使用者 ID 失敗: %w", err)
+	}
+	return id, nil
+}
+
+// UpdateUser 更新使用者的顯示名稱、email、密碼、角色與啟用狀態
+func (s *MySQLStore) UpdateUser(user *models.User) error {
+	query := `UPDATE users SET display_name = ?, email = ?, password_hash = ?, role = ?, enabled = ? WHERE id = ?;`
+	if _, err := s.db.Exec(query, user.DisplayName, user.Email, user.PasswordHash, user.Role, user.Enabled, user.ID); err != nil {
+		return fmt.Errorf("更新使用者 %d 失敗: %w", user.ID, err)
+	}
+	return nil
+}
+
+// UpdateUserLastLogin 更新使用者最後登入時間
+func (s *MySQLStore) UpdateUserLastLogin(userID int64, at time.Time) error {
+	if _, err := s.db.Exec("UPDATE users SET last_login_at = ? WHERE id = ?;", at, userID); err != nil {
+		return fmt.Errorf("更新使用者 %d 最後登入時間失敗: %w", userID, err)
+	}
+	return nil
+}
+
+// CreateSession 新增登入 session
+func (s *MySQLStore) CreateSession(session *models.Session) error {
+	query := `INSERT INTO sessions (id, user_id, csrf_token, ip_address, user_agent, expires_at) VALUES (?, ?, ?, ?, ?, ?);`
+	if _, err := s.db.Exec(query, session.ID, session.UserID, session.CSRFToken, session.IPAddress, session.UserAgent, session.ExpiresAt); err != nil {
+		return fmt.Errorf("新增 session 失敗 (UserID: %d): %w", session.UserID, err)
+	}
+	return nil
+}
+
+// GetSession 查詢未過期的 session，查無資料或已過期時回傳 (nil, nil)
+func (s *MySQLStore) GetSession(id string) (*models.Session, error) {
+	var sess models.Session
+	query := `SELECT id, user_id, csrf_token, ip_address, user_agent, expires_at, created_at FROM sessions WHERE id = ? AND expires_at > ?;`
+	err := s.db.QueryRow(query, id, time.Now()).Scan(&sess.ID, &sess.UserID, &sess.CSRFToken, &sess.IPAddress, &sess.UserAgent, &sess.ExpiresAt, &sess.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查詢 session 失敗: %w", err)
+	}
+	return &sess, nil
+}
+
+// DeleteSession 刪除 session (登出)
+func (s *MySQLStore) DeleteSession(id string) error {
+	if _, err := s.db.Exec("DELETE FROM sessions WHERE id = ?;", id); err != nil {
+		return fmt.Errorf("刪除 session 失敗: %w", err)
+	}
+	return nil
+}
+
+// DeleteUserSessions 刪除使用者的所有 session (停用帳號或變更密碼時使用)
+func (s *MySQLStore) DeleteUserSessions(userID int64) error {
+	if _, err := s.db.Exec("DELETE FROM sessions WHERE user_id = ?;", userID); err != nil {
+		return fmt.Errorf("刪除使用者 %d 的 session 失敗: %w", userID, err)
+	}
+	return nil
+}
+
+// DeleteExpiredSessions 清除已過期的 session，回傳刪除筆數
+func (s *MySQLStore) DeleteExpiredSessions() (int64, error) {
+	res, err := s.db.Exec("DELETE FROM sessions WHERE expires_at <= ?;", time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("清除過期 session 失敗: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
+
+// CreateAuditLog 新增一筆操作稽核紀錄
+func (s *MySQLStore) CreateAuditLog(entry *models.AuditLogEntry) error {
+	query := `INSERT INTO audit_logs (user_id, username, action, target, status_code, ip_address, details) VALUES (?, ?, ?, ?, ?, ?, ?);`
+	if _, err := s.db.Exec(query, entry.UserID, entry.Username, entry.Action, entry.Target, entry.StatusCode, entry.IPAddress, entry.Details); err != nil {
+		return fmt.Errorf("新增稽核紀錄失敗 (%s %s): %w", entry.Username, entry.Action, err)
+	}
+	return nil
+}
+
+// GetAuditLogs 查詢稽核紀錄 (新的在前)；username 不為空時只回傳該使用者的紀錄
+func (s *MySQLStore) GetAuditLogs(username string, limit, offset int) ([]models.AuditLogEntry, error) {
+	query := `SELECT id, user_id, username, action, target, status_code, ip_address, details, created_at FROM audit_logs`
+	var args []interface{}
+	if username != "" {
+		query += " WHERE username = ?"
+		args = append(args, username)
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?;"
+	args = append(args, limit, offset)
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查詢稽核紀錄失敗: %w", err)
+	}
+	defer rows.Close()
+	var entries []models.AuditLogEntry
+	for rows.Next() {
+		var e models.AuditLogEntry
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Username, &e.Action, &e.Target, &e.StatusCode, &e.IPAddress, &e.Details, &e.CreatedAt); err != nil {
+			log.Printf("錯誤：掃描稽核紀錄失敗: %v", err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("處理稽核紀錄查詢結果集時發生錯誤: %w", err)
+	}
+	return entries, nil
+}
+
+const analysisReviewColumns = `video_id, status, short_summary, bites, keywords, location, overall_rating, note, edited_by_user_id, edited_by, edited_at, reviewed_by_user_id, reviewed_by, reviewed_at, created_at, updated_at`
+
+func scanAnalysisReview(scan func(dest ...interface{}) error) (*models.AnalysisReview, error) {
+	var r models.AnalysisReview
+	var bites, keywords []byte
+	if err := scan(&r.VideoID, &r.Status, &r.ShortSummary, &bites, &keywords, &r.Location, &r.OverallRating, &r.Note,
+		&r.EditedByUserID, &r.EditedBy, &r.EditedAt, &r.ReviewedByUserID, &r.ReviewedBy, &r.ReviewedAt, &r.CreatedAt, &r.UpdatedAt); err != nil {
+		return nil, err
+	}
+	r.Bites = copyBytes(bites)
+	r.Keywords = copyBytes(keywords)
+	return &r, nil
+}
+
+// GetAnalysisReview 查詢單一影片的編輯審核紀錄，查無資料時回傳 (nil, nil)
+func (s *MySQLStore) GetAnalysisReview(videoID int64) (*models.AnalysisReview, error) {
+	row := s.db.QueryRow("SELECT "+analysisReviewColumns+" FROM analysis_reviews WHERE video_id = ?;", videoID)
+	r, err := scanAnalysisReview(row.Scan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查詢 VideoID %d 的審核紀錄失敗: %w", videoID, err)
+	}
+	return r, nil
+}
+
+// GetAnalysisReviews 批次查詢多部影片的編輯審核紀錄，回傳以 VideoID 為鍵的 map (沒有紀錄的影片不在 map 中)
+func (s *MySQLStore) GetAnalysisReviews(videoIDs []int64) (map[int64]*models.AnalysisReview, error) {
+	reviews := make(map[int64]*models.AnalysisReview)
+	if len(videoIDs) == 0 {
+		return reviews, nil
+	}
+	placeholders := make([]string, len(videoIDs))
+	args := make([]interface{}, len(videoIDs))
+	for i, id := range videoIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	rows, err := s.db.Query("SELECT "+analysisReviewColumns+" FROM analysis_reviews WHERE video_id IN ("+strings.Join(placeholders, ", ")+");", args...)
+	if err != nil {
+		return nil, fmt.Errorf("查詢審核紀錄失敗: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		r, err := scanAnalysisReview(rows.Scan)
+		if err != nil {
+			log.Printf("錯誤：掃描審核紀錄失敗: %v", err)
+			continue
+		}
+		reviews[r.VideoID] = r
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("處理審核紀錄查詢結果集時發生錯誤: %w", err)
+	}
+	return reviews, nil
+}
+
+// SaveAnalysisReview 新增或更新影片的編輯審核紀錄
+func (s *MySQLStore) SaveAnalysisReview(review *models.AnalysisReview) error {
+	var bites, keywords interface{}
+	if len(review.Bites) > 0 {
+		bites = string(review.Bites)
+	}
+	if len(review.Keywords) > 0 {
+		keywords = string(review.Keywords)
+	}
+	query := `
+		INSERT INTO analysis_reviews (video_id, status, short_summary, bites, keywords, location, overall_rating, note,
+			edited_by_user_id, edited_by, edited_at, reviewed_by_user_id, reviewed_by, reviewed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			status = VALUES(status), short_summary = VALUES(short_summary), bites = VALUES(bites), keywords = VALUES(keywords),
+			location = VALUES(location), overall_rating = VALUES(overall_rating), note = VALUES(note),
+			edited_by_user_id = VALUES(edited_by_user_id), edited_by = VALUES(edited_by), edited_at = VALUES(edited_at),
+			reviewed_by_user_id = VALUES(reviewed_by_user_id), reviewed_by = VALUES(reviewed_by), reviewed_at = VALUES(reviewed_at);`
+	_, err := s.db.Exec(query, review.VideoID, review.Status, review.ShortSummary, bites, keywords, review.Location, review.OverallRating, review.Note,
+		review.EditedByUserID, review.EditedBy, review.EditedAt, review.ReviewedByUserID, review.ReviewedBy, review.ReviewedAt)
+	if err != nil {
+		return fmt.Errorf("儲存 VideoID %d 的審核紀錄失敗: %w", review.VideoID, err)
+	}
+	return nil
+}
+
+// GetReviewSamples 查詢所有已審核 (狀態非 pending 或有編輯修訂) 的分析結果與其審核紀錄，供計算 prompt 品質指標
+func (s *MySQLStore) GetReviewSamples() ([]models.ReviewSample, error) {
+	columns := make([]string, 0, 16)
+	for _, c := range strings.Split(analysisReviewColumns, ",") {
+		columns = append(columns, "rv."+strings.TrimSpace(c))
+	}
+	query := `SELECT ar.prompt_version, v.prompt_version, ar.importance_score, ar.keywords, ` + strings.Join(columns, ", ") + `
+		FROM analysis_reviews rv
+		INNER JOIN analysis_results ar ON ar.video_id = rv.video_id
+		INNER JOIN videos v ON v.id = rv.video_id
+		WHERE rv.status <> ? OR rv.edited_at IS NOT NULL
+		ORDER BY rv.video_id;`
+	rows, err := s.db.Query(query, models.ReviewPending)
+	if err != nil {
+		return nil, fmt.Errorf("查詢已審核的分析結果失敗: %w", err)
+	}
+	defer rows.Close()
+	var samples []models.ReviewSample
+	for rows.Next() {
+		var sample models.ReviewSample
+		var videoPromptVersion, textPromptVersion sql.NullString
+		var importanceScore, keywords []byte
+		review, err := scanAnalysisReview(func(dest ...interface{}) error {
+			return rows.Scan(append([]interface{}{&videoPromptVersion, &textPromptVersion, &importanceScore, &keywords}, dest...)...)
+		})
+		if err != nil {
+			log.Printf("錯誤：掃描已審核的分析結果失敗: %v", err)
+			continue
+		}
+		sample.VideoID = review.VideoID
+		sample.VideoPromptVersion = videoPromptVersion.String
+		sample.TextPromptVersion = textPromptVersion.String
+		sample.ImportanceScore = copyBytes(importanceScore)
+		sample.Keywords = copyBytes(keywords)
+		sample.Review = *review
+		samples = append(samples, sample)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("處理已審核分析結果查詢結果集時發生錯誤: %w", err)
+	}
+	return samples, nil
+}
+
+// ListPromptVersions 列出指定類型的所有 prompt 版本 (不含內容)，依建立時間由新到舊排序
+func (s *MySQLStore) ListPromptVersions(kind models.PromptKind) ([]models.PromptVersion, error) {
+	query := `SELECT id, kind, version, base_version, author, notes, created_at FROM prompt_versions WHERE kind = ? ORDER BY created_at DESC, id DESC;`
+	rows, err := s.db.Query(query, kind)
+	if err != nil {
+		return nil, fmt.Errorf("查詢 %s prompt 版本失敗: %w", kind, err)
+	}
+	defer rows.Close()
+	var versions []models.PromptVersion
+	for rows.Next() {
+		var p models.PromptVersion
+		var baseVersion, notes sql.NullString
+		if err := rows.Scan(&p.ID, &p.Kind, &p.Version, &baseVersion, &p.Author, &notes, &p.CreatedAt); err != nil {
+			log.Printf("錯誤：掃描 prompt 版本失敗: %v", err)
+			continue
+		}
+		p.BaseVersion = baseVersion.String
+		p.Notes = notes.String
+		versions = append(versions, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("處理 prompt 版本查詢結果集時發生錯誤: %w", err)
+	}
+	return versions, nil
+}
+
+// GetPromptVersion 查詢指定的 prompt 版本 (含內容)；找不到時回傳 (nil, nil)
+func (s *MySQLStore) GetPromptVersion(kind models.PromptKind, version string) (*models.PromptVersion, error) {
+	query := `SELECT id, kind, version, content, base_version, author, notes, created_at FROM prompt_versions WHERE kind = ? AND version = ?;`
+	var p models.PromptVersion
+	var baseVersion, notes sql.NullString
+	err := s.db.QueryRow(query, kind, version).Scan(&p.ID, &p.Kind, &p.Version, &p.Content, &baseVersion, &p.Author, &notes, &p.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查詢 %s prompt 版本 '%s' 失敗: %w", kind, version, err)
+	}
+	p.BaseVersion = baseVersion.String
+	p.Notes = notes.String
+	return &p, nil
+}
+
+// CreatePromptVersion 新增一個 prompt 版本 (kind + version 需唯一)
+func (s *MySQLStore) CreatePromptVersion(p *models.PromptVersion) (int64, error) {
+	query := `INSERT INTO prompt_versions (kind, version, content, base_version, author, notes) VALUES (?, ?, ?, ?, ?, ?);`
+	res, err := s.db.Exec(query, p.Kind, p.Version, p.Content,
+		sql.NullString{String: p.BaseVersion, Valid: p.BaseVersion != ""}, p.Author,
+		sql.NullString{String: p.Notes, Valid: p.Notes != ""})
+	if err != nil {
+		return 0, fmt.Errorf("新增 %s prompt 版本 '%s' 失敗: %w", p.Kind, p.Version, err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("獲取 prompt 版本 ID 失敗: %w", err)
+	}
+	return id, nil
+}
+
+// GetActivePrompt 查詢指定類型目前使用的 prompt 版本；尚未設定時回傳 (nil, nil)
+func (s *MySQLStore) GetActivePrompt(kind models.PromptKind) (*models.ActivePrompt, error) {
+	query := `SELECT kind, version, activated_by, activated_at FROM prompt_active_versions WHERE kind = ?;`
+	var a models.ActivePrompt
+	err := s.db.QueryRow(query, kind).Scan(&a.Kind, &a.Version, &a.ActivatedBy, &a.ActivatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查詢目前使用的 %s prompt 版本失敗: %w", kind, err)
+	}
+	return &a, nil
+}
+
+// SetActivePrompt 設定指定類型目前使用的 prompt 版本
+func (s *MySQLStore) SetActivePrompt(kind models.PromptKind, version, activatedBy string) error {
+	query := `INSERT INTO prompt_active_versions (kind, version, activated_by) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE version = VALUES(version), activated_by = VALUES(activated_by), activated_at = CURRENT_TIMESTAMP;`
+	if _, err := s.db.Exec(query, kind, version, activatedBy); err != nil {
+		return fmt.Errorf("設定 %s prompt 使用版本 '%s' 失敗: %w", kind, version, err)
+	}
+	return nil
+}
+
+// RecordGeminiUsage 記錄一次 Gemini 呼叫的 token 用量
+func (s *MySQLStore) RecordGeminiUsage(u *models.GeminiUsage) error {
+	query := `INSERT INTO gemini_usage (video_id, source_name, task, model_name, prompt_version, prompt_tokens, candidate_tokens, total_tokens, success)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`
+	_, err := s.db.Exec(query, u.VideoID,
+		sql.NullString{String: u.SourceName, Valid: u.SourceName != ""},
+		u.Task, u.ModelName,
+		sql.NullString{String: u.PromptVersion, Valid: u.PromptVersion != ""},
+		u.PromptTokens, u.CandidateTokens, u.TotalTokens, u.Success)
+	if err != nil {
+		return fmt.Errorf("記錄 Gemini 用量失敗 (VideoID: %d, 模型: %s): %w", u.VideoID.Int64, u.ModelName, err)
+	}
+	return nil
+}
+
+// GetUsageAggregates 依日期、來源、分析類型、模型與 prompt 版本彙總 [from, to) 期間的 Gemini 用量
+func (s *MySQLStore) GetUsageAggregates(from, to time.Time) ([]models.UsageAggregate, error) {
+	query := `SELECT DATE_FORMAT(created_at, '%Y-%m-%d') AS day, IFNULL(source_name, ''), task, model_name, IFNULL(prompt_version, ''),
+			COUNT(*), SUM(CASE WHEN success THEN 0 ELSE 1 END), SUM(prompt_tokens), SUM(candidate_tokens), SUM(total_tokens)
+		FROM gemini_usage
+		WHERE created_at >= ? AND created_at < ?
+		GROUP BY day, source_name, task, model_name, prompt_version
+		ORDER BY day;`
+	rows, err := s.db.Query(query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("查詢 Gemini 用量失敗: %w", err)
+	}
+	defer rows.Close()
+	var aggs []models.UsageAggregate
+	for rows.Next() {
+		var a models.UsageAggregate
+		if err := rows.Scan(&a.Day, &a.SourceName, &a.Task, &a.ModelName, &a.PromptVersion,
+			&a.Calls, &a.FailedCalls, &a.PromptTokens, &a.CandidateTokens, &a.TotalTokens); err != nil {
+			log.Printf("錯誤：掃描 Gemini 用量失敗: %v", err)
+			continue
+		}
+		aggs = append(aggs, a)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("處理 Gemini 用量查詢結果集時發生錯誤: %w", err)
+	}
+	return aggs, nil
+}
+
+// GetNASManifest 查詢完整的 nas_files 清單，並依 source_name/source_id 帶出對應影片的分析狀態
+func (s *MySQLStore) GetNASManifest() ([]models.NASFile, error) {
+	query := `SELECT f.id, f.path, f.kind, f.source_name, f.source_id, f.size, f.mtime_ns,
+			IFNULL(f.sha256, ''), IFNULL(f.analyzed_sha256, ''), f.needs_reanalysis, f.video_id, f.updated_at,
+			IFNULL(v.analysis_status, '')
+		FROM nas_files f
+		LEFT JOIN videos v ON v.source_name = f.source_name AND v.source_id = f.source_id
+		ORDER BY f.path;`
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("查詢 NAS 檔案清單失敗: %w", err)
+	}
+	defer rows.Close()
+	var files []models.NASFile
+	for rows.Next() {
+		var f models.NASFile
+		if err := rows.Scan(&f.ID, &f.Path, &f.Kind, &f.SourceName, &f.SourceID, &f.Size, &f.ModTimeNs,
+			&f.SHA256, &f.AnalyzedSHA256, &f.NeedsReanalysis, &f.VideoID, &f.UpdatedAt, &f.AnalysisStatus); err != nil {
+			return nil, fmt.Errorf("掃描 NAS 檔案清單失敗: %w", err)
+		}
+		files = append(files, f)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("處理 NAS 檔案清單查詢結果集時發生錯誤: %w", err)
+	}
+	return files, nil
+}
+
+// SaveNASFiles 在同一個交易中新增或更新 (依 path) 多筆 nas_files 紀錄；analyzed_sha256 與 video_id 不會被清除
+func (s *MySQLStore) SaveNASFiles(files []models.NASFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("開始 NAS 檔案清單交易失敗: %w", err)
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(`INSERT INTO nas_files (path, kind, source_name, source_id, size, mtime_ns, sha256, analyzed_sha256, needs_reanalysis)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE kind = VALUES(kind), source_name = VALUES(source_name), source_id = VALUES(source_id),
+			size = VALUES(size), mtime_ns = VALUES(mtime_ns), sha256 = VALUES(sha256),
+			analyzed_sha256 = IFNULL(VALUES(analyzed_sha256), analyzed_sha256), needs_reanalysis = VALUES(needs_reanalysis);`)
+	if err != nil {
+		return fmt.Errorf("準備 NAS 檔案清單語句失敗: %w", err)
+	}
+	defer stmt.Close()
+	for _, f := range files {
+		if _, err := stmt.Exec(f.Path, f.Kind, f.SourceName, f.SourceID, f.Size, f.ModTimeNs,
+			sql.NullString{String: f.SHA256, Valid: f.SHA256 != ""},
+			sql.NullString{String: f.AnalyzedSHA256, Valid: f.AnalyzedSHA256 != ""},
+			f.NeedsReanalysis); err != nil {
+			return fmt.Errorf("儲存 NAS 檔案紀錄 '%s' 失敗: %w", f.Path, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交 NAS 檔案清單交易失敗: %w", err)
+	}
+	return nil
+}
+
+// DeleteNASFiles 刪除已不存在於 NAS 的 nas_files 紀錄
+func (s *MySQLStore) DeleteNASFiles(ids []int64) error {
+	for start := 0; start < len(ids); start += 500 {
+		end := start + 500
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := ids[start:end]
+		args := make([]interface{}, len(batch))
+		for i, id := range batch {
+			args[i] = id
+		}
+		query := `DELETE FROM nas_files WHERE id IN (?` + strings.Repeat(", ?", len(batch)-1) + `);`
+		if _, err := s.db.Exec(query, args...); err != nil {
+			return fmt.Errorf("刪除 NAS 檔案紀錄失敗: %w", err)
+		}
+	}
+	return nil
+}
+
+// LinkNASFiles 將 nas_files 連結到對應的影片紀錄；影片已完成文本元數據分析但檔案尚無分析基準 (例如首次建立清單)
+// 時，以目前的 sha256 作為基準，之後檔案內容改變才會被標記為需重新分析
+func (s *MySQLStore) LinkNASFiles() error {
+	query := `UPDATE nas_files f
+		JOIN videos v ON v.source_name = f.source_name AND v.source_id = f.source_id
+		SET f.video_id = v.id,
+			f.analyzed_sha256 = CASE
+				WHEN f.analyzed_sha256 IS NULL AND f.sha256 IS NOT NULL AND v.analysis_status IN (?, ?, ?, ?) THEN f.sha256
+				ELSE f.analyzed_sha256 END
+		WHERE f.video_id IS NULL OR f.video_id <> v.id OR (f.analyzed_sha256 IS NULL AND f.sha256 IS NOT NULL);`
+	_, err := s.db.Exec(query, models.StatusMetadataExtracted, models.StatusProcessing, models.StatusCompleted, models.StatusVideoAnalysisFailed)
+	if err != nil {
+		return fmt.Errorf("連結 NAS 檔案與影片紀錄失敗: %w", err)
+	}
+	return nil
+}
+
+// MarkNASFilesAnalyzed 在影片完成文本元數據分析後，記錄其檔案目前的 sha256 為分析基準並清除重新分析標記
+func (s *MySQLStore) MarkNASFilesAnalyzed(sourceName, sourceID string, videoID int64) error {
+	query := `UPDATE nas_files SET video_id = ?, analyzed_sha256 = sha256, needs_reanalysis = FALSE
+		WHERE source_name = ? AND source_id = ? AND kind <> ?;`
+	if _, err := s.db.Exec(query, videoID, sourceName, sourceID, models.NASFileDir); err != nil {
+		return fmt.Errorf("更新影片 %s/%s 的 NAS 檔案分析基準失敗: %w", sourceName, sourceID, err)
+	}
+	return nil
+}
+
+// SaveVideoFiles 以 files 取代影片在 video_files 中的版本清單 (不在 files 中的舊紀錄會被刪除)
+func (s *MySQLStore) SaveVideoFiles(videoID int64, files []models.VideoFile) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("開始儲存影片 ID %d 版本清單的交易失敗: %w", videoID, err)
+	}
+	defer tx.Rollback()
+
+	upsert := `
+		INSERT INTO video_files (video_id, path, role, width, height, size, mod_time)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE role = VALUES(role), width = VALUES(width), height = VALUES(height),
+			size = VALUES(size), mod_time = VALUES(mod_time);`
+	paths := make([]interface{}, 0, len(files)+1)
+	paths = append(paths, videoID)
+	for _, f := range files {
+		width := sql.NullInt64{Int64: int64(f.Width), Valid: f.Width > 0}
+		height := sql.NullInt64{Int64: int64(f.Height), Valid: f.Height > 0}
+		modTime := sql.NullTime{Time: f.ModTime, Valid: !f.ModTime.IsZero()}
+		if _, err := tx.Exec(upsert, videoID, f.Path, f.Role, width, height, f.Size, modTime); err != nil {
+			return fmt.Errorf("儲存影片 ID %d 的版本 '%s' 失敗: %w", videoID, f.Path, err)
+		}
+		paths = append(paths, f.Path)
+	}
+	del := "DELETE FROM video_files WHERE video_id = ?"
+	if len(files) > 0 {
+		del += " AND path NOT IN (?" + strings.Repeat(", ?", len(files)-1) + ")"
+	}
+	if _, err := tx.Exec(del, paths...); err != nil {
+		return fmt.Errorf("刪除影片 ID %d 已移除的版本失敗: %w", videoID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交影片 ID %d 版本清單失敗: %w", videoID, err)
+	}
+	return nil
+}
+
+// GetVideoFiles 批次查詢多部影片的版本清單，回傳以 VideoID 為鍵的 map (沒有紀錄的影片不在 map 中)
+func (s *MySQLStore) GetVideoFiles(videoIDs []int64) (map[int64][]models.VideoFile, error) {
+	files := make(map[int64][]models.VideoFile)
+	if len(videoIDs) == 0 {
+		return files, nil
+	}
+	placeholders := make([]string, len(videoIDs))
+	args := make([]interface{}, len(videoIDs))
+	for i, id := range videoIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	rows, err := s.db.Query(`SELECT id, video_id, path, role, width, height, size, mod_time FROM video_files
+		WHERE video_id IN (`+strings.Join(placeholders, ", ")+`) ORDER BY video_id, id;`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查詢影片版本清單失敗: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var f models.VideoFile
+		var width, height sql.NullInt64
+		var modTime sql.NullTime
+		if err := rows.Scan(&f.ID, &f.VideoID, &f.Path, &f.Role, &width, &height, &f.Size, &modTime); err != nil {
+			log.Printf("錯誤：掃描影片版本紀錄失敗: %v", err)
+			continue
+		}
+		f.Width, f.Height, f.ModTime = int(width.Int64), int(height.Int64), modTime.Time
+		files[f.VideoID] = append(files[f.VideoID], f)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("處理影片版本查詢結果集時發生錯誤: %w", err)
+	}
+	return files, nil
+}
+
+// GetRetentionItems 查詢保留政策評估的影片 (已完成或已封存)，評級以編輯修訂優先；版本清單需另以 GetVideoFiles 查詢
+func (s *MySQLStore) GetRetentionItems() ([]models.RetentionItem, error) {
+	query := `
+		SELECT v.id, v.source_name, v.source_id, v.nas_path, v.fetched_at, v.analysis_status, v.pinned,
+			COALESCE(rv.overall_rating, JSON_UNQUOTE(JSON_EXTRACT(ar.importance_score, '$.overall_rating')), '')
+		FROM videos v
+		LEFT JOIN analysis_results ar ON ar.video_id = v.id
+		LEFT JOIN analysis_reviews rv ON rv.video_id = v.id
+		WHERE v.analysis_status IN (?, ?)
+		ORDER BY v.fetched_at, v.id;`
+	rows, err := s.db.Query(query, models.StatusCompleted, models.StatusArchived)
+	if err != nil {
+		return nil, fmt.Errorf("查詢保留政策影片失敗: %w", err)
+	}
+	defer rows.Close()
+	var items []models.RetentionItem
+	for rows.Next() {
+		var item models.RetentionItem
+		if err := rows.Scan(&item.VideoID, &item.SourceName, &item.SourceID, &item.NASPath, &item.FetchedAt, &item.Status, &item.Pinned, &item.Rating); err != nil {
+			log.Printf("錯誤：掃描保留政策影片失敗: %v", err)
+			continue
+		}
+		item.Rating = strings.ToUpper(strings.TrimSpace(item.Rating))
+		items = append(items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("處理保留政策影片查詢結果集時發生錯誤: %w", err)
+	}
+	return items, nil
+}
+
+// ArchiveVideo 將影片標記為 archived，並將 reason 附加至封存原因
+func (s *MySQLStore) ArchiveVideo(videoID int64, reason string) error {
+	query := `UPDATE videos SET analysis_status = ?, archived_at = NOW(),
+		archive_reason = CONCAT_WS('; ', NULLIF(archive_reason, ''), ?) WHERE id = ?;`
+	if _, err := s.db.Exec(query, models.StatusArchived, reason, videoID); err != nil {
+		return fmt.Errorf("封存影片 ID %d 失敗: %w", videoID, err)
+	}
+	return nil
+}
+
+// SetVideoPinned 設定影片的編輯釘選狀態；影片不存在時回傳 sql.ErrNoRows
+func (s *MySQLStore) SetVideoPinned(videoID int64, pinned bool, by string) error {
+	var pinnedBy sql.NullString
+	var pinnedAt sql.NullTime
+	if pinned {
+		pinnedBy = sql.NullString{String: by, Valid: true}
+		pinnedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+	res, err := s.db.Exec("UPDATE videos SET pinned = ?, pinned_by = ?, pinned_at = ? WHERE id = ?;", pinned, pinnedBy, pinnedAt, videoID)
+	if err != nil {
+		return fmt.Errorf("更新影片 ID %d 的釘選狀態失敗: %w", videoID, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		var exists int
+		if err := s.db.QueryRow("SELECT 1 FROM videos WHERE id = ?;", videoID).Scan(&exists); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetVideosPendingThumbnails 查詢尚未產生縮圖的影片 (最新下載的優先)；不含已封存與只有 TXT 的影片
+func (s *MySQLStore) GetVideosPendingThumbnails(limit int) ([]models.Video, error) {
+	query := `SELECT id, source_name, source_id, nas_path, duration_secs FROM videos
+		WHERE thumbnails_at IS NULL AND analysis_status <> ? AND LOWER(nas_path) NOT LIKE '%.txt'
+		ORDER BY fetched_at DESC, id DESC LIMIT ?;`
+	rows, err := s.db.Query(query, models.StatusArchived, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查詢待產生縮圖的影片失敗: %w", err)
+	}
+	defer rows.Close()
+	var videos []models.Video
+	for rows.Next() {
+		var v models.Video
+		if err := rows.Scan(&v.ID, &v.SourceName, &v.SourceID, &v.NASPath, &v.DurationSecs); err != nil {
+			log.Printf("錯誤：掃描待產生縮圖的影片失敗: %v", err)
+			continue
+		}
+		videos = append(videos, v)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("處理待產生縮圖影片查詢結果集時發生錯誤: %w", err)
+	}
+	return videos, nil
+}
+
+// SaveVideoThumbnails 記錄影片的海報與縮圖表路徑 (產生失敗者為 NULL) 及產生時間
+func (s *MySQLStore) SaveVideoThumbnails(videoID int64, posterPath, contactSheetPath sql.NullString) error {
+	query := "UPDATE videos SET poster_path = ?, contact_sheet_path = ?, thumbnails_at = NOW() WHERE id = ?;"
+	if _, err := s.db.Exec(query, posterPath, contactSheetPath, videoID); err != nil {
+		return fmt.Errorf("更新影片 ID %d 的縮圖路徑失敗: %w", videoID, err)
+	}
+	return nil
+}
+
+// GetVideosPendingWebProxy 查詢尚未檢查是否需要播放版本的影片 (最新下載的優先)；不含已封存與只有 TXT 的影片
+func (s *MySQLStore) GetVideosPendingWebProxy(limit int) ([]models.Video, error) {
+	query := `SELECT id, source_name, source_id, nas_path, duration_secs FROM videos
+		WHERE web_proxy_at IS NULL AND analysis_status <> ? AND LOWER(nas_path) NOT LIKE '%.txt'
+		ORDER BY fetched_at DESC, id DESC LIMIT ?;`
+	rows, err := s.db.Query(query, models.StatusArchived, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查詢待產生播放版本的影片失敗: %w", err)
+	}
+	defer rows.Close()
+	var videos []models.Video
+	for rows.Next() {
+		var v models.Video
+		if err := rows.Scan(&v.ID, &v.SourceName, &v.SourceID, &v.NASPath, &v.DurationSecs); err != nil {
+			log.Printf("錯誤：掃描待產生播放版本的影片失敗: %v", err)
+			continue
+		}
+		videos = append(videos, v)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("處理待產生播放版本影片查詢結果集時發生錯誤: %w", err)
+	}
+	return videos, nil
+}
+
+// SaveVideoWebProxy 記錄影片的播放版本與其原始檔 (不需轉檔或失敗時 proxyPath 為 NULL) 及檢查時間
+func (s *MySQLStore) SaveVideoWebProxy(videoID int64, sourcePath, proxyPath sql.NullString) error {
+	query := "UPDATE videos SET web_proxy_source = ?, web_proxy_path = ?, web_proxy_at = NOW() WHERE id = ?;"
+	if _, err := s.db.Exec(query, sourcePath, proxyPath, videoID); err != nil {
+		return fmt.Errorf("更新影片 ID %d 的播放版本失敗: %w", videoID, err)
+	}
+	return nil
+}
+
+// GetWebProxyPath 依原始檔相對路徑查詢已產生的播放版本；沒有時回傳 false
+func (s *MySQLStore) GetWebProxyPath(sourcePath string) (string, bool, error) {
+	var proxyPath string
+	err := s.db.QueryRow("SELECT web_proxy_path FROM videos WHERE web_proxy_source = ? AND web_proxy_path IS NOT NULL LIMIT 1;", sourcePath).Scan(&proxyPath)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("查詢 '%s' 的播放版本失敗: %w", sourcePath, err)
+	}
+	return proxyPath, true, nil
+}
+
+// IsVideoRestricted 查詢 NAS 目錄 <sourceName>/<sourceID> 所屬的影片是否有通訊社限制條件 (restrictions 非空白)；找不到影片時回傳 false
+func (s *MySQLStore) IsVideoRestricted(sourceName, sourceID string) (bool, error) {
+	var restricted bool
+	err := s.db.QueryRow("SELECT TRIM(COALESCE(restrictions, '')) <> '' FROM videos WHERE source_name = ? AND source_id = ? LIMIT 1;", sourceName, sourceID).Scan(&restricted)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("查詢影片 %s/%s 的限制條件失敗: %w", sourceName, sourceID, err)
+	}
+	return restricted, nil
+}
+
+// SaveMediaInfo 新增或更新影片的技術資訊 (每部影片一筆)
+func (s *MySQLStore) SaveMediaInfo(info *models.MediaInfo) error {
+	query := `
+		INSERT INTO video_media_info (video_id, path, duration_ms, width, height, video_codec, audio_codec, frame_rate,
+			audio_channels, size, probed_with, txt_duration_secs, duration_mismatch, probed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE path = VALUES(path), duration_ms = VALUES(duration_ms), width = VALUES(width),
+			height = VALUES(height), video_codec = VALUES(video_codec), audio_codec = VALUES(audio_codec),
+			frame_rate = VALUES(frame_rate), audio_channels = VALUES(audio_channels), size = VALUES(size),
+			probed_with = VALUES(probed_with), txt_duration_secs = VALUES(txt_duration_secs),
+			duration_mismatch = VALUES(duration_mismatch), probed_at = VALUES(probed_at);`
+	_, err := s.db.Exec(query, info.VideoID, info.Path, info.DurationMs,
+		sql.NullInt64{Int64: int64(info.Width), Valid: info.Width > 0},
+		sql.NullInt64{Int64: int64(info.Height), Valid: info.Height > 0},
+		sql.NullString{String: info.VideoCodec, Valid: info.VideoCodec != ""},
+		sql.NullString{String: info.AudioCodec, Valid: info.AudioCodec != ""},
+		sql.NullFloat64{Float64: info.FrameRate, Valid: info.FrameRate > 0},
+		sql.NullInt64{Int64: int64(info.AudioChannels), Valid: info.AudioChannels > 0},
+		info.Size, info.ProbedWith, info.TxtDurationSecs, info.DurationMismatch, info.ProbedAt)
+	if err != nil {
+		return fmt.Errorf("儲存影片 ID %d 的技術資訊失敗: %w", info.VideoID, err)
+	}
+	return nil
+}
+
+// GetMediaInfo 批次查詢多部影片的技術資訊，回傳以 VideoID 為鍵的 map (沒有紀錄的影片不在 map 中)
+func (s *MySQLStore) GetMediaInfo(videoIDs []int64) (map[int64]*models.MediaInfo, error) {
+	infos := make(map[int64]*models.MediaInfo)
+	if len(videoIDs) == 0 {
+		return infos, nil
+	}
+	placeholders := make([]string, len(videoIDs))
+	args := make([]interface{}, len(videoIDs))
+	for i, id := range videoIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	rows, err := s.db.Query(`SELECT video_id, path, duration_ms, width, height, video_codec, audio_codec, frame_rate,
+			audio_channels, size, probed_with, txt_duration_secs, duration_mismatch, probed_at
+		FROM video_media_info WHERE video_id IN (`+strings.Join(placeholders, ", ")+`);`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查詢影片技術資訊失敗: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var info models.MediaInfo
+		var width, height, channels sql.NullInt64
+		var videoCodec, audioCodec sql.NullString
+		var frameRate sql.NullFloat64
+		if err := rows.Scan(&info.VideoID, &info.Path, &info.DurationMs, &width, &height, &videoCodec, &audioCodec, &frameRate,
+			&channels, &info.Size, &info.ProbedWith, &info.TxtDurationSecs, &info.DurationMismatch, &info.ProbedAt); err != nil {
+			log.Printf("錯誤：掃描影片技術資訊失敗: %v", err)
+			continue
+		}
+		info.Width, info.Height, info.AudioChannels = int(width.Int64), int(height.Int64), int(channels.Int64)
+		info.VideoCodec, info.AudioCodec, info.FrameRate = videoCodec.String, audioCodec.String, frameRate.Float64
+		infos[info.VideoID] = &info
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("處理影片技術資訊查詢結果集時發生錯誤: %w", err)
+	}
+	return infos, nil
+}
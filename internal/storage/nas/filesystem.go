@@ -2,17 +2,22 @@ package nas
 
 import (
 	"AiHackathon-admin/internal/config" // 引入我們定義的 config 套件
+	"AiHackathon-admin/internal/models"
+	"AiHackathon-admin/internal/storage/fsutil"
 	"fmt"
-	"io/ioutil" // 用於讀寫檔案
+	"io"
+	"io/ioutil" // 用於讀取目錄
 	"log"
 	"os"            // 用於檔案系統操作，如建立目錄、檢查檔案是否存在
 	"path/filepath" // 用於處理檔案路徑，確保跨平台相容性
+	"strings"       // 用於檢查路徑遍歷
 	"time"          // 用於根據日期建立子目錄 (可選)
 )
 
 // FileSystemStorage 結構負責與本地檔案系統互動
 type FileSystemStorage struct {
-	basePath string // 從設定檔讀取的影片儲存根路徑
+	basePath     string // 從設定檔讀取的影片儲存根路徑
+	minFreeBytes int64  // 寫入影片後需保留的磁碟剩餘空間 (nas.minFreeMB)
 }
 
 // NewFileSystemStorage 建立一個 FileSystemStorage 實例
@@ -41,7 +46,7 @@ func NewFileSystemStorage(nasCfg config.NASConfig) (*FileSystemStorage, error) {
 	}
 
 	log.Printf("資訊：FileSystemStorage 初始化成功，影片根路徑設定為: %s", absBasePath)
-	return &FileSystemStorage{basePath: absBasePath}, nil
+	return &FileSystemStorage{basePath: absBasePath, minFreeBytes: int64(nasCfg.MinFreeMB) << 20}, nil
 }
 
 // buildTargetPath 根據來源名稱、來源ID和原始檔名構造一個建議的儲存路徑
@@ -62,18 +67,18 @@ func (fs *FileSystemStorage) buildTargetPath(sourceName, sourceID, originalFileN
 	return filepath.Join(targetDir, originalFileName)
 }
 
-// SaveVideo 將影片數據儲存到本地檔案系統 (NAS)
+// SaveVideo 將影片串流寫入本地檔案系統 (NAS)：先確認磁碟剩餘空間，寫入同目錄的暫存檔並同步計算 sha256，完成後才 rename 為正式檔名
 // sourceName: 影片來源 (e.g., "ap", "reuters", "youtube", "cnn_nhk_recordings")
 // sourceID: 影片在來源系統的唯一 ID (用於建立獨特的檔案名或子目錄)
 // originalFileName: 原始影片檔名 (例如 "news_clip.mp4")
-// videoData: 影片的二進位數據
-// 返回儲存後的相對路徑 (相對於 basePath) 或絕對路徑，以及可能的錯誤
-func (fs *FileSystemStorage) SaveVideo(sourceName string, sourceID string, originalFileName string, videoData []byte) (string, error) {
+// r: 影片內容；size: 影片大小 (bytes)，未知時傳入 -1
+// 返回儲存後的相對路徑 (相對於 basePath)、大小與 sha256
+func (fs *FileSystemStorage) SaveVideo(sourceName string, sourceID string, originalFileName string, r io.Reader, size int64) (models.StoredFile, error) {
 	if sourceName == "" || sourceID == "" || originalFileName == "" {
-		return "", fmt.Errorf("SaveVideo 參數 sourceName, sourceID, originalFileName 不得為空")
+		return models.StoredFile{}, fmt.Errorf("SaveVideo 參數 sourceName, sourceID, originalFileName 不得為空")
 	}
-	if len(videoData) == 0 {
-		return "", fmt.Errorf("SaveVideo 參數 videoData 不得為空")
+	if r == nil || size == 0 {
+		return models.StoredFile{}, fmt.Errorf("SaveVideo 參數 r 不得為空")
 	}
 
 	targetPath := fs.buildTargetPath(sourceName, sourceID, originalFileName)
@@ -85,18 +90,22 @@ func (fs *FileSystemStorage) SaveVideo(sourceName string, sourceID string, origi
 	if _, err := os.Stat(targetDir); os.IsNotExist(err) {
 		log.Printf("資訊：目標目錄 '%s' 不存在，正在嘗試建立...", targetDir)
 		if err := os.MkdirAll(targetDir, os.ModePerm); err != nil {
-			return "", fmt.Errorf("無法建立目標目錄 '%s': %w", targetDir, err)
+			return models.StoredFile{}, fmt.Errorf("無法建立目標目錄 '%s': %w", targetDir, err)
 		}
 		log.Printf("資訊：目標目錄 '%s' 建立成功。", targetDir)
 	}
+	if err := fsutil.EnsureFreeSpace(targetDir, size, fs.minFreeBytes); err != nil {
+		return models.StoredFile{}, err
+	}
 
 	// 寫入檔案
 	log.Printf("資訊：正在將影片儲存到 '%s'", targetPath)
-	if err := ioutil.WriteFile(targetPath, videoData, 0644); err != nil { // 0644 檔案權限
-		return "", fmt.Errorf("無法寫入影片檔案到 '%s': %w", targetPath, err)
+	written, sum, err := fsutil.WriteAtomic(targetPath, r, size)
+	if err != nil {
+		return models.StoredFile{}, fmt.Errorf("無法寫入影片檔案: %w", err)
 	}
 
-	log.Printf("資訊：影片成功儲存到 '%s'", targetPath)
+	log.Printf("資訊：影片成功儲存到 '%s' (%d bytes, sha256: %s)", targetPath, written, sum)
 
 	// 回傳相對於 basePath 的路徑，方便資料庫儲存和後續查找
 	relativePath, err := filepath.Rel(fs.basePath, targetPath)
@@ -104,10 +113,30 @@ func (fs *FileSystemStorage) SaveVideo(sourceName string, sourceID string, origi
 		// 如果無法取得相對路徑 (理論上不應該發生，因為 targetPath 是基於 basePath 構造的)
 		// 則回傳絕對路徑，並記錄一個警告
 		log.Printf("警告：無法取得相對於 basePath '%s' 的相對路徑，將回傳絕對路徑 '%s': %v", fs.basePath, targetPath, err)
-		return targetPath, nil
+		relativePath = targetPath
 	}
 
-	return relativePath, nil
+	return models.StoredFile{Path: relativePath, Size: written, SHA256: sum}, nil
+}
+
+// SaveFile 將內容寫入 basePath 下的指定相對路徑 (例如影片旁的縮圖)，同樣先寫入暫存檔再 rename；拒絕 basePath 以外的路徑
+func (fs *FileSystemStorage) SaveFile(relativePath string, r io.Reader, size int64) (models.StoredFile, error) {
+	targetPath := filepath.Join(fs.basePath, relativePath)
+	if rel, err := filepath.Rel(fs.basePath, targetPath); err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return models.StoredFile{}, fmt.Errorf("無效的檔案路徑 '%s'", relativePath)
+	}
+	targetDir := filepath.Dir(targetPath)
+	if err := os.MkdirAll(targetDir, os.ModePerm); err != nil {
+		return models.StoredFile{}, fmt.Errorf("無法建立目標目錄 '%s': %w", targetDir, err)
+	}
+	if err := fsutil.EnsureFreeSpace(targetDir, size, fs.minFreeBytes); err != nil {
+		return models.StoredFile{}, err
+	}
+	written, sum, err := fsutil.WriteAtomic(targetPath, r, size)
+	if err != nil {
+		return models.StoredFile{}, fmt.Errorf("無法寫入檔案 '%s': %w", targetPath, err)
+	}
+	return models.StoredFile{Path: filepath.ToSlash(filepath.Clean(relativePath)), Size: written, SHA256: sum}, nil
 }
 
 // GetVideoAbsolutePath 根據儲存在資料庫中的相對路徑，取得影片的絕對路徑
@@ -129,21 +158,18 @@ func (fs *FileSystemStorage) GetVideoAbsolutePath(relativePath string) (string,
 	return absPath, nil
 }
 
-// ReadVideo 從本地檔案系統 (NAS) 讀取影片內容
+// OpenVideo 開啟本地檔案系統 (NAS) 中的影片供串流讀取，呼叫端需關閉
 // filePathInDB: 儲存在資料庫中的影片路徑 (我們約定這是相對於 basePath 的路徑)
-func (fs *FileSystemStorage) ReadVideo(filePathInDB string) ([]byte, error) {
+func (fs *FileSystemStorage) OpenVideo(filePathInDB string) (io.ReadSeekCloser, error) {
 	absolutePath, err := fs.GetVideoAbsolutePath(filePathInDB)
 	if err != nil {
 		return nil, fmt.Errorf("無法獲取影片絕對路徑: %w", err)
 	}
-
-	log.Printf("資訊：正在從 '%s' 讀取影片...", absolutePath)
-	videoData, err := ioutil.ReadFile(absolutePath)
+	f, err := os.Open(absolutePath)
 	if err != nil {
-		return nil, fmt.Errorf("無法讀取影片檔案 '%s': %w", absolutePath, err)
+		return nil, fmt.Errorf("無法開啟影片檔案 '%s': %w", absolutePath, err)
 	}
-	log.Printf("資訊：影片 '%s' 讀取成功。", absolutePath)
-	return videoData, nil
+	return f, nil
 }
 
 // DeleteVideo 從本地檔案系統 (NAS) 刪除影片檔案 (可選功能)
@@ -0,0 +1,421 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	amzDateFormat    = "20060102T150405Z"
+)
+
+// Client 以 AWS Signature Version 4 簽署請求的最小 S3 API 客戶端 (相容 MinIO)
+type Client struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+	http      *http.Client
+	now       func() time.Time
+}
+
+// APIError S3 回傳的錯誤；404 視為 os.ErrNotExist
+type APIError struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *APIError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("S3 回應 HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("S3 回應 HTTP %d: %s (%s)", e.StatusCode, e.Code, e.Message)
+}
+
+// HTTPStatus 回傳 S3 回應的 HTTP 狀態碼
+func (e *APIError) HTTPStatus() int { return e.StatusCode }
+
+// Is 讓 errors.Is(err, os.ErrNotExist) 可判斷物件不存在
+func (e *APIError) Is(target error) bool {
+	return target == os.ErrNotExist && (e.StatusCode == http.StatusNotFound || e.Code == "NoSuchKey")
+}
+
+// ObjectInfo ListObjectsV2 / HeadObject 回傳的物件資訊
+type ObjectInfo struct {
+	Key          string    `xml:"Key"`
+	Size         int64     `xml:"Size"`
+	LastModified time.Time `xml:"LastModified"`
+	ETag         string    `xml:"ETag"`
+}
+
+// NewClient 建立 S3 客戶端；endpoint 例如 https://minio.example.com:9000
+func NewClient(endpoint, region, bucket, accessKey, secretKey string, pathStyle bool) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("無效的 S3 endpoint '%s'", endpoint)
+	}
+	if bucket == "" {
+		return nil, fmt.Errorf("S3 bucket 不得為空")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &Client{
+		endpoint: u, region: region, bucket: bucket, accessKey: accessKey, secretKey: secretKey, pathStyle: pathStyle,
+		http: &http.Client{}, now: time.Now,
+	}, nil
+}
+
+// objectURL 物件 (key 為空時為 bucket) 的網址，query 不含簽章
+func (c *Client) objectURL(key string, query url.Values) *url.URL {
+	u := *c.endpoint
+	objPath := "/" + key
+	if c.pathStyle {
+		objPath = "/" + c.bucket
+		if key != "" {
+			objPath += "/" + key
+		}
+	} else {
+		u.Host = c.bucket + "." + u.Host
+	}
+	u.Path = c.endpoint.Path + objPath
+	u.RawPath = uriEncode(c.endpoint.Path, false) + uriEncode(objPath, false)
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+// do 送出已簽署的請求；回應狀態非 2xx 時解析 S3 錯誤並關閉回應
+func (c *Client) do(method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u := c.objectURL(key, query)
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	req.ContentLength = int64(len(body))
+	c.sign(req, payloadHash)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("S3 %s '%s' 請求失敗: %w", method, key, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, parseError(resp)
+	}
+	return resp, nil
+}
+
+func parseError(resp *http.Response) error {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	_ = xml.Unmarshal(data, apiErr)
+	return apiErr
+}
+
+// sign 以標頭方式簽署請求 (簽署 host、range 與所有 x-amz-* 標頭)
+func (c *Client) sign(req *http.Request, payloadHash string) {
+	now := c.now().UTC()
+	amzDate := now.Format(amzDateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, vs := range req.Header {
+		lk := strings.ToLower(k)
+		if lk == "range" || strings.HasPrefix(lk, "x-amz-") {
+			headers[lk] = strings.TrimSpace(strings.Join(vs, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	scope := now.Format("20060102") + "/" + c.region + "/s3/aws4_request"
+	canonicalRequest := strings.Join([]string{
+		req.Method, req.URL.EscapedPath(), req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+	signature := c.signature(now, scope, amzDate, canonicalRequest)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", c.accessKey, scope, signedHeaders, signature))
+}
+
+// PresignGet 產生在 expiry 內有效的 GET 預先簽署網址 (簽章放在 query string)
+func (c *Client) PresignGet(key string, expiry time.Duration) string {
+	now := c.now().UTC()
+	amzDate := now.Format(amzDateFormat)
+	scope := now.Format("20060102") + "/" + c.region + "/s3/aws4_request"
+	query := url.Values{
+		"X-Amz-Algorithm":     {"AWS4-HMAC-SHA256"},
+		"X-Amz-Credential":    {c.accessKey + "/" + scope},
+		"X-Amz-Date":          {amzDate},
+		"X-Amz-Expires":       {strconv.Itoa(int(expiry.Seconds()))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	u := c.objectURL(key, query)
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet, u.EscapedPath(), u.RawQuery, "host:" + u.Host + "\n", "host", unsignedPayload,
+	}, "\n")
+	u.RawQuery += "&X-Amz-Signature=" + c.signature(now, scope, amzDate, canonicalRequest)
+	return u.String()
+}
+
+func (c *Client) signature(now time.Time, scope, amzDate, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), now.Format("20060102"))
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// uriEncode 依 SigV4 規則編碼 (只保留 A-Z a-z 0-9 - _ . ~)；encodeSlash 為 false 時保留 /
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch >= 'A' && ch <= 'Z', ch >= 'a' && ch <= 'z', ch >= '0' && ch <= '9', ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+// canonicalQuery 依鍵名排序並編碼 query string
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vs := append([]string(nil), query[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// PutObject 以單一請求上傳物件
+func (c *Client) PutObject(key string, data []byte, contentType string) error {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	resp, err := c.do(http.MethodPut, key, nil, header, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// PutObjectStream 串流上傳物件：內容不超過 partSize 時以單一請求上傳，否則以 multipart 逐段上傳，
+// 記憶體中最多只保留一段；任一段失敗時中止上傳。回傳上傳的 bytes
+func (c *Client) PutObjectStream(key string, r io.Reader, contentType string, partSize int) (int64, error) {
+	buf := make([]byte, partSize)
+	n, err := io.ReadFull(r, buf)
+	if err == nil {
+		// 內容剛好填滿一段時確認是否還有後續內容，沒有則仍以單一請求上傳
+		br := bufio.NewReader(r)
+		if _, err = br.Peek(1); err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		} else {
+			r, err = br, nil
+		}
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return int64(n), c.PutObject(key, buf[:n], contentType)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("讀取上傳內容失敗: %w", err)
+	}
+
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	resp, err := c.do(http.MethodPost, key, url.Values{"uploads": {""}}, header, nil)
+	if err != nil {
+		return 0, fmt.Errorf("建立 multipart 上傳失敗: %w", err)
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&initiated)
+	resp.Body.Close()
+	if err != nil || initiated.UploadID == "" {
+		return 0, fmt.Errorf("無法解析 multipart 上傳 ID: %v", err)
+	}
+
+	type part struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	var parts []part
+	var total int64
+	for number := 1; n > 0; number++ {
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {initiated.UploadID}}
+		resp, err := c.do(http.MethodPut, key, query, nil, buf[:n])
+		if err != nil {
+			c.abortMultipart(key, initiated.UploadID)
+			return 0, fmt.Errorf("上傳第 %d 段失敗: %w", number, err)
+		}
+		resp.Body.Close()
+		parts = append(parts, part{PartNumber: number, ETag: resp.Header.Get("ETag")})
+		total += int64(n)
+
+		n, err = io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			c.abortMultipart(key, initiated.UploadID)
+			return 0, fmt.Errorf("讀取上傳內容失敗: %w", err)
+		}
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		c.abortMultipart(key, initiated.UploadID)
+		return 0, err
+	}
+	resp, err = c.do(http.MethodPost, key, url.Values{"uploadId": {initiated.UploadID}}, nil, body)
+	if err != nil {
+		c.abortMultipart(key, initiated.UploadID)
+		return 0, fmt.Errorf("完成 multipart 上傳失敗: %w", err)
+	}
+	defer resp.Body.Close()
+	// CompleteMultipartUpload 可能在 HTTP 200 的回應中回傳錯誤
+	data, _ := io.ReadAll(resp.Body)
+	if bytes.Contains(data, []byte("<Error>")) {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		_ = xml.Unmarshal(data, apiErr)
+		return 0, fmt.Errorf("完成 multipart 上傳失敗: %w", apiErr)
+	}
+	return total, nil
+}
+
+func (c *Client) abortMultipart(key, uploadID string) {
+	if resp, err := c.do(http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil, nil); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// GetObject 讀取物件；rangeHeader 不為空時 (例如 "bytes=0-1023") 只讀取該範圍。呼叫端需關閉回應
+func (c *Client) GetObject(key, rangeHeader string) (*http.Response, error) {
+	header := http.Header{}
+	if rangeHeader != "" {
+		header.Set("Range", rangeHeader)
+	}
+	return c.do(http.MethodGet, key, nil, header, nil)
+}
+
+// HeadObject 取得物件資訊
+func (c *Client) HeadObject(key string) (ObjectInfo, error) {
+	resp, err := c.do(http.MethodHead, key, nil, nil, nil)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	resp.Body.Close()
+	info := ObjectInfo{Key: key, Size: resp.ContentLength, ETag: resp.Header.Get("ETag")}
+	info.LastModified, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return info, nil
+}
+
+// HeadBucket 確認 bucket 存在且認證有效
+func (c *Client) HeadBucket() error {
+	resp, err := c.do(http.MethodHead, "", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// DeleteObject 刪除物件；物件不存在時 S3 也回傳成功
+func (c *Client) DeleteObject(key string) error {
+	resp, err := c.do(http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ListObjects 以 ListObjectsV2 列出 prefix 下的所有物件 (自動處理分頁)
+func (c *Client) ListObjects(prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := c.do(http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("列出 '%s' 下的物件失敗: %w", prefix, err)
+		}
+		var result struct {
+			Contents              []ObjectInfo `xml:"Contents"`
+			IsTruncated           bool         `xml:"IsTruncated"`
+			NextContinuationToken string       `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("無法解析物件列表: %w", err)
+		}
+		objects = append(objects, result.Contents...)
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
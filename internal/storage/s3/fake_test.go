@@ -0,0 +1,255 @@
+package s3_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 為 path-style 的記憶體 S3 相容伺服器，支援本套件使用的 API：HeadBucket、ListObjectsV2 (分頁)、
+// Put/Get (Range)/Head/DeleteObject 與 multipart 上傳。只確認請求帶有 accessKey 的簽章與正確的內容雜湊，
+// 簽章本身的正確性由 client_test.go 以 AWS 文件範例驗證
+type fakeS3 struct {
+	*httptest.Server
+	bucket    string
+	accessKey string
+
+	mu         sync.Mutex
+	objects    map[string]fakeObject
+	uploads    map[string]map[int][]byte // uploadId -> partNumber -> 內容
+	uploadType map[string]string         // uploadId -> 建立上傳時的 Content-Type
+	nextUpload int
+	maxKeys    int      // ListObjectsV2 每頁最多的物件數
+	failPart   int      // 不為 0 時該 partNumber 的上傳回傳 500
+	aborted    []string // 已中止的 uploadId
+	requests   []string // "METHOD key"，bucket 層級的請求 key 為空
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+}
+
+func (o fakeObject) etag() string {
+	sum := sha256.Sum256(o.data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func newFakeS3(t *testing.T) *fakeS3 {
+	t.Helper()
+	f := &fakeS3{
+		bucket: "videos", accessKey: "test-access-key",
+		objects: make(map[string]fakeObject), uploads: make(map[string]map[int][]byte), uploadType: make(map[string]string), maxKeys: 1000,
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// put 直接寫入物件 (不經過客戶端)
+func (f *fakeS3) put(key string, data []byte, modTime time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[key] = fakeObject{data: data, modTime: modTime.UTC().Truncate(time.Second)}
+}
+
+func (f *fakeS3) object(key string) (fakeObject, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	o, ok := f.objects[key]
+	return o, ok
+}
+
+// count 回傳符合 "METHOD key" 的請求數
+func (f *fakeS3) count(request string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, r := range f.requests {
+		if r == request {
+			n++
+		}
+	}
+	return n
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func (f *fakeS3) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	query := r.URL.Query()
+	credential := query.Get("X-Amz-Credential") // 預先簽署網址
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		credential = strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 Credential=")
+		sum := sha256.Sum256(body)
+		if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+			writeS3Error(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch")
+			return
+		}
+	}
+	if !strings.HasPrefix(credential, f.accessKey+"/") || (query.Get("X-Amz-Credential") != "" && query.Get("X-Amz-Signature") == "") {
+		writeS3Error(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+
+	bucketPath := "/" + f.bucket
+	if r.URL.Path != bucketPath && !strings.HasPrefix(r.URL.Path, bucketPath+"/") {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, bucketPath), "/")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+key)
+
+	if key == "" {
+		switch {
+		case r.Method == http.MethodHead:
+		case r.Method == http.MethodGet && query.Get("list-type") == "2":
+			f.list(w, query.Get("prefix"), query.Get("continuation-token"))
+		default:
+			writeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+		}
+		return
+	}
+
+	uploadID := query.Get("uploadId")
+	switch r.Method {
+	case http.MethodPut:
+		if uploadID != "" {
+			number, _ := strconv.Atoi(query.Get("partNumber"))
+			parts, ok := f.uploads[uploadID]
+			if !ok {
+				writeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+				return
+			}
+			if number == f.failPart {
+				writeS3Error(w, http.StatusInternalServerError, "InternalError")
+				return
+			}
+			parts[number] = body
+			w.Header().Set("ETag", fmt.Sprintf(`"part-%d"`, number))
+			return
+		}
+		f.objects[key] = fakeObject{data: body, contentType: r.Header.Get("Content-Type"), modTime: time.Now().UTC().Truncate(time.Second)}
+	case http.MethodPost:
+		if _, ok := query["uploads"]; ok {
+			f.nextUpload++
+			id := fmt.Sprintf("upload-%d", f.nextUpload)
+			f.uploads[id] = make(map[int][]byte)
+			f.uploadType[id] = r.Header.Get("Content-Type")
+			fmt.Fprintf(w, "<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", f.bucket, key, id)
+			return
+		}
+		f.completeMultipart(w, key, uploadID, body)
+	case http.MethodDelete:
+		if uploadID != "" {
+			delete(f.uploads, uploadID)
+			f.aborted = append(f.aborted, uploadID)
+		} else {
+			delete(f.objects, key)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodGet, http.MethodHead:
+		o, ok := f.objects[key]
+		if !ok {
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if o.contentType != "" {
+			w.Header().Set("Content-Type", o.contentType)
+		}
+		w.Header().Set("ETag", o.etag())
+		// ServeContent 處理 Range (206/416)、Content-Length 與 Last-Modified
+		http.ServeContent(w, r, "", o.modTime, bytes.NewReader(o.data))
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func (f *fakeS3) completeMultipart(w http.ResponseWriter, key, uploadID string, body []byte) {
+	parts, ok := f.uploads[uploadID]
+	if !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+	var complete struct {
+		Parts []struct {
+			PartNumber int    `xml:"PartNumber"`
+			ETag       string `xml:"ETag"`
+		} `xml:"Part"`
+	}
+	if err := xml.Unmarshal(body, &complete); err != nil || len(complete.Parts) == 0 {
+		writeS3Error(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+	var data []byte
+	for i, p := range complete.Parts {
+		content, ok := parts[p.PartNumber]
+		if p.PartNumber != i+1 || !ok || p.ETag != fmt.Sprintf(`"part-%d"`, p.PartNumber) {
+			// 與 S3 相同，CompleteMultipartUpload 的錯誤可能在 HTTP 200 的回應中回傳
+			fmt.Fprint(w, "<Error><Code>InvalidPart</Code><Message>InvalidPart</Message></Error>")
+			return
+		}
+		data = append(data, content...)
+	}
+	delete(f.uploads, uploadID)
+	f.objects[key] = fakeObject{data: data, contentType: f.uploadType[uploadID], modTime: time.Now().UTC().Truncate(time.Second)}
+	fmt.Fprintf(w, "<CompleteMultipartUploadResult><Key>%s</Key></CompleteMultipartUploadResult>", key)
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, prefix, token string) {
+	var keys []string
+	for k := range f.objects {
+		if strings.HasPrefix(k, prefix) && k > token {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	truncated := len(keys) > f.maxKeys
+	if truncated {
+		keys = keys[:f.maxKeys]
+	}
+	type content struct {
+		Key          string `xml:"Key"`
+		Size         int    `xml:"Size"`
+		LastModified string `xml:"LastModified"`
+		ETag         string `xml:"ETag"`
+	}
+	result := struct {
+		XMLName               xml.Name  `xml:"ListBucketResult"`
+		Contents              []content `xml:"Contents"`
+		IsTruncated           bool      `xml:"IsTruncated"`
+		NextContinuationToken string    `xml:"NextContinuationToken,omitempty"`
+	}{IsTruncated: truncated}
+	for _, k := range keys {
+		o := f.objects[k]
+		result.Contents = append(result.Contents, content{Key: k, Size: len(o.data), LastModified: o.modTime.Format(time.RFC3339), ETag: o.etag()})
+	}
+	if truncated {
+		result.NextContinuationToken = keys[len(keys)-1]
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,281 @@
+package s3
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/models"
+	"AiHackathon-admin/internal/storage/fsutil"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ObjectStorage 以 S3 相容物件儲存 (MinIO 等) 實作 services.NASStorage。
+// 物件鍵為 prefix + 相對路徑 (與本地 NAS 目錄結構相同)；需要本地檔案的操作 (Gemini 上傳、ffmpeg 切割、讀取 TXT)
+// 透過 GetVideoAbsolutePath 將物件下載到 nas.videoPath 下的對應路徑作為快取
+type ObjectStorage struct {
+	client   *Client
+	prefix   string // 物件鍵前綴，空字串或以 / 結尾
+	cacheDir string // 本地快取根目錄 (nas.videoPath 的絕對路徑)
+	partSize int
+
+	minFreeBytes int64 // 下載至快取後需保留的磁碟剩餘空間 (nas.minFreeMB)
+}
+
+// NewObjectStorage 建立 ObjectStorage 實例，並確認 bucket 可存取
+func NewObjectStorage(nasCfg config.NASConfig) (*ObjectStorage, error) {
+	s3Cfg := nasCfg.S3
+	client, err := NewClient(s3Cfg.Endpoint, s3Cfg.Region, s3Cfg.Bucket, s3Cfg.AccessKeyID, s3Cfg.SecretAccessKey, s3Cfg.PathStyle)
+	if err != nil {
+		return nil, err
+	}
+	cacheDir, err := filepath.Abs(nasCfg.VideoPath)
+	if err != nil {
+		return nil, fmt.Errorf("無法取得 NAS videoPath 的絕對路徑 '%s': %w", nasCfg.VideoPath, err)
+	}
+	prefix := strings.TrimLeft(s3Cfg.Prefix, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	s := &ObjectStorage{client: client, prefix: prefix, cacheDir: cacheDir, partSize: s3Cfg.PartSizeMB << 20, minFreeBytes: int64(nasCfg.MinFreeMB) << 20}
+	if err := client.HeadBucket(); err != nil {
+		return nil, fmt.Errorf("無法存取 S3 bucket '%s': %w", s3Cfg.Bucket, err)
+	}
+	log.Printf("資訊：ObjectStorage 初始化成功，bucket: %s，prefix: '%s'，本地快取: %s", s3Cfg.Bucket, prefix, cacheDir)
+	return s, nil
+}
+
+// key 將相對路徑轉為物件鍵，拒絕路徑遍歷
+func (s *ObjectStorage) key(relativePath string) (string, error) {
+	rel := strings.TrimLeft(filepath.ToSlash(relativePath), "/")
+	cleaned := path.Clean(rel)
+	if rel == "" || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("無效的物件路徑 '%s'", relativePath)
+	}
+	return s.prefix + cleaned, nil
+}
+
+// SaveVideo 串流上傳影片至 <source>/<YYYY/MM/DD>/<sourceID>/<檔名> 並同步計算 sha256；超過 nas.s3.partSizeMB 時以 multipart 上傳
+// (物件在上傳完成前不可見)。size 未知時傳入 -1；回傳相對路徑、大小與 sha256
+func (s *ObjectStorage) SaveVideo(sourceName string, sourceID string, originalFileName string, r io.Reader, size int64) (models.StoredFile, error) {
+	if sourceName == "" || sourceID == "" || originalFileName == "" {
+		return models.StoredFile{}, fmt.Errorf("SaveVideo 參數 sourceName, sourceID, originalFileName 不得為空")
+	}
+	if r == nil || size == 0 {
+		return models.StoredFile{}, fmt.Errorf("SaveVideo 參數 r 不得為空")
+	}
+	rel := path.Join(path.Clean(sourceName), time.Now().Format("2006/01/02"), path.Clean(sourceID), path.Base(originalFileName))
+	key, err := s.key(rel)
+	if err != nil {
+		return models.StoredFile{}, err
+	}
+	contentType := mime.TypeByExtension(strings.ToLower(path.Ext(originalFileName)))
+	log.Printf("資訊：正在將影片上傳到 S3 '%s'", key)
+	hash := sha256.New()
+	written, err := s.client.PutObjectStream(key, io.TeeReader(r, hash), contentType, s.partSize)
+	if err == nil && size >= 0 && written != size {
+		err = fmt.Errorf("上傳 %d bytes，預期 %d bytes", written, size)
+		_ = s.client.DeleteObject(key)
+	}
+	if err != nil {
+		return models.StoredFile{}, fmt.Errorf("無法上傳影片到 '%s': %w", key, err)
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	log.Printf("資訊：影片成功上傳到 S3 '%s' (%d bytes, sha256: %s)", key, written, sum)
+	return models.StoredFile{Path: rel, Size: written, SHA256: sum}, nil
+}
+
+// SaveFile 串流上傳內容至指定相對路徑的物件 (例如影片旁的縮圖)，已存在時覆寫
+func (s *ObjectStorage) SaveFile(relativePath string, r io.Reader, size int64) (models.StoredFile, error) {
+	key, err := s.key(relativePath)
+	if err != nil {
+		return models.StoredFile{}, err
+	}
+	hash := sha256.New()
+	written, err := s.client.PutObjectStream(key, io.TeeReader(r, hash), mime.TypeByExtension(strings.ToLower(path.Ext(key))), s.partSize)
+	if err == nil && size >= 0 && written != size {
+		err = fmt.Errorf("上傳 %d bytes，預期 %d bytes", written, size)
+		_ = s.client.DeleteObject(key)
+	}
+	if err != nil {
+		return models.StoredFile{}, fmt.Errorf("無法上傳檔案到 '%s': %w", key, err)
+	}
+	return models.StoredFile{Path: strings.TrimPrefix(key, s.prefix), Size: written, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// GetVideoAbsolutePath 將物件下載至本地快取並回傳其路徑；快取檔案大小與修改時間與物件相同時直接使用。
+// 下載前確認快取目錄的磁碟剩餘空間，並先寫入暫存檔再 rename
+func (s *ObjectStorage) GetVideoAbsolutePath(relativePath string) (string, error) {
+	key, err := s.key(relativePath)
+	if err != nil {
+		return "", err
+	}
+	info, err := s.client.HeadObject(key)
+	if err != nil {
+		return "", fmt.Errorf("影片物件 '%s' 不可用: %w", key, err)
+	}
+	localPath := filepath.Join(s.cacheDir, filepath.FromSlash(strings.TrimPrefix(key, s.prefix)))
+	if st, err := os.Stat(localPath); err == nil && st.Size() == info.Size && st.ModTime().Equal(info.LastModified) {
+		return localPath, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(localPath), os.ModePerm); err != nil {
+		return "", fmt.Errorf("無法建立快取目錄 '%s': %w", filepath.Dir(localPath), err)
+	}
+	if err := fsutil.EnsureFreeSpace(filepath.Dir(localPath), info.Size, s.minFreeBytes); err != nil {
+		return "", err
+	}
+	resp, err := s.client.GetObject(key, "")
+	if err != nil {
+		return "", fmt.Errorf("下載物件 '%s' 失敗: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if _, _, err := fsutil.WriteAtomic(localPath, resp.Body, info.Size); err != nil {
+		return "", fmt.Errorf("下載物件 '%s' 至快取失敗: %w", key, err)
+	}
+	if !info.LastModified.IsZero() {
+		_ = os.Chtimes(localPath, info.LastModified, info.LastModified)
+	}
+	log.Printf("資訊：[ObjectStorage] 已下載 '%s' 至本地快取 (%d bytes)。", key, info.Size)
+	return localPath, nil
+}
+
+// OpenVideo 開啟物件供串流讀取：以 Range 請求依需要讀取，Seek 後從新位置重新請求，不會下載整個物件
+func (s *ObjectStorage) OpenVideo(filePathInDB string) (io.ReadSeekCloser, error) {
+	key, err := s.key(filePathInDB)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.client.HeadObject(key)
+	if err != nil {
+		return nil, fmt.Errorf("無法開啟影片物件 '%s': %w", key, err)
+	}
+	return &objectReader{client: s.client, key: key, size: info.Size}, nil
+}
+
+// OpenRange 讀取物件的指定範圍 (HTTP Range 標頭格式，例如 "bytes=0-1023"，空字串為整個物件)；呼叫端需關閉回應
+func (s *ObjectStorage) OpenRange(relativePath, rangeHeader string) (*http.Response, error) {
+	key, err := s.key(relativePath)
+	if err != nil {
+		return nil, err
+	}
+	return s.client.GetObject(key, rangeHeader)
+}
+
+// PresignURL 產生物件在 expiry 內有效的 GET 網址
+func (s *ObjectStorage) PresignURL(relativePath string, expiry time.Duration) (string, error) {
+	key, err := s.key(relativePath)
+	if err != nil {
+		return "", err
+	}
+	return s.client.PresignGet(key, expiry), nil
+}
+
+// DeleteVideo 刪除物件及其本地快取；物件不存在時回傳 os.ErrNotExist
+func (s *ObjectStorage) DeleteVideo(filePathInDB string) error {
+	key, err := s.key(filePathInDB)
+	if err != nil {
+		return err
+	}
+	// S3 刪除不存在的物件也會成功，先確認物件存在以回報 os.ErrNotExist
+	if _, err := s.client.HeadObject(key); err != nil {
+		return fmt.Errorf("無法取得待刪除物件 '%s': %w", key, err)
+	}
+	log.Printf("資訊：正在從 S3 刪除物件 '%s'...", key)
+	if err := s.client.DeleteObject(key); err != nil {
+		return fmt.Errorf("無法刪除物件 '%s': %w", key, err)
+	}
+	localPath := filepath.Join(s.cacheDir, filepath.FromSlash(strings.TrimPrefix(key, s.prefix)))
+	if err := os.Remove(localPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("警告：[ObjectStorage] 刪除本地快取 '%s' 失敗: %v", localPath, err)
+	}
+	log.Printf("資訊：物件 '%s' 刪除成功。", key)
+	return nil
+}
+
+// ListFiles 列出相對路徑 prefix 下的所有物件 (實作 services.NASLister)
+func (s *ObjectStorage) ListFiles(prefix string) ([]models.StorageObject, error) {
+	objects, err := s.client.ListObjects(s.prefix + strings.TrimLeft(prefix, "/"))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]models.StorageObject, 0, len(objects))
+	for _, o := range objects {
+		if strings.HasSuffix(o.Key, "/") { // 目錄佔位物件
+			continue
+		}
+		files = append(files, models.StorageObject{
+			Path: strings.TrimPrefix(o.Key, s.prefix), Size: o.Size, ModTime: o.LastModified, ETag: strings.Trim(o.ETag, `"`),
+		})
+	}
+	return files, nil
+}
+
+// objectReader 以 Range 請求實作 io.ReadSeekCloser
+type objectReader struct {
+	client *Client
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser // 目前 offset 開始的回應內容；Seek 後關閉並於下次 Read 重新請求
+}
+
+func (o *objectReader) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+	if o.body == nil {
+		resp, err := o.client.GetObject(o.key, fmt.Sprintf("bytes=%d-", o.offset))
+		if err != nil {
+			return 0, err
+		}
+		o.body = resp.Body
+	}
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	if err == io.EOF && o.offset < o.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (o *objectReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = o.offset + offset
+	case io.SeekEnd:
+		abs = o.size + offset
+	default:
+		return 0, fmt.Errorf("無效的 whence: %d", whence)
+	}
+	if abs < 0 {
+		return 0, fmt.Errorf("無效的位置: %d", abs)
+	}
+	if abs != o.offset && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.offset = abs
+	return abs, nil
+}
+
+func (o *objectReader) Close() error {
+	if o.body == nil {
+		return nil
+	}
+	err := o.body.Close()
+	o.body = nil
+	return err
+}
@@ -0,0 +1,444 @@
+package s3_test
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/models"
+	"AiHackathon-admin/internal/storage/s3"
+	"AiHackathon-admin/internal/web/handlers"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testNASConfig 回傳指向 fake 的 nas 設定 (prefix 為 Download/，multipart 每段 1 MB)
+func testNASConfig(t *testing.T, f *fakeS3) config.NASConfig {
+	t.Helper()
+	return config.NASConfig{
+		Backend:   "s3",
+		VideoPath: t.TempDir(),
+		S3: config.NASS3Config{
+			Endpoint: f.URL, Region: "us-east-1", Bucket: f.bucket, Prefix: "/Download",
+			AccessKeyID: f.accessKey, SecretAccessKey: "test-secret", PathStyle: true,
+			PartSizeMB: 1, StreamMode: "proxy", PresignMinutes: 15,
+		},
+	}
+}
+
+func newTestStorage(t *testing.T, f *fakeS3) (*s3.ObjectStorage, config.NASConfig) {
+	t.Helper()
+	cfg := testNASConfig(t, f)
+	store, err := s3.NewObjectStorage(cfg)
+	if err != nil {
+		t.Fatalf("NewObjectStorage: %v", err)
+	}
+	return store, cfg
+}
+
+// testData 產生 n bytes 的非重複內容
+func testData(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i*7 + i/251)
+	}
+	return data
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestNewObjectStorageChecksBucket(t *testing.T) {
+	f := newFakeS3(t)
+	cfg := testNASConfig(t, f)
+	cfg.S3.AccessKeyID = "wrong-key"
+	var apiErr *s3.APIError
+	if _, err := s3.NewObjectStorage(cfg); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("認證錯誤時應回傳 HTTP 403，got %v", err)
+	}
+	cfg = testNASConfig(t, f)
+	cfg.S3.Bucket = "missing"
+	if _, err := s3.NewObjectStorage(cfg); err == nil {
+		t.Error("bucket 不存在時應回傳錯誤")
+	}
+}
+
+func TestSaveVideo(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int
+		knownSize bool
+		uploads   int // multipart 上傳次數
+	}{
+		{name: "單一請求", size: 1000, knownSize: true},
+		{name: "剛好一段", size: 1 << 20, knownSize: true},
+		{name: "multipart", size: 2<<20 + 12345, knownSize: true, uploads: 1},
+		{name: "大小未知", size: 1<<20 + 1, uploads: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeS3(t)
+			store, _ := newTestStorage(t, f)
+			data := testData(tt.size)
+			size := int64(-1)
+			if tt.knownSize {
+				size = int64(len(data))
+			}
+			stored, err := store.SaveVideo("ap", "4521987", "/tmp/Typhoon clip.mp4", bytes.NewReader(data), size)
+			if err != nil {
+				t.Fatalf("SaveVideo: %v", err)
+			}
+			wantPath := "ap/" + time.Now().Format("2006/01/02") + "/4521987/Typhoon clip.mp4"
+			if want := (models.StoredFile{Path: wantPath, Size: int64(len(data)), SHA256: sha256Hex(data)}); stored != want {
+				t.Errorf("SaveVideo = %+v, want %+v", stored, want)
+			}
+			o, ok := f.object("Download/" + wantPath)
+			if !ok || !bytes.Equal(o.data, data) {
+				t.Fatalf("物件內容不符 (存在: %t, %d bytes)", ok, len(o.data))
+			}
+			if o.contentType != "video/mp4" {
+				t.Errorf("Content-Type = %q", o.contentType)
+			}
+			if got := f.count("POST Download/" + wantPath); got != tt.uploads*2 {
+				t.Errorf("multipart 請求數 = %d, want %d", got, tt.uploads*2)
+			}
+		})
+	}
+}
+
+func TestSaveVideoErrors(t *testing.T) {
+	f := newFakeS3(t)
+	store, _ := newTestStorage(t, f)
+
+	// 大小與宣告不符時刪除已上傳的物件
+	if _, err := store.SaveVideo("ap", "1", "a.mp4", bytes.NewReader(testData(10)), 11); err == nil {
+		t.Error("大小不符時應回傳錯誤")
+	}
+	if _, ok := f.object("Download/ap/" + time.Now().Format("2006/01/02") + "/1/a.mp4"); ok {
+		t.Error("大小不符的物件應被刪除")
+	}
+
+	// multipart 任一段失敗時中止上傳
+	f.failPart = 2
+	if _, err := store.SaveVideo("ap", "2", "b.mp4", bytes.NewReader(testData(3<<20)), -1); err == nil || !strings.Contains(err.Error(), "第 2 段") {
+		t.Errorf("分段上傳失敗時應回傳錯誤，got %v", err)
+	}
+	f.mu.Lock()
+	if len(f.aborted) != 1 || len(f.uploads) != 0 {
+		t.Errorf("失敗的 multipart 上傳應被中止 (aborted: %v, 進行中: %d)", f.aborted, len(f.uploads))
+	}
+	f.mu.Unlock()
+
+	if _, err := store.SaveVideo("", "1", "a.mp4", bytes.NewReader(nil), 1); err == nil {
+		t.Error("sourceName 為空時應回傳錯誤")
+	}
+}
+
+func TestSaveFileAndListFiles(t *testing.T) {
+	f := newFakeS3(t)
+	f.maxKeys = 2 // 強制分頁
+	store, _ := newTestStorage(t, f)
+
+	for _, p := range []string{"ap/1/a.mp4", "ap/1/a.poster.jpg", "ap/2/b.mp4", "reuters/3/c.mp4"} {
+		if _, err := store.SaveFile(p, strings.NewReader(p), int64(len(p))); err != nil {
+			t.Fatalf("SaveFile(%s): %v", p, err)
+		}
+	}
+	f.put("Download/ap/2/", nil, time.Now()) // 目錄佔位物件
+	f.put("Other/ap/1/x.mp4", []byte("x"), time.Now())
+
+	files, err := store.ListFiles("/ap/")
+	if err != nil {
+		t.Fatalf("ListFiles: %v", err)
+	}
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.Path)
+		if file.Size != int64(len(file.Path)) || file.ETag == "" || strings.Contains(file.ETag, `"`) || file.ModTime.IsZero() {
+			t.Errorf("物件資訊不正確: %+v", file)
+		}
+	}
+	if want := []string{"ap/1/a.mp4", "ap/1/a.poster.jpg", "ap/2/b.mp4"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("ListFiles = %v, want %v", paths, want)
+	}
+	if got := f.count("GET "); got != 2 {
+		t.Errorf("ListObjectsV2 請求數 = %d, want 2 (分頁)", got)
+	}
+	if o, _ := f.object("Download/ap/1/a.poster.jpg"); o.contentType != "image/jpeg" {
+		t.Errorf("縮圖 Content-Type = %q", o.contentType)
+	}
+}
+
+func TestOpenVideoSeeks(t *testing.T) {
+	f := newFakeS3(t)
+	store, _ := newTestStorage(t, f)
+	data := testData(100000)
+	f.put("Download/ap/1/clip.mp4", data, time.Now())
+
+	rs, err := store.OpenVideo("ap/1/clip.mp4")
+	if err != nil {
+		t.Fatalf("OpenVideo: %v", err)
+	}
+	defer rs.Close()
+	head := make([]byte, 10)
+	if _, err := io.ReadFull(rs, head); err != nil || !bytes.Equal(head, data[:10]) {
+		t.Fatalf("讀取開頭失敗: %v", err)
+	}
+	if _, err := rs.Seek(-16, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	tail, err := io.ReadAll(rs)
+	if err != nil || !bytes.Equal(tail, data[len(data)-16:]) {
+		t.Fatalf("Seek 至結尾後讀取 = %d bytes, %v", len(tail), err)
+	}
+	if pos, _ := rs.Seek(50000, io.SeekStart); pos != 50000 {
+		t.Fatalf("Seek = %d", pos)
+	}
+	rest, err := io.ReadAll(rs)
+	if err != nil || !bytes.Equal(rest, data[50000:]) {
+		t.Fatalf("Seek 後讀取 = %d bytes, %v", len(rest), err)
+	}
+	// 每次 Seek 後只重新請求一次 Range，不會下載整個物件
+	if got := f.count("GET Download/ap/1/clip.mp4"); got != 3 {
+		t.Errorf("GET 請求數 = %d, want 3", got)
+	}
+
+	if _, err := store.OpenVideo("ap/1/missing.mp4"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("物件不存在時應回傳 os.ErrNotExist，got %v", err)
+	}
+}
+
+func TestGetVideoAbsolutePathCaches(t *testing.T) {
+	f := newFakeS3(t)
+	store, cfg := newTestStorage(t, f)
+	data := testData(5000)
+	f.put("Download/ap/1/clip.mp4", data, time.Date(2024, 9, 2, 1, 0, 0, 0, time.UTC))
+
+	local, err := store.GetVideoAbsolutePath("ap/1/clip.mp4")
+	if err != nil {
+		t.Fatalf("GetVideoAbsolutePath: %v", err)
+	}
+	if want := filepath.Join(cfg.VideoPath, "ap", "1", "clip.mp4"); local != want {
+		t.Errorf("本地路徑 = %s, want %s", local, want)
+	}
+	if got, _ := os.ReadFile(local); !bytes.Equal(got, data) {
+		t.Error("快取檔案內容不符")
+	}
+
+	// 大小與修改時間相同時直接使用快取
+	if _, err := store.GetVideoAbsolutePath("ap/1/clip.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.count("GET Download/ap/1/clip.mp4"); got != 1 {
+		t.Errorf("快取有效時不應重新下載 (GET %d 次)", got)
+	}
+
+	// 物件更新後重新下載
+	updated := testData(6000)
+	f.put("Download/ap/1/clip.mp4", updated, time.Date(2024, 9, 3, 1, 0, 0, 0, time.UTC))
+	if _, err := store.GetVideoAbsolutePath("ap/1/clip.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(local); !bytes.Equal(got, updated) {
+		t.Error("物件更新後快取應重新下載")
+	}
+
+	// 刪除物件時一併刪除快取
+	if err := store.DeleteVideo("ap/1/clip.mp4"); err != nil {
+		t.Fatalf("DeleteVideo: %v", err)
+	}
+	if _, ok := f.object("Download/ap/1/clip.mp4"); ok {
+		t.Error("物件應已刪除")
+	}
+	if _, err := os.Stat(local); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("本地快取應已刪除: %v", err)
+	}
+	if err := store.DeleteVideo("ap/1/clip.mp4"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("刪除不存在的物件應回傳 os.ErrNotExist，got %v", err)
+	}
+}
+
+func TestRejectsPathTraversal(t *testing.T) {
+	f := newFakeS3(t)
+	store, _ := newTestStorage(t, f)
+	for _, p := range []string{"", "/", ".", "..", "../Other/x.mp4", "ap/../../x.mp4"} {
+		if _, err := store.OpenRange(p, ""); err == nil {
+			t.Errorf("OpenRange(%q) 應回傳錯誤", p)
+		}
+		if _, err := store.SaveFile(p, strings.NewReader("x"), 1); err == nil {
+			t.Errorf("SaveFile(%q) 應回傳錯誤", p)
+		}
+	}
+	if got := f.count("PUT "); got != 0 {
+		t.Errorf("無效路徑不應送出請求")
+	}
+}
+
+// newMediaServer 依 routes.go 的方式掛載 /media/ 與 /thumbs/ (不含登入驗證)
+func newMediaServer(t *testing.T, cfg config.NASConfig, store *s3.ObjectStorage) *httptest.Server {
+	t.Helper()
+	videoHandler, err := handlers.NewVideoHandler(cfg, store, config.WebProxyConfig{}, config.MediaSigningConfig{Require: "none"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumbnailHandler, err := handlers.NewThumbnailHandler(cfg, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/media/", http.StripPrefix("/media/", videoHandler))
+	mux.Handle("/thumbs/", http.StripPrefix("/thumbs/", thumbnailHandler))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMediaProxy(t *testing.T) {
+	f := newFakeS3(t)
+	store, cfg := newTestStorage(t, f)
+	data := testData(4096)
+	if _, err := store.SaveFile("ap/1/clip.mp4", bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatal(err)
+	}
+	srv := newMediaServer(t, cfg, store)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		rangeHdr   string
+		wantStatus int
+		wantBody   []byte
+		wantHeader map[string]string
+	}{
+		{name: "整個檔案", method: http.MethodGet, path: "/media/ap/1/clip.mp4", wantStatus: http.StatusOK, wantBody: data,
+			wantHeader: map[string]string{"Content-Type": "video/mp4", "Content-Length": "4096", "Accept-Ranges": "bytes"}},
+		{name: "Range", method: http.MethodGet, path: "/media/ap/1/clip.mp4", rangeHdr: "bytes=100-199", wantStatus: http.StatusPartialContent, wantBody: data[100:200],
+			wantHeader: map[string]string{"Content-Range": "bytes 100-199/4096", "Content-Length": "100"}},
+		{name: "HEAD", method: http.MethodHead, path: "/media/ap/1/clip.mp4", wantStatus: http.StatusOK, wantBody: []byte{},
+			wantHeader: map[string]string{"Content-Length": "4096"}},
+		{name: "範圍無效", method: http.MethodGet, path: "/media/ap/1/clip.mp4", rangeHdr: "bytes=5000-", wantStatus: http.StatusRequestedRangeNotSatisfiable},
+		{name: "不存在", method: http.MethodGet, path: "/media/ap/1/missing.mp4", wantStatus: http.StatusNotFound},
+		{name: "目錄", method: http.MethodGet, path: "/media/ap/1/", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, srv.URL+tt.path, nil)
+			if tt.rangeHdr != "" {
+				req.Header.Set("Range", tt.rangeHdr)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("狀態碼 = %d, want %d (%s)", resp.StatusCode, tt.wantStatus, body)
+			}
+			if tt.wantBody != nil && !bytes.Equal(body, tt.wantBody) {
+				t.Errorf("內容不符 (%d bytes, want %d)", len(body), len(tt.wantBody))
+			}
+			for k, v := range tt.wantHeader {
+				if got := resp.Header.Get(k); got != v {
+					t.Errorf("%s = %q, want %q", k, got, v)
+				}
+			}
+		})
+	}
+}
+
+func TestMediaPresign(t *testing.T) {
+	f := newFakeS3(t)
+	store, cfg := newTestStorage(t, f)
+	cfg.S3.StreamMode = "presign"
+	data := testData(1024)
+	f.put("Download/ap/1/clip.mp4", data, time.Now())
+	srv := newMediaServer(t, cfg, store)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(srv.URL + "/media/ap/1/clip.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	location := resp.Header.Get("Location")
+	if resp.StatusCode != http.StatusFound || !strings.HasPrefix(location, f.URL+"/videos/Download/ap/1/clip.mp4?") {
+		t.Fatalf("應重新導向至物件儲存，got %d %s", resp.StatusCode, location)
+	}
+	if !strings.Contains(location, "X-Amz-Expires=900") || resp.Header.Get("Cache-Control") != "no-store" {
+		t.Errorf("預先簽署網址的有效期間或快取標頭不正確: %s, %s", location, resp.Header.Get("Cache-Control"))
+	}
+
+	// 瀏覽器跟隨重新導向後直接向物件儲存讀取
+	resp, err = http.Get(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(body, data) {
+		t.Errorf("預先簽署網址讀取失敗: %d, %d bytes", resp.StatusCode, len(body))
+	}
+}
+
+func TestThumbsProxy(t *testing.T) {
+	f := newFakeS3(t)
+	store, cfg := newTestStorage(t, f)
+	jpeg := []byte("\xff\xd8\xff\xe0 fake jpeg")
+	if _, err := store.SaveFile("ap/1/clip.poster.jpg", bytes.NewReader(jpeg), int64(len(jpeg))); err != nil {
+		t.Fatal(err)
+	}
+	f.put("Download/ap/1/clip.mp4", testData(10), time.Now())
+	srv := newMediaServer(t, cfg, store)
+
+	resp, err := http.Get(srv.URL + "/thumbs/ap/1/clip.poster.jpg?v=123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(body, jpeg) || etag == "" {
+		t.Fatalf("縮圖讀取失敗: %d, %q, ETag %q", resp.StatusCode, body, etag)
+	}
+	if cc := resp.Header.Get("Cache-Control"); cc != "private, max-age=31536000, immutable" {
+		t.Errorf("帶版本參數的 Cache-Control = %q", cc)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "image/jpeg" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/thumbs/ap/1/clip.poster.jpg", nil)
+	req.Header.Set("If-None-Match", etag)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotModified || resp.Header.Get("Cache-Control") != "private, max-age=3600" {
+		t.Errorf("ETag 相同時應回傳 304，got %d (%s)", resp.StatusCode, resp.Header.Get("Cache-Control"))
+	}
+
+	// 只允許縮圖檔案，不可透過 /thumbs/ 讀取影片
+	for _, p := range []string{"/thumbs/ap/1/clip.mp4", "/thumbs/ap/1/missing.poster.jpg"} {
+		resp, err := http.Get(srv.URL + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s 狀態碼 = %d, want 404", p, resp.StatusCode)
+		}
+	}
+}
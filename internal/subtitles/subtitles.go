@@ -0,0 +1,210 @@
+package subtitles
+
+import (
+	"AiHackathon-admin/internal/models"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format 字幕檔格式
+type Format string
+
+const (
+	FormatSRT Format = "srt"
+	FormatVTT Format = "vtt"
+)
+
+// 字幕語言
+const (
+	LangOriginal = "orig" // 原始語言 (transcript)
+	LangChinese  = "zh"   // 繁體中文 (translation)
+)
+
+// 沒有結束時間可參考時，單一字幕顯示的預設秒數
+const defaultCueDuration = 5 * time.Second
+
+// Cue 為一則字幕
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// BuildCues 依據分析結果產生字幕。
+// 優先使用 Segments (由 Prompt 要求的時間軸分段)；若沒有分段資料，則退而以 Bites 的 time_line 對齊。
+// Bites 的引言僅有繁體中文，因此只用於 LangChinese，原始語言在沒有分段資料時回傳空字幕。
+// videoDuration 為影片長度，用於限制最後一則字幕的結束時間，未知時傳 0。
+func BuildCues(result *models.AnalysisResult, lang string, videoDuration time.Duration) ([]Cue, error) {
+	if result == nil {
+		return nil, fmt.Errorf("分析結果不得為空")
+	}
+	if lang != LangOriginal && lang != LangChinese {
+		return nil, fmt.Errorf("不支援的字幕語言: %s", lang)
+	}
+
+	cues, err := cuesFromSegments(result.Segments, lang)
+	if err != nil {
+		return nil, err
+	}
+	if len(cues) == 0 && lang == LangChinese {
+		cues, err = cuesFromBites(result.Bites)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return normalizeCues(cues, videoDuration), nil
+}
+
+func cuesFromSegments(raw json.RawMessage, lang string) ([]Cue, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var segments []models.TranscriptSegment
+	if err := json.Unmarshal(raw, &segments); err != nil {
+		return nil, fmt.Errorf("無法解析 segments JSON: %w", err)
+	}
+	var cues []Cue
+	for _, seg := range segments {
+		text := seg.Text
+		if lang == LangChinese {
+			text = seg.Translation
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		start, err := ParseTimecode(seg.Start)
+		if err != nil {
+			continue
+		}
+		end, err := ParseTimecode(seg.End)
+		if err != nil {
+			end = 0
+		}
+		if seg.Speaker != "" {
+			text = seg.Speaker + "：" + text
+		}
+		cues = append(cues, Cue{Start: start, End: end, Text: text})
+	}
+	return cues, nil
+}
+
+func cuesFromBites(raw json.RawMessage) ([]Cue, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var bites []models.Bite
+	if err := json.Unmarshal(raw, &bites); err != nil {
+		return nil, fmt.Errorf("無法解析 bites JSON: %w", err)
+	}
+	var cues []Cue
+	for _, b := range bites {
+		quote := strings.TrimSpace(b.Quote)
+		if quote == "" {
+			continue
+		}
+		start, err := ParseTimecode(b.TimeLine)
+		if err != nil {
+			continue
+		}
+		text := quote
+		if b.Speaker != "" {
+			text = b.Speaker + "：" + quote
+		}
+		cues = append(cues, Cue{Start: start, Text: text})
+	}
+	return cues, nil
+}
+
+// normalizeCues 依起始時間排序，補上缺少的結束時間，並避免字幕彼此重疊
+func normalizeCues(cues []Cue, videoDuration time.Duration) []Cue {
+	sort.SliceStable(cues, func(i, j int) bool { return cues[i].Start < cues[j].Start })
+	for i := range cues {
+		var next time.Duration = -1
+		if i+1 < len(cues) {
+			next = cues[i+1].Start
+		}
+		if cues[i].End <= cues[i].Start {
+			cues[i].End = cues[i].Start + defaultCueDuration
+		}
+		if next > cues[i].Start && cues[i].End > next {
+			cues[i].End = next
+		}
+		if videoDuration > 0 && cues[i].End > videoDuration && cues[i].Start < videoDuration {
+			cues[i].End = videoDuration
+		}
+	}
+	return cues
+}
+
+// ParseTimecode 解析 "HH:MM:SS(.mmm)"、"MM:SS" 或純秒數格式的時間碼。
+// 也接受 SRT 慣用的逗號小數點 (例如 "00:00:01,500")。
+func ParseTimecode(tc string) (time.Duration, error) {
+	tc = strings.TrimSpace(strings.ReplaceAll(tc, ",", "."))
+	if tc == "" {
+		return 0, fmt.Errorf("時間碼為空")
+	}
+	parts := strings.Split(tc, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("無效的時間碼: %s", tc)
+	}
+	var total float64
+	for _, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("無效的時間碼: %s", tc)
+		}
+		total = total*60 + v
+	}
+	return time.Duration(total * float64(time.Second)), nil
+}
+
+// formatTimestamp 將時間格式化為 HH:MM:SS{sep}mmm
+func formatTimestamp(d time.Duration, sep string) string {
+	if d < 0 {
+		d = 0
+	}
+	ms := d.Milliseconds()
+	h := ms / 3600000
+	m := (ms % 3600000) / 60000
+	s := (ms % 60000) / 1000
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", h, m, s, sep, ms%1000)
+}
+
+// cleanCueText 移除字幕內文中的空行，空行在 SRT/WebVTT 中代表 cue 結束
+func cleanCueText(text string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Write 以指定格式輸出字幕
+func Write(w io.Writer, cues []Cue, format Format) error {
+	var sb strings.Builder
+	switch format {
+	case FormatSRT:
+		for i, c := range cues {
+			fmt.Fprintf(&sb, "%d\n%s --> %s\n%s\n\n", i+1, formatTimestamp(c.Start, ","), formatTimestamp(c.End, ","), cleanCueText(c.Text))
+		}
+	case FormatVTT:
+		sb.WriteString("WEBVTT\n\n")
+		for i, c := range cues {
+			// WebVTT 的 cue 內文不得包含 "-->"
+			text := strings.ReplaceAll(cleanCueText(c.Text), "-->", "->")
+			fmt.Fprintf(&sb, "%d\n%s --> %s\n%s\n\n", i+1, formatTimestamp(c.Start, "."), formatTimestamp(c.End, "."), text)
+		}
+	default:
+		return fmt.Errorf("不支援的字幕格式: %s", format)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,221 @@
+package subtitles
+
+import (
+	"AiHackathon-admin/internal/models"
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTimecode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "00:00:01.500", want: 1500 * time.Millisecond},
+		{in: "00:00:01,500", want: 1500 * time.Millisecond},
+		{in: "01:02:03", want: time.Hour + 2*time.Minute + 3*time.Second},
+		{in: "02:03", want: 2*time.Minute + 3*time.Second},
+		{in: " 7.25 ", want: 7250 * time.Millisecond},
+		{in: "00:90", want: 90 * time.Second},
+		{in: "", wantErr: true},
+		{in: "1:2:3:4", wantErr: true},
+		{in: "00:-1:00", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "00::01", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTimecode(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseTimecode(%q) 應回傳錯誤，got %v", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseTimecode(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func segmentsJSON(t *testing.T, segments ...models.TranscriptSegment) json.RawMessage {
+	t.Helper()
+	raw, err := json.Marshal(segments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestBuildCuesFromSegments(t *testing.T) {
+	result := &models.AnalysisResult{
+		Segments: segmentsJSON(t,
+			models.TranscriptSegment{Start: "00:00:04.000", End: "00:00:07.500", Speaker: "記者", Text: "Second line", Translation: "第二句"},
+			models.TranscriptSegment{Start: "00:00:00.500", End: "00:00:03.000", Text: "First line", Translation: "第一句"},
+			models.TranscriptSegment{Start: "bad", End: "00:00:09.000", Text: "skipped", Translation: "略過"},
+			models.TranscriptSegment{Start: "00:00:08.000", End: "00:00:09.000", Text: "  ", Translation: "只有翻譯"},
+		),
+		Bites: json.RawMessage(`[{"time_line":"00:00:01","speaker":"市長","quote":"不應使用"}]`),
+	}
+
+	orig, err := BuildCues(result, LangOriginal, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Cue{
+		{Start: 500 * time.Millisecond, End: 3 * time.Second, Text: "First line"},
+		{Start: 4 * time.Second, End: 7500 * time.Millisecond, Text: "記者：Second line"},
+	}
+	if !reflect.DeepEqual(orig, want) {
+		t.Errorf("原始語言字幕 = %+v, want %+v", orig, want)
+	}
+
+	zh, err := BuildCues(result, LangChinese, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []Cue{
+		{Start: 500 * time.Millisecond, End: 3 * time.Second, Text: "第一句"},
+		{Start: 4 * time.Second, End: 7500 * time.Millisecond, Text: "記者：第二句"},
+		{Start: 8 * time.Second, End: 9 * time.Second, Text: "只有翻譯"},
+	}
+	if !reflect.DeepEqual(zh, want) {
+		t.Errorf("中文字幕 = %+v, want %+v", zh, want)
+	}
+}
+
+func TestBuildCuesNormalizesTiming(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments []models.TranscriptSegment
+		duration time.Duration
+		want     []Cue
+	}{
+		{
+			name:     "缺少結束時間時使用預設長度",
+			segments: []models.TranscriptSegment{{Start: "00:00:01", End: "", Text: "a"}},
+			want:     []Cue{{Start: time.Second, End: time.Second + defaultCueDuration, Text: "a"}},
+		},
+		{
+			name:     "結束時間早於開始時間",
+			segments: []models.TranscriptSegment{{Start: "00:00:05", End: "00:00:02", Text: "a"}},
+			want:     []Cue{{Start: 5 * time.Second, End: 5*time.Second + defaultCueDuration, Text: "a"}},
+		},
+		{
+			name: "重疊的字幕截斷於下一則開始",
+			segments: []models.TranscriptSegment{
+				{Start: "00:00:00", End: "00:00:06", Text: "a"},
+				{Start: "00:00:04", End: "00:00:08", Text: "b"},
+			},
+			want: []Cue{{Start: 0, End: 4 * time.Second, Text: "a"}, {Start: 4 * time.Second, End: 8 * time.Second, Text: "b"}},
+		},
+		{
+			name:     "不超過影片長度",
+			segments: []models.TranscriptSegment{{Start: "00:00:58", End: "00:01:10", Text: "a"}},
+			duration: time.Minute,
+			want:     []Cue{{Start: 58 * time.Second, End: time.Minute, Text: "a"}},
+		},
+		{
+			name:     "開始時間超過影片長度時不調整",
+			segments: []models.TranscriptSegment{{Start: "00:01:05", End: "00:01:08", Text: "a"}},
+			duration: time.Minute,
+			want:     []Cue{{Start: 65 * time.Second, End: 68 * time.Second, Text: "a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BuildCues(&models.AnalysisResult{Segments: segmentsJSON(t, tt.segments...)}, LangOriginal, tt.duration)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BuildCues() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildCuesFallsBackToBites(t *testing.T) {
+	for _, segments := range []json.RawMessage{nil, json.RawMessage("null"), json.RawMessage("[]"), json.RawMessage(`[{"start":"00:00:01","text":""}]`)} {
+		result := &models.AnalysisResult{
+			Segments: segments,
+			Bites: json.RawMessage(`[
+				{"time_line":"00:00:20","speaker":"市長","quote":"＂第二段＂"},
+				{"time_line":"00:00:03","speaker":"","quote":"＂第一段＂"},
+				{"time_line":"","speaker":"無時間","quote":"略過"},
+				{"time_line":"00:00:30","speaker":"空白","quote":" "}
+			]`),
+		}
+		got, err := BuildCues(result, LangChinese, 22*time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []Cue{
+			{Start: 3 * time.Second, End: 8 * time.Second, Text: "＂第一段＂"},
+			{Start: 20 * time.Second, End: 22 * time.Second, Text: "市長：＂第二段＂"},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("segments %s: BuildCues() = %+v, want %+v", segments, got, want)
+		}
+		// BITE 引言為繁體中文，不作為原始語言字幕
+		if orig, err := BuildCues(result, LangOriginal, 22*time.Second); err != nil || len(orig) != 0 {
+			t.Errorf("segments %s: 原始語言字幕 = %+v, %v, want 空字幕", segments, orig, err)
+		}
+	}
+}
+
+func TestBuildCuesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *models.AnalysisResult
+		lang   string
+		want   string
+	}{
+		{name: "沒有分析結果", result: nil, lang: LangChinese, want: "不得為空"},
+		{name: "不支援的語言", result: &models.AnalysisResult{}, lang: "en", want: "不支援的字幕語言"},
+		{name: "無效的 segments", result: &models.AnalysisResult{Segments: json.RawMessage(`{"start":1}`)}, lang: LangChinese, want: "segments"},
+		{name: "無效的 bites", result: &models.AnalysisResult{Bites: json.RawMessage(`"x"`)}, lang: LangChinese, want: "bites"},
+	}
+	for _, tt := range tests {
+		if _, err := BuildCues(tt.result, tt.lang, 0); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want 包含 %q", tt.name, err, tt.want)
+		}
+	}
+	// 沒有 segments 與 bites 時回傳空字幕而非錯誤
+	if cues, err := BuildCues(&models.AnalysisResult{}, LangOriginal, 0); err != nil || len(cues) != 0 {
+		t.Errorf("空的分析結果 = %v, %v", cues, err)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	cues := []Cue{
+		{Start: 500 * time.Millisecond, End: 3 * time.Second, Text: "記者：第一句\r\n\r\n第二行"},
+		{Start: time.Hour + 2*time.Minute + 3*time.Second + 45*time.Millisecond, End: time.Hour + 2*time.Minute + 5*time.Second, Text: "a --> b"},
+	}
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{FormatSRT, "1\n00:00:00,500 --> 00:00:03,000\n記者：第一句\n第二行\n\n" +
+			"2\n01:02:03,045 --> 01:02:05,000\na --> b\n\n"},
+		{FormatVTT, "WEBVTT\n\n" +
+			"1\n00:00:00.500 --> 00:00:03.000\n記者：第一句\n第二行\n\n" +
+			"2\n01:02:03.045 --> 01:02:05.000\na -> b\n\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := Write(&buf, cues, tt.format); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("Write(%s) =\n%q\nwant\n%q", tt.format, buf.String(), tt.want)
+		}
+	}
+	if err := Write(&bytes.Buffer{}, cues, "ass"); err == nil {
+		t.Error("不支援的格式應回傳錯誤")
+	}
+}
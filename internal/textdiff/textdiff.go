@@ -0,0 +1,133 @@
+// Package textdiff 提供以行為單位的文字差異比對 (最長共同子序列)，供 prompt 版本比較使用。
+package textdiff
+
+import "strings"
+
+// Op 差異類型
+type Op string
+
+const (
+	Equal  Op = "="
+	Insert Op = "+"
+	Delete Op = "-"
+)
+
+// maxCells 限制 LCS 表格大小，避免超大文字耗盡記憶體；超過時退回整段刪除/新增
+const maxCells = 4_000_000
+
+// Line 為差異結果中的一行；OldNo / NewNo 為原始行號 (從 1 起算，0 代表該側無此行)
+type Line struct {
+	Op    Op     `json:"op"`
+	Text  string `json:"text"`
+	OldNo int    `json:"old_no,omitempty"`
+	NewNo int    `json:"new_no,omitempty"`
+}
+
+// Stats 差異統計
+type Stats struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+}
+
+// Lines 比對 a (舊) 與 b (新) 的逐行差異
+func Lines(a, b string) []Line {
+	oldLines, newLines := splitLines(a), splitLines(b)
+
+	// 先略過相同的開頭與結尾，縮小 LCS 表格
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+
+	out := make([]Line, 0, len(oldLines)+len(newLines))
+	for i := 0; i < prefix; i++ {
+		out = append(out, Line{Op: Equal, Text: oldLines[i], OldNo: i + 1, NewNo: i + 1})
+	}
+	out = append(out, diffMiddle(oldLines[prefix:len(oldLines)-suffix], newLines[prefix:len(newLines)-suffix], prefix, prefix)...)
+	for i := 0; i < suffix; i++ {
+		oi, ni := len(oldLines)-suffix+i, len(newLines)-suffix+i
+		out = append(out, Line{Op: Equal, Text: oldLines[oi], OldNo: oi + 1, NewNo: ni + 1})
+	}
+	return out
+}
+
+// Summarize 統計新增與刪除的行數
+func Summarize(lines []Line) Stats {
+	var st Stats
+	for _, l := range lines {
+		switch l.Op {
+		case Insert:
+			st.Added++
+		case Delete:
+			st.Removed++
+		}
+	}
+	return st
+}
+
+func diffMiddle(a, b []string, oldOffset, newOffset int) []Line {
+	var out []Line
+	if len(a)*len(b) > maxCells {
+		for i, l := range a {
+			out = append(out, Line{Op: Delete, Text: l, OldNo: oldOffset + i + 1})
+		}
+		for j, l := range b {
+			out = append(out, Line{Op: Insert, Text: l, NewNo: newOffset + j + 1})
+		}
+		return out
+	}
+
+	// lcs[i][j] 為 a[i:] 與 b[j:] 的最長共同子序列長度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, Line{Op: Equal, Text: a[i], OldNo: oldOffset + i + 1, NewNo: newOffset + j + 1})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, Line{Op: Delete, Text: a[i], OldNo: oldOffset + i + 1})
+			i++
+		default:
+			out = append(out, Line{Op: Insert, Text: b[j], NewNo: newOffset + j + 1})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, Line{Op: Delete, Text: a[i], OldNo: oldOffset + i + 1})
+	}
+	for ; j < len(b); j++ {
+		out = append(out, Line{Op: Insert, Text: b[j], NewNo: newOffset + j + 1})
+	}
+	return out
+}
+
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,105 @@
+package thumbnails
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 縮圖檔案的後綴；縮圖與影片位於同一目錄，檔名為影片檔名 (去除副檔名) 加上後綴
+const (
+	PosterSuffix       = ".poster.jpg"
+	ContactSheetSuffix = ".contact.jpg"
+)
+
+// Options 縮圖尺寸
+type Options struct {
+	PosterWidth int // 海報寬度 (像素)
+	Columns     int // 縮圖表欄數
+	Rows        int // 縮圖表列數
+	TileWidth   int // 縮圖表每格寬度 (像素)
+}
+
+// PosterPath 回傳影片 (以 / 分隔的相對路徑) 對應的海報路徑，例如 ap/1/clip.mp4 -> ap/1/clip.poster.jpg
+func PosterPath(videoPath string) string {
+	return strings.TrimSuffix(videoPath, path.Ext(videoPath)) + PosterSuffix
+}
+
+// ContactSheetPath 回傳影片對應的關鍵影格縮圖表路徑，例如 ap/1/clip.mp4 -> ap/1/clip.contact.jpg
+func ContactSheetPath(videoPath string) string {
+	return strings.TrimSuffix(videoPath, path.Ext(videoPath)) + ContactSheetSuffix
+}
+
+// IsThumbnail 判斷路徑是否為本套件產生的縮圖檔案
+func IsThumbnail(p string) bool {
+	return strings.HasSuffix(p, PosterSuffix) || strings.HasSuffix(p, ContactSheetSuffix)
+}
+
+// PosterOffset 回傳擷取海報的時間點：影片長度的 10% (避開片頭黑畫面與色條)，最多 30 秒；長度未知時為 1 秒
+func PosterOffset(duration time.Duration) time.Duration {
+	if duration <= 0 {
+		return time.Second
+	}
+	offset := duration / 10
+	if offset > 30*time.Second {
+		offset = 30 * time.Second
+	}
+	return offset
+}
+
+// ExtractPoster 以 ffmpeg 擷取 input (本地路徑或 http(s) 網址) 在 PosterOffset 的畫面存為 JPEG
+func ExtractPoster(ctx context.Context, ffmpegPath, input, dst string, duration time.Duration, opts Options) error {
+	args := []string{
+		"-hide_banner", "-loglevel", "error", "-y",
+		"-ss", seconds(PosterOffset(duration)),
+		"-i", input,
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale=%d:-2", opts.PosterWidth),
+		"-q:v", "3",
+		dst,
+	}
+	return run(ctx, ffmpegPath, args, dst, "海報")
+}
+
+// ContactSheet 以 ffmpeg 只解碼關鍵影格並組成 Columns x Rows 的縮圖表。
+// 影片長度已知時依時間平均取樣 (每格取該時段的關鍵影格)；長度未知時依序使用前幾個關鍵影格。
+// 關鍵影格不足時縮圖表會留白
+func ContactSheet(ctx context.Context, ffmpegPath, input, dst string, duration time.Duration, opts Options) error {
+	tiles := opts.Columns * opts.Rows
+	sample := "select='eq(pict_type\\,I)'"
+	if duration > 0 {
+		sample = "fps=" + strconv.FormatFloat(float64(tiles)/duration.Seconds(), 'f', 6, 64)
+	}
+	args := []string{
+		"-hide_banner", "-loglevel", "error", "-y",
+		"-skip_frame", "nokey",
+		"-i", input,
+		"-an", "-sn",
+		"-vf", fmt.Sprintf("%s,scale=%d:-2,tile=%dx%d:padding=4:margin=4", sample, opts.TileWidth, opts.Columns, opts.Rows),
+		"-frames:v", "1",
+		"-q:v", "4",
+		dst,
+	}
+	return run(ctx, ffmpegPath, args, dst, "關鍵影格縮圖表")
+}
+
+// run 執行 ffmpeg 並確認有產生輸出檔案 (時間點超出影片長度時 ffmpeg 不會回報錯誤)
+func run(ctx context.Context, ffmpegPath string, args []string, dst string, name string) error {
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ffmpeg 產生%s失敗: %w (%s)", name, err, strings.TrimSpace(string(out)))
+	}
+	if st, err := os.Stat(dst); err != nil || st.Size() == 0 {
+		return fmt.Errorf("ffmpeg 未產生%s (影片可能沒有可解碼的畫面)", name)
+	}
+	return nil
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
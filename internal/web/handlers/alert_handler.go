@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"AiHackathon-admin/internal/alerts"
+	"AiHackathon-admin/internal/models"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// AlertTester 定義了 AlertHandler 測試寄送快訊所需的操作
+type AlertTester interface {
+	TestFire(ruleID, videoID int64) (bool, error)
+}
+
+// AlertPageData 為快訊規則頁面的範本資料
+type AlertPageData struct {
+	Rules  []models.AlertRule
+	Fields []string
+}
+
+// AlertHandler 提供快訊規則的管理頁面與 JSON API
+// 路由:
+//   - GET                /alerts                           規則管理頁面
+//   - GET, POST          /api/v1/alerts/rules              列出 / 新增規則
+//   - PUT, DELETE        /api/v1/alerts/rules/{id}         更新 / 刪除規則
+//   - POST               /api/v1/alerts/rules/{id}/test    測試寄送 (?video_id=，省略時使用最新完成分析的影片)
+type AlertHandler struct {
+	db     DBStore
+	tester AlertTester
+	tpl    *pageTemplate
+}
+
+// NewAlertHandler 建立一個 AlertHandler 實例
+func NewAlertHandler(db DBStore, tester AlertTester, templateBasePath string) (*AlertHandler, error) {
+	if db == nil {
+		return nil, fmt.Errorf("DBStore不得為nil")
+	}
+	if tester == nil {
+		return nil, fmt.Errorf("AlertTester不得為nil")
+	}
+	tplPath := filepath.Join(templateBasePath, "alerts.html")
+	tpl, err := loadPageTemplate(tplPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("無法解析快訊規則範本 '%s': %w", tplPath, err)
+	}
+	return &AlertHandler{db: db, tester: tester, tpl: tpl}, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// ServePage 顯示快訊規則管理頁面
+func (h *AlertHandler) ServePage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "僅支援 GET 方法", http.StatusMethodNotAllowed)
+		return
+	}
+	rules, err := h.db.GetAlertRules(false)
+	if err != nil {
+		log.Printf("錯誤：[AlertHandler] %v", err)
+		http.Error(w, "內部伺服器錯誤", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tpl.Execute(w, AlertPageData{Rules: rules, Fields: alerts.Fields}); err != nil {
+		log.Printf("錯誤：[AlertHandler] 渲染快訊規則範本失敗: %v", err)
+	}
+}
+
+// validateRule 檢查規則欄位並確認規則語法可解析
+func validateRule(rule *models.AlertRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.Target = strings.TrimSpace(rule.Target)
+	if rule.Name == "" {
+		return fmt.Errorf("規則名稱不得為空")
+	}
+	if _, err := alerts.Parse(rule.Expression); err != nil {
+		return err
+	}
+	switch rule.Channel {
+	case models.AlertChannelEmail:
+		recipients := alerts.ParseRecipients(rule.Target)
+		if len(recipients) == 0 {
+			return fmt.Errorf("email 規則需至少一位收件者")
+		}
+		for _, rcpt := range recipients {
+			if !strings.Contains(rcpt, "@") {
+				return fmt.Errorf("無效的 email 收件者: %s", rcpt)
+			}
+		}
+	case models.AlertChannelChat:
+		if !strings.HasPrefix(rule.Target, "http://") && !strings.HasPrefix(rule.Target, "https://") {
+			return fmt.Errorf("聊天室 webhook URL 需以 http:// 或 https:// 開頭")
+		}
+	default:
+		return fmt.Errorf("通知管道需為 email 或 chat")
+	}
+	return nil
+}
+
+// ServeRules 處理 /api/v1/alerts/rules (列出 / 新增)
+func (h *AlertHandler) ServeRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		rules, err := h.db.GetAlertRules(false)
+		if err != nil {
+			log.Printf("錯誤：[AlertHandler] %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "查詢快訊規則失敗")
+			return
+		}
+		if rules == nil {
+			rules = []models.AlertRule{}
+		}
+		writeJSON(w, http.StatusOK, rules)
+	case http.MethodPost:
+		var rule models.AlertRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "無效的 JSON 內容")
+			return
+		}
+		if err := validateRule(&rule); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		id, err := h.db.CreateAlertRule(&rule)
+		if err != nil {
+			log.Printf("錯誤：[AlertHandler] %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "新增快訊規則失敗")
+			return
+		}
+		rule.ID = id
+		log.Printf("資訊：[AlertHandler] 新增快訊規則 %d '%s'", id, rule.Name)
+		writeJSON(w, http.StatusCreated, rule)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "僅支援 GET 或 POST 方法")
+	}
+}
+
+// ServeRule 處理 /api/v1/alerts/rules/{id} (更新 / 刪除)
+func (h *AlertHandler) ServeRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "無效的規則 ID")
+		return
+	}
+	existing, err := h.db.GetAlertRuleByID(id)
+	if err != nil {
+		log.Printf("錯誤：[AlertHandler] %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "查詢快訊規則失敗")
+		return
+	}
+	if existing == nil {
+		writeJSONError(w, http.StatusNotFound, "找不到快訊規則")
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		var rule models.AlertRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "無效的 JSON 內容")
+			return
+		}
+		rule.ID = id
+		if err := validateRule(&rule); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := h.db.UpdateAlertRule(&rule); err != nil {
+			log.Printf("錯誤：[AlertHandler] %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "更新快訊規則失敗")
+			return
+		}
+		writeJSON(w, http.StatusOK, rule)
+	case http.MethodDelete:
+		if err := h.db.DeleteAlertRule(id); err != nil {
+			log.Printf("錯誤：[AlertHandler] %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "刪除快訊規則失敗")
+			return
+		}
+		log.Printf("資訊：[AlertHandler] 刪除快訊規則 %d '%s'", id, existing.Name)
+		writeJSON(w, http.StatusOK, map[string]string{"message": "已刪除"})
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "僅支援 PUT 或 DELETE 方法")
+	}
+}
+
+// ServeTest 處理 /api/v1/alerts/rules/{id}/test，立即寄送一則測試快訊
+func (h *AlertHandler) ServeTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "僅支援 POST 方法")
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "無效的規則 ID")
+		return
+	}
+	var videoID int64
+	if v := r.URL.Query().Get("video_id"); v != "" {
+		if videoID, err = strconv.ParseInt(v, 10, 64); err != nil || videoID <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "無效的影片 ID")
+			return
+		}
+	}
+	matched, err := h.tester.TestFire(id, videoID)
+	if err != nil {
+		log.Printf("錯誤：[AlertHandler] 測試快訊規則 %d 失敗: %v", id, err)
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "測試快訊已寄送", "matched": matched})
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"AiHackathon-admin/internal/auth"
+	"AiHackathon-admin/internal/models"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const oidcStateCookieName = "oidc_state"
+
+// LoginPageData 為登入頁的範本資料
+type LoginPageData struct {
+	Next        string
+	Error       string
+	Username    string
+	OIDCEnabled bool
+}
+
+// AuthHandler 處理登入、登出與 OIDC 單一登入
+// 路由:
+//   - GET, POST  /login
+//   - POST       /logout
+//   - GET        /auth/oidc/login
+//   - GET        /auth/oidc/callback
+type AuthHandler struct {
+	db      DBStore
+	manager *auth.Manager
+	oidc    *auth.OIDCProvider // 未啟用 OIDC 時為 nil
+	tpl     *pageTemplate
+	secure  bool
+}
+
+// NewAuthHandler 建立一個 AuthHandler 實例；oidc 可為 nil
+func NewAuthHandler(db DBStore, manager *auth.Manager, oidc *auth.OIDCProvider, cookieSecure bool, templateBasePath string) (*AuthHandler, error) {
+	if db == nil {
+		return nil, fmt.Errorf("DBStore不得為nil")
+	}
+	if manager == nil {
+		return nil, fmt.Errorf("auth.Manager不得為nil")
+	}
+	tplPath := filepath.Join(templateBasePath, "login.html")
+	tpl, err := loadPageTemplate(tplPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("無法解析登入範本 '%s': %w", tplPath, err)
+	}
+	return &AuthHandler{db: db, manager: manager, oidc: oidc, tpl: tpl, secure: cookieSecure}, nil
+}
+
+// safeNext 只允許站內相對路徑作為登入後的導向目標，避免 open redirect
+func safeNext(next string) string {
+	if next == "" || !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/dashboard"
+	}
+	return next
+}
+
+func (h *AuthHandler) renderLogin(w http.ResponseWriter, status int, data LoginPageData) {
+	data.OIDCEnabled = h.oidc != nil
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := h.tpl.Execute(w, data); err != nil {
+		log.Printf("錯誤：[AuthHandler] 渲染登入範本失敗: %v", err)
+	}
+}
+
+// ServeLogin 顯示登入頁 (GET) 或以本機帳號登入 (POST)
+func (h *AuthHandler) ServeLogin(w http.ResponseWriter, r *http.Request) {
+	if !h.manager.Enabled() {
+		http.Redirect(w, r, "/dashboard", http.StatusFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		if user, _ := h.manager.Authenticate(r); user != nil {
+			http.Redirect(w, r, safeNext(r.URL.Query().Get("next")), http.StatusFound)
+			return
+		}
+		h.renderLogin(w, http.StatusOK, LoginPageData{Next: safeNext(r.URL.Query().Get("next"))})
+	case http.MethodPost:
+		username := strings.TrimSpace(r.PostFormValue("username"))
+		password := r.PostFormValue("password")
+		next := safeNext(r.PostFormValue("next"))
+		user, err := h.db.GetUserByUsername(username)
+		if err != nil {
+			log.Printf("錯誤：[AuthHandler] %v", err)
+			h.renderLogin(w, http.StatusInternalServerError, LoginPageData{Next: next, Username: username, Error: "登入時發生錯誤，請稍後再試"})
+			return
+		}
+		if !auth.CheckPassword(user, password) {
+			log.Printf("警告：[AuthHandler] 帳號 '%s' 登入失敗 (來自 %s)", username, h.manager.ClientIP(r))
+			h.manager.Audit(r, nil, "login.failed", username, http.StatusUnauthorized, "")
+			h.renderLogin(w, http.StatusUnauthorized, LoginPageData{Next: next, Username: username, Error: "帳號或密碼錯誤"})
+			return
+		}
+		if err := h.manager.StartSession(w, r, user); err != nil {
+			log.Printf("錯誤：[AuthHandler] %v", err)
+			h.renderLogin(w, http.StatusInternalServerError, LoginPageData{Next: next, Username: username, Error: "登入時發生錯誤，請稍後再試"})
+			return
+		}
+		h.manager.Audit(r, user, "login", "local", http.StatusFound, "")
+		log.Printf("資訊：[AuthHandler] 使用者 '%s' 登入成功", user.Username)
+		http.Redirect(w, r, next, http.StatusFound)
+	default:
+		http.Error(w, "僅支援 GET 或 POST 方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// ServeLogout 登出並導向登入頁
+func (h *AuthHandler) ServeLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "僅支援 POST 方法", http.StatusMethodNotAllowed)
+		return
+	}
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		log.Printf("資訊：[AuthHandler] 使用者 '%s' 登出", user.Username)
+	}
+	h.manager.EndSession(w, r)
+	http.Redirect(w, r, "/login", http.StatusFound)
+}
+
+// ServeOIDCLogin 導向 OIDC issuer 的登入頁
+func (h *AuthHandler) ServeOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if h.oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+	state, err := auth.NewToken()
+	if err != nil {
+		log.Printf("錯誤：[AuthHandler] %v", err)
+		http.Error(w, "內部伺服器錯誤", http.StatusInternalServerError)
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+	next := safeNext(r.URL.Query().Get("next"))
+	value := url.Values{"state": {state}, "verifier": {verifier}, "next": {next}}.Encode()
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookieName, Value: value, Path: "/auth/oidc/", MaxAge: 600, HttpOnly: true, Secure: h.secure, SameSite: http.SameSiteLaxMode})
+	http.Redirect(w, r, h.oidc.AuthCodeURL(state, verifier), http.StatusFound)
+}
+
+// ServeOIDCCallback 處理 issuer 登入後的回呼
+func (h *AuthHandler) ServeOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if h.oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+	c, err := r.Cookie(oidcStateCookieName)
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookieName, Value: "", Path: "/auth/oidc/", MaxAge: -1})
+	if err != nil {
+		h.renderLogin(w, http.StatusBadRequest, LoginPageData{Error: "登入逾時，請重新登入"})
+		return
+	}
+	saved, err := url.ParseQuery(c.Value)
+	q := r.URL.Query()
+	if err != nil || saved.Get("state") == "" || saved.Get("state") != q.Get("state") {
+		h.renderLogin(w, http.StatusBadRequest, LoginPageData{Error: "登入狀態驗證失敗，請重新登入"})
+		return
+	}
+	if errCode := q.Get("error"); errCode != "" {
+		log.Printf("警告：[AuthHandler] OIDC 登入被拒絕: %s %s", errCode, q.Get("error_description"))
+		h.renderLogin(w, http.StatusUnauthorized, LoginPageData{Error: "單一登入失敗：" + errCode})
+		return
+	}
+	claims, err := h.oidc.Exchange(r.Context(), q.Get("code"), saved.Get("verifier"))
+	if err != nil {
+		log.Printf("錯誤：[AuthHandler] %v", err)
+		h.renderLogin(w, http.StatusBadGateway, LoginPageData{Error: "單一登入失敗，請稍後再試"})
+		return
+	}
+	user, err := h.oidc.ResolveUser(h.db, claims)
+	if err != nil {
+		log.Printf("警告：[AuthHandler] OIDC 使用者 '%s' 登入失敗: %v", claims.Subject, err)
+		h.manager.Audit(r, nil, "login.failed", claims.Email, http.StatusForbidden, err.Error())
+		h.renderLogin(w, http.StatusForbidden, LoginPageData{Error: err.Error()})
+		return
+	}
+	if err := h.manager.StartSession(w, r, user); err != nil {
+		log.Printf("錯誤：[AuthHandler] %v", err)
+		h.renderLogin(w, http.StatusInternalServerError, LoginPageData{Error: "登入時發生錯誤，請稍後再試"})
+		return
+	}
+	h.manager.Audit(r, user, "login", "oidc", http.StatusFound, "")
+	log.Printf("資訊：[AuthHandler] OIDC 使用者 '%s' 登入成功", user.Username)
+	http.Redirect(w, r, safeNext(saved.Get("next")), http.StatusFound)
+}
+
+// AuditPageData 為稽核紀錄頁的範本資料
+type AuditPageData struct {
+	Entries  []models.AuditLogEntry
+	Username string
+	Page     int
+	HasNext  bool
+	PrevPage int
+	NextPage int
+}
+
+// AuditHandler 顯示操作稽核紀錄 (路由: GET /admin/audit?user=&page=)
+type AuditHandler struct {
+	db  DBStore
+	tpl *pageTemplate
+}
+
+const auditPageSize = 100
+
+// NewAuditHandler 建立一個 AuditHandler 實例
+func NewAuditHandler(db DBStore, templateBasePath string) (*AuditHandler, error) {
+	if db == nil {
+		return nil, fmt.Errorf("DBStore不得為nil")
+	}
+	tplPath := filepath.Join(templateBasePath, "audit.html")
+	tpl, err := loadPageTemplate(tplPath, template.FuncMap{
+		"formatTime": func(t time.Time) string { return t.Format("2006-01-02 15:04:05") },
+	})
+	if err != nil {
+		return nil, fmt.Errorf("無法解析稽核紀錄範本 '%s': %w", tplPath, err)
+	}
+	return &AuditHandler{db: db, tpl: tpl}, nil
+}
+
+// ServeHTTP 實現 http.Handler 介面
+func (h *AuditHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "僅支援 GET 方法", http.StatusMethodNotAllowed)
+		return
+	}
+	username := r.URL.Query().Get("user")
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	entries, err := h.db.GetAuditLogs(username, auditPageSize+1, (page-1)*auditPageSize)
+	if err != nil {
+		log.Printf("錯誤：[AuditHandler] %v", err)
+		http.Error(w, "內部伺服器錯誤", http.StatusInternalServerError)
+		return
+	}
+	data := AuditPageData{Username: username, Page: page, PrevPage: page - 1, NextPage: page + 1}
+	if len(entries) > auditPageSize {
+		data.HasNext = true
+		entries = entries[:auditPageSize]
+	}
+	data.Entries = entries
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tpl.Execute(w, data); err != nil {
+		log.Printf("錯誤：[AuditHandler] 渲染稽核紀錄範本失敗: %v", err)
+	}
+}
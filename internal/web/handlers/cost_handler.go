@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"AiHackathon-admin/internal/costs"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+// maxCostReportDays 限制單次報表的查詢天數
+const maxCostReportDays = 366
+
+// CostReporter 定義了 CostHandler 產生費用報表所需的操作
+type CostReporter interface {
+	Report(from, to time.Time) (costs.Report, error)
+}
+
+// costTableData 為範本中單一彙總表格的資料
+type costTableData struct {
+	Label    string
+	Rows     []costs.Row
+	Total    costs.Row
+	Currency string
+}
+
+// CostHandler 顯示 Gemini token 用量與費用報表
+// 路由:
+//   - GET /costs            報表頁面
+//   - GET /api/v1/costs     JSON 格式的報表
+//
+// 兩者皆接受 ?from=2006-01-02&to=2006-01-02 (含當日)，省略時為本月至今。
+type CostHandler struct {
+	reporter CostReporter
+	tpl      *pageTemplate
+}
+
+// NewCostHandler 建立一個 CostHandler 實例
+func NewCostHandler(reporter CostReporter, templateBasePath string) (*CostHandler, error) {
+	if reporter == nil {
+		return nil, fmt.Errorf("CostReporter不得為nil")
+	}
+	tplPath := filepath.Join(templateBasePath, "costs.html")
+	tpl, err := loadPageTemplate(tplPath, template.FuncMap{
+		"money": func(v float64) string { return fmt.Sprintf("%.4f", v) },
+		"percentOf": func(v, total float64) string {
+			if total <= 0 {
+				return "-"
+			}
+			return fmt.Sprintf("%.1f%%", v/total*100)
+		},
+		"costTable": func(label string, rows []costs.Row, report costs.Report) costTableData {
+			return costTableData{Label: label, Rows: rows, Total: report.Total, Currency: report.Currency}
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("無法解析費用報表範本 '%s': %w", tplPath, err)
+	}
+	return &CostHandler{reporter: reporter, tpl: tpl}, nil
+}
+
+// parseRange 解析查詢期間，預設為本月第一天至今天
+func (h *CostHandler) parseRange(r *http.Request) (time.Time, time.Time, error) {
+	now := time.Now()
+	from := costs.MonthStart(now)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var err error
+	if v := r.URL.Query().Get("from"); v != "" {
+		if from, err = time.ParseInLocation("2006-01-02", v, now.Location()); err != nil {
+			return from, to, fmt.Errorf("無效的 from 日期 '%s'，格式需為 YYYY-MM-DD", v)
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, err = time.ParseInLocation("2006-01-02", v, now.Location()); err != nil {
+			return from, to, fmt.Errorf("無效的 to 日期 '%s'，格式需為 YYYY-MM-DD", v)
+		}
+	}
+	if to.Before(from) {
+		return from, to, fmt.Errorf("to 不得早於 from")
+	}
+	if to.Sub(from) > maxCostReportDays*24*time.Hour {
+		return from, to, fmt.Errorf("查詢期間不得超過 %d 天", maxCostReportDays)
+	}
+	return from, to, nil
+}
+
+// ServePage 顯示費用報表頁面
+func (h *CostHandler) ServePage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "僅支援 GET 方法", http.StatusMethodNotAllowed)
+		return
+	}
+	from, to, err := h.parseRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report, err := h.reporter.Report(from, to)
+	if err != nil {
+		log.Printf("錯誤：[CostHandler] 產生費用報表失敗: %v", err)
+		http.Error(w, "內部伺服器錯誤", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tpl.Execute(w, report); err != nil {
+		log.Printf("錯誤：[CostHandler] 渲染費用報表範本失敗: %v", err)
+	}
+}
+
+// ServeJSON 以 JSON 回傳費用報表
+func (h *CostHandler) ServeJSON(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "僅支援 GET 方法")
+		return
+	}
+	from, to, err := h.parseRange(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	report, err := h.reporter.Report(from, to)
+	if err != nil {
+		log.Printf("錯誤：[CostHandler] 產生費用報表失敗: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "產生費用報表失敗")
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
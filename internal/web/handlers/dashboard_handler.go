@@ -1,22 +1,27 @@
 package handlers
 
 import (
+	"AiHackathon-admin/internal/auth"
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/mediasign"
 	"AiHackathon-admin/internal/models"
+	"AiHackathon-admin/internal/renditions"
+	"AiHackathon-admin/internal/webproxy"
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"html/template"
 	"log"
 	"net/http"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
 // DBStore 介面更新：GetAllVideosWithAnalysis 現在接收篩選和排序參數
 type DBStore interface {
-	GetAllVideosWithAnalysis(limit int, offset int, searchTerm string, sortBy string, sortOrder string) ([]models.Video, []models.AnalysisResult, error)
+	GetAllVideosWithAnalysis(limit int, offset int, searchTerm string, sortBy string, sortOrder string, outcome string) ([]models.Video, []models.AnalysisResult, error)
 	Close() error
 	FindOrCreateVideo(video *models.Video) (int64, error)
 	SaveAnalysisResult(result *models.AnalysisResult) error
@@ -25,15 +30,67 @@ type DBStore interface {
 	GetVideoByID(videoID int64) (*models.Video, error)
 	GetVideosPendingContentAnalysis(status models.AnalysisStatus, limit int) ([]models.Video, error)
 	GetVideoBySourceID(sourceName string, sourceID string) (*models.Video, error)
+	GetAnalysisResultByVideoID(videoID int64) (*models.AnalysisResult, error)
+	GetCompletedVideosForFeed(filter models.FeedFilter, limit int) ([]models.Video, []models.AnalysisResult, error)
+	CreateWebhookDelivery(delivery *models.WebhookDelivery) (int64, error)
+	UpdateWebhookDeliveryAttempt(id int64, status models.WebhookDeliveryStatus, attempts int, statusCode sql.NullInt64, lastError sql.NullString, nextAttemptAt sql.NullTime) error
+	ClaimWebhookDeliveryReplay(id int64, nextAttemptAt time.Time) (bool, error)
+	GetWebhookDeliveryByID(id int64) (*models.WebhookDelivery, error)
+	GetWebhookDeliveriesByStatus(status models.WebhookDeliveryStatus, limit int) ([]models.WebhookDelivery, error)
+	GetAlertRules(enabledOnly bool) ([]models.AlertRule, error)
+	GetAlertRuleByID(id int64) (*models.AlertRule, error)
+	CreateAlertRule(rule *models.AlertRule) (int64, error)
+	UpdateAlertRule(rule *models.AlertRule) error
+	DeleteAlertRule(id int64) error
+	RecordAlertFiring(ruleID, videoID int64) (firingID int64, created bool, err error)
+	UpdateAlertFiringStatus(id int64, status models.AlertFiringStatus, errorMessage sql.NullString) error
+	GetAnalysisReview(videoID int64) (*models.AnalysisReview, error)
+	GetAnalysisReviews(videoIDs []int64) (map[int64]*models.AnalysisReview, error)
+	SaveAnalysisReview(review *models.AnalysisReview) error
+	GetReviewSamples() ([]models.ReviewSample, error)
+	ListPromptVersions(kind models.PromptKind) ([]models.PromptVersion, error)
+	GetPromptVersion(kind models.PromptKind, version string) (*models.PromptVersion, error)
+	CreatePromptVersion(p *models.PromptVersion) (int64, error)
+	GetActivePrompt(kind models.PromptKind) (*models.ActivePrompt, error)
+	SetActivePrompt(kind models.PromptKind, version, activatedBy string) error
+	RecordGeminiUsage(u *models.GeminiUsage) error
+	GetUsageAggregates(from, to time.Time) ([]models.UsageAggregate, error)
+	GetNASManifest() ([]models.NASFile, error)
+	SaveNASFiles(files []models.NASFile) error
+	DeleteNASFiles(ids []int64) error
+	LinkNASFiles() error
+	MarkNASFilesAnalyzed(sourceName, sourceID string, videoID int64) error
+	SaveVideoFiles(videoID int64, files []models.VideoFile) error
+	GetVideoFiles(videoIDs []int64) (map[int64][]models.VideoFile, error)
+	GetRetentionItems() ([]models.RetentionItem, error)
+	ArchiveVideo(videoID int64, reason string) error
+	SetVideoPinned(videoID int64, pinned bool, by string) error
+	GetVideosPendingThumbnails(limit int) ([]models.Video, error)
+	SaveVideoThumbnails(videoID int64, posterPath, contactSheetPath sql.NullString) error
+	GetVideosPendingWebProxy(limit int) ([]models.Video, error)
+	SaveVideoWebProxy(videoID int64, sourcePath, proxyPath sql.NullString) error
+	GetWebProxyPath(sourcePath string) (string, bool, error)
+	IsVideoRestricted(sourceName, sourceID string) (bool, error)
+	SaveMediaInfo(info *models.MediaInfo) error
+	GetMediaInfo(videoIDs []int64) (map[int64]*models.MediaInfo, error)
+
+	// 使用者、session 與稽核紀錄
+	auth.Store
+	ListUsers() ([]models.User, error)
+	DeleteUserSessions(userID int64) error
+	GetAuditLogs(username string, limit, offset int) ([]models.AuditLogEntry, error)
 }
 
 // DashboardPageData 更新：加入篩選和排序的當前值，以便在範本中設定表單預設值
 type DashboardPageData struct {
-	Videos     []VideoDisplayData
-	SearchTerm string
-	SortBy     string
-	SortOrder  string
-	Paging     PagingData // 可選：用於將來實現分頁
+	Videos      []VideoDisplayData
+	SearchTerm  string
+	SortBy      string
+	SortOrder   string
+	Outcome     string       // 依 Gemini 回應結果篩選 (例如 safety)，空字串為全部
+	Paging      PagingData   // 可選：用於將來實現分頁
+	CurrentUser *models.User // 目前登入的使用者；產生靜態頁面時為 nil
+	CanReview   bool         // 目前使用者是否可編輯審核 (editor 以上)
 }
 
 // PagingData (可選，為將來分頁做準備)
@@ -67,27 +124,109 @@ type VideoDisplayData struct {
 	PrimarySubjects          []string
 	FlagEmoji                string
 	VideoURL                 string
-	PromptVersion            string // 新增：文本 Prompt 版本
-	FilePath                 string // 新增：檔案路徑
-	Restrictions             string // 新增：限制條件
-	TranRestrictions         string // 新增：轉檔限制
+	SubtitleBaseURL          string         // 新增：字幕 API 路徑 (不含副檔名與語言參數)，為空時不顯示字幕軌
+	PromptVersion            string         // 新增：文本 Prompt 版本
+	FilePath                 string         // 新增：檔案路徑
+	Restrictions             string         // 新增：限制條件
+	TranRestrictions         string         // 新增：轉檔限制
+	Review                   *ReviewDisplay // 編輯審核狀態；沒有 AI 分析結果時為 nil
+	Renditions               []RenditionDisplay
+	Pinned                   bool          // 編輯釘選，不受保留政策刪除
+	ArchiveReason            string        // 保留政策刪除檔案的紀錄；未封存時為空
+	PosterURL                string        // 海報圖片網址；尚未產生縮圖時為空，此時播放器預先載入影片 metadata
+	ContactSheetURL          string        // 關鍵影格縮圖表網址；尚未產生時為空
+	Media                    *MediaDisplay // 由影片檔案取得的技術資訊；尚未取得時為 nil
+	OriginalURL              string        // 播放的檔案為瀏覽器無法播放的格式 (例如 .mkv) 時的原始檔下載網址；其他格式為空
+	WebProxyReady            bool          // 已產生 H.264/AAC 播放版本 (/media/ 自動提供)；false 時播放器可能無法播放
+}
+
+// MediaDisplay 儀表板顯示的影片技術資訊 (未知的欄位為空字串或 0)
+type MediaDisplay struct {
+	Resolution       string
+	VideoCodec       string
+	AudioCodec       string
+	FrameRate        string
+	AudioChannels    int
+	Size             string
+	ProbedWith       string
+	DurationMismatch bool   // TXT 記載的長度與實際長度不符
+	TxtDuration      string // TXT 記載的長度 (MM:SS)
 }
 
-// KeywordDisplay, BiteDisplay, ImportanceScoreDisplay, DisplayableAnalysisResult (保持不變)
-type KeywordDisplay struct {
-	Keyword  string `json:"keyword"`
-	Category string `json:"category"`
+// NewMediaDisplay 將技術資訊轉為儀表板顯示格式 (靜態頁面產生器共用)
+func NewMediaDisplay(info *models.MediaInfo) *MediaDisplay {
+	if info == nil {
+		return nil
+	}
+	d := &MediaDisplay{
+		Resolution: models.VideoFile{Width: info.Width, Height: info.Height}.Resolution(),
+		VideoCodec: info.VideoCodec, AudioCodec: info.AudioCodec, AudioChannels: info.AudioChannels,
+		Size: formatFileSize(info.Size), ProbedWith: info.ProbedWith, DurationMismatch: info.DurationMismatch,
+	}
+	if info.FrameRate > 0 {
+		d.FrameRate = strconv.FormatFloat(info.FrameRate, 'f', -1, 64) + " fps"
+	}
+	if info.TxtDurationSecs.Valid {
+		d.TxtDuration = fmt.Sprintf("%02d:%02d", info.TxtDurationSecs.Int64/60, info.TxtDurationSecs.Int64%60)
+	}
+	return d
 }
-type BiteDisplay struct {
-	TimeLine string `json:"time_line"`
-	Speaker  string `json:"speaker"`
-	Quote    string `json:"quote"`
+
+// RenditionDisplay 影片資料夾中的一個版本 (master/proxy/audio)
+type RenditionDisplay struct {
+	Role       models.VideoFileRole
+	Resolution string
+	Size       string
+	URL        string
+	Streaming  bool // 儀表板播放器使用的版本
 }
-type ImportanceScoreDisplay struct {
-	OverallRating     string   `json:"overall_rating"`
-	KeyFactors        []string `json:"key_factors"`
-	AssessmentDetails string   `json:"assessment_details"`
+
+// ReviewDisplay 為儀表板顯示的編輯審核資訊；*Edited 為 true 的欄位在卡片上顯示的是編輯修訂後的值
+type ReviewDisplay struct {
+	Status               models.ReviewStatus
+	Note                 string
+	EditedBy             string
+	ReviewedBy           string
+	SummaryEdited        bool
+	BitesEdited          bool
+	KeywordsEdited       bool
+	LocationEdited       bool
+	RatingEdited         bool
+	OriginalShortSummary string
+	OriginalLocation     string
+	OriginalRating       string
+	OriginalBites        []BiteDisplay
+	OriginalKeywords     []KeywordDisplay
 }
+
+// newReviewDisplay 在套用修訂前以原始的影片與分析結果建立 ReviewDisplay；review 可為 nil (尚未審核)
+func newReviewDisplay(review *models.AnalysisReview, video models.Video, result models.AnalysisResult) *ReviewDisplay {
+	if review == nil {
+		return &ReviewDisplay{Status: models.ReviewPending}
+	}
+	original := reviewFieldsOf(video, result)
+	return &ReviewDisplay{
+		Status:               review.Status,
+		Note:                 review.Note.String,
+		EditedBy:             review.EditedBy.String,
+		ReviewedBy:           review.ReviewedBy.String,
+		SummaryEdited:        review.ShortSummary.Valid,
+		BitesEdited:          len(review.Bites) > 0,
+		KeywordsEdited:       len(review.Keywords) > 0,
+		LocationEdited:       review.Location.Valid,
+		RatingEdited:         review.OverallRating.Valid,
+		OriginalShortSummary: original.ShortSummary,
+		OriginalLocation:     original.Location,
+		OriginalRating:       original.OverallRating,
+		OriginalBites:        original.Bites,
+		OriginalKeywords:     original.Keywords,
+	}
+}
+
+// KeywordDisplay, BiteDisplay, ImportanceScoreDisplay 沿用 models 中分析結果 JSON 欄位的結構
+type KeywordDisplay = models.Keyword
+type BiteDisplay = models.Bite
+type ImportanceScoreDisplay = models.ImportanceScore
 type DisplayableAnalysisResult struct {
 	Transcript              *models.JsonNullString
 	Translation             *models.JsonNullString
@@ -103,27 +242,55 @@ type DisplayableAnalysisResult struct {
 	RelatedNews             []string
 	ErrorMessage            *models.JsonNullString
 	PromptVersion           string
+	ModelName               string
+	Outcome                 string                // Gemini 回應結果 (ok/safety/max_tokens/recitation)
+	Fallback                string                // 備援分析方式 (relaxed_safety/text_only)
+	SafetyRatings           []SafetyRatingDisplay // 被阻擋時的安全評級
 	AnalysisCreatedAt       time.Time
 }
 
+// SafetyRatingDisplay 被阻擋時的單一安全評級
+type SafetyRatingDisplay struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked"`
+}
+
+// outcomeFilters 儀表板可篩選的 Gemini 回應結果 (與 analysis_results.outcome 相同)
+var outcomeFilters = map[string]bool{"ok": true, "safety": true, "max_tokens": true, "recitation": true}
+
+// outcomeFilterFromRequest 讀取 outcome 查詢參數，不認得的值視為不篩選
+func outcomeFilterFromRequest(r *http.Request) string {
+	outcome := r.URL.Query().Get("outcome")
+	if !outcomeFilters[outcome] {
+		return ""
+	}
+	return outcome
+}
+
 // DashboardHandler (保持不變)
 type DashboardHandler struct {
 	db       DBStore
-	tpl      *template.Template
+	tpl      *pageTemplate
 	basePath string
+	rules    renditions.Rules // 選擇播放版本的規則
+
+	webProxyExtensions []string          // 瀏覽器無法播放、需產生播放版本的格式 (webProxy.extensions)
+	signer             *mediasign.Signer // 簽署 /media/ 網址；mediaSigning.secret 未設定時為 nil (不簽章)
 }
 
 // NewDashboardHandler (保持不變)
-func NewDashboardHandler(db DBStore, templateBasePath string) (*DashboardHandler, error) {
+func NewDashboardHandler(db DBStore, renditionsCfg config.NASRenditionsConfig, webProxyCfg config.WebProxyConfig, signingCfg config.MediaSigningConfig, templateBasePath string) (*DashboardHandler, error) {
 	if db == nil {
 		return nil, fmt.Errorf("DBStore不得為nil")
 	}
 	tplPath := filepath.Join(templateBasePath, "dashboard.html")
-	tpl, err := template.ParseFiles(tplPath)
+	tpl, err := loadPageTemplate(tplPath, nil)
 	if err != nil {
 		return nil, fmt.Errorf("無法解析儀表板範本 '%s': %w", tplPath, err)
 	}
-	return &DashboardHandler{db: db, tpl: tpl, basePath: templateBasePath}, nil
+	return &DashboardHandler{db: db, tpl: tpl, basePath: templateBasePath, rules: renditions.RulesFromConfig(renditionsCfg), webProxyExtensions: webProxyCfg.Extensions,
+		signer: mediasign.New(signingCfg.Secret, time.Duration(signingCfg.TTLMinutes)*time.Minute)}, nil
 }
 func getFlagForLocationGo(locationString string) string { /* ... */
 	if locationString == "" {
@@ -185,6 +352,7 @@ func (h *DashboardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	searchTerm := r.URL.Query().Get("search")
 	sortBy := r.URL.Query().Get("sortBy")
 	sortOrder := r.URL.Query().Get("sortOrder")
+	outcome := outcomeFilterFromRequest(r)
 
 	// 設定預設排序（如果前端未提供）
 	if sortBy == "" {
@@ -198,7 +366,7 @@ func (h *DashboardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	limit := 50 // 暫時固定每頁數量
 	offset := 0
 
-	videos, analysisResults, err := h.db.GetAllVideosWithAnalysis(limit, offset, searchTerm, sortBy, sortOrder)
+	videos, analysisResults, err := h.db.GetAllVideosWithAnalysis(limit, offset, searchTerm, sortBy, sortOrder, outcome)
 	if err != nil {
 		log.Printf("錯誤：從資料庫獲取影片數據失敗: %v", err)
 		http.Error(w, "無法載入儀表板數據", http.StatusInternalServerError)
@@ -210,8 +378,35 @@ func (h *DashboardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	for _, ar := range analysisResults {
 		analysisResultMap[ar.VideoID] = ar
 	}
+	videoIDs := make([]int64, len(videos))
+	for i, v := range videos {
+		videoIDs[i] = v.ID
+	}
+	reviews, err := h.db.GetAnalysisReviews(videoIDs)
+	if err != nil {
+		log.Printf("錯誤：[DashboardHandler] 查詢審核紀錄失敗，將只顯示 AI 原始輸出: %v", err)
+		reviews = nil
+	}
+	videoFiles, err := h.db.GetVideoFiles(videoIDs)
+	if err != nil {
+		log.Printf("錯誤：[DashboardHandler] 查詢影片版本清單失敗，將播放 nas_path: %v", err)
+		videoFiles = nil
+	}
+	mediaInfo, err := h.db.GetMediaInfo(videoIDs)
+	if err != nil {
+		log.Printf("錯誤：[DashboardHandler] 查詢影片技術資訊失敗，將不顯示: %v", err)
+		mediaInfo = nil
+	}
 
 	for _, v := range videos {
+		// 編輯修訂優先於 AI 原始輸出；原始值保留於 ReviewDisplay 供比對
+		var reviewDisplay *ReviewDisplay
+		if ar, ok := analysisResultMap[v.ID]; ok {
+			review := reviews[v.ID]
+			reviewDisplay = newReviewDisplay(review, v, ar)
+			review.ApplyTo(&v, &ar)
+			analysisResultMap[v.ID] = ar
+		}
 		displayItem := VideoDisplayData{
 			VideoID:    v.ID,
 			SourceName: v.SourceName, // 保留原始 SourceName
@@ -222,11 +417,30 @@ func (h *DashboardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			AnalysisResult: nil, PublishedAt: v.PublishedAt, FetchedAt: v.FetchedAt,
 			DurationSecs: v.DurationSecs, ShotlistContent: v.ShotlistContent, ViewLink: v.ViewLink,
 			PrimaryLocation: v.Location.String, FlagEmoji: getFlagForLocationGo(v.Location.String),
-			VideoURL:         fmt.Sprintf("/media/%s", v.NASPath),
+			SubtitleBaseURL:  fmt.Sprintf("/api/v1/videos/%d/subtitles", v.ID),
 			PromptVersion:    v.PromptVersion,
 			FilePath:         v.NASPath,
 			Restrictions:     v.Restrictions.String,
 			TranRestrictions: v.TranRestrictions.String,
+			Review:           reviewDisplay,
+			Pinned:           v.Pinned,
+			ArchiveReason:    v.ArchiveReason.String,
+		}
+		streamPath := filepath.ToSlash(v.NASPath)
+		if f, ok := renditions.ForStream(videoFiles[v.ID], h.rules); ok {
+			streamPath = f.Path
+		}
+		displayItem.VideoURL = h.signer.URL("/media", streamPath)
+		if webproxy.Needs(streamPath, h.webProxyExtensions) {
+			displayItem.OriginalURL = appendQuery(displayItem.VideoURL, "original=1")
+			displayItem.WebProxyReady = v.WebProxyPath.Valid && v.WebProxySource.String == streamPath
+		}
+		displayItem.PosterURL, displayItem.ContactSheetURL = thumbnailURLs(h.signer, v)
+		displayItem.Media = NewMediaDisplay(mediaInfo[v.ID])
+		for _, f := range videoFiles[v.ID] {
+			displayItem.Renditions = append(displayItem.Renditions, RenditionDisplay{
+				Role: f.Role, Resolution: f.Resolution(), Size: formatFileSize(f.Size), URL: h.signer.URL("/media", f.Path), Streaming: f.Path == streamPath,
+			})
 		}
 		if v.DurationSecs.Valid {
 			displayItem.FormattedDurationMinutes = v.DurationSecs.Int64 / 60
@@ -243,7 +457,8 @@ func (h *DashboardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 		if ar, ok := analysisResultMap[v.ID]; ok {
 			displayableAR := &DisplayableAnalysisResult{
-				PromptVersion: ar.PromptVersion, Transcript: ar.Transcript, Translation: ar.Translation,
+				PromptVersion: ar.PromptVersion, ModelName: ar.ModelName, Outcome: ar.Outcome, Fallback: ar.Fallback,
+				Transcript: ar.Transcript, Translation: ar.Translation,
 				ShortSummary: ar.ShortSummary, BulletedSummary: ar.BulletedSummary,
 				VisualDescription: ar.VisualDescription, MaterialType: ar.MaterialType, ErrorMessage: ar.ErrorMessage,
 				AnalysisCreatedAt: ar.CreatedAt,
@@ -287,6 +502,7 @@ func (h *DashboardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			parseAndSet(ar.Bites, &displayableAR.Bites, "Bites")
 			parseAndSet(ar.ImportanceScore, &displayableAR.ImportanceScore, "ImportanceScore")
 			parseAndSet(ar.RelatedNews, &displayableAR.RelatedNews, "RelatedNews")
+			parseAndSet(ar.SafetyRatings, &displayableAR.SafetyRatings, "SafetyRatings")
 
 			displayItem.AnalysisResult = displayableAR
 		}
@@ -349,14 +565,59 @@ func (h *DashboardHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	// --- 結束排序修改 ---
 
+	currentUser := auth.UserFromContext(r.Context())
 	pageData := DashboardPageData{
-		Videos:     displayData,
-		SearchTerm: searchTerm,
-		SortBy:     sortBy,
-		SortOrder:  sortOrder,
+		Videos:      displayData,
+		SearchTerm:  searchTerm,
+		SortBy:      sortBy,
+		SortOrder:   sortOrder,
+		Outcome:     outcome,
+		CurrentUser: currentUser,
+		CanReview:   currentUser != nil && auth.RoleAllows(currentUser.Role, models.RoleEditor),
 	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	if err := h.tpl.Execute(w, pageData); err != nil {
 		log.Printf("錯誤：執行儀表板範本失敗: %v", err)
 	}
 }
+
+// appendQuery 在網址後附加查詢參數 (網址可能已帶有簽章參數)
+func appendQuery(u, query string) string {
+	if strings.Contains(u, "?") {
+		return u + "&" + query
+	}
+	return u + "?" + query
+}
+
+// thumbnailURLs 回傳影片海報與縮圖表的 /thumbs/ 網址 (未產生者為空，signer 不為 nil 時附帶簽章)；
+// 網址帶有產生時間作為版本，縮圖重新產生後瀏覽器不會使用舊的快取
+func thumbnailURLs(signer *mediasign.Signer, v models.Video) (poster, contactSheet string) {
+	version := ""
+	if v.ThumbnailsAt.Valid {
+		version = "v=" + strconv.FormatInt(v.ThumbnailsAt.Time.Unix(), 10)
+	}
+	thumbURL := func(p sql.NullString) string {
+		if !p.Valid {
+			return ""
+		}
+		u := signer.URL("/thumbs", p.String)
+		if version != "" {
+			u = appendQuery(u, version)
+		}
+		return u
+	}
+	return thumbURL(v.PosterPath), thumbURL(v.ContactSheetPath)
+}
+
+// formatFileSize 以 KB/MB/GB 顯示檔案大小
+func formatFileSize(size int64) string {
+	switch {
+	case size < 1024:
+		return fmt.Sprintf("%d bytes", size)
+	case size < 1024*1024:
+		return fmt.Sprintf("%.2f KB", float64(size)/1024)
+	case size < 1024*1024*1024:
+		return fmt.Sprintf("%.2f MB", float64(size)/(1024*1024))
+	}
+	return fmt.Sprintf("%.2f GB", float64(size)/(1024*1024*1024))
+}
@@ -50,12 +50,18 @@ func (h *ExportHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 獲取所有影片資料（不分頁）
-	videos, analysisResults, err := h.db.GetAllVideosWithAnalysis(1000, 0, searchTerm, sortBy, sortOrder)
+	videos, analysisResults, err := h.db.GetAllVideosWithAnalysis(1000, 0, searchTerm, sortBy, sortOrder, outcomeFilterFromRequest(r))
 	if err != nil {
 		log.Printf("錯誤：[ExportHandler] 從資料庫獲取影片數據失敗: %v", err)
 		http.Error(w, "無法獲取匯出數據", http.StatusInternalServerError)
 		return
 	}
+	// 匯出優先使用編輯修訂後的內容
+	if err := ApplyAnalysisReviews(h.db, videos, analysisResults); err != nil {
+		log.Printf("錯誤：[ExportHandler] 套用編輯修訂失敗: %v", err)
+		http.Error(w, "無法獲取匯出數據", http.StatusInternalServerError)
+		return
+	}
 
 	// 新增除錯資訊
 	log.Printf("資訊：[ExportHandler] 獲取到 %d 個影片和 %d 個分析結果", len(videos), len(analysisResults))
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/feeds"
+	"AiHackathon-admin/internal/models"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// FeedHandler 提供最新完成分析影片的 Atom 訂閱源
+// 路由: /feeds/{filter}.atom，filter 可為：
+//   - all                 所有已完成分析的影片
+//   - source-{來源}        例如 source-ap
+//   - rating-{評級}        例如 rating-S、rating-SA
+//   - topic-{主題}         例如 topic-國際
+//   - search-{名稱}        設定檔 feeds.savedSearches 中的具名搜尋
+//
+// 另可透過查詢參數 source、rating、topic 進一步組合篩選條件。
+type FeedHandler struct {
+	db      DBStore
+	cfg     config.FeedsConfig
+	baseURL string
+}
+
+// NewFeedHandler 建立一個 FeedHandler 實例
+func NewFeedHandler(db DBStore, feedsCfg config.FeedsConfig, exportCfg config.ExportConfig) *FeedHandler {
+	if db == nil {
+		log.Panicln("FeedHandler：DBStore 不得為空")
+	}
+	if feedsCfg.MaxItems <= 0 {
+		feedsCfg.MaxItems = 50
+	}
+	return &FeedHandler{db: db, cfg: feedsCfg, baseURL: strings.TrimRight(exportCfg.PublicBaseURL, "/")}
+}
+
+// parseFeedFilter 將路徑中的篩選名稱轉換為 models.FeedFilter 與訂閱源標題
+func (h *FeedHandler) parseFeedFilter(name string) (models.FeedFilter, string, error) {
+	var filter models.FeedFilter
+	if name == "all" {
+		return filter, "所有已完成分析的影片", nil
+	}
+	kind, value, ok := strings.Cut(name, "-")
+	if !ok || value == "" {
+		return filter, "", fmt.Errorf("無效的訂閱源篩選: %s", name)
+	}
+	switch kind {
+	case "source":
+		filter.SourceName = value
+		return filter, fmt.Sprintf("來源 %s", strings.ToUpper(value)), nil
+	case "rating":
+		ratings, err := parseFeedRatings(value)
+		if err != nil {
+			return filter, "", err
+		}
+		filter.Ratings = ratings
+		return filter, fmt.Sprintf("評級 %s", strings.Join(filter.Ratings, "/")), nil
+	case "topic":
+		filter.Topic = value
+		return filter, fmt.Sprintf("主題 %s", value), nil
+	case "search":
+		term, ok := h.cfg.SavedSearches[value]
+		if !ok {
+			return filter, "", fmt.Errorf("找不到儲存搜尋: %s", value)
+		}
+		filter.SearchTerm = term
+		return filter, fmt.Sprintf("儲存搜尋 %s", value), nil
+	}
+	return filter, "", fmt.Errorf("無效的訂閱源篩選類型: %s", kind)
+}
+
+// parseFeedRatings 將評級字串 (例如 "SA") 拆為個別評級，只接受 S/A/B/C/N (不分大小寫)
+func parseFeedRatings(value string) ([]string, error) {
+	var ratings []string
+	for _, r := range strings.ToUpper(value) {
+		if !strings.ContainsRune("SABCN", r) {
+			return nil, fmt.Errorf("無效的評級: %c", r)
+		}
+		ratings = append(ratings, string(r))
+	}
+	return ratings, nil
+}
+
+// etagMatches 判斷 If-None-Match 標頭是否符合 etag：標頭可列出多個以逗號分隔的 ETag，
+// 依弱比較忽略 W/ 前綴，"*" 符合任何 ETag
+func etagMatches(header string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// requestBaseURL 回傳對外網址前綴，未設定 export.publicBaseURL 時由請求推斷
+func (h *FeedHandler) requestBaseURL(r *http.Request) string {
+	if h.baseURL != "" {
+		return h.baseURL
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// ServeHTTP 實現 http.Handler 介面
+func (h *FeedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "僅支援 GET 方法", http.StatusMethodNotAllowed)
+		return
+	}
+	file := r.PathValue("file")
+	name, ok := strings.CutSuffix(file, ".atom")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	filter, title, err := h.parseFeedFilter(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	q := r.URL.Query()
+	if v := q.Get("source"); v != "" {
+		filter.SourceName = v
+	}
+	if v := q.Get("rating"); v != "" {
+		ratings, err := parseFeedRatings(v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.Ratings = ratings
+	}
+	if v := q.Get("topic"); v != "" {
+		filter.Topic = v
+	}
+
+	videos, results, err := h.db.GetCompletedVideosForFeed(filter, h.cfg.MaxItems)
+	if err != nil {
+		log.Printf("錯誤：[FeedHandler] 查詢訂閱源 '%s' 失敗: %v", name, err)
+		http.Error(w, "內部伺服器錯誤", http.StatusInternalServerError)
+		return
+	}
+	// 套用編輯修訂 (修訂時間會反映在 ETag / Last-Modified)
+	if err := ApplyAnalysisReviews(h.db, videos, results); err != nil {
+		log.Printf("錯誤：[FeedHandler] 套用編輯修訂失敗: %v", err)
+		http.Error(w, "內部伺服器錯誤", http.StatusInternalServerError)
+		return
+	}
+
+	// 以項目 ID 與更新時間計算 ETag，任一項目變動或新增都會改變
+	var lastModified time.Time
+	hasher := sha256.New()
+	fmt.Fprintf(hasher, "%s?%s|", name, r.URL.RawQuery)
+	for i, v := range videos {
+		updated := feeds.EntryUpdated(v, results[i])
+		if updated.After(lastModified) {
+			lastModified = updated
+		}
+		fmt.Fprintf(hasher, "%d:%d|", v.ID, updated.UnixNano())
+	}
+	etag := `"` + hex.EncodeToString(hasher.Sum(nil))[:32] + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if etagMatches(inm, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil && !lastModified.Truncate(time.Second).After(t) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	baseURL := h.requestBaseURL(r)
+	feed := feeds.Feed{
+		ID:       "urn:aihackathon-admin:feed:" + name,
+		Title:    "AiHackathon 影片分析 - " + title,
+		Subtitle: "最新完成分析的影片",
+		SelfURL:  baseURL + r.URL.RequestURI(),
+		BaseURL:  baseURL,
+	}
+	var buf bytes.Buffer
+	if err := feeds.Render(&buf, feed, videos, results); err != nil {
+		log.Printf("錯誤：[FeedHandler] 產生訂閱源 '%s' 失敗: %v", name, err)
+		http.Error(w, "無法產生訂閱源", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	if r.Method == http.MethodGet {
+		w.Write(buf.Bytes())
+	}
+}
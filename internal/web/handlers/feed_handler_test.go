@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/models"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeFeedDB 回傳固定的已完成影片，並記錄最後一次查詢的篩選條件
+type fakeFeedDB struct {
+	DBStore
+
+	videos  []models.Video
+	results []models.AnalysisResult
+	reviews map[int64]*models.AnalysisReview
+	filter  *models.FeedFilter
+	limit   int
+}
+
+func (db *fakeFeedDB) GetCompletedVideosForFeed(filter models.FeedFilter, limit int) ([]models.Video, []models.AnalysisResult, error) {
+	db.filter, db.limit = &filter, limit
+	videos := append([]models.Video(nil), db.videos...)
+	results := append([]models.AnalysisResult(nil), db.results...)
+	return videos, results, nil
+}
+
+func (db *fakeFeedDB) GetAnalysisReviews(videoIDs []int64) (map[int64]*models.AnalysisReview, error) {
+	reviews := make(map[int64]*models.AnalysisReview)
+	for id, r := range db.reviews {
+		copied := *r
+		reviews[id] = &copied
+	}
+	return reviews, nil
+}
+
+func newFeedTestDB() *fakeFeedDB {
+	analyzed := time.Date(2024, 9, 2, 8, 30, 0, 0, time.UTC)
+	return &fakeFeedDB{
+		videos: []models.Video{
+			{ID: 11, SourceName: "ap", SourceID: "4521987", Title: sql.NullString{String: "Typhoon makes landfall", Valid: true},
+				AnalyzedAt: sql.NullTime{Time: analyzed, Valid: true}},
+			{ID: 12, SourceName: "reuters", SourceID: "RW123", AnalyzedAt: sql.NullTime{Time: analyzed.Add(-time.Hour), Valid: true}},
+		},
+		results: []models.AnalysisResult{
+			{VideoID: 11, ImportanceScore: json.RawMessage(`{"overall_rating":"S"}`), UpdatedAt: analyzed.Add(-time.Minute),
+				ShortSummary: &models.JsonNullString{NullString: sql.NullString{String: "颱風登陸", Valid: true}}},
+			{VideoID: 12, UpdatedAt: analyzed.Add(-2 * time.Hour)},
+		},
+	}
+}
+
+func serveFeed(h http.Handler, target string, header http.Header) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.Handle("/feeds/{file}", h)
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w
+}
+
+func TestFeedHandlerFilters(t *testing.T) {
+	feedsCfg := config.FeedsConfig{MaxItems: 20, SavedSearches: map[string]string{"typhoon": "颱風"}}
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantFilter models.FeedFilter
+		wantTitle  string
+	}{
+		{name: "全部", target: "/feeds/all.atom", wantStatus: http.StatusOK, wantTitle: "所有已完成分析的影片"},
+		{name: "來源", target: "/feeds/source-ap.atom", wantStatus: http.StatusOK, wantFilter: models.FeedFilter{SourceName: "ap"}, wantTitle: "來源 AP"},
+		{name: "多個評級", target: "/feeds/rating-sa.atom", wantStatus: http.StatusOK, wantFilter: models.FeedFilter{Ratings: []string{"S", "A"}}, wantTitle: "評級 S/A"},
+		{name: "主題", target: "/feeds/topic-%E5%9C%8B%E9%9A%9B.atom", wantStatus: http.StatusOK, wantFilter: models.FeedFilter{Topic: "國際"}, wantTitle: "主題 國際"},
+		{name: "儲存搜尋", target: "/feeds/search-typhoon.atom", wantStatus: http.StatusOK, wantFilter: models.FeedFilter{SearchTerm: "颱風"}, wantTitle: "儲存搜尋 typhoon"},
+		{
+			name:       "查詢參數組合篩選",
+			target:     "/feeds/rating-S.atom?source=reuters&rating=bc&topic=Sports",
+			wantStatus: http.StatusOK,
+			wantFilter: models.FeedFilter{SourceName: "reuters", Ratings: []string{"B", "C"}, Topic: "Sports"},
+			wantTitle:  "評級 S",
+		},
+		{name: "無效的查詢評級", target: "/feeds/all.atom?rating=SX", wantStatus: http.StatusBadRequest},
+		{name: "無效的路徑評級", target: "/feeds/rating-SX.atom", wantStatus: http.StatusNotFound},
+		{name: "找不到儲存搜尋", target: "/feeds/search-missing.atom", wantStatus: http.StatusNotFound},
+		{name: "無效的篩選類型", target: "/feeds/author-x.atom", wantStatus: http.StatusNotFound},
+		{name: "缺少篩選值", target: "/feeds/source-.atom", wantStatus: http.StatusNotFound},
+		{name: "不是 .atom", target: "/feeds/all.xml", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFeedTestDB()
+			h := NewFeedHandler(db, feedsCfg, config.ExportConfig{PublicBaseURL: "https://admin.example.com/"})
+			w := serveFeed(h, tt.target, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("狀態碼 = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				if db.filter != nil {
+					t.Errorf("無效的篩選不應查詢資料庫: %+v", *db.filter)
+				}
+				return
+			}
+			if db.filter == nil || !reflect.DeepEqual(*db.filter, tt.wantFilter) || db.limit != 20 {
+				t.Errorf("篩選條件 = %+v (limit %d), want %+v", db.filter, db.limit, tt.wantFilter)
+			}
+			body := w.Body.String()
+			if !strings.Contains(body, "<title>AiHackathon 影片分析 - "+tt.wantTitle+"</title>") {
+				t.Errorf("訂閱源標題不符: %s", body)
+			}
+			if !strings.Contains(body, `href="https://admin.example.com/dashboard?search=4521987#video-11"`) {
+				t.Errorf("項目連結應使用 export.publicBaseURL: %s", body)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/atom+xml; charset=utf-8" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+		})
+	}
+}
+
+func TestFeedHandlerMethod(t *testing.T) {
+	h := NewFeedHandler(newFeedTestDB(), config.FeedsConfig{}, config.ExportConfig{})
+	mux := http.NewServeMux()
+	mux.Handle("/feeds/{file}", h)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/feeds/all.atom", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST 狀態碼 = %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/feeds/all.atom", nil))
+	if w.Code != http.StatusOK || w.Body.Len() != 0 || w.Header().Get("ETag") == "" {
+		t.Errorf("HEAD: 狀態碼 %d，內容 %d bytes，ETag %q", w.Code, w.Body.Len(), w.Header().Get("ETag"))
+	}
+}
+
+func TestFeedHandlerConditionalGet(t *testing.T) {
+	db := newFeedTestDB()
+	h := NewFeedHandler(db, config.FeedsConfig{}, config.ExportConfig{})
+
+	first := serveFeed(h, "/feeds/all.atom", nil)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || len(etag) != 34 || !strings.HasPrefix(etag, `"`) {
+		t.Fatalf("第一次請求: 狀態碼 %d，ETag %q", first.Code, etag)
+	}
+	// Last-Modified 為所有項目中最新的更新時間 (影片分析時間與分析結果更新時間取較新者)
+	if lm := first.Header().Get("Last-Modified"); lm != "Mon, 02 Sep 2024 08:30:00 GMT" {
+		t.Errorf("Last-Modified = %q", lm)
+	}
+	if cc := first.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("Cache-Control = %q", cc)
+	}
+	if again := serveFeed(h, "/feeds/all.atom", nil).Header().Get("ETag"); again != etag {
+		t.Errorf("內容未變動時 ETag 應相同: %s / %s", again, etag)
+	}
+
+	tests := []struct {
+		name       string
+		header     http.Header
+		wantStatus int
+	}{
+		{name: "ETag 相同", header: http.Header{"If-None-Match": {etag}}, wantStatus: http.StatusNotModified},
+		{name: "弱 ETag", header: http.Header{"If-None-Match": {"W/" + etag}}, wantStatus: http.StatusNotModified},
+		{name: "多個 ETag", header: http.Header{"If-None-Match": {`"stale",  ` + etag + ` , W/"other"`}}, wantStatus: http.StatusNotModified},
+		{name: "萬用字元", header: http.Header{"If-None-Match": {"*"}}, wantStatus: http.StatusNotModified},
+		{name: "ETag 不同", header: http.Header{"If-None-Match": {`"stale", W/"other"`}}, wantStatus: http.StatusOK},
+		{name: "未修改", header: http.Header{"If-Modified-Since": {"Mon, 02 Sep 2024 08:30:00 GMT"}}, wantStatus: http.StatusNotModified},
+		{name: "之後已修改", header: http.Header{"If-Modified-Since": {"Mon, 02 Sep 2024 08:29:59 GMT"}}, wantStatus: http.StatusOK},
+		{name: "無效的日期", header: http.Header{"If-Modified-Since": {"yesterday"}}, wantStatus: http.StatusOK},
+		{
+			// 同時帶有兩個條件時以 If-None-Match 為準
+			name:       "ETag 不同但未修改",
+			header:     http.Header{"If-None-Match": {`"stale"`}, "If-Modified-Since": {"Mon, 02 Sep 2024 08:30:00 GMT"}},
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveFeed(h, "/feeds/all.atom", tt.header)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("狀態碼 = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if w.Header().Get("ETag") != etag {
+				t.Errorf("ETag = %q, want %q", w.Header().Get("ETag"), etag)
+			}
+			if tt.wantStatus == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("304 回應不應有內容: %s", w.Body.String())
+			}
+		})
+	}
+
+	// 篩選條件不同的訂閱源有不同的 ETag
+	if other := serveFeed(h, "/feeds/all.atom?source=ap", nil).Header().Get("ETag"); other == etag {
+		t.Error("查詢參數不同時 ETag 應不同")
+	}
+	// 編輯修訂會改變 ETag 與 Last-Modified
+	edited := time.Date(2024, 9, 3, 1, 0, 0, 0, time.UTC)
+	db.reviews = map[int64]*models.AnalysisReview{12: {VideoID: 12, OverallRating: sql.NullString{String: "A", Valid: true}, UpdatedAt: edited}}
+	w := serveFeed(h, "/feeds/all.atom", http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("修訂後: 狀態碼 %d，ETag %q", w.Code, w.Header().Get("ETag"))
+	}
+	if lm := w.Header().Get("Last-Modified"); lm != "Tue, 03 Sep 2024 01:00:00 GMT" {
+		t.Errorf("修訂後 Last-Modified = %q", lm)
+	}
+	// 新增項目時 ETag 改變
+	db.reviews = nil
+	db.videos = append(db.videos, models.Video{ID: 13, SourceName: "ap", SourceID: "4521990"})
+	db.results = append(db.results, models.AnalysisResult{VideoID: 13, UpdatedAt: time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)})
+	if w := serveFeed(h, "/feeds/all.atom", http.Header{"If-None-Match": {etag}}); w.Code != http.StatusOK {
+		t.Errorf("新增項目後應回傳完整內容，got %d", w.Code)
+	}
+
+	// 沒有任何項目時不設定 Last-Modified
+	empty := NewFeedHandler(&fakeFeedDB{}, config.FeedsConfig{}, config.ExportConfig{})
+	if w := serveFeed(empty, "/feeds/all.atom", nil); w.Code != http.StatusOK || w.Header().Get("Last-Modified") != "" {
+		t.Errorf("空的訂閱源: 狀態碼 %d，Last-Modified %q", w.Code, w.Header().Get("Last-Modified"))
+	}
+}
+
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		etag   string
+		want   bool
+	}{
+		{header: `"abc"`, etag: `"abc"`, want: true},
+		{header: `W/"abc"`, etag: `"abc"`, want: true},
+		{header: `"abc"`, etag: `W/"abc"`, want: true},
+		{header: `"x","abc"`, etag: `"abc"`, want: true},
+		{header: ` "x" , *`, etag: `"abc"`, want: true},
+		{header: `"abcd"`, etag: `"abc"`, want: false},
+		{header: `abc`, etag: `"abc"`, want: false},
+		{header: ``, etag: `"abc"`, want: false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, tt.etag); got != tt.want {
+			t.Errorf("etagMatches(%q, %q) = %t, want %t", tt.header, tt.etag, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/mediasign"
+	"AiHackathon-admin/internal/models"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MediaURLHandler 為影片的各版本產生 /media/ 網址，並為海報與縮圖表產生 /thumbs/ 網址 (設定 mediaSigning.secret 時附帶簽章與到期時間)
+// 路由: GET /api/v1/videos/{id}/media
+type MediaURLHandler struct {
+	db          DBStore
+	signer      *mediasign.Signer
+	urlPrefix   string // export.publicBaseURL + /media；未設定時為相對路徑 /media
+	thumbPrefix string // export.publicBaseURL + /thumbs
+}
+
+// mediaURLFile 單一版本的網址
+type mediaURLFile struct {
+	Path string               `json:"path"`
+	Role models.VideoFileRole `json:"role"`
+	URL  string               `json:"url"`
+}
+
+// NewMediaURLHandler 建立一個 MediaURLHandler 實例
+func NewMediaURLHandler(db DBStore, exportCfg config.ExportConfig, signingCfg config.MediaSigningConfig) *MediaURLHandler {
+	if db == nil {
+		log.Panicln("MediaURLHandler：DBStore 不得為空")
+	}
+	return &MediaURLHandler{
+		db:          db,
+		signer:      mediasign.New(signingCfg.Secret, time.Duration(signingCfg.TTLMinutes)*time.Minute),
+		urlPrefix:   strings.TrimRight(exportCfg.PublicBaseURL, "/") + "/media",
+		thumbPrefix: strings.TrimRight(exportCfg.PublicBaseURL, "/") + "/thumbs",
+	}
+}
+
+// ServeHTTP 實現 http.Handler 介面；沒有版本清單的影片回傳 nas_path 的網址
+func (h *MediaURLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "僅支援 GET 方法")
+		return
+	}
+	videoID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || videoID <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "無效的影片 ID")
+		return
+	}
+	video, err := h.db.GetVideoByID(videoID)
+	if err != nil {
+		log.Printf("錯誤：[MediaURLHandler] 查詢影片 ID %d 失敗: %v", videoID, err)
+		writeJSONError(w, http.StatusInternalServerError, "查詢影片失敗")
+		return
+	}
+	if video == nil {
+		writeJSONError(w, http.StatusNotFound, "找不到影片")
+		return
+	}
+	files, err := h.db.GetVideoFiles([]int64{videoID})
+	if err != nil {
+		log.Printf("錯誤：[MediaURLHandler] %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "查詢影片版本失敗")
+		return
+	}
+	versions := files[videoID]
+	if len(versions) == 0 && !strings.HasSuffix(strings.ToLower(video.NASPath), ".txt") {
+		versions = []models.VideoFile{{Path: filepath.ToSlash(video.NASPath), Role: models.VideoFileMaster}}
+	}
+
+	resp := map[string]interface{}{
+		"video_id":   videoID,
+		"restricted": strings.TrimSpace(video.Restrictions.String) != "",
+		"signed":     h.signer != nil,
+	}
+	expires := time.Now()
+	if h.signer != nil {
+		expires = expires.Add(h.signer.TTL()).Truncate(time.Second)
+		resp["expires_at"] = expires
+	}
+	out := make([]mediaURLFile, 0, len(versions))
+	for _, f := range versions {
+		out = append(out, mediaURLFile{Path: f.Path, Role: f.Role, URL: h.signer.URLUntil(h.urlPrefix, f.Path, expires)})
+	}
+	resp["files"] = out
+	if video.PosterPath.Valid {
+		resp["poster_url"] = h.signer.URLUntil(h.thumbPrefix, video.PosterPath.String, expires)
+	}
+	if video.ContactSheetPath.Valid {
+		resp["contact_sheet_url"] = h.signer.URLUntil(h.thumbPrefix, video.ContactSheetPath.String, expires)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
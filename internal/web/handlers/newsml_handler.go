@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/mediasign"
+	"AiHackathon-admin/internal/models"
+	"AiHackathon-admin/internal/newsml"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewsMLHandler 負責將分析過的影片匯出為 NewsML-G2 XML
+// 路由:
+//   - /api/v1/videos/{id}/newsml.xml  單一項目
+//   - /export/newsml.zip              依篩選條件批次匯出 (參數同 /export)
+type NewsMLHandler struct {
+	db   DBStore
+	opts newsml.Options
+}
+
+// NewNewsMLHandler 建立一個 NewsMLHandler 實例
+// rendition 連結依 mediaSigning 簽章，有效期間為 exportTTLHours (匯出的 XML 通常由其他系統稍後才讀取)
+func NewNewsMLHandler(db DBStore, exportCfg config.ExportConfig, signingCfg config.MediaSigningConfig) *NewsMLHandler {
+	if db == nil {
+		log.Panicln("NewsMLHandler：DBStore 不得為空")
+	}
+	mediaBaseURL := ""
+	if exportCfg.PublicBaseURL != "" {
+		mediaBaseURL = strings.TrimRight(exportCfg.PublicBaseURL, "/") + "/media"
+	}
+	opts := newsml.Options{
+		GUIDPrefix:   exportCfg.NewsMLGUIDPrefix,
+		MediaBaseURL: mediaBaseURL,
+	}
+	if signer := mediasign.New(signingCfg.Secret, 0); signer != nil {
+		ttl := time.Duration(signingCfg.ExportTTLHours) * time.Hour
+		opts.MediaQuery = func(nasPath string) string { return signer.Query(nasPath, time.Now().Add(ttl)) }
+	}
+	return &NewsMLHandler{db: db, opts: opts}
+}
+
+// ServeItem 輸出單一影片的 NewsML-G2 XML
+func (h *NewsMLHandler) ServeItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "僅支援 GET 方法", http.StatusMethodNotAllowed)
+		return
+	}
+	videoID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || videoID <= 0 {
+		http.Error(w, "無效的影片 ID", http.StatusBadRequest)
+		return
+	}
+	video, err := h.db.GetVideoByID(videoID)
+	if err != nil {
+		log.Printf("錯誤：[NewsMLHandler] 查詢影片 ID %d 失敗: %v", videoID, err)
+		http.Error(w, "內部伺服器錯誤", http.StatusInternalServerError)
+		return
+	}
+	if video == nil {
+		http.NotFound(w, r)
+		return
+	}
+	result, err := h.db.GetAnalysisResultByVideoID(videoID)
+	if err != nil {
+		log.Printf("錯誤：[NewsMLHandler] 查詢影片 ID %d 的分析結果失敗: %v", videoID, err)
+		http.Error(w, "內部伺服器錯誤", http.StatusInternalServerError)
+		return
+	}
+	review, err := h.db.GetAnalysisReview(videoID)
+	if err != nil {
+		log.Printf("錯誤：[NewsMLHandler] %v", err)
+		http.Error(w, "內部伺服器錯誤", http.StatusInternalServerError)
+		return
+	}
+	review.ApplyTo(video, result)
+
+	var buf bytes.Buffer
+	if err := newsml.Render(&buf, *video, result, h.opts); err != nil {
+		log.Printf("錯誤：[NewsMLHandler] %v", err)
+		http.Error(w, "無法產生 NewsML-G2", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.iptc.g2.newsitem+xml; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", newsml.ItemFileName(*video)))
+	w.Write(buf.Bytes())
+}
+
+// ServeBatch 依篩選條件將多個影片匯出為 zip，每個項目一個 XML 檔
+func (h *NewsMLHandler) ServeBatch(w http.ResponseWriter, r *http.Request) {
+	log.Printf("資訊：[NewsMLHandler] 收到批次匯出請求: %s %s 來自 %s\n", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method != http.MethodGet {
+		http.Error(w, "僅支援 GET 方法", http.StatusMethodNotAllowed)
+		return
+	}
+	searchTerm := r.URL.Query().Get("search")
+	sortBy := r.URL.Query().Get("sortBy")
+	sortOrder := r.URL.Query().Get("sortOrder")
+	if sortBy == "" {
+		sortBy = "importance"
+	}
+	if sortOrder == "" {
+		sortOrder = "desc"
+	}
+
+	videos, analysisResults, err := h.db.GetAllVideosWithAnalysis(1000, 0, searchTerm, sortBy, sortOrder, outcomeFilterFromRequest(r))
+	if err != nil {
+		log.Printf("錯誤：[NewsMLHandler] 從資料庫獲取影片數據失敗: %v", err)
+		http.Error(w, "無法獲取匯出數據", http.StatusInternalServerError)
+		return
+	}
+	if err := ApplyAnalysisReviews(h.db, videos, analysisResults); err != nil {
+		log.Printf("錯誤：[NewsMLHandler] 套用編輯修訂失敗: %v", err)
+		http.Error(w, "無法獲取匯出數據", http.StatusInternalServerError)
+		return
+	}
+	analysisResultMap := make(map[int64]*models.AnalysisResult)
+	for i := range analysisResults {
+		analysisResultMap[analysisResults[i].VideoID] = &analysisResults[i]
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	usedNames := make(map[string]int)
+	for _, v := range videos {
+		name := newsml.ItemFileName(v)
+		if n := usedNames[name]; n > 0 {
+			name = fmt.Sprintf("%s_%d.xml", strings.TrimSuffix(name, ".xml"), n)
+		}
+		usedNames[newsml.ItemFileName(v)]++
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			log.Printf("錯誤：[NewsMLHandler] 建立 zip 項目 '%s' 失敗: %v", name, err)
+			http.Error(w, "無法產生匯出檔案", http.StatusInternalServerError)
+			return
+		}
+		if err := newsml.Render(f, v, analysisResultMap[v.ID], h.opts); err != nil {
+			log.Printf("錯誤：[NewsMLHandler] %v", err)
+			http.Error(w, "無法產生匯出檔案", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("錯誤：[NewsMLHandler] 關閉 zip 失敗: %v", err)
+		http.Error(w, "無法產生匯出檔案", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("資訊：[NewsMLHandler] 批次匯出 %d 個項目 (%d bytes)", len(videos), buf.Len())
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=newsml_%s.zip", time.Now().Format("2006-01-02")))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
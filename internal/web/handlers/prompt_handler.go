@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"AiHackathon-admin/internal/auth"
+	"AiHackathon-admin/internal/models"
+	"AiHackathon-admin/internal/prompttpl"
+	"AiHackathon-admin/internal/textdiff"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// promptVersionPattern 限制版本名稱可用的字元 (會出現在 URL 路徑中)
+var promptVersionPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// PromptKindOption 為 prompt 類型與其顯示名稱
+type PromptKindOption struct {
+	Kind  models.PromptKind
+	Label string
+}
+
+// promptKindLabels 各分析類型於頁面上的顯示名稱
+var promptKindLabels = []PromptKindOption{
+	{models.PromptKindVideo, "影片內容分析"},
+	{models.PromptKindText, "文本元數據分析"},
+}
+
+// PromptVersionDisplay 為 prompt 管理頁面中單一版本的顯示資料
+type PromptVersionDisplay struct {
+	models.PromptVersion
+	IsActive bool
+}
+
+// PromptPageData 為 prompt 管理頁面的範本資料
+type PromptPageData struct {
+	Kind      models.PromptKind
+	KindLabel string
+	Kinds     []PromptKindOption
+	Versions  []PromptVersionDisplay
+	Active    *models.ActivePrompt
+	From      string
+	To        string
+	Diff      []textdiff.Line
+	DiffStats textdiff.Stats
+}
+
+// promptCreateRequest 為新增 prompt 版本的請求內容
+type promptCreateRequest struct {
+	Version     string `json:"version"`
+	BaseVersion string `json:"base_version"`
+	Content     string `json:"content"`
+	Notes       string `json:"notes"`
+	Activate    bool   `json:"activate"`
+}
+
+// PromptHandler 提供 prompt 版本管理頁面與 JSON API
+// 路由:
+//   - GET        /admin/prompts?kind=&from=&to=               管理頁面 (from/to 指定時顯示兩版本的差異)
+//   - GET, POST  /api/v1/prompts/{kind}                       列出 / 新增版本
+//   - POST       /api/v1/prompts/{kind}/diff                  預覽未儲存內容與指定版本的差異
+//   - GET        /api/v1/prompts/{kind}/{version}             查詢單一版本 (含內容)
+//   - POST       /api/v1/prompts/{kind}/{version}/activate    設為目前使用的版本，下一次分析即生效
+type PromptHandler struct {
+	db  DBStore
+	tpl *pageTemplate
+}
+
+// NewPromptHandler 建立一個 PromptHandler 實例
+func NewPromptHandler(db DBStore, templateBasePath string) (*PromptHandler, error) {
+	if db == nil {
+		return nil, fmt.Errorf("DBStore不得為nil")
+	}
+	tplPath := filepath.Join(templateBasePath, "prompts.html")
+	tpl, err := loadPageTemplate(tplPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("無法解析 prompt 管理範本 '%s': %w", tplPath, err)
+	}
+	return &PromptHandler{db: db, tpl: tpl}, nil
+}
+
+func parsePromptKind(s string) (models.PromptKind, bool) {
+	switch models.PromptKind(s) {
+	case models.PromptKindText:
+		return models.PromptKindText, true
+	case models.PromptKindVideo:
+		return models.PromptKindVideo, true
+	}
+	return "", false
+}
+
+func requestUsername(r *http.Request) string {
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		return user.Username
+	}
+	return "anonymous"
+}
+
+// ServePage 顯示 prompt 管理頁面
+func (h *PromptHandler) ServePage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "僅支援 GET 方法", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	kind := models.PromptKindVideo
+	if k := query.Get("kind"); k != "" {
+		var ok bool
+		if kind, ok = parsePromptKind(k); !ok {
+			http.Error(w, "kind 參數僅支援 text 或 video", http.StatusBadRequest)
+			return
+		}
+	}
+	data := PromptPageData{Kind: kind, Kinds: promptKindLabels, From: query.Get("from"), To: query.Get("to")}
+	for _, k := range promptKindLabels {
+		if k.Kind == kind {
+			data.KindLabel = k.Label
+		}
+	}
+
+	versions, err := h.db.ListPromptVersions(kind)
+	if err == nil {
+		data.Active, err = h.db.GetActivePrompt(kind)
+	}
+	if err != nil {
+		log.Printf("錯誤：[PromptHandler] %v", err)
+		http.Error(w, "內部伺服器錯誤", http.StatusInternalServerError)
+		return
+	}
+	for _, v := range versions {
+		data.Versions = append(data.Versions, PromptVersionDisplay{PromptVersion: v, IsActive: data.Active != nil && data.Active.Version == v.Version})
+	}
+
+	if data.From != "" && data.To != "" {
+		from, err := h.db.GetPromptVersion(kind, data.From)
+		var to *models.PromptVersion
+		if err == nil {
+			to, err = h.db.GetPromptVersion(kind, data.To)
+		}
+		if err != nil {
+			log.Printf("錯誤：[PromptHandler] %v", err)
+			http.Error(w, "內部伺服器錯誤", http.StatusInternalServerError)
+			return
+		}
+		if from == nil || to == nil {
+			http.Error(w, "找不到指定的 prompt 版本", http.StatusNotFound)
+			return
+		}
+		data.Diff = textdiff.Lines(from.Content, to.Content)
+		data.DiffStats = textdiff.Summarize(data.Diff)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tpl.Execute(w, data); err != nil {
+		log.Printf("錯誤：[PromptHandler] 渲染 prompt 管理範本失敗: %v", err)
+	}
+}
+
+// ServeVersions 處理 /api/v1/prompts/{kind} (列出 / 新增)
+func (h *PromptHandler) ServeVersions(w http.ResponseWriter, r *http.Request) {
+	kind, ok := parsePromptKind(r.PathValue("kind"))
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "未知的 prompt 類型")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		versions, err := h.db.ListPromptVersions(kind)
+		var active *models.ActivePrompt
+		if err == nil {
+			active, err = h.db.GetActivePrompt(kind)
+		}
+		if err != nil {
+			log.Printf("錯誤：[PromptHandler] %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "查詢 prompt 版本失敗")
+			return
+		}
+		if versions == nil {
+			versions = []models.PromptVersion{}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"active": active, "versions": versions})
+	case http.MethodPost:
+		var req promptCreateRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<20)).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "無效的 JSON 內容")
+			return
+		}
+		req.Version = strings.TrimSpace(req.Version)
+		req.BaseVersion = strings.TrimSpace(req.BaseVersion)
+		if !promptVersionPattern.MatchString(req.Version) || req.Version == "diff" {
+			writeJSONError(w, http.StatusBadRequest, "版本名稱僅能包含英數字、點、底線與連字號 (最多 64 字元)")
+			return
+		}
+		if strings.TrimSpace(req.Content) == "" {
+			writeJSONError(w, http.StatusBadRequest, "prompt 內容不得為空")
+			return
+		}
+		// 影片內容分析 prompt 支援 {{.Title}} 等佔位符，儲存前先確認可正確套用
+		if kind == models.PromptKindVideo {
+			if err := prompttpl.Validate(req.Version, req.Content); err != nil {
+				writeJSONError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		existing, err := h.db.GetPromptVersion(kind, req.Version)
+		if err != nil {
+			log.Printf("錯誤：[PromptHandler] %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "查詢 prompt 版本失敗")
+			return
+		}
+		if existing != nil {
+			writeJSONError(w, http.StatusConflict, fmt.Sprintf("版本 '%s' 已存在", req.Version))
+			return
+		}
+		if req.BaseVersion != "" {
+			base, err := h.db.GetPromptVersion(kind, req.BaseVersion)
+			if err != nil {
+				log.Printf("錯誤：[PromptHandler] %v", err)
+				writeJSONError(w, http.StatusInternalServerError, "查詢 prompt 版本失敗")
+				return
+			}
+			if base == nil {
+				writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("基準版本 '%s' 不存在", req.BaseVersion))
+				return
+			}
+		}
+		username := requestUsername(r)
+		p := &models.PromptVersion{
+			Kind:        kind,
+			Version:     req.Version,
+			Content:     req.Content,
+			BaseVersion: req.BaseVersion,
+			Author:      username,
+			Notes:       strings.TrimSpace(req.Notes),
+			CreatedAt:   time.Now(),
+		}
+		if p.ID, err = h.db.CreatePromptVersion(p); err != nil {
+			log.Printf("錯誤：[PromptHandler] %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "新增 prompt 版本失敗")
+			return
+		}
+		log.Printf("資訊：[PromptHandler] %s 新增 %s prompt 版本 '%s' (基準: '%s')", username, kind, p.Version, p.BaseVersion)
+		if req.Activate {
+			if err := h.db.SetActivePrompt(kind, p.Version, username); err != nil {
+				log.Printf("錯誤：[PromptHandler] %v", err)
+				writeJSONError(w, http.StatusInternalServerError, "版本已新增，但設為使用版本失敗")
+				return
+			}
+			log.Printf("資訊：[PromptHandler] %s 將 %s prompt 使用版本切換為 '%s'", username, kind, p.Version)
+		}
+		writeJSON(w, http.StatusCreated, p)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "僅支援 GET 或 POST 方法")
+	}
+}
+
+// ServeVersion 處理 /api/v1/prompts/{kind}/{version}，回傳含內容的版本
+func (h *PromptHandler) ServeVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "僅支援 GET 方法")
+		return
+	}
+	p, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// ServeActivate 處理 /api/v1/prompts/{kind}/{version}/activate
+func (h *PromptHandler) ServeActivate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "僅支援 POST 方法")
+		return
+	}
+	p, ok := h.lookup(w, r)
+	if !ok {
+		return
+	}
+	username := requestUsername(r)
+	if err := h.db.SetActivePrompt(p.Kind, p.Version, username); err != nil {
+		log.Printf("錯誤：[PromptHandler] %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "設定使用版本失敗")
+		return
+	}
+	log.Printf("資訊：[PromptHandler] %s 將 %s prompt 使用版本切換為 '%s'", username, p.Kind, p.Version)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "已切換使用版本", "kind": string(p.Kind), "version": p.Version})
+}
+
+// ServeDiff 處理 /api/v1/prompts/{kind}/diff，比對尚未儲存的內容與基準版本
+func (h *PromptHandler) ServeDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "僅支援 POST 方法")
+		return
+	}
+	kind, ok := parsePromptKind(r.PathValue("kind"))
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "未知的 prompt 類型")
+		return
+	}
+	var req promptCreateRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<20)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "無效的 JSON 內容")
+		return
+	}
+	var baseContent string
+	if req.BaseVersion != "" {
+		base, err := h.db.GetPromptVersion(kind, req.BaseVersion)
+		if err != nil {
+			log.Printf("錯誤：[PromptHandler] %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "查詢 prompt 版本失敗")
+			return
+		}
+		if base == nil {
+			writeJSONError(w, http.StatusNotFound, fmt.Sprintf("基準版本 '%s' 不存在", req.BaseVersion))
+			return
+		}
+		baseContent = base.Content
+	}
+	lines := textdiff.Lines(baseContent, req.Content)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"lines": lines, "stats": textdiff.Summarize(lines)})
+}
+
+// lookup 依路徑參數查詢 prompt 版本；失敗時已寫入錯誤回應
+func (h *PromptHandler) lookup(w http.ResponseWriter, r *http.Request) (*models.PromptVersion, bool) {
+	kind, ok := parsePromptKind(r.PathValue("kind"))
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "未知的 prompt 類型")
+		return nil, false
+	}
+	p, err := h.db.GetPromptVersion(kind, r.PathValue("version"))
+	if err != nil {
+		log.Printf("錯誤：[PromptHandler] %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "查詢 prompt 版本失敗")
+		return nil, false
+	}
+	if p == nil {
+		writeJSONError(w, http.StatusNotFound, "找不到 prompt 版本")
+		return nil, false
+	}
+	return p, true
+}
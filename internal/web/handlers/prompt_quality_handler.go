@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/models"
+	"AiHackathon-admin/internal/promptquality"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+)
+
+// PromptQualityHandler 依編輯審核紀錄顯示各 prompt 版本的品質指標
+// 路由:
+//   - GET /prompt-quality           指標頁面
+//   - GET /api/v1/prompt-quality    JSON 格式的指標
+type PromptQualityHandler struct {
+	db      DBStore
+	prompts config.PromptConfig
+	tpl     *pageTemplate
+}
+
+// NewPromptQualityHandler 建立一個 PromptQualityHandler 實例
+func NewPromptQualityHandler(db DBStore, prompts config.PromptConfig, templateBasePath string) (*PromptQualityHandler, error) {
+	if db == nil {
+		return nil, fmt.Errorf("DBStore不得為nil")
+	}
+	tplPath := filepath.Join(templateBasePath, "prompt_quality.html")
+	tpl, err := loadPageTemplate(tplPath, template.FuncMap{
+		"percent": func(v float64) string { return fmt.Sprintf("%.1f%%", v*100) },
+	})
+	if err != nil {
+		return nil, fmt.Errorf("無法解析 prompt 品質範本 '%s': %w", tplPath, err)
+	}
+	return &PromptQualityHandler{db: db, prompts: prompts, tpl: tpl}, nil
+}
+
+func (h *PromptQualityHandler) report() (promptquality.Report, error) {
+	samples, err := h.db.GetReviewSamples()
+	if err != nil {
+		return promptquality.Report{}, err
+	}
+	videoCurrent, videoVersions, err := h.promptVersions(models.PromptKindVideo, h.prompts.VideoAnalysis.CurrentVersion, h.prompts.VideoAnalysis.Versions)
+	if err != nil {
+		return promptquality.Report{}, err
+	}
+	textCurrent, textVersions, err := h.promptVersions(models.PromptKindText, h.prompts.TextFileAnalysis.CurrentVersion, h.prompts.TextFileAnalysis.Versions)
+	if err != nil {
+		return promptquality.Report{}, err
+	}
+	return promptquality.Compute(samples, promptquality.PromptVersions{
+		VideoCurrent:  videoCurrent,
+		VideoVersions: videoVersions,
+		TextCurrent:   textCurrent,
+		TextVersions:  textVersions,
+	}), nil
+}
+
+// promptVersions 回傳目前使用的版本與所有已知版本：資料庫 (prompt 版本管理) 優先，並補上設定檔中的版本
+func (h *PromptQualityHandler) promptVersions(kind models.PromptKind, configCurrent string, configVersions map[string]string) (string, []string, error) {
+	current := configCurrent
+	active, err := h.db.GetActivePrompt(kind)
+	if err != nil {
+		return "", nil, err
+	}
+	if active != nil {
+		current = active.Version
+	}
+	stored, err := h.db.ListPromptVersions(kind)
+	if err != nil {
+		return "", nil, err
+	}
+	seen := make(map[string]bool)
+	var versions []string
+	for _, p := range stored {
+		seen[p.Version] = true
+		versions = append(versions, p.Version)
+	}
+	for v := range configVersions {
+		if !seen[v] {
+			versions = append(versions, v)
+		}
+	}
+	sort.Strings(versions)
+	return current, versions, nil
+}
+
+// ServePage 顯示 prompt 品質指標頁面
+func (h *PromptQualityHandler) ServePage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "僅支援 GET 方法", http.StatusMethodNotAllowed)
+		return
+	}
+	report, err := h.report()
+	if err != nil {
+		log.Printf("錯誤：[PromptQualityHandler] 計算 prompt 品質指標失敗: %v", err)
+		http.Error(w, "內部伺服器錯誤", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tpl.Execute(w, report); err != nil {
+		log.Printf("錯誤：[PromptQualityHandler] 渲染 prompt 品質範本失敗: %v", err)
+	}
+}
+
+// ServeJSON 以 JSON 回傳 prompt 品質指標
+func (h *PromptQualityHandler) ServeJSON(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "僅支援 GET 方法")
+		return
+	}
+	report, err := h.report()
+	if err != nil {
+		log.Printf("錯誤：[PromptQualityHandler] 計算 prompt 品質指標失敗: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "計算 prompt 品質指標失敗")
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
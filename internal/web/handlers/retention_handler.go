@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"AiHackathon-admin/internal/auth"
+	"AiHackathon-admin/internal/retention"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// RetentionReporter 定義了 RetentionHandler 產生試算報表所需的操作
+type RetentionReporter interface {
+	Report() (retention.Report, error)
+}
+
+// RetentionHandler 顯示 NAS 保留政策的試算結果 (不刪除任何檔案)
+// 路由:
+//   - GET /api/v1/retention/report  依目前規則列出將被刪除的檔案與受釘選保護的影片
+type RetentionHandler struct {
+	reporter RetentionReporter
+}
+
+// NewRetentionHandler 建立一個 RetentionHandler 實例
+func NewRetentionHandler(reporter RetentionReporter) *RetentionHandler {
+	if reporter == nil {
+		log.Panicln("RetentionHandler：RetentionReporter 不得為空")
+	}
+	return &RetentionHandler{reporter: reporter}
+}
+
+// ServeReport 以 JSON 回傳保留政策試算報表
+func (h *RetentionHandler) ServeReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "僅支援 GET 方法")
+		return
+	}
+	report, err := h.reporter.Report()
+	if err != nil {
+		log.Printf("錯誤：[RetentionHandler] 產生保留政策試算報表失敗: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "產生保留政策試算報表失敗")
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// PinHandler 編輯釘選影片，釘選的影片不會被保留政策刪除
+// 路由:
+//   - PUT /api/v1/videos/{id}/pin  body: {"pinned": true}
+type PinHandler struct {
+	db DBStore
+}
+
+// NewPinHandler 建立一個 PinHandler 實例
+func NewPinHandler(db DBStore) *PinHandler {
+	if db == nil {
+		log.Panicln("PinHandler：DBStore 不得為空")
+	}
+	return &PinHandler{db: db}
+}
+
+// ServeHTTP 實現 http.Handler 介面
+func (h *PinHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeJSONError(w, http.StatusMethodNotAllowed, "僅支援 PUT 方法")
+		return
+	}
+	videoID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || videoID <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "無效的影片 ID")
+		return
+	}
+	var req struct {
+		Pinned *bool `json:"pinned"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil || req.Pinned == nil {
+		writeJSONError(w, http.StatusBadRequest, "需提供 pinned (true/false)")
+		return
+	}
+	username := "anonymous"
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		username = user.Username
+	}
+	if err := h.db.SetVideoPinned(videoID, *req.Pinned, username); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, "找不到影片")
+			return
+		}
+		log.Printf("錯誤：[PinHandler] %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "更新釘選狀態失敗")
+		return
+	}
+	log.Printf("資訊：[PinHandler] 使用者 '%s' 將影片 ID %d 的釘選狀態設為 %t", username, videoID, *req.Pinned)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"video_id": videoID, "pinned": *req.Pinned})
+}
@@ -0,0 +1,381 @@
+package handlers
+
+import (
+	"AiHackathon-admin/internal/auth"
+	"AiHackathon-admin/internal/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ApplyAnalysisReviews 將編輯修訂覆寫到影片與分析結果上 (就地修改傳入的切片)，
+// 供儀表板與各種匯出優先使用編輯後的內容。results 不需與 videos 一一對應。
+func ApplyAnalysisReviews(db DBStore, videos []models.Video, results []models.AnalysisResult) error {
+	if len(videos) == 0 {
+		return nil
+	}
+	videoIDs := make([]int64, len(videos))
+	for i, v := range videos {
+		videoIDs[i] = v.ID
+	}
+	reviews, err := db.GetAnalysisReviews(videoIDs)
+	if err != nil {
+		return err
+	}
+	resultIndex := make(map[int64]int, len(results))
+	for i, ar := range results {
+		resultIndex[ar.VideoID] = i
+	}
+	for i := range videos {
+		review, ok := reviews[videos[i].ID]
+		if !ok {
+			continue
+		}
+		var result *models.AnalysisResult
+		if idx, ok := resultIndex[videos[i].ID]; ok {
+			result = &results[idx]
+		}
+		review.ApplyTo(&videos[i], result)
+	}
+	return nil
+}
+
+// overallRating 由 importance_score JSON 取出評級
+func overallRating(raw json.RawMessage) string {
+	var score models.ImportanceScore
+	if len(raw) > 0 {
+		_ = json.Unmarshal(raw, &score)
+	}
+	return strings.ToUpper(strings.TrimSpace(score.OverallRating))
+}
+
+// normalizeBites 解析 BITE JSON，去除空白並略過沒有內容的項目
+func normalizeBites(raw json.RawMessage) ([]BiteDisplay, error) {
+	var bites []BiteDisplay
+	if len(raw) > 0 && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &bites); err != nil {
+			return nil, err
+		}
+	}
+	normalized := make([]BiteDisplay, 0, len(bites))
+	for _, b := range bites {
+		b.TimeLine = strings.TrimSpace(b.TimeLine)
+		b.Speaker = strings.TrimSpace(b.Speaker)
+		b.Quote = strings.TrimSpace(b.Quote)
+		if b.Quote != "" {
+			normalized = append(normalized, b)
+		}
+	}
+	return normalized, nil
+}
+
+// normalizeKeywords 解析關鍵字 JSON，去除空白並略過空白或重複的關鍵字
+func normalizeKeywords(raw json.RawMessage) ([]KeywordDisplay, error) {
+	var keywords []KeywordDisplay
+	if len(raw) > 0 && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &keywords); err != nil {
+			return nil, err
+		}
+	}
+	seen := make(map[string]bool)
+	normalized := make([]KeywordDisplay, 0, len(keywords))
+	for _, k := range keywords {
+		k.Keyword = strings.TrimSpace(k.Keyword)
+		k.Category = strings.TrimSpace(k.Category)
+		key := strings.ToLower(k.Keyword)
+		if k.Keyword != "" && !seen[key] {
+			seen[key] = true
+			normalized = append(normalized, k)
+		}
+	}
+	return normalized, nil
+}
+
+func sameKeywords(a, b []KeywordDisplay) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameBites(a, b []BiteDisplay) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ReviewFields 為可由編輯修訂的欄位
+type ReviewFields struct {
+	ShortSummary  string           `json:"short_summary"`
+	Bites         []BiteDisplay    `json:"bites"`
+	Keywords      []KeywordDisplay `json:"keywords"`
+	Location      string           `json:"location"`
+	OverallRating string           `json:"overall_rating"`
+}
+
+// ReviewResponse 為 GET/PUT /api/v1/videos/{id}/review 的回應
+type ReviewResponse struct {
+	VideoID    int64               `json:"video_id"`
+	Status     models.ReviewStatus `json:"status"`
+	Note       string              `json:"note"`
+	EditedBy   string              `json:"edited_by,omitempty"`
+	EditedAt   *time.Time          `json:"edited_at,omitempty"`
+	ReviewedBy string              `json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time          `json:"reviewed_at,omitempty"`
+	Edited     []string            `json:"edited"`    // 被修訂的欄位名稱
+	Original   ReviewFields        `json:"original"`  // AI 原始輸出
+	Effective  ReviewFields        `json:"effective"` // 套用修訂後的值 (匯出使用)
+}
+
+// reviewRequest 為 PUT 的請求內容；省略的欄位維持原修訂，與 AI 原始輸出相同 (或為空) 的值代表取消修訂
+type reviewRequest struct {
+	Status        models.ReviewStatus `json:"status"`
+	ShortSummary  *string             `json:"short_summary"`
+	Bites         json.RawMessage     `json:"bites"`
+	Keywords      json.RawMessage     `json:"keywords"`
+	Location      *string             `json:"location"`
+	OverallRating *string             `json:"overall_rating"`
+	Note          *string             `json:"note"`
+}
+
+// ReviewHandler 提供 AI 分析結果的編輯審核 API
+// 路由:
+//   - GET  /api/v1/videos/{id}/review  查詢審核狀態、原始與修訂後的值
+//   - PUT  /api/v1/videos/{id}/review  更新審核狀態與修訂 (需 editor 角色)
+type ReviewHandler struct {
+	db DBStore
+}
+
+// NewReviewHandler 建立一個 ReviewHandler 實例
+func NewReviewHandler(db DBStore) *ReviewHandler {
+	if db == nil {
+		log.Panicln("ReviewHandler：DBStore 不得為空")
+	}
+	return &ReviewHandler{db: db}
+}
+
+// ServeHTTP 實現 http.Handler 介面
+func (h *ReviewHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	videoID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || videoID <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "無效的影片 ID")
+		return
+	}
+	video, err := h.db.GetVideoByID(videoID)
+	if err != nil {
+		log.Printf("錯誤：[ReviewHandler] 查詢影片 ID %d 失敗: %v", videoID, err)
+		writeJSONError(w, http.StatusInternalServerError, "內部伺服器錯誤")
+		return
+	}
+	if video == nil {
+		writeJSONError(w, http.StatusNotFound, "找不到影片")
+		return
+	}
+	result, err := h.db.GetAnalysisResultByVideoID(videoID)
+	if err != nil {
+		log.Printf("錯誤：[ReviewHandler] 查詢影片 ID %d 的分析結果失敗: %v", videoID, err)
+		writeJSONError(w, http.StatusInternalServerError, "內部伺服器錯誤")
+		return
+	}
+	if result == nil {
+		writeJSONError(w, http.StatusConflict, "影片尚未有 AI 分析結果，無法審核")
+		return
+	}
+	review, err := h.db.GetAnalysisReview(videoID)
+	if err != nil {
+		log.Printf("錯誤：[ReviewHandler] %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "內部伺服器錯誤")
+		return
+	}
+	if review == nil {
+		review = &models.AnalysisReview{VideoID: videoID, Status: models.ReviewPending}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, buildReviewResponse(*video, *result, review))
+	case http.MethodPut:
+		var req reviewRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "無效的 JSON: "+err.Error())
+			return
+		}
+		updated, err := mergeReview(*review, req, *video, *result)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		user := auth.UserFromContext(r.Context())
+		username, userID := "anonymous", sql.NullInt64{}
+		if user != nil {
+			username = user.Username
+			userID = sql.NullInt64{Int64: user.ID, Valid: user.ID > 0}
+		}
+		now := time.Now()
+		if reviewFieldsChanged(review, &updated) {
+			if updated.HasOverrides() {
+				updated.EditedByUserID, updated.EditedBy, updated.EditedAt = userID, sql.NullString{String: username, Valid: true}, sql.NullTime{Time: now, Valid: true}
+			} else {
+				updated.EditedByUserID, updated.EditedBy, updated.EditedAt = sql.NullInt64{}, sql.NullString{}, sql.NullTime{}
+			}
+		}
+		if updated.Status != review.Status {
+			updated.ReviewedByUserID, updated.ReviewedBy, updated.ReviewedAt = userID, sql.NullString{String: username, Valid: true}, sql.NullTime{Time: now, Valid: true}
+		}
+		if err := h.db.SaveAnalysisReview(&updated); err != nil {
+			log.Printf("錯誤：[ReviewHandler] %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "儲存審核結果失敗")
+			return
+		}
+		updated.UpdatedAt = now
+		log.Printf("資訊：[ReviewHandler] 使用者 '%s' 更新影片 ID %d 的審核 (狀態: %s，修訂欄位: %v)", username, videoID, updated.Status, editedFields(&updated))
+		writeJSON(w, http.StatusOK, buildReviewResponse(*video, *result, &updated))
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, "僅支援 GET 或 PUT 方法")
+	}
+}
+
+// mergeReview 依請求內容產生新的審核紀錄；與 AI 原始輸出相同的值不會被記為修訂
+func mergeReview(review models.AnalysisReview, req reviewRequest, video models.Video, result models.AnalysisResult) (models.AnalysisReview, error) {
+	switch req.Status {
+	case "":
+	case models.ReviewPending, models.ReviewApproved, models.ReviewRejected:
+		review.Status = req.Status
+	default:
+		return review, fmt.Errorf("審核狀態需為 pending、approved 或 rejected")
+	}
+	if req.ShortSummary != nil {
+		value := strings.TrimSpace(*req.ShortSummary)
+		original := ""
+		if result.ShortSummary != nil {
+			original = strings.TrimSpace(result.ShortSummary.String)
+		}
+		review.ShortSummary = sql.NullString{String: value, Valid: value != "" && value != original}
+	}
+	if req.Location != nil {
+		value := strings.TrimSpace(*req.Location)
+		if len(value) > 255 {
+			return review, fmt.Errorf("地點長度不得超過 255 字元")
+		}
+		review.Location = sql.NullString{String: value, Valid: value != "" && value != strings.TrimSpace(video.Location.String)}
+	}
+	if req.OverallRating != nil {
+		value := strings.ToUpper(strings.TrimSpace(*req.OverallRating))
+		if value != "" && getRatingWeight(value) == 0 {
+			return review, fmt.Errorf("重要性評級需為 S、A、B、C 或 N")
+		}
+		review.OverallRating = sql.NullString{String: value, Valid: value != "" && value != overallRating(result.ImportanceScore)}
+	}
+	if req.Bites != nil {
+		bites, err := normalizeBites(req.Bites)
+		if err != nil {
+			return review, fmt.Errorf("BITE 格式錯誤: %v", err)
+		}
+		original, _ := normalizeBites(result.Bites)
+		review.Bites = nil
+		if string(req.Bites) != "null" && !sameBites(bites, original) {
+			encoded, err := json.Marshal(bites)
+			if err != nil {
+				return review, err
+			}
+			review.Bites = encoded
+		}
+	}
+	if req.Keywords != nil {
+		keywords, err := normalizeKeywords(req.Keywords)
+		if err != nil {
+			return review, fmt.Errorf("關鍵字格式錯誤: %v", err)
+		}
+		original, _ := normalizeKeywords(result.Keywords)
+		review.Keywords = nil
+		if string(req.Keywords) != "null" && !sameKeywords(keywords, original) {
+			encoded, err := json.Marshal(keywords)
+			if err != nil {
+				return review, err
+			}
+			review.Keywords = encoded
+		}
+	}
+	if req.Note != nil {
+		note := strings.TrimSpace(*req.Note)
+		review.Note = sql.NullString{String: note, Valid: note != ""}
+	}
+	return review, nil
+}
+
+func reviewFieldsChanged(before, after *models.AnalysisReview) bool {
+	return before.ShortSummary != after.ShortSummary || before.Location != after.Location ||
+		before.OverallRating != after.OverallRating || string(before.Bites) != string(after.Bites) ||
+		string(before.Keywords) != string(after.Keywords)
+}
+
+// editedFields 回傳被修訂的欄位名稱
+func editedFields(review *models.AnalysisReview) []string {
+	fields := []string{}
+	if review.ShortSummary.Valid {
+		fields = append(fields, "short_summary")
+	}
+	if len(review.Bites) > 0 {
+		fields = append(fields, "bites")
+	}
+	if len(review.Keywords) > 0 {
+		fields = append(fields, "keywords")
+	}
+	if review.Location.Valid {
+		fields = append(fields, "location")
+	}
+	if review.OverallRating.Valid {
+		fields = append(fields, "overall_rating")
+	}
+	return fields
+}
+
+func reviewFieldsOf(video models.Video, result models.AnalysisResult) ReviewFields {
+	fields := ReviewFields{
+		Location:      video.Location.String,
+		OverallRating: overallRating(result.ImportanceScore),
+	}
+	if result.ShortSummary != nil {
+		fields.ShortSummary = result.ShortSummary.String
+	}
+	fields.Bites, _ = normalizeBites(result.Bites)
+	fields.Keywords, _ = normalizeKeywords(result.Keywords)
+	return fields
+}
+
+func buildReviewResponse(video models.Video, result models.AnalysisResult, review *models.AnalysisReview) ReviewResponse {
+	resp := ReviewResponse{
+		VideoID:    video.ID,
+		Status:     review.Status,
+		Note:       review.Note.String,
+		EditedBy:   review.EditedBy.String,
+		ReviewedBy: review.ReviewedBy.String,
+		Edited:     editedFields(review),
+		Original:   reviewFieldsOf(video, result),
+	}
+	if review.EditedAt.Valid {
+		resp.EditedAt = &review.EditedAt.Time
+	}
+	if review.ReviewedAt.Valid {
+		resp.ReviewedAt = &review.ReviewedAt.Time
+	}
+	review.ApplyTo(&video, &result)
+	resp.Effective = reviewFieldsOf(video, result)
+	return resp
+}
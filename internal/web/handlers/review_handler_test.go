@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"AiHackathon-admin/internal/auth"
+	"AiHackathon-admin/internal/models"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func reviewTestVideo() models.Video {
+	return models.Video{ID: 7, SourceName: "ap", SourceID: "4567890", Location: sql.NullString{String: "Kyiv", Valid: true}}
+}
+
+func reviewTestResult() models.AnalysisResult {
+	return models.AnalysisResult{
+		VideoID:         7,
+		ShortSummary:    &models.JsonNullString{NullString: sql.NullString{String: "AI 摘要", Valid: true}},
+		Bites:           json.RawMessage(`[{"time_line":"00:00:05","speaker":"市長","quote":"＂第一段＂"},{"time_line":"","speaker":"","quote":" "}]`),
+		Keywords:        json.RawMessage(`[{"keyword":"烏克蘭","category":"地點"},{"keyword":"Kyiv","category":"地點"}]`),
+		ImportanceScore: json.RawMessage(`{"overall_rating":"b"}`),
+	}
+}
+
+func TestMergeReview(t *testing.T) {
+	existing := models.AnalysisReview{
+		VideoID:      7,
+		Status:       models.ReviewPending,
+		ShortSummary: sql.NullString{String: "舊的修訂", Valid: true},
+		Location:     sql.NullString{String: "基輔", Valid: true},
+		Note:         sql.NullString{String: "舊備註", Valid: true},
+	}
+	tests := []struct {
+		name    string
+		req     string
+		wantErr string
+		check   func(t *testing.T, got models.AnalysisReview)
+	}{
+		{
+			name: "省略的欄位維持原修訂",
+			req:  `{"status":"approved"}`,
+			check: func(t *testing.T, got models.AnalysisReview) {
+				if got.Status != models.ReviewApproved || got.ShortSummary.String != "舊的修訂" || got.Location.String != "基輔" || got.Note.String != "舊備註" {
+					t.Errorf("審核紀錄 = %+v", got)
+				}
+			},
+		},
+		{
+			name: "與 AI 原始輸出相同或空白的值取消修訂",
+			req:  `{"short_summary":"  AI 摘要 ","location":"","overall_rating":" B ","note":" "}`,
+			check: func(t *testing.T, got models.AnalysisReview) {
+				if got.ShortSummary.Valid || got.Location.Valid || got.OverallRating.Valid || got.Note.Valid || string(got.Keywords) != `[{"keyword":"舊"}]` {
+					t.Errorf("應取消修訂: %+v", got)
+				}
+				if got.Status != models.ReviewPending {
+					t.Errorf("未指定狀態時應維持原狀態: %s", got.Status)
+				}
+			},
+		},
+		{
+			name: "記錄新的修訂",
+			req:  `{"short_summary":" 編輯摘要 ","location":"基輔市","overall_rating":"s","note":"已確認"}`,
+			check: func(t *testing.T, got models.AnalysisReview) {
+				want := []sql.NullString{{String: "編輯摘要", Valid: true}, {String: "基輔市", Valid: true}, {String: "S", Valid: true}, {String: "已確認", Valid: true}}
+				if gotFields := []sql.NullString{got.ShortSummary, got.Location, got.OverallRating, got.Note}; !reflect.DeepEqual(gotFields, want) {
+					t.Errorf("修訂欄位 = %+v, want %+v", gotFields, want)
+				}
+			},
+		},
+		{
+			name: "正規化後與原始 BITE 相同時不記為修訂",
+			req:  `{"bites":[{"time_line":" 00:00:05","speaker":"市長 ","quote":"＂第一段＂"},{"quote":""}],"keywords":[{"keyword":" 烏克蘭","category":"地點"},{"keyword":"kyiv","category":"重複"},{"keyword":"Kyiv","category":"地點"}]}`,
+			check: func(t *testing.T, got models.AnalysisReview) {
+				if got.Bites != nil {
+					t.Errorf("Bites = %s, want nil", got.Bites)
+				}
+				// 不分大小寫去除重複後保留第一個，與原始輸出的分類不同因此記為修訂
+				if string(got.Keywords) != `[{"keyword":"烏克蘭","category":"地點"},{"keyword":"kyiv","category":"重複"}]` {
+					t.Errorf("Keywords = %s", got.Keywords)
+				}
+			},
+		},
+		{
+			name: "修改 BITE 並以 null 取消關鍵字修訂",
+			req:  `{"bites":[{"time_line":"00:00:09","speaker":"記者","quote":" 新引言 "}],"keywords":null}`,
+			check: func(t *testing.T, got models.AnalysisReview) {
+				if string(got.Bites) != `[{"time_line":"00:00:09","speaker":"記者","quote":"新引言"}]` {
+					t.Errorf("Bites = %s", got.Bites)
+				}
+				if got.Keywords != nil {
+					t.Errorf("Keywords = %s, want nil", got.Keywords)
+				}
+			},
+		},
+		{name: "無效的審核狀態", req: `{"status":"done"}`, wantErr: "審核狀態"},
+		{name: "無效的評級", req: `{"overall_rating":"X"}`, wantErr: "重要性評級"},
+		{name: "地點過長", req: `{"location":"` + strings.Repeat("a", 256) + `"}`, wantErr: "地點長度"},
+		{name: "BITE 格式錯誤", req: `{"bites":{"quote":"x"}}`, wantErr: "BITE 格式錯誤"},
+		{name: "關鍵字格式錯誤", req: `{"keywords":"x"}`, wantErr: "關鍵字格式錯誤"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req reviewRequest
+			if err := json.Unmarshal([]byte(tt.req), &req); err != nil {
+				t.Fatal(err)
+			}
+			prev := existing
+			prev.Keywords = json.RawMessage(`[{"keyword":"舊"}]`)
+			got, err := mergeReview(prev, req, reviewTestVideo(), reviewTestResult())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("mergeReview() error = %v, want 包含 %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, got)
+		})
+	}
+}
+
+// fakeReviewDB 以記憶體保存單一影片的審核紀錄
+type fakeReviewDB struct {
+	DBStore
+
+	video  models.Video
+	result models.AnalysisResult
+	review *models.AnalysisReview
+	saves  int
+}
+
+func (db *fakeReviewDB) GetVideoByID(videoID int64) (*models.Video, error) {
+	if videoID != db.video.ID {
+		return nil, nil
+	}
+	v := db.video
+	return &v, nil
+}
+
+func (db *fakeReviewDB) GetAnalysisResultByVideoID(videoID int64) (*models.AnalysisResult, error) {
+	r := db.result
+	return &r, nil
+}
+
+func (db *fakeReviewDB) GetAnalysisReview(videoID int64) (*models.AnalysisReview, error) {
+	if db.review == nil {
+		return nil, nil
+	}
+	r := *db.review
+	return &r, nil
+}
+
+func (db *fakeReviewDB) SaveAnalysisReview(review *models.AnalysisReview) error {
+	r := *review
+	db.review = &r
+	db.saves++
+	return nil
+}
+
+func (db *fakeReviewDB) GetAnalysisReviews(videoIDs []int64) (map[int64]*models.AnalysisReview, error) {
+	reviews := make(map[int64]*models.AnalysisReview)
+	if db.review != nil {
+		r := *db.review
+		reviews[db.video.ID] = &r
+	}
+	return reviews, nil
+}
+
+func putReview(t *testing.T, h http.Handler, user *models.User, body string) (int, ReviewResponse) {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.Handle("/api/v1/videos/{id}/review", h)
+	r := httptest.NewRequest(http.MethodPut, "/api/v1/videos/7/review", strings.NewReader(body))
+	if user != nil {
+		r = r.WithContext(auth.WithUser(r.Context(), user, nil))
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	var resp ReviewResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w.Code, resp
+}
+
+func TestReviewHandlerPut(t *testing.T) {
+	db := &fakeReviewDB{video: reviewTestVideo(), result: reviewTestResult()}
+	h := NewReviewHandler(db)
+	editor := &models.User{ID: 3, Username: "editor"}
+	chief := &models.User{ID: 4, Username: "chief"}
+
+	code, resp := putReview(t, h, editor, `{"short_summary":"編輯摘要","overall_rating":"A"}`)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	if !reflect.DeepEqual(resp.Edited, []string{"short_summary", "overall_rating"}) || resp.EditedBy != "editor" || resp.ReviewedBy != "" {
+		t.Errorf("回應 = %+v", resp)
+	}
+	if resp.Original.ShortSummary != "AI 摘要" || resp.Original.OverallRating != "B" || resp.Effective.ShortSummary != "編輯摘要" || resp.Effective.OverallRating != "A" {
+		t.Errorf("原始/修訂後的值 = %+v / %+v", resp.Original, resp.Effective)
+	}
+	if resp.Effective.Location != "Kyiv" || len(resp.Effective.Bites) != 1 {
+		t.Errorf("未修訂的欄位應沿用原始輸出: %+v", resp.Effective)
+	}
+	if db.review.EditedByUserID.Int64 != 3 || db.review.Status != models.ReviewPending {
+		t.Errorf("儲存的審核紀錄 = %+v", db.review)
+	}
+
+	// 只變更審核狀態：記錄審核者，保留原編輯者
+	code, resp = putReview(t, h, chief, `{"status":"approved"}`)
+	if code != http.StatusOK || resp.Status != models.ReviewApproved || resp.ReviewedBy != "chief" || resp.EditedBy != "editor" {
+		t.Errorf("核准: status %d，回應 %+v", code, resp)
+	}
+
+	// 取消所有修訂時清除編輯者
+	code, resp = putReview(t, h, chief, `{"short_summary":"","overall_rating":"B"}`)
+	if code != http.StatusOK || len(resp.Edited) != 0 || resp.EditedBy != "" || resp.EditedAt != nil || resp.ReviewedBy != "chief" {
+		t.Errorf("取消修訂: status %d，回應 %+v", code, resp)
+	}
+	if !reflect.DeepEqual(resp.Effective, resp.Original) {
+		t.Errorf("取消修訂後應與原始輸出相同: %+v / %+v", resp.Effective, resp.Original)
+	}
+
+	// 無效的請求不寫入資料庫
+	saves := db.saves
+	if code, _ := putReview(t, h, editor, `{"overall_rating":"Z"}`); code != http.StatusBadRequest || db.saves != saves {
+		t.Errorf("無效的評級: status %d，儲存次數 %d", code, db.saves-saves)
+	}
+}
+
+func TestApplyAnalysisReviews(t *testing.T) {
+	db := &fakeReviewDB{
+		video:  reviewTestVideo(),
+		review: &models.AnalysisReview{VideoID: 7, Location: sql.NullString{String: "基輔", Valid: true}, Bites: json.RawMessage(`[{"quote":"編輯"}]`)},
+	}
+	videos := []models.Video{{ID: 6, Location: sql.NullString{String: "Lviv", Valid: true}}, reviewTestVideo()}
+	results := []models.AnalysisResult{reviewTestResult()}
+	if err := ApplyAnalysisReviews(db, videos, results); err != nil {
+		t.Fatal(err)
+	}
+	if videos[0].Location.String != "Lviv" || videos[1].Location.String != "基輔" {
+		t.Errorf("地點 = %q, %q", videos[0].Location.String, videos[1].Location.String)
+	}
+	if string(results[0].Bites) != `[{"quote":"編輯"}]` || results[0].ShortSummary.String != "AI 摘要" {
+		t.Errorf("分析結果 = %s, %q", results[0].Bites, results[0].ShortSummary.String)
+	}
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"AiHackathon-admin/internal/subtitles"
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SubtitleHandler 負責依分析結果產生 SRT / WebVTT 字幕檔
+// 路由: /api/v1/videos/{id}/subtitles.{srt,vtt}?lang=orig|zh
+// 沒有時間軸分段時以 BITE 引言產生字幕，BITE 只有繁體中文，因此 lang=orig 會回傳 404。
+type SubtitleHandler struct {
+	db DBStore
+}
+
+// NewSubtitleHandler 建立一個 SubtitleHandler 實例
+func NewSubtitleHandler(db DBStore) *SubtitleHandler {
+	if db == nil {
+		log.Panicln("SubtitleHandler：DBStore 不得為空")
+	}
+	return &SubtitleHandler{db: db}
+}
+
+// ServeHTTP 實現 http.Handler 介面
+func (h *SubtitleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "僅支援 GET 方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	videoID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || videoID <= 0 {
+		http.Error(w, "無效的影片 ID", http.StatusBadRequest)
+		return
+	}
+	var format subtitles.Format
+	var contentType string
+	switch r.PathValue("file") {
+	case "subtitles.srt":
+		format, contentType = subtitles.FormatSRT, "application/x-subrip; charset=utf-8"
+	case "subtitles.vtt":
+		format, contentType = subtitles.FormatVTT, "text/vtt; charset=utf-8"
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	lang := r.URL.Query().Get("lang")
+	if lang == "" {
+		lang = subtitles.LangChinese
+	}
+	if lang != subtitles.LangOriginal && lang != subtitles.LangChinese {
+		http.Error(w, "lang 參數僅支援 orig 或 zh", http.StatusBadRequest)
+		return
+	}
+
+	video, err := h.db.GetVideoByID(videoID)
+	if err != nil {
+		log.Printf("錯誤：[SubtitleHandler] 查詢影片 ID %d 失敗: %v", videoID, err)
+		http.Error(w, "內部伺服器錯誤", http.StatusInternalServerError)
+		return
+	}
+	if video == nil {
+		http.NotFound(w, r)
+		return
+	}
+	result, err := h.db.GetAnalysisResultByVideoID(videoID)
+	if err != nil {
+		log.Printf("錯誤：[SubtitleHandler] 查詢影片 ID %d 的分析結果失敗: %v", videoID, err)
+		http.Error(w, "內部伺服器錯誤", http.StatusInternalServerError)
+		return
+	}
+	if result == nil {
+		http.Error(w, "此影片尚無分析結果", http.StatusNotFound)
+		return
+	}
+	// 以 BITE 產生字幕時優先使用編輯修訂後的內容
+	review, err := h.db.GetAnalysisReview(videoID)
+	if err != nil {
+		log.Printf("錯誤：[SubtitleHandler] %v", err)
+		http.Error(w, "內部伺服器錯誤", http.StatusInternalServerError)
+		return
+	}
+	review.ApplyTo(video, result)
+
+	var duration time.Duration
+	if video.DurationSecs.Valid {
+		duration = time.Duration(video.DurationSecs.Int64) * time.Second
+	}
+	cues, err := subtitles.BuildCues(result, lang, duration)
+	if err != nil {
+		log.Printf("錯誤：[SubtitleHandler] 產生影片 ID %d 的字幕失敗: %v", videoID, err)
+		http.Error(w, "無法產生字幕", http.StatusInternalServerError)
+		return
+	}
+	if len(cues) == 0 {
+		http.Error(w, fmt.Sprintf("此影片沒有 %s 語言的字幕內容", lang), http.StatusNotFound)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := subtitles.Write(&buf, cues, format); err != nil {
+		log.Printf("錯誤：[SubtitleHandler] 輸出影片 ID %d 的字幕失敗: %v", videoID, err)
+		http.Error(w, "無法產生字幕", http.StatusInternalServerError)
+		return
+	}
+	fileName := fmt.Sprintf("%s%s_%s.%s", strings.ToUpper(video.SourceName), video.SourceID, lang, format)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", fileName))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		w.Write(buf.Bytes())
+	}
+}
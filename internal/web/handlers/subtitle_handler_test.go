@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSubtitleHandlerBitesFallback(t *testing.T) {
+	db := &fakeReviewDB{video: reviewTestVideo(), result: reviewTestResult()}
+	mux := http.NewServeMux()
+	mux.Handle("/api/v1/videos/{id}/{file}", NewSubtitleHandler(db))
+
+	tests := []struct {
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{path: "/api/v1/videos/7/subtitles.srt", wantCode: http.StatusOK, wantBody: "00:00:05,000 --> 00:00:10,000\n市長：＂第一段＂"},
+		{path: "/api/v1/videos/7/subtitles.vtt?lang=zh", wantCode: http.StatusOK, wantBody: "WEBVTT"},
+		// 只有 BITE 時沒有原始語言的字幕
+		{path: "/api/v1/videos/7/subtitles.srt?lang=orig", wantCode: http.StatusNotFound, wantBody: "orig"},
+		{path: "/api/v1/videos/7/subtitles.srt?lang=en", wantCode: http.StatusBadRequest},
+		{path: "/api/v1/videos/8/subtitles.srt", wantCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.wantCode || !strings.Contains(w.Body.String(), tt.wantBody) {
+			t.Errorf("%s: status %d, body %q, want %d 包含 %q", tt.path, w.Code, w.Body.String(), tt.wantCode, tt.wantBody)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"html/template"
+	"io"
+	"path/filepath"
+	"sync"
+)
+
+// pageTemplate 為可於執行期重新載入的頁面範本 (見 ReloadTemplates)
+type pageTemplate struct {
+	path  string
+	funcs template.FuncMap
+	mu    sync.RWMutex
+	tpl   *template.Template
+}
+
+var (
+	pageTemplatesMu sync.Mutex
+	pageTemplates   []*pageTemplate
+)
+
+// loadPageTemplate 解析範本檔並登記，之後可由 ReloadTemplates 重新載入；funcs 可為 nil
+func loadPageTemplate(path string, funcs template.FuncMap) (*pageTemplate, error) {
+	t := &pageTemplate{path: path, funcs: funcs}
+	tpl, err := t.parse()
+	if err != nil {
+		return nil, err
+	}
+	t.tpl = tpl
+	pageTemplatesMu.Lock()
+	pageTemplates = append(pageTemplates, t)
+	pageTemplatesMu.Unlock()
+	return t, nil
+}
+
+func (t *pageTemplate) parse() (*template.Template, error) {
+	return template.New(filepath.Base(t.path)).Funcs(t.funcs).ParseFiles(t.path)
+}
+
+// Execute 以目前載入的範本輸出
+func (t *pageTemplate) Execute(w io.Writer, data interface{}) error {
+	t.mu.RLock()
+	tpl := t.tpl
+	t.mu.RUnlock()
+	return tpl.Execute(w, data)
+}
+
+// ReloadTemplates 重新解析所有頁面範本 (儀表板、各管理頁面)。
+// 任一範本解析失敗時全部維持舊版本，並回傳所有錯誤。
+func ReloadTemplates() error {
+	pageTemplatesMu.Lock()
+	defer pageTemplatesMu.Unlock()
+	parsed := make([]*template.Template, len(pageTemplates))
+	var errs []error
+	for i, t := range pageTemplates {
+		tpl, err := t.parse()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		parsed[i] = tpl
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	for i, t := range pageTemplates {
+		t.mu.Lock()
+		t.tpl = parsed[i]
+		t.mu.Unlock()
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/thumbnails"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 縮圖的快取時間：網址帶有版本參數 (v=產生時間) 時內容不會改變，可長期快取；否則每小時重新驗證
+const (
+	thumbnailCacheVersioned = "private, max-age=31536000, immutable"
+	thumbnailCacheDefault   = "private, max-age=3600"
+)
+
+// ThumbnailHandler 提供影片旁的海報與關鍵影格縮圖表 (/thumbs/{相對路徑})，只允許縮圖檔案
+type ThumbnailHandler struct {
+	nasBasePath string           // NAS 影片儲存的絕對根路徑
+	objects     MediaObjectStore // nas.backend 為 s3 時不為 nil；縮圖一律由伺服器轉送
+}
+
+// NewThumbnailHandler 建立 ThumbnailHandler 實例；objects 不為 nil 時由物件儲存讀取縮圖
+func NewThumbnailHandler(nasCfg config.NASConfig, objects MediaObjectStore) (*ThumbnailHandler, error) {
+	if objects != nil {
+		return &ThumbnailHandler{objects: objects}, nil
+	}
+	if nasCfg.VideoPath == "" {
+		return nil, fmt.Errorf("ThumbnailHandler: NAS 設定中的 videoPath 不得為空")
+	}
+	absBasePath, err := filepath.Abs(nasCfg.VideoPath)
+	if err != nil {
+		return nil, fmt.Errorf("ThumbnailHandler: 無法取得 NAS videoPath 的絕對路徑 '%s': %w", nasCfg.VideoPath, err)
+	}
+	return &ThumbnailHandler{nasBasePath: absBasePath}, nil
+}
+
+// ServeHTTP 實現 http.Handler 介面；路由以 http.StripPrefix 移除 /thumbs/ 前綴
+func (h *ThumbnailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	relativePath := strings.TrimPrefix(r.URL.Path, "/")
+	if !thumbnails.IsThumbnail(relativePath) {
+		http.NotFound(w, r)
+		return
+	}
+	cacheControl := thumbnailCacheDefault
+	if r.URL.Query().Get("v") != "" {
+		cacheControl = thumbnailCacheVersioned
+	}
+	if h.objects != nil {
+		h.serveObject(w, r, relativePath, cacheControl)
+		return
+	}
+
+	fullPath := filepath.Join(h.nasBasePath, relativePath)
+	if rel, err := filepath.Rel(h.nasBasePath, fullPath); err != nil || strings.HasPrefix(rel, "..") {
+		log.Printf("警告：[ThumbnailHandler] 偵測到潛在的路徑遍歷嘗試: '%s'", relativePath)
+		http.Error(w, "禁止存取", http.StatusForbidden)
+		return
+	}
+	st, err := os.Stat(fullPath)
+	if err != nil || st.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Cache-Control", cacheControl)
+	// ServeFile 會依 ETag 處理 If-None-Match，依修改時間處理 If-Modified-Since
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, st.ModTime().UnixNano(), st.Size()))
+	http.ServeFile(w, r, fullPath)
+}
+
+// serveObject 由物件儲存轉送縮圖；ETag 與 If-None-Match 相同時回傳 304
+func (h *ThumbnailHandler) serveObject(w http.ResponseWriter, r *http.Request, relativePath, cacheControl string) {
+	resp, err := h.objects.OpenRange(relativePath, "")
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Printf("錯誤：[ThumbnailHandler] 讀取縮圖物件 '%s' 失敗: %v", relativePath, err)
+		http.Error(w, "內部伺服器錯誤", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	w.Header().Set("Cache-Control", cacheControl)
+	etag := resp.Header.Get("ETag")
+	if etag != "" && etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	for _, name := range proxiedMediaHeaders {
+		if v := resp.Header.Get(name); v != "" {
+			w.Header().Set(name, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		log.Printf("警告：[ThumbnailHandler] 轉送縮圖物件 '%s' 中斷: %v", relativePath, err)
+	}
+}
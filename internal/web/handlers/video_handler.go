@@ -2,21 +2,68 @@ package handlers
 
 import (
 	"AiHackathon-admin/internal/config" // 引入 config 以獲取 NAS 路徑
+	"AiHackathon-admin/internal/mediasign"
+	"AiHackathon-admin/internal/webproxy"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
-	"os"            // 用於檢查檔案是否存在
+	"os" // 用於檢查檔案是否存在
+	"path"
 	"path/filepath" // 用於安全地處理檔案路徑
 	"strings"
+	"time"
 )
 
+// MediaObjectStore 物件儲存的影片來源 (由 s3.ObjectStorage 實作)
+type MediaObjectStore interface {
+	OpenRange(relativePath, rangeHeader string) (*http.Response, error)
+	PresignURL(relativePath string, expiry time.Duration) (string, error)
+}
+
+// MediaLookup 提供影片檔案時需要的資料庫查詢 (由 DBStore 實作)：已產生的瀏覽器播放版本與影片的限制條件
+type MediaLookup interface {
+	GetWebProxyPath(sourcePath string) (string, bool, error)
+	IsVideoRestricted(sourceName, sourceID string) (bool, error)
+}
+
+// proxiedMediaHeaders 轉送物件儲存回應時保留的標頭
+var proxiedMediaHeaders = []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges", "ETag", "Last-Modified"}
+
 // VideoHandler 負責提供影片檔案串流
 type VideoHandler struct {
 	nasBasePath string // NAS 影片儲存的絕對根路徑
+
+	objects       MediaObjectStore // nas.backend 為 s3 時不為 nil
+	presign       bool             // true 時重新導向至預先簽署網址，否則由伺服器轉送 Range 請求
+	presignExpiry time.Duration
+
+	lookup          MediaLookup // 可為 nil (不使用播放版本，restricted 模式視所有影片為有限制)
+	proxyExtensions []string    // webProxy.extensions；只有這些格式需要查詢播放版本
+
+	signer  *mediasign.Signer // mediaSigning.secret 未設定時為 nil
+	require string            // mediaSigning.require：none、restricted 或 all
 }
 
-// NewVideoHandler 建立一個 VideoHandler 實例
-func NewVideoHandler(nasCfg config.NASConfig) (*VideoHandler, error) {
+// NewVideoHandler 建立一個 VideoHandler 實例；objects 不為 nil 時改由物件儲存提供影片 (依 nas.s3.streamMode)。
+// lookup 不為 nil 時，webProxy.extensions 格式的影片自動改為提供已產生的播放版本。簽章驗證見 Protect
+func NewVideoHandler(nasCfg config.NASConfig, objects MediaObjectStore, proxyCfg config.WebProxyConfig, signingCfg config.MediaSigningConfig, lookup MediaLookup) (*VideoHandler, error) {
+	signer := mediasign.New(signingCfg.Secret, time.Duration(signingCfg.TTLMinutes)*time.Minute)
+	if objects != nil {
+		h := &VideoHandler{
+			objects:         objects,
+			presign:         nasCfg.S3.StreamMode == "presign",
+			presignExpiry:   time.Duration(nasCfg.S3.PresignMinutes) * time.Minute,
+			lookup:          lookup,
+			proxyExtensions: proxyCfg.Extensions,
+			signer:          signer,
+			require:         signingCfg.Require,
+		}
+		log.Printf("資訊：[VideoHandler] 初始化成功，影片由物件儲存提供 (串流方式: %s)", nasCfg.S3.StreamMode)
+		return h, nil
+	}
 	if nasCfg.VideoPath == "" {
 		return nil, fmt.Errorf("VideoHandler: NAS 設定中的 videoPath 不得為空")
 	}
@@ -25,12 +72,51 @@ func NewVideoHandler(nasCfg config.NASConfig) (*VideoHandler, error) {
 		return nil, fmt.Errorf("VideoHandler: 無法取得 NAS videoPath 的絕對路徑 '%s': %w", nasCfg.VideoPath, err)
 	}
 	log.Printf("資訊：[VideoHandler] 初始化成功，影片服務根路徑: %s", absBasePath)
-	return &VideoHandler{nasBasePath: absBasePath}, nil
+	return &VideoHandler{nasBasePath: absBasePath, lookup: lookup, proxyExtensions: proxyCfg.Extensions, signer: signer, require: signingCfg.Require}, nil
+}
+
+// Protect 依 mediaSigning 設定保護 prefix (/media/ 或 /thumbs/) 下的 NAS 檔案，authenticated 為 signed 加上登入檢查：
+// 帶有有效簽章的請求不需登入即由 signed 處理，簽章無效或過期時拒絕；沒有簽章時依 require 拒絕，否則交由 authenticated 處理
+func (h *VideoHandler) Protect(prefix string, signed, authenticated http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		relativePath := strings.TrimPrefix(r.URL.Path, prefix)
+		err := h.signer.Verify(relativePath, r.URL.Query(), time.Now())
+		switch {
+		case err == nil:
+			signed.ServeHTTP(w, r)
+		case !errors.Is(err, mediasign.ErrMissing):
+			log.Printf("警告：[VideoHandler] 拒絕 '%s' 的請求 (來自 %s): %v", relativePath, r.RemoteAddr, err)
+			http.Error(w, "影片網址已過期或簽章無效，請重新整理頁面", http.StatusForbidden)
+		case h.requiresSignature(relativePath):
+			http.Error(w, "此影片需使用已簽署的網址存取", http.StatusForbidden)
+		default:
+			authenticated.ServeHTTP(w, r)
+		}
+	})
+}
+
+// requiresSignature 判斷沒有簽章的請求是否應被拒絕；restricted 模式下查詢失敗時視為需要簽章
+func (h *VideoHandler) requiresSignature(relativePath string) bool {
+	if h.require != "restricted" {
+		return h.require == "all"
+	}
+	// NAS 路徑為 <來源>/<影片ID>/<檔名> (播放版本為 <來源>/<影片ID>/.web/<檔名>)
+	parts := strings.SplitN(path.Clean(relativePath), "/", 3)
+	if len(parts) < 3 || h.lookup == nil {
+		return true
+	}
+	restricted, err := h.lookup.IsVideoRestricted(parts[0], parts[1])
+	if err != nil {
+		log.Printf("警告：[VideoHandler] %v，視為需要簽章。", err)
+		return true
+	}
+	return restricted
 }
 
 // ServeHTTP 實現 http.Handler 介面
 // 它期望 URL 路徑是 /media/{影片在NASPath下的相對路徑}
 // 例如：/media/ap/videoID123/video.mp4
+// 瀏覽器無法播放的格式 (例如 .mkv) 已產生播放版本時改為提供播放版本；加上 ?original=1 時下載原始檔
 func (h *VideoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// 從 URL 中提取影片的相對路徑
 	// URL.Path 會是例如 "/media/ap/videoID123/video.mp4"
@@ -40,6 +126,16 @@ func (h *VideoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "無效的影片路徑", http.StatusBadRequest)
 		return
 	}
+	if r.URL.Query().Get("original") == "1" {
+		// presign 模式重新導向至物件儲存，此標頭不會生效，由瀏覽器決定播放或下載
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(relativePath)}))
+	} else {
+		relativePath = h.playbackPath(relativePath)
+	}
+	if h.objects != nil {
+		h.serveObject(w, r, relativePath)
+		return
+	}
 
 	// 安全地組合絕對路徑
 	// filepath.Join 會清理路徑，防止路徑遍歷攻擊 (例如 ../)
@@ -74,3 +170,69 @@ func (h *VideoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// http.ServeFile 會自動處理 Content-Type, ETag, Range requests (用於影片跳轉) 等
 	http.ServeFile(w, r, cleanedFullPath)
 }
+
+// playbackPath 原始檔為 webProxy.extensions 的格式且已產生播放版本時回傳播放版本的路徑，否則回傳原路徑
+func (h *VideoHandler) playbackPath(relativePath string) string {
+	if h.lookup == nil || !webproxy.Needs(relativePath, h.proxyExtensions) {
+		return relativePath
+	}
+	proxyPath, ok, err := h.lookup.GetWebProxyPath(path.Clean(relativePath))
+	if err != nil {
+		log.Printf("警告：[VideoHandler] %v，改為提供原始檔。", err)
+		return relativePath
+	}
+	if !ok {
+		return relativePath
+	}
+	if h.objects == nil {
+		// 播放版本可能已被刪除 (例如保留政策)，此時仍提供原始檔
+		if _, err := os.Stat(filepath.Join(h.nasBasePath, filepath.FromSlash(proxyPath))); err != nil {
+			return relativePath
+		}
+	}
+	return proxyPath
+}
+
+// serveObject 由物件儲存提供影片：presign 模式重新導向至預先簽署網址，proxy 模式將 Range 請求轉送至物件儲存
+func (h *VideoHandler) serveObject(w http.ResponseWriter, r *http.Request, relativePath string) {
+	if h.presign {
+		url, err := h.objects.PresignURL(relativePath, h.presignExpiry)
+		if err != nil {
+			log.Printf("警告：[VideoHandler] 無法產生 '%s' 的預先簽署網址: %v", relativePath, err)
+			http.Error(w, "無效的影片路徑", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, url, http.StatusFound)
+		return
+	}
+
+	resp, err := h.objects.OpenRange(relativePath, r.Header.Get("Range"))
+	if err != nil {
+		var status interface{ HTTPStatus() int }
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			log.Printf("錯誤：[VideoHandler] 請求的影片物件不存在: %s", relativePath)
+			http.NotFound(w, r)
+		case errors.As(err, &status) && status.HTTPStatus() == http.StatusRequestedRangeNotSatisfiable:
+			http.Error(w, "無效的範圍", http.StatusRequestedRangeNotSatisfiable)
+		default:
+			log.Printf("錯誤：[VideoHandler] 讀取影片物件 '%s' 失敗: %v", relativePath, err)
+			http.Error(w, "內部伺服器錯誤", http.StatusBadGateway)
+		}
+		return
+	}
+	defer resp.Body.Close()
+	for _, name := range proxiedMediaHeaders {
+		if v := resp.Header.Get(name); v != "" {
+			w.Header().Set(name, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		log.Printf("警告：[VideoHandler] 轉送影片物件 '%s' 中斷: %v", relativePath, err)
+	}
+}
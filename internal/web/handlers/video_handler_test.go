@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/mediasign"
+	"AiHackathon-admin/internal/models"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSigningSecret = "0123456789abcdef0123456789abcdef"
+
+// fakeMediaDB 提供影片、版本清單與限制條件查詢
+type fakeMediaDB struct {
+	DBStore
+
+	video models.Video
+}
+
+func (db *fakeMediaDB) GetVideoByID(videoID int64) (*models.Video, error) {
+	if videoID != db.video.ID {
+		return nil, nil
+	}
+	v := db.video
+	return &v, nil
+}
+
+func (db *fakeMediaDB) GetVideoFiles(videoIDs []int64) (map[int64][]models.VideoFile, error) {
+	return map[int64][]models.VideoFile{}, nil
+}
+
+func (db *fakeMediaDB) GetWebProxyPath(sourcePath string) (string, bool, error) {
+	return "", false, nil
+}
+
+func (db *fakeMediaDB) IsVideoRestricted(sourceName, sourceID string) (bool, error) {
+	return sourceName == db.video.SourceName && sourceID == db.video.SourceID && db.video.Restrictions.Valid, nil
+}
+
+func mediaTestVideo() models.Video {
+	return models.Video{
+		ID: 7, SourceName: "ap", SourceID: "123", NASPath: "ap/123/clip.mp4",
+		Restrictions:     sql.NullString{String: "No access Ukraine", Valid: true},
+		PosterPath:       sql.NullString{String: "ap/123/clip.poster.jpg", Valid: true},
+		ContactSheetPath: sql.NullString{String: "ap/123/clip.contact.jpg", Valid: true},
+	}
+}
+
+func TestVideoHandlerProtect(t *testing.T) {
+	base := t.TempDir()
+	for _, name := range []string{"clip.mp4", "clip.poster.jpg"} {
+		if err := os.MkdirAll(filepath.Join(base, "ap", "123"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(base, "ap", "123", name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db := &fakeMediaDB{video: mediaTestVideo()}
+	signingCfg := config.MediaSigningConfig{Secret: testSigningSecret, Require: "restricted", TTLMinutes: 10}
+	videoHandler, err := NewVideoHandler(config.NASConfig{VideoPath: base}, nil, config.WebProxyConfig{}, signingCfg, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumbnailHandler, err := NewThumbnailHandler(config.NASConfig{VideoPath: base}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 以標頭模擬已登入的檢視者
+	viewer := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Test-User") == "" {
+				http.Error(w, "未登入", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	mux := http.NewServeMux()
+	media := http.StripPrefix("/media/", videoHandler)
+	mux.Handle("/media/", videoHandler.Protect("/media/", media, viewer(media)))
+	thumbs := http.StripPrefix("/thumbs/", thumbnailHandler)
+	mux.Handle("/thumbs/", videoHandler.Protect("/thumbs/", thumbs, viewer(thumbs)))
+
+	signer := mediasign.New(testSigningSecret, 10*time.Minute)
+	tests := []struct {
+		name     string
+		url      string
+		loggedIn bool
+		wantCode int
+	}{
+		{name: "已簽署的影片", url: signer.URL("/media", "ap/123/clip.mp4"), wantCode: http.StatusOK},
+		{name: "已簽署的海報", url: signer.URL("/thumbs", "ap/123/clip.poster.jpg"), wantCode: http.StatusOK},
+		{name: "已簽署的海報附帶版本參數", url: appendQuery(signer.URL("/thumbs", "ap/123/clip.poster.jpg"), "v=1"), wantCode: http.StatusOK},
+		{name: "限制級影片的海報未簽署", url: "/thumbs/ap/123/clip.poster.jpg", loggedIn: true, wantCode: http.StatusForbidden},
+		{name: "限制級影片未簽署", url: "/media/ap/123/clip.mp4", loggedIn: true, wantCode: http.StatusForbidden},
+		{name: "簽章不涵蓋其他檔案", url: strings.Replace(signer.URL("/thumbs", "ap/123/clip.poster.jpg"), "poster", "contact", 1), wantCode: http.StatusForbidden},
+		{name: "過期的簽章", url: signer.URLUntil("/thumbs", "ap/123/clip.poster.jpg", time.Now().Add(-time.Minute)), wantCode: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.loggedIn {
+				r.Header.Set("X-Test-User", "viewer")
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != tt.wantCode {
+				t.Errorf("GET %s: status %d, want %d (%s)", tt.url, w.Code, tt.wantCode, strings.TrimSpace(w.Body.String()))
+			}
+		})
+	}
+
+	// 沒有限制條件的影片未簽署時交由登入檢查
+	db.video.Restrictions = sql.NullString{}
+	for _, u := range []string{"/thumbs/ap/123/clip.poster.jpg", "/media/ap/123/clip.mp4"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, u, nil))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("GET %s 未登入: status %d, want 401", u, w.Code)
+		}
+	}
+}
+
+func TestMediaURLHandlerThumbnails(t *testing.T) {
+	db := &fakeMediaDB{video: mediaTestVideo()}
+	h := NewMediaURLHandler(db, config.ExportConfig{PublicBaseURL: "https://admin.example.com/"}, config.MediaSigningConfig{Secret: testSigningSecret, TTLMinutes: 10})
+	mux := http.NewServeMux()
+	mux.Handle("/api/v1/videos/{id}/media", h)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/videos/7/media", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	var resp struct {
+		Files []struct {
+			URL string `json:"url"`
+		} `json:"files"`
+		PosterURL       string `json:"poster_url"`
+		ContactSheetURL string `json:"contact_sheet_url"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	signer := mediasign.New(testSigningSecret, 0)
+	for _, tt := range []struct{ url, prefix, relPath string }{
+		{resp.Files[0].URL, "https://admin.example.com/media/", "ap/123/clip.mp4"},
+		{resp.PosterURL, "https://admin.example.com/thumbs/", "ap/123/clip.poster.jpg"},
+		{resp.ContactSheetURL, "https://admin.example.com/thumbs/", "ap/123/clip.contact.jpg"},
+	} {
+		u, err := url.Parse(tt.url)
+		if err != nil || !strings.HasPrefix(tt.url, tt.prefix+tt.relPath+"?") {
+			t.Errorf("網址 = %q, want %s%s?...", tt.url, tt.prefix, tt.relPath)
+			continue
+		}
+		if err := signer.Verify(tt.relPath, u.Query(), time.Now()); err != nil {
+			t.Errorf("%s 的簽章無效: %v", tt.url, err)
+		}
+	}
+}
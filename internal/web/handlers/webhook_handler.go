@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"AiHackathon-admin/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+)
+
+// ErrWebhookDeliveryInProgress 傳送紀錄仍為 pending (原本的推送仍在等待或重試中)，不可重新推送
+var ErrWebhookDeliveryInProgress = errors.New("傳送紀錄仍在等待推送或重試中")
+
+// WebhookReplayer 定義了 WebhookHandler 重新推送傳送紀錄所需的操作
+type WebhookReplayer interface {
+	ReplayDelivery(id int64) error
+}
+
+// WebhookDeliveryDisplay 為 webhooks 頁面中單筆傳送紀錄的顯示資料
+type WebhookDeliveryDisplay struct {
+	models.WebhookDelivery
+	VideoTitle string
+}
+
+// WebhookPageData 為 webhooks 頁面的範本資料
+type WebhookPageData struct {
+	Failed  []WebhookDeliveryDisplay
+	Pending []WebhookDeliveryDisplay
+}
+
+// WebhookHandler 顯示失敗/等待中的 webhook 傳送紀錄，並提供重新推送
+// 路由:
+//   - GET  /webhooks                              傳送紀錄頁面
+//   - POST /webhooks/deliveries/{id}/replay       重新推送
+type WebhookHandler struct {
+	db       DBStore
+	replayer WebhookReplayer
+	tpl      *pageTemplate
+}
+
+// NewWebhookHandler 建立一個 WebhookHandler 實例
+func NewWebhookHandler(db DBStore, replayer WebhookReplayer, templateBasePath string) (*WebhookHandler, error) {
+	if db == nil {
+		return nil, fmt.Errorf("DBStore不得為nil")
+	}
+	if replayer == nil {
+		return nil, fmt.Errorf("WebhookReplayer不得為nil")
+	}
+	tplPath := filepath.Join(templateBasePath, "webhooks.html")
+	tpl, err := loadPageTemplate(tplPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("無法解析 webhooks 範本 '%s': %w", tplPath, err)
+	}
+	return &WebhookHandler{db: db, replayer: replayer, tpl: tpl}, nil
+}
+
+func (h *WebhookHandler) loadDisplay(status models.WebhookDeliveryStatus) ([]WebhookDeliveryDisplay, error) {
+	deliveries, err := h.db.GetWebhookDeliveriesByStatus(status, 200)
+	if err != nil {
+		return nil, err
+	}
+	titles := make(map[int64]string)
+	displays := make([]WebhookDeliveryDisplay, 0, len(deliveries))
+	for _, d := range deliveries {
+		title, ok := titles[d.VideoID]
+		if !ok {
+			if v, err := h.db.GetVideoByID(d.VideoID); err == nil && v != nil {
+				title = v.Title.String
+				if title == "" {
+					title = v.SourceName + v.SourceID
+				}
+			}
+			titles[d.VideoID] = title
+		}
+		displays = append(displays, WebhookDeliveryDisplay{WebhookDelivery: d, VideoTitle: title})
+	}
+	return displays, nil
+}
+
+// ServeList 顯示 webhook 傳送紀錄頁面
+func (h *WebhookHandler) ServeList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "僅支援 GET 方法", http.StatusMethodNotAllowed)
+		return
+	}
+	var data WebhookPageData
+	var err error
+	if data.Failed, err = h.loadDisplay(models.WebhookDeliveryFailed); err == nil {
+		data.Pending, err = h.loadDisplay(models.WebhookDeliveryPending)
+	}
+	if err != nil {
+		log.Printf("錯誤：[WebhookHandler] 查詢 webhook 傳送紀錄失敗: %v", err)
+		http.Error(w, "內部伺服器錯誤", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := h.tpl.Execute(w, data); err != nil {
+		log.Printf("錯誤：[WebhookHandler] 渲染 webhooks 範本失敗: %v", err)
+	}
+}
+
+// ServeReplay 重新推送指定的傳送紀錄
+func (h *WebhookHandler) ServeReplay(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "僅支援 POST 方法"})
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "無效的傳送紀錄 ID"})
+		return
+	}
+	if err := h.replayer.ReplayDelivery(id); err != nil {
+		log.Printf("錯誤：[WebhookHandler] 重新推送傳送紀錄 %d 失敗: %v", id, err)
+		if errors.Is(err, ErrWebhookDeliveryInProgress) {
+			w.WriteHeader(http.StatusConflict)
+		} else {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": fmt.Sprintf("傳送紀錄 %d 已重新排入推送", id)})
+}
@@ -1,7 +1,9 @@
 package web
 
 import (
+	"AiHackathon-admin/internal/auth"
 	"AiHackathon-admin/internal/config"
+	"AiHackathon-admin/internal/models"
 	"AiHackathon-admin/internal/services"
 	"AiHackathon-admin/internal/web/handlers"
 	"log"
@@ -15,17 +17,69 @@ type AnalysisServiceRunner interface {
 	handlers.VideoContentPipelineRunner
 }
 
-// SetupRouter 更新：接收 config.NASConfig
-func SetupRouter(appConfig *config.Config, db handlers.DBStore, analyzeService *services.AnalyzeService) http.Handler {
+// Dependencies 路由使用的資料庫與服務；DB、Analyze 與 Auth 必填，其餘為 nil 時不提供對應的頁面與 API
+type Dependencies struct {
+	DB           handlers.DBStore
+	Analyze      *services.AnalyzeService
+	Webhooks     *services.WebhookService
+	Alerts       *services.AlertService
+	Costs        *services.CostService
+	Retention    *services.RetentionService
+	MediaObjects handlers.MediaObjectStore // nas.backend 為 s3 時不為 nil，否則由本地 NAS 提供影片與縮圖
+	Auth         *auth.Manager
+	OIDC         *auth.OIDCProvider // 未啟用 OIDC 時為 nil
+}
+
+// SetupRouter 依設定與 deps 建立所有路由
+// 除登入相關路由外，所有路由皆需登入；角色需求：
+//   - viewer：瀏覽儀表板、匯出、字幕、訂閱源、影片串流與縮圖、prompt 品質指標與各管理頁面
+//   - editor：管理快訊規則、重新推送 webhook
+//   - admin：手動觸發分析、查看稽核紀錄、管理 prompt 版本
+func SetupRouter(appConfig *config.Config, deps Dependencies) http.Handler {
 	mux := http.NewServeMux()
 	templateBasePath := "internal/web/templates"
 
+	if deps.Auth == nil {
+		log.Panicln("SetupRouter：auth.Manager 不得為空")
+	}
+	viewer := func(h http.Handler) http.Handler { return deps.Auth.Require(models.RoleViewer, h) }
+	editor := func(h http.Handler) http.Handler { return deps.Auth.Require(models.RoleEditor, h) }
+	admin := func(h http.Handler) http.Handler { return deps.Auth.Require(models.RoleAdmin, h) }
+
+	// 登入 / 登出 / OIDC
+	authHandler, err := handlers.NewAuthHandler(deps.DB, deps.Auth, deps.OIDC, appConfig.Auth.CookieSecure, templateBasePath)
+	if err != nil {
+		log.Fatalf("錯誤：無法建立 Auth Handler: %v", err)
+	}
+	mux.HandleFunc("/login", authHandler.ServeLogin)
+	mux.Handle("/logout", viewer(http.HandlerFunc(authHandler.ServeLogout)))
+	mux.HandleFunc("/auth/oidc/login", authHandler.ServeOIDCLogin)
+	mux.HandleFunc("/auth/oidc/callback", authHandler.ServeOIDCCallback)
+
+	// 操作稽核紀錄
+	auditHandler, err := handlers.NewAuditHandler(deps.DB, templateBasePath)
+	if err != nil {
+		log.Fatalf("錯誤：無法建立 Audit Handler: %v", err)
+	}
+	mux.Handle("/admin/audit", admin(auditHandler))
+
+	// Prompt 版本管理 (建立、比較、切換使用版本)
+	promptHandler, err := handlers.NewPromptHandler(deps.DB, templateBasePath)
+	if err != nil {
+		log.Fatalf("錯誤：無法建立 Prompt Handler: %v", err)
+	}
+	mux.Handle("/admin/prompts", admin(http.HandlerFunc(promptHandler.ServePage)))
+	mux.Handle("/api/v1/prompts/{kind}", admin(http.HandlerFunc(promptHandler.ServeVersions)))
+	mux.Handle("/api/v1/prompts/{kind}/diff", admin(http.HandlerFunc(promptHandler.ServeDiff)))
+	mux.Handle("/api/v1/prompts/{kind}/{version}", admin(http.HandlerFunc(promptHandler.ServeVersion)))
+	mux.Handle("/api/v1/prompts/{kind}/{version}/activate", admin(http.HandlerFunc(promptHandler.ServeActivate)))
+
 	// Dashboard Handler
-	dashboardHandler, err := handlers.NewDashboardHandler(db, templateBasePath)
+	dashboardHandler, err := handlers.NewDashboardHandler(deps.DB, appConfig.NAS.Renditions, appConfig.WebProxy, appConfig.MediaSigning, templateBasePath)
 	if err != nil {
 		log.Fatalf("錯誤：無法建立 Dashboard Handler: %v", err)
 	}
-	mux.Handle("/dashboard", dashboardHandler)
+	mux.Handle("/dashboard", viewer(dashboardHandler))
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path == "/" {
 			http.Redirect(w, r, "/dashboard", http.StatusFound)
@@ -37,28 +91,105 @@ func SetupRouter(appConfig *config.Config, db handlers.DBStore, analyzeService *
 	})
 
 	// 手動觸發分析的路由 (保持不變)
-	if analyzeService == nil {
+	if deps.Analyze == nil {
 		log.Panicln("SetupRouter：AnalyzeService 不得為空")
 	}
-	triggerTextAnalysisHandler := handlers.NewTriggerTextAnalysisHandler(analyzeService)
-	mux.Handle("/manual-text-analyze", triggerTextAnalysisHandler)
-	triggerVideoAnalysisHandler := handlers.NewTriggerVideoAnalysisHandler(analyzeService)
-	mux.Handle("/manual-video-analyze", triggerVideoAnalysisHandler)
+	triggerTextAnalysisHandler := handlers.NewTriggerTextAnalysisHandler(deps.Analyze)
+	mux.Handle("/manual-text-analyze", admin(triggerTextAnalysisHandler))
+	triggerVideoAnalysisHandler := handlers.NewTriggerVideoAnalysisHandler(deps.Analyze)
+	mux.Handle("/manual-video-analyze", admin(triggerVideoAnalysisHandler))
 
 	// 匯出處理器
-	exportHandler := handlers.NewExportHandler(db)
-	mux.Handle("/export", exportHandler)
+	exportHandler := handlers.NewExportHandler(deps.DB)
+	mux.Handle("/export", viewer(exportHandler))
+
+	// NewsML-G2 匯出 (單一項目與批次 zip)
+	newsMLHandler := handlers.NewNewsMLHandler(deps.DB, appConfig.Export, appConfig.MediaSigning)
+	mux.Handle("/api/v1/videos/{id}/newsml.xml", viewer(http.HandlerFunc(newsMLHandler.ServeItem)))
+	mux.Handle("/export/newsml.zip", viewer(http.HandlerFunc(newsMLHandler.ServeBatch)))
+
+	// 編輯審核 (GET 查詢，PUT 修訂/核可/退回)
+	reviewHandler := handlers.NewReviewHandler(deps.DB)
+	mux.Handle("/api/v1/videos/{id}/review", deps.Auth.RequireByMethod(models.RoleViewer, models.RoleEditor, reviewHandler))
+
+	// Prompt 品質指標 (依編輯審核紀錄計算)
+	promptQualityHandler, err := handlers.NewPromptQualityHandler(deps.DB, appConfig.Prompts, templateBasePath)
+	if err != nil {
+		log.Fatalf("錯誤：無法建立 Prompt Quality Handler: %v", err)
+	}
+	mux.Handle("/prompt-quality", viewer(http.HandlerFunc(promptQualityHandler.ServePage)))
+	mux.Handle("/api/v1/prompt-quality", viewer(http.HandlerFunc(promptQualityHandler.ServeJSON)))
+
+	// Gemini token 用量與費用報表
+	if deps.Costs != nil {
+		costHandler, err := handlers.NewCostHandler(deps.Costs, templateBasePath)
+		if err != nil {
+			log.Fatalf("錯誤：無法建立 Cost Handler: %v", err)
+		}
+		mux.Handle("/costs", viewer(http.HandlerFunc(costHandler.ServePage)))
+		mux.Handle("/api/v1/costs", viewer(http.HandlerFunc(costHandler.ServeJSON)))
+	}
+
+	// NAS 保留政策試算與編輯釘選
+	if deps.Retention != nil {
+		retentionHandler := handlers.NewRetentionHandler(deps.Retention)
+		mux.Handle("/api/v1/retention/report", admin(http.HandlerFunc(retentionHandler.ServeReport)))
+	}
+	mux.Handle("/api/v1/videos/{id}/pin", editor(handlers.NewPinHandler(deps.DB)))
+	// 影片各版本的 /media/ 網址 (設定 mediaSigning.secret 時附帶簽章)
+	mux.Handle("/api/v1/videos/{id}/media", viewer(handlers.NewMediaURLHandler(deps.DB, appConfig.Export, appConfig.MediaSigning)))
+
+	// 字幕 (SRT/WebVTT) 路由
+	subtitleHandler := handlers.NewSubtitleHandler(deps.DB)
+	mux.Handle("/api/v1/videos/{id}/{file}", viewer(subtitleHandler))
+
+	// Atom 訂閱源 (閱讀器可使用 HTTP Basic 認證，見 auth.basicAuthForGets)
+	feedHandler := handlers.NewFeedHandler(deps.DB, appConfig.Feeds, appConfig.Export)
+	mux.Handle("/feeds/{file}", viewer(feedHandler))
+
+	// Webhook 傳送紀錄與重新推送
+	if deps.Webhooks != nil {
+		webhookHandler, err := handlers.NewWebhookHandler(deps.DB, deps.Webhooks, templateBasePath)
+		if err != nil {
+			log.Fatalf("錯誤：無法建立 Webhook Handler: %v", err)
+		}
+		mux.Handle("/webhooks", viewer(http.HandlerFunc(webhookHandler.ServeList)))
+		mux.Handle("/webhooks/deliveries/{id}/replay", editor(http.HandlerFunc(webhookHandler.ServeReplay)))
+	}
+
+	// 快訊規則管理與測試寄送
+	if deps.Alerts != nil {
+		alertHandler, err := handlers.NewAlertHandler(deps.DB, deps.Alerts, templateBasePath)
+		if err != nil {
+			log.Fatalf("錯誤：無法建立 Alert Handler: %v", err)
+		}
+		mux.Handle("/alerts", viewer(http.HandlerFunc(alertHandler.ServePage)))
+		mux.Handle("/api/v1/alerts/rules", deps.Auth.RequireByMethod(models.RoleViewer, models.RoleEditor, http.HandlerFunc(alertHandler.ServeRules)))
+		mux.Handle("/api/v1/alerts/rules/{id}", editor(http.HandlerFunc(alertHandler.ServeRule)))
+		mux.Handle("/api/v1/alerts/rules/{id}/test", editor(http.HandlerFunc(alertHandler.ServeTest)))
+	}
 
 	// --- 新增：影片串流服務路由 ---
-	videoHandler, err := handlers.NewVideoHandler(appConfig.NAS) // 使用 appConfig.NAS
+	videoHandler, err := handlers.NewVideoHandler(appConfig.NAS, deps.MediaObjects, appConfig.WebProxy, appConfig.MediaSigning, deps.DB) // 使用 appConfig.NAS；deps.MediaObjects 為 nil 時由本地 NAS 提供
 	if err != nil {
 		log.Fatalf("錯誤：無法建立 Video Handler: %v", err)
 	}
 	// http.StripPrefix 會移除 "/media/" 前綴，然後將剩餘路徑傳遞給 videoHandler
 	// videoHandler 的 ServeHTTP 內部需要再次處理這個相對路徑以構建完整檔案路徑
-	mux.Handle("/media/", http.StripPrefix("/media/", videoHandler))
+	// 帶有有效簽章的網址不需登入 (mediaSigning)，其餘請求仍需檢視者權限
+	media := http.StripPrefix("/media/", videoHandler)
+	mux.Handle("/media/", videoHandler.Protect("/media/", media, viewer(media)))
 	// --- 結束新增 ---
 
+	// 影片海報與關鍵影格縮圖表 (由縮圖排程任務產生於影片旁)
+	thumbnailHandler, err := handlers.NewThumbnailHandler(appConfig.NAS, deps.MediaObjects)
+	if err != nil {
+		log.Fatalf("錯誤：無法建立 Thumbnail Handler: %v", err)
+	}
+	// 縮圖與影片使用相同的簽章規則 (限制級影片的海報同樣需要簽章)
+	thumbs := http.StripPrefix("/thumbs/", thumbnailHandler)
+	mux.Handle("/thumbs/", videoHandler.Protect("/thumbs/", thumbs, viewer(thumbs)))
+
 	// 將根路徑的 NotFound 處理移到最後，確保其他 Handle 被優先匹配
 	mux.HandleFunc("/*", func(w http.ResponseWriter, r *http.Request) {
 		// 再次檢查，如果真的是根路徑 "/"，則重定向（避免之前的 HandleFunc "/" 被覆蓋）
@@ -0,0 +1,66 @@
+package webproxy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Dir 播放版本所在的子目錄；以 . 開頭，NAS 掃描 (只讀取影片ID目錄的第一層) 不會把它當成新的版本或觸發重新分析
+const Dir = ".web"
+
+// Options 轉檔參數
+type Options struct {
+	MaxHeight        int    // 輸出高度上限 (像素)；原始影片較低時維持原高度
+	CRF              int    // libx264 畫質 (越小畫質越高)
+	Preset           string // libx264 編碼速度，例如 veryfast
+	AudioBitrateKbps int    // AAC 位元率
+}
+
+// Path 回傳影片 (以 / 分隔的相對路徑) 對應的播放版本路徑，例如 ap/1/clip.mkv -> ap/1/.web/clip.mkv.mp4。
+// 保留原副檔名，避免同目錄的 clip.mkv 與 clip.avi 互相覆蓋
+func Path(videoPath string) string {
+	return path.Join(path.Dir(videoPath), Dir, path.Base(videoPath)+".mp4")
+}
+
+// Needs 判斷影片是否需要產生播放版本 (副檔名在 extensions 中，不分大小寫)
+func Needs(videoPath string, extensions []string) bool {
+	ext := strings.ToLower(path.Ext(videoPath))
+	for _, e := range extensions {
+		if ext != "" && strings.ToLower(e) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// Transcode 以 ffmpeg 將 input (本地路徑或 http(s) 網址) 轉為瀏覽器可播放的 H.264/AAC MP4。
+// 只保留第一個視訊與音訊串流，moov 移至檔案開頭 (faststart) 以便邊下載邊播放
+func Transcode(ctx context.Context, ffmpegPath, input, dst string, opts Options) error {
+	args := []string{
+		"-hide_banner", "-loglevel", "error", "-y",
+		"-i", input,
+		"-map", "0:v:0", "-map", "0:a:0?",
+		"-sn", "-dn",
+		"-c:v", "libx264", "-preset", opts.Preset, "-crf", strconv.Itoa(opts.CRF),
+		"-pix_fmt", "yuv420p",
+		// 高度取偶數，寬度依比例 (-2 同樣取偶數)，yuv420p 需要偶數尺寸
+		"-vf", fmt.Sprintf("scale=-2:'trunc(min(%d,ih)/2)*2'", opts.MaxHeight),
+		"-c:a", "aac", "-b:a", strconv.Itoa(opts.AudioBitrateKbps) + "k", "-ac", "2",
+		"-movflags", "+faststart",
+		"-f", "mp4",
+		dst,
+	}
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ffmpeg 轉檔失敗: %w (%s)", err, strings.TrimSpace(string(out)))
+	}
+	if st, err := os.Stat(dst); err != nil || st.Size() == 0 {
+		return fmt.Errorf("ffmpeg 未產生播放版本 (影片可能沒有可解碼的畫面)")
+	}
+	return nil
+}
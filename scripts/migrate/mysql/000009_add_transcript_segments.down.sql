@@ -0,0 +1,4 @@
+-- Down Migration: Remove segments column from analysis_results table
+
+ALTER TABLE analysis_results
+DROP COLUMN segments;
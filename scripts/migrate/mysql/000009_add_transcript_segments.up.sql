@@ -0,0 +1,4 @@
+-- Up Migration: Add segments column to analysis_results table
+
+ALTER TABLE analysis_results
+ADD COLUMN segments JSON NULL DEFAULT NULL COMMENT '逐字稿時間軸分段 (Gemini分析)' AFTER translation;
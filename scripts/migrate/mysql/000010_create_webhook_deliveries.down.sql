@@ -0,0 +1,3 @@
+-- Down Migration: Drop webhook_deliveries table
+
+DROP TABLE IF EXISTS webhook_deliveries;
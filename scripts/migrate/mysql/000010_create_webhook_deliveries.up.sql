@@ -0,0 +1,19 @@
+-- Up Migration: Create webhook_deliveries table for outbound webhook delivery log
+
+CREATE TABLE webhook_deliveries (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    subscription_name VARCHAR(100) NOT NULL COMMENT '設定檔中的 webhook 訂閱名稱',
+    video_id BIGINT NOT NULL,
+    event VARCHAR(50) NOT NULL,
+    payload JSON NOT NULL,
+    status ENUM('pending', 'succeeded', 'failed') NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_status_code INT NULL DEFAULT NULL,
+    last_error TEXT NULL DEFAULT NULL,
+    next_attempt_at TIMESTAMP NULL DEFAULT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_webhook_status (status, next_attempt_at),
+    INDEX idx_webhook_video (video_id),
+    FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,4 @@
+-- Down Migration: Drop alert tables
+
+DROP TABLE IF EXISTS alert_firings;
+DROP TABLE IF EXISTS alert_rules;
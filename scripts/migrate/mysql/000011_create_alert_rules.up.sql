@@ -0,0 +1,26 @@
+-- Up Migration: Create alert_rules and alert_firings tables for the breaking-news alerting engine
+
+CREATE TABLE alert_rules (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    expression TEXT NOT NULL COMMENT '規則語言，例如 importance = S AND key_factors contains "台灣相關"',
+    channel ENUM('email', 'chat') NOT NULL,
+    target VARCHAR(1000) NOT NULL COMMENT 'email: 以逗號分隔的收件者；chat: webhook URL',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 每個規則對每部影片只觸發一次 (uk_alert_rule_video)
+CREATE TABLE alert_firings (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    rule_id BIGINT NOT NULL,
+    video_id BIGINT NOT NULL,
+    status ENUM('pending', 'sent', 'failed') NOT NULL DEFAULT 'pending',
+    error_message TEXT NULL DEFAULT NULL,
+    fired_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_alert_rule_video (rule_id, video_id),
+    INDEX idx_alert_firings_video (video_id),
+    FOREIGN KEY (rule_id) REFERENCES alert_rules(id) ON DELETE CASCADE,
+    FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
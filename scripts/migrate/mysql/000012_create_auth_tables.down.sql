@@ -0,0 +1,5 @@
+-- Down Migration: Drop authentication tables
+
+DROP TABLE IF EXISTS audit_logs;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,47 @@
+-- Up Migration: Create users, sessions and audit_logs tables for web UI authentication
+
+CREATE TABLE users (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    username VARCHAR(255) NOT NULL,
+    display_name VARCHAR(255) NULL DEFAULT NULL,
+    email VARCHAR(255) NULL DEFAULT NULL,
+    password_hash VARCHAR(255) NULL DEFAULT NULL COMMENT 'bcrypt；OIDC 使用者為 NULL',
+    role ENUM('viewer', 'editor', 'admin') NOT NULL DEFAULT 'viewer',
+    auth_provider ENUM('local', 'oidc') NOT NULL DEFAULT 'local',
+    oidc_subject VARCHAR(255) NULL DEFAULT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    last_login_at TIMESTAMP NULL DEFAULT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uk_users_username (username),
+    UNIQUE KEY uk_users_oidc_subject (oidc_subject)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- id 為 session token 的 SHA-256 (不儲存原始 token)
+CREATE TABLE sessions (
+    id CHAR(64) PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    csrf_token VARCHAR(64) NOT NULL,
+    ip_address VARCHAR(64) NULL DEFAULT NULL,
+    user_agent VARCHAR(255) NULL DEFAULT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_sessions_user (user_id),
+    INDEX idx_sessions_expires (expires_at),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- user_id 允許 NULL (登入失敗或使用者已刪除時仍保留紀錄)
+CREATE TABLE audit_logs (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NULL DEFAULT NULL,
+    username VARCHAR(255) NOT NULL,
+    action VARCHAR(100) NOT NULL,
+    target VARCHAR(500) NULL DEFAULT NULL,
+    status_code INT NULL DEFAULT NULL,
+    ip_address VARCHAR(64) NULL DEFAULT NULL,
+    details TEXT NULL DEFAULT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_audit_created (created_at),
+    INDEX idx_audit_user (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
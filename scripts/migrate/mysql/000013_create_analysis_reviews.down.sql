@@ -0,0 +1,3 @@
+-- Down Migration: Drop analysis_reviews table
+
+DROP TABLE IF EXISTS analysis_reviews;
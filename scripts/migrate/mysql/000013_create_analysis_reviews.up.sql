@@ -0,0 +1,24 @@
+-- Up Migration: Create analysis_reviews table for the editorial review workflow
+-- 編輯修訂與審核狀態獨立存放，analysis_results 保留 Gemini 的原始輸出以供評估 prompt 品質
+
+CREATE TABLE analysis_reviews (
+    video_id BIGINT PRIMARY KEY,
+    status ENUM('pending', 'approved', 'rejected') NOT NULL DEFAULT 'pending',
+    short_summary TEXT NULL DEFAULT NULL COMMENT '編輯修訂後的短摘要；NULL 代表沿用 AI 輸出',
+    bites JSON NULL DEFAULT NULL COMMENT '編輯修訂後的 BITE (格式同 analysis_results.bites)',
+    location VARCHAR(255) NULL DEFAULT NULL COMMENT '編輯修訂後的主要地點',
+    overall_rating CHAR(1) NULL DEFAULT NULL COMMENT '編輯修訂後的重要性評級 (S/A/B/C/N)',
+    note TEXT NULL DEFAULT NULL,
+    edited_by_user_id BIGINT NULL DEFAULT NULL,
+    edited_by VARCHAR(255) NULL DEFAULT NULL,
+    edited_at TIMESTAMP NULL DEFAULT NULL,
+    reviewed_by_user_id BIGINT NULL DEFAULT NULL,
+    reviewed_by VARCHAR(255) NULL DEFAULT NULL,
+    reviewed_at TIMESTAMP NULL DEFAULT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_analysis_reviews_status (status),
+    FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE,
+    FOREIGN KEY (edited_by_user_id) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (reviewed_by_user_id) REFERENCES users(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
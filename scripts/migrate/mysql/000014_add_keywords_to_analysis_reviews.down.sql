@@ -0,0 +1,4 @@
+-- Down Migration: Remove keywords column from analysis_reviews table
+
+ALTER TABLE analysis_reviews
+DROP COLUMN keywords;
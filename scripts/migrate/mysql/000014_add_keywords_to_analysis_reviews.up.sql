@@ -0,0 +1,4 @@
+-- Up Migration: Add keywords override column to analysis_reviews table (used for prompt keyword precision)
+
+ALTER TABLE analysis_reviews
+ADD COLUMN keywords JSON NULL DEFAULT NULL COMMENT '編輯修訂後的關鍵字 (格式同 analysis_results.keywords)' AFTER bites;
@@ -0,0 +1,4 @@
+-- Down Migration: Drop prompt version tables
+
+DROP TABLE IF EXISTS prompt_active_versions;
+DROP TABLE IF EXISTS prompt_versions;
@@ -0,0 +1,23 @@
+-- Up Migration: Store prompt versions in the database so they can be managed and activated at runtime
+-- 設定檔 (configs/prompts/) 中的版本會在首次啟動時匯入
+
+CREATE TABLE prompt_versions (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    kind ENUM('text', 'video') NOT NULL COMMENT 'text: 文本元數據分析；video: 影片內容分析',
+    version VARCHAR(64) NOT NULL,
+    content MEDIUMTEXT NOT NULL,
+    base_version VARCHAR(64) NULL DEFAULT NULL COMMENT '建立時所依據的版本',
+    author VARCHAR(255) NOT NULL,
+    notes TEXT NULL DEFAULT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_prompt_versions_kind_version (kind, version)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 每種分析目前使用的版本
+CREATE TABLE prompt_active_versions (
+    kind ENUM('text', 'video') PRIMARY KEY,
+    version VARCHAR(64) NOT NULL,
+    activated_by VARCHAR(255) NOT NULL,
+    activated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (kind, version) REFERENCES prompt_versions(kind, version)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
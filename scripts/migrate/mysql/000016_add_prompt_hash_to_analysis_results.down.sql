@@ -0,0 +1,3 @@
+-- Down Migration: Remove prompt_hash from analysis_results
+ALTER TABLE analysis_results
+DROP COLUMN prompt_hash;
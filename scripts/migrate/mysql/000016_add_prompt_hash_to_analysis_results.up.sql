@@ -0,0 +1,3 @@
+-- Up Migration: Record the SHA-256 of the rendered prompt sent to Gemini for reproducibility
+ALTER TABLE analysis_results
+ADD COLUMN prompt_hash CHAR(64) NULL DEFAULT NULL COMMENT '實際送出 (已填入影片元數據) 之 prompt 的 SHA-256' AFTER prompt_version;
@@ -0,0 +1,4 @@
+-- Down Migration: Remove model_name and model_params from analysis_results
+ALTER TABLE analysis_results
+DROP COLUMN model_params,
+DROP COLUMN model_name;
@@ -0,0 +1,4 @@
+-- Up Migration: Record the Gemini model and generation parameters used for each analysis
+ALTER TABLE analysis_results
+ADD COLUMN model_name VARCHAR(100) NULL DEFAULT NULL COMMENT '影片內容分析使用的 Gemini 模型' AFTER prompt_hash,
+ADD COLUMN model_params JSON NULL DEFAULT NULL COMMENT '生成參數 (temperature、max tokens、安全設定等)' AFTER model_name;
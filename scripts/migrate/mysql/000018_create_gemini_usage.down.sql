@@ -0,0 +1,2 @@
+-- Down Migration: Drop gemini_usage
+DROP TABLE IF EXISTS gemini_usage;
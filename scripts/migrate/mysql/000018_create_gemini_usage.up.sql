@@ -0,0 +1,18 @@
+-- Up Migration: Record token usage of every Gemini GenerateContent call for cost accounting
+-- 費用於報表產生時依設定檔 costs.prices 計算，因此只保存 token 數
+
+CREATE TABLE gemini_usage (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    video_id BIGINT NULL DEFAULT NULL,
+    source_name VARCHAR(50) NULL DEFAULT NULL,
+    task ENUM('text', 'video') NOT NULL COMMENT 'text: 文本元數據分析；video: 影片內容分析',
+    model_name VARCHAR(100) NOT NULL,
+    prompt_version VARCHAR(64) NULL DEFAULT NULL,
+    prompt_tokens INT NOT NULL DEFAULT 0,
+    candidate_tokens INT NOT NULL DEFAULT 0,
+    total_tokens INT NOT NULL DEFAULT 0,
+    success BOOLEAN NOT NULL DEFAULT TRUE COMMENT '呼叫是否取得可用的分析結果',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_gemini_usage_created_at (created_at),
+    FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
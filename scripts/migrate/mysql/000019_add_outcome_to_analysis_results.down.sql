@@ -0,0 +1,6 @@
+-- Down Migration: Remove outcome, fallback and safety_ratings from analysis_results
+ALTER TABLE analysis_results
+DROP INDEX idx_analysis_results_outcome,
+DROP COLUMN safety_ratings,
+DROP COLUMN fallback,
+DROP COLUMN outcome;
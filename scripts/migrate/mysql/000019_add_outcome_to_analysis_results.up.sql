@@ -0,0 +1,6 @@
+-- Up Migration: Record Gemini outcome (safety / max_tokens / recitation), fallback path and safety ratings
+ALTER TABLE analysis_results
+ADD COLUMN outcome VARCHAR(20) NULL DEFAULT NULL COMMENT 'Gemini 回應結果：ok、safety、max_tokens、recitation' AFTER model_params,
+ADD COLUMN fallback VARCHAR(20) NULL DEFAULT NULL COMMENT '備援分析方式：relaxed_safety、text_only' AFTER outcome,
+ADD COLUMN safety_ratings JSON NULL DEFAULT NULL COMMENT '被阻擋時的安全評級' AFTER fallback,
+ADD INDEX idx_analysis_results_outcome (outcome);
@@ -0,0 +1,2 @@
+-- Down Migration: Drop nas_files manifest
+DROP TABLE IF EXISTS nas_files;
@@ -0,0 +1,20 @@
+-- Up Migration: Manifest of files under NAS.VideoPath for incremental scans
+-- 每個影片ID目錄一筆 dir 紀錄 (以目錄修改時間判斷是否需要重新掃描)，另記錄選用的影片與 TXT 檔案
+
+CREATE TABLE nas_files (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    path VARCHAR(768) NOT NULL COMMENT '相對於 NAS.VideoPath 的路徑 (以 / 分隔)',
+    kind ENUM('dir', 'video', 'text') NOT NULL,
+    source_name VARCHAR(50) NOT NULL,
+    source_id VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL DEFAULT 0,
+    mtime_ns BIGINT NOT NULL COMMENT '修改時間 (Unix 奈秒)',
+    sha256 CHAR(64) NULL DEFAULT NULL,
+    analyzed_sha256 CHAR(64) NULL DEFAULT NULL COMMENT '最近一次文本元數據分析時的 sha256',
+    needs_reanalysis BOOLEAN NOT NULL DEFAULT FALSE COMMENT '檔案在分析後被修改，需重新分析',
+    video_id BIGINT NULL DEFAULT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_nas_files_path (path),
+    INDEX idx_nas_files_source (source_name, source_id),
+    FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
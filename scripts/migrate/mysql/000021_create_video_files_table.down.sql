@@ -0,0 +1,2 @@
+-- Down Migration: Drop video_files renditions
+DROP TABLE IF EXISTS video_files;
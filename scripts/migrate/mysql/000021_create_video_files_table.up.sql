@@ -0,0 +1,15 @@
+-- Up Migration: Renditions (master/proxy/audio) found in each video's NAS folder
+
+CREATE TABLE video_files (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    video_id BIGINT NOT NULL,
+    path VARCHAR(768) NOT NULL COMMENT '相對於 NAS.VideoPath 的路徑 (以 / 分隔)',
+    role ENUM('master', 'proxy', 'audio') NOT NULL,
+    width INT NULL DEFAULT NULL,
+    height INT NULL DEFAULT NULL,
+    size BIGINT NOT NULL DEFAULT 0,
+    mod_time DATETIME NULL DEFAULT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_video_files_video_path (video_id, path),
+    FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
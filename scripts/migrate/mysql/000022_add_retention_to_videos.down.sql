@@ -0,0 +1,18 @@
+-- Down Migration: Remove retention/archival state and pinning from videos
+UPDATE videos SET analysis_status = 'completed' WHERE analysis_status = 'archived';
+ALTER TABLE videos
+DROP COLUMN archive_reason,
+DROP COLUMN archived_at,
+DROP COLUMN pinned_at,
+DROP COLUMN pinned_by,
+DROP COLUMN pinned,
+MODIFY COLUMN analysis_status ENUM(
+    'pending',
+    'metadata_extracting',
+    'metadata_extracted',
+    'txt_analysis_failed',
+    'processing',
+    'video_analysis_failed',
+    'completed',
+    'failed'
+) NOT NULL DEFAULT 'pending';
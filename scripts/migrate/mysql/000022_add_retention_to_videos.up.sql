@@ -0,0 +1,18 @@
+-- Up Migration: Retention/archival state and editor pinning on videos
+ALTER TABLE videos
+MODIFY COLUMN analysis_status ENUM(
+    'pending',
+    'metadata_extracting',
+    'metadata_extracted',
+    'txt_analysis_failed',
+    'processing',
+    'video_analysis_failed',
+    'completed',
+    'failed',
+    'archived' -- 保留政策已刪除部分或全部影片版本 (TXT 與分析結果仍保留)
+) NOT NULL DEFAULT 'pending',
+ADD COLUMN pinned BOOLEAN NOT NULL DEFAULT FALSE COMMENT '編輯釘選，保留政策不會刪除其檔案',
+ADD COLUMN pinned_by VARCHAR(100) NULL DEFAULT NULL,
+ADD COLUMN pinned_at DATETIME NULL DEFAULT NULL,
+ADD COLUMN archived_at DATETIME NULL DEFAULT NULL,
+ADD COLUMN archive_reason TEXT NULL;
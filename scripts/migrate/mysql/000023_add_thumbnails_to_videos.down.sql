@@ -0,0 +1,5 @@
+-- Down Migration: Remove thumbnail paths from videos
+ALTER TABLE videos
+DROP COLUMN thumbnails_at,
+DROP COLUMN contact_sheet_path,
+DROP COLUMN poster_path;
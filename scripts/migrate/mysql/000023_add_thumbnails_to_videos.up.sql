@@ -0,0 +1,5 @@
+-- Up Migration: Poster frame and keyframe contact sheet generated next to the media
+ALTER TABLE videos
+ADD COLUMN poster_path VARCHAR(1024) NULL DEFAULT NULL COMMENT '海報圖片相對於 NAS 根目錄的路徑',
+ADD COLUMN contact_sheet_path VARCHAR(1024) NULL DEFAULT NULL COMMENT '關鍵影格縮圖表相對於 NAS 根目錄的路徑',
+ADD COLUMN thumbnails_at DATETIME NULL DEFAULT NULL COMMENT '最近一次產生縮圖的時間 (失敗時路徑為 NULL，不再重試)';
@@ -0,0 +1,2 @@
+-- Down Migration: Drop probed technical metadata
+DROP TABLE IF EXISTS video_media_info;
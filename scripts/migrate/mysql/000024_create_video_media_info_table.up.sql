@@ -0,0 +1,19 @@
+-- Up Migration: Technical metadata probed from the media file (ffprobe or built-in MP4 parser)
+
+CREATE TABLE video_media_info (
+    video_id BIGINT PRIMARY KEY,
+    path VARCHAR(768) NOT NULL COMMENT '取得資訊的影片版本，相對於 NAS.VideoPath (以 / 分隔)',
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    width INT NULL DEFAULT NULL,
+    height INT NULL DEFAULT NULL,
+    video_codec VARCHAR(32) NULL DEFAULT NULL,
+    audio_codec VARCHAR(32) NULL DEFAULT NULL,
+    frame_rate DECIMAL(9,3) NULL DEFAULT NULL,
+    audio_channels INT NULL DEFAULT NULL,
+    size BIGINT NOT NULL DEFAULT 0,
+    probed_with ENUM('ffprobe', 'mp4') NOT NULL,
+    txt_duration_secs BIGINT NULL DEFAULT NULL COMMENT 'TXT 記載的長度',
+    duration_mismatch BOOLEAN NOT NULL DEFAULT FALSE COMMENT 'TXT 記載的長度與實際長度差異超過 mediaProbe.durationToleranceSecs',
+    probed_at DATETIME NOT NULL,
+    FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
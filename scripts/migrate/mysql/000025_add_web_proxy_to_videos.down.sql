@@ -0,0 +1,6 @@
+-- Down Migration: Remove web proxy paths from videos
+ALTER TABLE videos
+DROP INDEX idx_videos_web_proxy_source,
+DROP COLUMN web_proxy_at,
+DROP COLUMN web_proxy_path,
+DROP COLUMN web_proxy_source;
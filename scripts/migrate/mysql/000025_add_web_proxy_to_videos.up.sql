@@ -0,0 +1,6 @@
+-- Up Migration: Browser-playable H.264/AAC proxy generated for non-web formats
+ALTER TABLE videos
+ADD COLUMN web_proxy_source VARCHAR(1024) NULL DEFAULT NULL COMMENT '產生播放版本的原始檔相對路徑',
+ADD COLUMN web_proxy_path VARCHAR(1024) NULL DEFAULT NULL COMMENT '播放版本 (MP4) 相對於 NAS 根目錄的路徑',
+ADD COLUMN web_proxy_at DATETIME NULL DEFAULT NULL COMMENT '最近一次檢查或產生播放版本的時間 (不需轉檔或失敗時路徑為 NULL，不再重試)',
+ADD INDEX idx_videos_web_proxy_source (web_proxy_source(255));